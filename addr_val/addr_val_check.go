@@ -2,12 +2,13 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"log"
 	"math"
-	"math/rand"
 	"time"
 	"os"
+	"sort"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
@@ -17,12 +18,15 @@ import (
 	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/frontend/cs/scs"
 	"github.com/consensys/gnark/std/hash/mimc"
-	"github.com/consensys/gnark/test"
+	"github.com/consensys/gnark/std/math/bits"
 
 	cs "github.com/consensys/gnark/constraint/bn254"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/benchreport"
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/shuffle"
 )
 
 const (
@@ -36,9 +40,33 @@ const (
 	BN254Size       = 32
 	CommitmentSize  = 32
 	TestRepeat      = 5
+
+	// bit widths of the range checks of PerAddressCheckCircuit
+	DefaultAmountBits = 64
+	DefaultAddrBits   = 160
 )
 
-var file *os.File
+// Format is the format of the results appended to output-aml.csv or
+// output-aml.jsonl, see internal/benchreport.
+var Format = benchreport.FormatCSV
+
+// reporter writes the result of every run.
+var reporter *benchreport.Reporter
+
+// report writes result with reporter; a failure only loses the result.
+func report(result benchreport.BenchmarkResult) {
+	if err := reporter.Report(result); err != nil {
+		log.Printf("report: %v\n", err)
+	}
+}
+
+// Seed makes the runs reproducible, see internal/benchrand. 0 draws
+// everything from crypto/rand.
+var Seed uint64
+
+// SortedSums makes the circuit compute the sums per address over a sorted
+// copy of the transactions, see PerAddressCheckCircuit.SortedRecv.
+var SortedSums bool
 
 var DummyVecLength uint64
 
@@ -101,8 +129,26 @@ type PerAddressCheckCircuit struct {
 	// The following are for the commitment
 	PublicCommitment frontend.Variable `gnark:",public"`
 	PrivateSalt      frontend.Variable
+
+	// Every amount has to fit in AmountBits bits and every address in
+	// AddrBits bits, so that the sums cannot wrap around the field and two
+	// addresses cannot collide modulo p. Zero disables the check.
+	AmountBits int
+	AddrBits   int
+
+	// SortedRecv and SortedAmt, when not empty, are the Recv and the Amt of
+	// PrivateTxs sorted by Recv, see SortByRecv. The sums per address are
+	// then computed in one pass over them instead of comparing every pair
+	// of transactions.
+	SortedRecv []frontend.Variable
+	SortedAmt  []frontend.Variable
 }
 
+// perAddressCheckPublic are the public variables of PerAddressCheckCircuit, in
+// the order of its public witness. The setup is not run for a circuit that
+// differs.
+var perAddressCheckPublic = []string{"PublicThreshold", "PublicR", "PublicProd", "PublicCommitment"}
+
 func (circuit *PerAddressCheckCircuit) Define(api frontend.API) error {
 	//First check that each tx corresponds to a valid hash
 	for i := 0; i < len(circuit.PrivateTxs); i++ {
@@ -114,16 +160,33 @@ func (circuit *PerAddressCheckCircuit) Define(api frontend.API) error {
 		api.AssertIsEqual(circuit.PrivateHash[i], mimc.Sum())
 	}
 
-	// Then, for each recv address, check that the sum of the amt to that address is less than the threshold
+	// Range check the amounts and the addresses
 	for i := 0; i < len(circuit.PrivateTxs); i++ {
-		current_addr := circuit.PrivateTxs[i].Recv
-		current_amount := frontend.Variable(0)
-		for j := 0; j < len(circuit.PrivateTxs); j++ {
-			diff := api.Sub(current_addr, circuit.PrivateTxs[j].Recv)
-			diff_is_zero := api.IsZero(diff)
-			current_amount = api.Add(current_amount, api.Mul(diff_is_zero, circuit.PrivateTxs[j].Amt))
+		if circuit.AmountBits > 0 {
+			bits.ToBinary(api, circuit.PrivateTxs[i].Amt, bits.WithNbDigits(circuit.AmountBits))
+		}
+		if circuit.AddrBits > 0 {
+			bits.ToBinary(api, circuit.PrivateTxs[i].Send, bits.WithNbDigits(circuit.AddrBits))
+			bits.ToBinary(api, circuit.PrivateTxs[i].Recv, bits.WithNbDigits(circuit.AddrBits))
+		}
+	}
+
+	// Then, for each recv address, check that the sum of the amt to that address is less than the threshold
+	if len(circuit.SortedRecv) > 0 {
+		if err := circuit.checkSortedSums(api); err != nil {
+			return err
+		}
+	} else {
+		for i := 0; i < len(circuit.PrivateTxs); i++ {
+			current_addr := circuit.PrivateTxs[i].Recv
+			current_amount := frontend.Variable(0)
+			for j := 0; j < len(circuit.PrivateTxs); j++ {
+				diff := api.Sub(current_addr, circuit.PrivateTxs[j].Recv)
+				diff_is_zero := api.IsZero(diff)
+				current_amount = api.Add(current_amount, api.Mul(diff_is_zero, circuit.PrivateTxs[j].Amt))
+			}
+			api.AssertIsLessOrEqual(current_amount, circuit.PublicThreshold)
 		}
-		api.AssertIsLessOrEqual(current_amount, circuit.PublicThreshold)
 	}
 
 	// The following is for the polynomial evaluation
@@ -144,6 +207,78 @@ func (circuit *PerAddressCheckCircuit) Define(api frontend.API) error {
 	return nil
 }
 
+// checkSortedSums checks the sums per address with the sorted copy of the
+// transactions, in O(n) constraints instead of O(n^2):
+//
+//   - the sorted copy is a permutation of the (Recv, Amt) of PrivateTxs: with
+//     alpha and beta drawn from a commitment to both,
+//     prod (beta - Recv - alpha * Amt) agrees on the two sides;
+//   - SortedRecv does not decrease, so the transactions to one address are
+//     adjacent;
+//   - the running sum of the current address restarts at every new address
+//     and is checked against the threshold at every step, its last one
+//     included.
+func (circuit *PerAddressCheckCircuit) checkSortedSums(api frontend.API) error {
+	n := len(circuit.PrivateTxs)
+	if len(circuit.SortedRecv) != n || len(circuit.SortedAmt) != n {
+		return fmt.Errorf("%v sorted recv and %v sorted amounts for %v transactions", len(circuit.SortedRecv), len(circuit.SortedAmt), n)
+	}
+	committer, ok := api.(frontend.Committer)
+	if !ok {
+		return fmt.Errorf("the builder cannot commit, the sorted sums need it")
+	}
+	toCommit := make([]frontend.Variable, 0, 4*n)
+	for i := 0; i < n; i++ {
+		toCommit = append(toCommit, circuit.PrivateTxs[i].Recv, circuit.PrivateTxs[i].Amt, circuit.SortedRecv[i], circuit.SortedAmt[i])
+	}
+	alpha, err := committer.Commit(toCommit...)
+	if err != nil {
+		return err
+	}
+	mimc, _ := mimc.NewMiMC(api)
+	mimc.Write(alpha)
+	beta := mimc.Sum()
+
+	original, sorted := frontend.Variable(1), frontend.Variable(1)
+	for i := 0; i < n; i++ {
+		original = api.Mul(original, api.Sub(beta, circuit.PrivateTxs[i].Recv, api.Mul(alpha, circuit.PrivateTxs[i].Amt)))
+		sorted = api.Mul(sorted, api.Sub(beta, circuit.SortedRecv[i], api.Mul(alpha, circuit.SortedAmt[i])))
+	}
+	api.AssertIsEqual(original, sorted)
+
+	running := circuit.SortedAmt[0]
+	api.AssertIsLessOrEqual(running, circuit.PublicThreshold)
+	for i := 1; i < n; i++ {
+		diff := api.Sub(circuit.SortedRecv[i], circuit.SortedRecv[i-1])
+		if circuit.AddrBits > 0 {
+			// the addresses are range checked, so a difference that fits
+			// is not negative
+			bits.ToBinary(api, diff, bits.WithNbDigits(circuit.AddrBits))
+		} else {
+			api.AssertIsLessOrEqual(circuit.SortedRecv[i-1], circuit.SortedRecv[i])
+		}
+		running = api.Add(circuit.SortedAmt[i], api.Mul(api.IsZero(diff), running))
+		api.AssertIsLessOrEqual(running, circuit.PublicThreshold)
+	}
+	return nil
+}
+
+// SortByRecv returns the Recv and the Amt of txs sorted by Recv, the
+// SortedRecv and SortedAmt of PerAddressCheckCircuit.
+func SortByRecv(txs []PrivateTx) ([]frontend.Variable, []frontend.Variable) {
+	sorted := append([]PrivateTx(nil), txs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Recv.Cmp(&sorted[j].Recv) < 0
+	})
+	recv := make([]frontend.Variable, len(sorted))
+	amt := make([]frontend.Variable, len(sorted))
+	for i := 0; i < len(sorted); i++ {
+		recv[i] = frontend.Variable(sorted[i].Recv)
+		amt[i] = frontend.Variable(sorted[i].Amt)
+	}
+	return recv, amt
+}
+
 // generate a random element in fr_bn254
 func randomFr() fr_bn254.Element {
 	var e fr_bn254.Element
@@ -200,14 +335,17 @@ func GenProofGroth16(privateTxs []PrivateTx, privateHash []fr_bn254.Element,
 		PublicCommitment: frontend.Variable(com),
 		PrivateSalt:      frontend.Variable(salt),
 	}
+	if SortedSums {
+		assignment.SortedRecv, assignment.SortedAmt = SortByRecv(privateTxs)
+	}
 
 	if realProof {
-		witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+		witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 		//fmt.Println(witness)
 		publicWitness, _ := witness.Public()
 
 		// groth16: Prove & Verify
-		proof, _ := groth16.Prove(*ccs, *pk, witness)
+		proof, _ := gnarkshim.ProveGroth16(*ccs, *pk, witness)
 
 		return ClientSubmissionToServer{
 			publicWitness: &publicWitness,
@@ -255,14 +393,17 @@ func GenProofPlonk(privateTxs []PrivateTx, privateHash []fr_bn254.Element,
 		PublicCommitment: frontend.Variable(com),
 		PrivateSalt:      frontend.Variable(salt),
 	}
+	if SortedSums {
+		assignment.SortedRecv, assignment.SortedAmt = SortByRecv(privateTxs)
+	}
 
 	if realProof {
-		witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+		witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 		//fmt.Println(witness)
 		publicWitness, _ := witness.Public()
 
 		// groth16: Prove & Verify
-		proof, _ := plonk.Prove(*ccs, *pk, witness)
+		proof, _ := gnarkshim.ProvePlonk(*ccs, *pk, witness)
 
 		return ClientSubmissionToServerPlonk{
 			publicWitness: &publicWitness,
@@ -334,12 +475,12 @@ func SplitAndShareWithProof(secretVal uint64, publicRFr fr_bn254.Element, ccs *c
 		PublicR:         publicR,
 		PublicProd:      publicProd,
 	}
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	//fmt.Println(witness)
 	publicWitness, _ := witness.Public()
 
 	// groth16: Prove & Verify
-	proof, _ := groth16.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProveGroth16(*ccs, *pk, witness)
 
 	submissionToShuffler := ClientSubmissionToShuffler{
 		privateVec: privateVecFr,
@@ -385,13 +526,22 @@ func ShuffleZKGroth16() {
 		PublicProd:       0,
 		PublicCommitment: 0,
 		PrivateSalt:      0,
+		AmountBits:       DefaultAmountBits,
+		AddrBits:         DefaultAddrBits,
+	}
+	if SortedSums {
+		circuit.SortedRecv = make([]frontend.Variable, PrivateTxNum)
+		circuit.SortedAmt = make([]frontend.Variable, PrivateTxNum)
 	}
 
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	ccs, _ := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err := gnarkshim.CheckPublic(ccs, perAddressCheckPublic); err != nil {
+		log.Fatal(err)
+	}
 
 	// groth16 zkSNARK: Setup
-	pk, vk, _ := groth16.Setup(ccs)
+	pk, vk, _ := gnarkshim.SetupGroth16(ccs)
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -401,8 +551,7 @@ func ShuffleZKGroth16() {
 
 	//publicRFr := fr_bn254.NewElement(uint64(1))
 
-	source := rand.NewSource(time.Now().UnixNano())
-	rng := rand.New(source)
+	rnd := benchrand.New(Seed)
 
 	allPrivateTxs := make([][]PrivateTx, ClientNum)
 	allPrivateHash := make([][]fr_bn254.Element, ClientNum)
@@ -411,23 +560,27 @@ func ShuffleZKGroth16() {
 	privateSalt := make([]fr_bn254.Element, ClientNum)
 	commitment := make([]fr_bn254.Element, ClientNum)
 
-	shuffledHash := make([]fr_bn254.Element, ClientNum*PrivateTxNum)
-	shuffledMask := make([]fr_bn254.Element, ClientNum*DummyVecLength)
+	// the shuffler moves every hash on its own
+	shuffler := shuffle.New(rnd, 1)
 
 	start := time.Now()
 
+	// Step 1:
+	// Each client hashes its transactions and commits to the hashes, its mask and a salt.
+	// It sends the hashes and the split mask to the shuffler and the commitment to the server,
+	// before the challenge is drawn.
 	for i := 0; i < ClientNum; i++ {
 		allPrivateTxs[i] = make([]PrivateTx, PrivateTxNum)
 		allPrivateHash[i] = make([]fr_bn254.Element, PrivateTxNum)
 		for j := 0; j < PrivateTxNum; j++ {
 			send := i
-			recv := rng.Intn(ClientNum)
-			amt := rng.Intn(100)
+			recv := rnd.Intn(ClientNum)
+			amt := rnd.Intn(100)
 
 			allPrivateTxs[i][j].Send = fr_bn254.NewElement(uint64(send))
 			allPrivateTxs[i][j].Recv = fr_bn254.NewElement(uint64(recv))
 			allPrivateTxs[i][j].Amt = fr_bn254.NewElement(uint64(amt))
-			allPrivateTxs[i][j].Tx_salt = randomFr()
+			allPrivateTxs[i][j].Tx_salt = rnd.Fr()
 			// mimc hash and store the hash
 			goMimc := hash.MIMC_BN254.New()
 			tmpBytes := allPrivateTxs[i][j].Send.Bytes()
@@ -444,12 +597,12 @@ func ShuffleZKGroth16() {
 		privateMask[i] = fr_bn254.One()
 		splittedSecretMask[i] = make([]fr_bn254.Element, DummyVecLength)
 		for j := 0; j < len(splittedSecretMask[i]); j++ {
-			splittedSecretMask[i][j] = randomFr()
+			splittedSecretMask[i][j] = rnd.Fr()
 			privateMask[i].Mul(&privateMask[i], &splittedSecretMask[i][j])
 		}
 
 		// compute the commitment
-		privateSalt[i] = randomFr()
+		privateSalt[i] = rnd.Fr()
 		goMimc := hash.MIMC_BN254.New()
 		for j := 0; j < len(allPrivateHash[i]); j++ {
 			b := allPrivateHash[i][j].Bytes()
@@ -461,30 +614,22 @@ func ShuffleZKGroth16() {
 		goMimc.Write(b[:])
 		commitment[i].SetBytes(goMimc.Sum(nil))
 
-		// append the private hash and the private mask to the shuffled hash and shuffled mask
-		for j := 0; j < len(allPrivateHash[i]); j++ {
-			shuffledHash[i*PrivateTxNum+j] = allPrivateHash[i][j]
-		}
-		for j := 0; j < len(splittedSecretMask[i]); j++ {
-			shuffledMask[i*int(DummyVecLength)+j] = splittedSecretMask[i][j]
-		}
+		// send the private hash and the split mask to the shuffler
+		shuffler.CollectShares(i, shuffle.Records(allPrivateHash[i]))
+		shuffler.CollectDummies(i, splittedSecretMask[i])
 	}
 
 	prepTime := time.Since(start)
 
-	//shuffle the shuffledHash and shuffledMask
-	rand.Shuffle(len(shuffledHash), func(i, j int) {
-		shuffledHash[i], shuffledHash[j] = shuffledHash[j], shuffledHash[i]
-	})
-	rand.Shuffle(len(shuffledMask), func(i, j int) {
-		shuffledMask[i], shuffledMask[j] = shuffledMask[j], shuffledMask[i]
-	})
+	//shuffle the hashes and the masks
+	shuffledRecords, shuffledMask := shuffler.Output()
+	shuffledHash := shuffle.Column(shuffledRecords, 0)
 
 	// now the server can see the shuffled hash and shuffled mask
 
 	// Step 2:
 	// The server generates a public challenge and broadcasts it to all the clients.
-	publicRFr := randomFr()
+	publicRFr := rnd.Fr()
 
 	// Step 3:
 	// Each client computes the public witness and the public product and sends them to the server.
@@ -532,7 +677,7 @@ func ShuffleZKGroth16() {
 		//verify proof
 		//fmt.Printf("proof: %v
 		if i < MaxNumOfCheckProof {
-			verification_err := groth16.Verify(*allProof[i].proof, vk, *allProof[i].publicWitness)
+			verification_err := gnarkshim.VerifyGroth16(*allProof[i].proof, vk, *allProof[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
@@ -570,11 +715,28 @@ func ShuffleZKGroth16() {
 	log.Printf("To Shuffler %v\n", dummyCostPerClient)
 	log.Printf("To Server %v\n", proofSize+publicWitnessSize+CommitmentSize+BN254Size) // a commitment, a public prod, a proof, a public witness
 
-	clientTime := proving_time / time.Duration(MaxNumOfCheckProof) + prepTime/time.Duration(ClientNum)
-	amtServerTime := verifying_time/time.Duration(ClientNum) + verifying_time_only_proof/time.Duration(MaxNumOfCheckProof)
-	commCost := (float64(dummyCostPerClient) + float64(proofSize)+float64(publicWitnessSize)+float64(CommitmentSize)+float64(BN254Size) ) / 1024
 
-	file.WriteString(fmt.Sprintf("AML Groth16, %v, %v, %v, %v\n", ClientNum - CorruptedNum, clientTime, amtServerTime, commCost))
+	report(benchreport.BenchmarkResult{
+		Program:      "addr_val",
+		Backend:      "Groth16",
+		Curve:        ecc.BN254.String(),
+		Constraints:  ccs.GetNbConstraints(),
+		ClientNum:    ClientNum,
+		CorruptedNum: CorruptedNum,
+		DummyLen:     DummyVecLength,
+		PrepTime:     prepTime / time.Duration(ClientNum),
+		ProofTime:    proving_time / time.Duration(MaxNumOfCheckProof),
+		VerifyTime:   verifying_time_only_proof / time.Duration(MaxNumOfCheckProof),
+		ServerTime:   verifying_time / time.Duration(ClientNum),
+		ProofSize:    proofSize,
+		WitnessSize:  publicWitnessSize,
+		PKSize:       provingKeySize,
+		CommCost: benchreport.CommCost{
+			Proof: uint64(proofSize),
+			Other: dummyCostPerClient + uint64(publicWitnessSize+CommitmentSize+BN254Size),
+			Total: dummyCostPerClient + uint64(proofSize+publicWitnessSize+CommitmentSize+BN254Size),
+		},
+	})
 }
 
 func ShuffleZKPlonk() {
@@ -606,24 +768,33 @@ func ShuffleZKPlonk() {
 		PublicProd:       0,
 		PublicCommitment: 0,
 		PrivateSalt:      0,
+		AmountBits:       DefaultAmountBits,
+		AddrBits:         DefaultAddrBits,
+	}
+	if SortedSums {
+		circuit.SortedRecv = make([]frontend.Variable, PrivateTxNum)
+		circuit.SortedAmt = make([]frontend.Variable, PrivateTxNum)
 	}
 
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	ccs, err := gnarkshim.CompileSCS(ecc.BN254, &circuit)
 	if err != nil {
 		log.Println("scs circuit compile error")
 	}
+	if err := gnarkshim.CheckPublic(ccs, perAddressCheckPublic); err != nil {
+		log.Fatal(err)
+	}
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
 
 	//setup kzg
 	_r1cs := ccs.(*cs.SparseR1CS)
-	srs, err := test.NewKZGSRS(_r1cs)
+	srs, err := gnarkshim.NewKZGSRS(_r1cs)
 	if err != nil {
 		log.Println("kzg srs error")
 	}
 
 	// plonk zkSNARK: Setup
-	pk, vk, _ := plonk.Setup(ccs, srs)
+	pk, vk, _ := gnarkshim.SetupPlonk(ccs, srs)
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -633,8 +804,7 @@ func ShuffleZKPlonk() {
 
 	//publicRFr := fr_bn254.NewElement(uint64(1))
 
-	source := rand.NewSource(time.Now().UnixNano())
-	rng := rand.New(source)
+	rnd := benchrand.New(Seed)
 
 	allPrivateTxs := make([][]PrivateTx, ClientNum)
 	allPrivateHash := make([][]fr_bn254.Element, ClientNum)
@@ -643,23 +813,27 @@ func ShuffleZKPlonk() {
 	privateSalt := make([]fr_bn254.Element, ClientNum)
 	commitment := make([]fr_bn254.Element, ClientNum)
 
-	shuffledHash := make([]fr_bn254.Element, ClientNum*PrivateTxNum)
-	shuffledMask := make([]fr_bn254.Element, ClientNum*DummyVecLength)
+	// the shuffler moves every hash on its own
+	shuffler := shuffle.New(rnd, 1)
 
 	start := time.Now()
 
+	// Step 1:
+	// Each client hashes its transactions and commits to the hashes, its mask and a salt.
+	// It sends the hashes and the split mask to the shuffler and the commitment to the server,
+	// before the challenge is drawn.
 	for i := 0; i < ClientNum; i++ {
 		allPrivateTxs[i] = make([]PrivateTx, PrivateTxNum)
 		allPrivateHash[i] = make([]fr_bn254.Element, PrivateTxNum)
 		for j := 0; j < PrivateTxNum; j++ {
 			send := i
-			recv := rng.Intn(ClientNum)
-			amt := rng.Intn(100)
+			recv := rnd.Intn(ClientNum)
+			amt := rnd.Intn(100)
 
 			allPrivateTxs[i][j].Send = fr_bn254.NewElement(uint64(send))
 			allPrivateTxs[i][j].Recv = fr_bn254.NewElement(uint64(recv))
 			allPrivateTxs[i][j].Amt = fr_bn254.NewElement(uint64(amt))
-			allPrivateTxs[i][j].Tx_salt = randomFr()
+			allPrivateTxs[i][j].Tx_salt = rnd.Fr()
 			// mimc hash and store the hash
 			goMimc := hash.MIMC_BN254.New()
 			tmpBytes := allPrivateTxs[i][j].Send.Bytes()
@@ -676,12 +850,12 @@ func ShuffleZKPlonk() {
 		privateMask[i] = fr_bn254.One()
 		splittedSecretMask[i] = make([]fr_bn254.Element, DummyVecLength)
 		for j := 0; j < len(splittedSecretMask[i]); j++ {
-			splittedSecretMask[i][j] = randomFr()
+			splittedSecretMask[i][j] = rnd.Fr()
 			privateMask[i].Mul(&privateMask[i], &splittedSecretMask[i][j])
 		}
 
 		// compute the commitment
-		privateSalt[i] = randomFr()
+		privateSalt[i] = rnd.Fr()
 		goMimc := hash.MIMC_BN254.New()
 		for j := 0; j < len(allPrivateHash[i]); j++ {
 			b := allPrivateHash[i][j].Bytes()
@@ -693,30 +867,22 @@ func ShuffleZKPlonk() {
 		goMimc.Write(b[:])
 		commitment[i].SetBytes(goMimc.Sum(nil))
 
-		// append the private hash and the private mask to the shuffled hash and shuffled mask
-		for j := 0; j < len(allPrivateHash[i]); j++ {
-			shuffledHash[i*PrivateTxNum+j] = allPrivateHash[i][j]
-		}
-		for j := 0; j < len(splittedSecretMask[i]); j++ {
-			shuffledMask[i*int(DummyVecLength)+j] = splittedSecretMask[i][j]
-		}
+		// send the private hash and the split mask to the shuffler
+		shuffler.CollectShares(i, shuffle.Records(allPrivateHash[i]))
+		shuffler.CollectDummies(i, splittedSecretMask[i])
 	}
 
 	prepTime := time.Since(start)
 
-	//shuffle the shuffledHash and shuffledMask
-	rand.Shuffle(len(shuffledHash), func(i, j int) {
-		shuffledHash[i], shuffledHash[j] = shuffledHash[j], shuffledHash[i]
-	})
-	rand.Shuffle(len(shuffledMask), func(i, j int) {
-		shuffledMask[i], shuffledMask[j] = shuffledMask[j], shuffledMask[i]
-	})
+	//shuffle the hashes and the masks
+	shuffledRecords, shuffledMask := shuffler.Output()
+	shuffledHash := shuffle.Column(shuffledRecords, 0)
 
 	// now the server can see the shuffled hash and shuffled mask
 
 	// Step 2:
 	// The server generates a public challenge and broadcasts it to all the clients.
-	publicRFr := randomFr()
+	publicRFr := rnd.Fr()
 
 	// Step 3:
 	// Each client computes the public witness and the public product and sends them to the server.
@@ -764,7 +930,7 @@ func ShuffleZKPlonk() {
 		//verify proof
 		//fmt.Printf("proof: %v
 		if i < MaxNumOfCheckProof {
-			verification_err := plonk.Verify(*allProof[i].proof, vk, *allProof[i].publicWitness)
+			verification_err := gnarkshim.VerifyPlonk(*allProof[i].proof, vk, *allProof[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
@@ -803,25 +969,47 @@ func ShuffleZKPlonk() {
 	log.Printf("To Server %v\n", proofSize+publicWitnessSize+CommitmentSize+BN254Size) // a commitment, a public prod, a proof, a public witness
 
 	
-	clientTime := proving_time / time.Duration(MaxNumOfCheckProof) + prepTime/time.Duration(ClientNum)
-	amtServerTime := verifying_time/time.Duration(ClientNum) + verifying_time_only_proof/time.Duration(MaxNumOfCheckProof)
-	commCost := (float64(dummyCostPerClient) + float64(proofSize)+float64(publicWitnessSize)+float64(CommitmentSize)+float64(BN254Size) ) / 1024
-	//commCost := dummyCostPerClient + proofSize+publicWitnessSize+CommitmentSize+BN254Size
 
-	file.WriteString(fmt.Sprintf("AML Plonk, %v, %v, %v, %v\n", ClientNum - CorruptedNum, clientTime, amtServerTime, commCost))
+	report(benchreport.BenchmarkResult{
+		Program:      "addr_val",
+		Backend:      "Plonk",
+		Curve:        ecc.BN254.String(),
+		Constraints:  ccs.GetNbConstraints(),
+		ClientNum:    ClientNum,
+		CorruptedNum: CorruptedNum,
+		DummyLen:     DummyVecLength,
+		PrepTime:     prepTime / time.Duration(ClientNum),
+		ProofTime:    proving_time / time.Duration(MaxNumOfCheckProof),
+		VerifyTime:   verifying_time_only_proof / time.Duration(MaxNumOfCheckProof),
+		ServerTime:   verifying_time / time.Duration(ClientNum),
+		ProofSize:    proofSize,
+		WitnessSize:  publicWitnessSize,
+		PKSize:       provingKeySize,
+		CommCost: benchreport.CommCost{
+			Proof: uint64(proofSize),
+			Other: dummyCostPerClient + uint64(publicWitnessSize+CommitmentSize+BN254Size),
+			Total: dummyCostPerClient + uint64(proofSize+publicWitnessSize+CommitmentSize+BN254Size),
+		},
+	})
 }
 
 func main() {
+	flag.Uint64Var(&Seed, "seed", Seed, "seed of a reproducible run, for benchmarks only; 0 for crypto/rand")
+	format := flag.String("format", Format.String(), "format of the results, csv or json")
+	flag.BoolVar(&SortedSums, "sorted", SortedSums, "compute the sums per address over a sorted copy of the transactions, in O(n) constraints")
+	flag.Parse()
 	var err error
-	file, err = os.OpenFile("output-aml.csv", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if Format, err = benchreport.ParseFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	rep, file, err := benchreport.OpenReporter("output-aml"+Format.Ext(), Format)
 	if err != nil {
 		panic(err)
 	}
 
 	defer file.Close()
-
-	file.WriteString("Name, Honest Client Num, Client Time, Server Time, Communication Cost\n")
-
+	reporter = rep
 
 	for t := 0; t < TestRepeat; t++ {
 		ShuffleZKGroth16()
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// txHashes hashes every transaction of txs as a client does.
+func txHashes(txs []PrivateTx) []fr_bn254.Element {
+	hashes := make([]fr_bn254.Element, len(txs))
+	for i := 0; i < len(txs); i++ {
+		goMimc := hash.MIMC_BN254.New()
+		for _, x := range []fr_bn254.Element{txs[i].Send, txs[i].Recv, txs[i].Amt, txs[i].Tx_salt} {
+			b := x.Bytes()
+			goMimc.Write(b[:])
+		}
+		hashes[i].SetBytes(goMimc.Sum(nil))
+	}
+	return hashes
+}
+
+// commitHashes is the commitment of a client to its transaction hashes.
+func commitHashes(hashes []fr_bn254.Element, mask fr_bn254.Element, salt fr_bn254.Element) fr_bn254.Element {
+	goMimc := hash.MIMC_BN254.New()
+	for _, x := range append(append([]fr_bn254.Element(nil), hashes...), mask, salt) {
+		b := x.Bytes()
+		goMimc.Write(b[:])
+	}
+	var com fr_bn254.Element
+	com.SetBytes(goMimc.Sum(nil))
+	return com
+}
+
+// txAssignment hashes and commits to txs as a client does, with a mask of one.
+func txAssignment(txs []PrivateTx) PerAddressCheckCircuit {
+	publicR := randomFr()
+	mask := fr_bn254.One()
+	salt := randomFr()
+
+	hashes := txHashes(txs)
+	txsVar := make([]PrivateTxVar, len(txs))
+	hashesVar := make([]frontend.Variable, len(txs))
+	for i := 0; i < len(txs); i++ {
+		hashesVar[i] = hashes[i]
+		txsVar[i] = PrivateTxVar{Send: txs[i].Send, Recv: txs[i].Recv, Amt: txs[i].Amt, Tx_salt: txs[i].Tx_salt}
+	}
+	com := commitHashes(hashes, mask, salt)
+	publicProd := PolyEval(hashes, publicR)
+
+	return PerAddressCheckCircuit{
+		PrivateTxs:       txsVar,
+		PrivateHash:      hashesVar,
+		PublicThreshold:  PublicThreshold,
+		PrivateMask:      mask,
+		PublicR:          publicR,
+		PublicProd:       publicProd,
+		PublicCommitment: com,
+		PrivateSalt:      salt,
+	}
+}
+
+func TestPerAddressCheckRanges(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// two amounts to the same address, 2^200 and p - 2^200 + 5, sum to 5
+	var huge, rest fr_bn254.Element
+	huge.SetBigInt(new(big.Int).Lsh(big.NewInt(1), 200))
+	rest.SetUint64(5)
+	rest.Sub(&rest, &huge)
+	wrapping := txAssignment([]PrivateTx{
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(2), Amt: huge, Tx_salt: randomFr()},
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(2), Amt: rest, Tx_salt: randomFr()},
+	})
+	honest := txAssignment([]PrivateTx{
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(2), Amt: fr_bn254.NewElement(99), Tx_salt: randomFr()},
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(3), Amt: fr_bn254.NewElement(0), Tx_salt: randomFr()},
+	})
+
+	unchecked := PerAddressCheckCircuit{PrivateTxs: make([]PrivateTxVar, 2), PrivateHash: make([]frontend.Variable, 2)}
+	assert.ProverSucceeded(&unchecked, &wrapping, test.WithCurves(ecc.BN254))
+
+	checked := PerAddressCheckCircuit{
+		PrivateTxs:  make([]PrivateTxVar, 2),
+		PrivateHash: make([]frontend.Variable, 2),
+		AmountBits:  DefaultAmountBits,
+		AddrBits:    DefaultAddrBits,
+	}
+	assert.ProverFailed(&checked, &wrapping, test.WithCurves(ecc.BN254))
+	assert.ProverSucceeded(&checked, &honest, test.WithCurves(ecc.BN254))
+
+	// an address over AddrBits bits
+	var addr fr_bn254.Element
+	addr.SetBigInt(new(big.Int).Lsh(big.NewInt(1), DefaultAddrBits))
+	longAddr := txAssignment([]PrivateTx{
+		{Send: fr_bn254.NewElement(1), Recv: addr, Amt: fr_bn254.NewElement(1), Tx_salt: randomFr()},
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(3), Amt: fr_bn254.NewElement(1), Tx_salt: randomFr()},
+	})
+	assert.ProverFailed(&checked, &longAddr, test.WithCurves(ecc.BN254))
+}
+
+func TestPerAddressCheckCommitment(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	txs := []PrivateTx{
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(2), Amt: fr_bn254.NewElement(10), Tx_salt: randomFr()},
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(3), Amt: fr_bn254.NewElement(20), Tx_salt: randomFr()},
+	}
+	committed := txAssignment(txs)
+	circuit := PerAddressCheckCircuit{PrivateTxs: make([]PrivateTxVar, 2), PrivateHash: make([]frontend.Variable, 2)}
+	assert.ProverSucceeded(&circuit, &committed, test.WithCurves(ecc.BN254))
+
+	// the client changes a transaction after committing; the hashes and the
+	// product are consistent with the new transaction, the commitment is not
+	txs[1].Amt = fr_bn254.NewElement(21)
+	changed := txAssignment(txs)
+	changed.PublicCommitment = committed.PublicCommitment
+	assert.ProverFailed(&circuit, &changed, test.WithCurves(ecc.BN254))
+}
+
+// TestGenProofGroth16 compiles the circuit, then proves and verifies one small
+// batch of transactions with the groth16 backend of the benchmark.
+func TestGenProofGroth16(t *testing.T) {
+	txs := []PrivateTx{
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(2), Amt: fr_bn254.NewElement(10), Tx_salt: randomFr()},
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(3), Amt: fr_bn254.NewElement(20), Tx_salt: randomFr()},
+	}
+	circuit := PerAddressCheckCircuit{
+		PrivateTxs:  make([]PrivateTxVar, len(txs)),
+		PrivateHash: make([]frontend.Variable, len(txs)),
+		AmountBits:  DefaultAmountBits,
+		AddrBits:    DefaultAddrBits,
+	}
+	ccs, err := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := txHashes(txs)
+	mask, salt := randomFr(), randomFr()
+	com := commitHashes(hashes, mask, salt)
+	publicR := randomFr()
+	sub := GenProofGroth16(txs, hashes, publicR, mask, com, salt, &ccs, &pk, true)
+	if sub.proof == nil || sub.publicWitness == nil {
+		t.Fatal("no proof generated")
+	}
+	if err := gnarkshim.VerifyGroth16(*sub.proof, vk, *sub.publicWitness); err != nil {
+		t.Fatal(err)
+	}
+	expected := PolyEval(hashes, publicR)
+	expected.Mul(&expected, &mask)
+	if !sub.publicProd.Equal(&expected) {
+		t.Fatal("the submitted product is not the masked evaluation of the hashes")
+	}
+}
+
+// TestGenProofPlonk is TestGenProofGroth16 with the plonk backend, whose
+// proofs the benchmark reports next to the groth16 ones.
+func TestGenProofPlonk(t *testing.T) {
+	txs := []PrivateTx{
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(2), Amt: fr_bn254.NewElement(10), Tx_salt: randomFr()},
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(3), Amt: fr_bn254.NewElement(20), Tx_salt: randomFr()},
+	}
+	circuit := PerAddressCheckCircuit{
+		PrivateTxs:  make([]PrivateTxVar, len(txs)),
+		PrivateHash: make([]frontend.Variable, len(txs)),
+		AmountBits:  DefaultAmountBits,
+		AddrBits:    DefaultAddrBits,
+	}
+	ccs, err := gnarkshim.CompileSCS(ecc.BN254, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srs, err := gnarkshim.NewKZGSRS(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := gnarkshim.SetupPlonk(ccs, srs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := txHashes(txs)
+	mask, salt := randomFr(), randomFr()
+	com := commitHashes(hashes, mask, salt)
+	publicR := randomFr()
+	sub := GenProofPlonk(txs, hashes, publicR, mask, com, salt, &ccs, &pk, true)
+	if sub.proof == nil || sub.publicWitness == nil {
+		t.Fatal("no proof generated")
+	}
+	if err := gnarkshim.VerifyPlonk(*sub.proof, vk, *sub.publicWitness); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sortedAssignment is txAssignment with the sorted copy of txs.
+func sortedAssignment(txs []PrivateTx) PerAddressCheckCircuit {
+	assignment := txAssignment(txs)
+	assignment.SortedRecv, assignment.SortedAmt = SortByRecv(txs)
+	return assignment
+}
+
+// sortedCircuit is a range checked circuit for n transactions that sums them
+// per address over their sorted copy.
+func sortedCircuit(n int) PerAddressCheckCircuit {
+	return PerAddressCheckCircuit{
+		PrivateTxs:  make([]PrivateTxVar, n),
+		PrivateHash: make([]frontend.Variable, n),
+		AmountBits:  DefaultAmountBits,
+		AddrBits:    DefaultAddrBits,
+		SortedRecv:  make([]frontend.Variable, n),
+		SortedAmt:   make([]frontend.Variable, n),
+	}
+}
+
+func TestPerAddressCheckSorted(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := sortedCircuit(3)
+
+	// 2 receives 6000 + 4000, at the threshold, with a transaction to 3 in
+	// between
+	txs := []PrivateTx{
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(2), Amt: fr_bn254.NewElement(6000), Tx_salt: randomFr()},
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(3), Amt: fr_bn254.NewElement(9000), Tx_salt: randomFr()},
+		{Send: fr_bn254.NewElement(1), Recv: fr_bn254.NewElement(2), Amt: fr_bn254.NewElement(4000), Tx_salt: randomFr()},
+	}
+	honest := sortedAssignment(txs)
+	assert.ProverSucceeded(&circuit, &honest, test.WithCurves(ecc.BN254))
+
+	// 2 receives one more over the threshold
+	txs[2].Amt = fr_bn254.NewElement(4001)
+	over := sortedAssignment(txs)
+	assert.ProverFailed(&circuit, &over, test.WithCurves(ecc.BN254))
+	txs[2].Amt = fr_bn254.NewElement(4000)
+
+	// a sorted copy that hides part of the amount to 2
+	hidden := sortedAssignment(txs)
+	hidden.SortedAmt = append([]frontend.Variable(nil), hidden.SortedAmt...)
+	hidden.SortedAmt[1] = fr_bn254.NewElement(0)
+	assert.ProverFailed(&circuit, &hidden, test.WithCurves(ecc.BN254))
+
+	// a copy that is a permutation but not sorted splits the group of 2
+	unsorted := sortedAssignment(txs)
+	unsorted.SortedRecv = []frontend.Variable{txs[0].Recv, txs[1].Recv, txs[2].Recv}
+	unsorted.SortedAmt = []frontend.Variable{txs[0].Amt, txs[1].Amt, txs[2].Amt}
+	assert.ProverFailed(&circuit, &unsorted, test.WithCurves(ecc.BN254))
+}
+
+// TestSortedSumsConstraints compares the constraint counts of the two ways to
+// sum per address, for the 100 transactions of a client.
+func TestSortedSumsConstraints(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles two circuits of 100 transactions")
+	}
+	const n = 100
+	nested := sortedCircuit(n)
+	nested.SortedRecv, nested.SortedAmt = nil, nil
+	sorted := sortedCircuit(n)
+
+	nestedCCS, err := gnarkshim.CompileR1CS(ecc.BN254, &nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortedCCS, err := gnarkshim.CompileR1CS(ecc.BN254, &sorted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%v transactions: %v constraints nested, %v sorted", n, nestedCCS.GetNbConstraints(), sortedCCS.GetNbConstraints())
+	if sortedCCS.GetNbConstraints() >= nestedCCS.GetNbConstraints() {
+		t.Fatalf("the sorted sums take %v constraints, the nested ones %v", sortedCCS.GetNbConstraints(), nestedCCS.GetNbConstraints())
+	}
+}
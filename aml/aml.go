@@ -17,12 +17,11 @@ import (
 	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/frontend/cs/scs"
 	"github.com/consensys/gnark/std/hash/mimc"
-	"github.com/consensys/gnark/test"
 
 	cs "github.com/consensys/gnark/constraint/bn254"
+
+	"example/verification/internal/gnarkshim"
 )
 
 const (
@@ -104,6 +103,13 @@ type AMLCircuit struct {
 	PrivateSalt      frontend.Variable
 }
 
+// amlPublic are the public variables of AMLCircuit, in the order of its public
+// witness. The setup is not run for a circuit that differs.
+func amlPublic() []string {
+	public := append([]string{"PublicThreshold"}, gnarkshim.PublicSlice("PublicBlacklist", BlacklistSize)...)
+	return append(public, "PublicR", "PublicProd", "PublicCommitment")
+}
+
 func (circuit *AMLCircuit) Define(api frontend.API) error {
 	//First check that each tx corresponds to a valid hash
 	for i := 0; i < len(circuit.PrivateTxs); i++ {
@@ -294,24 +300,24 @@ func (c *ClientState) GenAssignment(publicR fr_bn254.Element, blacklist []int) A
 
 func GenProofGroth16(assignment AMLCircuit, ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey) (*groth16.Proof, *witness.Witness) {
 	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	//fmt.Println(witness)
 	publicWitness, _ := witness.Public()
 
 	// groth16: Prove & Verify
-	proof, _ := groth16.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProveGroth16(*ccs, *pk, witness)
 
 	return &proof, &publicWitness
 }
 
 func GenProofPlonk(assignment AMLCircuit, ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey) (*plonk.Proof, *witness.Witness) {
 	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	//fmt.Println(witness)
 	publicWitness, _ := witness.Public()
 
 	// plonk: Prove & Verify
-	proof, _ := plonk.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProvePlonk(*ccs, *pk, witness)
 
 	return &proof, &publicWitness
 }
@@ -353,10 +359,13 @@ func AMLGroth16() {
 	}
 
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	ccs, _ := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err := gnarkshim.CheckPublic(ccs, amlPublic()); err != nil {
+		log.Fatal(err)
+	}
 
 	// groth16 zkSNARK: Setup
-	pk, vk, _ := groth16.Setup(ccs)
+	pk, vk, _ := gnarkshim.SetupGroth16(ccs)
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -471,7 +480,7 @@ func AMLGroth16() {
 	start = time.Now()
 	for i := 0; i < len(allSubmission); i++ {
 		if i < MaxNumOfCheckProof {
-			verification_err := groth16.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
+			verification_err := gnarkshim.VerifyGroth16(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
@@ -588,20 +597,23 @@ func AMLPlonk() {
 	}
 
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	ccs, err := gnarkshim.CompileSCS(ecc.BN254, &circuit)
 	if err != nil {
 		log.Println("scs circuit compile error")
 	}
+	if err := gnarkshim.CheckPublic(ccs, amlPublic()); err != nil {
+		log.Fatal(err)
+	}
 
 	//setup kzg
 	_r1cs := ccs.(*cs.SparseR1CS)
-	srs, err := test.NewKZGSRS(_r1cs)
+	srs, err := gnarkshim.NewKZGSRS(_r1cs)
 	if err != nil {
 		log.Println("kzg srs error")
 	}
 
 	// plonk zkSNARK: Setup
-	pk, vk, _ := plonk.Setup(ccs, srs)
+	pk, vk, _ := gnarkshim.SetupPlonk(ccs, srs)
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -716,7 +728,7 @@ func AMLPlonk() {
 	start = time.Now()
 	for i := 0; i < len(allSubmission); i++ {
 		if i < MaxNumOfCheckProof {
-			verification_err := plonk.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
+			verification_err := gnarkshim.VerifyPlonk(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
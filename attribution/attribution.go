@@ -17,13 +17,12 @@ import (
 	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/frontend/cs/scs"
 	"github.com/consensys/gnark/std/hash/mimc"
-	"github.com/consensys/gnark/test"
 
 	cs "github.com/consensys/gnark/constraint/bn254"
 	//"gonum.org/v1/gonum/stat/sampleuv"
+
+	"example/verification/internal/gnarkshim"
 )
 
 const (
@@ -139,6 +138,15 @@ type attributionCircuit struct {
 	MerklePositionY []frontend.Variable
 }
 
+// attributionPublic are the public variables of attributionCircuit, in the
+// order of its public witness. The setup is not run for a circuit that
+// differs.
+func attributionPublic() []string {
+	public := []string{"PublicCommitment", "MerkleRootX", "MerkleRootY"}
+	public = append(public, gnarkshim.PublicSlice("SerialNumX", PrivateVecLength)...)
+	return append(public, gnarkshim.PublicSlice("SerialNumY", DummyVecLength)...)
+}
+
 func (circuit *attributionCircuit) Define(api frontend.API) error {
 	//assert error if privateVec is empty
 
@@ -528,24 +536,24 @@ func (c *ClientState) GenAssignment(setX *SetAccumulator, setY *SetAccumulator)
 
 func GenProofGroth16(assignment attributionCircuit, ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey) (*groth16.Proof, *witness.Witness) {
 	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	//fmt.Println(witness)
 	publicWitness, _ := witness.Public()
 
 	// groth16: Prove & Verify
-	proof, _ := groth16.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProveGroth16(*ccs, *pk, witness)
 
 	return &proof, &publicWitness
 }
 
 func GenProofPlonk(assignment attributionCircuit, ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey) (*plonk.Proof, *witness.Witness) {
 	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	//fmt.Println(witness)
 	publicWitness, _ := witness.Public()
 
 	// plonk: Prove & Verify
-	proof, _ := plonk.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProvePlonk(*ccs, *pk, witness)
 
 	return &proof, &publicWitness
 }
@@ -580,10 +588,13 @@ func AttributionGroth16() {
 		MerklePositionY: make([]frontend.Variable, DummyVecLength * MerkleTreeHeight),
 	}
 
-	ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	ccs, _ := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err := gnarkshim.CheckPublic(ccs, attributionPublic()); err != nil {
+		log.Fatal(err)
+	}
 
 	// plonk zkSNARK: Setup
-	pk, vk, _ := groth16.Setup(ccs)
+	pk, vk, _ := gnarkshim.SetupGroth16(ccs)
 
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
@@ -680,7 +691,7 @@ func AttributionGroth16() {
 	start = time.Now()
 	for i := 0; i < len(allSubmission); i++ {
 		if i < MaxNumOfCheckProof {
-			verification_err := groth16.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
+			verification_err := gnarkshim.VerifyGroth16(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
@@ -813,20 +824,23 @@ func AttributionPlonk() {
 		MerklePositionY: make([]frontend.Variable, DummyVecLength * MerkleTreeHeight),
 	}
 
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	ccs, err := gnarkshim.CompileSCS(ecc.BN254, &circuit)
 	if err != nil {
 		log.Println("scs circuit compile error")
 	}
+	if err := gnarkshim.CheckPublic(ccs, attributionPublic()); err != nil {
+		log.Fatal(err)
+	}
 
 	//setup kzg
 	_r1cs := ccs.(*cs.SparseR1CS)
-	srs, err := test.NewKZGSRS(_r1cs)
+	srs, err := gnarkshim.NewKZGSRS(_r1cs)
 	if err != nil {
 		log.Println("kzg srs error")
 	}
 
 	// plonk zkSNARK: Setup
-	pk, vk, _ := plonk.Setup(ccs, srs)
+	pk, vk, _ := gnarkshim.SetupPlonk(ccs, srs)
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -922,7 +936,7 @@ func AttributionPlonk() {
 	start = time.Now()
 	for i := 0; i < len(allSubmission); i++ {
 		if i < MaxNumOfCheckProof {
-			verification_err := plonk.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
+			verification_err := gnarkshim.VerifyPlonk(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
@@ -1051,20 +1065,20 @@ func ShuffleZKPlonk() {
 		PrivateSalt:      0,
 	}
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	ccs, err := gnarkshim.CompileSCS(ecc.BN254, &circuit)
 	if err != nil {
 		log.Println("scs circuit compile error")
 	}
 
 	//setup kzg
 	_r1cs := ccs.(*cs.SparseR1CS)
-	srs, err := test.NewKZGSRS(_r1cs)
+	srs, err := gnarkshim.NewKZGSRS(_r1cs)
 	if err != nil {
 		log.Println("kzg srs error")
 	}
 
 	// plonk zkSNARK: Setup
-	pk, vk, _ := plonk.Setup(ccs, srs)
+	pk, vk, _ := gnarkshim.SetupPlonk(ccs, srs)
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -1214,7 +1228,7 @@ func ShuffleZKPlonk() {
 		//verify proof
 		//fmt.Printf("proof: %v
 		if i < MaxNumOfCheckProof {
-			verification_err := plonk.Verify(*allProof[i].proof, vk, *allProof[i].publicWitness)
+			verification_err := gnarkshim.VerifyPlonk(*allProof[i].proof, vk, *allProof[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"math"
 	"math/rand"
 	"os"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/consensys/gnark-crypto/ecc"
@@ -17,14 +21,16 @@ import (
 	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/frontend/cs/scs"
 	"github.com/consensys/gnark/std/hash/mimc"
-	"github.com/consensys/gnark/test"
 
 	//"gonum.org/v1/gonum/stat/sampleuv"
 
 	cs "github.com/consensys/gnark/constraint/bn254"
+
+	"example/verification/internal/benchreport"
+	"example/verification/internal/fiatshamir"
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/pool"
 )
 
 const (
@@ -47,24 +53,139 @@ const (
 )
 
 var DummyVecLength uint64
-var file *os.File
+
+// Format is the format of the results appended to output-shuffle-dp-sum.csv or
+// output-shuffle-dp-sum.jsonl, see internal/benchreport.
+var Format = benchreport.FormatCSV
+
+// reporter writes the result of every run.
+var reporter *benchreport.Reporter
+
+// report writes result with reporter; a failure only loses the result.
+func report(result benchreport.BenchmarkResult) {
+	if err := reporter.Report(result); err != nil {
+		log.Printf("report: %v\n", err)
+	}
+}
+
+// Workers is the number of goroutines generating the client proofs and
+// evaluating the shuffled vector.
+var Workers = runtime.NumCPU()
 
 func ComputeDummyNum(lambda uint64, n uint64, t uint64) uint64 {
 	tmp := float64(2*lambda+254)/float64(math.Log2(float64(n-t))-math.Log2(e)) + 2
 	return uint64(math.Ceil(tmp))
 }
 
+// BelowSecurityTarget reports whether dummyNum dummies per client are fewer
+// than ComputeDummyNum requires for lambda bits against t corrupted clients
+// out of n.
+func BelowSecurityTarget(dummyNum uint64, lambda uint64, n uint64, t uint64) bool {
+	return dummyNum < ComputeDummyNum(lambda, n, t)
+}
+
+// DummyLengths, if not empty, replaces the number of dummies ComputeDummyNum
+// requires: each protocol runs once per length, see -dummies.
+var DummyLengths []uint64
+
+// setDummyVecLength sets DummyVecLength to dummyNum, or to the number the
+// security target requires for 0, and reports whether dummyNum is below the
+// target.
+func setDummyVecLength(dummyNum uint64) bool {
+	DummyVecLength = ComputeDummyNum(80, ClientNum, CorruptedNum)
+	log.Printf("lambda %v, n %v, t %v, Dummy Num: %v\n", 80, ClientNum, CorruptedNum, DummyVecLength)
+	if dummyNum == 0 {
+		return false
+	}
+	below := BelowSecurityTarget(dummyNum, 80, ClientNum, CorruptedNum)
+	if below {
+		log.Printf("WARNING: %v dummies per client, below the %v of the security target: this run does not have the claimed privacy\n", dummyNum, DummyVecLength)
+	}
+	DummyVecLength = dummyNum
+	return below
+}
+
+// PolyEval returns the product of the vec[i] + r. It does not write to vec:
+// every term is added to r in a copy, so vec may be shared with other
+// goroutines or read again afterwards. The empty product is 1.
 func PolyEval(vec []fr_bn254.Element, r fr_bn254.Element) fr_bn254.Element {
-	prod := vec[0]
-	prod.Add(&prod, &r)
-	for i := 1; i < len(vec); i++ {
-		tmp := vec[i]
-		tmp.Add(&tmp, &r)
-		prod.Mul(&prod, &tmp)
+	prod := fr_bn254.One()
+	for i := 0; i < len(vec); i++ {
+		var term fr_bn254.Element
+		term.Add(&vec[i], &r)
+		prod.Mul(&prod, &term)
 	}
 	return prod
 }
 
+// PolyEvalInPlace is PolyEval without the copies: it leaves vec[i] + r in
+// vec[i]. It is for the server paths that own the vector and do not read it
+// again, such as the evaluation of the shuffled vector once its sum is known.
+func PolyEvalInPlace(vec []fr_bn254.Element, r fr_bn254.Element) fr_bn254.Element {
+	prod := fr_bn254.One()
+	for i := 0; i < len(vec); i++ {
+		vec[i].Add(&vec[i], &r)
+		prod.Mul(&prod, &vec[i])
+	}
+	return prod
+}
+
+// PolyEvalParallelThreshold is the length from which the server evaluates the
+// shuffled vector with PolyEvalParallel rather than PolyEval.
+var PolyEvalParallelThreshold = 10000
+
+// PolyEvalParallel returns PolyEval(vec, r), splitting vec into workers chunks
+// whose products are evaluated concurrently and then multiplied together. Like
+// PolyEval, it does not write to vec.
+func PolyEvalParallel(vec []fr_bn254.Element, r fr_bn254.Element, workers int) fr_bn254.Element {
+	return polyEvalChunks(vec, r, workers, PolyEval)
+}
+
+// polyEvalChunks evaluates the workers chunks of vec with eval concurrently
+// and multiplies their products. The chunks do not overlap, so eval may write
+// to its chunk.
+func polyEvalChunks(vec []fr_bn254.Element, r fr_bn254.Element, workers int,
+	eval func([]fr_bn254.Element, fr_bn254.Element) fr_bn254.Element) fr_bn254.Element {
+	if len(vec) == 0 {
+		return fr_bn254.One()
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	chunk := (len(vec) + workers - 1) / workers
+	chunks := (len(vec) + chunk - 1) / chunk
+	prods := make([]fr_bn254.Element, chunks)
+	var wg sync.WaitGroup
+	for w := 0; w < chunks; w++ {
+		end := (w + 1) * chunk
+		if end > len(vec) {
+			end = len(vec)
+		}
+		wg.Add(1)
+		go func(w int, part []fr_bn254.Element) {
+			defer wg.Done()
+			prods[w] = eval(part, r)
+		}(w, vec[w*chunk:end:end])
+	}
+	wg.Wait()
+
+	prod := prods[0]
+	for w := 1; w < chunks; w++ {
+		prod.Mul(&prod, &prods[w])
+	}
+	return prod
+}
+
+// serverPolyEvalInPlace is the evaluation of the shuffled vector by the
+// server, on Workers goroutines once it reaches PolyEvalParallelThreshold
+// elements. It clobbers vec, see PolyEvalInPlace.
+func serverPolyEvalInPlace(vec []fr_bn254.Element, r fr_bn254.Element) fr_bn254.Element {
+	if len(vec) >= PolyEvalParallelThreshold {
+		return polyEvalChunks(vec, r, Workers, PolyEvalInPlace)
+	}
+	return PolyEvalInPlace(vec, r)
+}
+
 func PolyEvalInCircuit(api frontend.API, vec []frontend.Variable, publicR frontend.Variable) frontend.Variable {
 	prod := api.Add(vec[0], publicR)
 	for i := 1; i < len(vec); i++ {
@@ -87,6 +208,10 @@ type SumAndCmpCircuit struct {
 	PrivateSalt      frontend.Variable
 }
 
+// sumAndCmpPublic are the public variables of SumAndCmpCircuit, in the order
+// of its public witness. The setup is not run for a circuit that differs.
+var sumAndCmpPublic = []string{"PublicThreshold", "PublicR", "PublicProd", "PublicCommitment"}
+
 func (circuit *SumAndCmpCircuit) Define(api frontend.API) error {
 	// check 0 <= sum <= threshold
 	sum := frontend.Variable(0)
@@ -178,16 +303,32 @@ func (c *ClientState) Init(x int, noise int) {
 	c.PrivateSalt = randomFr()
 
 	// the public commitment is the hash of the privateX, privateMask and privateSalt
+	c.PublicCom = Commit(c.PrivateX, c.PrivateMask, c.PrivateSalt)
+}
+
+// Commit returns the MiMC hash of privateX, privateMask and privateSalt, the
+// commitment checked by SumAndCmpCircuit.
+func Commit(privateX []fr_bn254.Element, privateMask fr_bn254.Element, privateSalt fr_bn254.Element) fr_bn254.Element {
 	goMimc := hash.MIMC_BN254.New()
-	for i := 0; i < len(c.PrivateX); i++ {
-		b := c.PrivateX[i].Bytes()
+	for i := 0; i < len(privateX); i++ {
+		b := privateX[i].Bytes()
 		goMimc.Write(b[:])
 	}
-	b := c.PrivateMask.Bytes()
+	b := privateMask.Bytes()
 	goMimc.Write(b[:])
-	b = c.PrivateSalt.Bytes()
+	b = privateSalt.Bytes()
 	goMimc.Write(b[:])
-	c.PublicCom.SetBytes(goMimc.Sum(nil))
+	var com fr_bn254.Element
+	com.SetBytes(goMimc.Sum(nil))
+	return com
+}
+
+// VerifyCommitment reports whether expectedCom opens to privateX, privateMask
+// and privateSalt. It lets the server reject a wrong opening before running
+// the verification of a proof.
+func VerifyCommitment(privateX []fr_bn254.Element, privateMask fr_bn254.Element, privateSalt fr_bn254.Element, expectedCom fr_bn254.Element) bool {
+	com := Commit(privateX, privateMask, privateSalt)
+	return com.Equal(&expectedCom)
 }
 
 func (c *ClientState) ComputePolyEval(publicR fr_bn254.Element) {
@@ -219,34 +360,179 @@ func (c *ClientState) GenAssignment(publicR fr_bn254.Element) SumAndCmpCircuit {
 	return assignment
 }
 
-func GenProofGroth16(assignment SumAndCmpCircuit, ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey) (*groth16.Proof, *witness.Witness) {
+// GenProofGroth16 proves the assignment and returns the proof with its public
+// witness. An inconsistent assignment fails here instead of yielding a nil
+// proof.
+func GenProofGroth16(assignment SumAndCmpCircuit, ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey) (*groth16.Proof, *witness.Witness, error) {
 	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	//fmt.Println(witness)
-	publicWitness, _ := witness.Public()
+	witness, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("witness: %w", err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return nil, nil, fmt.Errorf("public witness: %w", err)
+	}
 
 	// groth16: Prove & Verify
-	proof, _ := groth16.Prove(*ccs, *pk, witness)
+	proof, err := gnarkshim.ProveGroth16(*ccs, *pk, witness)
+	if err != nil {
+		return nil, nil, fmt.Errorf("groth16 prove: %w", err)
+	}
 
-	return &proof, &publicWitness
+	return &proof, &publicWitness, nil
 }
 
-func GenProofPlonk(assignment SumAndCmpCircuit, ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey) (*plonk.Proof, *witness.Witness) {
+// GenProofPlonk is the plonk counterpart of GenProofGroth16.
+func GenProofPlonk(assignment SumAndCmpCircuit, ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey) (*plonk.Proof, *witness.Witness, error) {
 	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	//fmt.Println(witness)
-	publicWitness, _ := witness.Public()
+	witness, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("witness: %w", err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return nil, nil, fmt.Errorf("public witness: %w", err)
+	}
 
 	// plonk: Prove & Verify
-	proof, _ := plonk.Prove(*ccs, *pk, witness)
+	proof, err := gnarkshim.ProvePlonk(*ccs, *pk, witness)
+	if err != nil {
+		return nil, nil, fmt.Errorf("plonk prove: %w", err)
+	}
+
+	return &proof, &publicWitness, nil
+}
+
+// challengeDomain separates the challenges of dp_sum from the other MiMC
+// hashes of commitments.
+const challengeDomain = "shuffle-zkp/dp_sum/challenge/v1"
+
+// RandomChallenge makes the server sample publicR at random instead of
+// deriving it with DeriveChallenge. It is only sound if the server samples
+// publicR after receiving every commitment, and is kept to compare the
+// benchmarks with the interactive protocol.
+var RandomChallenge = false
+
+// RoundID identifies the round the challenge is derived for, so that the
+// commitments of a round cannot be replayed in another one.
+var RoundID uint64
+
+// DeriveChallenge is the Fiat-Shamir challenge publicR of the round: see
+// fiatshamir.Challenge. A client cannot choose its commitment after seeing
+// publicR, since publicR depends on it, and anybody holding the commitments
+// can recompute it.
+func DeriveChallenge(round uint64, commitments []fr_bn254.Element) fr_bn254.Element {
+	return fiatshamir.Challenge(challengeDomain, round, commitments)
+}
+
+// serverChallenge is the publicR the server broadcasts, see RandomChallenge,
+// and the time it took to derive it.
+func serverChallenge(commitments []fr_bn254.Element) (fr_bn254.Element, time.Duration) {
+	start := time.Now()
+	if RandomChallenge {
+		return randomFr(), time.Since(start)
+	}
+	return DeriveChallenge(RoundID, commitments), time.Since(start)
+}
+
+// numCheckedProofs is the number of clients out of n attaching a real proof.
+func numCheckedProofs(n int) int {
+	if n < MaxNumOfCheckProof {
+		return n
+	}
+	return MaxNumOfCheckProof
+}
+
+// logProofErrors logs the clients whose proof could not be generated.
+func logProofErrors(errs []error) {
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("proof error in client %v: %v\n", i, err)
+		}
+	}
+}
+
+// errMissingProof marks a client expected to attach a proof without one.
+var errMissingProof = errors.New("missing proof")
 
-	return &proof, &publicWitness
+// VerifyAllGroth16 verifies the proofs of the first MaxNumOfCheckProof
+// submissions on Workers goroutines and returns the sorted indices of the
+// clients whose proof is missing or does not verify, empty when all verify.
+func VerifyAllGroth16(vk groth16.VerifyingKey, submissions []ClientSubmissionToServer) []int {
+	errs := pool.RunAll(numCheckedProofs(len(submissions)), Workers, func(i int) error {
+		if submissions[i].proof == nil {
+			return errMissingProof
+		}
+		return gnarkshim.VerifyGroth16(*submissions[i].proof, vk, *submissions[i].publicWitness)
+	})
+	return failingIndices(errs)
+}
+
+// VerifyAllPlonk is the plonk counterpart of VerifyAllGroth16.
+func VerifyAllPlonk(vk plonk.VerifyingKey, submissions []ClientSubmissionToServerPlonk) []int {
+	errs := pool.RunAll(numCheckedProofs(len(submissions)), Workers, func(i int) error {
+		if submissions[i].proof == nil {
+			return errMissingProof
+		}
+		return gnarkshim.VerifyPlonk(*submissions[i].proof, vk, *submissions[i].publicWitness)
+	})
+	return failingIndices(errs)
+}
+
+// failingIndices returns the indices of the non-nil errors of errs, in order.
+func failingIndices(errs []error) []int {
+	failing := []int{}
+	for i, err := range errs {
+		if err != nil {
+			failing = append(failing, i)
+		}
+	}
+	return failing
 }
 
-func DPSumGroth16() {
+// GenSubmissionsGroth16 builds the server submissions of all clients. Only the
+// first MaxNumOfCheckProof clients attach a real proof, generated on workers
+// goroutines sharing the read-only ccs and pk; the proofs land at the index of
+// their client. A client whose proof fails is logged and left without one.
+func GenSubmissionsGroth16(clients []ClientState, allAssignment []SumAndCmpCircuit, ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey, workers int) []ClientSubmissionToServer {
+	allSubmission := make([]ClientSubmissionToServer, len(clients))
+	for i := 0; i < len(clients); i++ {
+		allSubmission[i].publicProd = clients[i].PublicProd
+	}
+	errs := pool.RunAll(numCheckedProofs(len(clients)), workers, func(i int) error {
+		proof, publicWitness, err := GenProofGroth16(allAssignment[i], ccs, pk)
+		if err != nil {
+			return err
+		}
+		allSubmission[i].proof, allSubmission[i].publicWitness = proof, publicWitness
+		return nil
+	})
+	logProofErrors(errs)
+	return allSubmission
+}
+
+// GenSubmissionsPlonk is the plonk counterpart of GenSubmissionsGroth16.
+func GenSubmissionsPlonk(clients []ClientState, allAssignment []SumAndCmpCircuit, ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey, workers int) []ClientSubmissionToServerPlonk {
+	allSubmission := make([]ClientSubmissionToServerPlonk, len(clients))
+	for i := 0; i < len(clients); i++ {
+		allSubmission[i].publicProd = clients[i].PublicProd
+	}
+	errs := pool.RunAll(numCheckedProofs(len(clients)), workers, func(i int) error {
+		proof, publicWitness, err := GenProofPlonk(allAssignment[i], ccs, pk)
+		if err != nil {
+			return err
+		}
+		allSubmission[i].proof, allSubmission[i].publicWitness = proof, publicWitness
+		return nil
+	})
+	logProofErrors(errs)
+	return allSubmission
+}
+
+func DPSumGroth16(dummyNum uint64) {
 	// compute the dummy number needed
-	DummyVecLength = ComputeDummyNum(80, ClientNum, CorruptedNum)
-	log.Printf("lambda %v, n %v, t %v, Dummy Num: %v\n", 80, ClientNum, CorruptedNum, DummyVecLength)
+	belowTarget := setDummyVecLength(dummyNum)
 
 	// setup the constraint system
 	privateVec := make([]frontend.Variable, PrivateVecLength)
@@ -262,10 +548,13 @@ func DPSumGroth16() {
 		PublicCommitment: 0,
 		PrivateSalt:      0,
 	}
-	ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	ccs, _ := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err := gnarkshim.CheckPublic(ccs, sumAndCmpPublic); err != nil {
+		log.Fatal(err)
+	}
 
 	// groth16 zkSNARK: Setup
-	pk, vk, _ := groth16.Setup(ccs)
+	pk, vk, _ := gnarkshim.SetupGroth16(ccs)
 
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
@@ -297,6 +586,13 @@ func DPSumGroth16() {
 		shuffledX[i], shuffledX[j] = shuffledX[j], shuffledX[i]
 	})
 
+	// the server computes the sum of all the secret values as soon as it gets
+	// the shuffled vector, which the product check then clobbers
+	sum := fr_bn254.NewElement(uint64(0))
+	for i := 0; i < len(shuffledX); i++ {
+		sum.Add(&sum, &shuffledX[i])
+	}
+
 	// DETECTION PHASE:
 
 	// Step 1: Client does the following
@@ -321,8 +617,9 @@ func DPSumGroth16() {
 		commitments[i] = clients[i].PublicCom
 	}
 
-	// Step 2: the server broadcasts the publicR
-	publicR := randomFr()
+	// Step 2: the server derives the publicR from the commitments and
+	// broadcasts it
+	publicR, challengeTime := serverChallenge(commitments)
 
 	// Step 3:
 	// now the clients can compute the assignment
@@ -337,17 +634,7 @@ func DPSumGroth16() {
 	// now the clients can compute the proofs
 	// we only generate proofs for the first MaxNumOfCheckProof clients
 	start = time.Now()
-	allSubmission := make([]ClientSubmissionToServer, ClientNum)
-	for i := 0; i < len(clients); i++ {
-		if i < MaxNumOfCheckProof {
-			allSubmission[i].proof, allSubmission[i].publicWitness = GenProofGroth16(allAssignment[i], &ccs, &pk)
-			allSubmission[i].publicProd = clients[i].PublicProd
-		} else {
-			allSubmission[i].proof = nil
-			allSubmission[i].publicWitness = nil
-			allSubmission[i].publicProd = clients[i].PublicProd
-		}
-	}
+	allSubmission := GenSubmissionsGroth16(clients, allAssignment, &ccs, &pk, Workers)
 	proofTime := time.Since(start)
 
 	// check how many bytes are written per client
@@ -373,22 +660,20 @@ func DPSumGroth16() {
 	// c) The server computes the sum of all the secret values
 
 	start = time.Now()
-	for i := 0; i < len(allSubmission); i++ {
-		if i < MaxNumOfCheckProof {
-			verification_err := groth16.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
-			if verification_err != nil {
-				fmt.Printf("verification error in client %v", i)
-			}
-		}
-	}
+	failing := VerifyAllGroth16(vk, allSubmission)
 	verifyTime := time.Since(start)
+	if len(failing) > 0 {
+		// the sum would include values nobody proved to be in range
+		log.Printf("The proofs of clients %v fail, aborting the tally\n", failing)
+		return
+	}
 
 	prodFromClient := fr_bn254.NewElement(uint64(1))
 	for i := 0; i < ClientNum; i++ {
 		prodFromClient.Mul(&prodFromClient, &allSubmission[i].publicProd)
 	}
 
-	prodFromShuffler := PolyEval(shuffledX, publicR)
+	prodFromShuffler := serverPolyEvalInPlace(shuffledX, publicR)
 	for i := 0; i < len(allDummies); i++ {
 		prodFromShuffler.Mul(&prodFromShuffler, &allDummies[i])
 	}
@@ -399,12 +684,6 @@ func DPSumGroth16() {
 
 	serverTime := time.Since(start)
 
-	// the server then computes the sum of all the secret values
-	sum := fr_bn254.NewElement(uint64(0))
-	for i := 0; i < len(shuffledX); i++ {
-		sum.Add(&sum, &shuffledX[i])
-	}
-
 	fmt.Printf("The computed sum is %v\n", sum.Uint64())
 
 	proofRelatedCommCost := uint64(proofSize) // + publicWitnessSize
@@ -434,6 +713,7 @@ func DPSumGroth16() {
 	clientTime := prepTime/time.Duration(ClientNum) + proofTime/time.Duration(MaxNumOfCheckProof)
 	log.Printf("=====Client Computation Cost=====\n")
 	log.Printf("Preparation: %v\n", prepTime/time.Duration(ClientNum))
+	log.Printf("Proof (wall-clock for %v proofs, %v workers): %v\n", MaxNumOfCheckProof, Workers, proofTime)
 	log.Printf("Proof: %v\n", proofTime/time.Duration(MaxNumOfCheckProof))
 	log.Printf("Total: %v\n", clientTime)
 	log.Printf("============================\n")
@@ -441,6 +721,7 @@ func DPSumGroth16() {
 	// now we compute the server time amortized per client
 	serverTotalTime := serverTime/time.Duration(ClientNum) + verifyTime/time.Duration(MaxNumOfCheckProof)
 	log.Printf("=====Server Computation Cost=====\n")
+	log.Printf("Challenge (random %v, round %v): %v\n", RandomChallenge, RoundID, challengeTime)
 	log.Printf("Other: %v\n", serverTime/time.Duration(ClientNum))
 	log.Printf("Verify: %v\n", verifyTime/time.Duration(MaxNumOfCheckProof))
 	log.Printf("Total: %v\n", serverTotalTime)
@@ -452,21 +733,33 @@ func DPSumGroth16() {
 	log.Printf("Proving Key: %v\n", provingKeySize)
 	log.Printf("============================\n")
 
-	s := fmt.Sprintf("DP Sum Groth16, %v, %v, %v, %v, %v, %v, %v\n",
-		nbConstraints,
-		ClientNum,
-		ClientNum-CorruptedNum,
-		clientTime,
-		serverTotalTime,
-		commCost,
-		provingKeySize)
-	file.WriteString(s)
+	report(benchreport.BenchmarkResult{
+		Program:      "dp_sum",
+		Backend:      "Groth16",
+		Curve:        ecc.BN254.String(),
+		Constraints:  nbConstraints,
+		ClientNum:    ClientNum,
+		CorruptedNum: CorruptedNum,
+		DummyLen:     DummyVecLength,
+		PrepTime:     prepTime / time.Duration(ClientNum),
+		ProofTime:    proofTime / time.Duration(MaxNumOfCheckProof),
+		VerifyTime:   verifyTime / time.Duration(MaxNumOfCheckProof),
+		ServerTime:   serverTime / time.Duration(ClientNum),
+		ProofSize:    proofSize,
+		WitnessSize:  publicWitnessSize,
+		PKSize:       provingKeySize,
+		CommCost: benchreport.CommCost{
+			Proof: proofRelatedCommCost,
+			Other: commCost - proofRelatedCommCost,
+			Total: commCost,
+		},
+		BelowTarget: belowTarget,
+	})
 }
 
-func DPSumPlonk() {
+func DPSumPlonk(dummyNum uint64) {
 	// compute the dummy number needed
-	DummyVecLength = ComputeDummyNum(80, ClientNum, CorruptedNum)
-	log.Printf("lambda %v, n %v, t %v, Dummy Num: %v\n", 80, ClientNum, CorruptedNum, DummyVecLength)
+	belowTarget := setDummyVecLength(dummyNum)
 
 	// setup the constraint system
 	privateVec := make([]frontend.Variable, PrivateVecLength)
@@ -483,20 +776,23 @@ func DPSumPlonk() {
 		PrivateSalt:      0,
 	}
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	ccs, err := gnarkshim.CompileSCS(ecc.BN254, &circuit)
 	if err != nil {
 		log.Println("scs circuit compile error")
 	}
+	if err := gnarkshim.CheckPublic(ccs, sumAndCmpPublic); err != nil {
+		log.Fatal(err)
+	}
 
 	//setup kzg
 	_r1cs := ccs.(*cs.SparseR1CS)
-	srs, err := test.NewKZGSRS(_r1cs)
+	srs, err := gnarkshim.NewKZGSRS(_r1cs)
 	if err != nil {
 		log.Println("kzg srs error")
 	}
 
 	// plonk zkSNARK: Setup
-	pk, vk, _ := plonk.Setup(ccs, srs)
+	pk, vk, _ := gnarkshim.SetupPlonk(ccs, srs)
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -529,6 +825,13 @@ func DPSumPlonk() {
 		shuffledX[i], shuffledX[j] = shuffledX[j], shuffledX[i]
 	})
 
+	// the server computes the sum of all the secret values as soon as it gets
+	// the shuffled vector, which the product check then clobbers
+	sum := fr_bn254.NewElement(uint64(0))
+	for i := 0; i < len(shuffledX); i++ {
+		sum.Add(&sum, &shuffledX[i])
+	}
+
 	// DETECTION PHASE:
 
 	// Step 1: Client does the following
@@ -553,8 +856,9 @@ func DPSumPlonk() {
 		commitments[i] = clients[i].PublicCom
 	}
 
-	// Step 2: the server broadcasts the publicR
-	publicR := randomFr()
+	// Step 2: the server derives the publicR from the commitments and
+	// broadcasts it
+	publicR, challengeTime := serverChallenge(commitments)
 
 	// Step 3:
 	// now the clients can compute the assignment
@@ -569,17 +873,7 @@ func DPSumPlonk() {
 	// now the clients can compute the proofs
 	// we only generate proofs for the first MaxNumOfCheckProof clients
 	start = time.Now()
-	allSubmission := make([]ClientSubmissionToServerPlonk, ClientNum)
-	for i := 0; i < len(clients); i++ {
-		if i < MaxNumOfCheckProof {
-			allSubmission[i].proof, allSubmission[i].publicWitness = GenProofPlonk(allAssignment[i], &ccs, &pk)
-			allSubmission[i].publicProd = clients[i].PublicProd
-		} else {
-			allSubmission[i].proof = nil
-			allSubmission[i].publicWitness = nil
-			allSubmission[i].publicProd = clients[i].PublicProd
-		}
-	}
+	allSubmission := GenSubmissionsPlonk(clients, allAssignment, &ccs, &pk, Workers)
 	proofTime := time.Since(start)
 
 	// check how many bytes are written per client
@@ -605,22 +899,20 @@ func DPSumPlonk() {
 	// c) The server computes the sum of all the secret values
 
 	start = time.Now()
-	for i := 0; i < len(allSubmission); i++ {
-		if i < MaxNumOfCheckProof {
-			verification_err := plonk.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
-			if verification_err != nil {
-				fmt.Printf("verification error in client %v", i)
-			}
-		}
-	}
+	failing := VerifyAllPlonk(vk, allSubmission)
 	verifyTime := time.Since(start)
+	if len(failing) > 0 {
+		// the sum would include values nobody proved to be in range
+		log.Printf("The proofs of clients %v fail, aborting the tally\n", failing)
+		return
+	}
 
 	prodFromClient := fr_bn254.NewElement(uint64(1))
 	for i := 0; i < ClientNum; i++ {
 		prodFromClient.Mul(&prodFromClient, &allSubmission[i].publicProd)
 	}
 
-	prodFromShuffler := PolyEval(shuffledX, publicR)
+	prodFromShuffler := serverPolyEvalInPlace(shuffledX, publicR)
 	for i := 0; i < len(allDummies); i++ {
 		prodFromShuffler.Mul(&prodFromShuffler, &allDummies[i])
 	}
@@ -631,12 +923,6 @@ func DPSumPlonk() {
 
 	serverTime := time.Since(start)
 
-	// the server then computes the sum of all the secret values
-	sum := fr_bn254.NewElement(uint64(0))
-	for i := 0; i < len(shuffledX); i++ {
-		sum.Add(&sum, &shuffledX[i])
-	}
-
 	fmt.Printf("The computed sum is %v\n", sum.Uint64())
 
 	proofRelatedCommCost := uint64(proofSize) // + publicWitnessSize
@@ -666,6 +952,7 @@ func DPSumPlonk() {
 	clientTime := prepTime/time.Duration(ClientNum) + proofTime/time.Duration(MaxNumOfCheckProof)
 	log.Printf("=====Client Computation Cost=====\n")
 	log.Printf("Preparation: %v\n", prepTime/time.Duration(ClientNum))
+	log.Printf("Proof (wall-clock for %v proofs, %v workers): %v\n", MaxNumOfCheckProof, Workers, proofTime)
 	log.Printf("Proof: %v\n", proofTime/time.Duration(MaxNumOfCheckProof))
 	log.Printf("Total: %v\n", clientTime)
 	log.Printf("============================\n")
@@ -673,6 +960,7 @@ func DPSumPlonk() {
 	// now we compute the server time amortized per client
 	serverTotalTime := serverTime/time.Duration(ClientNum) + verifyTime/time.Duration(MaxNumOfCheckProof)
 	log.Printf("=====Server Computation Cost=====\n")
+	log.Printf("Challenge (random %v, round %v): %v\n", RandomChallenge, RoundID, challengeTime)
 	log.Printf("Other: %v\n", serverTime/time.Duration(ClientNum))
 	log.Printf("Verify: %v\n", verifyTime/time.Duration(MaxNumOfCheckProof))
 	log.Printf("Total: %v\n", serverTotalTime)
@@ -684,15 +972,28 @@ func DPSumPlonk() {
 	log.Printf("Proving Key: %v\n", provingKeySize)
 	log.Printf("============================\n")
 
-	s := fmt.Sprintf("DP Sum Plonk, %v, %v, %v, %v, %v, %v, %v\n",
-		nbConstraints,
-		ClientNum,
-		ClientNum-CorruptedNum,
-		clientTime,
-		serverTotalTime,
-		commCost,
-		provingKeySize)
-	file.WriteString(s)
+	report(benchreport.BenchmarkResult{
+		Program:      "dp_sum",
+		Backend:      "Plonk",
+		Curve:        ecc.BN254.String(),
+		Constraints:  nbConstraints,
+		ClientNum:    ClientNum,
+		CorruptedNum: CorruptedNum,
+		DummyLen:     DummyVecLength,
+		PrepTime:     prepTime / time.Duration(ClientNum),
+		ProofTime:    proofTime / time.Duration(MaxNumOfCheckProof),
+		VerifyTime:   verifyTime / time.Duration(MaxNumOfCheckProof),
+		ServerTime:   serverTime / time.Duration(ClientNum),
+		ProofSize:    proofSize,
+		WitnessSize:  publicWitnessSize,
+		PKSize:       provingKeySize,
+		CommCost: benchreport.CommCost{
+			Proof: proofRelatedCommCost,
+			Other: commCost - proofRelatedCommCost,
+			Total: commCost,
+		},
+		BelowTarget: belowTarget,
+	})
 }
 
 func GenPolyaPDF(r float64, p float64) []float64 {
@@ -762,21 +1063,45 @@ func GenDistributedDPNoise(eps float64, sensitivity float64, n int) []int {
 }
 
 func main() {
+	flag.IntVar(&Workers, "workers", Workers, "number of goroutines generating the client proofs and evaluating the shuffled vector")
+	format := flag.String("format", Format.String(), "format of the results, csv or json")
+	flag.BoolVar(&RandomChallenge, "random-challenge", RandomChallenge, "sample publicR at random instead of deriving it from the commitments, for benchmark comparisons")
+	flag.Uint64Var(&RoundID, "round-id", RoundID, "identifier of the round the challenge is derived for")
+	flag.IntVar(&PolyEvalParallelThreshold, "parallel-eval", PolyEvalParallelThreshold, "length of the shuffled vector from which the server evaluates it on -workers goroutines")
+	dummies := flag.String("dummies", "", "comma separated numbers of dummies per client to sweep instead of the one the security target requires")
+	flag.Parse()
+
 	var err error
-	file, err = os.OpenFile("output-shuffle-dp-sum.csv", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if Format, err = benchreport.ParseFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if DummyLengths, err = benchreport.ParseDummyLengths(*dummies); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	rep, file, err := benchreport.OpenReporter("output-shuffle-dp-sum"+Format.Ext(), Format)
 	if err != nil {
 		panic(err)
 	}
 
 	defer file.Close()
+	reporter = rep
 
-	file.WriteString("Name, #Const, #Client, #Honest, Client Time, Server Time, Comm Cost, Proving Key Size\n")
-
-	for t := 0; t < TestRepeat; t++ {
-		DPSumGroth16()
+	// without -dummies, one length: the one of the security target
+	lengths := DummyLengths
+	if len(lengths) == 0 {
+		lengths = []uint64{0}
+	}
+	for _, dummyNum := range lengths {
+		for t := 0; t < TestRepeat; t++ {
+			DPSumGroth16(dummyNum)
+		}
 	}
 
-	for t := 0; t < TestRepeat; t++ {
-		DPSumPlonk()
+	for _, dummyNum := range lengths {
+		for t := 0; t < TestRepeat; t++ {
+			DPSumPlonk(dummyNum)
+		}
 	}
 }
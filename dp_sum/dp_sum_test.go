@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"testing/quick"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gnarkshim"
+)
+
+func TestGenSubmissionsGroth16(t *testing.T) {
+	DummyVecLength = 4
+	circuit := SumAndCmpCircuit{PrivateVec: make([]frontend.Variable, PrivateVecLength)}
+	ccs, err := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more clients than checked proofs, so that some only submit publicProd
+	clients := make([]ClientState, MaxNumOfCheckProof+2)
+	publicR := randomFr()
+	allAssignment := make([]SumAndCmpCircuit, len(clients))
+	for i := 0; i < len(clients); i++ {
+		clients[i].Init(1000, -i) // a positive noise overflows the threshold
+		allAssignment[i] = clients[i].GenAssignment(publicR)
+	}
+
+	sequential := GenSubmissionsGroth16(clients, allAssignment, &ccs, &pk, 1)
+	concurrent := GenSubmissionsGroth16(clients, allAssignment, &ccs, &pk, 4)
+	for i := 0; i < len(clients); i++ {
+		s, c := sequential[i], concurrent[i]
+		if !s.publicProd.Equal(&c.publicProd) || !c.publicProd.Equal(&clients[i].PublicProd) {
+			t.Fatalf("client %v: publicProd differs", i)
+		}
+		if i >= MaxNumOfCheckProof {
+			if c.proof != nil || c.publicWitness != nil {
+				t.Fatalf("client %v: unexpected proof", i)
+			}
+			continue
+		}
+		// the proofs are randomized, the public inputs are not
+		sw, _ := (*s.publicWitness).MarshalBinary()
+		cw, _ := (*c.publicWitness).MarshalBinary()
+		if !bytes.Equal(sw, cw) {
+			t.Fatalf("client %v: public witness differs", i)
+		}
+		if err := gnarkshim.VerifyGroth16(*s.proof, vk, *s.publicWitness); err != nil {
+			t.Fatalf("client %v: sequential proof: %v", i, err)
+		}
+		if err := gnarkshim.VerifyGroth16(*c.proof, vk, *c.publicWitness); err != nil {
+			t.Fatalf("client %v: concurrent proof: %v", i, err)
+		}
+	}
+}
+
+func TestGenProofGroth16InconsistentAssignment(t *testing.T) {
+	DummyVecLength = 4
+	circuit := SumAndCmpCircuit{PrivateVec: make([]frontend.Variable, PrivateVecLength)}
+	ccs, err := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, _, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var client ClientState
+	client.Init(1000, 0)
+	assignment := client.GenAssignment(randomFr())
+	assignment.PublicProd = frontend.Variable(randomFr())
+
+	proof, publicWitness, err := GenProofGroth16(assignment, &ccs, &pk)
+	if err == nil {
+		t.Fatal("expected an error for a wrong PublicProd")
+	}
+	if proof != nil || publicWitness != nil {
+		t.Fatal("expected no proof on error")
+	}
+}
+
+func randomVec(n int) []fr_bn254.Element {
+	vec := make([]fr_bn254.Element, n)
+	for i := range vec {
+		vec[i] = randomFr()
+	}
+	return vec
+}
+
+func TestPolyEvalParallel(t *testing.T) {
+	property := func(n uint16, workers uint8) bool {
+		vec := randomVec(int(n)%2000 + 1)
+		r := randomFr()
+		want := PolyEval(vec, r)
+		got := PolyEvalParallel(vec, r, int(workers)%(len(vec)+4))
+		return got.Equal(&want)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// hashVec is the SHA-256 of the elements of vec, to tell whether a call wrote
+// to vec.
+func hashVec(vec []fr_bn254.Element) [32]byte {
+	h := sha256.New()
+	for i := range vec {
+		b := vec[i].Bytes()
+		h.Write(b[:])
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+func TestPolyEvalDoesNotMutate(t *testing.T) {
+	defer func(w int) { Workers = w }(Workers)
+	Workers = 4
+	vec := randomVec(1000)
+	r := randomFr()
+	before := hashVec(vec)
+	want := PolyEval(vec, r)
+	if hashVec(vec) != before {
+		t.Fatal("PolyEval wrote to its input")
+	}
+	// the chunks of the parallel evaluation share the backing array of vec
+	if got := PolyEvalParallel(vec, r, 7); !got.Equal(&want) || hashVec(vec) != before {
+		t.Fatal("PolyEvalParallel wrote to its input or differs from PolyEval")
+	}
+	// two evaluations of overlapping windows of the same array
+	a, b := vec[:600], vec[400:]
+	wantA, wantB := PolyEval(a, r), PolyEval(b, r)
+	if gotA, gotB := PolyEvalParallel(a, r, 3), PolyEval(b, r); !gotA.Equal(&wantA) || !gotB.Equal(&wantB) || hashVec(vec) != before {
+		t.Fatal("the evaluations of overlapping windows interfere")
+	}
+	if empty := PolyEval(nil, r); !empty.IsOne() {
+		t.Fatal("the empty product is not 1")
+	}
+}
+
+func TestPolyEvalInPlace(t *testing.T) {
+	defer func(w, threshold int) { Workers, PolyEvalParallelThreshold = w, threshold }(Workers, PolyEvalParallelThreshold)
+	Workers, PolyEvalParallelThreshold = 4, 100
+	r := randomFr()
+	for _, n := range []int{0, 1, 99, 100, 1001} {
+		vec := randomVec(n)
+		want := PolyEval(vec, r)
+		shifted := make([]fr_bn254.Element, n)
+		for i := range vec {
+			shifted[i].Add(&vec[i], &r)
+		}
+
+		// PolyEvalInPlace clobbers exactly its window of the array
+		padded := append(append([]fr_bn254.Element{randomFr()}, vec...), randomFr())
+		edges := [2]fr_bn254.Element{padded[0], padded[n+1]}
+		if got := PolyEvalInPlace(padded[1:n+1], r); !got.Equal(&want) {
+			t.Fatalf("%v elements: PolyEvalInPlace differs from PolyEval", n)
+		}
+		if hashVec(padded[1:n+1]) != hashVec(shifted) {
+			t.Fatalf("%v elements: PolyEvalInPlace does not leave vec[i] + r", n)
+		}
+		if !padded[0].Equal(&edges[0]) || !padded[n+1].Equal(&edges[1]) {
+			t.Fatalf("%v elements: PolyEvalInPlace wrote outside its window", n)
+		}
+
+		// so does the server evaluation, serial or parallel
+		if got := serverPolyEvalInPlace(vec, r); !got.Equal(&want) || hashVec(vec) != hashVec(shifted) {
+			t.Fatalf("%v elements: serverPolyEvalInPlace is not PolyEvalInPlace", n)
+		}
+	}
+}
+
+func BenchmarkPolyEval(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		vec := randomVec(n)
+		r := randomFr()
+		b.Run(fmt.Sprintf("serial/%v", n), func(b *testing.B) {
+			for k := 0; k < b.N; k++ {
+				PolyEval(vec, r)
+			}
+		})
+		b.Run(fmt.Sprintf("parallel/%v", n), func(b *testing.B) {
+			for k := 0; k < b.N; k++ {
+				PolyEvalParallel(vec, r, Workers)
+			}
+		})
+	}
+}
+
+func TestVerifyCommitment(t *testing.T) {
+	DummyVecLength = 4
+	var client ClientState
+	client.Init(1000, 0)
+	if !VerifyCommitment(client.PrivateX, client.PrivateMask, client.PrivateSalt, client.PublicCom) {
+		t.Fatal("the opening of Init does not verify")
+	}
+	if VerifyCommitment(client.PrivateX, client.PrivateMask, randomFr(), client.PublicCom) {
+		t.Fatal("a wrong salt verifies")
+	}
+}
+
+func FuzzVerifyCommitment(f *testing.F) {
+	DummyVecLength = 4
+	var client ClientState
+	client.Init(1000, 0)
+	f.Add(uint(0), uint(0), byte(1))
+	f.Add(uint(PrivateVecLength-1), uint(31), byte(0x80))
+	f.Fuzz(func(t *testing.T, i uint, j uint, delta byte) {
+		if delta == 0 {
+			return
+		}
+		privateX := append([]fr_bn254.Element(nil), client.PrivateX...)
+		i %= uint(len(privateX))
+		b := privateX[i].Bytes()
+		b[j%uint(len(b))] ^= delta
+		privateX[i].SetBytes(b[:])
+		if privateX[i].Equal(&client.PrivateX[i]) {
+			return
+		}
+		if VerifyCommitment(privateX, client.PrivateMask, client.PrivateSalt, client.PublicCom) {
+			t.Fatalf("privateX[%v] mutated at byte %v verifies", i, j)
+		}
+	})
+}
+
+func TestDeriveChallenge(t *testing.T) {
+	commitments := randomVec(5)
+	r := DeriveChallenge(1, commitments)
+	again := DeriveChallenge(1, append([]fr_bn254.Element(nil), commitments...))
+	if !r.Equal(&again) {
+		t.Fatal("the same commitments derive different challenges")
+	}
+	if other := DeriveChallenge(2, commitments); other.Equal(&r) {
+		t.Fatal("another round derives the same challenge")
+	}
+
+	commitments[2] = randomFr()
+	changed := DeriveChallenge(1, commitments)
+	if r.Equal(&changed) {
+		t.Fatal("changing a commitment does not change the challenge")
+	}
+	if empty := DeriveChallenge(1, nil); empty.Equal(&r) {
+		t.Fatal("the empty set derives the same challenge")
+	}
+}
+
+func TestSetDummyVecLength(t *testing.T) {
+	defer func(n uint64) { DummyVecLength = n }(DummyVecLength)
+	required := ComputeDummyNum(80, ClientNum, CorruptedNum)
+	if setDummyVecLength(0) || DummyVecLength != required {
+		t.Fatalf("no override: %v dummies, expected the %v of the target", DummyVecLength, required)
+	}
+	if !setDummyVecLength(required-1) || DummyVecLength != required-1 {
+		t.Fatalf("%v dummies are not flagged below the target of %v", required-1, required)
+	}
+	if setDummyVecLength(required) || setDummyVecLength(2*required) || DummyVecLength != 2*required {
+		t.Fatal("the dummies of the target or more are flagged below it")
+	}
+}
+
+func TestVerifyAllGroth16(t *testing.T) {
+	DummyVecLength = 4
+	circuit := SumAndCmpCircuit{PrivateVec: make([]frontend.Variable, PrivateVecLength)}
+	ccs, err := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients := make([]ClientState, MaxNumOfCheckProof+2)
+	publicR := randomFr()
+	allAssignment := make([]SumAndCmpCircuit, len(clients))
+	for i := 0; i < len(clients); i++ {
+		clients[i].Init(1000, -i)
+		allAssignment[i] = clients[i].GenAssignment(publicR)
+	}
+	submissions := GenSubmissionsGroth16(clients, allAssignment, &ccs, &pk, Workers)
+	if failing := VerifyAllGroth16(vk, submissions); len(failing) != 0 {
+		t.Fatalf("honest submissions: failing %v", failing)
+	}
+
+	// client 3 sends the proof of client 1, which does not hold for its
+	// public witness
+	submissions[3].proof = submissions[1].proof
+	if failing := VerifyAllGroth16(vk, submissions); fmt.Sprint(failing) != "[3]" {
+		t.Fatalf("corrupted proof of client 3: failing %v", failing)
+	}
+	// client 7 drops its proof
+	submissions[7].proof = nil
+	if failing := VerifyAllGroth16(vk, submissions); fmt.Sprint(failing) != "[3 7]" {
+		t.Fatalf("corrupted proof of client 3, missing proof of client 7: failing %v", failing)
+	}
+}
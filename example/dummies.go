@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/hasher"
+)
+
+// A client masks the product of its shares with its dummies, so that the
+// server cannot link the product to the shuffled shares. Sending the
+// DummyVecLength dummies through the shuffler dominates the communication
+// outside the proof. In the in-circuit mode the client commits to its
+// dummies, keeps them in the circuit and only sends the aggregate mask
+// PolyEval(dummies, publicR) through the shuffler once the challenge is
+// known. The shuffler then carries one mask per client instead of the
+// dummies, so the server no longer sees the individual dummies.
+
+// DummyMode is how the dummies of a client reach the product check.
+type DummyMode int
+
+const (
+	// DummiesShuffled sends the dummies through the shuffler; the mask is
+	// their product.
+	DummiesShuffled DummyMode = iota
+	// DummiesInCircuit keeps the dummies in the circuit and sends only the
+	// mask, their evaluation at publicR, through the shuffler.
+	DummiesInCircuit
+)
+
+func (m DummyMode) String() string {
+	switch m {
+	case DummiesShuffled:
+		return "shuffled"
+	case DummiesInCircuit:
+		return "incircuit"
+	}
+	return fmt.Sprintf("DummyMode(%d)", int(m))
+}
+
+// ParseDummyMode returns the mode named name, "shuffled" or "incircuit".
+func ParseDummyMode(name string) (DummyMode, error) {
+	for _, m := range []DummyMode{DummiesShuffled, DummiesInCircuit} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return DummiesShuffled, fmt.Errorf("unknown dummy mode %q", name)
+}
+
+// Dummies is the dummy mode of the benchmarks, set with -dummy-mode.
+var Dummies = DummiesShuffled
+
+// ShufflerCost is the number of bytes a client sends to the shuffler besides
+// its shares.
+func (m DummyMode) ShufflerCost() uint64 {
+	if m == DummiesInCircuit {
+		return BN254Size
+	}
+	return DummyVecLength * BN254Size
+}
+
+// sumAndCmpDummyCircuit is sumAndCmpCircuit with the dummies in the circuit:
+// PublicProd = PolyEval(PrivateVec) * PolyEval(DummyVec), and the commitment
+// binds the dummies instead of their product.
+type sumAndCmpDummyCircuit struct {
+	PrivateVec      []frontend.Variable
+	DummyVec        []frontend.Variable
+	PublicThreshold frontend.Variable `gnark:",public"`
+
+	// The following are for the polynomial evaluation
+	PublicR    frontend.Variable `gnark:",public"`
+	PublicProd frontend.Variable `gnark:",public"`
+
+	// The following are for the commitment
+	PublicCommitment frontend.Variable `gnark:",public"`
+	PrivateSalt      frontend.Variable
+
+	// Hash is the hash of the commitment, see ProtocolParams.
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+func (circuit *sumAndCmpDummyCircuit) Define(api frontend.API) error {
+	sum := frontend.Variable(0)
+	for i := 0; i < len(circuit.PrivateVec); i++ {
+		sum = api.Add(sum, circuit.PrivateVec[i])
+	}
+	api.AssertIsLessOrEqual(0, sum)
+	api.AssertIsLessOrEqual(sum, circuit.PublicThreshold)
+
+	privateProd := PolyEvalInCircuit(api, circuit.PrivateVec, circuit.PublicR)
+	privateProd = api.Mul(privateProd, PolyEvalInCircuit(api, circuit.DummyVec, circuit.PublicR))
+	api.AssertIsEqual(privateProd, circuit.PublicProd)
+
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	h.Write(circuit.PrivateVec...)
+	h.Write(circuit.DummyVec...)
+	h.Write(circuit.PrivateSalt)
+	api.AssertIsEqual(circuit.PublicCommitment, h.Sum())
+	return nil
+}
+
+// newSumCircuit returns the empty circuit of mode, hashing with Params.Hash,
+// ready to be compiled.
+func newSumCircuit(mode DummyMode) frontend.Circuit {
+	if mode == DummiesInCircuit {
+		return &sumAndCmpDummyCircuit{
+			PrivateVec: make([]frontend.Variable, PrivateVecLength),
+			DummyVec:   make([]frontend.Variable, DummyVecLength),
+			Hash:       Params.Hash,
+		}
+	}
+	return &sumAndCmpCircuit{
+		PrivateVec: make([]frontend.Variable, PrivateVecLength),
+		DummyVec:   make([]frontend.Variable, DummyVecLength),
+		Hash:       Params.Hash,
+	}
+}
+
+// sumPublic are the public variables of the circuits of newSumCircuit, the
+// same in both modes, in the order of their public witness.
+var sumPublic = []string{"PublicThreshold", "PublicR", "PublicProd", "PublicCommitment"}
+
+// SumDummyCommitment is the commitment of a client to its shares, its dummies
+// and its salt in the in-circuit mode.
+func SumDummyCommitment(secretVal []fr_bn254.Element, dummies []fr_bn254.Element, salt fr_bn254.Element) fr_bn254.Element {
+	return commitFr(secretVal, dummies, []fr_bn254.Element{salt})
+}
+
+// NewSumDummyWitness builds the full and the public witness of the in-circuit
+// mode. The mask of the client is PolyEval(dummies, publicR).
+func NewSumDummyWitness(secretVal []fr_bn254.Element, dummies []fr_bn254.Element, publicR fr_bn254.Element,
+	publicProd fr_bn254.Element, com fr_bn254.Element, salt fr_bn254.Element) (witness.Witness, witness.Witness, error) {
+	assignment := sumAndCmpDummyCircuit{
+		PrivateVec:       make([]frontend.Variable, len(secretVal)),
+		DummyVec:         make([]frontend.Variable, len(dummies)),
+		PublicThreshold:  PublicThreshold,
+		PublicR:          publicR,
+		PublicProd:       publicProd,
+		PublicCommitment: com,
+		PrivateSalt:      salt,
+	}
+	for j := 0; j < len(secretVal); j++ {
+		assignment.PrivateVec[j] = secretVal[j]
+	}
+	for j := 0; j < len(dummies); j++ {
+		assignment.DummyVec[j] = dummies[j]
+	}
+	witness, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("witness generation: %w", err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return nil, nil, fmt.Errorf("public witness extraction: %w", err)
+	}
+	return witness, publicWitness, nil
+}
+
+// logDummyModes compiles the circuit of every dummy mode with compile and logs
+// its constraint count and what a client sends to the shuffler, so that the
+// two modes can be compared.
+func logDummyModes(compile func(ecc.ID, frontend.Circuit) (constraint.ConstraintSystem, error)) error {
+	log.Printf("=====Dummy Modes=====\n")
+	for _, mode := range []DummyMode{DummiesShuffled, DummiesInCircuit} {
+		ccs, err := compile(ecc.BN254, newSumCircuit(mode))
+		if err != nil {
+			return fmt.Errorf("%v circuit compile: %w", mode, err)
+		}
+		log.Printf("%v: %v constraints, %v bytes of dummies to the shuffler\n", mode, ccs.GetNbConstraints(), mode.ShufflerCost())
+	}
+	log.Printf("============================\n")
+	return nil
+}
+
+// newClientWitness builds the full and the public witness of client i in the
+// mode Dummies. mask is the product of the dummies in the shuffled mode and
+// their evaluation at publicR in the in-circuit mode.
+func newClientWitness(assignments *SumAssignments, i int, secretVal []fr_bn254.Element, dummies []fr_bn254.Element,
+	publicR *fr_bn254.Element, mask *fr_bn254.Element, publicProd *fr_bn254.Element, com *fr_bn254.Element, salt *fr_bn254.Element) (witness.Witness, witness.Witness, error) {
+	if Dummies == DummiesInCircuit {
+		return NewSumDummyWitness(secretVal, dummies, *publicR, *publicProd, *com, *salt)
+	}
+	assignment := assignments.Assign(i, secretVal, dummies, publicR, mask, publicProd, com, salt)
+	return NewSumWitness(&assignment)
+}
+
+// dummyModeSuffix tells apart the CSV rows of the in-circuit mode.
+func dummyModeSuffix() string {
+	if Dummies == DummiesShuffled {
+		return ""
+	}
+	return fmt.Sprintf(" (%v dummies)", Dummies)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// dummyClient is the private state of one client of the in-circuit mode.
+type dummyClient struct {
+	shares     []fr_bn254.Element
+	dummies    []fr_bn254.Element
+	mask       fr_bn254.Element
+	salt       fr_bn254.Element
+	com        fr_bn254.Element
+	publicProd fr_bn254.Element
+}
+
+// newDummyClient splits val into vecLength shares, draws dummyLength dummies
+// and commits to both before publicR is known.
+func newDummyClient(val uint64, vecLength int, dummyLength int, publicR fr_bn254.Element) dummyClient {
+	var c dummyClient
+	c.shares = newSumClient(val, vecLength, publicR).shares
+	c.dummies = make([]fr_bn254.Element, dummyLength)
+	for j := 0; j < dummyLength; j++ {
+		c.dummies[j] = randomFr()
+	}
+	c.salt = randomFr()
+	c.com = SumDummyCommitment(c.shares, c.dummies, c.salt)
+	c.mask = PolyEval(c.dummies, publicR)
+	c.publicProd = SumPublicProd(c.shares, publicR, c.mask)
+	return c
+}
+
+func (c *dummyClient) assign(publicR fr_bn254.Element) sumAndCmpDummyCircuit {
+	assignment := sumAndCmpDummyCircuit{
+		PrivateVec:       make([]frontend.Variable, len(c.shares)),
+		DummyVec:         make([]frontend.Variable, len(c.dummies)),
+		PublicThreshold:  PublicThreshold,
+		PublicR:          publicR,
+		PublicProd:       c.publicProd,
+		PublicCommitment: c.com,
+		PrivateSalt:      c.salt,
+	}
+	for j := 0; j < len(c.shares); j++ {
+		assignment.PrivateVec[j] = c.shares[j]
+	}
+	for j := 0; j < len(c.dummies); j++ {
+		assignment.DummyVec[j] = c.dummies[j]
+	}
+	return assignment
+}
+
+func TestSumAndCmpDummyCircuit(t *testing.T) {
+	publicR := randomFr()
+	client := newDummyClient(1000, 5, 4, publicR)
+	circuit := sumAndCmpDummyCircuit{PrivateVec: make([]frontend.Variable, 5), DummyVec: make([]frontend.Variable, 4)}
+
+	assignment := client.assign(publicR)
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a product which is not masked by the committed dummies
+	wrongProd := SumPublicProd(client.shares, publicR, randomFr())
+	assignment = client.assign(publicR)
+	assignment.PublicProd = wrongProd
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("a product with a wrong mask was accepted")
+	}
+
+	// dummies chosen after the challenge, which do not open the commitment
+	forged := client
+	forged.dummies = append([]fr_bn254.Element(nil), client.dummies...)
+	forged.dummies[0] = randomFr()
+	forged.mask = PolyEval(forged.dummies, publicR)
+	forged.publicProd = SumPublicProd(forged.shares, publicR, forged.mask)
+	assignment = forged.assign(publicR)
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("dummies which do not open the commitment were accepted")
+	}
+}
+
+func TestNewSumDummyWitness(t *testing.T) {
+	defer func(n uint64) { DummyVecLength = n }(DummyVecLength)
+	DummyVecLength = 4
+
+	publicR := randomFr()
+	client := newDummyClient(1000, PrivateVecLength, int(DummyVecLength), publicR)
+	witness, publicWitness, err := NewSumDummyWitness(client.shares, client.dummies, publicR, client.publicProd, client.com, client.salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccs, err := gnarkshim.CompileR1CS(ecc.BN254, newSumCircuit(DummiesInCircuit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ccs.IsSolved(witness); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := witness.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBytes, _ := expected.MarshalBinary()
+	publicBytes, _ := publicWitness.MarshalBinary()
+	if !bytes.Equal(expectedBytes, publicBytes) {
+		t.Fatal("the public witness differs from the public part of the witness")
+	}
+}
+
+// TestDummiesInCircuitProduct checks the server side of the in-circuit mode:
+// the masks from the shuffler cancel the masks in the products of the clients.
+func TestDummiesInCircuitProduct(t *testing.T) {
+	publicR := randomFr()
+	var allShares, allMasks []fr_bn254.Element
+	prodFromClients := fr_bn254.One()
+	for i := 0; i < 5; i++ {
+		client := newDummyClient(uint64(i), 5, 4, publicR)
+		allShares = append(allShares, client.shares...)
+		allMasks = append(allMasks, client.mask)
+		prodFromClients.Mul(&prodFromClients, &client.publicProd)
+	}
+	prodFromShuffler := PolyEval(allShares, publicR)
+	for i := 0; i < len(allMasks); i++ {
+		prodFromShuffler.Mul(&prodFromShuffler, &allMasks[i])
+	}
+	if !prodFromShuffler.Equal(&prodFromClients) {
+		t.Fatal("the product from the shuffler differs from the product from the clients")
+	}
+}
+
+func TestParseDummyMode(t *testing.T) {
+	defer func(n uint64) { DummyVecLength = n }(DummyVecLength)
+	DummyVecLength = ComputeDummyNum(80, 1000, 500)
+
+	for _, m := range []DummyMode{DummiesShuffled, DummiesInCircuit} {
+		got, err := ParseDummyMode(m.String())
+		if err != nil || got != m {
+			t.Fatalf("ParseDummyMode(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseDummyMode("none"); err == nil {
+		t.Fatal("an unknown mode was accepted")
+	}
+	if DummiesInCircuit.ShufflerCost() >= DummiesShuffled.ShufflerCost() {
+		t.Fatal("the in-circuit mode does not send less to the shuffler")
+	}
+}
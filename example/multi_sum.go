@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/hasher"
+	"example/verification/internal/pool"
+)
+
+// A client reporting M bounded attributes splits every attribute into its own
+// PrivateVecLength shares and proves all of them at once: one mask, one
+// commitment and one PublicProd cover the M share vectors. The shuffler
+// carries every share with the index of its attribute, so that the server can
+// add up each attribute on its own. The product check runs over the packed
+// shares PackShare(a, share, publicT) = share + a*publicT, where publicT is a
+// second challenge drawn with publicR: a client which sends a share under
+// another attribute than the one it proved would have to guess publicT.
+
+// ErrMultiSumDummies is returned by MultiSumGroth16 in the in-circuit dummy
+// mode, which only has a circuit for one attribute.
+var ErrMultiSumDummies = errors.New("multi-attribute sum: only the shuffled dummies are supported")
+
+// PackShare tags share with the attribute attr.
+func PackShare(attr int, share fr_bn254.Element, publicT fr_bn254.Element) fr_bn254.Element {
+	tag := fr_bn254.NewElement(uint64(attr))
+	tag.Mul(&tag, &publicT)
+	tag.Add(&tag, &share)
+	return tag
+}
+
+// PackShareInCircuit is PackShare in the circuit.
+func PackShareInCircuit(api frontend.API, attr int, share frontend.Variable, publicT frontend.Variable) frontend.Variable {
+	return api.Add(share, api.Mul(attr, publicT))
+}
+
+// multiSumTDomain separates the challenge publicT that packs the attributes
+// from publicR, both derived from the same commitments.
+const multiSumTDomain = "shuffle-zkp/multi_sum/challenge-t/v1"
+
+// multiSumCircuit is sumAndCmpCircuit for len(PrivateVecs) attributes: the
+// shares of attribute a add up to at most PublicThresholds[a].
+type multiSumCircuit struct {
+	PrivateVecs      [][]frontend.Variable
+	PublicThresholds []frontend.Variable `gnark:",public"`
+
+	// The following are for the polynomial evaluation
+	PrivateMask frontend.Variable
+	PublicR     frontend.Variable `gnark:",public"`
+	PublicT     frontend.Variable `gnark:",public"`
+	PublicProd  frontend.Variable `gnark:",public"`
+
+	// The following are for the commitment
+	PublicCommitment frontend.Variable `gnark:",public"`
+	PrivateSalt      frontend.Variable
+
+	// Hash is the hash of the commitment, see ProtocolParams.
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+func (circuit *multiSumCircuit) Define(api frontend.API) error {
+	if len(circuit.PrivateVecs) != len(circuit.PublicThresholds) {
+		return fmt.Errorf("%v share vectors but %v thresholds", len(circuit.PrivateVecs), len(circuit.PublicThresholds))
+	}
+	var packed []frontend.Variable
+	for a := 0; a < len(circuit.PrivateVecs); a++ {
+		sum := frontend.Variable(0)
+		for i := 0; i < len(circuit.PrivateVecs[a]); i++ {
+			sum = api.Add(sum, circuit.PrivateVecs[a][i])
+			packed = append(packed, PackShareInCircuit(api, a, circuit.PrivateVecs[a][i], circuit.PublicT))
+		}
+		api.AssertIsLessOrEqual(0, sum)
+		api.AssertIsLessOrEqual(sum, circuit.PublicThresholds[a])
+	}
+
+	privateProd := PolyEvalInCircuit(api, packed, circuit.PublicR)
+	privateProd = api.Mul(privateProd, circuit.PrivateMask)
+	api.AssertIsEqual(privateProd, circuit.PublicProd)
+
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	for a := 0; a < len(circuit.PrivateVecs); a++ {
+		h.Write(circuit.PrivateVecs[a]...)
+	}
+	h.Write(circuit.PrivateMask)
+	h.Write(circuit.PrivateSalt)
+	api.AssertIsEqual(circuit.PublicCommitment, h.Sum())
+	return nil
+}
+
+// newMultiSumCircuit returns the empty circuit of m attributes of vecLength
+// shares each, hashing with Params.Hash, ready to be compiled.
+func newMultiSumCircuit(m int, vecLength int) *multiSumCircuit {
+	circuit := &multiSumCircuit{
+		PrivateVecs:      make([][]frontend.Variable, m),
+		PublicThresholds: make([]frontend.Variable, m),
+		Hash:             Params.Hash,
+	}
+	for a := 0; a < m; a++ {
+		circuit.PrivateVecs[a] = make([]frontend.Variable, vecLength)
+	}
+	return circuit
+}
+
+// multiSumPublic are the public variables of the circuit of m attributes, in
+// the order of its public witness.
+func multiSumPublic(m int) []string {
+	return append(gnarkshim.PublicSlice("PublicThresholds", m), "PublicR", "PublicT", "PublicProd", "PublicCommitment")
+}
+
+// SplitAttributes splits every value of vals into vecLength shares adding up
+// to it.
+func SplitAttributes(vals []uint64, vecLength int) [][]fr_bn254.Element {
+	shares := make([][]fr_bn254.Element, len(vals))
+	for a := 0; a < len(vals); a++ {
+		shares[a] = make([]fr_bn254.Element, vecLength)
+		shares[a][0] = fr_bn254.NewElement(vals[a])
+		for j := 1; j < vecLength; j++ {
+			shares[a][j] = randomFr()
+			shares[a][0].Sub(&shares[a][0], &shares[a][j])
+		}
+	}
+	return shares
+}
+
+// MultiSumCommitment is the commitment of a client to the shares of all its
+// attributes, its mask and its salt.
+func MultiSumCommitment(shares [][]fr_bn254.Element, mask fr_bn254.Element, salt fr_bn254.Element) fr_bn254.Element {
+	vals := make([][]fr_bn254.Element, 0, len(shares)+1)
+	vals = append(vals, shares...)
+	return commitFr(append(vals, []fr_bn254.Element{mask, salt})...)
+}
+
+// packShares packs the shares of every attribute, in order.
+func packShares(shares [][]fr_bn254.Element, publicT fr_bn254.Element) []fr_bn254.Element {
+	var packed []fr_bn254.Element
+	for a := 0; a < len(shares); a++ {
+		for j := 0; j < len(shares[a]); j++ {
+			packed = append(packed, PackShare(a, shares[a][j], publicT))
+		}
+	}
+	return packed
+}
+
+// MultiSumPublicProd returns the product of the packed shares evaluated at
+// publicR times mask, which a client reveals to the server.
+func MultiSumPublicProd(shares [][]fr_bn254.Element, publicR fr_bn254.Element, publicT fr_bn254.Element, mask fr_bn254.Element) fr_bn254.Element {
+	return SumPublicProd(packShares(shares, publicT), publicR, mask)
+}
+
+// NewMultiSumWitness builds the full and the public witness of a client with
+// the given shares, one vector per attribute.
+func NewMultiSumWitness(shares [][]fr_bn254.Element, thresholds []uint64, mask fr_bn254.Element, publicR fr_bn254.Element,
+	publicT fr_bn254.Element, publicProd fr_bn254.Element, com fr_bn254.Element, salt fr_bn254.Element) (witness.Witness, witness.Witness, error) {
+	assignment := multiSumCircuit{
+		PrivateVecs:      make([][]frontend.Variable, len(shares)),
+		PublicThresholds: make([]frontend.Variable, len(thresholds)),
+		PrivateMask:      mask,
+		PublicR:          publicR,
+		PublicT:          publicT,
+		PublicProd:       publicProd,
+		PublicCommitment: com,
+		PrivateSalt:      salt,
+	}
+	for a := 0; a < len(shares); a++ {
+		assignment.PrivateVecs[a] = make([]frontend.Variable, len(shares[a]))
+		for j := 0; j < len(shares[a]); j++ {
+			assignment.PrivateVecs[a][j] = shares[a][j]
+		}
+	}
+	for a := 0; a < len(thresholds); a++ {
+		assignment.PublicThresholds[a] = thresholds[a]
+	}
+	witness, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("witness generation: %w", err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return nil, nil, fmt.Errorf("public witness extraction: %w", err)
+	}
+	return witness, publicWitness, nil
+}
+
+// ShuffleTaggedShares is the shuffler releasing the shares of all the clients
+// with their attributes, both shuffled with the same permutation.
+func ShuffleTaggedShares(allShares [][][]fr_bn254.Element) ([]int, []fr_bn254.Element) {
+	var attrs []int
+	var shares []fr_bn254.Element
+	for i := 0; i < len(allShares); i++ {
+		for a := 0; a < len(allShares[i]); a++ {
+			for j := 0; j < len(allShares[i][a]); j++ {
+				attrs = append(attrs, a)
+				shares = append(shares, allShares[i][a][j])
+			}
+		}
+	}
+	rand.Shuffle(len(shares), func(i, j int) {
+		attrs[i], attrs[j] = attrs[j], attrs[i]
+		shares[i], shares[j] = shares[j], shares[i]
+	})
+	return attrs, shares
+}
+
+// MultiShufflerProduct is the product the server recomputes from the shuffled
+// tagged shares and masks. It equals the product of the PublicProd of the
+// clients when the shuffler and the clients saw the same data.
+func MultiShufflerProduct(attrs []int, shares []fr_bn254.Element, masks []fr_bn254.Element, publicR fr_bn254.Element, publicT fr_bn254.Element) fr_bn254.Element {
+	packed := make([]fr_bn254.Element, len(shares))
+	for i := 0; i < len(shares); i++ {
+		packed[i] = PackShare(attrs[i], shares[i], publicT)
+	}
+	prod := PolyEval(packed, publicR)
+	for i := 0; i < len(masks); i++ {
+		prod.Mul(&prod, &masks[i])
+	}
+	return prod
+}
+
+// DemuxSums adds up the shuffled shares of each of the m attributes.
+func DemuxSums(attrs []int, shares []fr_bn254.Element, m int) ([]fr_bn254.Element, error) {
+	sums := make([]fr_bn254.Element, m)
+	for i := 0; i < len(shares); i++ {
+		if attrs[i] < 0 || attrs[i] >= m {
+			return nil, fmt.Errorf("share %v: attribute %v out of [0, %v)", i, attrs[i], m)
+		}
+		sums[attrs[i]].Add(&sums[attrs[i]], &shares[i])
+	}
+	return sums, nil
+}
+
+// sumCost is the amortized cost of one run of a sum benchmark.
+type sumCost struct {
+	clientTime time.Duration
+	serverTime time.Duration
+	commCost   uint64
+}
+
+// MultiSumGroth16 runs the sum protocol with m attributes per client and one
+// Groth16 proof per client. Every attribute is bounded by PublicThreshold.
+func MultiSumGroth16(m int) (sumCost, error) {
+	if Dummies != DummiesShuffled {
+		return sumCost{}, ErrMultiSumDummies
+	}
+	DummyVecLength = ComputeDummyNum(80, ClientNum, CorruptedNum)
+	log.Printf("lambda %v, n %v, t %v, Dummy Num: %v, attributes: %v\n", 80, ClientNum, CorruptedNum, DummyVecLength, m)
+
+	ccs, err := gnarkshim.CompileR1CS(ecc.BN254, newMultiSumCircuit(m, PrivateVecLength))
+	if err != nil {
+		return sumCost{}, fmt.Errorf("r1cs circuit compile: %w", err)
+	}
+	if err := gnarkshim.CheckPublic(ccs, multiSumPublic(m)); err != nil {
+		return sumCost{}, err
+	}
+	pk, vk, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		return sumCost{}, fmt.Errorf("groth16 setup: %w", err)
+	}
+
+	thresholds := make([]uint64, m)
+	secretVals := make([][]uint64, ClientNum)
+	for i := 0; i < ClientNum; i++ {
+		secretVals[i] = make([]uint64, m)
+	}
+	expectedSums := make([]uint64, m)
+	for a := 0; a < m; a++ {
+		thresholds[a] = PublicThreshold
+		noise := GenDistributedDPNoise(eps, 1000.0, ClientNum)
+		for i := 0; i < ClientNum; i++ {
+			secretVals[i][a] = uint64(1000 + noise[i])
+			expectedSums[a] += secretVals[i][a]
+		}
+	}
+
+	// Step 1: every client splits its attributes, draws its dummies and
+	// commits to its shares and its mask
+	start := time.Now()
+	shares := make([][][]fr_bn254.Element, ClientNum)
+	secretMask := make([]fr_bn254.Element, ClientNum)
+	secretSalt := make([]fr_bn254.Element, ClientNum)
+	commitment := make([]fr_bn254.Element, ClientNum)
+	allMask := make([]fr_bn254.Element, 0, uint64(ClientNum)*DummyVecLength)
+	for i := 0; i < ClientNum; i++ {
+		shares[i] = SplitAttributes(secretVals[i], PrivateVecLength)
+		secretMask[i] = fr_bn254.One()
+		for j := uint64(0); j < DummyVecLength; j++ {
+			dummy := randomFr()
+			secretMask[i].Mul(&secretMask[i], &dummy)
+			allMask = append(allMask, dummy)
+		}
+		secretSalt[i] = randomFr()
+		commitment[i] = MultiSumCommitment(shares[i], secretMask[i], secretSalt[i])
+	}
+	prepTime := time.Since(start)
+
+	attrs, allShares := ShuffleTaggedShares(shares)
+	rand.Shuffle(len(allMask), func(i, j int) {
+		allMask[i], allMask[j] = allMask[j], allMask[i]
+	})
+
+	// Step 2: the server derives the challenges from the commitments
+	start = time.Now()
+	publicRFr := serverChallenge(challengeDomain, commitment)
+	publicTFr := serverChallenge(multiSumTDomain, commitment)
+	challengeTime := time.Since(start)
+
+	// Step 3: the clients prove
+	start = time.Now()
+	allProof := make([]ClientSubmissionToServer, ClientNum)
+	var witnessTime atomic.Int64
+	err = pool.Run(ClientNum, Workers, func(i int) error {
+		publicProd := MultiSumPublicProd(shares[i], publicRFr, publicTFr, secretMask[i])
+		if i >= MaxNumOfCheckProof {
+			allProof[i] = ClientSubmissionToServer{publicProd: publicProd}
+			return nil
+		}
+		witnessStart := time.Now()
+		witness, publicWitness, err := NewMultiSumWitness(shares[i], thresholds, secretMask[i], publicRFr, publicTFr,
+			publicProd, commitment[i], secretSalt[i])
+		witnessTime.Add(int64(time.Since(witnessStart)))
+		if err != nil {
+			return fmt.Errorf("client %v: proof generation: %w", i, err)
+		}
+		toServer, err := GenProofGroth16(witness, publicWitness, publicProd, &ccs, &pk)
+		if err != nil {
+			return fmt.Errorf("client %v: proof generation: %w", i, err)
+		}
+		allProof[i] = toServer
+		return nil
+	})
+	if err != nil {
+		return sumCost{}, err
+	}
+	proofTime := time.Since(start)
+	witnessTimePerClient := time.Duration(witnessTime.Load()) / time.Duration(MaxNumOfCheckProof)
+
+	var buf bytes.Buffer
+	(*(allProof[0].proof)).WriteTo(&buf)
+	proofSize := buf.Len()
+	buf.Reset()
+	(*(allProof[0].publicWitness)).WriteTo(&buf)
+	publicWitnessSize := buf.Len()
+	buf.Reset()
+
+	// Step 4: the server verifies the proofs and the product, then adds up
+	// every attribute
+	start = time.Now()
+	verifyErrs := VerifyGroth16Submissions(allProof, vk)
+	prodFromClients := fr_bn254.One()
+	for i := 0; i < ClientNum; i++ {
+		prodFromClients.Mul(&prodFromClients, &allProof[i].publicProd)
+	}
+	verifyTime := time.Since(start)
+	if len(verifyErrs) > 0 {
+		return sumCost{}, fmt.Errorf("%v proof(s) failed verification, first: %w", len(verifyErrs), verifyErrs[0])
+	}
+
+	start = time.Now()
+	prodFromShuffler := MultiShufflerProduct(attrs, allShares, allMask, publicRFr, publicTFr)
+	if prodFromShuffler.Equal(&prodFromClients) {
+		fmt.Printf("server: the set from clients is the same as the set from shuffler\n")
+	} else {
+		fmt.Printf("server: the set from clients is NOT the same as the set from shuffler\n")
+	}
+	sums, err := DemuxSums(attrs, allShares, m)
+	if err != nil {
+		return sumCost{}, err
+	}
+	serverTime := time.Since(start)
+	for a := 0; a < m; a++ {
+		fmt.Printf("The computed sum of attribute %v is %v (expected %v)\n", a, sums[a].Uint64(), expectedSums[a])
+	}
+
+	// the challenge is two field elements
+	commCost := uint64(proofSize) + uint64(publicWitnessSize) + 2*BN254Size + CommitmentSize + Dummies.ShufflerCost()
+	clientTime := prepTime/time.Duration(ClientNum) + proofTime/time.Duration(MaxNumOfCheckProof)
+	serverTotalTime := serverTime/time.Duration(ClientNum) + verifyTime/time.Duration(MaxNumOfCheckProof)
+
+	log.Printf("========Stats (Multi-Attribute Sum w/ Groth16 Proof, %v attributes)======\n", m)
+	log.Printf("Constraints %v\n", ccs.GetNbConstraints())
+	log.Printf("Challenges (random %v, round %v): %v\n", RandomChallenge, RoundID, challengeTime)
+	log.Printf("Proof Size %v\n", proofSize)
+	log.Printf("Public Witness Size %v\n", publicWitnessSize)
+	log.Printf("Communication Total: %v\n", commCost)
+	log.Printf("Client Total: %v\n", clientTime)
+	log.Printf("Server Total: %v\n", serverTotalTime)
+	log.Printf("============================\n")
+
+	file.WriteString(fmt.Sprintf("Shuffle-DP Multi-Sum Groth16 (%v attributes), %v, %v, %v, %v, %v\n", m, ClientNum-CorruptedNum, clientTime, serverTotalTime, commCost, witnessTimePerClient))
+	return sumCost{clientTime: clientTime, serverTime: serverTotalTime, commCost: commCost}, nil
+}
+
+// logMultiSumComparison logs the cost of one run with m attributes against m
+// separate runs of single, the cost of one single-attribute run.
+func logMultiSumComparison(m int, single sumCost, multi sumCost) {
+	separate := sumCost{
+		clientTime: single.clientTime * time.Duration(m),
+		serverTime: single.serverTime * time.Duration(m),
+		commCost:   single.commCost * uint64(m),
+	}
+	log.Printf("=====%v Attributes: One Proof vs %v Separate Runs=====\n", m, m)
+	log.Printf("Client Time: %v vs %v\n", multi.clientTime, separate.clientTime)
+	log.Printf("Server Time: %v vs %v\n", multi.serverTime, separate.serverTime)
+	log.Printf("Communication Cost (bytes): %v vs %v\n", multi.commCost, separate.commCost)
+	log.Printf("============================\n")
+}
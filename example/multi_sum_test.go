@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// multiSumClient is the private state of one client of the multi-attribute sum.
+type multiSumClient struct {
+	shares     [][]fr_bn254.Element
+	mask       fr_bn254.Element
+	salt       fr_bn254.Element
+	com        fr_bn254.Element
+	publicProd fr_bn254.Element
+}
+
+func newMultiSumClient(vals []uint64, vecLength int, publicR fr_bn254.Element, publicT fr_bn254.Element) multiSumClient {
+	var c multiSumClient
+	c.shares = SplitAttributes(vals, vecLength)
+	c.mask = randomFr()
+	c.salt = randomFr()
+	c.com = MultiSumCommitment(c.shares, c.mask, c.salt)
+	c.publicProd = MultiSumPublicProd(c.shares, publicR, publicT, c.mask)
+	return c
+}
+
+func (c *multiSumClient) assign(thresholds []uint64, publicR fr_bn254.Element, publicT fr_bn254.Element) multiSumCircuit {
+	assignment := multiSumCircuit{
+		PrivateVecs:      make([][]frontend.Variable, len(c.shares)),
+		PublicThresholds: make([]frontend.Variable, len(thresholds)),
+		PrivateMask:      c.mask,
+		PublicR:          publicR,
+		PublicT:          publicT,
+		PublicProd:       c.publicProd,
+		PublicCommitment: c.com,
+		PrivateSalt:      c.salt,
+	}
+	for a := 0; a < len(c.shares); a++ {
+		assignment.PrivateVecs[a] = make([]frontend.Variable, len(c.shares[a]))
+		for j := 0; j < len(c.shares[a]); j++ {
+			assignment.PrivateVecs[a][j] = c.shares[a][j]
+		}
+	}
+	for a := 0; a < len(thresholds); a++ {
+		assignment.PublicThresholds[a] = thresholds[a]
+	}
+	return assignment
+}
+
+func TestMultiSumCircuit(t *testing.T) {
+	thresholds := []uint64{100, 10, 1}
+	publicR, publicT := randomFr(), randomFr()
+	circuit := newMultiSumCircuit(3, 5)
+
+	under := newMultiSumClient([]uint64{100, 10, 1}, 5, publicR, publicT)
+	assignment := under.assign(thresholds, publicR, publicT)
+	if err := test.IsSolved(circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+
+	// every attribute has its own bound
+	over := newMultiSumClient([]uint64{0, 11, 0}, 5, publicR, publicT)
+	assignment = over.assign(thresholds, publicR, publicT)
+	if err := test.IsSolved(circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("an attribute over its threshold was accepted")
+	}
+
+	// a product which tags the shares with other attributes
+	swapped := under
+	swapped.shares = [][]fr_bn254.Element{under.shares[1], under.shares[0], under.shares[2]}
+	swapped.publicProd = MultiSumPublicProd(swapped.shares, publicR, publicT, under.mask)
+	assignment = under.assign(thresholds, publicR, publicT)
+	assignment.PublicProd = swapped.publicProd
+	if err := test.IsSolved(circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("a product of mistagged shares was accepted")
+	}
+}
+
+func TestMultiSumWitness(t *testing.T) {
+	thresholds := []uint64{PublicThreshold, PublicThreshold}
+	publicR, publicT := randomFr(), randomFr()
+	client := newMultiSumClient([]uint64{7, 8}, 4, publicR, publicT)
+	assignment := client.assign(thresholds, publicR, publicT)
+	witness, _, err := NewMultiSumWitness(client.shares, thresholds, client.mask, publicR, publicT, client.publicProd, client.com, client.salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnessBytes, _ := witness.MarshalBinary()
+	expectedBytes, _ := expected.MarshalBinary()
+	if !bytes.Equal(witnessBytes, expectedBytes) {
+		t.Fatal("the witness differs from the assignment")
+	}
+}
+
+// TestMultiSumServer checks the server side: the tagged shares from the
+// shuffler match the products of the clients and add up to every attribute.
+func TestMultiSumServer(t *testing.T) {
+	const m = 4
+	publicR, publicT := randomFr(), randomFr()
+	var allShares [][][]fr_bn254.Element
+	var masks []fr_bn254.Element
+	expected := make([]uint64, m)
+	prodFromClients := fr_bn254.One()
+	for i := 0; i < 5; i++ {
+		vals := []uint64{uint64(i), uint64(10 * i), 3, 0}
+		for a := 0; a < m; a++ {
+			expected[a] += vals[a]
+		}
+		client := newMultiSumClient(vals, 5, publicR, publicT)
+		allShares = append(allShares, client.shares)
+		masks = append(masks, client.mask)
+		prodFromClients.Mul(&prodFromClients, &client.publicProd)
+	}
+
+	attrs, shares := ShuffleTaggedShares(allShares)
+	prodFromShuffler := MultiShufflerProduct(attrs, shares, masks, publicR, publicT)
+	if !prodFromShuffler.Equal(&prodFromClients) {
+		t.Fatal("the product from the shuffler differs from the product from the clients")
+	}
+	sums, err := DemuxSums(attrs, shares, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for a := 0; a < m; a++ {
+		if sums[a].Uint64() != expected[a] || !sums[a].IsUint64() {
+			t.Fatalf("attribute %v: sum %v, expected %v", a, sums[a].String(), expected[a])
+		}
+	}
+
+	// a share moved to another attribute
+	attrs[0] = (attrs[0] + 1) % m
+	prodFromShuffler = MultiShufflerProduct(attrs, shares, masks, publicR, publicT)
+	if prodFromShuffler.Equal(&prodFromClients) {
+		t.Fatal("a mistagged share passed the product check")
+	}
+	attrs[0] = m
+	if _, err := DemuxSums(attrs, shares, m); err == nil {
+		t.Fatal("an unknown attribute was accepted")
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+
+	"example/verification/internal/fiatshamir"
+	"example/verification/internal/polyeval"
+)
+
+// A round of the sum protocol runs in four phases:
+//
+//  1. Commit: every client splits its value into shares, commits to them and
+//     sends the commitment to the server and the shares (and, in the shuffled
+//     mode, the dummies) through the shuffler.
+//  2. Challenge: once the commitments are in, the server issues publicR.
+//  3. Prove: every client answers with its public product and a proof, and
+//     in the in-circuit mode sends its mask through the shuffler.
+//  4. Verify: the server verifies the proofs, compares the product of the
+//     public products with the evaluation of the shuffler output at publicR,
+//     and sums the shares.
+//
+// Round is the server side and RoundClient the client side. The shuffler is
+// left to the caller, who collects the ShufflerBatch of every client, shuffles
+// it and hands it to Finalize.
+
+var (
+	// ErrAlreadyCommitted is returned for a second commitment of a client.
+	ErrAlreadyCommitted = errors.New("round: client already committed")
+	// ErrNotCommitted is returned when a client answers the challenge without
+	// having committed.
+	ErrNotCommitted = errors.New("round: client did not commit")
+	// ErrNoChallenge is returned when a submission comes before the challenge.
+	ErrNoChallenge = errors.New("round: challenge not issued")
+	// ErrChallengeIssued is returned for a commitment after the challenge, or a
+	// second challenge.
+	ErrChallengeIssued = errors.New("round: challenge already issued")
+	// ErrAlreadySubmitted is returned for a second submission of a client.
+	ErrAlreadySubmitted = errors.New("round: client already submitted")
+	// ErrMissingSubmission is returned by Finalize when a client committed but
+	// did not submit.
+	ErrMissingSubmission = errors.New("round: missing submission")
+	// ErrSubmissionMismatch is returned for a submission whose public witness
+	// is not the one of its commitment, the challenge and its public product.
+	ErrSubmissionMismatch = errors.New("round: submission does not match the round")
+	// ErrChallengeMismatch is returned when a derived challenge is not the
+	// Fiat-Shamir challenge of its commitments, or misses the commitment of
+	// the client.
+	ErrChallengeMismatch = errors.New("round: challenge not derived from the commitments")
+)
+
+// challengeDomain separates the challenges of the sum from the other MiMC
+// hashes of commitments.
+const challengeDomain = "shuffle-zkp/sum/challenge/v1"
+
+// RandomChallenge makes the server sample publicR at random instead of
+// deriving it with DeriveChallenge. It is only sound if the server samples
+// publicR after receiving every commitment, and is kept to compare the
+// benchmarks with the interactive protocol.
+var RandomChallenge = false
+
+// RoundID identifies the round the challenges are derived for, so that the
+// commitments of a round cannot be replayed in another one.
+var RoundID uint64
+
+// DeriveChallenge is the Fiat-Shamir challenge publicR of the round whose
+// clients sent commitments, in order: see fiatshamir.Challenge.
+func DeriveChallenge(round uint64, commitments []fr_bn254.Element) fr_bn254.Element {
+	return fiatshamir.Challenge(challengeDomain, round, commitments)
+}
+
+// serverChallenge is the challenge of domain the server broadcasts, see
+// RandomChallenge.
+func serverChallenge(domain string, commitments []fr_bn254.Element) fr_bn254.Element {
+	if RandomChallenge {
+		return randomFr()
+	}
+	return fiatshamir.Challenge(domain, RoundID, commitments)
+}
+
+// ShufflerBatch are elements sent through the shuffler: the shares of the
+// values and the masks of the clients, the dummies in the shuffled mode.
+type ShufflerBatch struct {
+	Shares []fr_bn254.Element
+	Masks  []fr_bn254.Element
+}
+
+// Append appends the elements of o to b.
+func (b *ShufflerBatch) Append(o ShufflerBatch) {
+	b.Shares = append(b.Shares, o.Shares...)
+	b.Masks = append(b.Masks, o.Masks...)
+}
+
+// Shuffle shuffles the shares and the masks of b, as the shuffler does.
+func (b *ShufflerBatch) Shuffle() {
+	rand.Shuffle(len(b.Shares), func(i, j int) {
+		b.Shares[i], b.Shares[j] = b.Shares[j], b.Shares[i]
+	})
+	rand.Shuffle(len(b.Masks), func(i, j int) {
+		b.Masks[i], b.Masks[j] = b.Masks[j], b.Masks[i]
+	})
+}
+
+// Challenge is what the server broadcasts to the clients: publicR, along with
+// the circuit and the proving key of the round the clients got at setup. A
+// derived challenge also carries its round and the commitments, ordered by
+// client ID, so that anybody can recompute it.
+type Challenge struct {
+	PublicR     fr_bn254.Element
+	Round       uint64
+	Commitments []fr_bn254.Element
+	ccs         constraint.ConstraintSystem
+	pk          groth16.ProvingKey
+}
+
+// Derived reports whether publicR is derived from the commitments rather
+// than sampled by the server.
+func (c Challenge) Derived() bool {
+	return c.Commitments != nil
+}
+
+// Check recomputes a derived challenge and checks that it holds com.
+func (c Challenge) Check(com fr_bn254.Element) error {
+	r := DeriveChallenge(c.Round, c.Commitments)
+	if !r.Equal(&c.PublicR) {
+		return ErrChallengeMismatch
+	}
+	for i := 0; i < len(c.Commitments); i++ {
+		if c.Commitments[i].Equal(&com) {
+			return nil
+		}
+	}
+	return ErrChallengeMismatch
+}
+
+// RoundClient is a client of a round, in the dummy mode Dummies at its
+// creation.
+type RoundClient struct {
+	// NoProof skips the proof of the client, as the benchmarks do past the
+	// first MaxNumOfCheckProof clients: the server then only checks its
+	// public product.
+	NoProof bool
+
+	mode      DummyMode
+	shares    []fr_bn254.Element
+	dummies   []fr_bn254.Element
+	mask      fr_bn254.Element
+	salt      fr_bn254.Element
+	com       fr_bn254.Element
+	committed bool
+
+	witnessTime time.Duration
+}
+
+// NewClient splits value into PrivateVecLength shares and draws the
+// DummyVecLength dummies of a client.
+func NewClient(value uint64) *RoundClient {
+	c := &RoundClient{
+		mode:    Dummies,
+		shares:  make([]fr_bn254.Element, PrivateVecLength),
+		dummies: make([]fr_bn254.Element, DummyVecLength),
+	}
+	c.shares[0] = fr_bn254.NewElement(value)
+	for j := 1; j < len(c.shares); j++ {
+		c.shares[j] = randomFr()
+		c.shares[0].Sub(&c.shares[0], &c.shares[j])
+	}
+	c.mask = fr_bn254.One()
+	for j := 0; j < len(c.dummies); j++ {
+		c.dummies[j] = randomFr()
+		c.mask.Mul(&c.mask, &c.dummies[j])
+	}
+	return c
+}
+
+// Commit returns the commitment the client sends to the server and what it
+// sends through the shuffler.
+func (c *RoundClient) Commit() (fr_bn254.Element, ShufflerBatch, error) {
+	if c.committed {
+		return fr_bn254.Element{}, ShufflerBatch{}, ErrAlreadyCommitted
+	}
+	c.salt = randomFr()
+	toShuffler := ShufflerBatch{Shares: c.shares}
+	if c.mode == DummiesInCircuit {
+		c.com = SumDummyCommitment(c.shares, c.dummies, c.salt)
+	} else {
+		c.com = SumCommitment(c.shares, c.mask, c.salt)
+		toShuffler.Masks = c.dummies
+	}
+	c.committed = true
+	return c.com, toShuffler, nil
+}
+
+// Respond answers the challenge with the submission the client sends to the
+// server and, in the in-circuit mode, the mask it sends through the shuffler.
+// Calls on distinct clients may run concurrently.
+func (c *RoundClient) Respond(challenge Challenge) (ClientSubmissionToServer, ShufflerBatch, error) {
+	if !c.committed {
+		return ClientSubmissionToServer{}, ShufflerBatch{}, ErrNotCommitted
+	}
+	if challenge.ccs == nil {
+		return ClientSubmissionToServer{}, ShufflerBatch{}, ErrNoChallenge
+	}
+	if challenge.Derived() {
+		if err := challenge.Check(c.com); err != nil {
+			return ClientSubmissionToServer{}, ShufflerBatch{}, err
+		}
+	}
+	publicR := challenge.PublicR
+	var toShuffler ShufflerBatch
+	if c.mode == DummiesInCircuit {
+		c.mask = PolyEval(c.dummies, publicR)
+		toShuffler.Masks = []fr_bn254.Element{c.mask}
+	}
+	publicProd := SumPublicProd(c.shares, publicR, c.mask)
+	if c.NoProof {
+		return ClientSubmissionToServer{publicProd: publicProd}, toShuffler, nil
+	}
+
+	witnessStart := time.Now()
+	assignments := NewSumAssignments(1, len(c.shares), len(c.dummies))
+	witness, publicWitness, err := newClientWitness(assignments, 0, c.shares, c.dummies, &publicR, &c.mask, &publicProd, &c.com, &c.salt)
+	c.witnessTime = time.Since(witnessStart)
+	if err != nil {
+		return ClientSubmissionToServer{}, ShufflerBatch{}, fmt.Errorf("proof generation: %w", err)
+	}
+	submission, err := GenProofGroth16(witness, publicWitness, publicProd, &challenge.ccs, &challenge.pk)
+	if err != nil {
+		return ClientSubmissionToServer{}, ShufflerBatch{}, fmt.Errorf("proof generation: %w", err)
+	}
+	return submission, toShuffler, nil
+}
+
+// WitnessTime is the time the last Respond spent building the witness.
+func (c *RoundClient) WitnessTime() time.Duration {
+	return c.witnessTime
+}
+
+// ClientFailure is a client whose proof did not verify.
+type ClientFailure struct {
+	ClientID int
+	Err      error
+}
+
+// RoundResult is the outcome of a round.
+type RoundResult struct {
+	// Consistent reports whether the product of the public products is the
+	// evaluation of the shuffler output at publicR.
+	Consistent bool
+	// Failing are the clients whose proof did not verify, by ID.
+	Failing []ClientFailure
+	// Sum is the sum of the shares of the shuffler output.
+	Sum fr_bn254.Element
+	// VerifyTime is the time spent verifying the proofs, and CheckTime the
+	// time spent on the product check and the sum.
+	VerifyTime time.Duration
+	CheckTime  time.Duration
+}
+
+// Round is the server side of a round of the Groth16 sum protocol. Its
+// methods may be called concurrently.
+type Round struct {
+	ccs constraint.ConstraintSystem
+	pk  groth16.ProvingKey
+	vk  groth16.VerifyingKey
+
+	mu          sync.Mutex
+	commitments map[int]fr_bn254.Element
+	challenge   *Challenge
+	submissions map[int]ClientSubmissionToServer
+}
+
+// NewRound starts a round of the circuit ccs set up with pk and vk.
+func NewRound(ccs constraint.ConstraintSystem, pk groth16.ProvingKey, vk groth16.VerifyingKey) *Round {
+	return &Round{
+		ccs:         ccs,
+		pk:          pk,
+		vk:          vk,
+		commitments: make(map[int]fr_bn254.Element),
+		submissions: make(map[int]ClientSubmissionToServer),
+	}
+}
+
+// AcceptCommitment records the commitment of a client.
+func (r *Round) AcceptCommitment(clientID int, com fr_bn254.Element) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.challenge != nil {
+		return fmt.Errorf("client %v: %w", clientID, ErrChallengeIssued)
+	}
+	if _, ok := r.commitments[clientID]; ok {
+		return fmt.Errorf("client %v: %w", clientID, ErrAlreadyCommitted)
+	}
+	r.commitments[clientID] = com
+	return nil
+}
+
+// IssueChallenge closes the commitments and derives the challenge from them,
+// ordered by client ID, or draws it with RandomChallenge. The clients that
+// committed are the ones Finalize expects a submission from.
+func (r *Round) IssueChallenge() (Challenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.challenge != nil {
+		return Challenge{}, ErrChallengeIssued
+	}
+	if len(r.commitments) == 0 {
+		return Challenge{}, errors.New("round: no commitment")
+	}
+	r.challenge = &Challenge{Round: RoundID, ccs: r.ccs, pk: r.pk}
+	if RandomChallenge {
+		r.challenge.PublicR = randomFr()
+		return *r.challenge, nil
+	}
+	ids := make([]int, 0, len(r.commitments))
+	for id := range r.commitments {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	r.challenge.Commitments = make([]fr_bn254.Element, len(ids))
+	for i, id := range ids {
+		r.challenge.Commitments[i] = r.commitments[id]
+	}
+	r.challenge.PublicR = DeriveChallenge(RoundID, r.challenge.Commitments)
+	return *r.challenge, nil
+}
+
+// AcceptSubmission records the submission of a client. The public witness of
+// a submission with a proof must carry the commitment of the client, the
+// challenge and its public product; the proof itself is verified by Finalize.
+func (r *Round) AcceptSubmission(clientID int, submission ClientSubmissionToServer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.challenge == nil {
+		return fmt.Errorf("client %v: %w", clientID, ErrNoChallenge)
+	}
+	com, ok := r.commitments[clientID]
+	if !ok {
+		return fmt.Errorf("client %v: %w", clientID, ErrNotCommitted)
+	}
+	if _, ok := r.submissions[clientID]; ok {
+		return fmt.Errorf("client %v: %w", clientID, ErrAlreadySubmitted)
+	}
+	if submission.proof != nil {
+		public, ok := (*submission.publicWitness).Vector().(fr_bn254.Vector)
+		// sumPublic: PublicThreshold, PublicR, PublicProd, PublicCommitment
+		if !ok || len(public) != len(sumPublic) || !public[1].Equal(&r.challenge.PublicR) ||
+			!public[2].Equal(&submission.publicProd) || !public[3].Equal(&com) {
+			return fmt.Errorf("client %v: %w", clientID, ErrSubmissionMismatch)
+		}
+	}
+	r.submissions[clientID] = submission
+	return nil
+}
+
+// Finalize verifies the submissions and checks them against the shuffler
+// output. All the clients that committed must have submitted.
+func (r *Round) Finalize(out ShufflerBatch) (RoundResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.challenge == nil {
+		return RoundResult{}, ErrNoChallenge
+	}
+	ids := make([]int, 0, len(r.commitments))
+	var missing []int
+	for id := range r.commitments {
+		if _, ok := r.submissions[id]; !ok {
+			missing = append(missing, id)
+		}
+		ids = append(ids, id)
+	}
+	if len(missing) > 0 {
+		sort.Ints(missing)
+		return RoundResult{}, fmt.Errorf("%w from client(s) %v", ErrMissingSubmission, missing)
+	}
+	sort.Ints(ids)
+
+	var res RoundResult
+	start := time.Now()
+	allProof := make([]ClientSubmissionToServer, len(ids))
+	for i, id := range ids {
+		allProof[i] = r.submissions[id]
+	}
+	for i, err := range VerifyAll(allProof, r.vk, false) {
+		if err != nil {
+			res.Failing = append(res.Failing, ClientFailure{ClientID: ids[i], Err: err})
+		}
+	}
+	prodFromClients := fr_bn254.One()
+	for i := 0; i < len(allProof); i++ {
+		prodFromClients.Mul(&prodFromClients, &allProof[i].publicProd)
+	}
+	res.VerifyTime = time.Since(start)
+
+	start = time.Now()
+	prodFromShuffler := polyeval.Slice(out.Shares, r.challenge.PublicR, polyeval.DefaultChunk, Workers)
+	for i := 0; i < len(out.Masks); i++ {
+		prodFromShuffler.Mul(&prodFromShuffler, &out.Masks[i])
+	}
+	res.Consistent = prodFromShuffler.Equal(&prodFromClients)
+	for i := 0; i < len(out.Shares); i++ {
+		res.Sum.Add(&res.Sum, &out.Shares[i])
+	}
+	res.CheckTime = time.Since(start)
+	return res, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// roundKeys are the circuit and the keys of a dummy mode.
+type roundKeys struct {
+	ccs constraint.ConstraintSystem
+	pk  groth16.ProvingKey
+	vk  groth16.VerifyingKey
+}
+
+// testRoundKeys caches the setup of every dummy mode across the tests.
+var testRoundKeys = map[DummyMode]roundKeys{}
+
+// newTestRound starts a round of the circuit of the dummy mode Dummies with
+// sumDummyNum dummies.
+func newTestRound(t *testing.T) *Round {
+	t.Helper()
+	k, ok := testRoundKeys[Dummies]
+	if !ok {
+		var err error
+		if k.ccs, err = gnarkshim.CompileR1CS(ecc.BN254, newSumCircuit(Dummies)); err != nil {
+			t.Fatal(err)
+		}
+		if k.pk, k.vk, err = gnarkshim.SetupGroth16(k.ccs); err != nil {
+			t.Fatal(err)
+		}
+		testRoundKeys[Dummies] = k
+	}
+	return NewRound(k.ccs, k.pk, k.vk)
+}
+
+// runRound runs a round of values, the first client attaching a proof, and
+// returns its result. tamper may change the shuffler output.
+func runRound(t *testing.T, round *Round, values []uint64, tamper func(*ShufflerBatch)) RoundResult {
+	t.Helper()
+	clients := make([]*RoundClient, len(values))
+	var out ShufflerBatch
+	for i, v := range values {
+		clients[i] = NewClient(v)
+		clients[i].NoProof = i > 0
+		com, batch, err := clients[i].Commit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := round.AcceptCommitment(i, com); err != nil {
+			t.Fatal(err)
+		}
+		out.Append(batch)
+	}
+	challenge, err := round.IssueChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range clients {
+		submission, batch, err := clients[i].Respond(challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := round.AcceptSubmission(i, submission); err != nil {
+			t.Fatal(err)
+		}
+		out.Append(batch)
+	}
+	out.Shuffle()
+	if tamper != nil {
+		tamper(&out)
+	}
+	res, err := round.Finalize(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestRound(t *testing.T) {
+	defer func(n uint64, m DummyMode) { DummyVecLength, Dummies = n, m }(DummyVecLength, Dummies)
+	DummyVecLength = sumDummyNum
+	for _, mode := range []DummyMode{DummiesShuffled, DummiesInCircuit} {
+		Dummies = mode
+		res := runRound(t, newTestRound(t), []uint64{10, 200, 3000}, nil)
+		if !res.Consistent || len(res.Failing) != 0 || res.Sum.Uint64() != 3210 {
+			t.Fatalf("%v: consistent %v, failing %v, sum %v", mode, res.Consistent, res.Failing, res.Sum.Uint64())
+		}
+
+		// the shuffler drops a share
+		res = runRound(t, newTestRound(t), []uint64{10, 200, 3000}, func(out *ShufflerBatch) {
+			out.Shares = out.Shares[1:]
+		})
+		if res.Consistent {
+			t.Fatalf("%v: a dropped share went unnoticed", mode)
+		}
+	}
+}
+
+func TestRoundErrors(t *testing.T) {
+	defer func(n uint64, m DummyMode) { DummyVecLength, Dummies = n, m }(DummyVecLength, Dummies)
+	DummyVecLength, Dummies = sumDummyNum, DummiesShuffled
+	round := newTestRound(t)
+
+	// respond before the challenge, on both sides
+	client := NewClient(10)
+	client.NoProof = true
+	if _, _, err := client.Respond(Challenge{}); !errors.Is(err, ErrNotCommitted) {
+		t.Fatalf("respond before commit: got %v, expected ErrNotCommitted", err)
+	}
+	com, _, err := client.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.Respond(Challenge{}); !errors.Is(err, ErrNoChallenge) {
+		t.Fatalf("respond before the challenge: got %v, expected ErrNoChallenge", err)
+	}
+	if err := round.AcceptCommitment(0, com); err != nil {
+		t.Fatal(err)
+	}
+	if err := round.AcceptSubmission(0, ClientSubmissionToServer{}); !errors.Is(err, ErrNoChallenge) {
+		t.Fatalf("submission before the challenge: got %v, expected ErrNoChallenge", err)
+	}
+
+	// double commit, on both sides
+	if _, _, err := client.Commit(); !errors.Is(err, ErrAlreadyCommitted) {
+		t.Fatalf("second commit: got %v, expected ErrAlreadyCommitted", err)
+	}
+	if err := round.AcceptCommitment(0, com); !errors.Is(err, ErrAlreadyCommitted) {
+		t.Fatalf("second commitment: got %v, expected ErrAlreadyCommitted", err)
+	}
+
+	other := NewClient(20)
+	other.NoProof = true
+	otherCom, _, err := other.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := round.AcceptCommitment(1, otherCom); err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := round.IssueChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := round.AcceptCommitment(2, otherCom); !errors.Is(err, ErrChallengeIssued) {
+		t.Fatalf("commitment after the challenge: got %v, expected ErrChallengeIssued", err)
+	}
+	submission, _, err := client.Respond(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := round.AcceptSubmission(2, submission); !errors.Is(err, ErrNotCommitted) {
+		t.Fatalf("submission of an unknown client: got %v, expected ErrNotCommitted", err)
+	}
+	if err := round.AcceptSubmission(0, submission); err != nil {
+		t.Fatal(err)
+	}
+	if err := round.AcceptSubmission(0, submission); !errors.Is(err, ErrAlreadySubmitted) {
+		t.Fatalf("second submission: got %v, expected ErrAlreadySubmitted", err)
+	}
+
+	// client 1 committed but never submitted
+	if _, err := round.Finalize(ShufflerBatch{}); !errors.Is(err, ErrMissingSubmission) {
+		t.Fatalf("finalize with a missing submission: got %v, expected ErrMissingSubmission", err)
+	}
+}
+
+func TestRoundSubmissionMismatch(t *testing.T) {
+	defer func(n uint64, m DummyMode) { DummyVecLength, Dummies = n, m }(DummyVecLength, Dummies)
+	DummyVecLength, Dummies = sumDummyNum, DummiesShuffled
+	round := newTestRound(t)
+
+	// the proof of client 0 is sent on behalf of client 1
+	clients := []*RoundClient{NewClient(10), NewClient(20)}
+	for i, c := range clients {
+		com, _, err := c.Commit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := round.AcceptCommitment(i, com); err != nil {
+			t.Fatal(err)
+		}
+	}
+	challenge, err := round.IssueChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	submission, _, err := clients[0].Respond(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := round.AcceptSubmission(1, submission); !errors.Is(err, ErrSubmissionMismatch) {
+		t.Fatalf("got %v, expected ErrSubmissionMismatch", err)
+	}
+	if err := round.AcceptSubmission(0, submission); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoundChallenge(t *testing.T) {
+	defer func(n uint64, m DummyMode) { DummyVecLength, Dummies = n, m }(DummyVecLength, Dummies)
+	DummyVecLength, Dummies = sumDummyNum, DummiesShuffled
+	round := newTestRound(t)
+
+	client := NewClient(10)
+	client.NoProof = true
+	com, _, err := client.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the commitments are ordered by client ID, not by arrival
+	other := randomFr()
+	if err := round.AcceptCommitment(5, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := round.AcceptCommitment(2, com); err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := round.IssueChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := DeriveChallenge(RoundID, []fr_bn254.Element{com, other}); !challenge.PublicR.Equal(&expected) {
+		t.Fatal("the challenge is not derived from the commitments ordered by client ID")
+	}
+	if _, _, err := client.Respond(challenge); err != nil {
+		t.Fatal(err)
+	}
+
+	// a challenge whose commitments were swapped for others
+	forged := challenge
+	forged.Commitments = []fr_bn254.Element{com, randomFr()}
+	if _, _, err := client.Respond(forged); !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("forged commitments: got %v, expected ErrChallengeMismatch", err)
+	}
+	// a correctly derived challenge the client is left out of
+	forged.Commitments = []fr_bn254.Element{other}
+	forged.PublicR = DeriveChallenge(forged.Round, forged.Commitments)
+	if _, _, err := client.Respond(forged); !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("missing commitment: got %v, expected ErrChallengeMismatch", err)
+	}
+}
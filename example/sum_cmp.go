@@ -2,28 +2,32 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"math"
 	"math/rand"
 	"os"
+	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
-	"github.com/consensys/gnark-crypto/hash"
 	"github.com/consensys/gnark/backend/groth16"
 	"github.com/consensys/gnark/backend/plonk"
 	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/frontend/cs/scs"
-	"github.com/consensys/gnark/std/hash/mimc"
-	"github.com/consensys/gnark/test"
 
 	cs "github.com/consensys/gnark/constraint/bn254"
 	//"gonum.org/v1/gonum/stat/sampleuv"
+
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/hasher"
+	"example/verification/internal/polyeval"
+	"example/verification/internal/pool"
 )
 
 const (
@@ -45,6 +49,19 @@ const (
 var DummyVecLength uint64
 var file *os.File
 
+// Workers is the number of goroutines generating and verifying the client proofs.
+var Workers = runtime.NumCPU()
+
+// ProtocolParams are the choices the clients, the server and the circuit have
+// to agree on, besides the proof system.
+type ProtocolParams struct {
+	// Hash is the hash of the commitments, in and out of the circuit.
+	Hash hasher.HashConfig
+}
+
+// Params are the protocol parameters of the benchmarks.
+var Params = ProtocolParams{Hash: hasher.MiMC}
+
 func ComputeDummyNum(lambda uint64, n uint64, t uint64) uint64 {
 	tmp := float64(2*lambda+254)/float64(math.Log2(float64(n-t))-math.Log2(e)) + 2
 	return uint64(math.Ceil(tmp))
@@ -75,12 +92,18 @@ type sumAndCmpCircuit struct {
 
 	// The following are for the polynomial evaluation
 	PrivateMask frontend.Variable
-	PublicR     frontend.Variable `gnark:",public"`
-	PublicProd  frontend.Variable `gnark:",public"`
+	// DummyVec are the dummies sent to the shuffler; the mask is their
+	// product, as the server multiplies them in.
+	DummyVec   []frontend.Variable
+	PublicR    frontend.Variable `gnark:",public"`
+	PublicProd frontend.Variable `gnark:",public"`
 
 	// The following are for the commitment
 	PublicCommitment frontend.Variable `gnark:",public"`
 	PrivateSalt      frontend.Variable
+
+	// Hash is the hash of the commitment, see ProtocolParams.
+	Hash hasher.HashConfig `gnark:"-"`
 }
 
 func (circuit *sumAndCmpCircuit) Define(api frontend.API) error {
@@ -112,18 +135,28 @@ func (circuit *sumAndCmpCircuit) Define(api frontend.API) error {
 	// The following is for the polynomial evaluation
 	privateProd := PolyEvalInCircuit(api, circuit.PrivateVec, circuit.PublicR)
 	privateProd = api.Mul(privateProd, circuit.PrivateMask)
-	//privateProd = api.Mul(privateProd, PolyEvalInCircuit(api, circuit.DummyVec, circuit.PublicR))
 	api.AssertIsEqual(privateProd, circuit.PublicProd)
 
+	// the mask is the product of the dummies, otherwise the product of the
+	// client would not match the data of the shuffler
+	dummyProd := frontend.Variable(1)
+	for i := 0; i < len(circuit.DummyVec); i++ {
+		dummyProd = api.Mul(dummyProd, circuit.DummyVec[i])
+	}
+	api.AssertIsEqual(circuit.PrivateMask, dummyProd)
+
 	// TODO: check commitment
 
-	mimc, _ := mimc.NewMiMC(api)
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
 	for i := 0; i < len(circuit.PrivateVec); i++ {
-		mimc.Write(circuit.PrivateVec[i])
+		h.Write(circuit.PrivateVec[i])
 	}
-	mimc.Write(circuit.PrivateMask)
-	mimc.Write(circuit.PrivateSalt)
-	api.AssertIsEqual(circuit.PublicCommitment, mimc.Sum())
+	h.Write(circuit.PrivateMask)
+	h.Write(circuit.PrivateSalt)
+	api.AssertIsEqual(circuit.PublicCommitment, h.Sum())
 
 	return nil
 }
@@ -152,102 +185,217 @@ type ClientSubmissionToServerPlonk struct {
 	proof         *plonk.Proof
 }
 
+// ErrVerificationSkipped marks the proofs left unchecked by a fail-fast
+// verification after another proof failed.
+var ErrVerificationSkipped = errors.New("verification skipped after an earlier failure")
+
+// VerifyAll verifies the proofs attached to the submissions on Workers
+// goroutines and returns one entry per submission, nil when the proof verified
+// or no proof was attached. With failFast, the proofs not yet started when a
+// failure is seen are reported as ErrVerificationSkipped.
+func VerifyAll(allProof []ClientSubmissionToServer, vk groth16.VerifyingKey, failFast bool) []error {
+	var failed atomic.Bool
+	return pool.RunAll(len(allProof), Workers, func(i int) error {
+		if allProof[i].proof == nil {
+			return nil
+		}
+		if failFast && failed.Load() {
+			return ErrVerificationSkipped
+		}
+		if err := gnarkshim.VerifyGroth16(*allProof[i].proof, vk, *allProof[i].publicWitness); err != nil {
+			failed.Store(true)
+			return fmt.Errorf("client %v: verification: %w", i, err)
+		}
+		return nil
+	})
+}
+
+// VerifyAllPlonk is the plonk counterpart of VerifyAll.
+func VerifyAllPlonk(allProof []ClientSubmissionToServerPlonk, vk plonk.VerifyingKey, failFast bool) []error {
+	var failed atomic.Bool
+	return pool.RunAll(len(allProof), Workers, func(i int) error {
+		if allProof[i].proof == nil {
+			return nil
+		}
+		if failFast && failed.Load() {
+			return ErrVerificationSkipped
+		}
+		if err := gnarkshim.VerifyPlonk(*allProof[i].proof, vk, *allProof[i].publicWitness); err != nil {
+			failed.Store(true)
+			return fmt.Errorf("client %v: verification: %w", i, err)
+		}
+		return nil
+	})
+}
+
+// compactErrors drops the nil entries of errs.
+func compactErrors(errs []error) []error {
+	var res []error
+	for i := 0; i < len(errs); i++ {
+		if errs[i] != nil {
+			res = append(res, errs[i])
+		}
+	}
+	return res
+}
+
+// VerifyGroth16Submissions verifies the proofs attached to the submissions in
+// parallel and returns one error per client whose proof does not verify.
+func VerifyGroth16Submissions(allProof []ClientSubmissionToServer, vk groth16.VerifyingKey) []error {
+	return compactErrors(VerifyAll(allProof, vk, false))
+}
+
+// VerifyPlonkSubmissions is the plonk counterpart of VerifyGroth16Submissions.
+func VerifyPlonkSubmissions(allProof []ClientSubmissionToServerPlonk, vk plonk.VerifyingKey) []error {
+	return compactErrors(VerifyAllPlonk(allProof, vk, false))
+}
+
 func asb(asdf uint64, asd uint64) (uint64, uint64) {
 	return asdf, asd
 }
 
-func GenProofGroth16(secretVal []fr_bn254.Element, publicRFr fr_bn254.Element, mask fr_bn254.Element,
-	com fr_bn254.Element, salt fr_bn254.Element, ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey,
-	realProof bool) ClientSubmissionToServer {
-	//publicRFr := fr_bn254.NewElement(uint64(1))
-	//publicRFr := randomFr()
-	//publicR := frontend.Variable(publicRFr)
-	secretValVar := make([]frontend.Variable, len(secretVal))
-	for i := 0; i < len(secretVal); i++ {
-		secretValVar[i] = frontend.Variable(secretVal[i])
+// SumCommitment is the commitment of a client to its shares, its mask and its
+// salt, under Params.Hash.
+func SumCommitment(secretVal []fr_bn254.Element, mask fr_bn254.Element, salt fr_bn254.Element) fr_bn254.Element {
+	return commitFr(secretVal, []fr_bn254.Element{mask, salt})
+}
+
+// commitFr hashes the elements of vals, in order, with Params.Hash.
+func commitFr(vals ...[]fr_bn254.Element) fr_bn254.Element {
+	goHash, err := Params.Hash.Native(ecc.BN254)
+	if err != nil {
+		panic(err)
 	}
-	privateProdFr := PolyEval(secretVal[:], publicRFr)
-	var publicProdFr fr_bn254.Element
-	publicProdFr.Mul(&privateProdFr, &mask)
+	for _, v := range vals {
+		for j := 0; j < len(v); j++ {
+			b := v[j].Bytes()
+			goHash.Write(b[:])
+		}
+	}
+	var com fr_bn254.Element
+	com.SetBytes(goHash.Sum(nil))
+	return com
+}
 
-	// witness definition
-	assignment := sumAndCmpCircuit{
-		PrivateVec:       secretValVar[:],
-		PublicThreshold:  frontend.Variable(fr_bn254.NewElement(uint64(PublicThreshold))),
-		PrivateMask:      frontend.Variable(mask),
-		PublicR:          frontend.Variable(publicRFr),
-		PublicProd:       frontend.Variable(publicProdFr),
-		PublicCommitment: frontend.Variable(com),
-		PrivateSalt:      frontend.Variable(salt),
-	}
-
-	if realProof {
-		witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-		//fmt.Println(witness)
-		publicWitness, _ := witness.Public()
+// SumPublicProd returns the product PolyEval(secretVal, publicR) * mask a
+// client reveals to the server.
+func SumPublicProd(secretVal []fr_bn254.Element, publicR fr_bn254.Element, mask fr_bn254.Element) fr_bn254.Element {
+	prod := PolyEval(secretVal, publicR)
+	prod.Mul(&prod, &mask)
+	return prod
+}
 
-		// groth16: Prove & Verify
-		proof, _ := groth16.Prove(*ccs, *pk, witness)
+// SumAssignments builds the sumAndCmpCircuit assignments of many clients. The
+// PrivateVec of every assignment is a window of a single backing array
+// allocated up front, and the variables point to the caller's elements
+// instead of boxing a copy of each of them, so building an assignment does not
+// allocate. The elements must not change until the witness is built.
+type SumAssignments struct {
+	vars        []frontend.Variable
+	vecLength   int
+	dummyLength int
+}
 
-		return ClientSubmissionToServer{
-			publicWitness: &publicWitness,
-			publicProd:    publicProdFr,
-			proof:         &proof,
-		}
-	} else {
-		return ClientSubmissionToServer{
-			publicWitness: nil,
-			publicProd:    publicProdFr,
-			proof:         nil,
-		}
+// NewSumAssignments allocates the variables of n assignments of vecLength
+// private values and dummyLength dummies each.
+func NewSumAssignments(n int, vecLength int, dummyLength int) *SumAssignments {
+	return &SumAssignments{
+		vars:        make([]frontend.Variable, n*(vecLength+dummyLength)),
+		vecLength:   vecLength,
+		dummyLength: dummyLength,
 	}
 }
 
-func GenProofPlonk(secretVal []fr_bn254.Element, publicRFr fr_bn254.Element, mask fr_bn254.Element,
-	com fr_bn254.Element, salt fr_bn254.Element, ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey,
-	realProof bool) ClientSubmissionToServerPlonk {
-	//publicRFr := fr_bn254.NewElement(uint64(1))
-	//publicRFr := randomFr()
-	//publicR := frontend.Variable(publicRFr)
-	secretValVar := make([]frontend.Variable, len(secretVal))
-	for i := 0; i < len(secretVal); i++ {
-		secretValVar[i] = frontend.Variable(secretVal[i])
+// Assign returns the i-th assignment. Calls with distinct i may run
+// concurrently; a later call with the same i overwrites the PrivateVec and the
+// DummyVec of the earlier assignment.
+func (a *SumAssignments) Assign(i int, secretVal []fr_bn254.Element, dummies []fr_bn254.Element, publicR *fr_bn254.Element, mask *fr_bn254.Element,
+	publicProd *fr_bn254.Element, com *fr_bn254.Element, salt *fr_bn254.Element) sumAndCmpCircuit {
+	stride := a.vecLength + a.dummyLength
+	vars := a.vars[i*stride : i*stride+a.vecLength : i*stride+a.vecLength]
+	for j := 0; j < len(vars); j++ {
+		vars[j] = &secretVal[j]
 	}
-	privateProdFr := PolyEval(secretVal[:], publicRFr)
-	var publicProdFr fr_bn254.Element
-	publicProdFr.Mul(&privateProdFr, &mask)
+	dummyVars := a.vars[i*stride+a.vecLength : (i+1)*stride : (i+1)*stride]
+	for j := 0; j < len(dummyVars); j++ {
+		dummyVars[j] = &dummies[j]
+	}
+	return sumAndCmpCircuit{
+		PrivateVec:       vars,
+		PublicThreshold:  PublicThreshold,
+		PrivateMask:      mask,
+		DummyVec:         dummyVars,
+		PublicR:          publicR,
+		PublicProd:       publicProd,
+		PublicCommitment: com,
+		PrivateSalt:      salt,
+	}
+}
 
-	// witness definition
-	assignment := sumAndCmpCircuit{
-		PrivateVec:       secretValVar[:],
-		PublicThreshold:  frontend.Variable(fr_bn254.NewElement(uint64(PublicThreshold))),
-		PrivateMask:      frontend.Variable(mask),
-		PublicR:          frontend.Variable(publicRFr),
-		PublicProd:       frontend.Variable(publicProdFr),
-		PublicCommitment: frontend.Variable(com),
-		PrivateSalt:      frontend.Variable(salt),
-	}
-	if realProof {
-
-		witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-		//fmt.Println(witness)
-		publicWitness, _ := witness.Public()
+// NewSumWitness builds the full and the public witness of the assignment.
+// Unlike frontend.NewWitness, it does not walk the assignment by reflection
+// nor hand the values over one by one to another goroutine: the values are
+// queued in bulk, in the order of the witness (the public values, then the
+// secret ones, each in the order of the fields of sumAndCmpCircuit).
+func NewSumWitness(assignment *sumAndCmpCircuit) (witness.Witness, witness.Witness, error) {
+	const nbPublic = 4
+	nbSecret := len(assignment.PrivateVec) + 1 + len(assignment.DummyVec) + 1
+	values := make(chan any, nbPublic+nbSecret)
+	values <- assignment.PublicThreshold
+	values <- assignment.PublicR
+	values <- assignment.PublicProd
+	values <- assignment.PublicCommitment
+	for i := 0; i < len(assignment.PrivateVec); i++ {
+		values <- assignment.PrivateVec[i]
+	}
+	values <- assignment.PrivateMask
+	for i := 0; i < len(assignment.DummyVec); i++ {
+		values <- assignment.DummyVec[i]
+	}
+	values <- assignment.PrivateSalt
+	close(values)
 
-		// groth16: Prove & Verify
-		proof, _ := plonk.Prove(*ccs, *pk, witness)
+	witness, err := gnarkshim.NewEmptyWitness(ecc.BN254)
+	if err != nil {
+		return nil, nil, fmt.Errorf("witness generation: %w", err)
+	}
+	if err := witness.Fill(nbPublic, nbSecret, values); err != nil {
+		return nil, nil, fmt.Errorf("witness generation: %w", err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return nil, nil, fmt.Errorf("public witness extraction: %w", err)
+	}
+	return witness, publicWitness, nil
+}
 
-		return ClientSubmissionToServerPlonk{
-			publicWitness: &publicWitness,
-			publicProd:    publicProdFr,
-			proof:         &proof,
-		}
-	} else {
-		return ClientSubmissionToServerPlonk{
-			publicWitness: nil,
-			publicProd:    publicProdFr,
-			proof:         nil,
-		}
+func GenProofGroth16(witness witness.Witness, publicWitness witness.Witness, publicProdFr fr_bn254.Element,
+	ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey) (ClientSubmissionToServer, error) {
+	// groth16: Prove & Verify
+	proof, err := gnarkshim.ProveGroth16(*ccs, *pk, witness)
+	if err != nil {
+		return ClientSubmissionToServer{}, fmt.Errorf("groth16 prove: %w", err)
+	}
+
+	return ClientSubmissionToServer{
+		publicWitness: &publicWitness,
+		publicProd:    publicProdFr,
+		proof:         &proof,
+	}, nil
+}
+
+func GenProofPlonk(witness witness.Witness, publicWitness witness.Witness, publicProdFr fr_bn254.Element,
+	ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey) (ClientSubmissionToServerPlonk, error) {
+	proof, err := gnarkshim.ProvePlonk(*ccs, *pk, witness)
+	if err != nil {
+		return ClientSubmissionToServerPlonk{}, fmt.Errorf("plonk prove: %w", err)
 	}
+
+	return ClientSubmissionToServerPlonk{
+		publicWitness: &publicWitness,
+		publicProd:    publicProdFr,
+		proof:         &proof,
+	}, nil
 }
 
 /*
@@ -305,12 +453,12 @@ func SplitAndShareWithProof(secretVal uint64, publicRFr fr_bn254.Element, ccs *c
 		PublicR:         publicR,
 		PublicProd:      publicProd,
 	}
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	//fmt.Println(witness)
 	publicWitness, _ := witness.Public()
 
 	// groth16: Prove & Verify
-	proof, _ := groth16.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProveGroth16(*ccs, *pk, witness)
 
 	submissionToShuffler := ClientSubmissionToShuffler{
 		privateVec: privateVecFr,
@@ -327,7 +475,7 @@ func SplitAndShareWithProof(secretVal uint64, publicRFr fr_bn254.Element, ccs *c
 }
 */
 
-func ShuffleZKGroth16() {
+func ShuffleZKGroth16() (sumCost, error) {
 	DummyVecLength = ComputeDummyNum(80, ClientNum, CorruptedNum)
 	log.Printf("lambda %v, n %v, t %v, Dummy Num: %v\n", 80, ClientNum, CorruptedNum, DummyVecLength)
 	/*
@@ -341,33 +489,21 @@ func ShuffleZKGroth16() {
 		return
 	*/
 
-	privateVec := make([]frontend.Variable, PrivateVecLength)
-	//var dummyVec [DummyVecLength]frontend.Variable
-	for i := 0; i < len(privateVec); i++ {
-		privateVec[i] = frontend.Variable(fr_bn254.NewElement(uint64(0)))
+	circuit := newSumCircuit(Dummies)
+	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	ccs, err := gnarkshim.CompileR1CS(ecc.BN254, circuit)
+	if err != nil {
+		return sumCost{}, fmt.Errorf("r1cs circuit compile: %w", err)
 	}
-	//for i := 0; i < len(dummyVec); i++ {
-	//	dummyVec[i] = frontend.Variable(fr_bn254.NewElement(uint64(0)))
-	//	}
-	//for i := 0; i < len(array); i++ {
-	//	array[i] = frontend.Variable(fr_bn254.NewElement(uint64(i)))
-	//	}
-
-	//array := [...]frontend.Variable{1, 2, 3, 4, 5}
-	var circuit = sumAndCmpCircuit{
-		PrivateVec:       privateVec[:],
-		PublicThreshold:  0,
-		PrivateMask:      0,
-		PublicR:          0,
-		PublicProd:       0,
-		PublicCommitment: 0,
-		PrivateSalt:      0,
+	if err := gnarkshim.CheckPublic(ccs, sumPublic); err != nil {
+		return sumCost{}, err
 	}
-	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
 
 	// groth16 zkSNARK: Setup
-	pk, vk, _ := groth16.Setup(ccs)
+	pk, vk, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		return sumCost{}, fmt.Errorf("groth16 setup: %w", err)
+	}
 
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
@@ -378,30 +514,7 @@ func ShuffleZKGroth16() {
 
 	// for clients, each client has a private value
 	secretVal := make([]uint64, ClientNum)
-	splittedSecretVal := make([][]fr_bn254.Element, ClientNum)
-	secretMask := make([]fr_bn254.Element, ClientNum)
-	splittedSecretMask := make([][]fr_bn254.Element, ClientNum)
-	commitment := make([]fr_bn254.Element, ClientNum)
-	secretSalt := make([]fr_bn254.Element, ClientNum)
-
-	//var secretVal [ClientNum]uint64
-	//var splittedSecretVal [ClientNum][PrivateVecLength]fr_bn254.Element
-	//var secretMask [ClientNum]fr_bn254.Element
-	//splittedSecretMask := make([]fr_bn254.Element, ClientNum)
-	//var splittedSecretMask [ClientNum][DummyVecLength]fr_bn254.Element
-	//var commitment [ClientNum]fr_bn254.Element
-	//var secretSalt [ClientNum]fr_bn254.Element
-
-	var allSecretVal []fr_bn254.Element
-	var allMask []fr_bn254.Element
-	var allProof []ClientSubmissionToServer
-
-	//var clientVal []uint64
-
-	// set up the clients' inputs
-
 	noise := GenDistributedDPNoise(eps, 1000.0, ClientNum)
-
 	for i := 0; i < ClientNum; i++ {
 		// client i has a private value
 		secretVal[i] = uint64(1000 + noise[i])
@@ -410,66 +523,44 @@ func ShuffleZKGroth16() {
 		}
 	}
 
+	round := NewRound(ccs, pk, vk)
+	clients := make([]*RoundClient, ClientNum)
+	var toShuffler ShufflerBatch
+
 	// Step 1:
 	// Each client splits its secret vals into mulitple shares.
 	// Also, it generates the mulitple masks and compute the product of the masks.
 	// It commits to those masks vals and those masks then sends the commitments to the server.
 
 	start := time.Now()
-
 	for i := 0; i < ClientNum; i++ {
-		// split the secret value
-		splittedSecretVal[i] = make([]fr_bn254.Element, PrivateVecLength)
-		splittedSecretVal[i][0] = fr_bn254.NewElement(secretVal[i])
-		for j := 1; j < len(splittedSecretVal[i]); j++ {
-			splittedSecretVal[i][j] = randomFr()
-			splittedSecretVal[i][0].Sub(&splittedSecretVal[i][0], &splittedSecretVal[i][j])
+		clients[i] = NewClient(secretVal[i])
+		clients[i].NoProof = i >= MaxNumOfCheckProof
+		com, batch, err := clients[i].Commit()
+		if err != nil {
+			return sumCost{}, fmt.Errorf("client %v: %w", i, err)
 		}
-
-		secretMask[i] = fr_bn254.One()
-		splittedSecretMask[i] = make([]fr_bn254.Element, DummyVecLength)
-		for j := 0; j < len(splittedSecretMask[i]); j++ {
-			splittedSecretMask[i][j] = randomFr()
-			secretMask[i].Mul(&secretMask[i], &splittedSecretMask[i][j])
+		if err := round.AcceptCommitment(i, com); err != nil {
+			return sumCost{}, err
 		}
-
-		// compute the commitment
-		secretSalt[i] = randomFr()
-		goMimc := hash.MIMC_BN254.New()
-		for j := 0; j < len(splittedSecretVal[i]); j++ {
-			b := splittedSecretVal[i][j].Bytes()
-			goMimc.Write(b[:])
-		}
-		b := secretMask[i].Bytes()
-		goMimc.Write(b[:])
-		b = secretSalt[i].Bytes()
-		goMimc.Write(b[:])
-		commitment[i].SetBytes(goMimc.Sum(nil))
-		//secretSalt[i] = randomFr()
-		//log.Printf("commitment: %v\n", commitment[i])
-
 		// submit the splitted secret val and the splitted secret mask to the shuffler
-		allSecretVal = append(allSecretVal, splittedSecretVal[i][:]...)
-		allMask = append(allMask, splittedSecretMask[i][:]...)
+		toShuffler.Append(batch)
 	}
-
 	prepTime := time.Since(start)
 
-	dummyCostPerClient := DummyVecLength * BN254Size
+	dummyCostPerClient := Dummies.ShufflerCost()
 
-	//shuffle the allSecretVal and allMask
-	rand.Shuffle(len(allSecretVal), func(i, j int) {
-		allSecretVal[i], allSecretVal[j] = allSecretVal[j], allSecretVal[i]
-	})
-	rand.Shuffle(len(allMask), func(i, j int) {
-		allMask[i], allMask[j] = allMask[j], allMask[i]
-	})
-
-	// now the server can see the shuffled allSecretVal and allMask and also the commitments
+	// now the server can see the shuffled shares and masks and also the commitments
+	toShuffler.Shuffle()
 
 	// Step 2:
 	// The server generates a public challenge and broadcasts it to all the clients.
-	publicRFr := randomFr()
+	start = time.Now()
+	challenge, err := round.IssueChallenge()
+	if err != nil {
+		return sumCost{}, err
+	}
+	challengeTime := time.Since(start)
 
 	// Step 3:
 	// Each client computes the public witness and the public product and sends them to the server.
@@ -477,17 +568,26 @@ func ShuffleZKGroth16() {
 	start = time.Now()
 
 	// this counted as proving time
-	for i := 0; i < ClientNum; i++ {
-		realProof := false
-		if i < MaxNumOfCheckProof {
-			realProof = true
+	// the clients prove on Workers goroutines sharing the read-only ccs and pk
+	// only the clients attaching a real proof build a witness, the time they
+	// spend on it is summed up in witnessTime
+	allProof := make([]ClientSubmissionToServer, ClientNum)
+	masks := make([]ShufflerBatch, ClientNum)
+	err = pool.Run(ClientNum, Workers, func(i int) error {
+		var err error
+		if allProof[i], masks[i], err = clients[i].Respond(challenge); err != nil {
+			return fmt.Errorf("client %v: %w", i, err)
 		}
-		//toShuffler, toServer := SplitAndShareWithProof(uint64(secretVal), publicRFr, &ccs, &pk)
-		toServer := GenProofGroth16(splittedSecretVal[i][:], publicRFr, secretMask[i], commitment[i], secretSalt[i], &ccs, &pk, realProof)
-		//allSecretVal = append(allSecretVal, toShuffler.privateVec[:]...)
-		//allDummyVal = append(allDummyVal, toShuffler.dummyVec[:]...)
-		allProof = append(allProof, toServer)
+		return nil
+	})
+	if err != nil {
+		return sumCost{}, err
 	}
+	var witnessTime time.Duration
+	for i := 0; i < MaxNumOfCheckProof; i++ {
+		witnessTime += clients[i].WitnessTime()
+	}
+	witnessTimePerClient := witnessTime / time.Duration(MaxNumOfCheckProof)
 
 	(*(allProof[0].proof)).WriteTo(&buf)
 	// check how many bytes are written
@@ -502,47 +602,44 @@ func ShuffleZKGroth16() {
 	buf.Reset()
 
 	proofTime := time.Since(start)
-	start = time.Now()
+
+	for i := 0; i < ClientNum; i++ {
+		if err := round.AcceptSubmission(i, allProof[i]); err != nil {
+			return sumCost{}, err
+		}
+	}
+	// in the in-circuit mode, the clients now send their masks to the shuffler
+	if Dummies == DummiesInCircuit {
+		var maskBatch ShufflerBatch
+		for i := 0; i < ClientNum; i++ {
+			maskBatch.Append(masks[i])
+		}
+		maskBatch.Shuffle()
+		toShuffler.Masks = append(toShuffler.Masks, maskBatch.Masks...)
+	}
 
 	// Step 4:
 	// The server now sees all the secret values and dummy values.
 	// It first verifies all the proof
 	// It also computes the product of all the publicProd
+	// It then computes the product from shufflers
+	// the server then computes the sum of all the secret values
 
-	prodFromClients := fr_bn254.NewElement(uint64(1))
-	for i := 0; i < ClientNum; i++ {
-		if i < MaxNumOfCheckProof {
-			verification_err := groth16.Verify(*allProof[i].proof, vk, *allProof[i].publicWitness)
-			if verification_err != nil {
-				fmt.Printf("verification error in client %v", i)
-			}
-		}
-		prodFromClients.Mul(&prodFromClients, &allProof[i].publicProd)
+	res, err := round.Finalize(toShuffler)
+	if err != nil {
+		return sumCost{}, err
 	}
-
-	verifyTime := time.Since(start)
-	start = time.Now()
-
-	// It then computes the product from shufflers
-	prodFromShuffler := PolyEval(allSecretVal, publicRFr)
-	for i := 0; i < len(allMask); i++ {
-		prodFromShuffler.Mul(&prodFromShuffler, &allMask[i])
+	verifyTime, serverTime := res.VerifyTime, res.CheckTime
+	if len(res.Failing) > 0 {
+		return sumCost{}, fmt.Errorf("%v proof(s) failed verification, first: %w", len(res.Failing), res.Failing[0].Err)
 	}
-	//prodFromShuffler.Mul(&prodFromShuffler, &dummyProdFromShuffler)
-	if prodFromShuffler.Equal(&prodFromClients) {
+	if res.Consistent {
 		fmt.Printf("server: the set from clients is the same as the set from shuffler\n")
 	} else {
 		fmt.Printf("server: the set from clients is NOT the same as the set from shuffler\n")
 	}
 
-	// the server then computes the sum of all the secret values
-	sum := fr_bn254.NewElement(uint64(0))
-	for i := 0; i < len(allSecretVal); i++ {
-		sum.Add(&sum, &allSecretVal[i])
-	}
-	serverTime := time.Since(start)
-
-	fmt.Printf("The computed sum is %v\n", sum.Uint64())
+	fmt.Printf("The computed sum is %v\n", res.Sum.Uint64())
 
 	proofRelatedCommCost := uint64(proofSize) // + publicWitnessSize
 	//commCost := (float64(dummyCostPerClient) + float64(proofSize) + float64(publicWitnessSize) + float64(CommitmentSize) + float64(BN254Size)) / 1024
@@ -560,13 +657,19 @@ func ShuffleZKGroth16() {
 	log.Printf("Commitment Size %v\n", CommitmentSize)
 	log.Printf("Challenge Size %v\n", BN254Size)
 	log.Printf("Dummy Size %v\n", dummyCostPerClient)
+	log.Printf("Dummy Mode %v\n", Dummies)
+	log.Printf("Constraints %v\n", ccs.GetNbConstraints())
 	log.Printf("============================\n")
+	if err := logDummyModes(gnarkshim.CompileR1CS); err != nil {
+		return sumCost{}, err
+	}
 
 	// now we compute the computation cost
 	//23 parts : prep, proof
 	clientTime := prepTime/time.Duration(ClientNum) + proofTime/time.Duration(MaxNumOfCheckProof)
 	log.Printf("=====Client Computation Cost=====\n")
 	log.Printf("Preparation: %v\n", prepTime/time.Duration(ClientNum))
+	log.Printf("Witness Construction (included in Proof): %v\n", witnessTimePerClient)
 	log.Printf("Proof: %v\n", proofTime/time.Duration(MaxNumOfCheckProof))
 	log.Printf("Total: %v\n", clientTime)
 	log.Printf("============================\n")
@@ -574,7 +677,9 @@ func ShuffleZKGroth16() {
 	// now we compute the server time amortized per client
 	serverTotalTime := serverTime/time.Duration(ClientNum) + verifyTime/time.Duration(MaxNumOfCheckProof)
 	log.Printf("=====Server Computation Cost=====\n")
+	log.Printf("Challenge (random %v, round %v): %v\n", RandomChallenge, RoundID, challengeTime)
 	log.Printf("Other: %v\n", serverTime/time.Duration(ClientNum))
+	log.Printf("Verify (wall-clock for %v proofs, %v workers): %v\n", MaxNumOfCheckProof, Workers, verifyTime)
 	log.Printf("Verify: %v\n", verifyTime/time.Duration(MaxNumOfCheckProof))
 	log.Printf("Total: %v\n", serverTotalTime)
 	log.Printf("============================\n")
@@ -591,10 +696,11 @@ func ShuffleZKGroth16() {
 	log.Printf("To Server %v\n", proofSize+publicWitnessSize+CommitmentSize+BN254Size) // a commitment, a public prod, a proof, a public witness
 	log.Printf("Proof Size %v\n", proofSize)
 
-	file.WriteString(fmt.Sprintf("Shuffle-DP Sum Groth16, %v, %v, %v, %v\n", ClientNum-CorruptedNum, clientTime, serverTotalTime, commCost))
+	file.WriteString(fmt.Sprintf("Shuffle-DP Sum Groth16%v, %v, %v, %v, %v, %v\n", dummyModeSuffix(), ClientNum-CorruptedNum, clientTime, serverTotalTime, commCost, witnessTimePerClient))
+	return sumCost{clientTime: clientTime, serverTime: serverTotalTime, commCost: commCost}, nil
 }
 
-func ShuffleZKPlonk() {
+func ShuffleZKPlonk() error {
 	DummyVecLength = ComputeDummyNum(80, ClientNum, CorruptedNum)
 	log.Printf("lambda %v, n %v, t %v, Dummy Num: %v\n", 80, ClientNum, CorruptedNum, DummyVecLength)
 	/*
@@ -608,43 +714,28 @@ func ShuffleZKPlonk() {
 		return
 	*/
 
-	privateVec := make([]frontend.Variable, PrivateVecLength)
-	//var dummyVec [DummyVecLength]frontend.Variable
-	for i := 0; i < len(privateVec); i++ {
-		privateVec[i] = frontend.Variable(fr_bn254.NewElement(uint64(0)))
-	}
-	//for i := 0; i < len(dummyVec); i++ {
-	//	dummyVec[i] = frontend.Variable(fr_bn254.NewElement(uint64(0)))
-	//	}
-	//for i := 0; i < len(array); i++ {
-	//	array[i] = frontend.Variable(fr_bn254.NewElement(uint64(i)))
-	//	}
-
-	//array := [...]frontend.Variable{1, 2, 3, 4, 5}
-	var circuit = sumAndCmpCircuit{
-		PrivateVec:       privateVec[:],
-		PublicThreshold:  0,
-		PrivateMask:      0,
-		PublicR:          0,
-		PublicProd:       0,
-		PublicCommitment: 0,
-		PrivateSalt:      0,
-	}
+	circuit := newSumCircuit(Dummies)
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	ccs, err := gnarkshim.CompileSCS(ecc.BN254, circuit)
 	if err != nil {
-		log.Println("scs circuit compile error")
+		return fmt.Errorf("scs circuit compile: %w", err)
+	}
+	if err := gnarkshim.CheckPublic(ccs, sumPublic); err != nil {
+		return err
 	}
 
 	//setup kzg
 	_r1cs := ccs.(*cs.SparseR1CS)
-	srs, err := test.NewKZGSRS(_r1cs)
+	srs, err := gnarkshim.NewKZGSRS(_r1cs)
 	if err != nil {
-		log.Println("kzg srs error")
+		return fmt.Errorf("kzg srs: %w", err)
 	}
 
 	// plonk zkSNARK: Setup
-	pk, vk, _ := plonk.Setup(ccs, srs)
+	pk, vk, err := gnarkshim.SetupPlonk(ccs, srs)
+	if err != nil {
+		return fmt.Errorf("plonk setup: %w", err)
+	}
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -696,9 +787,16 @@ func ShuffleZKPlonk() {
 
 	start := time.Now()
 
+	// the shares and the dummies of all clients are carved out of two backing
+	// arrays, and the shuffler input is sized up front
+	shares := make([]fr_bn254.Element, ClientNum*PrivateVecLength)
+	dummies := make([]fr_bn254.Element, uint64(ClientNum)*DummyVecLength)
+	allSecretVal = make([]fr_bn254.Element, 0, len(shares))
+	allMask = make([]fr_bn254.Element, 0, len(dummies))
+
 	for i := 0; i < ClientNum; i++ {
 		// split the secret value
-		splittedSecretVal[i] = make([]fr_bn254.Element, PrivateVecLength)
+		splittedSecretVal[i] = shares[i*PrivateVecLength : (i+1)*PrivateVecLength : (i+1)*PrivateVecLength]
 		splittedSecretVal[i][0] = fr_bn254.NewElement(secretVal[i])
 		for j := 1; j < len(splittedSecretVal[i]); j++ {
 			splittedSecretVal[i][j] = randomFr()
@@ -706,7 +804,7 @@ func ShuffleZKPlonk() {
 		}
 
 		secretMask[i] = fr_bn254.One()
-		splittedSecretMask[i] = make([]fr_bn254.Element, DummyVecLength)
+		splittedSecretMask[i] = dummies[uint64(i)*DummyVecLength : uint64(i+1)*DummyVecLength : uint64(i+1)*DummyVecLength]
 		for j := 0; j < len(splittedSecretMask[i]); j++ {
 			splittedSecretMask[i][j] = randomFr()
 			secretMask[i].Mul(&secretMask[i], &splittedSecretMask[i][j])
@@ -714,27 +812,24 @@ func ShuffleZKPlonk() {
 
 		// compute the commitment
 		secretSalt[i] = randomFr()
-		goMimc := hash.MIMC_BN254.New()
-		for j := 0; j < len(splittedSecretVal[i]); j++ {
-			b := splittedSecretVal[i][j].Bytes()
-			goMimc.Write(b[:])
-		}
-		b := secretMask[i].Bytes()
-		goMimc.Write(b[:])
-		b = secretSalt[i].Bytes()
-		goMimc.Write(b[:])
-		commitment[i].SetBytes(goMimc.Sum(nil))
+		if Dummies == DummiesInCircuit {
+			commitment[i] = SumDummyCommitment(splittedSecretVal[i], splittedSecretMask[i], secretSalt[i])
+		} else {
+			commitment[i] = SumCommitment(splittedSecretVal[i], secretMask[i], secretSalt[i])
+		}
 		//secretSalt[i] = randomFr()
 		//log.Printf("commitment: %v\n", commitment[i])
 
 		// submit the splitted secret val and the splitted secret mask to the shuffler
 		allSecretVal = append(allSecretVal, splittedSecretVal[i][:]...)
-		allMask = append(allMask, splittedSecretMask[i][:]...)
+		if Dummies == DummiesShuffled {
+			allMask = append(allMask, splittedSecretMask[i][:]...)
+		}
 	}
 
 	prepTime := time.Since(start)
 
-	dummyCostPerClient := DummyVecLength * BN254Size
+	dummyCostPerClient := Dummies.ShufflerCost()
 
 	//shuffle the allSecretVal and allMask
 	rand.Shuffle(len(allSecretVal), func(i, j int) {
@@ -747,8 +842,21 @@ func ShuffleZKPlonk() {
 	// now the server can see the shuffled allSecretVal and allMask and also the commitments
 
 	// Step 2:
-	// The server generates a public challenge and broadcasts it to all the clients.
-	publicRFr := randomFr()
+	// The server derives the public challenge from the commitments and broadcasts it to all the clients.
+	start = time.Now()
+	publicRFr := serverChallenge(challengeDomain, commitment)
+	challengeTime := time.Since(start)
+
+	// in the in-circuit mode, the clients now send their masks to the shuffler
+	if Dummies == DummiesInCircuit {
+		for i := 0; i < ClientNum; i++ {
+			secretMask[i] = PolyEval(splittedSecretMask[i], publicRFr)
+			allMask = append(allMask, secretMask[i])
+		}
+		rand.Shuffle(len(allMask), func(i, j int) {
+			allMask[i], allMask[j] = allMask[j], allMask[i]
+		})
+	}
 
 	// Step 3:
 	// Each client computes the public witness and the public product and sends them to the server.
@@ -756,17 +864,36 @@ func ShuffleZKPlonk() {
 	start = time.Now()
 
 	// this counted as proving time
-	for i := 0; i < ClientNum; i++ {
-		realProof := false
-		if i < MaxNumOfCheckProof {
-			realProof = true
+	// the clients prove on Workers goroutines sharing the read-only ccs and pk
+	// only the clients attaching a real proof build a witness, the time they
+	// spend on it is summed up in witnessTime
+	allProof = make([]ClientSubmissionToServerPlonk, ClientNum)
+	assignments := NewSumAssignments(MaxNumOfCheckProof, PrivateVecLength, int(DummyVecLength))
+	var witnessTime atomic.Int64
+	err = pool.Run(ClientNum, Workers, func(i int) error {
+		publicProd := SumPublicProd(splittedSecretVal[i], publicRFr, secretMask[i])
+		if i >= MaxNumOfCheckProof {
+			allProof[i] = ClientSubmissionToServerPlonk{publicProd: publicProd}
+			return nil
+		}
+		witnessStart := time.Now()
+		witness, publicWitness, err := newClientWitness(assignments, i, splittedSecretVal[i], splittedSecretMask[i],
+			&publicRFr, &secretMask[i], &publicProd, &commitment[i], &secretSalt[i])
+		witnessTime.Add(int64(time.Since(witnessStart)))
+		if err != nil {
+			return fmt.Errorf("client %v: proof generation: %w", i, err)
+		}
+		toServer, err := GenProofPlonk(witness, publicWitness, publicProd, &ccs, &pk)
+		if err != nil {
+			return fmt.Errorf("client %v: proof generation: %w", i, err)
 		}
-		//toShuffler, toServer := SplitAndShareWithProof(uint64(secretVal), publicRFr, &ccs, &pk)
-		toServer := GenProofPlonk(splittedSecretVal[i][:], publicRFr, secretMask[i], commitment[i], secretSalt[i], &ccs, &pk, realProof)
-		//allSecretVal = append(allSecretVal, toShuffler.privateVec[:]...)
-		//allDummyVal = append(allDummyVal, toShuffler.dummyVec[:]...)
-		allProof = append(allProof, toServer)
+		allProof[i] = toServer
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	witnessTimePerClient := time.Duration(witnessTime.Load()) / time.Duration(MaxNumOfCheckProof)
 
 	(*(allProof[0].proof)).WriteTo(&buf)
 	// check how many bytes are written
@@ -788,24 +915,20 @@ func ShuffleZKPlonk() {
 	// It first verifies all the proof
 	// It also computes the product of all the publicProd
 
+	verifyErrs := VerifyPlonkSubmissions(allProof, vk)
 	prodFromClients := fr_bn254.NewElement(uint64(1))
 	for i := 0; i < ClientNum; i++ {
-		//verify proof
-		//fmt.Printf("proof: %v
-		if i < MaxNumOfCheckProof {
-			verification_err := plonk.Verify(*allProof[i].proof, vk, *allProof[i].publicWitness)
-			if verification_err != nil {
-				fmt.Printf("verification error in client %v", i)
-			}
-		}
 		prodFromClients.Mul(&prodFromClients, &allProof[i].publicProd)
 	}
 
 	verifying_time_only_proof := time.Since(start)
+	if len(verifyErrs) > 0 {
+		return fmt.Errorf("%v proof(s) failed verification, first: %w", len(verifyErrs), verifyErrs[0])
+	}
 	start = time.Now()
 
 	// It then computes the product from shufflers
-	prodFromShuffler := PolyEval(allSecretVal, publicRFr)
+	prodFromShuffler := polyeval.Slice(allSecretVal, publicRFr, polyeval.DefaultChunk, Workers)
 	for i := 0; i < len(allMask); i++ {
 		prodFromShuffler.Mul(&prodFromShuffler, &allMask[i])
 	}
@@ -827,9 +950,17 @@ func ShuffleZKPlonk() {
 	fmt.Printf("The computed sum is %v\n", sum.Uint64())
 
 	log.Printf("Task: DP-Shuffle-Sum; Proof System: Plonk")
+	log.Printf("Dummy mode: %v, constraints: %v, dummies to the shuffler: %v bytes\n", Dummies, ccs.GetNbConstraints(), dummyCostPerClient)
+	if err := logDummyModes(gnarkshim.CompileSCS); err != nil {
+		return err
+	}
 
+	log.Printf("challenge time (random %v, round %v): %v\n", RandomChallenge, RoundID, challengeTime)
 	log.Printf("proving time: %v\n", proving_time)
 	log.Printf("Per client proving time: %v\n", proving_time/time.Duration(MaxNumOfCheckProof))
+	log.Printf("Per client witness construction time (included in proving): %v\n", witnessTimePerClient)
+	log.Printf("proof verifying time (wall-clock for %v proofs, %v workers): %v\n", MaxNumOfCheckProof, Workers, verifying_time_only_proof)
+	log.Printf("Per proof verifying time: %v\n", verifying_time_only_proof/time.Duration(MaxNumOfCheckProof))
 	log.Printf("verifying time (only verifying %v proofs): %v\n", MaxNumOfCheckProof, verifying_time)
 	log.Printf("Per client verifying time: %v\n", verifying_time_only_proof/time.Duration(MaxNumOfCheckProof)+verifying_time/time.Duration(ClientNum))
 
@@ -844,7 +975,8 @@ func ShuffleZKPlonk() {
 	commCost := (float64(dummyCostPerClient) + float64(proofSize) + float64(publicWitnessSize) + float64(CommitmentSize) + float64(BN254Size)) / 1024
 	//commCost := dummyCostPerClient + proofSize+publicWitnessSize+CommitmentSize+BN254Size
 
-	file.WriteString(fmt.Sprintf("Shuffle-DP Sum Plonk, %v, %v, %v, %v\n", ClientNum-CorruptedNum, clientTime, amtServerTime, commCost))
+	file.WriteString(fmt.Sprintf("Shuffle-DP Sum Plonk%v, %v, %v, %v, %v, %v\n", dummyModeSuffix(), ClientNum-CorruptedNum, clientTime, amtServerTime, commCost, witnessTimePerClient))
+	return nil
 
 	/*
 		// just create a private Vec
@@ -910,10 +1042,10 @@ func ShuffleZKPlonk() {
 		publicWitness, _ := witness.Public()
 
 		// groth16: Prove & Verify
-		proof, proof_err := groth16.Prove(ccs, pk, witness)
+		proof, proof_err := gnarkshim.ProveGroth16(ccs, pk, witness)
 		fmt.Printf("proof error: %v\n", proof_err)
 
-		verification_err := groth16.Verify(proof, vk, publicWitness)
+		verification_err := gnarkshim.VerifyGroth16(proof, vk, publicWitness)
 
 		fmt.Printf("verification error: %v\n", verification_err)
 	*/
@@ -986,7 +1118,24 @@ func GenDistributedDPNoise(eps float64, sensitivity float64, n int) []int {
 }
 
 func main() {
+	dummyMode := flag.String("dummy-mode", Dummies.String(), "how the dummies reach the product check, shuffled or incircuit")
+	flag.BoolVar(&RandomChallenge, "random-challenge", RandomChallenge, "sample the challenges at random instead of deriving them from the commitments, for benchmark comparisons")
+	flag.Uint64Var(&RoundID, "round-id", RoundID, "identifier of the round the challenges are derived for")
+	attributes := flag.Int("attributes", 1, "number of attributes per client; above 1, also runs the multi-attribute sum and compares it with as many single-attribute runs")
+	flag.Parse()
 	var err error
+	if Dummies, err = ParseDummyMode(*dummyMode); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if *attributes < 1 {
+		fmt.Fprintf(os.Stderr, "invalid configuration: -attributes must be positive, got %v\n", *attributes)
+		os.Exit(2)
+	}
+	if *attributes > 1 && Dummies != DummiesShuffled {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", ErrMultiSumDummies)
+		os.Exit(2)
+	}
 	file, err = os.OpenFile("output-shuffle-dp-sum.csv", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
 		panic(err)
@@ -994,10 +1143,20 @@ func main() {
 
 	defer file.Close()
 
-	file.WriteString("Name, Honest Client Num, Client Time, Server Time, Communication Cost\n")
+	file.WriteString("Name, Honest Client Num, Client Time, Server Time, Communication Cost, Witness Time\n")
 
 	for t := 0; t < TestRepeat; t++ {
-		ShuffleZKGroth16()
+		single, err := ShuffleZKGroth16()
+		if err != nil {
+			log.Fatalf("Shuffle-DP Sum Groth16: %v", err)
+		}
+		if *attributes > 1 {
+			multi, err := MultiSumGroth16(*attributes)
+			if err != nil {
+				log.Fatalf("Shuffle-DP Multi-Sum Groth16: %v", err)
+			}
+			logMultiSumComparison(*attributes, single, multi)
+		}
 	}
 
 	//for t := 0; t < TestRepeat; t++ {
@@ -1,27 +1,205 @@
 package main
 
 import (
+	"bytes"
 	"testing"
 
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/hasher"
 )
 
+// sumDummyNum is the number of dummies of the clients of the tests.
+const sumDummyNum = 3
+
+// sumClient is the private state of one client of the sum protocol.
+type sumClient struct {
+	shares     []fr_bn254.Element
+	dummies    []fr_bn254.Element
+	mask       fr_bn254.Element
+	salt       fr_bn254.Element
+	com        fr_bn254.Element
+	publicProd fr_bn254.Element
+}
+
+// newSumClient splits val into vecLength shares and commits to them.
+func newSumClient(val uint64, vecLength int, publicR fr_bn254.Element) sumClient {
+	var c sumClient
+	c.shares = make([]fr_bn254.Element, vecLength)
+	c.shares[0] = fr_bn254.NewElement(val)
+	for j := 1; j < vecLength; j++ {
+		c.shares[j] = randomFr()
+		c.shares[0].Sub(&c.shares[0], &c.shares[j])
+	}
+	c.dummies = make([]fr_bn254.Element, sumDummyNum)
+	c.mask = fr_bn254.One()
+	for j := 0; j < len(c.dummies); j++ {
+		c.dummies[j] = randomFr()
+		c.mask.Mul(&c.mask, &c.dummies[j])
+	}
+	c.salt = randomFr()
+	c.com = SumCommitment(c.shares, c.mask, c.salt)
+	c.publicProd = SumPublicProd(c.shares, publicR, c.mask)
+	return c
+}
+
+func (c *sumClient) assign(assignments *SumAssignments, i int, publicR *fr_bn254.Element) sumAndCmpCircuit {
+	return assignments.Assign(i, c.shares, c.dummies, publicR, &c.mask, &c.publicProd, &c.com, &c.salt)
+}
+
 func TestSumAndCmpCircuit(t *testing.T) {
 	assert := test.NewAssert(t)
 
 	var definingCircuit = sumAndCmpCircuit{
-		PrivateVec:      []frontend.Variable{0, 0, 0, 0, 0},
-		PublicThreshold: frontend.Variable(0),
+		PrivateVec: make([]frontend.Variable, 5),
+		DummyVec:   make([]frontend.Variable, sumDummyNum),
 	}
 
-	assert.ProverFailed(&definingCircuit, &sumAndCmpCircuit{
-		PrivateVec:      []frontend.Variable{1, 2, 3, 4, 5},
-		PublicThreshold: frontend.Variable(10),
-	})
+	publicR := randomFr()
+	assignments := NewSumAssignments(2, 5, sumDummyNum)
+	over := newSumClient(PublicThreshold+1, 5, publicR)
+	under := newSumClient(PublicThreshold, 5, publicR)
+
+	overAssignment := over.assign(assignments, 0, &publicR)
+	assert.ProverFailed(&definingCircuit, &overAssignment, test.WithCurves(ecc.BN254))
+
+	underAssignment := under.assign(assignments, 1, &publicR)
+	assert.ProverSucceeded(&definingCircuit, &underAssignment, test.WithCurves(ecc.BN254))
+}
+
+func TestSumAndCmpCircuitMask(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var definingCircuit = sumAndCmpCircuit{
+		PrivateVec: make([]frontend.Variable, 5),
+		DummyVec:   make([]frontend.Variable, sumDummyNum),
+	}
+	publicR := randomFr()
+	assignments := NewSumAssignments(2, 5, sumDummyNum)
+
+	// the commitment and the product are consistent with the mask, but the
+	// mask is not the product of the dummies
+	forged := newSumClient(PublicThreshold, 5, publicR)
+	forged.mask.Double(&forged.mask)
+	forged.com = SumCommitment(forged.shares, forged.mask, forged.salt)
+	forged.publicProd = SumPublicProd(forged.shares, publicR, forged.mask)
+	forgedAssignment := forged.assign(assignments, 0, &publicR)
+	assert.ProverFailed(&definingCircuit, &forgedAssignment, test.WithCurves(ecc.BN254))
+
+	honest := newSumClient(PublicThreshold, 5, publicR)
+	honestAssignment := honest.assign(assignments, 1, &publicR)
+	assert.ProverSucceeded(&definingCircuit, &honestAssignment, test.WithCurves(ecc.BN254))
+}
+
+func TestSumAndCmpCircuitHash(t *testing.T) {
+	defer func(p ProtocolParams) { Params = p }(Params)
+	Params = ProtocolParams{Hash: hasher.Stub}
 
-	assert.ProverSucceeded(&definingCircuit, &sumAndCmpCircuit{
-		PrivateVec:      []frontend.Variable{1, 2, 3, 4, 5},
-		PublicThreshold: frontend.Variable(15),
+	publicR := randomFr()
+	client := newSumClient(PublicThreshold, 5, publicR)
+	assignment := client.assign(NewSumAssignments(1, 5, sumDummyNum), 0, &publicR)
+
+	withStub := sumAndCmpCircuit{PrivateVec: make([]frontend.Variable, 5), DummyVec: make([]frontend.Variable, sumDummyNum), Hash: hasher.Stub}
+	if err := test.IsSolved(&withStub, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+	withMiMC := sumAndCmpCircuit{PrivateVec: make([]frontend.Variable, 5), DummyVec: make([]frontend.Variable, sumDummyNum), Hash: hasher.MiMC}
+	if err := test.IsSolved(&withMiMC, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("a stub commitment solved the MiMC circuit")
+	}
+}
+
+func TestNewSumWitnessMatchesNewWitness(t *testing.T) {
+	publicR := randomFr()
+	client := newSumClient(1000, PrivateVecLength, publicR)
+	assignment := client.assign(NewSumAssignments(1, PrivateVecLength, sumDummyNum), 0, &publicR)
+
+	expected, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witness, publicWitness, err := NewSumWitness(&assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBytes, _ := expected.MarshalBinary()
+	witnessBytes, _ := witness.MarshalBinary()
+	if !bytes.Equal(expectedBytes, witnessBytes) {
+		t.Fatal("the witness differs from frontend.NewWitness")
+	}
+
+	expectedPublic, err := expected.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBytes, _ = expectedPublic.MarshalBinary()
+	witnessBytes, _ = publicWitness.MarshalBinary()
+	if !bytes.Equal(expectedBytes, witnessBytes) {
+		t.Fatal("the public witness differs from frontend.NewWitness")
+	}
+}
+
+// naiveSumAssignment boxes a copy of every value, as the assignments used to
+// be built before SumAssignments.
+func naiveSumAssignment(c *sumClient, publicR fr_bn254.Element) sumAndCmpCircuit {
+	vars := make([]frontend.Variable, len(c.shares))
+	for j := 0; j < len(c.shares); j++ {
+		vars[j] = frontend.Variable(c.shares[j])
+	}
+	dummyVars := make([]frontend.Variable, len(c.dummies))
+	for j := 0; j < len(c.dummies); j++ {
+		dummyVars[j] = frontend.Variable(c.dummies[j])
+	}
+	return sumAndCmpCircuit{
+		PrivateVec:       vars,
+		PublicThreshold:  frontend.Variable(fr_bn254.NewElement(uint64(PublicThreshold))),
+		PrivateMask:      frontend.Variable(c.mask),
+		DummyVec:         dummyVars,
+		PublicR:          frontend.Variable(publicR),
+		PublicProd:       frontend.Variable(c.publicProd),
+		PublicCommitment: frontend.Variable(c.com),
+		PrivateSalt:      frontend.Variable(c.salt),
+	}
+}
+
+// BenchmarkSumWitness builds the witnesses of 10^4 clients of the sum circuit.
+func BenchmarkSumWitness(b *testing.B) {
+	const n = 10000
+	publicR := randomFr()
+	clients := make([]sumClient, n)
+	for i := 0; i < n; i++ {
+		clients[i] = newSumClient(1000, PrivateVecLength, publicR)
+	}
+
+	b.Run("naive", func(b *testing.B) {
+		b.ReportAllocs()
+		for k := 0; k < b.N; k++ {
+			for i := 0; i < n; i++ {
+				assignment := naiveSumAssignment(&clients[i], publicR)
+				witness, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := witness.Public(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("vectorized", func(b *testing.B) {
+		b.ReportAllocs()
+		assignments := NewSumAssignments(n, PrivateVecLength, sumDummyNum)
+		for k := 0; k < b.N; k++ {
+			for i := 0; i < n; i++ {
+				assignment := clients[i].assign(assignments, i, &publicR)
+				if _, _, err := NewSumWitness(&assignment); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
 	})
 }
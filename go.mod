@@ -1,11 +1,14 @@
 module example/verification
 
-go 1.19
+go 1.22
 
 require (
 	github.com/consensys/gnark v0.9.1
 	github.com/consensys/gnark-crypto v0.12.2-0.20231013160410-1f65e75b6dfb
 //github.com/consensys/gnark-crypto v0.9.1-0.20230203170247-e77b0919d1aa
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.12.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -24,9 +27,7 @@ require (
 	github.com/rs/zerolog v1.30.0 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
 	github.com/x448/float16 v0.8.4 // indirect
-	golang.org/x/crypto v0.12.0 // indirect
 	golang.org/x/sync v0.3.0 // indirect
 	golang.org/x/sys v0.11.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	rsc.io/tmplfunc v0.0.3 // indirect
 )
@@ -17,12 +17,11 @@ import (
 	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/frontend/cs/scs"
 	"github.com/consensys/gnark/std/hash/mimc"
-	"github.com/consensys/gnark/test"
 
 	cs "github.com/consensys/gnark/constraint/bn254"
+
+	"example/verification/internal/gnarkshim"
 )
 
 const (
@@ -79,6 +78,10 @@ type HistogramCircuit struct {
 	PrivateSalt      frontend.Variable
 }
 
+// histogramPublic are the public variables of HistogramCircuit, in the order
+// of its public witness. The setup is not run for a circuit that differs.
+var histogramPublic = []string{"PublicR", "PublicProd", "PublicCommitment"}
+
 func (circuit *HistogramCircuit) Define(api frontend.API) error {
 
 	// just v
@@ -220,24 +223,24 @@ func (c *ClientState) GenAssignment(publicR fr_bn254.Element) HistogramCircuit {
 
 func GenProofGroth16(assignment HistogramCircuit, ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey) (*groth16.Proof, *witness.Witness) {
 	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	//fmt.Println(witness)
 	publicWitness, _ := witness.Public()
 
 	// groth16: Prove & Verify
-	proof, _ := groth16.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProveGroth16(*ccs, *pk, witness)
 
 	return &proof, &publicWitness
 }
 
 func GenProofPlonk(assignment HistogramCircuit, ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey) (*plonk.Proof, *witness.Witness) {
 	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	//fmt.Println(witness)
 	publicWitness, _ := witness.Public()
 
 	// plonk: Prove & Verify
-	proof, _ := plonk.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProvePlonk(*ccs, *pk, witness)
 
 	return &proof, &publicWitness
 }
@@ -256,10 +259,13 @@ func HistogramGroth16() {
 		PrivateSalt:      0,
 	}
 
-	ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	ccs, _ := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err := gnarkshim.CheckPublic(ccs, histogramPublic); err != nil {
+		log.Fatal(err)
+	}
 
 	// groth16 zkSNARK: Setup
-	pk, vk, _ := groth16.Setup(ccs)
+	pk, vk, _ := gnarkshim.SetupGroth16(ccs)
 
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
@@ -366,7 +372,7 @@ func HistogramGroth16() {
 	start = time.Now()
 	for i := 0; i < len(allSubmission); i++ {
 		if i < MaxNumOfCheckProof {
-			verification_err := groth16.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
+			verification_err := gnarkshim.VerifyGroth16(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
@@ -496,20 +502,23 @@ func HistogramPlonk() {
 	}
 
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	ccs, err := gnarkshim.CompileSCS(ecc.BN254, &circuit)
 	if err != nil {
 		log.Println("scs circuit compile error")
 	}
+	if err := gnarkshim.CheckPublic(ccs, histogramPublic); err != nil {
+		log.Fatal(err)
+	}
 
 	//setup kzg
 	_r1cs := ccs.(*cs.SparseR1CS)
-	srs, err := test.NewKZGSRS(_r1cs)
+	srs, err := gnarkshim.NewKZGSRS(_r1cs)
 	if err != nil {
 		log.Println("kzg srs error")
 	}
 
 	// plonk Setup
-	pk, vk, _ := plonk.Setup(ccs, srs)
+	pk, vk, _ := gnarkshim.SetupPlonk(ccs, srs)
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -615,7 +624,7 @@ func HistogramPlonk() {
 	start = time.Now()
 	for i := 0; i < len(allSubmission); i++ {
 		if i < MaxNumOfCheckProof {
-			verification_err := plonk.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
+			verification_err := gnarkshim.VerifyPlonk(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
@@ -0,0 +1,198 @@
+// Package apicheck lists the exported identifiers of the library packages of
+// the module, so that a test can compare them with the api.txt checked in next
+// to every package.
+//
+// A change of the list fails the test until api.txt is regenerated with
+//
+//	go run ./internal/apicheck/apigen
+//
+// which makes every addition or removal of an export show up in the diff of
+// the commit that does it.
+//
+// The identifiers are read from the syntax of the non-test files, with
+// go/parser: go/packages is not a dependency of the module and the syntax is
+// all a list of exports needs.
+package apicheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileName is the name of the list of exports in a package directory.
+const FileName = "api.txt"
+
+// Exports returns the sorted exports of the package in dir, one per line:
+// "const X", "var X", "type T", "func F", "method T.M" and "field T.F" for the
+// exported fields of the exported structs. It returns "", false if dir holds a
+// main package or no package at all.
+func Exports(dir string) (string, bool, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return "", false, err
+	}
+	if len(pkgs) > 1 {
+		return "", false, fmt.Errorf("%v: more than one package", dir)
+	}
+
+	var lines []string
+	for name, pkg := range pkgs {
+		if name == "main" {
+			return "", false, nil
+		}
+		for _, file := range pkg.Files {
+			lines = append(lines, fileExports(file)...)
+		}
+	}
+	if lines == nil {
+		return "", len(pkgs) == 1, nil
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n") + "\n", true, nil
+}
+
+func fileExports(file *ast.File) []string {
+	var lines []string
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+			if decl.Recv == nil {
+				lines = append(lines, "func "+decl.Name.Name)
+				continue
+			}
+			if recv := receiverName(decl.Recv.List[0].Type); ast.IsExported(recv) {
+				lines = append(lines, "method "+recv+"."+decl.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.IsExported() {
+							lines = append(lines, decl.Tok.String()+" "+name.Name)
+						}
+					}
+				case *ast.TypeSpec:
+					if !spec.Name.IsExported() {
+						continue
+					}
+					lines = append(lines, "type "+spec.Name.Name)
+					if st, ok := spec.Type.(*ast.StructType); ok {
+						for _, field := range st.Fields.List {
+							for _, name := range field.Names {
+								if name.IsExported() {
+									lines = append(lines, "field "+spec.Name.Name+"."+name.Name)
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// receiverName returns the name of the type of a method receiver, T for
+// both T and *T.
+func receiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(expr.X)
+	case *ast.IndexExpr:
+		return receiverName(expr.X)
+	case *ast.IndexListExpr:
+		return receiverName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// Packages returns the directories under root holding a library package,
+// skipping the hidden directories and testdata.
+func Packages(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "testdata") {
+			return filepath.SkipDir
+		}
+		if _, ok, err := Exports(path); err != nil {
+			return err
+		} else if ok {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// Check compares the exports of the package in dir with its api.txt.
+func Check(dir string) error {
+	got, _, err := Exports(dir)
+	if err != nil {
+		return err
+	}
+	want, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return err
+	}
+	if got != string(want) {
+		return fmt.Errorf("%v: the exports changed:\n%v", dir, diff(string(want), got))
+	}
+	return nil
+}
+
+// diff lists the lines removed from want with a "-" and the lines added by
+// got with a "+".
+func diff(want, got string) string {
+	in := func(s string) map[string]bool {
+		set := map[string]bool{}
+		for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+			set[line] = true
+		}
+		return set
+	}
+	wantSet, gotSet := in(want), in(got)
+	var lines []string
+	for line := range wantSet {
+		if !gotSet[line] {
+			lines = append(lines, "-"+line)
+		}
+	}
+	for line := range gotSet {
+		if !wantSet[line] {
+			lines = append(lines, "+"+line)
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][1:] < lines[j][1:] })
+	return strings.Join(lines, "\n")
+}
+
+// Write regenerates the api.txt of the package in dir.
+func Write(dir string) error {
+	got, _, err := Exports(dir)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, FileName), []byte(got), 0644)
+}
@@ -0,0 +1,76 @@
+package apicheck
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// root is the root of the module, from the directory of the test.
+const root = "../.."
+
+func TestAPI(t *testing.T) {
+	dirs, err := Packages(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no library package found")
+	}
+	for _, dir := range dirs {
+		if err := Check(dir); err != nil {
+			t.Errorf("%v\nrun go run ./internal/apicheck/apigen if the change is deliberate", err)
+		}
+	}
+}
+
+func TestExports(t *testing.T) {
+	dir := t.TempDir()
+	src := `package p
+
+const A, b = 1, 2
+
+var V int
+
+type T struct {
+	Exported   int
+	unexported int
+}
+
+type u struct{ F int }
+
+func (T) M()  {}
+func (*T) N() {}
+func (u) O()  {}
+func F()      {}
+func g()      {}
+`
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "p_test.go"), []byte("package p\n\nfunc TestX() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := Exports(dir)
+	if err != nil || !ok {
+		t.Fatalf("Exports: %v, %v", ok, err)
+	}
+	want := strings.Join([]string{"const A", "field T.Exported", "func F", "method T.M", "method T.N", "type T", "var V"}, "\n") + "\n"
+	if got != want {
+		t.Fatalf("exports:\n%v\nexpected:\n%v", got, want)
+	}
+
+	if err := Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "q.go"), []byte("package p\n\nfunc G() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(dir); err == nil || !strings.Contains(err.Error(), "+func G") {
+		t.Fatalf("an added export was not reported: %v", err)
+	}
+}
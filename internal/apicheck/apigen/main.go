@@ -0,0 +1,30 @@
+// Command apigen regenerates the api.txt of every library package of the
+// module, see package apicheck. Run it from the root of the module:
+//
+//	go run ./internal/apicheck/apigen
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"example/verification/internal/apicheck"
+)
+
+func main() {
+	root := flag.String("root", ".", "root of the module")
+	flag.Parse()
+
+	dirs, err := apicheck.Packages(*root)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, dir := range dirs {
+		if err := apicheck.Write(dir); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(filepath.Join(dir, apicheck.FileName))
+	}
+}
@@ -0,0 +1,100 @@
+// Package benchrand is the randomness of the benchmark runs.
+//
+// A run with seed 0 draws everything from crypto/rand, as the programs always
+// did. Any other seed makes the run reproducible: the permutations come from a
+// math/rand source seeded with it, and the field elements from a ChaCha20
+// keystream keyed by it. Seeded field elements are predictable by anyone who
+// knows the seed, so they are only fit for benchmarks and tests; the
+// production code samples its secrets with crypto/rand.
+package benchrand
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"golang.org/x/crypto/chacha20"
+)
+
+const keyDomain = "shuffle-zkp/benchrand/v1"
+
+// Rand is the source of the randomness of one run. It is not safe for
+// concurrent use.
+type Rand struct {
+	seed   uint64
+	perm   *mathrand.Rand
+	stream io.Reader
+}
+
+// New returns the Rand of seed, see the package documentation.
+func New(seed uint64) *Rand {
+	if seed == 0 {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(fmt.Errorf("benchrand: %w", err))
+		}
+		return &Rand{perm: mathrand.New(mathrand.NewSource(int64(binary.LittleEndian.Uint64(b[:])))), stream: rand.Reader}
+	}
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], seed)
+	key := sha256.Sum256(append([]byte(keyDomain), b[:]...))
+	var nonce [chacha20.NonceSize]byte
+	c, err := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+	if err != nil {
+		panic(fmt.Errorf("benchrand: %w", err))
+	}
+	return &Rand{
+		seed:   seed,
+		perm:   mathrand.New(mathrand.NewSource(int64(seed))),
+		stream: cipher.StreamReader{S: c, R: zeroReader{}},
+	}
+}
+
+// Seed returns the seed of r, 0 if r is not reproducible.
+func (r *Rand) Seed() uint64 {
+	return r.seed
+}
+
+// Read fills p with random bytes.
+func (r *Rand) Read(p []byte) (int, error) {
+	return io.ReadFull(r.stream, p)
+}
+
+// Fr returns a uniformly random element of fr_bn254. It reads 16 bytes more
+// than the modulus takes, which makes the bias of the reduction negligible.
+func (r *Rand) Fr() fr_bn254.Element {
+	var b [fr_bn254.Bytes + 16]byte
+	if _, err := r.Read(b[:]); err != nil {
+		panic(fmt.Errorf("benchrand: %w", err))
+	}
+	var e fr_bn254.Element
+	e.SetBigInt(new(big.Int).SetBytes(b[:]))
+	return e
+}
+
+// Shuffle permutes n elements with swap, as math/rand.Shuffle.
+func (r *Rand) Shuffle(n int, swap func(i, j int)) {
+	r.perm.Shuffle(n, swap)
+}
+
+// Intn returns a number in [0, n), as math/rand.Intn.
+func (r *Rand) Intn(n int) int {
+	return r.perm.Intn(n)
+}
+
+// zeroReader is the plaintext of the keystream.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,52 @@
+package benchrand
+
+import (
+	"reflect"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// draw returns n field elements, a shuffle of n elements and n numbers in
+// [0, 100) drawn from r.
+func draw(r *Rand, n int) ([]fr_bn254.Element, []int, []int) {
+	elements := make([]fr_bn254.Element, n)
+	perm := make([]int, n)
+	nums := make([]int, n)
+	for i := 0; i < n; i++ {
+		elements[i] = r.Fr()
+		perm[i] = i
+		nums[i] = r.Intn(100)
+	}
+	r.Shuffle(n, func(i, j int) { perm[i], perm[j] = perm[j], perm[i] })
+	return elements, perm, nums
+}
+
+func TestSeeded(t *testing.T) {
+	e1, p1, n1 := draw(New(42), 20)
+	e2, p2, n2 := draw(New(42), 20)
+	if !reflect.DeepEqual(e1, e2) || !reflect.DeepEqual(p1, p2) || !reflect.DeepEqual(n1, n2) {
+		t.Fatal("two runs of the same seed differ")
+	}
+
+	e3, p3, _ := draw(New(43), 20)
+	if reflect.DeepEqual(e1, e3) || reflect.DeepEqual(p1, p3) {
+		t.Fatal("two seeds gave the same run")
+	}
+	for i := 1; i < len(e1); i++ {
+		if e1[i].Equal(&e1[0]) {
+			t.Fatal("the stream repeats")
+		}
+	}
+}
+
+func TestUnseeded(t *testing.T) {
+	if New(0).Seed() != 0 || New(42).Seed() != 42 {
+		t.Fatal("wrong seed")
+	}
+	e1, _, _ := draw(New(0), 4)
+	e2, _, _ := draw(New(0), 4)
+	if reflect.DeepEqual(e1, e2) {
+		t.Fatal("two unseeded runs are identical")
+	}
+}
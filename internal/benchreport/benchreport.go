@@ -0,0 +1,245 @@
+// Package benchreport writes the results of the benchmark programs in one
+// format, so that the analysis scripts parse a single kind of line whatever
+// the program.
+//
+// A result is either a JSON object per line or a CSV row under a header
+// naming the columns. The durations are in nanoseconds and the sizes in
+// bytes.
+package benchreport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CommCost is the communication of one client, in bytes.
+type CommCost struct {
+	// Proof is the size of the proof.
+	Proof uint64 `json:"proof"`
+	// Other is everything else the client sends: the public witness, the
+	// commitment, the dummies, ...
+	Other uint64 `json:"other"`
+	// Total is Proof + Other.
+	Total uint64 `json:"total"`
+	// TotalCompressed is Total with the compressible messages compressed, or
+	// 0 when the program does not compress.
+	TotalCompressed uint64 `json:"totalCompressed,omitempty"`
+}
+
+// BenchmarkResult is one run of a benchmark program with one backend.
+//
+// PrepTime and ServerTime are per client, ProofTime and VerifyTime per
+// checked proof, as in the stats the programs log.
+type BenchmarkResult struct {
+	Program      string `json:"program"`
+	Backend      string `json:"backend"`
+	Curve        string `json:"curve"`
+	Constraints  int    `json:"constraints"`
+	ClientNum    int    `json:"clientNum"`
+	CorruptedNum int    `json:"corruptedNum"`
+	// CandidateNum is only set by the vote program.
+	CandidateNum int    `json:"candidateNum,omitempty"`
+	DummyLen     uint64 `json:"dummyLen"`
+
+	PrepTime   time.Duration `json:"prepTime"`
+	ProofTime  time.Duration `json:"proofTime"`
+	VerifyTime time.Duration `json:"verifyTime"`
+	ServerTime time.Duration `json:"serverTime"`
+
+	ProofSize   int `json:"proofSize"`
+	WitnessSize int `json:"witnessSize"`
+	PKSize      int `json:"pkSize"`
+
+	CommCost CommCost `json:"commCost"`
+
+	// BelowTarget marks a run whose DummyLen was set by hand below the
+	// number of dummies the security target of (lambda, ClientNum,
+	// CorruptedNum) requires: its privacy is not the one claimed.
+	BelowTarget bool `json:"belowTarget,omitempty"`
+}
+
+// Header is the header of the CSV format.
+var Header = []string{
+	"program", "backend", "curve", "constraints", "clientNum", "corruptedNum", "candidateNum", "dummyLen",
+	"prepTime", "proofTime", "verifyTime", "serverTime",
+	"proofSize", "witnessSize", "pkSize",
+	"commProof", "commOther", "commTotal", "commTotalCompressed",
+	"belowTarget",
+}
+
+func (r BenchmarkResult) record() []string {
+	i := strconv.Itoa
+	u := func(v uint64) string { return strconv.FormatUint(v, 10) }
+	d := func(v time.Duration) string { return strconv.FormatInt(int64(v), 10) }
+	return []string{
+		r.Program, r.Backend, r.Curve, i(r.Constraints), i(r.ClientNum), i(r.CorruptedNum), i(r.CandidateNum), u(r.DummyLen),
+		d(r.PrepTime), d(r.ProofTime), d(r.VerifyTime), d(r.ServerTime),
+		i(r.ProofSize), i(r.WitnessSize), i(r.PKSize),
+		u(r.CommCost.Proof), u(r.CommCost.Other), u(r.CommCost.Total), u(r.CommCost.TotalCompressed),
+		strconv.FormatBool(r.BelowTarget),
+	}
+}
+
+// WriteTo writes r as a CSV header and one row. It has the signature of
+// io.WriterTo and returns the number of bytes written.
+func (r BenchmarkResult) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := NewReporter(cw, FormatCSV).Report(r)
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Mean returns the results averaged over the runs of one configuration: the
+// times and the communication are the means, everything else is taken from
+// the first run. It returns the zero result for no run.
+func Mean(results []BenchmarkResult) BenchmarkResult {
+	if len(results) == 0 {
+		return BenchmarkResult{}
+	}
+	mean := results[0]
+	mean.PrepTime, mean.ProofTime, mean.VerifyTime, mean.ServerTime = 0, 0, 0, 0
+	mean.CommCost = CommCost{}
+	for _, r := range results {
+		mean.PrepTime += r.PrepTime
+		mean.ProofTime += r.ProofTime
+		mean.VerifyTime += r.VerifyTime
+		mean.ServerTime += r.ServerTime
+		mean.CommCost.Proof += r.CommCost.Proof
+		mean.CommCost.Other += r.CommCost.Other
+		mean.CommCost.Total += r.CommCost.Total
+		mean.CommCost.TotalCompressed += r.CommCost.TotalCompressed
+	}
+	n := len(results)
+	mean.PrepTime /= time.Duration(n)
+	mean.ProofTime /= time.Duration(n)
+	mean.VerifyTime /= time.Duration(n)
+	mean.ServerTime /= time.Duration(n)
+	mean.CommCost.Proof /= uint64(n)
+	mean.CommCost.Other /= uint64(n)
+	mean.CommCost.Total /= uint64(n)
+	mean.CommCost.TotalCompressed /= uint64(n)
+	return mean
+}
+
+// ParseDummyLengths parses the comma separated list of dummy lengths of the
+// -dummies flag of the programs, which replace the number of dummies the
+// security target requires to sweep the cost of the dummies. The empty list
+// keeps the required number.
+func ParseDummyLengths(list string) ([]uint64, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var lengths []uint64
+	for _, field := range strings.Split(list, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("dummy length %q: %w", field, err)
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("dummy length %q: a client needs at least one dummy", field)
+		}
+		lengths = append(lengths, n)
+	}
+	return lengths, nil
+}
+
+// Format is the format of the results.
+type Format int
+
+const (
+	FormatCSV Format = iota
+	FormatJSON
+)
+
+// ParseFormat returns the format named name, csv or json.
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "csv":
+		return FormatCSV, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return FormatCSV, fmt.Errorf("unknown format %q", name)
+}
+
+func (f Format) String() string {
+	if f == FormatJSON {
+		return "json"
+	}
+	return "csv"
+}
+
+// Ext is the extension of the files in format f.
+func (f Format) Ext() string {
+	if f == FormatJSON {
+		return ".jsonl"
+	}
+	return ".csv"
+}
+
+// Reporter writes results to w. In the CSV format the header precedes the
+// first result.
+type Reporter struct {
+	w      io.Writer
+	format Format
+	header bool
+}
+
+// NewReporter returns a Reporter writing to w in format.
+func NewReporter(w io.Writer, format Format) *Reporter {
+	return &Reporter{w: w, format: format}
+}
+
+// OpenReporter returns a Reporter appending to the file name in format,
+// created if needed, and the file for the caller to close. The CSV header is
+// only written to an empty file, so that the runs appended to one file share
+// the header of the first.
+func OpenReporter(name string, format Format) (*Reporter, *os.File, error) {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	rep := NewReporter(f, format)
+	rep.header = info.Size() > 0
+	return rep, f, nil
+}
+
+// Report writes r.
+func (rep *Reporter) Report(r BenchmarkResult) error {
+	if rep.format == FormatJSON {
+		return json.NewEncoder(rep.w).Encode(r)
+	}
+	cw := csv.NewWriter(rep.w)
+	if !rep.header {
+		if err := cw.Write(Header); err != nil {
+			return err
+		}
+		rep.header = true
+	}
+	if err := cw.Write(r.record()); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,146 @@
+package benchreport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var result = BenchmarkResult{
+	Program:     "vote",
+	Backend:     "Groth16",
+	Curve:       "bn254",
+	Constraints: 100,
+	ClientNum:   10,
+	ProofTime:   time.Millisecond,
+	CommCost:    CommCost{Proof: 1, Other: 2, Total: 3},
+}
+
+func TestReportCSV(t *testing.T) {
+	var out bytes.Buffer
+	rep := NewReporter(&out, FormatCSV)
+	for i := 0; i < 2; i++ {
+		if err := rep.Report(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !reflect.DeepEqual(records[0], Header) {
+		t.Fatalf("expected a header and two rows, got %v", records)
+	}
+	if records[1][0] != "vote" || records[1][9] != "1000000" || len(records[1]) != len(Header) {
+		t.Fatalf("unexpected row %v", records[1])
+	}
+}
+
+// TestOpenReporter appends two runs to one file: the header is written once.
+func TestOpenReporter(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "output.csv")
+	for run := 0; run < 2; run++ {
+		rep, f, err := OpenReporter(name, FormatCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rep.Report(result); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !reflect.DeepEqual(records[0], Header) || reflect.DeepEqual(records[2], Header) {
+		t.Fatalf("expected a header and two rows, got %v", records)
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	var out bytes.Buffer
+	rep := NewReporter(&out, FormatJSON)
+	if err := rep.Report(result); err != nil {
+		t.Fatal(err)
+	}
+	var got BenchmarkResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != result {
+		t.Fatalf("got %+v, expected %+v", got, result)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	var out bytes.Buffer
+	n, err := result.WriteTo(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(out.Len()) {
+		t.Fatalf("WriteTo returned %v, wrote %v bytes", n, out.Len())
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !reflect.DeepEqual(records[0], Header) || !reflect.DeepEqual(records[1], result.record()) {
+		t.Fatalf("expected a header and the row of the result, got %v", records)
+	}
+}
+
+func TestMean(t *testing.T) {
+	other := result
+	other.ProofTime = 3 * time.Millisecond
+	other.CommCost = CommCost{Proof: 3, Other: 4, Total: 7}
+	mean := Mean([]BenchmarkResult{result, other})
+	if mean.ProofTime != 2*time.Millisecond || mean.CommCost != (CommCost{Proof: 2, Other: 3, Total: 5}) {
+		t.Fatalf("unexpected mean %+v", mean)
+	}
+	if mean.Program != result.Program || mean.Constraints != result.Constraints {
+		t.Fatalf("the configuration of the mean %+v is not the one of the runs", mean)
+	}
+	if Mean(nil) != (BenchmarkResult{}) {
+		t.Fatal("the mean of no run is not zero")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []Format{FormatCSV, FormatJSON} {
+		if got, err := ParseFormat(f.String()); err != nil || got != f {
+			t.Fatalf("ParseFormat(%q) = %v, %v", f, got, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Fatal("an unknown format was accepted")
+	}
+}
+
+func TestParseDummyLengths(t *testing.T) {
+	lengths, err := ParseDummyLengths("4, 8,16")
+	if err != nil || !reflect.DeepEqual(lengths, []uint64{4, 8, 16}) {
+		t.Fatalf("ParseDummyLengths = %v, %v", lengths, err)
+	}
+	if lengths, err := ParseDummyLengths(""); err != nil || lengths != nil {
+		t.Fatalf("the empty list: %v, %v", lengths, err)
+	}
+	for _, list := range []string{"4,x", "4,0", "-1"} {
+		if _, err := ParseDummyLengths(list); err == nil {
+			t.Fatalf("ParseDummyLengths(%q) accepted", list)
+		}
+	}
+}
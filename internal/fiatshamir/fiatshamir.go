@@ -0,0 +1,42 @@
+// Package fiatshamir derives the challenge publicR of a round from the
+// commitments of its clients, so that the server does not have to be trusted
+// to sample it after the commit phase.
+//
+// The challenge is the MiMC hash of the domain tag of the protocol, the
+// identifier of the round and the commitments, in the order of the clients.
+// Anybody who knows the commitments can recompute it, and a client cannot
+// choose its commitment after seeing the challenge, since the challenge
+// depends on it. The mapping is pinned by testdata/vectors.json.
+package fiatshamir
+
+import (
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/hash"
+)
+
+// Tag maps the domain tag of a protocol to a field element: its bytes, big
+// endian, reduced modulo the field.
+func Tag(domain string) fr_bn254.Element {
+	var tag fr_bn254.Element
+	tag.SetBytes([]byte(domain))
+	return tag
+}
+
+// Challenge is the challenge of the round of the protocol domain whose
+// clients sent commitments.
+func Challenge(domain string, round uint64, commitments []fr_bn254.Element) fr_bn254.Element {
+	goMimc := hash.MIMC_BN254.New()
+	tag := Tag(domain)
+	b := tag.Bytes()
+	goMimc.Write(b[:])
+	roundFr := fr_bn254.NewElement(round)
+	b = roundFr.Bytes()
+	goMimc.Write(b[:])
+	for i := 0; i < len(commitments); i++ {
+		b := commitments[i].Bytes()
+		goMimc.Write(b[:])
+	}
+	var publicR fr_bn254.Element
+	publicR.SetBytes(goMimc.Sum(nil))
+	return publicR
+}
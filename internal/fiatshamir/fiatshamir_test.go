@@ -0,0 +1,78 @@
+package fiatshamir
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// vector is an entry of testdata/vectors.json, with the field elements in
+// decimal.
+type vector struct {
+	Domain      string   `json:"domain"`
+	Round       uint64   `json:"round"`
+	Commitments []string `json:"commitments"`
+	Challenge   string   `json:"challenge"`
+}
+
+func TestVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []vector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) == 0 {
+		t.Fatal("no test vector")
+	}
+	for i, v := range vectors {
+		commitments := make([]fr_bn254.Element, len(v.Commitments))
+		for j := range commitments {
+			if _, err := commitments[j].SetString(v.Commitments[j]); err != nil {
+				t.Fatalf("vector %v: commitment %v: %v", i, j, err)
+			}
+		}
+		if r := Challenge(v.Domain, v.Round, commitments); r.String() != v.Challenge {
+			t.Fatalf("vector %v: challenge %v, expected %v", i, r.String(), v.Challenge)
+		}
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	const domain = "shuffle-zkp/test/challenge/v1"
+	commitments := make([]fr_bn254.Element, 5)
+	for i := range commitments {
+		commitments[i].SetRandom()
+	}
+	r := Challenge(domain, 1, commitments)
+	again := Challenge(domain, 1, append([]fr_bn254.Element(nil), commitments...))
+	if !r.Equal(&again) {
+		t.Fatal("the same commitments derive different challenges")
+	}
+
+	for name, other := range map[string]fr_bn254.Element{
+		"another round":  Challenge(domain, 2, commitments),
+		"another domain": Challenge(domain+"/other", 1, commitments),
+		"no commitment":  Challenge(domain, 1, nil),
+		"reordered":      Challenge(domain, 1, append([]fr_bn254.Element{commitments[1], commitments[0]}, commitments[2:]...)),
+	} {
+		if other.Equal(&r) {
+			t.Fatalf("%v derives the same challenge", name)
+		}
+	}
+
+	// a single changed commitment
+	for i := range commitments {
+		changed := append([]fr_bn254.Element(nil), commitments...)
+		var one fr_bn254.Element
+		one.SetOne()
+		changed[i].Add(&changed[i], &one)
+		if c := Challenge(domain, 1, changed); c.Equal(&r) {
+			t.Fatalf("changing commitment %v does not change the challenge", i)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Package frwire is the wire format of a vector of BN254 field elements: a
+// 4-byte little-endian count, then every element in 32 bytes, big-endian, as
+// fr_bn254.Element.Bytes writes it.
+//
+// The decoder checks the count against the bytes present before allocating,
+// so a corrupted count cannot trigger a huge allocation, and refuses the
+// elements above the modulus, which would otherwise be reduced into another
+// value than the one sent.
+package frwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// PrefixSize is the size of the count of a vector.
+const PrefixSize = 4
+
+var (
+	ErrTooLong      = errors.New("frwire: the vector does not fit a 4-byte count")
+	ErrTruncated    = errors.New("frwire: the vector is truncated")
+	ErrNonCanonical = errors.New("frwire: the vector holds an element above the modulus")
+	ErrTrailing     = errors.New("frwire: trailing bytes after the vector")
+)
+
+// Size returns the size of the encoding of n elements.
+func Size(n int) int {
+	return PrefixSize + n*fr_bn254.Bytes
+}
+
+// SerializeFieldElements returns the encoding of elems.
+func SerializeFieldElements(elems []fr_bn254.Element) ([]byte, error) {
+	return AppendFieldElements(make([]byte, 0, Size(len(elems))), elems)
+}
+
+// AppendFieldElements appends the encoding of elems to dst.
+func AppendFieldElements(dst []byte, elems []fr_bn254.Element) ([]byte, error) {
+	if uint64(len(elems)) > math.MaxUint32 {
+		return dst, fmt.Errorf("%w: %v elements", ErrTooLong, len(elems))
+	}
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(elems)))
+	for i := range elems {
+		b := elems[i].Bytes()
+		dst = append(dst, b[:]...)
+	}
+	return dst, nil
+}
+
+// DeserializeFieldElements decodes b, which must hold exactly one vector.
+func DeserializeFieldElements(b []byte) ([]fr_bn254.Element, error) {
+	elems, rest, err := ReadFieldElements(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: %v bytes", ErrTrailing, len(rest))
+	}
+	return elems, nil
+}
+
+// ReadFieldElements decodes the vector at the start of b and returns the bytes
+// after it.
+func ReadFieldElements(b []byte) ([]fr_bn254.Element, []byte, error) {
+	if len(b) < PrefixSize {
+		return nil, nil, fmt.Errorf("%w: %v bytes of count", ErrTruncated, len(b))
+	}
+	n := binary.LittleEndian.Uint32(b)
+	b = b[PrefixSize:]
+	if uint64(n)*fr_bn254.Bytes > uint64(len(b)) {
+		return nil, nil, fmt.Errorf("%w: %v elements in %v bytes", ErrTruncated, n, len(b))
+	}
+	elems := make([]fr_bn254.Element, n)
+	for i := range elems {
+		if err := elems[i].SetBytesCanonical(b[:fr_bn254.Bytes]); err != nil {
+			return nil, nil, fmt.Errorf("%w: element %v", ErrNonCanonical, i)
+		}
+		b = b[fr_bn254.Bytes:]
+	}
+	return elems, b, nil
+}
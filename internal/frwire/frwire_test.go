@@ -0,0 +1,101 @@
+package frwire
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 100} {
+		elems := make([]fr_bn254.Element, n)
+		for i := range elems {
+			if _, err := elems[i].SetRandom(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b, err := SerializeFieldElements(elems)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) != Size(n) {
+			t.Fatalf("%v elements: %v bytes, expected %v", n, len(b), Size(n))
+		}
+		got, err := DeserializeFieldElements(b)
+		if err != nil {
+			t.Fatalf("%v elements: %v", n, err)
+		}
+		if len(got) != n || (n > 0 && !reflect.DeepEqual(got, elems)) {
+			t.Fatalf("%v elements: got %v back", n, len(got))
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	elems := []fr_bn254.Element{fr_bn254.NewElement(1), fr_bn254.NewElement(0x0203)}
+	b, err := SerializeFieldElements(elems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make([]byte, Size(2))
+	expected[0] = 2
+	expected[PrefixSize+31] = 1
+	expected[PrefixSize+62], expected[PrefixSize+63] = 2, 3
+	if !bytes.Equal(b, expected) {
+		t.Fatalf("got %x, expected %x", b, expected)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	one := []fr_bn254.Element{fr_bn254.NewElement(5)}
+	valid, err := SerializeFieldElements(one)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range [][]byte{nil, {1, 0}, valid[:len(valid)-1]} {
+		if _, err := DeserializeFieldElements(b); !errors.Is(err, ErrTruncated) {
+			t.Fatalf("%x: got %v, expected ErrTruncated", b, err)
+		}
+	}
+	// a count far beyond the data is refused before any allocation
+	huge := []byte{0xff, 0xff, 0xff, 0xff}
+	if _, err := DeserializeFieldElements(huge); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("a count of 2^32-1 in no data: got %v, expected ErrTruncated", err)
+	}
+
+	above := append([]byte(nil), valid...)
+	for i := PrefixSize; i < len(above); i++ {
+		above[i] = 0xff
+	}
+	if _, err := DeserializeFieldElements(above); !errors.Is(err, ErrNonCanonical) {
+		t.Fatalf("an element above the modulus: got %v, expected ErrNonCanonical", err)
+	}
+
+	if _, err := DeserializeFieldElements(append(valid, 0)); !errors.Is(err, ErrTrailing) {
+		t.Fatalf("a trailing byte: got %v, expected ErrTrailing", err)
+	}
+}
+
+func TestReadFieldElements(t *testing.T) {
+	first := []fr_bn254.Element{fr_bn254.NewElement(1), fr_bn254.NewElement(2)}
+	second := []fr_bn254.Element{fr_bn254.NewElement(3)}
+	b, err := AppendFieldElements(nil, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = AppendFieldElements(b, second); err != nil {
+		t.Fatal(err)
+	}
+
+	got, rest, err := ReadFieldElements(b)
+	if err != nil || !reflect.DeepEqual(got, first) {
+		t.Fatalf("first vector: %v, %v", got, err)
+	}
+	if got, rest, err = ReadFieldElements(rest); err != nil || !reflect.DeepEqual(got, second) || len(rest) != 0 {
+		t.Fatalf("second vector: %v, %v bytes left, %v", got, len(rest), err)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !js || !wasm
+
+package gadget
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// Diagnose runs assignment through circuit, over the scalar field of curve,
+// in the test engine of gnark and returns the first assertion that fails, nil
+// if the assignment solves the circuit. It is meant for a witness the solver
+// of a compiled circuit rejected: the solver only names the index of the
+// constraint, the test engine fails on the assertion that defined it.
+func Diagnose(curve ecc.ID, circuit, assignment frontend.Circuit) *Failure {
+	if err := gnarkshim.IsSolved(curve, circuit, assignment); err != nil {
+		return ParseFailure(err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Package gadget labels the assertions of the circuits with the protocol
+// invariant they enforce, so that a witness that fails to solve is reported
+// as the invariant it breaks rather than as the index of a constraint.
+//
+// A circuit asserts through Label(api, inv) instead of api:
+//
+//	gadget.Label(api, gadget.Product).AssertIsEqual(privateProd, circuit.PublicProd)
+//
+// The label adds no constraint: a compiled circuit is the same with or
+// without it. It only shows in the test engine of gnark, where an assertion
+// fails as soon as it is defined, and Diagnose reads it back from there.
+package gadget
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// Invariant is a protocol-level property checked by a circuit.
+type Invariant int
+
+const (
+	// Unlabeled is the invariant of an assertion that was not labeled.
+	Unlabeled Invariant = iota
+	// Commitment is the opening of the public commitment by the private
+	// inputs.
+	Commitment
+	// Product is the public product of the client, and the mask in it, on
+	// the private inputs.
+	Product
+	// Threshold is a bound on a private input: a number of approvals, a
+	// weight or a budget.
+	Threshold
+	// Permutation is the ballot being a ranking of the candidates and its
+	// pairs the pairs of that ranking.
+	Permutation
+	// Range is a private input being one of the candidates: a candidate
+	// index or a choice.
+	Range
+)
+
+var invariantNames = [...]string{
+	Unlabeled:   "unlabeled",
+	Commitment:  "commitment",
+	Product:     "product",
+	Threshold:   "threshold",
+	Permutation: "permutation",
+	Range:       "range",
+}
+
+func (inv Invariant) String() string {
+	if inv < 0 || int(inv) >= len(invariantNames) {
+		return fmt.Sprintf("Invariant(%d)", int(inv))
+	}
+	return invariantNames[inv]
+}
+
+// Failure is an assertion of a circuit that does not hold on a witness.
+type Failure struct {
+	Invariant Invariant
+	// Cause is the message of gnark for the assertion, with the values it
+	// compared.
+	Cause string
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("%v%v invariant violated: %v", failurePrefix, f.Invariant, f.Cause)
+}
+
+// Label returns api with its assertions labeled inv.
+func Label(api frontend.API, inv Invariant) frontend.API {
+	return labeled{API: api, inv: inv}
+}
+
+// labeled forwards everything to the API. When an assertion panics, which
+// only the test engine does, it panics again with the Failure of inv.
+type labeled struct {
+	frontend.API
+	inv Invariant
+}
+
+func (l labeled) relabel() {
+	if r := recover(); r != nil {
+		panic(&Failure{Invariant: l.inv, Cause: fmt.Sprint(r)})
+	}
+}
+
+func (l labeled) AssertIsEqual(i1, i2 frontend.Variable) {
+	defer l.relabel()
+	l.API.AssertIsEqual(i1, i2)
+}
+
+func (l labeled) AssertIsDifferent(i1, i2 frontend.Variable) {
+	defer l.relabel()
+	l.API.AssertIsDifferent(i1, i2)
+}
+
+func (l labeled) AssertIsBoolean(i1 frontend.Variable) {
+	defer l.relabel()
+	l.API.AssertIsBoolean(i1)
+}
+
+func (l labeled) AssertIsLessOrEqual(v frontend.Variable, bound frontend.Variable) {
+	defer l.relabel()
+	l.API.AssertIsLessOrEqual(v, bound)
+}
+
+// ToBinary is labeled too: decomposing a value in n bits asserts that it is
+// below 2^n.
+func (l labeled) ToBinary(i1 frontend.Variable, n ...int) []frontend.Variable {
+	defer l.relabel()
+	return l.API.ToBinary(i1, n...)
+}
+
+// failurePrefix starts the message of every Failure.
+const failurePrefix = "gadget: "
+
+// ParseFailure returns the Failure in the message of err, in which the test
+// engine of gnark formats the panic of a failing assertion. An assertion that
+// was not labeled is a Failure of Unlabeled, with the first line of the
+// message as its cause.
+func ParseFailure(err error) *Failure {
+	msg := err.Error()
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	if i := strings.Index(msg, failurePrefix); i >= 0 {
+		labeled := msg[i+len(failurePrefix):]
+		for inv, name := range invariantNames {
+			if cause, ok := strings.CutPrefix(labeled, name+" invariant violated: "); ok {
+				return &Failure{Invariant: Invariant(inv), Cause: cause}
+			}
+		}
+	}
+	return &Failure{Invariant: Unlabeled, Cause: msg}
+}
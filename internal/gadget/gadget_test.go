@@ -0,0 +1,99 @@
+package gadget
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// checkCircuit asserts one relation of every kind, labeled with Labels when
+// it is set.
+type checkCircuit struct {
+	Equal, Bit, Small, Bits, Different frontend.Variable
+	Labels                             []Invariant `gnark:"-"`
+}
+
+func (circuit *checkCircuit) Define(api frontend.API) error {
+	label := func(i int) frontend.API {
+		if circuit.Labels == nil {
+			return api
+		}
+		return Label(api, circuit.Labels[i])
+	}
+	label(0).AssertIsEqual(circuit.Equal, 1)
+	label(1).AssertIsBoolean(circuit.Bit)
+	label(2).AssertIsLessOrEqual(circuit.Small, 3)
+	label(3).ToBinary(circuit.Bits, 2)
+	label(4).AssertIsDifferent(circuit.Different, 0)
+	return nil
+}
+
+var checkLabels = []Invariant{Commitment, Permutation, Range, Threshold, Product}
+
+func TestDiagnose(t *testing.T) {
+	circuit := checkCircuit{Labels: checkLabels}
+	valid := checkCircuit{Equal: 1, Bit: 0, Small: 3, Bits: 3, Different: 2}
+	if f := Diagnose(ecc.BN254, &circuit, &valid); f != nil {
+		t.Fatalf("a valid assignment: got %v", f)
+	}
+
+	for i, tc := range []struct {
+		name   string
+		change func(*checkCircuit)
+	}{
+		{"equal", func(c *checkCircuit) { c.Equal = 2 }},
+		{"boolean", func(c *checkCircuit) { c.Bit = 2 }},
+		{"less or equal", func(c *checkCircuit) { c.Small = 4 }},
+		{"to binary", func(c *checkCircuit) { c.Bits = 4 }},
+		{"different", func(c *checkCircuit) { c.Different = 0 }},
+	} {
+		assignment := valid
+		tc.change(&assignment)
+		f := Diagnose(ecc.BN254, &circuit, &assignment)
+		if f == nil || f.Invariant != checkLabels[i] {
+			t.Fatalf("%v: got %v, expected the %v invariant", tc.name, f, checkLabels[i])
+		}
+		if f.Cause == "" {
+			t.Fatalf("%v: the failure has no cause", tc.name)
+		}
+
+		// without the labels, the same failure is unlabeled
+		f = Diagnose(ecc.BN254, &checkCircuit{}, &assignment)
+		if f == nil || f.Invariant != Unlabeled {
+			t.Fatalf("%v unlabeled: got %v", tc.name, f)
+		}
+	}
+}
+
+// TestLabelAddsNoConstraint checks that labeling leaves the compiled circuit
+// as it is.
+func TestLabelAddsNoConstraint(t *testing.T) {
+	labeled, err := gnarkshim.CompileR1CS(ecc.BN254, &checkCircuit{Labels: checkLabels})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlabeled, err := gnarkshim.CompileR1CS(ecc.BN254, &checkCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labeled.GetNbConstraints() != unlabeled.GetNbConstraints() {
+		t.Fatalf("%v constraints labeled, %v unlabeled", labeled.GetNbConstraints(), unlabeled.GetNbConstraints())
+	}
+}
+
+func TestParseFailure(t *testing.T) {
+	f := &Failure{Invariant: Product, Cause: "[assertIsEqual] 1 == 2"}
+	if got := ParseFailure(errors.New(f.Error() + "\ngoroutine 1 [running]:")); *got != *f {
+		t.Fatalf("got %+v, expected %+v", *got, *f)
+	}
+	if got := ParseFailure(errors.New("[assertIsEqual] 1 == 2\nstack")); got.Invariant != Unlabeled || got.Cause != "[assertIsEqual] 1 == 2" {
+		t.Fatalf("unlabeled: got %+v", *got)
+	}
+	if s := Invariant(9).String(); s != "Invariant(9)" {
+		t.Fatalf("got %q", s)
+	}
+}
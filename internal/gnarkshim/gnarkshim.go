@@ -0,0 +1,69 @@
+// Package gnarkshim wraps the gnark entry points used by the repository
+// behind signatures of our own, so that an upgrade of gnark touches this
+// package only.
+//
+// The assertions at the top of the files pin the gnark signatures the shim is
+// written against: when gnark changes one of them, the build fails here, next
+// to the only call that needs to be fixed, rather than all over the
+// repository.
+//
+// The plonk entry points, in plonk.go, and the test engine, in solve.go, are
+// left out of the js/wasm build: the wasm client only proves with groth16.
+package gnarkshim
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+)
+
+var (
+	_ func(*big.Int, frontend.NewBuilder, frontend.Circuit, ...frontend.CompileOption) (constraint.ConstraintSystem, error) = frontend.Compile
+	_ frontend.NewBuilder                                                                                                   = r1cs.NewBuilder
+	_ func(frontend.Circuit, *big.Int, ...frontend.WitnessOption) (witness.Witness, error)                                  = frontend.NewWitness
+	_ func(*big.Int) (witness.Witness, error)                                                                               = witness.New
+
+	_ func(constraint.ConstraintSystem) (groth16.ProvingKey, groth16.VerifyingKey, error)                                    = groth16.Setup
+	_ func(constraint.ConstraintSystem, groth16.ProvingKey, witness.Witness, ...backend.ProverOption) (groth16.Proof, error) = groth16.Prove
+	_ func(groth16.Proof, groth16.VerifyingKey, witness.Witness) error                                                       = groth16.Verify
+)
+
+// CompileR1CS compiles circuit over the scalar field of curve into the rank-1
+// constraint system of groth16.
+func CompileR1CS(curve ecc.ID, circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	return frontend.Compile(curve.ScalarField(), r1cs.NewBuilder, circuit)
+}
+
+// NewWitness returns the full witness of assignment.
+func NewWitness(curve ecc.ID, assignment frontend.Circuit) (witness.Witness, error) {
+	return frontend.NewWitness(assignment, curve.ScalarField())
+}
+
+// NewPublicWitness returns the public witness of assignment, whose secret
+// values may be left unset.
+func NewPublicWitness(curve ecc.ID, assignment frontend.Circuit) (witness.Witness, error) {
+	return frontend.NewWitness(assignment, curve.ScalarField(), frontend.PublicOnly())
+}
+
+// NewEmptyWitness returns an empty witness, to be filled or unmarshalled.
+func NewEmptyWitness(curve ecc.ID) (witness.Witness, error) {
+	return witness.New(curve.ScalarField())
+}
+
+func SetupGroth16(ccs constraint.ConstraintSystem) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	return groth16.Setup(ccs)
+}
+
+func ProveGroth16(ccs constraint.ConstraintSystem, pk groth16.ProvingKey, fullWitness witness.Witness) (groth16.Proof, error) {
+	return groth16.Prove(ccs, pk, fullWitness)
+}
+
+func VerifyGroth16(proof groth16.Proof, vk groth16.VerifyingKey, publicWitness witness.Witness) error {
+	return groth16.Verify(proof, vk, publicWitness)
+}
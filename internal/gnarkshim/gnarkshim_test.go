@@ -0,0 +1,174 @@
+package gnarkshim
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+)
+
+// squareCircuit proves the knowledge of a square root of Y.
+type squareCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (circuit *squareCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(circuit.X, circuit.X), circuit.Y)
+	return nil
+}
+
+// squareWitnesses returns the full and the public witness of 3^2 = 9, and the
+// public witness of a wrong Y.
+func squareWitnesses(t *testing.T, curve ecc.ID) (witness.Witness, witness.Witness, witness.Witness) {
+	fullWitness, err := NewWitness(curve, &squareCircuit{X: 3, Y: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicWitness, err := NewPublicWitness(curve, &squareCircuit{Y: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongWitness, err := NewPublicWitness(curve, &squareCircuit{Y: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fullWitness, publicWitness, wrongWitness
+}
+
+func TestWitness(t *testing.T) {
+	for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+		fullWitness, publicWitness, _ := squareWitnesses(t, curve)
+		expected, err := fullWitness.Public()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := expected.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := publicWitness.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, got) {
+			t.Fatalf("%v: NewPublicWitness differs from the public part of NewWitness", curve)
+		}
+
+		empty, err := NewEmptyWitness(curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := empty.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ = empty.MarshalBinary(); !bytes.Equal(b, got) {
+			t.Fatalf("%v: the unmarshalled witness differs", curve)
+		}
+	}
+}
+
+func TestGroth16(t *testing.T) {
+	for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+		ccs, err := CompileR1CS(curve, &squareCircuit{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pk, vk, err := SetupGroth16(ccs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fullWitness, publicWitness, wrongWitness := squareWitnesses(t, curve)
+		proof, err := ProveGroth16(ccs, pk, fullWitness)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyGroth16(proof, vk, publicWitness); err != nil {
+			t.Fatalf("%v: %v", curve, err)
+		}
+		if err := VerifyGroth16(proof, vk, wrongWitness); err == nil {
+			t.Fatalf("%v: a proof verified against a wrong public witness", curve)
+		}
+	}
+}
+
+func TestPlonk(t *testing.T) {
+	for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+		ccs, err := CompileSCS(curve, &squareCircuit{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		srs, err := NewKZGSRS(ccs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pk, vk, err := SetupPlonk(ccs, srs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fullWitness, publicWitness, wrongWitness := squareWitnesses(t, curve)
+		proof, err := ProvePlonk(ccs, pk, fullWitness)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyPlonk(proof, vk, publicWitness); err != nil {
+			t.Fatalf("%v: %v", curve, err)
+		}
+		if err := VerifyPlonk(proof, vk, wrongWitness); err == nil {
+			t.Fatalf("%v: a proof verified against a wrong public witness", curve)
+		}
+	}
+}
+
+// layoutCircuit has a public slice after a public variable.
+type layoutCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable   `gnark:",public"`
+	Z []frontend.Variable `gnark:",public"`
+}
+
+func (circuit *layoutCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(circuit.X, circuit.X), api.Add(circuit.Y, circuit.Z[0], circuit.Z[1]))
+	return nil
+}
+
+// misTaggedCircuit is layoutCircuit with X tagged public by mistake.
+type misTaggedCircuit struct {
+	X frontend.Variable   `gnark:",public"`
+	Y frontend.Variable   `gnark:",public"`
+	Z []frontend.Variable `gnark:",public"`
+}
+
+func (circuit *misTaggedCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(circuit.X, circuit.X), api.Add(circuit.Y, circuit.Z[0], circuit.Z[1]))
+	return nil
+}
+
+func TestCheckPublic(t *testing.T) {
+	expected := append([]string{"Y"}, PublicSlice("Z", 2)...)
+	for _, compile := range []func(ecc.ID, frontend.Circuit) (constraint.ConstraintSystem, error){CompileR1CS, CompileSCS} {
+		for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+			ccs, err := compile(curve, &layoutCircuit{Z: make([]frontend.Variable, 2)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := CheckPublic(ccs, expected); err != nil {
+				t.Fatalf("%v: %v", curve, err)
+			}
+
+			ccs, err = compile(curve, &misTaggedCircuit{Z: make([]frontend.Variable, 2)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = CheckPublic(ccs, expected)
+			if !errors.Is(err, ErrPublicLayout) || !strings.Contains(err.Error(), "unexpected [X]") {
+				t.Fatalf("%v: a mis-tagged circuit: got %v", curve, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package gnarkshim
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/consensys/gnark/constraint"
+	cs_bls12381 "github.com/consensys/gnark/constraint/bls12-381"
+	cs_bn254 "github.com/consensys/gnark/constraint/bn254"
+)
+
+// A public tag added to or dropped from a circuit field changes the public
+// witness, and the proofs then fail to verify far from the cause. The
+// programs declare the public variables their protocol expects and check the
+// compiled circuit against them with CheckPublic before the setup.
+
+// ErrPublicLayout is returned by CheckPublic when the public variables of a
+// compiled circuit differ from the expected ones.
+var ErrPublicLayout = errors.New("gnarkshim: the public variables differ from the expected layout")
+
+// PublicVariables returns the names of the public variables of ccs in the
+// order of the public witness, without the constant wire of the R1CS.
+func PublicVariables(ccs constraint.ConstraintSystem) ([]string, error) {
+	var public []string
+	switch ccs := ccs.(type) {
+	case *cs_bn254.R1CS:
+		public = ccs.Public
+	case *cs_bls12381.R1CS:
+		public = ccs.Public
+	default:
+		return nil, fmt.Errorf("gnarkshim: unsupported constraint system %T", ccs)
+	}
+	if len(public) > 0 && public[0] == "1" {
+		public = public[1:]
+	}
+	return append([]string(nil), public...), nil
+}
+
+// PublicSlice returns the names gnark gives to the public variables of a
+// slice field of length n: name_0, ..., name_{n-1}.
+func PublicSlice(name string, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = name + "_" + strconv.Itoa(i)
+	}
+	return names
+}
+
+// CheckPublic checks that ccs has exactly the expected public variables, in
+// order. The error lists the names missing from ccs and the unexpected ones.
+func CheckPublic(ccs constraint.ConstraintSystem, expected []string) error {
+	got, err := PublicVariables(ccs)
+	if err != nil {
+		return err
+	}
+	if len(got) == len(expected) {
+		same := true
+		for i := range got {
+			same = same && got[i] == expected[i]
+		}
+		if same {
+			return nil
+		}
+	}
+	in := func(names []string) map[string]bool {
+		set := make(map[string]bool, len(names))
+		for _, name := range names {
+			set[name] = true
+		}
+		return set
+	}
+	gotSet, expectedSet := in(got), in(expected)
+	var missing, unexpected []string
+	for _, name := range expected {
+		if !gotSet[name] {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range got {
+		if !expectedSet[name] {
+			unexpected = append(unexpected, name)
+		}
+	}
+	return fmt.Errorf("%w: expected %v, got %v (missing %v, unexpected %v)", ErrPublicLayout, expected, got, missing, unexpected)
+}
@@ -0,0 +1,48 @@
+//go:build !js || !wasm
+
+package gnarkshim
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test"
+)
+
+var (
+	_ frontend.NewBuilder = scs.NewBuilder
+
+	_ func(constraint.ConstraintSystem) (kzg.SRS, error)                                                                 = test.NewKZGSRS
+	_ func(constraint.ConstraintSystem, kzg.SRS) (plonk.ProvingKey, plonk.VerifyingKey, error)                           = plonk.Setup
+	_ func(constraint.ConstraintSystem, plonk.ProvingKey, witness.Witness, ...backend.ProverOption) (plonk.Proof, error) = plonk.Prove
+	_ func(plonk.Proof, plonk.VerifyingKey, witness.Witness) error                                                       = plonk.Verify
+)
+
+// CompileSCS compiles circuit over the scalar field of curve into the sparse
+// constraint system of plonk.
+func CompileSCS(curve ecc.ID, circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	return frontend.Compile(curve.ScalarField(), scs.NewBuilder, circuit)
+}
+
+// NewKZGSRS returns a KZG SRS large enough for ccs. Its toxic waste is known,
+// so it is only fit for benchmarks and tests.
+func NewKZGSRS(ccs constraint.ConstraintSystem) (kzg.SRS, error) {
+	return test.NewKZGSRS(ccs)
+}
+
+func SetupPlonk(ccs constraint.ConstraintSystem, srs kzg.SRS) (plonk.ProvingKey, plonk.VerifyingKey, error) {
+	return plonk.Setup(ccs, srs)
+}
+
+func ProvePlonk(ccs constraint.ConstraintSystem, pk plonk.ProvingKey, fullWitness witness.Witness) (plonk.Proof, error) {
+	return plonk.Prove(ccs, pk, fullWitness)
+}
+
+func VerifyPlonk(proof plonk.Proof, vk plonk.VerifyingKey, publicWitness witness.Witness) error {
+	return plonk.Verify(proof, vk, publicWitness)
+}
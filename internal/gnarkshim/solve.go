@@ -0,0 +1,20 @@
+//go:build !js || !wasm
+
+package gnarkshim
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+var _ func(frontend.Circuit, frontend.Circuit, *big.Int, ...test.TestEngineOption) error = test.IsSolved
+
+// IsSolved runs circuit on assignment over the scalar field of curve in the
+// test engine of gnark, which evaluates the constraints as they are defined
+// instead of compiling them, and returns the first one that fails.
+func IsSolved(curve ecc.ID, circuit, assignment frontend.Circuit) error {
+	return test.IsSolved(circuit, assignment, curve.ScalarField())
+}
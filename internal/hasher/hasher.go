@@ -0,0 +1,222 @@
+// Package hasher lets the circuits and the code outside of them agree on a
+// snark-friendly hash without naming it.
+//
+// A commitment is computed twice: by the client, outside of the circuit, and
+// by the circuit, which checks it. Both sides take their hash from the same
+// HashConfig, so substituting the hash (Poseidon, Pedersen, ...) only takes
+// registering it.
+package hasher
+
+import (
+	"fmt"
+	gohash "hash"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+)
+
+// CircuitHasher is a hash computed in a circuit. It has the methods of
+// mimc.MiMC: the inputs are field elements and so is the digest.
+type CircuitHasher interface {
+	Write(data ...frontend.Variable)
+	Sum() frontend.Variable
+	Reset()
+}
+
+// NativeHasher is a hash computed outside of a circuit. Every input is written
+// as one big-endian block of BlockSize bytes, and the digest is the same field
+// element as the Sum of the CircuitHasher.
+type NativeHasher interface {
+	gohash.Hash
+}
+
+// Hash builds a hash in and out of the circuits.
+type Hash struct {
+	NewCircuit func(api frontend.API) (CircuitHasher, error)
+	NewNative  func(curve ecc.ID) (NativeHasher, error)
+}
+
+var hashes = map[string]Hash{
+	"mimc":     {NewCircuit: newMiMCCircuit, NewNative: newMiMCNative},
+	"poseidon": {NewCircuit: newPoseidonCircuit, NewNative: newPoseidonNative},
+}
+
+// testHashes are the hashes only tests select, by their HashConfig: Parse
+// does not know them, so that no flag or file can pick them.
+var testHashes = map[string]Hash{
+	"stub": {NewCircuit: newStubCircuit, NewNative: newStubNative},
+}
+
+// Register makes h available as HashConfig{Name: name}. It is not safe to call
+// concurrently with the use of a HashConfig.
+func Register(name string, h Hash) {
+	hashes[name] = h
+}
+
+// HashConfig selects a registered hash by its name. It is only a name so that
+// the circuits can carry it: the gnark test engine compares circuits with
+// reflect.DeepEqual, which never holds for func values.
+type HashConfig struct {
+	Name string
+}
+
+var (
+	// MiMC is the hash of the circuits of the repository. It is the zero
+	// HashConfig, so that the circuits and the fields built before the hash
+	// was configurable keep hashing with MiMC.
+	MiMC = HashConfig{}
+
+	// Poseidon takes fewer constraints than MiMC per hashed element, see
+	// poseidon.go for its parameters.
+	Poseidon = HashConfig{Name: "poseidon"}
+
+	// Stub is a linear hash, Horner's rule at StubBase. It is not collision
+	// resistant and only exercises the substitution of the hash in tests;
+	// Parse refuses it.
+	Stub = HashConfig{Name: "stub"}
+)
+
+// Parse returns the registered hash named name. The hashes for tests, such
+// as Stub, are refused.
+func Parse(name string) (HashConfig, error) {
+	if _, ok := testHashes[name]; ok {
+		return HashConfig{}, fmt.Errorf("hash %q is for tests only", name)
+	}
+	if _, ok := hashes[name]; !ok {
+		return HashConfig{}, fmt.Errorf("unknown hash %q", name)
+	}
+	if name == MiMC.String() {
+		return MiMC, nil
+	}
+	return HashConfig{Name: name}, nil
+}
+
+func (h HashConfig) String() string {
+	if h.Name == "" {
+		return "mimc"
+	}
+	return h.Name
+}
+
+func (h HashConfig) hash() (Hash, error) {
+	impl, ok := hashes[h.String()]
+	if !ok {
+		impl, ok = testHashes[h.String()]
+	}
+	if !ok {
+		return Hash{}, fmt.Errorf("unknown hash %q", h.Name)
+	}
+	return impl, nil
+}
+
+// Circuit returns the hash in the circuit of api.
+func (h HashConfig) Circuit(api frontend.API) (CircuitHasher, error) {
+	impl, err := h.hash()
+	if err != nil {
+		return nil, err
+	}
+	return impl.NewCircuit(api)
+}
+
+// Native returns the hash over the scalar field of curve.
+func (h HashConfig) Native(curve ecc.ID) (NativeHasher, error) {
+	impl, err := h.hash()
+	if err != nil {
+		return nil, err
+	}
+	return impl.NewNative(curve)
+}
+
+func newMiMCCircuit(api frontend.API) (CircuitHasher, error) {
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+var mimcHashes = map[ecc.ID]hash.Hash{
+	ecc.BN254:     hash.MIMC_BN254,
+	ecc.BLS12_381: hash.MIMC_BLS12_381,
+	ecc.BLS12_377: hash.MIMC_BLS12_377,
+	ecc.BW6_761:   hash.MIMC_BW6_761,
+	ecc.BLS24_315: hash.MIMC_BLS24_315,
+	ecc.BLS24_317: hash.MIMC_BLS24_317,
+	ecc.BW6_633:   hash.MIMC_BW6_633,
+}
+
+func newMiMCNative(curve ecc.ID) (NativeHasher, error) {
+	h, ok := mimcHashes[curve]
+	if !ok {
+		return nil, fmt.Errorf("no MiMC over the scalar field of %v", curve)
+	}
+	return h.New(), nil
+}
+
+// StubBase is the base of the polynomial of Stub.
+const StubBase = 7
+
+// stubCircuit computes digest = digest*StubBase + x for every input x.
+type stubCircuit struct {
+	api    frontend.API
+	digest frontend.Variable
+}
+
+func newStubCircuit(api frontend.API) (CircuitHasher, error) {
+	return &stubCircuit{api: api, digest: 0}, nil
+}
+
+func (h *stubCircuit) Write(data ...frontend.Variable) {
+	for _, x := range data {
+		h.digest = h.api.Add(h.api.Mul(h.digest, StubBase), x)
+	}
+}
+
+func (h *stubCircuit) Sum() frontend.Variable { return h.digest }
+
+func (h *stubCircuit) Reset() { h.digest = 0 }
+
+// stubNative is stubCircuit over big.Int, reduced modulo the scalar field.
+type stubNative struct {
+	modulus *big.Int
+	digest  big.Int
+	buf     []byte
+}
+
+func newStubNative(curve ecc.ID) (NativeHasher, error) {
+	modulus := curve.ScalarField()
+	if modulus == nil {
+		return nil, fmt.Errorf("unknown curve %v", curve)
+	}
+	return &stubNative{modulus: modulus}, nil
+}
+
+func (h *stubNative) BlockSize() int { return (h.modulus.BitLen() + 7) / 8 }
+
+func (h *stubNative) Size() int { return h.BlockSize() }
+
+// Write absorbs every complete block of p; the rest waits for the next Write.
+func (h *stubNative) Write(p []byte) (int, error) {
+	h.buf = append(h.buf, p...)
+	var x big.Int
+	for len(h.buf) >= h.BlockSize() {
+		x.SetBytes(h.buf[:h.BlockSize()])
+		h.digest.Mul(&h.digest, big.NewInt(StubBase))
+		h.digest.Add(&h.digest, &x)
+		h.digest.Mod(&h.digest, h.modulus)
+		h.buf = h.buf[h.BlockSize():]
+	}
+	return len(p), nil
+}
+
+func (h *stubNative) Sum(b []byte) []byte {
+	return append(b, h.digest.FillBytes(make([]byte, h.Size()))...)
+}
+
+func (h *stubNative) Reset() {
+	h.digest.SetUint64(0)
+	h.buf = nil
+}
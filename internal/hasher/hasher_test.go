@@ -0,0 +1,129 @@
+package hasher
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// digestCircuit checks that Digest is the hash of In.
+type digestCircuit struct {
+	In     []frontend.Variable
+	Digest frontend.Variable `gnark:",public"`
+	Hash   HashConfig        `gnark:"-"`
+}
+
+func (circuit *digestCircuit) Define(api frontend.API) error {
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	h.Write(circuit.In...)
+	api.AssertIsEqual(circuit.Digest, h.Sum())
+	return nil
+}
+
+// nativeDigest hashes vals outside of the circuit, one 32-byte block each.
+func nativeDigest(t *testing.T, h HashConfig, curve ecc.ID, vals []*big.Int) *big.Int {
+	native, err := h.Native(curve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b [32]byte
+	for _, v := range vals {
+		native.Write(v.FillBytes(b[:]))
+	}
+	return new(big.Int).SetBytes(native.Sum(nil))
+}
+
+func TestCircuitMatchesNative(t *testing.T) {
+	vals := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(1000)}
+	in := make([]frontend.Variable, len(vals))
+	for i := range vals {
+		in[i] = vals[i]
+	}
+	for _, h := range []HashConfig{MiMC, Poseidon, Stub} {
+		for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+			if h == Poseidon && curve != ecc.BN254 {
+				continue
+			}
+			digest := nativeDigest(t, h, curve, vals)
+			circuit := digestCircuit{In: make([]frontend.Variable, len(vals)), Hash: h}
+			assignment := digestCircuit{In: in, Digest: digest}
+			if err := test.IsSolved(&circuit, &assignment, curve.ScalarField()); err != nil {
+				t.Fatalf("%v on %v: %v", h, curve, err)
+			}
+
+			assignment.Digest = new(big.Int).Add(digest, big.NewInt(1))
+			if err := test.IsSolved(&circuit, &assignment, curve.ScalarField()); err == nil {
+				t.Fatalf("%v on %v: a wrong digest was accepted", h, curve)
+			}
+		}
+	}
+}
+
+func TestStubDiffersFromMiMC(t *testing.T) {
+	vals := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	if nativeDigest(t, MiMC, ecc.BN254, vals).Cmp(nativeDigest(t, Stub, ecc.BN254, vals)) == 0 {
+		t.Fatal("the stub digest is the MiMC digest")
+	}
+	// 1*7 + 2
+	if got := nativeDigest(t, Stub, ecc.BN254, vals); got.Cmp(big.NewInt(9)) != 0 {
+		t.Fatalf("stub digest %v, expected 9", got)
+	}
+}
+
+func TestPoseidonPadding(t *testing.T) {
+	one := []*big.Int{big.NewInt(5)}
+	two := []*big.Int{big.NewInt(5), big.NewInt(0)}
+	if nativeDigest(t, Poseidon, ecc.BN254, one).Cmp(nativeDigest(t, Poseidon, ecc.BN254, two)) == 0 {
+		t.Fatal("a trailing zero does not change the digest")
+	}
+	if nativeDigest(t, Poseidon, ecc.BN254, one).Cmp(nativeDigest(t, MiMC, ecc.BN254, one)) == 0 {
+		t.Fatal("the Poseidon digest is the MiMC digest")
+	}
+}
+
+// TestPoseidonKnownAnswer checks the permutation against circomlib, whose
+// poseidon([1, 2]) is the first element of the permutation of [0, 1, 2].
+func TestPoseidonKnownAnswer(t *testing.T) {
+	native, err := Poseidon.Native(ecc.BN254)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := native.(*poseidonNative)
+	var state [poseidonWidth]big.Int
+	state[1].SetInt64(1)
+	state[2].SetInt64(2)
+	h.permute(&state)
+	want, _ := new(big.Int).SetString("115cc0f5e7d690413df64c6b9662e9cf2a3617f2743245519e19607a4417189a", 16)
+	if state[0].Cmp(want) != 0 {
+		t.Fatalf("poseidon([1, 2]) = %x, expected %x", &state[0], want)
+	}
+
+	if _, err := Poseidon.Native(ecc.BLS12_381); err == nil {
+		t.Fatal("no error for poseidon over bls12-381")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, h := range []HashConfig{MiMC, Poseidon} {
+		got, err := Parse(h.String())
+		if err != nil || got != h {
+			t.Fatalf("Parse(%q) = %v, %v", h, got, err)
+		}
+	}
+	if _, err := Parse("sha256"); err == nil {
+		t.Fatal("an unknown hash was accepted")
+	}
+	// the stub hash is linear: no flag may select it
+	if _, err := Parse(Stub.String()); err == nil {
+		t.Fatal("the stub hash was accepted")
+	}
+	if (HashConfig{}).String() != "mimc" {
+		t.Fatal("the zero HashConfig is not MiMC")
+	}
+}
@@ -0,0 +1,333 @@
+package hasher
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+// The Poseidon instance is the one of circomlib for two inputs: a
+// permutation of width 3 over the scalar field of BN254 with the x^5 S-box, 8
+// full and 57 partial rounds, the parameters of the Poseidon paper at 128
+// bits of security. It is used as a sponge of rate 2 and capacity 1, so it
+// absorbs two field elements per permutation, against one per MiMC
+// permutation, and a partial round has a single S-box.
+//
+// The round constants and the MDS matrix are those of the reference
+// implementation, drawn from the Grain LFSR seeded with the parameters of the
+// instance (generate_parameters_grain.sage of the Poseidon authors). The
+// permutation of [0, 1, 2] is circomlib's poseidon([1, 2]), see
+// TestPoseidonKnownAnswer. No other field has an instance checked against
+// circomlib, so the hash is only available over BN254.
+const (
+	poseidonWidth         = 3
+	poseidonRate          = poseidonWidth - 1
+	poseidonFullRounds    = 8
+	poseidonPartialRounds = 57
+	poseidonRounds        = poseidonFullRounds + poseidonPartialRounds
+)
+
+// poseidonParams are the constants of the permutation over one field.
+type poseidonParams struct {
+	modulus   *big.Int
+	constants [poseidonRounds][poseidonWidth]*big.Int
+	mds       [poseidonWidth][poseidonWidth]*big.Int
+}
+
+var (
+	poseidonOnce    sync.Once
+	poseidonBN254   *poseidonParams
+	poseidonModulus = ecc.BN254.ScalarField()
+)
+
+// getPoseidonParams returns the constants over the field of the given modulus,
+// computing them on the first call.
+func getPoseidonParams(modulus *big.Int) (*poseidonParams, error) {
+	if modulus.Cmp(poseidonModulus) != 0 {
+		return nil, fmt.Errorf("poseidon is only defined over the scalar field of bn254, not the field of modulus %v", modulus)
+	}
+	poseidonOnce.Do(func() {
+		poseidonBN254 = newPoseidonParams(poseidonModulus)
+	})
+	return poseidonBN254, nil
+}
+
+// newPoseidonParams draws the constants over the field of modulus from the
+// Grain LFSR, as generate_parameters_grain.sage does: first the round
+// constants, by rejection sampling, then the x and y of the Cauchy matrix
+// 1/(x_i + y_j), reduced modulo the field.
+func newPoseidonParams(modulus *big.Int) *poseidonParams {
+	p := &poseidonParams{modulus: new(big.Int).Set(modulus)}
+	n := modulus.BitLen()
+	g := newGrain(n, poseidonWidth, poseidonFullRounds, poseidonPartialRounds)
+	for r := 0; r < poseidonRounds; r++ {
+		for i := 0; i < poseidonWidth; i++ {
+			c := g.field(n)
+			for c.Cmp(modulus) >= 0 {
+				c = g.field(n)
+			}
+			p.constants[r][i] = c
+		}
+	}
+	for {
+		var xy [2 * poseidonWidth]*big.Int
+		for distinct := false; !distinct; {
+			seen := map[string]bool{}
+			distinct = true
+			for i := range xy {
+				xy[i] = g.field(n)
+				xy[i].Mod(xy[i], modulus)
+				if seen[xy[i].String()] {
+					distinct = false
+				}
+				seen[xy[i].String()] = true
+			}
+		}
+		ok := true
+		for i := 0; i < poseidonWidth && ok; i++ {
+			for j := 0; j < poseidonWidth && ok; j++ {
+				m := new(big.Int).Add(xy[i], xy[poseidonWidth+j])
+				ok = m.ModInverse(m, modulus) != nil
+				p.mds[i][j] = m
+			}
+		}
+		if ok {
+			return p
+		}
+	}
+}
+
+// grain is the self-shrinking Grain LFSR of the Poseidon reference
+// implementation.
+type grain struct {
+	state [80]byte
+}
+
+// newGrain seeds the LFSR with the instance: a prime field of n bits, the
+// x^alpha S-box, the width t and the numbers of rounds, and discards its
+// first 160 bits.
+func newGrain(n, t, fullRounds, partialRounds int) *grain {
+	g := &grain{}
+	pos := 0
+	put := func(v, width int) {
+		for i := width - 1; i >= 0; i-- {
+			g.state[pos] = byte(v>>i) & 1
+			pos++
+		}
+	}
+	put(1, 2) // prime field
+	put(0, 4) // x^alpha
+	put(n, 12)
+	put(t, 12)
+	put(fullRounds, 10)
+	put(partialRounds, 10)
+	put(1<<30-1, 30)
+	for i := 0; i < 160; i++ {
+		g.next()
+	}
+	return g
+}
+
+func (g *grain) next() byte {
+	b := g.state[62] ^ g.state[51] ^ g.state[38] ^ g.state[23] ^ g.state[13] ^ g.state[0]
+	copy(g.state[:], g.state[1:])
+	g.state[len(g.state)-1] = b
+	return b
+}
+
+// bit returns the next output bit: of every pair of bits, the second one
+// is kept when the first one is 1.
+func (g *grain) bit() byte {
+	for g.next() == 0 {
+		g.next()
+	}
+	return g.next()
+}
+
+// field returns the integer of the next n bits, most significant first.
+func (g *grain) field(n int) *big.Int {
+	x := new(big.Int)
+	for i := 0; i < n; i++ {
+		x.Lsh(x, 1)
+		if g.bit() == 1 {
+			x.SetBit(x, 0, 1)
+		}
+	}
+	return x
+}
+
+// isFullRound reports whether round r applies the S-box to the whole state;
+// the partial rounds sit between two halves of the full rounds.
+func isFullRound(r int) bool {
+	return r < poseidonFullRounds/2 || r >= poseidonFullRounds/2+poseidonPartialRounds
+}
+
+// poseidonCircuit absorbs the inputs into state[1:] and pads the last block
+// with a single 1 when Sum is called, so that inputs of different lengths do
+// not collide. The digest is state[1].
+type poseidonCircuit struct {
+	api    frontend.API
+	params *poseidonParams
+	state  [poseidonWidth]frontend.Variable
+	pos    int
+}
+
+func newPoseidonCircuit(api frontend.API) (CircuitHasher, error) {
+	params, err := getPoseidonParams(api.Compiler().Field())
+	if err != nil {
+		return nil, err
+	}
+	h := &poseidonCircuit{api: api, params: params}
+	h.Reset()
+	return h, nil
+}
+
+func (h *poseidonCircuit) permute(state *[poseidonWidth]frontend.Variable) {
+	api := h.api
+	sbox := func(x frontend.Variable) frontend.Variable {
+		x2 := api.Mul(x, x)
+		return api.Mul(api.Mul(x2, x2), x)
+	}
+	for r := 0; r < poseidonRounds; r++ {
+		for i := 0; i < poseidonWidth; i++ {
+			state[i] = api.Add(state[i], h.params.constants[r][i])
+		}
+		if isFullRound(r) {
+			for i := 0; i < poseidonWidth; i++ {
+				state[i] = sbox(state[i])
+			}
+		} else {
+			state[0] = sbox(state[0])
+		}
+		var mixed [poseidonWidth]frontend.Variable
+		for i := 0; i < poseidonWidth; i++ {
+			mixed[i] = 0
+			for j := 0; j < poseidonWidth; j++ {
+				mixed[i] = api.Add(mixed[i], api.Mul(h.params.mds[i][j], state[j]))
+			}
+		}
+		*state = mixed
+	}
+}
+
+func (h *poseidonCircuit) Write(data ...frontend.Variable) {
+	for _, x := range data {
+		h.state[1+h.pos] = h.api.Add(h.state[1+h.pos], x)
+		h.pos++
+		if h.pos == poseidonRate {
+			h.permute(&h.state)
+			h.pos = 0
+		}
+	}
+}
+
+func (h *poseidonCircuit) Sum() frontend.Variable {
+	state := h.state
+	state[1+h.pos] = h.api.Add(state[1+h.pos], 1)
+	h.permute(&state)
+	return state[1]
+}
+
+func (h *poseidonCircuit) Reset() {
+	for i := range h.state {
+		h.state[i] = 0
+	}
+	h.pos = 0
+}
+
+// poseidonNative is poseidonCircuit over big.Int, reduced modulo the scalar
+// field.
+type poseidonNative struct {
+	params *poseidonParams
+	state  [poseidonWidth]big.Int
+	pos    int
+	buf    []byte
+}
+
+func newPoseidonNative(curve ecc.ID) (NativeHasher, error) {
+	modulus := curve.ScalarField()
+	if modulus == nil {
+		return nil, fmt.Errorf("unknown curve %v", curve)
+	}
+	params, err := getPoseidonParams(modulus)
+	if err != nil {
+		return nil, err
+	}
+	return &poseidonNative{params: params}, nil
+}
+
+func (h *poseidonNative) permute(state *[poseidonWidth]big.Int) {
+	modulus := h.params.modulus
+	sbox := func(x *big.Int) {
+		var x2, x4 big.Int
+		x2.Mul(x, x).Mod(&x2, modulus)
+		x4.Mul(&x2, &x2).Mod(&x4, modulus)
+		x.Mul(x, &x4).Mod(x, modulus)
+	}
+	for r := 0; r < poseidonRounds; r++ {
+		for i := 0; i < poseidonWidth; i++ {
+			state[i].Add(&state[i], h.params.constants[r][i]).Mod(&state[i], modulus)
+		}
+		if isFullRound(r) {
+			for i := 0; i < poseidonWidth; i++ {
+				sbox(&state[i])
+			}
+		} else {
+			sbox(&state[0])
+		}
+		var mixed [poseidonWidth]big.Int
+		var t big.Int
+		for i := 0; i < poseidonWidth; i++ {
+			for j := 0; j < poseidonWidth; j++ {
+				t.Mul(h.params.mds[i][j], &state[j])
+				mixed[i].Add(&mixed[i], &t)
+			}
+			mixed[i].Mod(&mixed[i], modulus)
+		}
+		*state = mixed
+	}
+}
+
+func (h *poseidonNative) BlockSize() int { return (h.params.modulus.BitLen() + 7) / 8 }
+
+func (h *poseidonNative) Size() int { return h.BlockSize() }
+
+// Write absorbs every complete block of p; the rest waits for the next Write.
+func (h *poseidonNative) Write(p []byte) (int, error) {
+	h.buf = append(h.buf, p...)
+	var x big.Int
+	for len(h.buf) >= h.BlockSize() {
+		x.SetBytes(h.buf[:h.BlockSize()])
+		s := &h.state[1+h.pos]
+		s.Add(s, &x).Mod(s, h.params.modulus)
+		h.pos++
+		if h.pos == poseidonRate {
+			h.permute(&h.state)
+			h.pos = 0
+		}
+		h.buf = h.buf[h.BlockSize():]
+	}
+	return len(p), nil
+}
+
+func (h *poseidonNative) Sum(b []byte) []byte {
+	var state [poseidonWidth]big.Int
+	for i := range state {
+		state[i].Set(&h.state[i])
+	}
+	s := &state[1+h.pos]
+	s.Add(s, big.NewInt(1)).Mod(s, h.params.modulus)
+	h.permute(&state)
+	return append(b, state[1].FillBytes(make([]byte, h.Size()))...)
+}
+
+func (h *poseidonNative) Reset() {
+	for i := range h.state {
+		h.state[i].SetUint64(0)
+	}
+	h.pos = 0
+	h.buf = nil
+}
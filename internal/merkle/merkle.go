@@ -0,0 +1,204 @@
+// Package merkle is the MiMC Merkle tree of the protocols: the tree of the
+// eligible voters, and the tree of the commitments of an epoch whose root
+// the server publishes.
+//
+// The hash of a leaf, of an inner node and of a padding leaf starts with its
+// own tag, so that no leaf passes for an inner node nor for the padding: a
+// leaf is the MiMC hash of LeafTag and the leaf, a parent the hash of NodeTag,
+// its left then its right child. The leaves are padded to a power of two with
+// the hash of PadTag alone, which is the hash of no leaf. A path from a leaf
+// to the root is checked natively with Verify and in a circuit with
+// VerifyMerklePathInCircuit.
+package merkle
+
+import (
+	"errors"
+	"fmt"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+)
+
+// The tags of the hashes of the tree.
+const (
+	LeafTag = 0
+	NodeTag = 1
+	PadTag  = 2
+)
+
+var (
+	ErrMalformedPath = errors.New("merkle: malformed path")
+	ErrNotIncluded   = errors.New("merkle: the path does not lead to the root")
+)
+
+// hashTagged is the MiMC hash of tag then of elems.
+func hashTagged(tag uint64, elems ...*fr_bn254.Element) fr_bn254.Element {
+	goMimc := hash.MIMC_BN254.New()
+	var t fr_bn254.Element
+	t.SetUint64(tag)
+	b := t.Bytes()
+	goMimc.Write(b[:])
+	for _, e := range elems {
+		b = e.Bytes()
+		goMimc.Write(b[:])
+	}
+	var res fr_bn254.Element
+	res.SetBytes(goMimc.Sum(nil))
+	return res
+}
+
+// HashLeaf is the node of the leaf.
+func HashLeaf(leaf *fr_bn254.Element) fr_bn254.Element {
+	return hashTagged(LeafTag, leaf)
+}
+
+// HashPair is the parent of the nodes left and right.
+func HashPair(left, right *fr_bn254.Element) fr_bn254.Element {
+	return hashTagged(NodeTag, left, right)
+}
+
+// Padding is the node of a padding leaf.
+func Padding() fr_bn254.Element {
+	return hashTagged(PadTag)
+}
+
+// Tree is a Merkle tree. Leaves are its leaves, Levels[0] their nodes with
+// the padding and the last level is the root.
+type Tree struct {
+	Leaves []fr_bn254.Element
+	Levels [][]fr_bn254.Element
+}
+
+// BuildTree builds the tree of leaves. The tree of no leaf is the tree of
+// one padding leaf.
+func BuildTree(leaves []fr_bn254.Element) *Tree {
+	width := 1
+	for width < len(leaves) {
+		width *= 2
+	}
+	nodes := make([]fr_bn254.Element, width)
+	for i := range nodes {
+		if i < len(leaves) {
+			nodes[i] = HashLeaf(&leaves[i])
+		} else {
+			nodes[i] = Padding()
+		}
+	}
+	t := &Tree{Leaves: append([]fr_bn254.Element(nil), leaves...), Levels: [][]fr_bn254.Element{nodes}}
+	for level := nodes; len(level) > 1; {
+		parents := make([]fr_bn254.Element, len(level)/2)
+		for i := 0; i < len(parents); i++ {
+			parents[i] = HashPair(&level[2*i], &level[2*i+1])
+		}
+		t.Levels = append(t.Levels, parents)
+		level = parents
+	}
+	return t
+}
+
+// Root returns the root of t.
+func (t *Tree) Root() fr_bn254.Element {
+	return t.Levels[len(t.Levels)-1][0]
+}
+
+// Depth is the number of levels above the leaves, the length of a path.
+func (t *Tree) Depth() int {
+	return len(t.Levels) - 1
+}
+
+// Path is the path from a leaf to the root: the sibling at every level and
+// whether the node is the right child (1) or the left one (0).
+type Path struct {
+	Siblings []fr_bn254.Element
+	Dirs     []int
+}
+
+// Prove returns the path from the leaf index to the root.
+func (t *Tree) Prove(index int) (Path, error) {
+	if index < 0 || index >= len(t.Leaves) {
+		return Path{}, fmt.Errorf("merkle: leaf %v out of the %v leaves", index, len(t.Leaves))
+	}
+	var p Path
+	for level := 0; level < t.Depth(); level++ {
+		p.Siblings = append(p.Siblings, t.Levels[level][index^1])
+		p.Dirs = append(p.Dirs, index&1)
+		index /= 2
+	}
+	return p, nil
+}
+
+// Verify checks that path goes from leaf to root. It returns
+// ErrMalformedPath for a path with more or fewer directions than siblings or
+// a direction other than 0 and 1, and ErrNotIncluded for a path to another
+// root.
+func Verify(leaf fr_bn254.Element, path Path, root fr_bn254.Element) error {
+	if len(path.Dirs) != len(path.Siblings) {
+		return fmt.Errorf("%w: %v directions for %v siblings", ErrMalformedPath, len(path.Dirs), len(path.Siblings))
+	}
+	node := HashLeaf(&leaf)
+	for i := range path.Siblings {
+		switch path.Dirs[i] {
+		case 0:
+			node = HashPair(&node, &path.Siblings[i])
+		case 1:
+			node = HashPair(&path.Siblings[i], &node)
+		default:
+			return fmt.Errorf("%w: direction %v at level %v", ErrMalformedPath, path.Dirs[i], i)
+		}
+	}
+	if !node.Equal(&root) {
+		return ErrNotIncluded
+	}
+	return nil
+}
+
+// CircuitPath is a Path in a circuit, of the depth of the tree.
+type CircuitPath struct {
+	Siblings []frontend.Variable
+	Dirs     []frontend.Variable
+}
+
+// NewCircuitPath allocates an empty path of a tree of the given depth.
+func NewCircuitPath(depth int) CircuitPath {
+	return CircuitPath{Siblings: make([]frontend.Variable, depth), Dirs: make([]frontend.Variable, depth)}
+}
+
+// Assign returns the assignment of p.
+func (p Path) Assign() CircuitPath {
+	c := NewCircuitPath(len(p.Siblings))
+	for i := range p.Siblings {
+		c.Siblings[i] = p.Siblings[i]
+		c.Dirs[i] = p.Dirs[i]
+	}
+	return c
+}
+
+// VerifyMerklePathInCircuit asserts that path goes from leaf to root. It
+// returns ErrMalformedPath for a path with more or fewer directions than
+// siblings.
+func VerifyMerklePathInCircuit(api frontend.API, leaf frontend.Variable, path CircuitPath, root frontend.Variable) error {
+	if len(path.Dirs) != len(path.Siblings) {
+		return fmt.Errorf("%w: %v directions for %v siblings", ErrMalformedPath, len(path.Dirs), len(path.Siblings))
+	}
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	h.Write(LeafTag, leaf)
+	node := h.Sum()
+	for i := 0; i < len(path.Siblings); i++ {
+		api.AssertIsBoolean(path.Dirs[i])
+		left := api.Select(path.Dirs[i], path.Siblings[i], node)
+		right := api.Select(path.Dirs[i], node, path.Siblings[i])
+		h, err := mimc.NewMiMC(api)
+		if err != nil {
+			return err
+		}
+		h.Write(NodeTag, left, right)
+		node = h.Sum()
+	}
+	api.AssertIsEqual(node, root)
+	return nil
+}
@@ -0,0 +1,133 @@
+package merkle
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+func leaves(n int) []fr_bn254.Element {
+	l := make([]fr_bn254.Element, n)
+	for i := range l {
+		l[i].SetUint64(uint64(1000 + i))
+	}
+	return l
+}
+
+func TestProve(t *testing.T) {
+	for _, n := range []int{1, 2, 5, 7, 8} {
+		l := leaves(n)
+		tree := BuildTree(l)
+		root := tree.Root()
+		// the first and the last leaves, and one in the middle
+		for _, i := range []int{0, n / 2, n - 1} {
+			path, err := tree.Prove(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(path.Siblings) != tree.Depth() {
+				t.Fatalf("%v leaves: a path of %v siblings in a tree of depth %v", n, len(path.Siblings), tree.Depth())
+			}
+			if err := Verify(l[i], path, root); err != nil {
+				t.Fatalf("%v leaves: leaf %v: %v", n, i, err)
+			}
+			var tampered fr_bn254.Element
+			tampered.SetUint64(1)
+			tampered.Add(&tampered, &l[i])
+			if err := Verify(tampered, path, root); !errors.Is(err, ErrNotIncluded) {
+				t.Fatalf("%v leaves: a tampered leaf %v: got %v, expected ErrNotIncluded", n, i, err)
+			}
+		}
+		if _, err := tree.Prove(n); err == nil {
+			t.Fatalf("%v leaves: no error for the path of a padding leaf", n)
+		}
+		if _, err := tree.Prove(-1); err == nil {
+			t.Fatalf("%v leaves: no error for leaf -1", n)
+		}
+	}
+
+	// the padding is part of the root
+	if BuildTree(leaves(3)).Root() == BuildTree(leaves(4)).Root() {
+		t.Fatal("3 and 4 leaves have the same root")
+	}
+}
+
+// TestPadding proves the membership of zero, the padding of the old tree, and
+// of an inner node passed for a leaf: neither verifies.
+func TestPadding(t *testing.T) {
+	tree := BuildTree(leaves(3))
+	root := tree.Root()
+	// the path of the padding leaf, built by hand
+	padPath := Path{Siblings: []fr_bn254.Element{tree.Levels[0][2], tree.Levels[1][0]}, Dirs: []int{1, 1}}
+	for _, leaf := range []fr_bn254.Element{{}, Padding()} {
+		if err := Verify(leaf, padPath, root); !errors.Is(err, ErrNotIncluded) {
+			t.Fatalf("padding leaf %v: got %v, expected ErrNotIncluded", leaf.String(), err)
+		}
+	}
+	// the left inner node of level 1, with the path of level 1 upward
+	inner := Path{Siblings: []fr_bn254.Element{tree.Levels[1][1]}, Dirs: []int{0}}
+	if err := Verify(tree.Levels[1][0], inner, root); !errors.Is(err, ErrNotIncluded) {
+		t.Fatalf("an inner node as a leaf: got %v, expected ErrNotIncluded", err)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	l := leaves(4)
+	tree := BuildTree(l)
+	path, err := tree.Prove(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, p := range map[string]Path{
+		"fewer directions": {Siblings: path.Siblings, Dirs: path.Dirs[:1]},
+		"more directions":  {Siblings: path.Siblings, Dirs: append(append([]int(nil), path.Dirs...), 0)},
+		"direction 2":      {Siblings: path.Siblings, Dirs: []int{2, 0}},
+	} {
+		if err := Verify(l[1], p, tree.Root()); !errors.Is(err, ErrMalformedPath) {
+			t.Fatalf("%v: got %v, expected ErrMalformedPath", name, err)
+		}
+	}
+}
+
+type pathCircuit struct {
+	Leaf frontend.Variable
+	Path CircuitPath
+	Root frontend.Variable `gnark:",public"`
+}
+
+func (c *pathCircuit) Define(api frontend.API) error {
+	return VerifyMerklePathInCircuit(api, c.Leaf, c.Path, c.Root)
+}
+
+func TestVerifyMerklePathInCircuit(t *testing.T) {
+	l := leaves(5)
+	tree := BuildTree(l)
+	circuit := pathCircuit{Path: NewCircuitPath(tree.Depth())}
+	for _, i := range []int{0, 3, 4} {
+		path, err := tree.Prove(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assignment := pathCircuit{Leaf: l[i], Path: path.Assign(), Root: tree.Root()}
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("leaf %v: %v", i, err)
+		}
+		// the path of another leaf
+		assignment.Leaf = l[(i+1)%len(l)]
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("leaf %v: a tampered leaf was accepted", i)
+		}
+	}
+}
+
+func TestVerifyMerklePathInCircuitMalformed(t *testing.T) {
+	circuit := pathCircuit{Path: CircuitPath{Siblings: make([]frontend.Variable, 2), Dirs: make([]frontend.Variable, 1)}}
+	assignment := pathCircuit{Leaf: 0, Path: CircuitPath{Siblings: []frontend.Variable{0, 0}, Dirs: []frontend.Variable{0}}, Root: 0}
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); !errors.Is(err, ErrMalformedPath) {
+		t.Fatalf("got %v, expected ErrMalformedPath", err)
+	}
+}
@@ -0,0 +1,222 @@
+// Package polyeval evaluates the product the server checks the shuffler with,
+// (x_0 + r) * ... * (x_{n-1} + r), without holding the elements in memory.
+//
+// An Evaluator takes the elements one at a time, or as a stream of 32-byte
+// field elements, and keeps only the running product. Evaluators of disjoint
+// parts of the input, at the same point, merge into the evaluator of their
+// union, since the product does not depend on the order of the elements; Slice
+// and Reader split their input into chunks this way and evaluate the chunks in
+// parallel. The product of the dummies is the evaluation at r = 0.
+package polyeval
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// DefaultChunk is the number of elements of a chunk, 2 MiB of input.
+const DefaultChunk = 1 << 16
+
+var (
+	ErrTruncated    = errors.New("polyeval: the stream ends inside an element")
+	ErrNonCanonical = errors.New("polyeval: the stream holds an element above the modulus")
+	ErrPoint        = errors.New("polyeval: merging evaluators of different points")
+)
+
+// Evaluator is the running product of (x + r) over the elements x it was
+// given. The zero value is not usable, see New.
+type Evaluator struct {
+	r    fr_bn254.Element
+	prod fr_bn254.Element
+	n    uint64
+}
+
+// New returns the evaluator at r of no element, whose product is one.
+func New(r fr_bn254.Element) *Evaluator {
+	return &Evaluator{r: r, prod: fr_bn254.One()}
+}
+
+// Add multiplies the product by (x + r) for every x of xs.
+func (e *Evaluator) Add(xs ...fr_bn254.Element) {
+	for i := range xs {
+		var tmp fr_bn254.Element
+		tmp.Add(&xs[i], &e.r)
+		e.prod.Mul(&e.prod, &tmp)
+	}
+	e.n += uint64(len(xs))
+}
+
+// Merge adds the elements o was given to e. Both must evaluate at the same
+// point.
+func (e *Evaluator) Merge(o *Evaluator) error {
+	if !e.r.Equal(&o.r) {
+		return ErrPoint
+	}
+	e.prod.Mul(&e.prod, &o.prod)
+	e.n += o.n
+	return nil
+}
+
+// Prod returns the product of (x + r) over the elements given so far.
+func (e *Evaluator) Prod() fr_bn254.Element {
+	return e.prod
+}
+
+// Len returns the number of elements given so far.
+func (e *Evaluator) Len() uint64 {
+	return e.n
+}
+
+// ReadFrom adds the elements of rd, 32-byte big-endian canonical encodings, up
+// to the end of the stream. It implements io.ReaderFrom.
+func (e *Evaluator) ReadFrom(rd io.Reader) (int64, error) {
+	buf := make([]byte, DefaultChunk*fr_bn254.Bytes)
+	var read int64
+	for {
+		n, err := io.ReadFull(rd, buf)
+		read += int64(n)
+		if n > 0 {
+			if addErr := e.addBytes(buf[:n], read-int64(n)); addErr != nil {
+				return read, addErr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return read, nil
+		}
+		if err != nil {
+			return read, err
+		}
+	}
+}
+
+// addBytes adds the elements encoded in b, found at offset of the stream.
+func (e *Evaluator) addBytes(b []byte, offset int64) error {
+	if len(b)%fr_bn254.Bytes != 0 {
+		return fmt.Errorf("%w: %v trailing bytes", ErrTruncated, len(b)%fr_bn254.Bytes)
+	}
+	for i := 0; i < len(b); i += fr_bn254.Bytes {
+		var x fr_bn254.Element
+		if err := x.SetBytesCanonical(b[i : i+fr_bn254.Bytes]); err != nil {
+			return fmt.Errorf("%w: element %v", ErrNonCanonical, (offset+int64(i))/fr_bn254.Bytes)
+		}
+		e.Add(x)
+	}
+	return nil
+}
+
+// Func evaluates at r the n elements at(0), ..., at(n-1) in chunks of chunk
+// elements on workers goroutines and merges the chunks. at is called once per
+// index, concurrently, so it may build the elements on the fly instead of
+// reading them from a slice.
+func Func(n int, at func(i int) fr_bn254.Element, r fr_bn254.Element, chunk int, workers int) fr_bn254.Element {
+	if chunk < 1 {
+		chunk = DefaultChunk
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	chunks := make(chan int)
+	partials := make([]*Evaluator, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		partials[w] = New(r)
+		wg.Add(1)
+		go func(e *Evaluator) {
+			defer wg.Done()
+			for lo := range chunks {
+				hi := min(lo+chunk, n)
+				for i := lo; i < hi; i++ {
+					e.Add(at(i))
+				}
+			}
+		}(partials[w])
+	}
+	for lo := 0; lo < n; lo += chunk {
+		chunks <- lo
+	}
+	close(chunks)
+	wg.Wait()
+
+	res := New(r)
+	for _, e := range partials {
+		res.Merge(e)
+	}
+	return res.Prod()
+}
+
+// Slice evaluates vec at r in chunks on workers goroutines, see Func. vec is
+// not copied nor changed.
+func Slice(vec []fr_bn254.Element, r fr_bn254.Element, chunk int, workers int) fr_bn254.Element {
+	return Func(len(vec), func(i int) fr_bn254.Element { return vec[i] }, r, chunk, workers)
+}
+
+// Reader evaluates at r the elements of rd, encoded as in ReadFrom, in chunks
+// of chunk elements on workers goroutines, and returns the product and the
+// number of elements. At most workers+1 chunks are held at a time, whatever
+// the length of the stream.
+func Reader(rd io.Reader, r fr_bn254.Element, chunk int, workers int) (fr_bn254.Element, uint64, error) {
+	if chunk < 1 {
+		chunk = DefaultChunk
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	type job struct {
+		buf    []byte
+		offset int64
+	}
+	free := make(chan []byte, workers+1)
+	for i := 0; i < workers+1; i++ {
+		free <- make([]byte, chunk*fr_bn254.Bytes)
+	}
+	jobs := make(chan job)
+	partials := make([]*Evaluator, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		partials[w] = New(r)
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for j := range jobs {
+				if errs[w] == nil {
+					errs[w] = partials[w].addBytes(j.buf, j.offset)
+				}
+				free <- j.buf[:cap(j.buf)]
+			}
+		}(w)
+	}
+
+	var readErr error
+	var offset int64
+	for readErr == nil {
+		buf := <-free
+		n, err := io.ReadFull(rd, buf)
+		if n > 0 {
+			jobs <- job{buf[:n], offset}
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		readErr = err
+	}
+	close(jobs)
+	wg.Wait()
+
+	if readErr != nil {
+		return fr_bn254.Element{}, 0, readErr
+	}
+	res := New(r)
+	for w := range partials {
+		if errs[w] != nil {
+			return fr_bn254.Element{}, 0, errs[w]
+		}
+		res.Merge(partials[w])
+	}
+	return res.Prod(), res.Len(), nil
+}
@@ -0,0 +1,194 @@
+package polyeval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// polyEval is the evaluation of the servers before the streaming one.
+func polyEval(vec []fr_bn254.Element, r fr_bn254.Element) fr_bn254.Element {
+	prod := fr_bn254.One()
+	for i := 0; i < len(vec); i++ {
+		tmp := vec[i]
+		tmp.Add(&tmp, &r)
+		prod.Mul(&prod, &tmp)
+	}
+	return prod
+}
+
+func randomVec(t testing.TB, n int) []fr_bn254.Element {
+	vec := make([]fr_bn254.Element, n)
+	for i := range vec {
+		if _, err := vec[i].SetRandom(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return vec
+}
+
+func encode(vec []fr_bn254.Element) []byte {
+	var buf bytes.Buffer
+	for i := range vec {
+		b := vec[i].Bytes()
+		buf.Write(b[:])
+	}
+	return buf.Bytes()
+}
+
+func TestStreamedEqualsPolyEval(t *testing.T) {
+	vec := randomVec(t, 1000)
+	r := randomVec(t, 1)[0]
+	want := polyEval(vec, r)
+
+	e := New(r)
+	for i := range vec {
+		e.Add(vec[i])
+	}
+	if got := e.Prod(); !got.Equal(&want) || e.Len() != uint64(len(vec)) {
+		t.Fatalf("one at a time: %v elements", e.Len())
+	}
+	if _, err := e.ReadFrom(bytes.NewReader(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chunk := range []int{1, 7, 1000, 4096} {
+		for _, workers := range []int{1, 3, 8} {
+			name := fmt.Sprintf("chunk %v, %v workers", chunk, workers)
+			if got := Slice(vec, r, chunk, workers); !got.Equal(&want) {
+				t.Fatalf("Slice, %v", name)
+			}
+			got, n, err := Reader(bytes.NewReader(encode(vec)), r, chunk, workers)
+			if err != nil {
+				t.Fatalf("Reader, %v: %v", name, err)
+			}
+			if !got.Equal(&want) || n != uint64(len(vec)) {
+				t.Fatalf("Reader, %v: %v elements", name, n)
+			}
+		}
+	}
+
+	e = New(r)
+	if _, err := e.ReadFrom(bytes.NewReader(encode(vec))); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Prod(); !got.Equal(&want) {
+		t.Fatal("ReadFrom")
+	}
+	// no element: the empty product
+	if got, one := Slice(nil, r, 0, 4), fr_bn254.One(); !got.Equal(&one) {
+		t.Fatal("empty Slice")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	vec := randomVec(t, 10)
+	r := randomVec(t, 1)[0]
+	a, b := New(r), New(r)
+	a.Add(vec[:4]...)
+	b.Add(vec[4:]...)
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := a.Prod(), polyEval(vec, r); !got.Equal(&want) || a.Len() != 10 {
+		t.Fatal("merged product")
+	}
+	if err := a.Merge(New(fr_bn254.NewElement(1))); !errors.Is(err, ErrPoint) {
+		t.Fatalf("got %v, expected ErrPoint", err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	vec := randomVec(t, 100)
+	r := randomVec(t, 1)[0]
+	data := encode(vec)
+
+	truncated := data[:len(data)-1]
+	if _, _, err := Reader(bytes.NewReader(truncated), r, 16, 4); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Reader, truncated: got %v, expected ErrTruncated", err)
+	}
+	if _, err := New(r).ReadFrom(bytes.NewReader(truncated)); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("ReadFrom, truncated: got %v, expected ErrTruncated", err)
+	}
+
+	nonCanonical := append([]byte(nil), data...)
+	copy(nonCanonical[50*fr_bn254.Bytes:], fr_bn254.Modulus().FillBytes(make([]byte, fr_bn254.Bytes)))
+	if _, _, err := Reader(bytes.NewReader(nonCanonical), r, 16, 4); !errors.Is(err, ErrNonCanonical) {
+		t.Fatalf("Reader, non-canonical: got %v, expected ErrNonCanonical", err)
+	}
+	if _, err := New(r).ReadFrom(bytes.NewReader(nonCanonical)); !errors.Is(err, ErrNonCanonical) {
+		t.Fatalf("ReadFrom, non-canonical: got %v, expected ErrNonCanonical", err)
+	}
+
+	broken := errors.New("broken pipe")
+	if _, _, err := Reader(io.MultiReader(bytes.NewReader(data), errReader{broken}), r, 16, 4); !errors.Is(err, broken) {
+		t.Fatalf("Reader, read error: got %v, expected %v", err, broken)
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// elementStream is a stream of n encoded elements generated on the fly, so
+// that the benchmarks measure the memory of the evaluation only.
+type elementStream struct {
+	n, i int
+	pos  int
+	cur  [fr_bn254.Bytes]byte
+}
+
+func (s *elementStream) Read(p []byte) (int, error) {
+	read := 0
+	for read < len(p) {
+		if s.pos == 0 {
+			if s.i == s.n {
+				break
+			}
+			x := fr_bn254.NewElement(uint64(s.i) + 1)
+			s.cur = x.Bytes()
+			s.i++
+		}
+		m := copy(p[read:], s.cur[s.pos:])
+		read += m
+		s.pos = (s.pos + m) % fr_bn254.Bytes
+	}
+	if read == 0 {
+		return 0, io.EOF
+	}
+	return read, nil
+}
+
+// BenchmarkReader streams up to 10^6 elements: the bytes allocated per
+// evaluation are the chunks of the workers, the same for every size.
+func BenchmarkReader(b *testing.B) {
+	r := fr_bn254.NewElement(7)
+	for _, n := range []int{10_000, 100_000, 1_000_000} {
+		b.Run(fmt.Sprintf("n=%v", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := Reader(&elementStream{n: n}, r, DefaultChunk, 4); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFunc evaluates up to 10^6 elements built on the fly, without
+// allocating per element.
+func BenchmarkFunc(b *testing.B) {
+	r := fr_bn254.NewElement(7)
+	for _, n := range []int{10_000, 100_000, 1_000_000} {
+		b.Run(fmt.Sprintf("n=%v", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Func(n, func(i int) fr_bn254.Element { return fr_bn254.NewElement(uint64(i) + 1) }, r, DefaultChunk, 4)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+// Package pool runs the jobs of the benchmark programs, one per client, on
+// a pool of goroutines.
+package pool
+
+import "sync"
+
+// RunAll calls job(i) for every i in [0, n) on a pool of workers goroutines,
+// at least one, waits for all the jobs and returns their errors by index.
+func RunAll(n int, workers int, job func(i int) error) []error {
+	if workers < 1 {
+		workers = 1
+	}
+	errs := make([]error, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = job(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return errs
+}
+
+// Run is RunAll returning the error of the smallest failing index, so the
+// outcome does not depend on the scheduling.
+func Run(n int, workers int, job func(i int) error) error {
+	errs := RunAll(n, workers, job)
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			return errs[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package pool
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunAll(t *testing.T) {
+	for _, w := range []int{0, 1, 4, 20} {
+		var calls [10]int32
+		errs := RunAll(len(calls), w, func(i int) error {
+			atomic.AddInt32(&calls[i], 1)
+			if i%3 == 0 {
+				return fmt.Errorf("job %v", i)
+			}
+			return nil
+		})
+		for i := range calls {
+			if calls[i] != 1 {
+				t.Fatalf("%v workers: job %v ran %v times", w, i, calls[i])
+			}
+			if (errs[i] != nil) != (i%3 == 0) {
+				t.Fatalf("%v workers: job %v returned %v", w, i, errs[i])
+			}
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	// the smallest failing index wins whatever finishes first
+	for rep := 0; rep < 20; rep++ {
+		err := Run(10, 4, func(i int) error {
+			if i == 2 || i == 7 {
+				return fmt.Errorf("job %v", i)
+			}
+			return nil
+		})
+		if err == nil || err.Error() != "job 2" {
+			t.Fatalf("got %v, expected job 2", err)
+		}
+	}
+	if err := Run(5, 2, func(int) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,146 @@
+// Package shuffle is the shuffler of the protocols: it collects the shares
+// and the dummies of the clients and releases them under a random
+// permutation.
+//
+// The shares of a client are records, tuples of field elements that move as
+// a unit: a (first, second) pair of a ballot keeps its two elements together,
+// and so would a transaction triple. The dummies are shuffled on their own.
+//
+// A Shuffler can be told to misbehave, to test that the server catches a
+// dishonest shuffler with the product check.
+package shuffle
+
+import (
+	"fmt"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// Source is where the permutations come from, such as a benchrand.Rand or a
+// math/rand.Rand.
+type Source interface {
+	Shuffle(n int, swap func(i, j int))
+}
+
+// Record is one share of a client, of the width of the shuffler.
+type Record []fr_bn254.Element
+
+// Misbehavior rewrites the output of the shuffler before it is released.
+type Misbehavior func(records []Record, dummies []fr_bn254.Element) ([]Record, []fr_bn254.Element)
+
+// Shuffler collects the records and the dummies of the clients.
+type Shuffler struct {
+	src   Source
+	width int
+
+	records []Record
+	dummies []fr_bn254.Element
+	shared  map[int]bool
+	dummied map[int]bool
+
+	// Misbehave, if not nil, is applied to the output of the honest
+	// shuffler.
+	Misbehave Misbehavior
+}
+
+// New returns a shuffler of records of width elements, permuted by src.
+func New(src Source, width int) *Shuffler {
+	return &Shuffler{src: src, width: width, shared: make(map[int]bool), dummied: make(map[int]bool)}
+}
+
+// CollectShares receives the records of the client clientID. A client shares
+// once.
+func (s *Shuffler) CollectShares(clientID int, shares []Record) error {
+	if s.shared[clientID] {
+		return fmt.Errorf("shuffle: client %v: shares already collected", clientID)
+	}
+	for i, r := range shares {
+		if len(r) != s.width {
+			return fmt.Errorf("shuffle: client %v: record %v has %v elements, expected %v", clientID, i, len(r), s.width)
+		}
+	}
+	s.shared[clientID] = true
+	for _, r := range shares {
+		s.records = append(s.records, append(Record(nil), r...))
+	}
+	return nil
+}
+
+// CollectDummies receives the dummies of the client clientID. A client sends
+// its dummies once.
+func (s *Shuffler) CollectDummies(clientID int, dummies []fr_bn254.Element) error {
+	if s.dummied[clientID] {
+		return fmt.Errorf("shuffle: client %v: dummies already collected", clientID)
+	}
+	s.dummied[clientID] = true
+	s.dummies = append(s.dummies, dummies...)
+	return nil
+}
+
+// Output returns the records collected so far under one permutation, then
+// the dummies under another. The records are drawn first from the source.
+func (s *Shuffler) Output() ([]Record, []fr_bn254.Element) {
+	records := make([]Record, len(s.records))
+	for i, r := range s.records {
+		records[i] = append(Record(nil), r...)
+	}
+	dummies := append([]fr_bn254.Element(nil), s.dummies...)
+	s.src.Shuffle(len(records), func(i, j int) {
+		records[i], records[j] = records[j], records[i]
+	})
+	s.src.Shuffle(len(dummies), func(i, j int) {
+		dummies[i], dummies[j] = dummies[j], dummies[i]
+	})
+	if s.Misbehave != nil {
+		records, dummies = s.Misbehave(records, dummies)
+	}
+	return records, dummies
+}
+
+// Column returns the element j of every record.
+func Column(records []Record, j int) []fr_bn254.Element {
+	column := make([]fr_bn254.Element, len(records))
+	for i, r := range records {
+		column[i] = r[j]
+	}
+	return column
+}
+
+// Records zips the columns, all of the same length, into records: record i
+// holds the element i of every column.
+func Records(columns ...[]fr_bn254.Element) []Record {
+	if len(columns) == 0 {
+		return nil
+	}
+	records := make([]Record, len(columns[0]))
+	for i := range records {
+		records[i] = make(Record, len(columns))
+		for j, c := range columns {
+			records[i][j] = c[i]
+		}
+	}
+	return records
+}
+
+// ReorderColumn is the misbehavior of a shuffler that permutes the element j
+// of the records apart from the others, with a permutation drawn from src:
+// the pairs of a ballot come apart.
+func ReorderColumn(src Source, j int) Misbehavior {
+	return func(records []Record, dummies []fr_bn254.Element) ([]Record, []fr_bn254.Element) {
+		column := Column(records, j)
+		src.Shuffle(len(column), func(a, b int) {
+			column[a], column[b] = column[b], column[a]
+		})
+		for i := range records {
+			records[i][j] = column[i]
+		}
+		return records, dummies
+	}
+}
+
+// DropRecord is the misbehavior of a shuffler that leaves out the record i.
+func DropRecord(i int) Misbehavior {
+	return func(records []Record, dummies []fr_bn254.Element) ([]Record, []fr_bn254.Element) {
+		return append(records[:i:i], records[i+1:]...), dummies
+	}
+}
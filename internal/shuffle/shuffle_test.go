@@ -0,0 +1,94 @@
+package shuffle
+
+import (
+	mathrand "math/rand"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// triples returns the records of n clients, each with two triples (c, k, -c)
+// for k = 0, 1, so that a record is recognized by its first element and
+// checked by its last one.
+func triples(n int) [][]Record {
+	shares := make([][]Record, n)
+	for c := 0; c < n; c++ {
+		for k := 0; k < 2; k++ {
+			first := fr_bn254.NewElement(uint64(2*c + k))
+			var last fr_bn254.Element
+			last.Neg(&first)
+			shares[c] = append(shares[c], Record{first, fr_bn254.NewElement(uint64(k)), last})
+		}
+	}
+	return shares
+}
+
+func TestOutputKeepsRecords(t *testing.T) {
+	s := New(mathrand.New(mathrand.NewSource(1)), 3)
+	shares := triples(10)
+	for c := range shares {
+		if err := s.CollectShares(c, shares[c]); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CollectDummies(c, []fr_bn254.Element{fr_bn254.NewElement(uint64(c))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, dummies := s.Output()
+	if len(records) != 20 || len(dummies) != 10 {
+		t.Fatalf("%v records and %v dummies, expected 20 and 10", len(records), len(dummies))
+	}
+	seen := make(map[uint64]bool)
+	moved := false
+	for i, r := range records {
+		id := r[0].Uint64()
+		var sum fr_bn254.Element
+		sum.Add(&r[0], &r[2])
+		if seen[id] || !sum.IsZero() || r[1].Uint64() != id%2 {
+			t.Fatalf("record %v is not one of the input records: %v", i, r)
+		}
+		seen[id] = true
+		moved = moved || id != uint64(i)
+	}
+	if !moved {
+		t.Fatal("the records are not permuted")
+	}
+
+	// a misbehaving shuffler breaks the records apart
+	s.Misbehave = ReorderColumn(mathrand.New(mathrand.NewSource(2)), 2)
+	records, _ = s.Output()
+	broken := false
+	for _, r := range records {
+		var sum fr_bn254.Element
+		sum.Add(&r[0], &r[2])
+		broken = broken || !sum.IsZero()
+	}
+	if !broken {
+		t.Fatal("reordering a column kept every record")
+	}
+
+	s.Misbehave = DropRecord(0)
+	if records, _ = s.Output(); len(records) != 19 {
+		t.Fatalf("%v records after dropping one, expected 19", len(records))
+	}
+}
+
+func TestCollect(t *testing.T) {
+	s := New(mathrand.New(mathrand.NewSource(1)), 3)
+	shares := triples(2)
+	if err := s.CollectShares(0, shares[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CollectShares(0, shares[1]); err == nil {
+		t.Fatal("no error for a client sharing twice")
+	}
+	if err := s.CollectShares(1, []Record{shares[1][0][:2]}); err == nil {
+		t.Fatal("no error for a record of the wrong width")
+	}
+	if err := s.CollectDummies(0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CollectDummies(0, nil); err == nil {
+		t.Fatal("no error for a client sending its dummies twice")
+	}
+}
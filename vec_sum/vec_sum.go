@@ -17,12 +17,11 @@ import (
 	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/frontend/cs/scs"
 	"github.com/consensys/gnark/std/hash/mimc"
-	"github.com/consensys/gnark/test"
 
 	cs "github.com/consensys/gnark/constraint/bn254"
+
+	"example/verification/internal/gnarkshim"
 )
 
 const (
@@ -81,6 +80,10 @@ type VecSumCircuit struct {
 	PrivateSalt      frontend.Variable
 }
 
+// vecSumPublic are the public variables of VecSumCircuit, in the order of its
+// public witness. The setup is not run for a circuit that differs.
+var vecSumPublic = []string{"PublicThreshold", "PublicR", "PublicProd", "PublicCommitment"}
+
 func (circuit *VecSumCircuit) Define(api frontend.API) error {
 	//assert error if privateVec is empty
 
@@ -204,19 +207,19 @@ func (c *ClientState) Init(x []int) {
 
 func (c *ClientState) GenAssignment(publicR fr_bn254.Element) VecSumCircuit {
 	// first initialize all the variables in the circuit
+	// the variables point to the shares of the client rather than boxing a
+	// copy of each of them
 	processedVec := make([]fr_bn254.Element, PrivateVecLength*PrivateShareNum)
 	privateVecVar := make([]frontend.Variable, PrivateVecLength*PrivateShareNum)
+	varLen := fr_bn254.NewElement(uint64(PrivateVecLength))
 	for i := 0; i < PrivateVecLength; i++ {
+		vari := fr_bn254.NewElement(uint64(i))
 		for j := 0; j < PrivateShareNum; j++ {
 			var tmp fr_bn254.Element
-			varLen := fr_bn254.NewElement(uint64(PrivateVecLength))
-			vari := fr_bn254.NewElement(uint64(i))
-
-			tmp.Set(&c.PrivateX[i][j])
-			tmp.Mul(&tmp, &varLen)
+			tmp.Mul(&c.PrivateX[i][j], &varLen)
 			tmp.Add(&tmp, &vari)
 			processedVec[i*PrivateShareNum+j] = tmp
-			privateVecVar[i*PrivateShareNum+j] = frontend.Variable(c.PrivateX[i][j])
+			privateVecVar[i*PrivateShareNum+j] = &c.PrivateX[i][j]
 		}
 	}
 
@@ -239,7 +242,7 @@ func (c *ClientState) GenAssignment(publicR fr_bn254.Element) VecSumCircuit {
 
 func GenProofGroth16(assignment VecSumCircuit, ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey) (*groth16.Proof, *witness.Witness) {
 	// witness definition
-	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	if err != nil {
 		// print the error
 		fmt.Printf("Error in creating the witness: %v\n", err)
@@ -248,19 +251,19 @@ func GenProofGroth16(assignment VecSumCircuit, ccs *constraint.ConstraintSystem,
 	publicWitness, _ := witness.Public()
 
 	// groth16: Prove & Verify
-	proof, _ := groth16.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProveGroth16(*ccs, *pk, witness)
 
 	return &proof, &publicWitness
 }
 
 func GenProofPlonk(assignment VecSumCircuit, ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey) (*plonk.Proof, *witness.Witness) {
 	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := gnarkshim.NewWitness(ecc.BN254, &assignment)
 	//fmt.Println(witness)
 	publicWitness, _ := witness.Public()
 
 	// plonk: Prove & Verify
-	proof, _ := plonk.Prove(*ccs, *pk, witness)
+	proof, _ := gnarkshim.ProvePlonk(*ccs, *pk, witness)
 
 	return &proof, &publicWitness
 }
@@ -286,10 +289,13 @@ func VecSumGroth16() {
 		PrivateSalt:      0,
 	}
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	ccs, _ := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err := gnarkshim.CheckPublic(ccs, vecSumPublic); err != nil {
+		log.Fatal(err)
+	}
 
 	//groth16 zkSNARK: Setup
-	pk, vk, _ := groth16.Setup(ccs)
+	pk, vk, _ := gnarkshim.SetupGroth16(ccs)
 
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
@@ -405,7 +411,7 @@ func VecSumGroth16() {
 	start = time.Now()
 	for i := 0; i < len(allSubmission); i++ {
 		if i < MaxNumOfCheckProof {
-			verification_err := groth16.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
+			verification_err := gnarkshim.VerifyGroth16(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
@@ -538,20 +544,23 @@ func VecSumPlonk() {
 	}
 
 	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	ccs, err := gnarkshim.CompileSCS(ecc.BN254, &circuit)
 	if err != nil {
 		log.Println("scs circuit compile error")
 	}
+	if err := gnarkshim.CheckPublic(ccs, vecSumPublic); err != nil {
+		log.Fatal(err)
+	}
 
 	//setup kzg
 	_r1cs := ccs.(*cs.SparseR1CS)
-	srs, err := test.NewKZGSRS(_r1cs)
+	srs, err := gnarkshim.NewKZGSRS(_r1cs)
 	if err != nil {
 		log.Println("kzg srs error")
 	}
 
 	// plonk Setup
-	pk, vk, _ := plonk.Setup(ccs, srs)
+	pk, vk, _ := gnarkshim.SetupPlonk(ccs, srs)
 	var buf bytes.Buffer
 	pk.WriteTo(&buf)
 	// check how many bytes are written
@@ -666,7 +675,7 @@ func VecSumPlonk() {
 	start = time.Now()
 	for i := 0; i < len(allSubmission); i++ {
 		if i < MaxNumOfCheckProof {
-			verification_err := plonk.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
+			verification_err := gnarkshim.VerifyPlonk(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
 			if verification_err != nil {
 				fmt.Printf("verification error in client %v", i)
 			}
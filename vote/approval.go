@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+	"example/verification/internal/hasher"
+)
+
+// In approval voting each voter approves a subset of the candidates. The
+// client sends the shuffler one pair (candidateNum + i, approved) per
+// candidate i, packed as (candidateNum + i) * candidateNum + approved like the
+// pairs of the ranked vote, so that the shuffler, the product check and the
+// commitments of the server are the same for both ballots. The first element
+// is shifted by candidateNum so that the packed approvals, at least
+// candidateNum^2, are never a packed ranked pair: the ranked tally rejects
+// them, and ComputeApprovals rejects the ranked pairs.
+
+type ApprovalVoteCircuit struct {
+	// Approvals[i] is 1 if the voter approves candidate i and 0 otherwise
+	Approvals []frontend.Variable
+	// MaxApprovals caps the number of approvals of a ballot, 0 for no cap
+	MaxApprovals int `gnark:"-"`
+
+	// The following are for the polynomial evaluation
+	PrivateMask frontend.Variable
+	// DummyVec are the dummies the client sends to the shuffler; the mask is
+	// their product, as the server multiplies them in. They are committed, so
+	// the mask is the one of the dummies fixed before publicR.
+	DummyVec   []frontend.Variable
+	PublicR    frontend.Variable `gnark:",public"`
+	PublicProd frontend.Variable `gnark:",public"`
+
+	// The following are for the commitment
+	PublicCommitment frontend.Variable `gnark:",public"`
+	PrivateSalt      frontend.Variable
+
+	// Hash is the hash of the commitment, see params.go
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+func (circuit *ApprovalVoteCircuit) Define(api frontend.API) error {
+	candidateNum := len(circuit.Approvals)
+
+	// every approval is 0 or 1, and the pair of candidate i is
+	// (candidateNum + i) * candidateNum + approval
+	processedVec := make([]frontend.Variable, candidateNum)
+	approved := frontend.Variable(0)
+	for i := 0; i < candidateNum; i++ {
+		gadget.Label(api, gadget.Threshold).AssertIsBoolean(circuit.Approvals[i])
+		processedVec[i] = api.Add((candidateNum+i)*candidateNum, circuit.Approvals[i])
+		approved = api.Add(approved, circuit.Approvals[i])
+	}
+	if circuit.MaxApprovals > 0 {
+		gadget.Label(api, gadget.Threshold).AssertIsLessOrEqual(approved, circuit.MaxApprovals)
+	}
+
+	// The following is for the polynomial evaluation
+	privateProd := PolyEvalInCircuit(api, processedVec, circuit.PublicR)
+	privateProd = api.Mul(privateProd, circuit.PrivateMask)
+	gadget.Label(api, gadget.Product).AssertIsEqual(privateProd, circuit.PublicProd)
+
+	dummyProd := frontend.Variable(1)
+	for i := 0; i < len(circuit.DummyVec); i++ {
+		dummyProd = api.Mul(dummyProd, circuit.DummyVec[i])
+	}
+	gadget.Label(api, gadget.Product).AssertIsEqual(circuit.PrivateMask, dummyProd)
+
+	// the commitment binds the pairs sent to the shuffler
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	h.Write(processedVec...)
+	h.Write(circuit.DummyVec...)
+	h.Write(circuit.PrivateMask)
+	h.Write(circuit.PrivateSalt)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicCommitment, h.Sum())
+	return nil
+}
+
+// NewApprovalVoteCircuit allocates an empty approval circuit for candidateNum
+// candidates and dummyNum dummies, hashing with Params.Hash, ready to be
+// compiled. Its public variables are VotePublic.
+func NewApprovalVoteCircuit(candidateNum int, dummyNum int) ApprovalVoteCircuit {
+	return ApprovalVoteCircuit{
+		Approvals:        make([]frontend.Variable, candidateNum),
+		PrivateMask:      0,
+		DummyVec:         make([]frontend.Variable, dummyNum),
+		PublicR:          0,
+		PublicProd:       0,
+		PublicCommitment: 0,
+		PrivateSalt:      0,
+		Hash:             Params.Hash,
+	}
+}
+
+// InitApprovalRand samples a random approval ballot for the client, drawn
+// from rnd like InitRand: PairFirst are the candidates, shifted by
+// CandidateNum, and PairSecond the approvals.
+func (c *ClientState) InitApprovalRand(rnd *benchrand.Rand, field Field, dummyNum uint64) {
+	c.SortedCandidate = nil
+	c.PairFirst = make([]fr_bn254.Element, CandidateNum)
+	c.PairSecond = make([]fr_bn254.Element, CandidateNum)
+	for i := 0; i < CandidateNum; i++ {
+		c.PairFirst[i] = fr_bn254.NewElement(uint64(CandidateNum + i))
+		c.PairSecond[i] = fr_bn254.NewElement(uint64(rnd.Intn(2)))
+	}
+	c.PrivateX = packPairs(CandidateNum, c.PairFirst, c.PairSecond)
+
+	c.initSecrets(rnd, field, dummyNum)
+}
+
+// GenApprovalAssignment is GenAssignment for the approval ballot of the client.
+func (c *ClientState) GenApprovalAssignment(publicR fr_bn254.Element) ApprovalVoteCircuit {
+	approvals := make([]frontend.Variable, len(c.PairSecond))
+	for i := 0; i < len(approvals); i++ {
+		approvals[i] = frontendVariable(&c.PairSecond[i])
+	}
+
+	dummyVec := make([]frontend.Variable, len(c.PrivateY))
+	for i := 0; i < len(dummyVec); i++ {
+		dummyVec[i] = frontendVariable(&c.PrivateY[i])
+	}
+
+	publicProd := c.ComputePolyEval(publicR)
+
+	return ApprovalVoteCircuit{
+		Approvals:        approvals,
+		PrivateMask:      frontend.Variable(c.PrivateMask),
+		DummyVec:         dummyVec,
+		PublicR:          frontendVariable(&publicR),
+		PublicProd:       frontend.Variable(publicProd),
+		PublicCommitment: frontend.Variable(c.PublicCom),
+		PrivateSalt:      frontendVariable(&c.PrivateSalt),
+	}
+}
+
+// ComputeApprovals returns the number of approvals of every candidate in the
+// shuffled approval pairs of candidateNum candidates.
+func ComputeApprovals(shuffledPairFirst, shuffledPairSecond []fr_bn254.Element, candidateNum int) ([]uint64, error) {
+	if len(shuffledPairFirst) != len(shuffledPairSecond) {
+		return nil, fmt.Errorf("%w: %v first and %v second elements", ErrBallotKind, len(shuffledPairFirst), len(shuffledPairSecond))
+	}
+	approvals := make([]uint64, candidateNum)
+	for i := 0; i < len(shuffledPairFirst); i++ {
+		a, b := shuffledPairFirst[i], shuffledPairSecond[i]
+		if !a.IsUint64() || !b.IsUint64() || a.Uint64() < uint64(candidateNum) || a.Uint64() >= uint64(2*candidateNum) || b.Uint64() > 1 {
+			return nil, fmt.Errorf("%w: pair %v is not an approval of one of %v candidates", ErrBallotKind, i, candidateNum)
+		}
+		approvals[a.Uint64()-uint64(candidateNum)] += b.Uint64()
+	}
+	return approvals, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/benchrand"
+)
+
+func TestApprovalVoteCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewApprovalVoteCircuit(CandidateNum, 4)
+
+	var client ClientState
+	client.InitApprovalRand(benchrand.New(1), bn254Field{}, 4)
+	assignment := client.GenApprovalAssignment(randomFr())
+	assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// an approval of 2, with the packed pairs and the commitment consistent
+	// with it
+	client.PairSecond[0] = fr_bn254.NewElement(2)
+	client.PrivateX = packPairs(CandidateNum, client.PairFirst, client.PairSecond)
+	client.PublicCom = client.Opening().Commit(bn254Field{})
+	assignment = client.GenApprovalAssignment(randomFr())
+	assert.ProverFailed(&circuit, &assignment, test.WithCurves(ecc.BN254))
+}
+
+func TestApprovalShufflerProduct(t *testing.T) {
+	field := bn254Field{}
+	publicR := randomFr()
+	rnd := benchrand.New(2)
+	clients := make([]ClientState, 5)
+	for i := range clients {
+		clients[i].InitApprovalRand(rnd, field, 3)
+	}
+	pairFirst, pairSecond := ShufflePairs(rnd, clients)
+	dummies := ShuffleDummies(rnd, clients)
+
+	clientsProd := big.NewInt(1)
+	for i := range clients {
+		clientsProd = field.Mul(clientsProd, clients[i].ComputePolyEval(publicR))
+	}
+	if ShufflerProduct(field, pairFirst, pairSecond, dummies, publicR).Cmp(clientsProd) != 0 {
+		t.Fatal("the product of the shuffled approvals is not the product of the clients")
+	}
+}
+
+func TestComputeApprovals(t *testing.T) {
+	rnd := benchrand.New(3)
+	clients := make([]ClientState, 5)
+	expected := make([]uint64, CandidateNum)
+	for i := range clients {
+		clients[i].InitApprovalRand(rnd, bn254Field{}, 2)
+		for j := 0; j < CandidateNum; j++ {
+			expected[j] += clients[i].PairSecond[j].Uint64()
+		}
+	}
+	pairFirst, pairSecond := ShufflePairs(rnd, clients)
+	approvals, err := ComputeApprovals(pairFirst, pairSecond, CandidateNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(approvals, expected) {
+		t.Fatalf("approvals %v, expected %v", approvals, expected)
+	}
+
+	// the approvals are not ranked pairs, and the other way around
+	if _, err := ComputeTally(pairFirst, pairSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("the ranked tally of approvals: got %v, expected ErrBallotKind", err)
+	}
+	var ranked ClientState
+	ranked.InitRand(rnd, bn254Field{}, 2)
+	if _, err := ComputeApprovals(ranked.PairFirst, ranked.PairSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("the approvals of a ranking: got %v, expected ErrBallotKind", err)
+	}
+	mixedFirst := append(append([]fr_bn254.Element(nil), pairFirst...), ranked.PairFirst...)
+	mixedSecond := append(append([]fr_bn254.Element(nil), pairSecond...), ranked.PairSecond...)
+	if _, err := ComputeApprovals(mixedFirst, mixedSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("approvals mixed with a ranking: got %v, expected ErrBallotKind", err)
+	}
+	if _, err := ComputeTally(mixedFirst, mixedSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("a ranking mixed with approvals: got %v, expected ErrBallotKind", err)
+	}
+}
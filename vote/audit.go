@@ -0,0 +1,119 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"example/verification/internal/pool"
+)
+
+// A third party can audit the proofs of an election from the verifying key
+// and the submissions saved by SaveSubmissions, without the proving key or
+// any client secret:
+//
+//	vote verify -vk keys/vote-<checksum>/groth16.vk -dir submissions
+
+// submissionFile is the name of the file of client i in a submission directory.
+func submissionFile(i int) string {
+	return fmt.Sprintf("client-%06d.sub", i)
+}
+
+// SaveSubmissions writes every submission to its own file of dir, in the wire
+// format of wire.go.
+func SaveSubmissions(dir string, allSubmission []ClientSubmission) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for i := 0; i < len(allSubmission); i++ {
+		name := filepath.Join(dir, submissionFile(i))
+		if err := writeToFile(name, &allSubmission[i]); err != nil {
+			return fmt.Errorf("write %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// LoadSubmission reads a submission file written by SaveSubmissions.
+func LoadSubmission(name string) (ClientSubmission, error) {
+	var s ClientSubmission
+	if err := readFromFile(name, &s); err != nil {
+		return ClientSubmission{}, fmt.Errorf("read %v: %w", name, err)
+	}
+	return s, nil
+}
+
+// AuditResult is the outcome of the audit of one submission file.
+type AuditResult struct {
+	Name string
+	// Err is nil for a valid proof and ErrNoProof for a submission without one.
+	Err error
+}
+
+// AuditSubmissions verifies all the submission files of dir against vk on
+// Workers goroutines. The results are sorted by file name.
+func AuditSubmissions(vk VerifyingKeyBytes, dir string) ([]AuditResult, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.sub"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	results := make([]AuditResult, len(names))
+	pool.RunAll(len(names), Workers, func(i int) error {
+		results[i].Name = filepath.Base(names[i])
+		s, err := LoadSubmission(names[i])
+		if err == nil {
+			err = VerifySubmission(vk, &s)
+		}
+		results[i].Err = err
+		return nil
+	})
+	return results, nil
+}
+
+// verifyMain is the entry point of "vote verify".
+func verifyMain(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	vkFile := fs.String("vk", "", "verifying key file, e.g. keys/vote-<checksum>/groth16.vk")
+	dir := fs.String("dir", "submissions", "directory of the submission files")
+	fs.IntVar(&Workers, "workers", Workers, "number of goroutines verifying the proofs")
+	fs.Parse(args)
+	if *vkFile == "" {
+		fmt.Fprintf(os.Stderr, "invalid configuration: -vk is required\n")
+		return 2
+	}
+
+	vk, err := os.ReadFile(*vkFile)
+	if err != nil {
+		log.Printf("verify: %v\n", err)
+		return 1
+	}
+	results, err := AuditSubmissions(vk, *dir)
+	if err != nil {
+		log.Printf("verify: %v\n", err)
+		return 1
+	}
+	verified, unchecked, failed := 0, 0, 0
+	for _, res := range results {
+		switch {
+		case res.Err == nil:
+			verified++
+		case errors.Is(res.Err, ErrNoProof):
+			unchecked++
+		default:
+			failed++
+			fmt.Printf("%v: FAILED: %v\n", res.Name, res.Err)
+		}
+	}
+	fmt.Printf("%v submissions: %v verified, %v without proof, %v failed\n", len(results), verified, unchecked, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+func TestAuditSubmissions(t *testing.T) {
+	allSubmission, vk := genSubmissions(t, NewGroth16System(ecc.BN254), 3)
+	// client 1 does not attach a proof, the proof of client 2 is corrupted
+	allSubmission[1].proof = nil
+	allSubmission[1].publicWitness = nil
+	allSubmission[2].proof = append(ProofBytes(nil), allSubmission[2].proof...)
+	allSubmission[2].proof[len(allSubmission[2].proof)/2] ^= 1
+
+	dir := t.TempDir()
+	if err := SaveSubmissions(dir, allSubmission); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSubmission(filepath.Join(dir, submissionFile(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySubmission(vk, &s); err != nil {
+		t.Fatalf("the reloaded submission does not verify: %v", err)
+	}
+
+	results, err := AuditSubmissions(vk, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("audited %v submissions, expected 3", len(results))
+	}
+	if results[0].Err != nil {
+		t.Errorf("%v: %v", results[0].Name, results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrNoProof) {
+		t.Errorf("%v: expected ErrNoProof, got %v", results[1].Name, results[1].Err)
+	}
+	if results[2].Err == nil || errors.Is(results[2].Err, ErrNoProof) {
+		t.Errorf("%v: the corrupted proof was not reported: %v", results[2].Name, results[2].Err)
+	}
+
+	// the audit only needs the verifying key on disk
+	vkFile := filepath.Join(dir, "groth16.vk")
+	if err := os.WriteFile(vkFile, vk, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if code := verifyMain([]string{"-vk", vkFile, "-dir", dir}); code != 1 {
+		t.Fatalf("vote verify exited with %v, expected 1 for the corrupted proof", code)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/witness"
+
+	"example/verification/internal/gnarkshim"
+)
+
+var errBatchPairingFailed = errors.New("batched pairing check failed")
+
+// batchPairingCheckGroth16 checks all the proofs with a single final
+// exponentiation. Every proof i satisfies
+//
+//	e(A_i, B_i) = e(α, β) · e(L_i, γ) · e(C_i, δ)
+//
+// where L_i is the public input commitment. Raising equation i to a random
+// r_i and multiplying them together gives
+//
+//	Π e(r_i·A_i, B_i) · e(Σ r_i·C_i, -δ) · e(Σ r_i·L_i, -γ) · e(-(Σ r_i)·α, β) = 1
+//
+// which holds for all i except with negligible probability if it holds at all.
+// Σ r_i·L_i is computed with a single multi-exponentiation over the aggregated
+// public inputs.
+func batchPairingCheckGroth16(proofs []*groth16_bn254.Proof, vk *groth16_bn254.VerifyingKey, publicInputs []fr_bn254.Vector) error {
+	if len(vk.PublicAndCommitmentCommitted) != 0 {
+		return errors.New("batch verification does not support circuits with commitments")
+	}
+
+	nbPublic := len(vk.G1.K) - 1
+	aggregatedInputs := make(fr_bn254.Vector, nbPublic)
+	var rSum fr_bn254.Element
+	var rC curve.G1Jac
+
+	P := make([]curve.G1Affine, 0, len(proofs)+3)
+	Q := make([]curve.G2Affine, 0, len(proofs)+3)
+
+	for i := 0; i < len(proofs); i++ {
+		if len(publicInputs[i]) != nbPublic {
+			return fmt.Errorf("proof %v: invalid witness size, got %v, expected %v", i, len(publicInputs[i]), nbPublic)
+		}
+		if !proofs[i].Ar.IsInSubGroup() || !proofs[i].Krs.IsInSubGroup() || !proofs[i].Bs.IsInSubGroup() {
+			return fmt.Errorf("proof %v: points are not in the correct subgroup", i)
+		}
+
+		r := randomFr()
+		var rBig big.Int
+		r.BigInt(&rBig)
+		rSum.Add(&rSum, &r)
+
+		var rA curve.G1Affine
+		rA.ScalarMultiplication(&proofs[i].Ar, &rBig)
+		P = append(P, rA)
+		Q = append(Q, proofs[i].Bs)
+
+		var tmp curve.G1Jac
+		tmp.FromAffine(&proofs[i].Krs)
+		tmp.ScalarMultiplication(&tmp, &rBig)
+		rC.AddAssign(&tmp)
+
+		for j := 0; j < nbPublic; j++ {
+			var t fr_bn254.Element
+			t.Mul(&r, &publicInputs[i][j])
+			aggregatedInputs[j].Add(&aggregatedInputs[j], &t)
+		}
+	}
+
+	// Σ r_i·L_i = (Σ r_i)·K_0 + Σ_j (Σ_i r_i·x_ij)·K_j
+	var rL curve.G1Jac
+	if _, err := rL.MultiExp(vk.G1.K[1:], aggregatedInputs, ecc.MultiExpConfig{}); err != nil {
+		return err
+	}
+	var rSumBig big.Int
+	rSum.BigInt(&rSumBig)
+	var k0 curve.G1Jac
+	k0.FromAffine(&vk.G1.K[0])
+	k0.ScalarMultiplication(&k0, &rSumBig)
+	rL.AddAssign(&k0)
+
+	var rLAff, rCAff, rAlpha curve.G1Affine
+	rLAff.FromJacobian(&rL)
+	rCAff.FromJacobian(&rC)
+	rAlpha.ScalarMultiplication(&vk.G1.Alpha, &rSumBig)
+	rAlpha.Neg(&rAlpha)
+
+	var deltaNeg, gammaNeg curve.G2Affine
+	deltaNeg.Neg(&vk.G2.Delta)
+	gammaNeg.Neg(&vk.G2.Gamma)
+
+	P = append(P, rCAff, rLAff, rAlpha)
+	Q = append(Q, deltaNeg, gammaNeg, vk.G2.Beta)
+
+	ok, err := curve.PairingCheck(P, Q)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errBatchPairingFailed
+	}
+	return nil
+}
+
+func toBatchInputs(proofs []groth16.Proof, vk groth16.VerifyingKey, publicWitnesses []*witness.Witness) ([]*groth16_bn254.Proof, *groth16_bn254.VerifyingKey, []fr_bn254.Vector, error) {
+	_vk, ok := vk.(*groth16_bn254.VerifyingKey)
+	if !ok {
+		return nil, nil, nil, errors.New("batch verification only supports BN254 keys")
+	}
+	_proofs := make([]*groth16_bn254.Proof, len(proofs))
+	publicInputs := make([]fr_bn254.Vector, len(proofs))
+	for i := 0; i < len(proofs); i++ {
+		if _proofs[i], ok = proofs[i].(*groth16_bn254.Proof); !ok {
+			return nil, nil, nil, fmt.Errorf("proof %v: not a BN254 proof", i)
+		}
+		if publicInputs[i], ok = (*publicWitnesses[i]).Vector().(fr_bn254.Vector); !ok {
+			return nil, nil, nil, fmt.Errorf("proof %v: not a BN254 public witness", i)
+		}
+	}
+	return _proofs, _vk, publicInputs, nil
+}
+
+// batchCheckGroth16 runs the batched pairing check without any fallback.
+func batchCheckGroth16(proofs []groth16.Proof, vk groth16.VerifyingKey, publicWitnesses []*witness.Witness) error {
+	_proofs, _vk, publicInputs, err := toBatchInputs(proofs, vk, publicWitnesses)
+	if err != nil {
+		return err
+	}
+	return batchPairingCheckGroth16(_proofs, _vk, publicInputs)
+}
+
+// BatchVerifyGroth16 verifies all proofs against vk at the cost of roughly one
+// pairing per proof plus a single final exponentiation. If the batch does not
+// verify, the proofs are verified one by one and the error joins one error
+// per invalid proof, each naming it.
+func BatchVerifyGroth16(proofs []groth16.Proof, vk groth16.VerifyingKey, publicWitnesses []*witness.Witness) error {
+	if len(proofs) != len(publicWitnesses) {
+		return fmt.Errorf("got %v proofs but %v public witnesses", len(proofs), len(publicWitnesses))
+	}
+	if len(proofs) == 0 {
+		return nil
+	}
+
+	err := batchCheckGroth16(proofs, vk, publicWitnesses)
+	if err == nil {
+		return nil
+	}
+
+	// fall back to the individual verification to localize the invalid proofs
+	var invalid []error
+	for i := 0; i < len(proofs); i++ {
+		if verr := gnarkshim.VerifyGroth16(proofs[i], vk, *publicWitnesses[i]); verr != nil {
+			invalid = append(invalid, fmt.Errorf("proof %v: %w", i, verr))
+		}
+	}
+	if len(invalid) > 0 {
+		return errors.Join(invalid...)
+	}
+	if errors.Is(err, errBatchPairingFailed) {
+		return err
+	}
+	// the batch path is not available for these keys, but every proof verified
+	return nil
+}
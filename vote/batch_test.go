@@ -0,0 +1,92 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// genGroth16Proofs proves n fresh clients and returns their proofs.
+func genGroth16Proofs(tb testing.TB, n int) ([]groth16.Proof, []*witness.Witness, groth16.VerifyingKey) {
+	system := NewGroth16System(ecc.BN254)
+	allSubmission, vkBytes := genSubmissions(tb, system, n)
+	vk, err := system.verifyingKey(vkBytes)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	proofs := make([]groth16.Proof, n)
+	publicWitnesses := make([]*witness.Witness, n)
+	for i := 0; i < n; i++ {
+		if proofs[i], err = system.proof(allSubmission[i].proof); err != nil {
+			tb.Fatal(err)
+		}
+		publicWitnesses[i] = &allSubmission[i].publicWitness
+	}
+	return proofs, publicWitnesses, vk
+}
+
+func TestBatchVerifyGroth16(t *testing.T) {
+	proofs, publicWitnesses, vk := genGroth16Proofs(t, 4)
+
+	if err := BatchVerifyGroth16(proofs, vk, publicWitnesses); err != nil {
+		t.Fatal(err)
+	}
+
+	// proof 2 is checked against the public witness of another client
+	publicWitnesses[2] = publicWitnesses[3]
+	err := BatchVerifyGroth16(proofs, vk, publicWitnesses)
+	if err == nil {
+		t.Fatal("expected the batch to fail")
+	}
+	if !strings.HasPrefix(err.Error(), "proof 2:") {
+		t.Fatalf("error does not name the invalid proof: %v", err)
+	}
+
+	// every invalid proof is named, not only the first one
+	publicWitnesses[0] = publicWitnesses[1]
+	err = BatchVerifyGroth16(proofs, vk, publicWitnesses)
+	if err == nil || !strings.Contains(err.Error(), "proof 0:") || !strings.Contains(err.Error(), "proof 2:") || strings.Contains(err.Error(), "proof 1:") {
+		t.Fatalf("error does not name proofs 0 and 2 only: %v", err)
+	}
+}
+
+func BenchmarkVerifyGroth16(b *testing.B) {
+	const distinct = 10
+	baseProofs, baseWitnesses, vk := genGroth16Proofs(b, distinct)
+
+	for _, n := range []int{10, 100, 1000} {
+		// the same valid proofs are reused to keep the setup cheap
+		proofs := make([]groth16.Proof, n)
+		publicWitnesses := make([]*witness.Witness, n)
+		for i := 0; i < n; i++ {
+			proofs[i] = baseProofs[i%distinct]
+			publicWitnesses[i] = baseWitnesses[i%distinct]
+		}
+
+		b.Run(fmt.Sprintf("sequential/%v", n), func(b *testing.B) {
+			for k := 0; k < b.N; k++ {
+				for i := 0; i < n; i++ {
+					if err := gnarkshim.VerifyGroth16(proofs[i], vk, *publicWitnesses[i]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("batch/%v", n), func(b *testing.B) {
+			for k := 0; k < b.N; k++ {
+				if err := BatchVerifyGroth16(proofs, vk, publicWitnesses); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
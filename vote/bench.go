@@ -0,0 +1,524 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/benchreport"
+	"example/verification/internal/merkle"
+	"example/verification/internal/pool"
+)
+
+// The benchmark of the protocol, run by main.go. It writes its results and
+// metrics to files, so the wasm client leaves it out.
+
+// reporters write the result of every run.
+var reporters []*benchreport.Reporter
+
+// Vote runs one election with system as the proof backend of the clients.
+// The protocol runs in the scalar field of the curve of system. Every client
+// sends dummyNum dummies, or the number ComputeDummyNum requires for 0. The
+// result is reported and returned, for the caller to aggregate the repeated
+// runs.
+func Vote(system ProofSystem, dummyNum uint64) (benchreport.BenchmarkResult, error) {
+	field, err := NewField(system.Curve())
+	if err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+	dummyVecLength, err := ComputeDummyNum(Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	if err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+	log.Printf("lambda %v, n %v, t %v, Dummy Num: %v\n", Lambda, ClientNum, CorruptedNum, dummyVecLength)
+	belowTarget := false
+	if dummyNum != 0 {
+		if belowTarget, err = BelowSecurityTarget(dummyNum, Lambda, uint64(ClientNum), uint64(CorruptedNum)); err != nil {
+			return benchreport.BenchmarkResult{}, err
+		}
+		if belowTarget {
+			log.Printf("WARNING: %v dummies per client, below the %v of the security target: this run does not have the claimed privacy\n", dummyNum, dummyVecLength)
+		}
+		dummyVecLength = dummyNum
+	}
+	rnd := benchrand.New(Seed)
+	if Seed != 0 {
+		log.Printf("seed %v: the secrets of the clients are predictable, for benchmarks only\n", Seed)
+	}
+
+	// define a dummy vote circuit
+	circuit := NewVoteCircuit(CandidateNum, int(dummyVecLength))
+
+	// compile and zkSNARK: Setup (or reuse the circuit and the keys saved
+	// in KeyDir)
+	var memory MemoryStats
+	memBefore := sampleMemory()
+	start := time.Now()
+	ccs, vk, err := CompileAndSetup(system, &circuit, VotePublic, VoteManifest(system, int(dummyVecLength)))
+	if err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+	// the first run with a KeyDir saves the artifacts, the next ones only load
+	// them: the gap between the two is the time the cache saves
+	log.Printf("Compile and setup (keydir %q): %v\n", KeyDir, time.Since(start))
+	memAfter := sampleMemory()
+	memory.Setup = newMemoryPhase(&memBefore, &memAfter)
+	provingKeySize := system.ProvingKeySize()
+
+	// the nullifier circuit has its own key pair, so its own proof system
+	nullifierSystem, err := NewProofSystem(system.Backend(), system.Curve())
+	if err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+	nullifierCCS, nullifierVK, err := CompileAndSetup(nullifierSystem, &NullifierCircuit{Hash: Params.Hash}, NullifierPublic, NullifierManifest(nullifierSystem))
+	if err != nil {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("nullifier: %w", err)
+	}
+
+	// Step 1: define n clients
+	start = time.Now()
+	// a seeded run draws all the clients from rnd, in order, to be reproducible
+	var clients []ClientState
+	if Seed != 0 {
+		clients = make([]ClientState, ClientNum)
+		for i := 0; i < len(clients); i++ {
+			clients[i].InitRand(rnd, field, dummyVecLength)
+		}
+	} else {
+		clients = initClients(ClientNum, Workers, field, dummyVecLength)
+	}
+	prepTime := time.Since(start)
+	// the session holds the rounds below to their order
+	var session ProtocolSession
+	if err := session.Advance(ProtocolInit, clients); err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+
+	// in the simulation mode, some clients ship to the shuffler pairs that do
+	// not match their proof, see malicious.go
+	malicious := SelectMalicious(rnd, len(clients), MaliciousFrac)
+	shipped := ShipInconsistentPairs(clients, malicious)
+	if len(malicious) > 0 {
+		log.Printf("Malicious: clients %v ship pairs that do not match their proof\n", malicious)
+	}
+
+	// the server publishes the Merkle root of the commitments and gives every
+	// client the path of its own
+	start = time.Now()
+	commitments := make([]*big.Int, len(clients))
+	for i := range clients {
+		commitments[i] = clients[i].PublicCom
+	}
+	commitTree := CommitmentTree(commitments)
+	commitPaths := make([]merkle.Path, len(clients))
+	for i := range clients {
+		if commitPaths[i], err = commitTree.Prove(i); err != nil {
+			return benchreport.BenchmarkResult{}, err
+		}
+	}
+	rootTime := time.Since(start)
+	if err := session.Advance(ProtocolCommitment, commitments); err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+	commitRoot := commitTree.Root()
+	if !VerifyCommitmentInclusion(clients[0].PublicCom, commitPaths[0], commitRoot) {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("the commitment of client 0 is not in the root")
+	}
+
+	// print the information of the 0-th client
+	fmt.Printf("=====Client 0=====\n")
+	for i := 0; i < len(clients[0].SortedCandidate); i++ {
+		// print the sorted candidate, cast it to uint64
+		fmt.Printf("rank: %v", clients[0].SortedCandidate[i].Uint64())
+	}
+	fmt.Printf("\n")
+	tmpCnt := 0
+	for i := 0; i < CandidateNum; i++ {
+		for j := 0; j < CandidateNum-i-1; j++ {
+			fmt.Printf("(%v, %v)", clients[0].PairFirst[tmpCnt].Uint64(), clients[0].PairSecond[tmpCnt].Uint64())
+			tmpCnt += 1
+		}
+		fmt.Printf("\n")
+	}
+	tmpCnt = 0
+	for i := 0; i < CandidateNum; i++ {
+		for j := 0; j < CandidateNum-i-1; j++ {
+			fmt.Printf("%v ", clients[0].PrivateX[tmpCnt].Uint64())
+			tmpCnt += 1
+		}
+		fmt.Printf("\n")
+	}
+	fmt.Printf("============================\n")
+
+	// DATA COLLECTION PHASE: each client submits its votes to the shuffler
+
+	// with MixnetFile, an external mixnet shuffled the ballots and the dummies
+	// and released them in a batch file, with no commitment nor proof
+	var shuffledPairFirst, shuffledPairSecond, allDummies []fr_bn254.Element
+	var releaseCom [32]byte
+	if MixnetFile != "" {
+		batch, err := ImportMixnet(MixnetFile, len(commitments), CandidateNum, dummyVecLength)
+		if err != nil {
+			return benchreport.BenchmarkResult{}, err
+		}
+		log.Printf("Imported mixnet batch %q of %q\n", batch.Attestation.Batch, batch.Attestation.Source)
+		shuffledPairFirst, shuffledPairSecond, allDummies = batch.PairFirst, batch.PairSecond, batch.Dummies
+	} else {
+		shuffledPairFirst, shuffledPairSecond = ShufflePairs(rnd, shipped)
+		// the shuffler commits to its release before publicR is derived
+		releaseCom = ReleaseCommitment(packPairs(CandidateNum, shuffledPairFirst, shuffledPairSecond))
+	}
+
+	// DETECTION PHASE:
+
+	// Step 1: Client does the following
+	// a) randomly sample the dummies (already done when we initialize the clients)
+	// b) send the dummies to the shuffler
+	// c) send the commitment to the server
+
+	if MixnetFile == "" {
+		allDummies = ShuffleDummies(rnd, shipped)
+	}
+
+	// the commitments of the epoch must be unique
+	commitSet, commitErrs := CheckCommitments(field, Duplicates, clients)
+	log.Printf("Duplicate commitments (%v): %v rejected, %v flagged\n", Duplicates, len(commitSet.Rejected()), len(commitSet.Flagged()))
+	if len(commitErrs) > 0 {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("%v commitment(s) refused, first: %w", len(commitErrs), commitErrs[0])
+	}
+
+	// the tally stays shut until the polls close and a quorum of proofs verified
+	gate := NewTallyGate(tallyQuorum(), systemClock{})
+
+	// Step 2: the clients reveal their nonces, the server reveals its seed and
+	// publicR is derived from both, or, with FiatShamir, from the commitments
+	start = time.Now()
+	var publicR fr_bn254.Element
+	if FiatShamir {
+		publicR = DeriveFiatShamirChallenge(ElectionID, VoteCommitments(clients))
+	} else if publicR, err = DeriveChallenge(rnd, clients); err != nil {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("challenge: %w", err)
+	}
+	challengeTime := time.Since(start)
+	if err := session.Advance(ProtocolChallenge, publicR); err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+
+	// Step 3:
+	// now the clients can compute the assignment
+	start = time.Now()
+	allAssignment := make([]VoteAssignment, ClientNum)
+	pool.RunAll(len(clients), Workers, func(i int) error {
+		allAssignment[i] = clients[i].GenAssignment(publicR)
+		return nil
+	})
+	prepTime += time.Since(start)
+
+	// now the clients can compute the proofs
+	// we only generate proofs for the first MaxNumOfCheckProof clients
+	memBefore = sampleMemory()
+	start = time.Now()
+	allSubmission, err := GenSubmissions(system, allAssignment, ccs)
+	if err != nil {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("proof generation: %w", err)
+	}
+	if err := session.Advance(ProtocolProve, allSubmission); err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+	// each client proves its nullifier alongside its vote
+	nullifierSubmissions, err := GenNullifierSubmissions(nullifierSystem, clients, nullifierCCS, ElectionID)
+	if err != nil {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("nullifier proof generation: %w", err)
+	}
+	// all the submissions are in: the polls close
+	gate.Close()
+	proofTime := time.Since(start)
+	memAfter = sampleMemory()
+	memory.Prove = newMemoryPhase(&memBefore, &memAfter)
+
+	if SubmissionDir != "" {
+		if err := SaveSubmissions(filepath.Join(SubmissionDir, system.Name()), allSubmission); err != nil {
+			return benchreport.BenchmarkResult{}, fmt.Errorf("save submissions: %w", err)
+		}
+	}
+
+	// check how many bytes are written per client
+	proofSize := 0
+	publicWitnessSize := 0
+	// proofSize is the size of the allSubmission[0].proof
+	// publicWitnessSize is the size of the allSubmission[0].publicWitness
+	// we assume that all the proofs and publicWitnesses have the same size
+	proofSize = len(allSubmission[0].proof)
+	var publicWitnessBytes []byte
+	if allSubmission[0].publicWitness != nil {
+		var buf bytes.Buffer
+		allSubmission[0].publicWitness.WriteTo(&buf)
+		publicWitnessSize = buf.Len()
+		publicWitnessBytes = buf.Bytes()
+	}
+	// the same messages once compressed, see compress.go; the dummies are
+	// random and stay raw
+	var dummyBytes msgBuilder
+	for i := 0; i < len(clients[0].PrivateY); i++ {
+		dummyBytes.putElement(&clients[0].PrivateY[i])
+	}
+	proofWireSize := compressedSize(Compression, allSubmission[0].proof)
+	publicWitnessWireSize := compressedSize(Compression, publicWitnessBytes)
+	dummyWireSize := compressedSize(Compression, dummyBytes.Bytes())
+
+	// the latency of every proof, measured alone and outside of verifyTime
+	latencies, err := MeasureVerifyLatency(allSubmission, vk)
+	if err != nil {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("verify latency: %w", err)
+	}
+	verifyLatency := NewLatencyStats(latencies)
+
+	// in the audit mode the audited clients open their ballot, which the
+	// server checks natively instead of verifying their proofs, see opening.go
+	audited := SelectAudited(rnd, ClientNum, AuditFraction)
+	toVerify, saved := skipAudited(allSubmission, audited)
+
+	// now the server can verify the proofs
+	start = time.Now()
+	if auditErrs := AuditOpenings(field, clients, allSubmission, audited, publicR); len(auditErrs) > 0 {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("%v opening(s) failed the audit, first: %w", len(auditErrs), auditErrs[0])
+	}
+	verifyErrs := VerifySubmissions(toVerify, vk)
+	verifyTime := time.Since(start)
+	if len(verifyErrs) > 0 {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("%v proof(s) failed verification, first: %w", len(verifyErrs), verifyErrs[0])
+	}
+	if err := session.Advance(ProtocolVerify, nil); err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+	if len(audited) > 0 {
+		log.Printf("Audit: %v clients opened their ballot, %v SNARK verification(s) saved\n", len(audited), saved)
+	}
+	// every voter votes once: a nullifier is only accepted once per election
+	start = time.Now()
+	_, nullifierErrs := CheckNullifiers(nullifierSubmissions)
+	if len(nullifierErrs) > 0 {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("%v replayed submission(s), first: %w", len(nullifierErrs), nullifierErrs[0])
+	}
+	if errs := VerifyNullifierSubmissions(nullifierSystem, nullifierSubmissions, nullifierVK, ElectionID); len(errs) > 0 {
+		return benchreport.BenchmarkResult{}, fmt.Errorf("%v nullifier proof(s) failed verification, first: %w", len(errs), errs[0])
+	}
+	verifyTime += time.Since(start)
+	gate.RecordVerified(numCheckedProofs(len(allSubmission)))
+
+	// finally, the server verifies the shuffle and the polynomial evaluations
+	memBefore = sampleMemory()
+	start = time.Now()
+
+	prodFromClient := ClientsProduct(field, allSubmission)
+	prodFromShuffler := ShufflerProduct(field, shuffledPairFirst, shuffledPairSecond, allDummies, publicR)
+
+	// print the product from the shuffler
+	fmt.Printf("prodFromShuffler: %v\n", prodFromShuffler)
+
+	// the product check below is the permutation argument, see
+	// shuffleproof.go, and needs the release fixed before publicR; an
+	// external mixnet commits to nothing
+	if MixnetFile == "" && ReleaseCommitment(packPairs(CandidateNum, shuffledPairFirst, shuffledPairSecond)) != releaseCom {
+		return benchreport.BenchmarkResult{}, ErrBadShuffle
+	}
+
+	// now the server compares the prodFromShuffler and the prodFromClients;
+	// a mismatch is the shuffler's or a client's, which localizeErr tells
+	// apart
+	if prodFromShuffler.Cmp(prodFromClient) != 0 {
+		return benchreport.BenchmarkResult{}, localizeErr(ErrInconsistent, field, clients, shipped, allSubmission, publicR)
+	}
+
+	serverTime := time.Since(start)
+	memAfter = sampleMemory()
+	memory.Aggregate = newMemoryPhase(&memBefore, &memAfter)
+	memory.PeakSys = max(memory.Setup.Sys, memory.Prove.Sys, memory.Aggregate.Sys)
+
+	// now we see if there is any sole winner
+	tally, err := gate.Tally(shuffledPairFirst, shuffledPairSecond, CandidateNum)
+	if err != nil {
+		return benchreport.BenchmarkResult{}, err
+	}
+	openedAt, _ := gate.OpenedAt()
+	log.Printf("Tally gate opened at %v\n", openedAt.Format(time.RFC3339Nano))
+	if err := tally.Pairs.Validate(ClientNum); err != nil {
+		fmt.Printf("The comparison is not correct: %v\n", err)
+	}
+	tally.Print(os.Stdout)
+
+	//now we compute the cost
+
+	// now we compute the communication
+	// the client sends the commitments to the server
+	// the server broadcasts the challenge
+	// the client sends the public witness and the proof to the server
+
+	proofRelatedCommCost := uint64(proofSize) // + publicWitnessSize
+	//commCost := (float64(dummyCostPerClient) + float64(proofSize) + float64(publicWitnessSize) + float64(CommitmentSize) + float64(BN254Size)) / 1024
+	dummyCostPerClient := dummyVecLength * uint64(BN254Size)
+	// the nonce commitment and the revealed nonce
+	nonceCostPerClient := uint64(2 * BN254Size)
+	if FiatShamir {
+		nonceCostPerClient = 0
+	}
+	commCost := uint64(proofSize) + uint64(publicWitnessSize) + BN254Size + CommitmentSize + dummyCostPerClient + nonceCostPerClient
+	commCostCompressed := uint64(proofWireSize) + uint64(publicWitnessWireSize) + BN254Size + CommitmentSize + uint64(dummyWireSize) + nonceCostPerClient
+
+	log.Printf("========Stats (Voting w/ %v Proof on %v)======\n", system.Name(), system.Curve())
+	nbConstraints := ccs.GetNbConstraints()
+	log.Printf("Number of Constraints: %v\n", nbConstraints)
+	log.Printf("============================\n")
+
+	log.Printf("=====Communication Cost (bytes)=====\n")
+	log.Printf("Proof: %v\n", proofRelatedCommCost)
+	log.Printf("Other: %v\n", commCost-proofRelatedCommCost)
+	log.Printf("Total: %v\n", commCost)
+	log.Printf("Total (%v): %v\n", Compression, commCostCompressed)
+	// we now print the breakdown of the communication cost
+	log.Printf("Proof Size %v\n", proofSize)
+	log.Printf("Public Witness Size %v\n", publicWitnessSize)
+	log.Printf("Commitment Size %v\n", CommitmentSize)
+	log.Printf("Challenge Size %v\n", BN254Size)
+	log.Printf("Dummy Size %v\n", dummyCostPerClient)
+	log.Printf("Nonce Size %v\n", nonceCostPerClient)
+	log.Printf("Proof Size (%v) %v\n", Compression, proofWireSize)
+	log.Printf("Public Witness Size (%v) %v\n", Compression, publicWitnessWireSize)
+	log.Printf("Dummy Size (%v) %v\n", Compression, dummyWireSize)
+	log.Printf("============================\n")
+
+	// now we compute the computation cost, the proof times amortized over
+	// the proofs actually generated and checked
+	numChecked := numCheckedProofs(len(allSubmission))
+	checked := time.Duration(numChecked)
+	//23 parts : prep, proof
+	clientTime := prepTime/time.Duration(ClientNum) + proofTime/checked
+	log.Printf("=====Client Computation Cost=====\n")
+	log.Printf("Preparation: %v\n", prepTime/time.Duration(ClientNum))
+	log.Printf("Proof (wall-clock for %v proofs, %v workers): %v\n", numChecked, Workers, proofTime)
+	log.Printf("Proof: %v\n", proofTime/checked)
+	log.Printf("Total: %v\n", clientTime)
+	log.Printf("============================\n")
+
+	// now we compute the server time amortized per client
+	serverTotalTime := serverTime/time.Duration(ClientNum) + verifyTime/checked
+	log.Printf("=====Server Computation Cost=====\n")
+	log.Printf("Other: %v\n", serverTime/time.Duration(ClientNum))
+	log.Printf("Challenge (Fiat-Shamir %v): %v\n", FiatShamir, challengeTime)
+	log.Printf("Commitment root (%v, with the paths of the %v clients): %v\n", hexElement(&commitRoot), len(clients), rootTime)
+	log.Printf("Verify (wall-clock for %v proofs, %v workers): %v\n", numChecked, Workers, verifyTime)
+	log.Printf("Verify: %v\n", verifyTime/checked)
+	log.Printf("Verify latency (one proof at a time): %v\n", verifyLatency)
+	log.Printf("Total: %v\n", serverTotalTime)
+	log.Printf("============================\n")
+
+	// now we compute the storage cost
+	// the proving key size is the storage cost
+	log.Printf("=====Storage Cost (Bytes) =====\n")
+	log.Printf("Proving Key: %v\n", provingKeySize)
+	log.Printf("============================\n")
+
+	// and the memory, after a garbage collection, see metrics.go
+	log.Printf("=====Memory (Bytes)=====\n")
+	log.Printf("Setup: %v\n", memory.Setup)
+	log.Printf("Proof: %v\n", memory.Prove)
+	log.Printf("Aggregation: %v\n", memory.Aggregate)
+	log.Printf("Peak Sys: %v\n", memory.PeakSys)
+	log.Printf("============================\n")
+
+	result := benchreport.BenchmarkResult{
+		Program:      "vote",
+		Backend:      system.Name(),
+		Curve:        system.Curve().String(),
+		Constraints:  nbConstraints,
+		ClientNum:    ClientNum,
+		CorruptedNum: CorruptedNum,
+		CandidateNum: CandidateNum,
+		DummyLen:     dummyVecLength,
+		PrepTime:     prepTime / time.Duration(ClientNum),
+		ProofTime:    proofTime / checked,
+		VerifyTime:   verifyTime / checked,
+		ServerTime:   serverTime / time.Duration(ClientNum),
+		ProofSize:    proofSize,
+		WitnessSize:  publicWitnessSize,
+		PKSize:       provingKeySize,
+		CommCost: benchreport.CommCost{
+			Proof:           proofRelatedCommCost,
+			Other:           commCost - proofRelatedCommCost,
+			Total:           commCost,
+			TotalCompressed: commCostCompressed,
+		},
+		BelowTarget: belowTarget,
+	}
+	for _, rep := range reporters {
+		if err := rep.Report(result); err != nil {
+			return benchreport.BenchmarkResult{}, fmt.Errorf("report: %w", err)
+		}
+	}
+
+	if MetricsFile != "" {
+		if VerboseMetrics {
+			verifyLatency.Samples = latencies
+		}
+		m := RunMetrics{
+			Backend:       system.Name(),
+			Curve:         system.Curve().String(),
+			Clients:       ClientNum,
+			Seed:          Seed,
+			VerifyTotal:   verifyTime,
+			VerifyLatency: verifyLatency,
+			Memory:        memory,
+		}
+		if err := appendMetrics(MetricsFile, m); err != nil {
+			return benchreport.BenchmarkResult{}, fmt.Errorf("metrics: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// localizeErr adds to err the clients whose product does not match their
+// data, as the shuffler received it in shipped, with -localize, and the
+// verdict of the audit of their openings, see AuditMismatch.
+func localizeErr(err error, field Field, clients []ClientState, shipped []ClientState, allSubmission []ClientSubmission, publicR fr_bn254.Element) error {
+	if !Localize {
+		return err
+	}
+	mismatched := LocalizeMismatch(field, SubmissionProducts(allSubmission), ClientsOracle(field, shipped, publicR))
+	blamed, cleared := Blamed(AuditMismatch(field, clients, shipped, allSubmission, mismatched, publicR))
+	return fmt.Errorf("%w: the products of clients %v do not match their data, their openings blame %v and clear %v", err, mismatched, blamed, cleared)
+}
+
+// validateConfig rejects configurations the protocol cannot run with.
+func validateConfig() error {
+	if ClientNum < 1 {
+		return fmt.Errorf("-clients must be positive, got %v", ClientNum)
+	}
+	if CorruptedNum < 0 || float64(ClientNum-CorruptedNum) <= e {
+		return fmt.Errorf("-corrupted must leave more than e, so at least 3, honest clients out of %v, got %v", ClientNum, CorruptedNum)
+	}
+	if CandidateNum < 2 || CandidateNum > MaxCandidateNum {
+		return fmt.Errorf("-candidates must be in [2, %v], got %v", MaxCandidateNum, CandidateNum)
+	}
+	if AuditFraction < 0 || AuditFraction > 1 {
+		return fmt.Errorf("-audit must be in [0, 1], got %v", AuditFraction)
+	}
+	if MaliciousFrac < 0 || MaliciousFrac > 1 {
+		return fmt.Errorf("-malicious-frac must be in [0, 1], got %v", MaliciousFrac)
+	}
+	if TallyQuorum < 0 || TallyQuorum > numCheckedProofs(ClientNum) {
+		return fmt.Errorf("-quorum must be in [0, %v], got %v", numCheckedProofs(ClientNum), TallyQuorum)
+	}
+	if _, err := Params.NewField(Curve); err != nil {
+		return fmt.Errorf("-hash %v on -curve %v: %w", Params.Hash, Curve, err)
+	}
+	return nil
+}
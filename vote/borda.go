@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+	"example/verification/internal/hasher"
+)
+
+// In a Borda count each voter gives every candidate a score, a permutation of
+// 0, ..., candidateNum-1, and the candidate with the largest sum wins. The
+// client sends the shuffler one pair (2 * candidateNum + i, score) per
+// candidate i, packed as (2 * candidateNum + i) * candidateNum + score: the
+// first element is shifted past the approvals of approval.go, so that the
+// three ballots never share a packed value and every tally rejects the pairs
+// of the others.
+//
+// The Borda scores of a ranked ballot are derived from its pairs by
+// ComputeBorda; this ballot sends the scores themselves, C pairs instead of
+// C(C-1)/2.
+
+type BordaVoteCircuit struct {
+	// Scores[i] is the score the voter gives candidate i
+	Scores []frontend.Variable
+
+	// The following are for the polynomial evaluation
+	PrivateMask frontend.Variable
+	// DummyVec are the dummies the client sends to the shuffler; the mask is
+	// their product, as the server multiplies them in. They are committed, so
+	// the mask is the one of the dummies fixed before publicR.
+	DummyVec   []frontend.Variable
+	PublicR    frontend.Variable `gnark:",public"`
+	PublicProd frontend.Variable `gnark:",public"`
+
+	// The following are for the commitment
+	PublicCommitment frontend.Variable `gnark:",public"`
+	PrivateSalt      frontend.Variable
+
+	// Hash is the hash of the commitment, see params.go
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+func (circuit *BordaVoteCircuit) Define(api frontend.API) error {
+	candidateNum := len(circuit.Scores)
+
+	// the scores are a permutation of 0, ..., candidateNum-1: their
+	// polynomial agrees with the one of the fixed set at the challenge
+	fixedProd := frontend.Variable(1)
+	for k := 0; k < candidateNum; k++ {
+		fixedProd = api.Mul(fixedProd, api.Add(k, circuit.PublicR))
+	}
+	gadget.Label(api, gadget.Permutation).AssertIsEqual(PolyEvalInCircuit(api, circuit.Scores, circuit.PublicR), fixedProd)
+
+	// the pair of candidate i is (2 * candidateNum + i) * candidateNum + score
+	processedVec := make([]frontend.Variable, candidateNum)
+	for i := 0; i < candidateNum; i++ {
+		processedVec[i] = api.Add((2*candidateNum+i)*candidateNum, circuit.Scores[i])
+	}
+
+	// The following is for the polynomial evaluation
+	privateProd := PolyEvalInCircuit(api, processedVec, circuit.PublicR)
+	privateProd = api.Mul(privateProd, circuit.PrivateMask)
+	gadget.Label(api, gadget.Product).AssertIsEqual(privateProd, circuit.PublicProd)
+
+	dummyProd := frontend.Variable(1)
+	for i := 0; i < len(circuit.DummyVec); i++ {
+		dummyProd = api.Mul(dummyProd, circuit.DummyVec[i])
+	}
+	gadget.Label(api, gadget.Product).AssertIsEqual(circuit.PrivateMask, dummyProd)
+
+	// the commitment binds the pairs sent to the shuffler
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	h.Write(processedVec...)
+	h.Write(circuit.DummyVec...)
+	h.Write(circuit.PrivateMask)
+	h.Write(circuit.PrivateSalt)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicCommitment, h.Sum())
+	return nil
+}
+
+// NewBordaVoteCircuit allocates an empty Borda circuit for candidateNum
+// candidates and dummyNum dummies, hashing with Params.Hash, ready to be
+// compiled. Its public variables are VotePublic.
+func NewBordaVoteCircuit(candidateNum int, dummyNum int) BordaVoteCircuit {
+	return BordaVoteCircuit{
+		Scores:           make([]frontend.Variable, candidateNum),
+		PrivateMask:      0,
+		DummyVec:         make([]frontend.Variable, dummyNum),
+		PublicR:          0,
+		PublicProd:       0,
+		PublicCommitment: 0,
+		PrivateSalt:      0,
+		Hash:             Params.Hash,
+	}
+}
+
+// InitBordaRand samples a random Borda ballot for the client, drawn from rnd
+// like InitRand: PairFirst are the candidates, shifted by 2 * CandidateNum,
+// and PairSecond their scores.
+func (c *ClientState) InitBordaRand(rnd *benchrand.Rand, field Field, dummyNum uint64) {
+	c.SortedCandidate = nil
+	c.PairFirst = make([]fr_bn254.Element, CandidateNum)
+	c.PairSecond = make([]fr_bn254.Element, CandidateNum)
+	for i := 0; i < CandidateNum; i++ {
+		c.PairFirst[i] = fr_bn254.NewElement(uint64(2*CandidateNum + i))
+		c.PairSecond[i] = fr_bn254.NewElement(uint64(i))
+	}
+	rnd.Shuffle(CandidateNum, func(i, j int) {
+		c.PairSecond[i], c.PairSecond[j] = c.PairSecond[j], c.PairSecond[i]
+	})
+	c.PrivateX = packPairs(CandidateNum, c.PairFirst, c.PairSecond)
+
+	c.initSecrets(rnd, field, dummyNum)
+}
+
+// GenBordaAssignment is GenAssignment for the Borda ballot of the client.
+func (c *ClientState) GenBordaAssignment(publicR fr_bn254.Element) BordaVoteCircuit {
+	scores := make([]frontend.Variable, len(c.PairSecond))
+	for i := 0; i < len(scores); i++ {
+		scores[i] = frontendVariable(&c.PairSecond[i])
+	}
+
+	dummyVec := make([]frontend.Variable, len(c.PrivateY))
+	for i := 0; i < len(dummyVec); i++ {
+		dummyVec[i] = frontendVariable(&c.PrivateY[i])
+	}
+
+	publicProd := c.ComputePolyEval(publicR)
+
+	return BordaVoteCircuit{
+		Scores:           scores,
+		PrivateMask:      frontend.Variable(c.PrivateMask),
+		DummyVec:         dummyVec,
+		PublicR:          frontendVariable(&publicR),
+		PublicProd:       frontend.Variable(publicProd),
+		PublicCommitment: frontend.Variable(c.PublicCom),
+		PrivateSalt:      frontendVariable(&c.PrivateSalt),
+	}
+}
+
+// ComputeBordaScores returns the sum of the scores of every candidate in the
+// shuffled Borda pairs of candidateNum candidates.
+func ComputeBordaScores(shuffledPairFirst, shuffledPairSecond []fr_bn254.Element, candidateNum int) ([]uint64, error) {
+	if len(shuffledPairFirst) != len(shuffledPairSecond) {
+		return nil, fmt.Errorf("%w: %v first and %v second elements", ErrBallotKind, len(shuffledPairFirst), len(shuffledPairSecond))
+	}
+	scores := make([]uint64, candidateNum)
+	for i := 0; i < len(shuffledPairFirst); i++ {
+		a, b := shuffledPairFirst[i], shuffledPairSecond[i]
+		if !a.IsUint64() || !b.IsUint64() || a.Uint64() < uint64(2*candidateNum) || a.Uint64() >= uint64(3*candidateNum) || b.Uint64() >= uint64(candidateNum) {
+			return nil, fmt.Errorf("%w: pair %v is not a Borda score of one of %v candidates", ErrBallotKind, i, candidateNum)
+		}
+		scores[a.Uint64()-uint64(2*candidateNum)] += b.Uint64()
+	}
+	return scores, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/benchrand"
+)
+
+func TestBordaVoteCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewBordaVoteCircuit(CandidateNum, 4)
+
+	var client ClientState
+	client.InitBordaRand(benchrand.New(1), bn254Field{}, 4)
+	assignment := client.GenBordaAssignment(randomFr())
+	assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// two candidates with the same score, with the packed pairs and the
+	// commitment consistent with them
+	client.PairSecond[0] = client.PairSecond[1]
+	client.PrivateX = packPairs(CandidateNum, client.PairFirst, client.PairSecond)
+	client.PublicCom = client.Opening().Commit(bn254Field{})
+	assignment = client.GenBordaAssignment(randomFr())
+	assert.ProverFailed(&circuit, &assignment, test.WithCurves(ecc.BN254))
+}
+
+func TestApprovalVoteCircuitCap(t *testing.T) {
+	circuit := NewApprovalVoteCircuit(CandidateNum, 2)
+	circuit.MaxApprovals = 2
+	var client ClientState
+	client.InitApprovalRand(benchrand.New(2), bn254Field{}, 2)
+	for approved := 0; approved <= 3; approved++ {
+		for i := 0; i < CandidateNum; i++ {
+			client.PairSecond[i].SetZero()
+			if i < approved {
+				client.PairSecond[i].SetOne()
+			}
+		}
+		client.PrivateX = packPairs(CandidateNum, client.PairFirst, client.PairSecond)
+		client.PublicCom = client.Opening().Commit(bn254Field{})
+		assignment := client.GenApprovalAssignment(randomFr())
+		err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+		if approved <= 2 && err != nil {
+			t.Fatalf("%v approvals under a cap of 2: %v", approved, err)
+		}
+		if approved > 2 && err == nil {
+			t.Fatalf("%v approvals over a cap of 2 are accepted", approved)
+		}
+	}
+}
+
+// TestElectionRules runs an election of 20 clients under the approval and the
+// Borda rules: every ballot satisfies its circuit, the shuffled pairs match
+// the products of the clients, and the tally elects the candidates that lead
+// the ballots.
+func TestElectionRules(t *testing.T) {
+	field := bn254Field{}
+	rules := []struct {
+		name    string
+		circuit frontend.Circuit
+		init    func(c *ClientState, rnd *benchrand.Rand)
+		assign  func(c *ClientState, publicR fr_bn254.Element) frontend.Circuit
+		tally   func(pairFirst, pairSecond []fr_bn254.Element, candidateNum int) ([]uint64, error)
+	}{
+		{
+			"approval",
+			func() frontend.Circuit { c := NewApprovalVoteCircuit(CandidateNum, 2); return &c }(),
+			func(c *ClientState, rnd *benchrand.Rand) { c.InitApprovalRand(rnd, field, 2) },
+			func(c *ClientState, publicR fr_bn254.Element) frontend.Circuit {
+				a := c.GenApprovalAssignment(publicR)
+				return &a
+			},
+			ComputeApprovals,
+		},
+		{
+			"borda",
+			func() frontend.Circuit { c := NewBordaVoteCircuit(CandidateNum, 2); return &c }(),
+			func(c *ClientState, rnd *benchrand.Rand) { c.InitBordaRand(rnd, field, 2) },
+			func(c *ClientState, publicR fr_bn254.Element) frontend.Circuit {
+				a := c.GenBordaAssignment(publicR)
+				return &a
+			},
+			ComputeBordaScores,
+		},
+	}
+	for _, rule := range rules {
+		t.Run(rule.name, func(t *testing.T) {
+			rnd := benchrand.New(6)
+			publicR := rnd.Fr()
+			clients := make([]ClientState, 20)
+			expected := make([]uint64, CandidateNum)
+			clientsProd := big.NewInt(1)
+			for i := range clients {
+				rule.init(&clients[i], rnd)
+				assignment := rule.assign(&clients[i], publicR)
+				if err := test.IsSolved(rule.circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+					t.Fatalf("client %v: %v", i, err)
+				}
+				clientsProd = field.Mul(clientsProd, clients[i].ComputePolyEval(publicR))
+				for j := 0; j < CandidateNum; j++ {
+					expected[j] += clients[i].PairSecond[j].Uint64()
+				}
+			}
+
+			pairFirst, pairSecond := ShufflePairs(rnd, clients)
+			dummies := ShuffleDummies(rnd, clients)
+			if ShufflerProduct(field, pairFirst, pairSecond, dummies, publicR).Cmp(clientsProd) != 0 {
+				t.Fatal("the product of the shuffled ballots is not the product of the clients")
+			}
+			scores, err := rule.tally(pairFirst, pairSecond, CandidateNum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(scores, expected) {
+				t.Fatalf("scores %v, expected %v", scores, expected)
+			}
+			if leaders := Leaders(scores); !reflect.DeepEqual(leaders, Leaders(expected)) || len(leaders) == 0 {
+				t.Fatalf("leaders %v, expected %v", leaders, Leaders(expected))
+			}
+			if _, err := ComputeTally(pairFirst, pairSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+				t.Fatalf("the ranked tally of %v ballots: got %v, expected ErrBallotKind", rule.name, err)
+			}
+		})
+	}
+	// the approvals and the Borda scores are not mistaken for each other
+	var approval, borda ClientState
+	approval.InitApprovalRand(benchrand.New(7), field, 2)
+	borda.InitBordaRand(benchrand.New(7), field, 2)
+	if _, err := ComputeBordaScores(approval.PairFirst, approval.PairSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("the Borda tally of approvals: got %v, expected ErrBallotKind", err)
+	}
+	if _, err := ComputeApprovals(borda.PairFirst, borda.PairSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("the approval tally of Borda scores: got %v, expected ErrBallotKind", err)
+	}
+}
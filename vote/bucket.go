@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/hasher"
+)
+
+// With a single product, one corrupted element anywhere in the release of the
+// shuffler fails the whole round. In the bucketed variant every element, a
+// packed pair or a dummy, falls in one of k buckets by the low bits of its
+// hash under the hash of the commitment, which anybody can recompute. A
+// client proves one product per bucket, of its pairs and its dummies in that
+// bucket, and the server checks every bucket on its own: a corrupted element
+// only fails its bucket, and the others can be tallied.
+//
+// The bucket of an element does not depend on its client nor on publicR, so
+// a corruption that keeps an element in its bucket fails that bucket only,
+// and one that moves it fails the bucket it left and the one it joined.
+
+var ErrBucketNum = errors.New("bucket: the number of buckets must be a power of two")
+
+// checkBucketNum checks that k buckets are addressed by the low bits of a
+// hash.
+func checkBucketNum(k int) error {
+	if k < 1 || k&(k-1) != 0 {
+		return fmt.Errorf("%w, got %v", ErrBucketNum, k)
+	}
+	return nil
+}
+
+// BucketOf returns the bucket of x out of k: the low bits of the hash of x
+// under the commitment hash of field.
+func BucketOf(field Field, x fr_bn254.Element, k int) int {
+	digest := field.Commit([]*big.Int{x.BigInt(new(big.Int))})
+	low := new(big.Int).And(digest, big.NewInt(int64(k-1)))
+	return int(low.Int64())
+}
+
+// BucketProducts returns, for each of the k buckets, the evaluation at r of
+// the packed pairs in the bucket times the product of the dummies in the
+// bucket. An empty bucket evaluates to one.
+func BucketProducts(field Field, packed []fr_bn254.Element, dummies []fr_bn254.Element, r fr_bn254.Element, k int) []*big.Int {
+	packedIn := make([][]fr_bn254.Element, k)
+	for i := range packed {
+		b := BucketOf(field, packed[i], k)
+		packedIn[b] = append(packedIn[b], packed[i])
+	}
+	dummiesIn := make([][]fr_bn254.Element, k)
+	for i := range dummies {
+		b := BucketOf(field, dummies[i], k)
+		dummiesIn[b] = append(dummiesIn[b], dummies[i])
+	}
+	prods := make([]*big.Int, k)
+	for b := range prods {
+		prods[b] = field.Mul(field.PolyEval(packedIn[b], r), field.Prod(dummiesIn[b]))
+	}
+	return prods
+}
+
+// ShufflerBucketProducts is ShufflerProduct per bucket: the products the
+// server recomputes from the shuffled data, see BucketProducts.
+func ShufflerBucketProducts(field Field, pairFirst []fr_bn254.Element, pairSecond []fr_bn254.Element, dummies []fr_bn254.Element, publicR fr_bn254.Element, k int) []*big.Int {
+	return BucketProducts(field, packPairs(CandidateNum, pairFirst, pairSecond), dummies, publicR, k)
+}
+
+// ClientsBucketProducts is ClientsProduct per bucket: the product, bucket by
+// bucket, of the PublicProd of the clients.
+func ClientsBucketProducts(field Field, clientProds [][]*big.Int, k int) []*big.Int {
+	prods := make([]*big.Int, k)
+	for b := range prods {
+		prods[b] = big.NewInt(1)
+		for i := range clientProds {
+			prods[b] = field.Mul(prods[b], clientProds[i][b])
+		}
+	}
+	return prods
+}
+
+// MismatchedBuckets returns, in increasing order, the buckets whose product
+// from the shuffler does not match the product from the clients.
+func MismatchedBuckets(fromShuffler []*big.Int, fromClients []*big.Int) []int {
+	var mismatched []int
+	for b := range fromShuffler {
+		if fromShuffler[b].Cmp(fromClients[b]) != 0 {
+			mismatched = append(mismatched, b)
+		}
+	}
+	return mismatched
+}
+
+// BucketPublic returns the public variables of BucketVoteCircuit with k
+// buckets, in the order of the public witness.
+func BucketPublic(k int) []string {
+	public := []string{"PublicR"}
+	public = append(public, gnarkshim.PublicSlice("PublicProd", k)...)
+	return append(public, "PublicCommitment")
+}
+
+// BucketVoteCircuit is VoteCircuit with one PublicProd and one PrivateMask
+// per bucket. The commitment is the hash of the packed pairs, the dummies,
+// the masks and the salt.
+type BucketVoteCircuit struct {
+	// the ranking and its pairs, see VoteCircuit
+	SortedCandidate []frontend.Variable
+	PairFirstVar    []frontend.Variable
+	PairSecondVar   []frontend.Variable
+
+	// PrivateMask[b] is the product of the dummies in bucket b
+	PrivateMask []frontend.Variable
+	DummyVec    []frontend.Variable
+	PublicR     frontend.Variable   `gnark:",public"`
+	PublicProd  []frontend.Variable `gnark:",public"`
+
+	// The following are for the commitment
+	PublicCommitment frontend.Variable `gnark:",public"`
+	PrivateSalt      frontend.Variable
+
+	// Hash is the hash of the commitment and of the buckets, see params.go
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+// bucketSelectors returns the selectors of the bucket of x out of k, one per
+// bucket, 1 for the bucket of x and 0 for the others.
+func bucketSelectors(api frontend.API, hash hasher.HashConfig, x frontend.Variable, k int) ([]frontend.Variable, error) {
+	h, err := hash.Circuit(api)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(x)
+	// the decomposition of the whole digest is unique, so x has one bucket
+	digestBits := api.ToBinary(h.Sum(), api.Compiler().FieldBitLen())
+	nbBits := bits.Len(uint(k - 1))
+	selectors := make([]frontend.Variable, k)
+	for b := 0; b < k; b++ {
+		sel := frontend.Variable(1)
+		for i := 0; i < nbBits; i++ {
+			if b>>i&1 == 1 {
+				sel = api.Mul(sel, digestBits[i])
+			} else {
+				sel = api.Mul(sel, api.Sub(1, digestBits[i]))
+			}
+		}
+		selectors[b] = sel
+	}
+	return selectors, nil
+}
+
+func (circuit *BucketVoteCircuit) Define(api frontend.API) error {
+	k := len(circuit.PublicProd)
+	if err := checkBucketNum(k); err != nil {
+		return err
+	}
+	processedVec := rankingInCircuit(api, circuit.PublicR, circuit.SortedCandidate, circuit.PairFirstVar, circuit.PairSecondVar)
+
+	// every element multiplies the product of its bucket, and 1 into the
+	// others
+	pairProd := make([]frontend.Variable, k)
+	dummyProd := make([]frontend.Variable, k)
+	for b := 0; b < k; b++ {
+		pairProd[b], dummyProd[b] = 1, 1
+	}
+	for i := range processedVec {
+		selectors, err := bucketSelectors(api, circuit.Hash, processedVec[i], k)
+		if err != nil {
+			return err
+		}
+		shifted := api.Sub(api.Add(processedVec[i], circuit.PublicR), 1)
+		for b := 0; b < k; b++ {
+			pairProd[b] = api.Mul(pairProd[b], api.Add(1, api.Mul(selectors[b], shifted)))
+		}
+	}
+	for i := range circuit.DummyVec {
+		selectors, err := bucketSelectors(api, circuit.Hash, circuit.DummyVec[i], k)
+		if err != nil {
+			return err
+		}
+		shifted := api.Sub(circuit.DummyVec[i], 1)
+		for b := 0; b < k; b++ {
+			dummyProd[b] = api.Mul(dummyProd[b], api.Add(1, api.Mul(selectors[b], shifted)))
+		}
+	}
+	for b := 0; b < k; b++ {
+		gadget.Label(api, gadget.Product).AssertIsEqual(circuit.PrivateMask[b], dummyProd[b])
+		gadget.Label(api, gadget.Product).AssertIsEqual(circuit.PublicProd[b], api.Mul(pairProd[b], circuit.PrivateMask[b]))
+	}
+
+	// checking commitment
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	h.Write(processedVec...)
+	h.Write(circuit.DummyVec...)
+	h.Write(circuit.PrivateMask...)
+	h.Write(circuit.PrivateSalt)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicCommitment, h.Sum())
+	return nil
+}
+
+// NewBucketVoteCircuit allocates an empty bucketed vote circuit for
+// candidateNum candidates, dummyNum dummies and k buckets, hashing with
+// Params.Hash, ready to be compiled. Its public variables are BucketPublic(k).
+func NewBucketVoteCircuit(candidateNum int, dummyNum int, k int) BucketVoteCircuit {
+	return BucketVoteCircuit{
+		SortedCandidate:  make([]frontend.Variable, candidateNum),
+		PairFirstVar:     make([]frontend.Variable, candidateNum*(candidateNum-1)/2),
+		PairSecondVar:    make([]frontend.Variable, candidateNum*(candidateNum-1)/2),
+		PrivateMask:      make([]frontend.Variable, k),
+		DummyVec:         make([]frontend.Variable, dummyNum),
+		PublicR:          0,
+		PublicProd:       make([]frontend.Variable, k),
+		PublicCommitment: 0,
+		PrivateSalt:      0,
+		Hash:             Params.Hash,
+	}
+}
+
+// BucketClientState is the ClientState of a client proving one product per
+// bucket out of BucketNum.
+type BucketClientState struct {
+	ClientState
+	BucketNum int
+
+	// BucketMasks are the products of the dummies per bucket and BucketCom
+	// the commitment to the ballot and to them.
+	BucketMasks []*big.Int
+	BucketCom   *big.Int
+}
+
+// InitRand is ClientState.InitRand for k buckets, with the masks and the
+// commitment of the buckets.
+func (c *BucketClientState) InitRand(rnd *benchrand.Rand, field Field, dummyNum uint64, k int) error {
+	if err := checkBucketNum(k); err != nil {
+		return err
+	}
+	c.ClientState.InitRand(rnd, field, dummyNum)
+	c.BucketNum = k
+	c.BucketMasks = BucketProducts(field, nil, c.PrivateY, fr_bn254.Element{}, k)
+	vals := make([]*big.Int, 0, len(c.PrivateX)+len(c.PrivateY)+k+1)
+	for i := range c.PrivateX {
+		vals = append(vals, c.PrivateX[i].BigInt(new(big.Int)))
+	}
+	for i := range c.PrivateY {
+		vals = append(vals, c.PrivateY[i].BigInt(new(big.Int)))
+	}
+	vals = append(vals, c.BucketMasks...)
+	vals = append(vals, c.PrivateSalt.BigInt(new(big.Int)))
+	c.BucketCom = field.Commit(vals)
+	return nil
+}
+
+// ComputePolyEval is ClientState.ComputePolyEval per bucket: it returns the
+// products of the buckets, see BucketProducts.
+func (c *BucketClientState) ComputePolyEval(publicR fr_bn254.Element) []*big.Int {
+	return BucketProducts(c.field, c.PrivateX, c.PrivateY, publicR, c.BucketNum)
+}
+
+// GenAssignment is ClientState.GenAssignment for the products per bucket of
+// the client.
+func (c *BucketClientState) GenAssignment(publicR fr_bn254.Element) BucketVoteCircuit {
+	assignment := c.ClientState.GenAssignment(publicR).Circuit
+	bucketProds := c.ComputePolyEval(publicR)
+	masks := make([]frontend.Variable, c.BucketNum)
+	prods := make([]frontend.Variable, c.BucketNum)
+	for b := 0; b < c.BucketNum; b++ {
+		masks[b], prods[b] = c.BucketMasks[b], bucketProds[b]
+	}
+	return BucketVoteCircuit{
+		SortedCandidate:  assignment.SortedCandidate,
+		PairFirstVar:     assignment.PairFirstVar,
+		PairSecondVar:    assignment.PairSecondVar,
+		PrivateMask:      masks,
+		DummyVec:         assignment.DummyVec,
+		PublicR:          assignment.PublicR,
+		PublicProd:       prods,
+		PublicCommitment: c.BucketCom,
+		PrivateSalt:      assignment.PrivateSalt,
+	}
+}
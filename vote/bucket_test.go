@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+	"example/verification/internal/shuffle"
+)
+
+func TestBucketVoteCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewBucketVoteCircuit(CandidateNum, 4, 4)
+
+	var client BucketClientState
+	if err := client.InitRand(benchrand.New(1), bn254Field{}, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	assignment := client.GenAssignment(randomFr())
+	assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// the product of bucket 1 claimed twice as large
+	changed := assignment
+	changed.PublicProd = append(changed.PublicProd[:0:0], assignment.PublicProd...)
+	changed.PublicProd[1] = bn254Field{}.Mul(assignment.PublicProd[1].(*big.Int), big.NewInt(2))
+	assert.ProverFailed(&circuit, &changed, test.WithCurves(ecc.BN254))
+
+	// a ranking naming a candidate twice, whatever the buckets
+	twice := assignment
+	twice.SortedCandidate = append(twice.SortedCandidate[:0:0], assignment.SortedCandidate...)
+	twice.SortedCandidate[1] = twice.SortedCandidate[0]
+	err := test.IsSolved(&circuit, &twice, ecc.BN254.ScalarField())
+	if err == nil || gadget.ParseFailure(err).Invariant != gadget.Permutation {
+		t.Fatalf("a candidate ranked twice: got %v, expected the permutation to fail", err)
+	}
+	assert.ProverFailed(&circuit, &twice, test.WithCurves(ecc.BN254))
+
+	var odd BucketClientState
+	if err := odd.InitRand(benchrand.New(1), bn254Field{}, 4, 3); !errors.Is(err, ErrBucketNum) {
+		t.Fatalf("3 buckets: got %v, expected ErrBucketNum", err)
+	}
+}
+
+// sameBucket returns an element other than x in the bucket of x.
+func sameBucket(rnd *benchrand.Rand, field Field, x fr_bn254.Element, k int) fr_bn254.Element {
+	for {
+		y := rnd.Fr()
+		if !y.Equal(&x) && BucketOf(field, y, k) == BucketOf(field, x, k) {
+			return y
+		}
+	}
+}
+
+// TestBucketLocalization corrupts the release of the shuffler in one bucket
+// and checks that only that bucket fails.
+func TestBucketLocalization(t *testing.T) {
+	const k = 4
+	field := bn254Field{}
+	rnd := benchrand.New(7)
+	publicR := rnd.Fr()
+	s := shuffle.New(rnd, 2)
+	var clientProds [][]*big.Int
+	for i := 0; i < 6; i++ {
+		var c BucketClientState
+		if err := c.InitRand(rnd, field, 8, k); err != nil {
+			t.Fatal(err)
+		}
+		clientProds = append(clientProds, c.ComputePolyEval(publicR))
+		s.CollectShares(i, shuffle.Records(c.PairFirst, c.PairSecond))
+		s.CollectDummies(i, c.PrivateY)
+	}
+	records, dummies := s.Output()
+	pairFirst, pairSecond := shuffle.Column(records, 0), shuffle.Column(records, 1)
+	fromClients := ClientsBucketProducts(field, clientProds, k)
+
+	// the buckets multiply up to the single product
+	whole := big.NewInt(1)
+	for _, prod := range fromClients {
+		whole = field.Mul(whole, prod)
+	}
+	if whole.Cmp(ShufflerProduct(field, pairFirst, pairSecond, dummies, publicR)) != 0 {
+		t.Fatal("the buckets do not multiply up to the product of the release")
+	}
+	if mismatched := MismatchedBuckets(ShufflerBucketProducts(field, pairFirst, pairSecond, dummies, publicR, k), fromClients); len(mismatched) != 0 {
+		t.Fatalf("honest shuffler: buckets %v fail", mismatched)
+	}
+
+	// a dummy swapped for another of its bucket
+	corrupted := append([]fr_bn254.Element(nil), dummies...)
+	corrupted[3] = sameBucket(rnd, field, dummies[3], k)
+	mismatched := MismatchedBuckets(ShufflerBucketProducts(field, pairFirst, pairSecond, corrupted, publicR, k), fromClients)
+	if want := []int{BucketOf(field, dummies[3], k)}; !reflect.DeepEqual(mismatched, want) {
+		t.Fatalf("corrupted dummy: buckets %v fail, expected %v", mismatched, want)
+	}
+
+	// a dropped pair
+	packed := packPairs(CandidateNum, pairFirst, pairSecond)
+	mismatched = MismatchedBuckets(ShufflerBucketProducts(field, pairFirst[1:], pairSecond[1:], dummies, publicR, k), fromClients)
+	if want := []int{BucketOf(field, packed[0], k)}; !reflect.DeepEqual(mismatched, want) {
+		t.Fatalf("dropped pair: buckets %v fail, expected %v", mismatched, want)
+	}
+}
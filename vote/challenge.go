@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/hash"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/fiatshamir"
+)
+
+// The challenge publicR is derived from a server seed and from one nonce per
+// client, so neither the server nor the clients alone control it:
+//
+//  1. the server publishes SeedCommitment(seed) before any nonce is revealed;
+//  2. during the commit phase each client sends NonceCommitment(nonce);
+//  3. once the commit phase is closed the clients reveal their nonces;
+//  4. the server reveals its seed and everybody computes DeriveChallenge.
+//
+// A client that does not reveal, or reveals a nonce that does not open its
+// commitment, is excluded: its nonce is left out and the exclusion itself is
+// part of the hashed transcript.
+
+const challengeDomain = "shuffle-zkp/vote/challenge/v1"
+
+// FiatShamir derives publicR from the vote commitments of the clients and
+// ElectionID with DeriveFiatShamirChallenge instead of from the server seed
+// and the client nonces. No nonce is exchanged, and anybody holding the
+// commitments can recompute the challenge.
+var FiatShamir = false
+
+var (
+	ErrSeedMismatch = errors.New("challenge: the server seed does not open its commitment")
+	ErrBadReveal    = errors.New("challenge: the nonce does not open its commitment")
+)
+
+// NewServerSeed samples a fresh server seed.
+func NewServerSeed() ([32]byte, error) {
+	var seed [32]byte
+	_, err := rand.Read(seed[:])
+	return seed, err
+}
+
+// SeedCommitment is the commitment the server publishes to its seed.
+func SeedCommitment(seed [32]byte) [32]byte {
+	return sha256.Sum256(append([]byte(challengeDomain+"/seed"), seed[:]...))
+}
+
+// NonceCommitment is the commitment a client sends for its nonce. The nonce is
+// a uniformly random field element, so the MiMC digest hides it.
+func NonceCommitment(nonce fr_bn254.Element) fr_bn254.Element {
+	goMimc := hash.MIMC_BN254.New()
+	b := nonce.Bytes()
+	goMimc.Write(b[:])
+	var com fr_bn254.Element
+	com.SetBytes(goMimc.Sum(nil))
+	return com
+}
+
+// ChallengeRound keeps the server side of the challenge derivation.
+type ChallengeRound struct {
+	seedCommitment   [32]byte
+	nonceCommitments []fr_bn254.Element
+	nonces           []fr_bn254.Element
+	revealed         []bool
+	derived          bool
+}
+
+// NewChallengeRound starts a round once the seed commitment and the nonce
+// commitments of all clients are known.
+func NewChallengeRound(seedCommitment [32]byte, nonceCommitments []fr_bn254.Element) *ChallengeRound {
+	return &ChallengeRound{
+		seedCommitment:   seedCommitment,
+		nonceCommitments: nonceCommitments,
+		nonces:           make([]fr_bn254.Element, len(nonceCommitments)),
+		revealed:         make([]bool, len(nonceCommitments)),
+	}
+}
+
+// Reveal records the nonce revealed by client i.
+func (r *ChallengeRound) Reveal(i int, nonce fr_bn254.Element) error {
+	if r.derived {
+		return errors.New("challenge: the reveal round is over")
+	}
+	if i < 0 || i >= len(r.nonceCommitments) {
+		return fmt.Errorf("challenge: unknown client %v", i)
+	}
+	com := NonceCommitment(nonce)
+	if !com.Equal(&r.nonceCommitments[i]) {
+		return fmt.Errorf("client %v: %w", i, ErrBadReveal)
+	}
+	r.nonces[i] = nonce
+	r.revealed[i] = true
+	return nil
+}
+
+// Excluded returns the clients whose nonce is left out of the challenge.
+func (r *ChallengeRound) Excluded() []int {
+	var excluded []int
+	for i := 0; i < len(r.revealed); i++ {
+		if !r.revealed[i] {
+			excluded = append(excluded, i)
+		}
+	}
+	return excluded
+}
+
+// Derive closes the reveal round and computes the challenge from the revealed
+// seed. The transcript is the seed followed, for every client in order, by its
+// index, a one-byte reveal flag and, if revealed, its nonce.
+func (r *ChallengeRound) Derive(seed [32]byte) (fr_bn254.Element, error) {
+	var publicR fr_bn254.Element
+	if SeedCommitment(seed) != r.seedCommitment {
+		return publicR, ErrSeedMismatch
+	}
+	r.derived = true
+
+	h := sha256.New()
+	h.Write([]byte(challengeDomain))
+	h.Write(seed[:])
+	var buf [8]byte
+	for i := 0; i < len(r.nonceCommitments); i++ {
+		binary.BigEndian.PutUint64(buf[:], uint64(i))
+		h.Write(buf[:])
+		if r.revealed[i] {
+			h.Write([]byte{1})
+			b := r.nonces[i].Bytes()
+			h.Write(b[:])
+		} else {
+			h.Write([]byte{0})
+		}
+	}
+	publicR.SetBytes(h.Sum(nil))
+	return publicR, nil
+}
+
+// DeriveChallenge runs a whole round between the server, whose seed is drawn
+// from rnd, and the clients. Clients whose reveal does not open their
+// commitment are excluded.
+func DeriveChallenge(rnd *benchrand.Rand, clients []ClientState) (fr_bn254.Element, error) {
+	var seed [32]byte
+	if _, err := rnd.Read(seed[:]); err != nil {
+		return fr_bn254.Element{}, err
+	}
+	nonceCommitments := make([]fr_bn254.Element, len(clients))
+	for i := 0; i < len(clients); i++ {
+		nonceCommitments[i] = clients[i].NonceCom
+	}
+	round := NewChallengeRound(SeedCommitment(seed), nonceCommitments)
+	for i := 0; i < len(clients); i++ {
+		// a failed reveal only excludes the client
+		round.Reveal(i, clients[i].Nonce)
+	}
+	return round.Derive(seed)
+}
+
+// DeriveFiatShamirChallenge is the Fiat-Shamir challenge of the round of the
+// given commitments, in the order of the clients: see fiatshamir.Challenge.
+func DeriveFiatShamirChallenge(round uint64, commitments []fr_bn254.Element) fr_bn254.Element {
+	return fiatshamir.Challenge(challengeDomain+"/fiat-shamir", round, commitments)
+}
+
+// VoteCommitments returns the vote commitments of the clients, in order, as
+// elements of the BN254 scalar field.
+func VoteCommitments(clients []ClientState) []fr_bn254.Element {
+	commitments := make([]fr_bn254.Element, len(clients))
+	for i := 0; i < len(clients); i++ {
+		commitments[i].SetBigInt(clients[i].PublicCom)
+	}
+	return commitments
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/benchrand"
+)
+
+func newNonces(n int) ([]fr_bn254.Element, []fr_bn254.Element) {
+	nonces := make([]fr_bn254.Element, n)
+	nonceCommitments := make([]fr_bn254.Element, n)
+	for i := 0; i < n; i++ {
+		nonces[i] = randomFr()
+		nonceCommitments[i] = NonceCommitment(nonces[i])
+	}
+	return nonces, nonceCommitments
+}
+
+func TestChallengeNonRevealingClient(t *testing.T) {
+	seed, err := NewServerSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonces, nonceCommitments := newNonces(4)
+
+	// client 2 stays silent, client 3 reveals a nonce that does not open its commitment
+	round := NewChallengeRound(SeedCommitment(seed), nonceCommitments)
+	for _, i := range []int{0, 1} {
+		if err := round.Reveal(i, nonces[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := round.Reveal(3, nonces[2]); !errors.Is(err, ErrBadReveal) {
+		t.Fatalf("expected a bad reveal, got %v", err)
+	}
+	if excluded := round.Excluded(); !reflect.DeepEqual(excluded, []int{2, 3}) {
+		t.Fatalf("excluded %v, expected [2 3]", excluded)
+	}
+	partial, err := round.Derive(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := round.Reveal(2, nonces[2]); err == nil {
+		t.Fatal("a reveal after the derivation must be rejected")
+	}
+
+	// the same reveals give the same challenge, while a full reveal does not
+	again := NewChallengeRound(SeedCommitment(seed), nonceCommitments)
+	again.Reveal(0, nonces[0])
+	again.Reveal(1, nonces[1])
+	if r, _ := again.Derive(seed); !r.Equal(&partial) {
+		t.Fatal("the challenge is not a function of the transcript")
+	}
+	full := NewChallengeRound(SeedCommitment(seed), nonceCommitments)
+	for i := 0; i < len(nonces); i++ {
+		full.Reveal(i, nonces[i])
+	}
+	if r, _ := full.Derive(seed); r.Equal(&partial) {
+		t.Fatal("the excluded clients are not bound into the challenge")
+	}
+}
+
+func TestChallengeServerCannotRegrindSeed(t *testing.T) {
+	seed, err := NewServerSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonces, nonceCommitments := newNonces(3)
+	round := NewChallengeRound(SeedCommitment(seed), nonceCommitments)
+	for i := 0; i < len(nonces); i++ {
+		if err := round.Reveal(i, nonces[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// after seeing the reveals the server tries another seed
+	otherSeed, err := NewServerSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := round.Derive(otherSeed); !errors.Is(err, ErrSeedMismatch) {
+		t.Fatalf("expected the seed commitment to bind the server, got %v", err)
+	}
+	if _, err := round.Derive(seed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeriveFiatShamirChallenge(t *testing.T) {
+	clients := make([]ClientState, 3)
+	for i := range clients {
+		clients[i].InitRand(benchrand.New(uint64(i+1)), bn254Field{}, 2)
+	}
+	commitments := VoteCommitments(clients)
+	r := DeriveFiatShamirChallenge(1, commitments)
+	if again := DeriveFiatShamirChallenge(1, VoteCommitments(clients)); !again.Equal(&r) {
+		t.Fatal("the same commitments derive different challenges")
+	}
+	if other := DeriveFiatShamirChallenge(2, commitments); other.Equal(&r) {
+		t.Fatal("another election derives the same challenge")
+	}
+	commitments[1] = randomFr()
+	if changed := DeriveFiatShamirChallenge(1, commitments); changed.Equal(&r) {
+		t.Fatal("changing a commitment does not change the challenge")
+	}
+}
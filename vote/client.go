@@ -0,0 +1,243 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"example/verification/internal/hasher"
+)
+
+// RunClient takes part in one election of the server at addr as a single
+// voter. The proof system, the circuit and the number of dummies are announced
+// by the server; the keys are loaded from KeyDir, which has to be the one of
+// the server. shufflerAddr overrides the shuffler address announced by the
+// server if it is not empty. It returns the outcome sent by the server.
+func RunClient(addr string, shufflerAddr string, timeout time.Duration) (string, error) {
+	return RunRegisteredClient(addr, shufflerAddr, timeout, nil)
+}
+
+// RunRegisteredClient is RunClient for the client provisioned with bundle,
+// see provision.go: it identifies to a server with a registry and derives
+// the salt of its commitment from its master secret. A nil bundle is a client
+// that was not provisioned.
+func RunRegisteredClient(addr string, shufflerAddr string, timeout time.Duration, bundle *Bundle) (string, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// the server answers once it is set up and the join round is over
+	p, err := readMessage(conn, msgHello)
+	if err != nil {
+		return "", fmt.Errorf("hello: %w", err)
+	}
+	var hello helloMsg
+	if err := hello.decode(p); err != nil {
+		return "", fmt.Errorf("hello: %w", err)
+	}
+	if bundle != nil {
+		if err := bundle.Params.check(&hello); err != nil {
+			return "", err
+		}
+	}
+	if hello.Identify {
+		if bundle == nil {
+			return "", ErrNotProvisioned
+		}
+		cred := &bundle.Credential
+		identify := identifyMsg{
+			ClientID:  cred.ClientID,
+			Signature: ed25519.Sign(cred.PrivateKey, identifyMessage(bundle.Params.ElectionID, hello.SeedCommitment, hello.Token)),
+		}
+		if err := writeMessage(conn, msgIdentify, identify.encode()); err != nil {
+			return "", fmt.Errorf("identify: %w", err)
+		}
+	}
+	if shufflerAddr == "" {
+		shufflerAddr = hello.ShufflerAddr
+	}
+	// compress only if the server accepts the codec
+	codec := Compression
+	if !hello.Codecs.has(codec) {
+		codec = CodecNone
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	system, err := NewProofSystem(hello.Backend, hello.Curve)
+	if err != nil {
+		return "", err
+	}
+	field, err := NewField(hello.Curve)
+	if err != nil {
+		return "", err
+	}
+	// only written when it differs, so that clients running in the process of
+	// the server do not race on it
+	if CandidateNum != int(hello.CandidateNum) {
+		CandidateNum = int(hello.CandidateNum)
+	}
+	circuit := NewVoteCircuit(CandidateNum, int(hello.DummyNum))
+	ccs, vk, err := CompileAndSetup(system, &circuit, VotePublic, VoteManifest(system, int(hello.DummyNum)))
+	if err != nil {
+		return "", err
+	}
+	checksum, err := CircuitChecksum(ccs)
+	if err != nil {
+		return "", fmt.Errorf("circuit checksum: %w", err)
+	}
+	if checksum != hello.Checksum {
+		return "", fmt.Errorf("the circuit %v differs from the circuit %v of the server", checksum, hello.Checksum)
+	}
+	if !bytes.Equal(vk, hello.VerifyingKey) {
+		return "", errors.New("the keys differ from the keys of the server, run the client with the -keydir of the server")
+	}
+
+	var c ClientState
+	c.Init(field, uint64(hello.DummyNum))
+	if bundle != nil {
+		c.PrivateSalt = bundle.Credential.Salt(bundle.Params.ElectionID, hello.SeedCommitment)
+		c.PublicCom = c.Opening().Commit(field)
+	}
+
+	// commit round: the shares to the shuffler, the commitments to the server
+	shares := sharesMsg{
+		ClientID:   hello.ClientID,
+		Token:      hello.Token,
+		PairFirst:  c.PairFirst,
+		PairSecond: c.PairSecond,
+		Dummies:    c.PrivateY,
+	}
+	shufflerConn, err := net.Dial("tcp", shufflerAddr)
+	if err != nil {
+		return "", fmt.Errorf("shuffler: %w", err)
+	}
+	shufflerConn.SetDeadline(time.Now().Add(timeout))
+	err = writeMessage(shufflerConn, msgShares, shares.encode())
+	shufflerConn.Close()
+	if err != nil {
+		return "", fmt.Errorf("shuffler: %w", err)
+	}
+	commit := commitMsg{PublicCom: c.PublicCom, NonceCom: c.NonceCom}
+	if err := writeMessage(conn, msgCommit, commit.encode()); err != nil {
+		return "", fmt.Errorf("commit: %w", err)
+	}
+
+	// reveal round, after the open round if the commitment was flagged
+	conn.SetDeadline(time.Now().Add(timeout))
+	typ, _, err := readOneOf(conn, msgOpenRequest, msgRevealRequest)
+	if err != nil {
+		return "", fmt.Errorf("reveal: %w", err)
+	}
+	if typ == msgOpenRequest {
+		if _, err := writeCompressed(conn, msgOpening, encodeOpening(c.Opening()), codec); err != nil {
+			return "", fmt.Errorf("opening: %w", err)
+		}
+		conn.SetDeadline(time.Now().Add(timeout))
+		if _, err := readMessage(conn, msgRevealRequest); err != nil {
+			return "", fmt.Errorf("reveal: %w", err)
+		}
+	}
+	var reveal msgBuilder
+	reveal.putElement(&c.Nonce)
+	if err := writeMessage(conn, msgReveal, reveal.Bytes()); err != nil {
+		return "", fmt.Errorf("reveal: %w", err)
+	}
+
+	// prove round
+	conn.SetDeadline(time.Now().Add(timeout))
+	p, err = readMessage(conn, msgChallenge)
+	if err != nil {
+		return "", fmt.Errorf("challenge: %w", err)
+	}
+	var challenge challengeMsg
+	if err := challenge.decode(p); err != nil {
+		return "", fmt.Errorf("challenge: %w", err)
+	}
+	if SeedCommitment(challenge.Seed) != hello.SeedCommitment {
+		return "", ErrSeedMismatch
+	}
+	c.PublicR = challenge.PublicR
+	assignment := c.GenAssignment(c.PublicR)
+	proof, publicWitness, err := GenProof(system, assignment.Circuit, ccs)
+	if err != nil {
+		return "", fmt.Errorf("proof generation: %w", err)
+	}
+	submission := ClientSubmission{publicWitness: publicWitness, publicProd: assignment.PublicProd, proof: proof, system: system}
+	var buf bytes.Buffer
+	if _, err := submission.WriteTo(&buf); err != nil {
+		return "", err
+	}
+	if _, err := writeCompressed(conn, msgSubmission, buf.Bytes(), codec); err != nil {
+		return "", fmt.Errorf("submission: %w", err)
+	}
+
+	// the server verifies every client before answering
+	conn.SetDeadline(time.Now().Add(2 * timeout))
+	p, err = readMessage(conn, msgResult)
+	if err != nil {
+		return "", fmt.Errorf("result: %w", err)
+	}
+	var res resultMsg
+	if err := res.decode(p); err != nil {
+		return "", fmt.Errorf("result: %w", err)
+	}
+	if !res.OK {
+		return "", fmt.Errorf("%w: %v", ErrAborted, res.Message)
+	}
+	return res.Message, nil
+}
+
+// clientMain is the entry point of "vote client".
+func clientMain(args []string) int {
+	fs := flag.NewFlagSet("client", flag.ExitOnError)
+	addr := fs.String("server", "localhost:7000", "address of the server")
+	shufflerAddr := fs.String("shuffler", "", "address of the shuffler, empty for the one announced by the server")
+	fs.StringVar(&KeyDir, "keydir", "keys", "directory caching the circuit and its keys, shared with the server")
+	timeout := fs.Duration("timeout", time.Minute, "deadline of every round")
+	compression := fs.String("compress", Compression.String(), "codec of the compressible messages, none, deflate or zstd")
+	fs.BoolVar(&DebugSolver, "debug-solver", DebugSolver, "name the invariant of the circuit the witness breaks when the proof fails")
+	bundleFile := fs.String("bundle", "", "bundle of the client provisioned with \"vote admin provision\", empty for a client that was not")
+	bundleKey := fs.String("bundle-key", "", "key of the -bundle, in hex, as in bundle-keys.txt")
+	fs.Parse(args)
+	var err error
+	if Compression, err = ParseCodec(*compression); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+	var bundle *Bundle
+	if *bundleFile != "" {
+		if bundle, err = LoadBundle(*bundleFile, *bundleKey); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+			return 2
+		}
+		// the hash is not announced by the server
+		if Params.Hash, err = hasher.Parse(bundle.Params.Hash); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+			return 2
+		}
+		// the server of the bundle, unless -server is given
+		given := false
+		fs.Visit(func(f *flag.Flag) { given = given || f.Name == "server" })
+		if !given && bundle.Params.Server != "" {
+			*addr = bundle.Params.Server
+		}
+	}
+
+	res, err := RunRegisteredClient(*addr, *shufflerAddr, *timeout, bundle)
+	if err != nil {
+		log.Printf("client: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Server: %v\n", res)
+	return 0
+}
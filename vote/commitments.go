@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// Two clients sending the same commitment either drew the same salt, which
+// does not happen by accident, or one copied the submission of the other. The
+// server enforces that the commitments of an epoch are unique under one of
+// the policies below.
+
+// DuplicatePolicy is how the server handles a commitment seen twice.
+type DuplicatePolicy int
+
+const (
+	// RejectDuplicates keeps the first client and rejects the later one.
+	RejectDuplicates DuplicatePolicy = iota
+	// AuditDuplicates keeps both clients, flags them and asks them to open
+	// their commitment.
+	AuditDuplicates
+)
+
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case RejectDuplicates:
+		return "reject"
+	case AuditDuplicates:
+		return "audit"
+	}
+	return fmt.Sprintf("DuplicatePolicy(%d)", int(p))
+}
+
+// ParseDuplicatePolicy returns the policy named name, "reject" or "audit".
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	for _, p := range []DuplicatePolicy{RejectDuplicates, AuditDuplicates} {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return RejectDuplicates, fmt.Errorf("unknown duplicate policy %q", name)
+}
+
+var (
+	ErrDuplicateCommitment = errors.New("commitments: the commitment was already submitted")
+	ErrBadOpening          = errors.New("commitments: the opening does not match the commitment")
+)
+
+// CommitmentSet holds the commitments of one epoch.
+type CommitmentSet struct {
+	Policy DuplicatePolicy
+
+	first       map[string]int
+	commitments map[int]*big.Int
+	rejected    []int
+	flagged     map[int]bool
+}
+
+// NewCommitmentSet returns an empty set enforcing policy.
+func NewCommitmentSet(policy DuplicatePolicy) *CommitmentSet {
+	return &CommitmentSet{
+		Policy:      policy,
+		first:       make(map[string]int),
+		commitments: make(map[int]*big.Int),
+		flagged:     make(map[int]bool),
+	}
+}
+
+// Add records the commitment of client i. Under RejectDuplicates a commitment
+// seen before is not recorded and ErrDuplicateCommitment is returned; under
+// AuditDuplicates it is recorded and both clients are flagged.
+func (s *CommitmentSet) Add(i int, com *big.Int) error {
+	key := string(com.Bytes())
+	j, seen := s.first[key]
+	if !seen {
+		s.first[key] = i
+		s.commitments[i] = com
+		return nil
+	}
+	if s.Policy == RejectDuplicates {
+		s.rejected = append(s.rejected, i)
+		return fmt.Errorf("client %v: %w by client %v", i, ErrDuplicateCommitment, j)
+	}
+	s.commitments[i] = com
+	s.flagged[i] = true
+	s.flagged[j] = true
+	return nil
+}
+
+// Rejected returns the clients rejected by Add, in the order they were added.
+func (s *CommitmentSet) Rejected() []int {
+	return s.rejected
+}
+
+// Flagged returns the flagged clients in increasing order.
+func (s *CommitmentSet) Flagged() []int {
+	flagged := make([]int, 0, len(s.flagged))
+	for i := range s.flagged {
+		flagged = append(flagged, i)
+	}
+	sort.Ints(flagged)
+	return flagged
+}
+
+// Opening is what a client reveals to open its commitment.
+type Opening struct {
+	PrivateX    []fr_bn254.Element
+	PrivateY    []fr_bn254.Element
+	PrivateMask *big.Int
+	PrivateSalt fr_bn254.Element
+}
+
+// Commit returns the commitment opened by o: the hash of the privateX, the
+// dummies privateY, the privateMask and the privateSalt. The dummies are
+// committed so that the vote circuit proves the mask of the dummies the
+// client committed to, not of any vector it picks.
+func (o Opening) Commit(field Field) *big.Int {
+	committed := make([]*big.Int, 0, len(o.PrivateX)+len(o.PrivateY)+2)
+	for i := 0; i < len(o.PrivateX); i++ {
+		committed = append(committed, o.PrivateX[i].BigInt(new(big.Int)))
+	}
+	for i := 0; i < len(o.PrivateY); i++ {
+		committed = append(committed, o.PrivateY[i].BigInt(new(big.Int)))
+	}
+	committed = append(committed, o.PrivateMask, o.PrivateSalt.BigInt(new(big.Int)))
+	return field.Commit(committed)
+}
+
+// Opens reports whether o opens commitment, recomputing it as the client did
+// in Init and comparing it in constant time.
+func (o Opening) Opens(field Field, commitment *big.Int) bool {
+	return constantTimeEqual(field, o.Commit(field), commitment)
+}
+
+// Opening returns the opening of the commitment of the client.
+func (c *ClientState) Opening() Opening {
+	return Opening{PrivateX: c.PrivateX, PrivateY: c.PrivateY, PrivateMask: c.PrivateMask, PrivateSalt: c.PrivateSalt}
+}
+
+// Open checks the opening sent by client i against its recorded commitment.
+func (s *CommitmentSet) Open(field Field, i int, o Opening) error {
+	com, ok := s.commitments[i]
+	if !ok {
+		return fmt.Errorf("client %v: no commitment recorded", i)
+	}
+	if !o.Opens(field, com) {
+		return fmt.Errorf("client %v: %w", i, ErrBadOpening)
+	}
+	return nil
+}
+
+// CheckCommitments records the commitments of the clients, in order, in a
+// fresh set enforcing policy and has the flagged clients open them. It returns
+// one error per rejected client or failed opening.
+func CheckCommitments(field Field, policy DuplicatePolicy, clients []ClientState) (*CommitmentSet, []error) {
+	set := NewCommitmentSet(policy)
+	var errs []error
+	for i := 0; i < len(clients); i++ {
+		if err := set.Add(i, clients[i].PublicCom); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, i := range set.Flagged() {
+		if err := set.Open(field, i, clients[i].Opening()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return set, errs
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/hash"
+)
+
+// duplicateClients returns three clients, the last one a copy of the first.
+func duplicateClients() []ClientState {
+	clients := make([]ClientState, 3)
+	clients[0].Init(bn254Field{}, 4)
+	clients[1].Init(bn254Field{}, 4)
+	clients[2] = clients[0]
+	return clients
+}
+
+func TestCommitmentSetRejectsDuplicate(t *testing.T) {
+	clients := duplicateClients()
+	set, errs := CheckCommitments(bn254Field{}, RejectDuplicates, clients)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrDuplicateCommitment) {
+		t.Fatalf("expected one ErrDuplicateCommitment, got %v", errs)
+	}
+	if !reflect.DeepEqual(set.Rejected(), []int{2}) {
+		t.Fatalf("rejected %v, expected the later client 2", set.Rejected())
+	}
+	if len(set.Flagged()) != 0 {
+		t.Fatalf("flagged %v under the reject policy", set.Flagged())
+	}
+}
+
+func TestCommitmentSetAudit(t *testing.T) {
+	clients := duplicateClients()
+	set, errs := CheckCommitments(bn254Field{}, AuditDuplicates, clients)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if !reflect.DeepEqual(set.Flagged(), []int{0, 2}) || len(set.Rejected()) != 0 {
+		t.Fatalf("flagged %v and rejected %v, expected both copies flagged", set.Flagged(), set.Rejected())
+	}
+
+	// a flagged client that cannot open its commitment
+	opening := clients[2].Opening()
+	opening.PrivateSalt = randomFr()
+	if err := set.Open(bn254Field{}, 2, opening); !errors.Is(err, ErrBadOpening) {
+		t.Fatalf("expected ErrBadOpening, got %v", err)
+	}
+}
+
+// mimcVector is one entry of testdata/mimc_vectors.json: the inputs of a
+// commitment, the blocks its MiMC consumes and the digest, all in hex.
+type mimcVector struct {
+	Inputs []string `json:"inputs"`
+	Blocks []string `json:"blocks"`
+	Digest string   `json:"digest"`
+}
+
+// TestMiMCInputBytes hashes the blocks of MiMCInputBytes with the MiMC of
+// gnark-crypto and a manual big-endian padding of the inputs, and checks both
+// against the commitment and the vectors that clients in other languages
+// are tested with. A change of the convention, here or in gnark-crypto,
+// fails it.
+func TestOpens(t *testing.T) {
+	field := bn254Field{}
+	var client ClientState
+	client.Init(field, 4)
+	if !client.Opening().Opens(field, client.PublicCom) {
+		t.Fatal("the opening of the client does not open its commitment")
+	}
+
+	wrongSalt := client.Opening()
+	wrongSalt.PrivateSalt.SetUint64(1)
+	wrongSalt.PrivateSalt.Add(&wrongSalt.PrivateSalt, &client.PrivateSalt)
+	// the committed values in another order
+	reordered := client.Opening()
+	reordered.PrivateX = append([]fr_bn254.Element(nil), reordered.PrivateX...)
+	reordered.PrivateX[0], reordered.PrivateX[1] = reordered.PrivateX[1], reordered.PrivateX[0]
+	for name, o := range map[string]Opening{"wrong salt": wrongSalt, "reordered": reordered} {
+		if o.Opens(field, client.PublicCom) {
+			t.Fatalf("%v: the opening passes", name)
+		}
+	}
+
+	// a commitment out of the field, with the bytes of the right one past
+	// the width of the field, opens nothing
+	outside := new(big.Int).Lsh(big.NewInt(1), 256)
+	outside.Add(outside, client.PublicCom)
+	if client.Opening().Opens(field, outside) || client.Opening().Opens(field, new(big.Int).Neg(client.PublicCom)) {
+		t.Fatal("a commitment out of the field passes")
+	}
+}
+
+func TestMiMCInputBytes(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "mimc_vectors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []mimcVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) == 0 {
+		t.Fatal("no vector")
+	}
+	for n, v := range vectors {
+		elems := make([]fr_bn254.Element, len(v.Inputs))
+		vals := make([]*big.Int, len(v.Inputs))
+		for i, in := range v.Inputs {
+			x, ok := new(big.Int).SetString(in, 16)
+			if !ok {
+				t.Fatalf("vector %v: bad input %q", n, in)
+			}
+			elems[i].SetBigInt(x)
+			vals[i] = x
+		}
+
+		blocks := MiMCInputBytes(elems...)
+		if len(blocks) != len(v.Blocks) {
+			t.Fatalf("vector %v: %v blocks, expected %v", n, len(blocks), len(v.Blocks))
+		}
+		goMimc := hash.MIMC_BN254.New()
+		manual := hash.MIMC_BN254.New()
+		for i, b := range blocks {
+			if hex.EncodeToString(b) != v.Blocks[i] {
+				t.Fatalf("vector %v: block %v is %x, expected %v", n, i, b, v.Blocks[i])
+			}
+			goMimc.Write(b)
+
+			// the re-implementation: the value, left padded to 32 bytes
+			padded := make([]byte, 32)
+			raw := vals[i].Bytes()
+			copy(padded[32-len(raw):], raw)
+			if !bytes.Equal(padded, b) {
+				t.Fatalf("vector %v: block %v is %x, padded %x", n, i, b, padded)
+			}
+			manual.Write(padded)
+		}
+
+		digest := new(big.Int).SetBytes(goMimc.Sum(nil))
+		if digest.Text(16) != v.Digest {
+			t.Fatalf("vector %v: digest %x, expected %v", n, digest, v.Digest)
+		}
+		if new(big.Int).SetBytes(manual.Sum(nil)).Cmp(digest) != 0 {
+			t.Fatalf("vector %v: the padded inputs hash to another digest", n)
+		}
+		if (bn254Field{}).Commit(vals).Cmp(digest) != 0 {
+			t.Fatalf("vector %v: the commitment is not the hash of the blocks", n)
+		}
+	}
+}
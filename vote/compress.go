@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is the compression of a payload. Only the message classes that
+// compress well are compressed: the field element batches (dummies, pairs,
+// challenges) are uniformly random and are always sent raw.
+type Codec byte
+
+const (
+	CodecNone Codec = iota
+	// CodecDeflate is DEFLATE (RFC 1951) from the standard library.
+	CodecDeflate
+	// CodecZstd is Zstandard (RFC 8878), from github.com/klauspost/compress.
+	CodecZstd
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecDeflate:
+		return "deflate"
+	case CodecZstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("Codec(%d)", byte(c))
+}
+
+// ParseCodec returns the codec named name, "none", "deflate" or "zstd".
+func ParseCodec(name string) (Codec, error) {
+	for _, c := range []Codec{CodecNone, CodecDeflate, CodecZstd} {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return CodecNone, fmt.Errorf("unknown codec %q", name)
+}
+
+// codecMask is a set of codecs, as announced by the server in its hello.
+type codecMask byte
+
+func (m codecMask) has(c Codec) bool { return c == CodecNone || m&(1<<c) != 0 }
+
+func codecsOf(codecs ...Codec) codecMask {
+	var m codecMask
+	for _, c := range codecs {
+		m |= 1 << c
+	}
+	return m
+}
+
+// minCompressionRatio is the largest compressed/raw size ratio worth the
+// decompression on the other side; above it the payload is sent raw.
+const minCompressionRatio = 0.9
+
+// newCompressor returns a writer compressing to w with codec.
+func newCompressor(codec Codec, w io.Writer) (io.WriteCloser, error) {
+	switch codec {
+	case CodecDeflate:
+		return flate.NewWriter(w, flate.DefaultCompression)
+	case CodecZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	return nil, fmt.Errorf("unsupported codec %v", codec)
+}
+
+// newDecompressor returns a reader decompressing r with codec. The window of
+// a zstd frame is bounded by maxSize, so that a hostile header cannot make
+// the reader allocate more than the payload may decompress to.
+func newDecompressor(codec Codec, r io.Reader, maxSize int) (io.ReadCloser, error) {
+	switch codec {
+	case CodecDeflate:
+		return flate.NewReader(r), nil
+	case CodecZstd:
+		window := uint64(maxSize)
+		if window < zstd.MinWindowSize {
+			window = zstd.MinWindowSize
+		}
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(window))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unsupported codec %v", codec)
+}
+
+// compressPayload compresses b with codec. It returns CodecNone and b itself
+// when the payload does not shrink below minCompressionRatio, which is the
+// case of every batch of field elements, or when the compression fails.
+func compressPayload(codec Codec, b []byte) (Codec, []byte) {
+	if codec == CodecNone || len(b) == 0 {
+		return CodecNone, b
+	}
+	var buf bytes.Buffer
+	w, err := newCompressor(codec, &buf)
+	if err != nil {
+		return CodecNone, b
+	}
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return CodecNone, b
+	}
+	if w.Close() != nil || float64(buf.Len()) > minCompressionRatio*float64(len(b)) {
+		return CodecNone, b
+	}
+	return codec, buf.Bytes()
+}
+
+// decompressPayload reverses compressPayload. A payload decompressing to
+// more than maxSize bytes is rejected.
+func decompressPayload(codec Codec, b []byte, maxSize int) ([]byte, error) {
+	if codec == CodecNone {
+		return b, nil
+	}
+	r, err := newDecompressor(codec, bytes.NewReader(b), maxSize)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	res, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(res) > maxSize {
+		return nil, errors.New("the payload decompresses to more than the maximum size")
+	}
+	return res, nil
+}
+
+// compressedSize is the size b takes on the wire once compressed with codec.
+func compressedSize(codec Codec, b []byte) int {
+	_, res := compressPayload(codec, b)
+	return len(res)
+}
@@ -0,0 +1,91 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// codecs are the codecs that compress.
+var codecs = []Codec{CodecDeflate, CodecZstd}
+
+func TestCompressedMessage(t *testing.T) {
+	res := resultMsg{OK: true, Message: string(bytes.Repeat([]byte("the consistency check passed "), 64))}
+	payload := res.encode()
+	for _, codec := range codecs {
+		var buf bytes.Buffer
+		n, err := writeCompressed(&buf, msgResult, payload, codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != buf.Len() || n >= len(payload) {
+			t.Fatalf("%v: frame of %v bytes, reported %v, for a payload of %v bytes", codec, buf.Len(), n, len(payload))
+		}
+		if buf.Bytes()[4] != msgResult|msgCompressed || Codec(buf.Bytes()[5]) != codec {
+			t.Fatalf("%v: the frame is not flagged as compressed with %v", codec, codec)
+		}
+		p, err := readMessage(&buf, msgResult)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got resultMsg
+		if err := got.decode(p); err != nil {
+			t.Fatal(err)
+		}
+		if got != res {
+			t.Fatalf("%v: the message changed in the round trip", codec)
+		}
+	}
+}
+
+func TestParseCodec(t *testing.T) {
+	for _, codec := range append(codecs, CodecNone) {
+		if got, err := ParseCodec(codec.String()); err != nil || got != codec {
+			t.Fatalf("ParseCodec(%q) = %v, %v", codec.String(), got, err)
+		}
+	}
+	if _, err := ParseCodec("gzip"); err == nil {
+		t.Fatal("no error for an unknown codec")
+	}
+}
+
+func TestCompressFieldElements(t *testing.T) {
+	// a batch of field elements does not compress and is sent raw
+	var b msgBuilder
+	for i := 0; i < 256; i++ {
+		x := randomFr()
+		b.putElement(&x)
+	}
+	for _, codec := range codecs {
+		if used, _ := compressPayload(codec, b.Bytes()); used != CodecNone {
+			t.Fatalf("the field elements were compressed with %v", used)
+		}
+	}
+	// the shares are never compressed, whatever their content
+	var buf bytes.Buffer
+	if _, err := writeCompressed(&buf, msgShares, make([]byte, 1024), CodecDeflate); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Bytes()[4] != msgShares {
+		t.Fatalf("the shares were compressed")
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	for _, codec := range codecs {
+		used, b := compressPayload(codec, make([]byte, 1<<16))
+		if used != codec {
+			t.Fatalf("the zeros were not compressed with %v", codec)
+		}
+		if _, err := decompressPayload(codec, b, 1<<16); err != nil {
+			t.Fatalf("%v: %v", codec, err)
+		}
+		if _, err := decompressPayload(codec, b, 1<<16-1); err == nil {
+			t.Fatalf("%v: no error for a payload over the limit", codec)
+		}
+		if _, err := decompressPayload(Codec(7), b, 1<<16); err == nil {
+			t.Fatalf("no error for an unknown codec")
+		}
+	}
+}
@@ -0,0 +1,159 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A configuration file sets the flags of a run: it is an object whose keys are
+// the names of the flags, without the dash, in JSON or, for a .yaml or .yml
+// file, YAML. The flags are the schema: a key that is not a flag, or a value
+// the flag does not parse, is an error naming the key. A list is the comma
+// separated value of a flag such as -dummies.
+//
+// The precedence is flag default < file < command line: a flag given on the
+// command line overrides the file. Whatever the source, the resolved value of
+// every flag is saved in the run directory, and that file is itself a
+// configuration file reproducing the run.
+
+// RunDir is the directory of the results and of the resolved configuration of
+// a run.
+var RunDir = "."
+
+// configFlag is the flag naming the configuration file, which a configuration
+// file cannot set.
+const configFlag = "config"
+
+var ErrConfig = errors.New("config: invalid configuration")
+
+// LoadConfig sets the flags of fs that were not given on the command line
+// from the configuration file name, after fs.Parse. An empty name loads
+// nothing.
+func LoadConfig(fs *flag.FlagSet, name string) error {
+	if name == "" {
+		return nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	var values map[string]any
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&values)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v: %v", ErrConfig, name, err)
+	}
+
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == configFlag || fs.Lookup(key) == nil {
+			return fmt.Errorf("%w: %v: %v: unknown field", ErrConfig, name, key)
+		}
+		value, err := configValue(key, values[key])
+		if err != nil {
+			return fmt.Errorf("%w: %v: %v", ErrConfig, name, err)
+		}
+		if given[key] {
+			continue
+		}
+		if err := fs.Set(key, value); err != nil {
+			return fmt.Errorf("%w: %v: %v: invalid value %q: %v", ErrConfig, name, key, value, err)
+		}
+	}
+	return nil
+}
+
+// configValue returns the flag value of the value v of the field at path.
+func configValue(path string, v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case []any:
+		elems := make([]string, len(v))
+		for i := range v {
+			if _, ok := v[i].([]any); ok {
+				return "", fmt.Errorf("%v[%v]: nested lists are not supported", path, i)
+			}
+			elem, err := configValue(fmt.Sprintf("%v[%v]", path, i), v[i])
+			if err != nil {
+				return "", err
+			}
+			elems[i] = elem
+		}
+		return strings.Join(elems, ","), nil
+	case nil:
+		return "", fmt.Errorf("%v: missing value", path)
+	default:
+		return "", fmt.Errorf("%v: unsupported value of type %T", path, v)
+	}
+}
+
+// ResolvedConfig returns the value of every flag of fs but configFlag, by
+// name.
+func ResolvedConfig(fs *flag.FlagSet) map[string]any {
+	resolved := map[string]any{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == configFlag {
+			return
+		}
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			resolved[f.Name] = f.Value.String()
+			return
+		}
+		// a duration is saved as it is written on the command line
+		if d, ok := getter.Get().(time.Duration); ok {
+			resolved[f.Name] = d.String()
+			return
+		}
+		resolved[f.Name] = getter.Get()
+	})
+	return resolved
+}
+
+// SaveConfig writes the resolved configuration of fs to name, in JSON.
+func SaveConfig(fs *flag.FlagSet, name string) error {
+	data, err := json.MarshalIndent(ResolvedConfig(fs), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0600)
+}
@@ -0,0 +1,109 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testFlags struct {
+	fs       *flag.FlagSet
+	clients  int
+	lambda   uint64
+	frac     float64
+	localize bool
+	dummies  string
+	timeout  time.Duration
+	config   string
+}
+
+func newTestFlags() *testFlags {
+	f := &testFlags{fs: flag.NewFlagSet("test", flag.ContinueOnError)}
+	f.fs.IntVar(&f.clients, "clients", 10, "")
+	f.fs.Uint64Var(&f.lambda, "lambda", 40, "")
+	f.fs.Float64Var(&f.frac, "malicious-frac", 0, "")
+	f.fs.BoolVar(&f.localize, "localize", false, "")
+	f.fs.StringVar(&f.dummies, "dummies", "", "")
+	f.fs.DurationVar(&f.timeout, "timeout", time.Minute, "")
+	f.fs.StringVar(&f.config, configFlag, "", "")
+	return f
+}
+
+func writeConfig(t *testing.T, name string, content string) string {
+	name = filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLoadConfig(t *testing.T) {
+	name := writeConfig(t, "run.json", `{"clients": 20, "lambda": 80, "malicious-frac": 0.25, "localize": true, "timeout": "5s"}`)
+	f := newTestFlags()
+	if err := f.fs.Parse([]string{"-lambda", "60"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(f.fs, name); err != nil {
+		t.Fatal(err)
+	}
+	// the command line overrides the file
+	if f.clients != 20 || f.lambda != 60 || f.frac != 0.25 || !f.localize || f.timeout != 5*time.Second {
+		t.Fatalf("got clients %v, lambda %v, fraction %v, localize %v, timeout %v", f.clients, f.lambda, f.frac, f.localize, f.timeout)
+	}
+
+	name = writeConfig(t, "run.yaml", "clients: 30\ndummies: [4, 8, 16]\n")
+	f = newTestFlags()
+	if err := LoadConfig(f.fs, name); err != nil {
+		t.Fatal(err)
+	}
+	if f.clients != 30 || f.dummies != "4,8,16" {
+		t.Fatalf("got clients %v, dummies %q", f.clients, f.dummies)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name, content, field string
+	}{
+		{"unknown.json", `{"clients": 20, "cliens": 30}`, "cliens"},
+		{"type.json", `{"clients": "many"}`, "clients"},
+		{"negative.yaml", "lambda: -1\n", "lambda"},
+		{"nested.yaml", "dummies: [[1, 2]]\n", "dummies[0]"},
+		{"config.json", `{"config": "other.json"}`, "config"},
+	} {
+		f := newTestFlags()
+		err := LoadConfig(f.fs, writeConfig(t, tc.name, tc.content))
+		if !errors.Is(err, ErrConfig) {
+			t.Fatalf("%v: got %v, expected ErrConfig", tc.name, err)
+		}
+		if !strings.Contains(err.Error(), tc.field) {
+			t.Fatalf("%v: %v does not name the field %v", tc.name, err, tc.field)
+		}
+	}
+}
+
+// TestSaveConfig checks that the resolved configuration reproduces the run.
+func TestSaveConfig(t *testing.T) {
+	f := newTestFlags()
+	if err := f.fs.Parse([]string{"-clients", "12", "-localize", "-dummies", "4,8", "-timeout", "90s", "-config", "ignored.json"}); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "run", "vote-config.json")
+	if err := SaveConfig(f.fs, name); err != nil {
+		t.Fatal(err)
+	}
+
+	g := newTestFlags()
+	if err := LoadConfig(g.fs, name); err != nil {
+		t.Fatal(err)
+	}
+	if g.clients != f.clients || g.lambda != f.lambda || g.localize != f.localize || g.dummies != f.dummies || g.timeout != f.timeout || g.config != "" {
+		t.Fatalf("saved %+v, loaded %+v", *f, *g)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gadget"
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/hasher"
+)
+
+// In liquid democracy a voter may delegate its vote to another voter, who
+// may delegate it further. A delegation is registered publicly as a link, the
+// commitment DelegationLink(delegator, delegatee), and the voter at the end
+// of a chain registers the link to the commitment of its ballot. The voters
+// are field elements, see VoterElement.
+//
+// DelegationCircuit proves that a chain of registered links goes from
+// voter_0 through voter_1, ..., to voter_k and ends on a valid ranked ballot,
+// as checked by VoteCircuit: the server checks that the links are in its
+// registry, and learns no voter of the chain. A circuit is for chains of one
+// number of hops, as it is for one number of dummies.
+
+// ErrDelegationCycle is returned for delegations that loop back to a voter.
+var ErrDelegationCycle = errors.New("delegation: the delegations form a cycle")
+
+type DelegationCircuit struct {
+	// Path is the chain of voters, from the delegator to the voter of the
+	// ballot
+	Path []frontend.Variable
+	// PublicLinks[i] is the link from Path[i] to Path[i+1], the last one the
+	// link from the last voter to the commitment of Ballot
+	PublicLinks []frontend.Variable `gnark:",public"`
+
+	// Ballot is the ranked ballot of the last voter of the chain
+	Ballot VoteCircuit
+
+	// Hash is the hash of the links, see params.go
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+func (circuit *DelegationCircuit) Define(api frontend.API) error {
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	hops := len(circuit.Path) - 1
+	for i := 0; i < hops; i++ {
+		h.Reset()
+		h.Write(circuit.Path[i], circuit.Path[i+1])
+		gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicLinks[i], h.Sum())
+	}
+	h.Reset()
+	h.Write(circuit.Path[hops], circuit.Ballot.PublicCommitment)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicLinks[hops], h.Sum())
+
+	return circuit.Ballot.Define(api)
+}
+
+// NewDelegationCircuit allocates an empty delegation circuit for chains of
+// hops delegations ending on a ballot of candidateNum candidates and
+// dummyNum dummies, hashing with Params.Hash, ready to be compiled. Its
+// public variables are DelegationPublic(hops).
+func NewDelegationCircuit(hops int, candidateNum int, dummyNum int) DelegationCircuit {
+	return DelegationCircuit{
+		Path:        make([]frontend.Variable, hops+1),
+		PublicLinks: make([]frontend.Variable, hops+1),
+		Ballot:      NewVoteCircuit(candidateNum, dummyNum),
+		Hash:        Params.Hash,
+	}
+}
+
+// DelegationPublic returns the public variables of DelegationCircuit for
+// chains of hops delegations, in the order of the public witness.
+func DelegationPublic(hops int) []string {
+	public := gnarkshim.PublicSlice("PublicLinks", hops+1)
+	for _, name := range VotePublic {
+		public = append(public, "Ballot_"+name)
+	}
+	return public
+}
+
+// VoterElement is the field element of the voter named name.
+func VoterElement(name string) fr_bn254.Element {
+	digest := sha256.Sum256([]byte("delegation/voter/" + name))
+	var v fr_bn254.Element
+	v.SetBytes(digest[:])
+	return v
+}
+
+// DelegationLink is the registered link from the voter from to to, a voter
+// or the commitment of a ballot.
+func DelegationLink(field Field, from fr_bn254.Element, to *big.Int) *big.Int {
+	return field.Commit([]*big.Int{from.BigInt(new(big.Int)), to})
+}
+
+// DelegationLinks returns the links of the chain of voters path ending on
+// the ballot committed to by ballotCom.
+func DelegationLinks(field Field, path []fr_bn254.Element, ballotCom *big.Int) []*big.Int {
+	links := make([]*big.Int, len(path))
+	for i := 0; i+1 < len(path); i++ {
+		links[i] = DelegationLink(field, path[i], path[i+1].BigInt(new(big.Int)))
+	}
+	links[len(path)-1] = DelegationLink(field, path[len(path)-1], ballotCom)
+	return links
+}
+
+// GenDelegationAssignment assigns the delegation circuit of the chain of
+// voters path ending on the ballot of the client, whose field computes the
+// links.
+func (c *ClientState) GenDelegationAssignment(path []fr_bn254.Element, publicR fr_bn254.Element) DelegationCircuit {
+	ballot := c.GenAssignment(publicR).Circuit
+	pathVar := make([]frontend.Variable, len(path))
+	for i := range path {
+		pathVar[i] = frontendVariable(&path[i])
+	}
+	links := DelegationLinks(c.field, path, c.PublicCom)
+	linkVar := make([]frontend.Variable, len(links))
+	for i := range links {
+		linkVar[i] = frontend.Variable(links[i])
+	}
+	return DelegationCircuit{
+		Path:        pathVar,
+		PublicLinks: linkVar,
+		Ballot:      ballot,
+	}
+}
+
+// DelegationTree is the forest of the delegations: every voter points to its
+// delegatee, up to the voters who vote themselves.
+type DelegationTree struct {
+	delegatee map[string]string
+}
+
+// BuildDelegationTree builds the tree of the delegations, a map from every
+// delegator to its delegatee. A voter cannot delegate to itself, and the
+// delegations cannot form a cycle, or no voter would cast the ballot.
+func BuildDelegationTree(delegations map[string]string) (*DelegationTree, error) {
+	t := &DelegationTree{delegatee: make(map[string]string, len(delegations))}
+	for from, to := range delegations {
+		if from == to {
+			return nil, fmt.Errorf("%w: %q delegates to itself", ErrDelegationCycle, from)
+		}
+		t.delegatee[from] = to
+	}
+	// in the order of the voters, for the error to name the same voter
+	// every time
+	voters := make([]string, 0, len(delegations))
+	for from := range delegations {
+		voters = append(voters, from)
+	}
+	sort.Strings(voters)
+	for _, v := range voters {
+		if _, err := GetDelegationPath(t, v); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// GetDelegationPath returns the chain of voters from voter to the voter who
+// casts its ballot, voter alone if it does not delegate.
+func GetDelegationPath(t *DelegationTree, voter string) ([]string, error) {
+	path := []string{voter}
+	seen := map[string]bool{voter: true}
+	for {
+		next, ok := t.delegatee[path[len(path)-1]]
+		if !ok {
+			return path, nil
+		}
+		if seen[next] {
+			return nil, fmt.Errorf("%w: %q delegates back to %q", ErrDelegationCycle, path[len(path)-1], next)
+		}
+		seen[next] = true
+		path = append(path, next)
+	}
+}
+
+// DelegationPath returns the field elements of the voters of the chain of
+// voter, see GetDelegationPath.
+func DelegationPath(t *DelegationTree, voter string) ([]fr_bn254.Element, error) {
+	names, err := GetDelegationPath(t, voter)
+	if err != nil {
+		return nil, err
+	}
+	path := make([]fr_bn254.Element, len(names))
+	for i, name := range names {
+		path[i] = VoterElement(name)
+	}
+	return path, nil
+}
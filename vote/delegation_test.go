@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+)
+
+func TestDelegationCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	tree, err := BuildDelegationTree(map[string]string{"alice": "bob", "bob": "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := DelegationPath(tree, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	circuit := NewDelegationCircuit(len(path)-1, CandidateNum, 4)
+
+	var carol ClientState
+	carol.InitRand(benchrand.New(1), bn254Field{}, 4)
+	assignment := carol.GenDelegationAssignment(path, randomFr())
+	assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// a link missing from the chain: alice did not delegate to carol
+	skipped := assignment
+	skipped.Path = append([]frontend.Variable(nil), assignment.Path...)
+	skipped.Path[1] = skipped.Path[2]
+	assert.ProverFailed(&circuit, &skipped, test.WithCurves(ecc.BN254))
+
+	// the chain ends on another ballot than the one of carol
+	other := assignment
+	other.PublicLinks = append([]frontend.Variable(nil), assignment.PublicLinks...)
+	otherCom := randomFr()
+	other.PublicLinks[2] = DelegationLink(bn254Field{}, path[2], otherCom.BigInt(new(big.Int)))
+	assert.ProverFailed(&circuit, &other, test.WithCurves(ecc.BN254))
+
+	// the chain is sound but ends on a ranking naming a candidate twice
+	var twice ClientState
+	twice.InitRand(benchrand.New(2), bn254Field{}, 4)
+	twice.SortedCandidate[1] = twice.SortedCandidate[0]
+	invalid := twice.GenDelegationAssignment(path, randomFr())
+	err = test.IsSolved(&circuit, &invalid, ecc.BN254.ScalarField())
+	if err == nil || gadget.ParseFailure(err).Invariant != gadget.Permutation {
+		t.Fatalf("an invalid ballot at the end of the chain: got %v, expected the permutation to fail", err)
+	}
+	assert.ProverFailed(&circuit, &invalid, test.WithCurves(ecc.BN254))
+}
+
+func TestGetDelegationPath(t *testing.T) {
+	tree, err := BuildDelegationTree(map[string]string{"a": "b", "b": "c", "d": "c", "e": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for voter, expected := range map[string][]string{
+		"e": {"e", "a", "b", "c"},
+		"d": {"d", "c"},
+		"c": {"c"},
+		"x": {"x"},
+	} {
+		if path, err := GetDelegationPath(tree, voter); err != nil || !reflect.DeepEqual(path, expected) {
+			t.Fatalf("the path of %v: %v, %v, expected %v", voter, path, err, expected)
+		}
+	}
+
+	for _, delegations := range []map[string]string{
+		{"a": "a"},
+		{"a": "b", "b": "a"},
+		{"a": "b", "b": "c", "c": "d", "d": "b"},
+	} {
+		if _, err := BuildDelegationTree(delegations); !errors.Is(err, ErrDelegationCycle) {
+			t.Fatalf("%v: got %v, expected ErrDelegationCycle", delegations, err)
+		}
+	}
+}
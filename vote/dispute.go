@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// When the product of the shuffled data does not match the product of the
+// PublicProd of the clients, the server asks the shuffler for the product of
+// the data of subsets of the clients: the shuffler received the pairs and the
+// dummies of every client apart, before shuffling them. LocalizeMismatch
+// splits the clients in halves until it isolates those whose PublicProd does
+// not match their data, with O(k log n) queries for k such clients out of n.
+//
+// A subset whose mismatches cancel out passes for consistent; with random
+// corruptions this happens with negligible probability.
+//
+// A mismatch isolated this way is the client's or the shuffler's. The server
+// settles it with AuditMismatch: the client opens its commitment, which must
+// hold the ballot and the dummies it proved, and they are compared with the
+// pairs and the dummies the shuffler received from it.
+
+// ShufflerOracle returns the product, at publicR, of the pairs and the
+// dummies the shuffler received from the clients in subset.
+type ShufflerOracle func(subset []int) *big.Int
+
+// LocalizeMismatch returns, in increasing order, the clients whose product in
+// clientProds does not match the product of their data, as returned by
+// shufflerEval.
+func LocalizeMismatch(field Field, clientProds []*big.Int, shufflerEval ShufflerOracle) []int {
+	all := make([]int, len(clientProds))
+	for i := range all {
+		all[i] = i
+	}
+	return localizeMismatch(field, clientProds, shufflerEval, all, nil)
+}
+
+func localizeMismatch(field Field, clientProds []*big.Int, shufflerEval ShufflerOracle, subset []int, mismatched []int) []int {
+	if len(subset) == 0 {
+		return mismatched
+	}
+	prod := big.NewInt(1)
+	for _, i := range subset {
+		prod = field.Mul(prod, clientProds[i])
+	}
+	if prod.Cmp(shufflerEval(subset)) == 0 {
+		return mismatched
+	}
+	if len(subset) == 1 {
+		return append(mismatched, subset[0])
+	}
+	half := len(subset) / 2
+	mismatched = localizeMismatch(field, clientProds, shufflerEval, subset[:half], mismatched)
+	return localizeMismatch(field, clientProds, shufflerEval, subset[half:], mismatched)
+}
+
+// ClientsOracle is the ShufflerOracle of a shuffler that received the data of
+// clients.
+func ClientsOracle(field Field, clients []ClientState, publicR fr_bn254.Element) ShufflerOracle {
+	return func(subset []int) *big.Int {
+		prod := big.NewInt(1)
+		for _, i := range subset {
+			c := &clients[i]
+			prod = field.Mul(prod, shufflerProduct(field, CandidateNum, c.PairFirst, c.PairSecond, c.PrivateY, publicR))
+		}
+		return prod
+	}
+}
+
+// SubmissionProducts returns the publicProd of every submission.
+func SubmissionProducts(allSubmission []ClientSubmission) []*big.Int {
+	prods := make([]*big.Int, len(allSubmission))
+	for i := range allSubmission {
+		prods[i] = allSubmission[i].publicProd
+	}
+	return prods
+}
+
+// ErrShipped blames a client whose opening holds, but whose pairs or dummies,
+// as the shuffler received them, are not the ones it committed.
+var ErrShipped = errors.New("dispute: the data shipped to the shuffler is not the data committed")
+
+// AuditVerdict is the outcome of the audit of a client. Err says why the
+// client is blamed; it is nil for a client the audit clears, which leaves
+// the mismatch to the shuffler.
+type AuditVerdict struct {
+	Client int
+	Err    error
+}
+
+// AuditMismatch has every suspect open its commitment to the server, see
+// VerifyOpening, and compares the opening with the data of the suspect in
+// shipped, as the shuffler received it. clients hold the openings and
+// allSubmission the products of the clients. The verdicts follow suspects.
+func AuditMismatch(field Field, clients []ClientState, shipped []ClientState, allSubmission []ClientSubmission, suspects []int, publicR fr_bn254.Element) []AuditVerdict {
+	verdicts := make([]AuditVerdict, len(suspects))
+	for k, i := range suspects {
+		verdicts[k] = AuditVerdict{Client: i, Err: auditClient(field, &clients[i], &shipped[i], allSubmission[i].publicProd, publicR)}
+	}
+	return verdicts
+}
+
+func auditClient(field Field, c *ClientState, shipped *ClientState, prod *big.Int, publicR fr_bn254.Element) error {
+	opened := c.OpenedBallot()
+	if err := VerifyOpening(field, opened, c.PublicCom, publicR, prod); err != nil {
+		return err
+	}
+	if len(shipped.PairFirst) != len(opened.PrivateX) || len(shipped.PairSecond) != len(opened.PrivateX) {
+		return fmt.Errorf("%w: %v pairs shipped, %v committed", ErrShipped, len(shipped.PairFirst), len(opened.PrivateX))
+	}
+	packed := packPairs(CandidateNum, shipped.PairFirst, shipped.PairSecond)
+	for j := range packed {
+		if !constantTimeEqual(field, packed[j].BigInt(new(big.Int)), opened.PrivateX[j].BigInt(new(big.Int))) {
+			return fmt.Errorf("%w: pair %v", ErrShipped, j)
+		}
+	}
+	if len(shipped.PrivateY) != len(opened.PrivateY) {
+		return fmt.Errorf("%w: %v dummies shipped, %v committed", ErrShipped, len(shipped.PrivateY), len(opened.PrivateY))
+	}
+	for j := range opened.PrivateY {
+		if !constantTimeEqual(field, shipped.PrivateY[j].BigInt(new(big.Int)), opened.PrivateY[j].BigInt(new(big.Int))) {
+			return fmt.Errorf("%w: dummy %v", ErrShipped, j)
+		}
+	}
+	return nil
+}
+
+// Blamed splits verdicts into the clients the audit blames and the ones it
+// clears.
+func Blamed(verdicts []AuditVerdict) (blamed []int, cleared []int) {
+	for _, v := range verdicts {
+		if v.Err != nil {
+			blamed = append(blamed, v.Client)
+		} else {
+			cleared = append(cleared, v.Client)
+		}
+	}
+	return blamed, cleared
+}
@@ -0,0 +1,108 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/benchrand"
+)
+
+func TestLocalizeMismatch(t *testing.T) {
+	field := bn254Field{}
+	publicR := randomFr()
+	clients := make([]ClientState, 100)
+	rnd := benchrand.New(4)
+	for i := range clients {
+		clients[i].InitRand(rnd, field, 2)
+	}
+	oracle := ClientsOracle(field, clients, publicR)
+
+	for _, corrupted := range [][]int{nil, {42}, {3, 57, 99}} {
+		prods := make([]*big.Int, len(clients))
+		for i := range clients {
+			prods[i] = clients[i].ComputePolyEval(publicR)
+		}
+		for _, i := range corrupted {
+			prods[i] = field.Mul(prods[i], big.NewInt(2))
+		}
+		queries := 0
+		counting := func(subset []int) *big.Int {
+			queries++
+			return oracle(subset)
+		}
+		if got := LocalizeMismatch(field, prods, counting); !reflect.DeepEqual(got, corrupted) {
+			t.Fatalf("corrupted %v: got %v", corrupted, got)
+		}
+		// every corrupted client costs at most two queries on each of the 7
+		// levels of the split of 100 clients
+		if max := 1 + 2*len(corrupted)*7; queries > max {
+			t.Fatalf("corrupted %v: %v queries, expected at most %v", corrupted, queries, max)
+		}
+	}
+}
+
+func TestLocalizeErr(t *testing.T) {
+	defer func(localize bool) { Localize = localize }(Localize)
+	field := bn254Field{}
+	publicR := randomFr()
+	clients := make([]ClientState, 5)
+	submissions := make([]ClientSubmission, len(clients))
+	rnd := benchrand.New(5)
+	for i := range clients {
+		clients[i].InitRand(rnd, field, 2)
+		submissions[i].publicProd = clients[i].ComputePolyEval(publicR)
+	}
+	submissions[1].publicProd = big.NewInt(1)
+
+	Localize = false
+	if err := localizeErr(ErrInconsistent, field, clients, clients, submissions, publicR); err != ErrInconsistent {
+		t.Fatalf("without -localize: got %v", err)
+	}
+	Localize = true
+	err := localizeErr(ErrInconsistent, field, clients, clients, submissions, publicR)
+	if !errors.Is(err, ErrInconsistent) || !strings.Contains(err.Error(), "clients [1]") || !strings.Contains(err.Error(), "blame [1]") {
+		t.Fatalf("with -localize: got %v", err)
+	}
+}
+
+func TestAuditMismatch(t *testing.T) {
+	defer func(c int) { CandidateNum = c }(CandidateNum)
+	CandidateNum = 3
+	field := bn254Field{}
+	publicR := randomFr()
+	clients := make([]ClientState, 5)
+	submissions := make([]ClientSubmission, len(clients))
+	rnd := benchrand.New(6)
+	for i := range clients {
+		clients[i].InitRand(rnd, field, 2)
+		submissions[i].publicProd = clients[i].ComputePolyEval(publicR)
+	}
+	// client 1 ships other pairs than its ballot, client 2 other dummies
+	// than its mask and client 3 claims a product its opening does not make
+	shipped := ShipInconsistentPairs(clients, []int{1})
+	shipped[2].PrivateY = []fr_bn254.Element{randomFr(), randomFr()}
+	submissions[3].publicProd = big.NewInt(1)
+
+	// the product check isolates the three of them
+	if got := LocalizeMismatch(field, SubmissionProducts(submissions), ClientsOracle(field, shipped, publicR)); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("the product check isolates %v", got)
+	}
+
+	// client 4 is honest, and cleared if a shuffler points at it
+	verdicts := AuditMismatch(field, clients, shipped, submissions, []int{1, 2, 3, 4}, publicR)
+	for k, expected := range []error{ErrShipped, ErrShipped, ErrBadProduct, nil} {
+		if v := verdicts[k]; v.Client != k+1 || !errors.Is(v.Err, expected) || (expected == nil) != (v.Err == nil) {
+			t.Fatalf("client %v: got %v, expected %v", k+1, v.Err, expected)
+		}
+	}
+	if blamed, cleared := Blamed(verdicts); !reflect.DeepEqual(blamed, []int{1, 2, 3}) || !reflect.DeepEqual(cleared, []int{4}) {
+		t.Fatalf("blamed %v, cleared %v", blamed, cleared)
+	}
+}
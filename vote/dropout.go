@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The number of dummies per client is fixed by ComputeDummyNum for the
+// planned number of clients, before anybody joins. The clients that register
+// but do not submit shrink the honest crowd the dummies hide in: with the
+// corrupted clients assumed to stay, the survivors need more dummies for the
+// same lambda, and with e or fewer honest survivors no number of dummies is
+// enough. Their product is not in the aggregate of the server, so their
+// shares must not be in the release of the shuffler either: a shuffler that
+// holds the shares per client until the release, like the one of the network
+// demo, leaves them out, but once their shares are mixed with the others the
+// consistency check cannot pass and the round cannot be recovered.
+
+var ErrDropoutMixed = errors.New("dropout: the shares of the dropped clients are already mixed")
+
+// DropoutPlan is the decision of the server on the clients that registered
+// but did not submit.
+type DropoutPlan struct {
+	// Survivors are the clients that submitted and Dropped the others, in
+	// increasing order. Only the products and the shares of the survivors
+	// enter the consistency check.
+	Survivors []uint32
+	Dropped   []uint32
+	// Required is the number of dummies per client the survivors need for
+	// the security target, 0 when they leave e or fewer honest clients and
+	// no number is enough.
+	Required uint64
+	// BelowTarget is set when the dummies of the round are fewer than
+	// Required, or when no number is enough.
+	BelowTarget bool
+	// Unrecoverable is set when the shares of dropped clients were already
+	// mixed: the release cannot be restricted to the survivors.
+	Unrecoverable bool
+}
+
+// PlanDropout decides the fate of a round with dummyNum dummies per client,
+// lambda bits of security and corrupted clients, where the clients registered
+// and only submitted sent their submission. mixed tells whether the shuffler
+// already mixed the shares of the registered clients.
+func PlanDropout(lambda uint64, dummyNum uint64, corrupted uint64, registered []uint32, submitted []uint32, mixed bool) (DropoutPlan, error) {
+	isSubmitted := make(map[uint32]bool, len(submitted))
+	for _, id := range submitted {
+		if isSubmitted[id] {
+			return DropoutPlan{}, fmt.Errorf("dropout: client %v submitted twice", id)
+		}
+		isSubmitted[id] = true
+	}
+	var plan DropoutPlan
+	for _, id := range registered {
+		if isSubmitted[id] {
+			plan.Survivors = append(plan.Survivors, id)
+			delete(isSubmitted, id)
+		} else {
+			plan.Dropped = append(plan.Dropped, id)
+		}
+	}
+	for id := range isSubmitted {
+		return DropoutPlan{}, fmt.Errorf("dropout: client %v submitted without registering", id)
+	}
+	sort.Slice(plan.Survivors, func(i, j int) bool { return plan.Survivors[i] < plan.Survivors[j] })
+	sort.Slice(plan.Dropped, func(i, j int) bool { return plan.Dropped[i] < plan.Dropped[j] })
+
+	// the error of ComputeDummyNum is the lack of honest survivors
+	if required, err := ComputeDummyNum(lambda, uint64(len(plan.Survivors)), corrupted); err == nil {
+		plan.Required = required
+		plan.BelowTarget = dummyNum < required
+	} else {
+		plan.BelowTarget = true
+	}
+	plan.Unrecoverable = mixed && len(plan.Dropped) > 0
+	return plan, nil
+}
@@ -0,0 +1,94 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// clientIDs returns the ids 0 to n-1.
+func clientIDs(n int) []uint32 {
+	ids := make([]uint32, n)
+	for i := range ids {
+		ids[i] = uint32(i)
+	}
+	return ids
+}
+
+func TestPlanDropout(t *testing.T) {
+	const lambda, n, corrupted = 80, 100, 10
+	dummyNum := mustDummyNum(t, lambda, n, corrupted)
+	for _, tc := range []struct {
+		dropped     int
+		belowTarget bool
+	}{
+		{0, false},
+		// 90 clients, 80 of them honest
+		{10, true},
+		// 40 clients, 30 of them honest
+		{60, true},
+	} {
+		// tc.dropped percent of the clients drop, spread over the ids
+		var submitted []uint32
+		for i := uint32(0); i < n; i++ {
+			if i%10 >= uint32(tc.dropped/10) {
+				submitted = append(submitted, i)
+			}
+		}
+		plan, err := PlanDropout(lambda, dummyNum, corrupted, clientIDs(n), submitted, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(plan.Survivors, submitted) || len(plan.Dropped) != tc.dropped || plan.Unrecoverable {
+			t.Fatalf("%v%% dropout: survivors %v, dropped %v, unrecoverable %v", tc.dropped, plan.Survivors, plan.Dropped, plan.Unrecoverable)
+		}
+		if plan.Required != mustDummyNum(t, lambda, uint64(n-tc.dropped), corrupted) || plan.BelowTarget != tc.belowTarget {
+			t.Fatalf("%v%% dropout: %v dummies required, below target %v, the round has %v", tc.dropped, plan.Required, plan.BelowTarget, dummyNum)
+		}
+
+		// the shares of the dropped clients cannot leave a mixed release
+		mixed, err := PlanDropout(lambda, dummyNum, corrupted, clientIDs(n), submitted, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mixed.Unrecoverable != (tc.dropped > 0) {
+			t.Fatalf("%v%% dropout, shares mixed: unrecoverable %v", tc.dropped, mixed.Unrecoverable)
+		}
+	}
+}
+
+func TestPlanDropoutNoHonestClient(t *testing.T) {
+	// 60% of 10 clients drop: 4 survivors and 3 corrupted leave one honest
+	// client, below e, and no number of dummies is enough
+	const lambda, n, corrupted = 80, 10, 3
+	dummyNum := mustDummyNum(t, lambda, n, corrupted)
+	plan, err := PlanDropout(lambda, dummyNum, corrupted, clientIDs(n), []uint32{9, 2, 5, 7}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan.Survivors, []uint32{2, 5, 7, 9}) || !reflect.DeepEqual(plan.Dropped, []uint32{0, 1, 3, 4, 6, 8}) {
+		t.Fatalf("survivors %v, dropped %v", plan.Survivors, plan.Dropped)
+	}
+	if plan.Required != 0 || !plan.BelowTarget {
+		t.Fatalf("%v dummies required, below target %v, expected none and below target", plan.Required, plan.BelowTarget)
+	}
+
+	// more corrupted clients than survivors
+	plan, err = PlanDropout(lambda, dummyNum, corrupted, clientIDs(n), []uint32{0, 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Required != 0 || !plan.BelowTarget {
+		t.Fatalf("2 survivors: %v dummies required, below target %v", plan.Required, plan.BelowTarget)
+	}
+}
+
+func TestPlanDropoutInvalid(t *testing.T) {
+	if _, err := PlanDropout(80, 100, 0, clientIDs(4), []uint32{1, 1}, false); err == nil {
+		t.Fatal("no error for a client submitting twice")
+	}
+	if _, err := PlanDropout(80, 100, 0, clientIDs(4), []uint32{1, 4}, false); err == nil {
+		t.Fatal("no error for a client that did not register")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/merkle"
+)
+
+// The eligible voters are the leaves of a MiMC Merkle tree, each leaf the hash
+// of the public key of a voter. A voter proves its eligibility with the path
+// from its leaf to the published root, without revealing the leaf.
+
+// BuildEligibilityTree builds the Merkle tree of voters, see internal/merkle.
+func BuildEligibilityTree(voters []fr_bn254.Element) (root fr_bn254.Element, tree *merkle.Tree) {
+	tree = merkle.BuildTree(voters)
+	return tree.Root(), tree
+}
+
+// GetMerklePath returns the siblings and the directions of the path from the
+// voter index of tree to its root, see merkle.Tree.Prove.
+func GetMerklePath(tree *merkle.Tree, index int) (siblings []fr_bn254.Element, dirs []int, err error) {
+	path, err := tree.Prove(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	return path.Siblings, path.Dirs, nil
+}
+
+// MerkleEligibilityCircuit proves that PrivateLeaf is a leaf of the tree of
+// root PublicRoot. The depth of the tree is the length of PrivateSiblings.
+type MerkleEligibilityCircuit struct {
+	PrivateLeaf     frontend.Variable
+	PrivateSiblings []frontend.Variable
+	PrivateDirs     []frontend.Variable
+	PublicRoot      frontend.Variable `gnark:",public"`
+}
+
+func (circuit *MerkleEligibilityCircuit) Define(api frontend.API) error {
+	path := merkle.CircuitPath{Siblings: circuit.PrivateSiblings, Dirs: circuit.PrivateDirs}
+	return merkle.VerifyMerklePathInCircuit(api, circuit.PrivateLeaf, path, circuit.PublicRoot)
+}
+
+// NewEligibilityAssignment assigns the path from the voter index of tree.
+func NewEligibilityAssignment(tree *merkle.Tree, index int) (MerkleEligibilityCircuit, error) {
+	path, err := tree.Prove(index)
+	if err != nil {
+		return MerkleEligibilityCircuit{}, err
+	}
+	assigned := path.Assign()
+	return MerkleEligibilityCircuit{
+		PrivateLeaf:     tree.Leaves[index],
+		PrivateSiblings: assigned.Siblings,
+		PrivateDirs:     assigned.Dirs,
+		PublicRoot:      tree.Root(),
+	}, nil
+}
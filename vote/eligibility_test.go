@@ -0,0 +1,85 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/merkle"
+)
+
+func TestMerkleEligibility(t *testing.T) {
+	voters := make([]fr_bn254.Element, 5)
+	for i := 0; i < len(voters); i++ {
+		voters[i] = randomFr()
+	}
+	root, tree := BuildEligibilityTree(voters)
+	if len(tree.Levels) != 4 || len(tree.Levels[0]) != 8 {
+		t.Fatalf("tree of %v levels and %v leaves, expected 4 and 8", len(tree.Levels), len(tree.Levels[0]))
+	}
+
+	circuit := MerkleEligibilityCircuit{
+		PrivateSiblings: make([]frontend.Variable, 3),
+		PrivateDirs:     make([]frontend.Variable, 3),
+	}
+	for i := 0; i < len(voters); i++ {
+		assignment, err := NewEligibilityAssignment(tree, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("voter %v: %v", i, err)
+		}
+	}
+
+	// a leaf which is not in the tree
+	assignment, _ := NewEligibilityAssignment(tree, 2)
+	assignment.PrivateLeaf = randomFr()
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("an ineligible voter was accepted")
+	}
+	// a wrong direction
+	assignment, _ = NewEligibilityAssignment(tree, 2)
+	assignment.PrivateDirs[0] = 1
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("a path with a wrong direction was accepted")
+	}
+	// a wrong sibling
+	assignment, _ = NewEligibilityAssignment(tree, 2)
+	assignment.PrivateSiblings[1] = root
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("a path with a wrong sibling was accepted")
+	}
+	// a padding leaf is not a voter
+	if _, err := NewEligibilityAssignment(tree, len(voters)); err == nil {
+		t.Fatal("no error for the path of a padding leaf")
+	}
+}
+
+func TestGetMerklePath(t *testing.T) {
+	voters := make([]fr_bn254.Element, 5)
+	for i := 0; i < len(voters); i++ {
+		voters[i] = randomFr()
+	}
+	root, tree := BuildEligibilityTree(voters)
+	for i := 0; i < len(voters); i++ {
+		siblings, dirs, err := GetMerklePath(tree, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(siblings) != 3 || len(dirs) != 3 {
+			t.Fatalf("voter %v: %v siblings and %v directions, expected 3", i, len(siblings), len(dirs))
+		}
+		if err := merkle.Verify(voters[i], merkle.Path{Siblings: siblings, Dirs: dirs}, root); err != nil {
+			t.Fatalf("voter %v: %v", i, err)
+		}
+	}
+	if _, _, err := GetMerklePath(tree, len(voters)); err == nil {
+		t.Fatal("no error for the path of a padding leaf")
+	}
+}
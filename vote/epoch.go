@@ -0,0 +1,402 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+
+	"example/verification/internal/merkle"
+)
+
+// At the end of an election the server can publish everything an observer
+// needs to check it, without any client secret, in an epoch directory:
+//
+//	epoch.json     the commitments, the challenge transcript, the shuffled
+//	               multiset and the tally
+//	verifying.key  the verifying key of the circuit
+//	submissions/   the submissions of the counted clients, see audit.go
+//	manifest.json  the SHA-256 of every other file
+//
+// The i-th submission is the one of the i-th commitment.
+//
+// The publication is atomic: the files are written to a staging directory
+// next to dir, the manifest last, and a rename publishes the whole directory
+// at once. A server crashing halfway leaves the staging directory behind and
+// nothing at dir; publishing again starts the staging directory over.
+// LoadEpoch refuses a directory whose manifest is missing or does not match
+// its files, so an observer never checks a partial epoch.
+
+const (
+	epochFile          = "epoch.json"
+	epochKeyFile       = "verifying.key"
+	epochSubmissions   = "submissions"
+	epochManifestFile  = "manifest.json"
+	epochStagingSuffix = ".staging"
+)
+
+// ErrUnpublished is returned by LoadEpoch for a directory which is not a
+// complete published epoch.
+var ErrUnpublished = errors.New("epoch: the directory is not a complete published epoch")
+
+// epochWriteHook, if not nil, is called before every file of the epoch is
+// written, with its name; an error aborts the publication there, as a crash
+// of the server would. It is for the tests.
+var epochWriteHook func(name string) error
+
+// Epoch is the published record of one election.
+type Epoch struct {
+	Backend      backend.ID
+	Curve        ecc.ID
+	CandidateNum int
+	VerifyingKey VerifyingKeyBytes
+
+	// the challenge transcript: the seed of the server and, for every client
+	// of the reveal round, its nonce commitment and its nonce if revealed
+	Seed             [32]byte
+	SeedCommitment   [32]byte
+	NonceCommitments []fr_bn254.Element
+	Nonces           []fr_bn254.Element
+	Revealed         []bool
+	PublicR          fr_bn254.Element
+
+	// the commitments of the counted clients and their Merkle root
+	Commitments    []*big.Int
+	CommitmentRoot fr_bn254.Element
+
+	// the multiset released by the shuffler
+	PairFirst  []fr_bn254.Element
+	PairSecond []fr_bn254.Element
+	Dummies    []fr_bn254.Element
+
+	Tally  TallyPairs
+	Winner int
+
+	Submissions []ClientSubmission
+}
+
+// commitmentLeaf is the leaf of a commitment in the commitment tree. A
+// commitment may not be an element of the BN254 field, so it is hashed as its
+// two 16-byte halves.
+func commitmentLeaf(com *big.Int) fr_bn254.Element {
+	var b [32]byte
+	com.FillBytes(b[:])
+	var hi, lo fr_bn254.Element
+	hi.SetBytes(b[:16])
+	lo.SetBytes(b[16:])
+	return merkle.HashPair(&hi, &lo)
+}
+
+// CommitmentTree is the MiMC Merkle tree of the commitments, in order. The
+// server publishes its root and gives client i the path Prove(i) of its
+// commitment.
+func CommitmentTree(commitments []*big.Int) *merkle.Tree {
+	leaves := make([]fr_bn254.Element, len(commitments))
+	for i := 0; i < len(commitments); i++ {
+		leaves[i] = commitmentLeaf(commitments[i])
+	}
+	return merkle.BuildTree(leaves)
+}
+
+// CommitmentRoot is the root of the CommitmentTree of the commitments.
+func CommitmentRoot(commitments []*big.Int) fr_bn254.Element {
+	return CommitmentTree(commitments).Root()
+}
+
+// VerifyCommitmentInclusion reports whether path proves that the commitment
+// com is in the CommitmentTree of root. A malformed path proves nothing.
+func VerifyCommitmentInclusion(com *big.Int, path merkle.Path, root fr_bn254.Element) bool {
+	return merkle.Verify(commitmentLeaf(com), path, root) == nil
+}
+
+type epochTranscriptJSON struct {
+	NonceCommitment string `json:"nonceCommitment"`
+	// Nonce is empty for a client that did not reveal
+	Nonce string `json:"nonce,omitempty"`
+}
+
+type epochJSON struct {
+	Backend        string                `json:"backend"`
+	Curve          string                `json:"curve"`
+	CandidateNum   int                   `json:"candidateNum"`
+	Seed           string                `json:"seed"`
+	SeedCommitment string                `json:"seedCommitment"`
+	Transcript     []epochTranscriptJSON `json:"transcript"`
+	PublicR        string                `json:"publicR"`
+	Commitments    []string              `json:"commitments"`
+	CommitmentRoot string                `json:"commitmentRoot"`
+	PairFirst      []string              `json:"pairFirst"`
+	PairSecond     []string              `json:"pairSecond"`
+	Dummies        []string              `json:"dummies"`
+	Tally          [][]uint64            `json:"tally"`
+	Winner         int                   `json:"winner"`
+}
+
+// ExportEpoch publishes e at dir, which must not exist yet, atomically.
+func ExportEpoch(dir string, e *Epoch) error {
+	doc := epochJSON{
+		Backend:        e.Backend.String(),
+		Curve:          e.Curve.String(),
+		CandidateNum:   e.CandidateNum,
+		Seed:           hex.EncodeToString(e.Seed[:]),
+		SeedCommitment: hex.EncodeToString(e.SeedCommitment[:]),
+		Transcript:     make([]epochTranscriptJSON, len(e.NonceCommitments)),
+		PublicR:        hexElement(&e.PublicR),
+		Commitments:    make([]string, len(e.Commitments)),
+		CommitmentRoot: hexElement(&e.CommitmentRoot),
+		PairFirst:      hexElements(e.PairFirst),
+		PairSecond:     hexElements(e.PairSecond),
+		Dummies:        hexElements(e.Dummies),
+		Tally:          e.Tally,
+		Winner:         e.Winner,
+	}
+	for i := 0; i < len(e.NonceCommitments); i++ {
+		doc.Transcript[i].NonceCommitment = hexElement(&e.NonceCommitments[i])
+		if e.Revealed[i] {
+			doc.Transcript[i].Nonce = hexElement(&e.Nonces[i])
+		}
+	}
+	for i := 0; i < len(e.Commitments); i++ {
+		doc.Commitments[i] = hexBigInt(e.Commitments[i])
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("epoch: %v: %w", dir, os.ErrExist)
+	}
+	// a staging directory left by a crash is started over
+	staging := filepath.Clean(dir) + epochStagingSuffix
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(staging, 0700); err != nil {
+		return err
+	}
+	artifacts := []struct {
+		name  string
+		write func(name string) error
+	}{
+		{epochSubmissions, func(name string) error { return SaveSubmissions(name, e.Submissions) }},
+		{epochKeyFile, func(name string) error { return os.WriteFile(name, e.VerifyingKey, 0600) }},
+		{epochFile, func(name string) error { return os.WriteFile(name, b, 0600) }},
+		{epochManifestFile, func(name string) error { return writeEpochManifest(staging) }},
+	}
+	for _, a := range artifacts {
+		if epochWriteHook != nil {
+			if err := epochWriteHook(a.name); err != nil {
+				return err
+			}
+		}
+		if err := a.write(filepath.Join(staging, a.name)); err != nil {
+			return fmt.Errorf("write %v: %w", a.name, err)
+		}
+	}
+	return os.Rename(staging, dir)
+}
+
+type epochManifestEntryJSON struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// epochFileHashes returns the SHA-256 of every file of the epoch in dir but
+// the manifest, in the order of their slash separated names.
+func epochFileHashes(dir string) ([]epochManifestEntryJSON, error) {
+	var entries []epochManifestEntryJSON
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == epochManifestFile {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		entries = append(entries, epochManifestEntryJSON{Name: filepath.ToSlash(rel), SHA256: hex.EncodeToString(sum[:])})
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, err
+}
+
+// writeEpochManifest writes the manifest of the files of the epoch in dir.
+func writeEpochManifest(dir string) error {
+	entries, err := epochFileHashes(dir)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, epochManifestFile), b, 0600)
+}
+
+// checkPublished returns ErrUnpublished unless dir is a published epoch: not
+// a staging directory, and with a manifest matching its files.
+func checkPublished(dir string) error {
+	if strings.HasSuffix(filepath.Clean(dir), epochStagingSuffix) {
+		return fmt.Errorf("%w: %v is a staging directory", ErrUnpublished, dir)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, epochManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %v has no %v", ErrUnpublished, dir, epochManifestFile)
+	}
+	if err != nil {
+		return err
+	}
+	var manifest []epochManifestEntryJSON
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return fmt.Errorf("%w: %v: %v", ErrUnpublished, epochManifestFile, err)
+	}
+	files, err := epochFileHashes(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) != len(manifest) {
+		return fmt.Errorf("%w: %v files for the %v of the manifest", ErrUnpublished, len(files), len(manifest))
+	}
+	for i := range files {
+		if files[i] != manifest[i] {
+			return fmt.Errorf("%w: %v does not match the manifest", ErrUnpublished, files[i].Name)
+		}
+	}
+	return nil
+}
+
+func parseHexSeed(s string) ([32]byte, error) {
+	var seed [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return seed, err
+	}
+	if len(b) != len(seed) {
+		return seed, fmt.Errorf("seed of %v bytes", len(b))
+	}
+	copy(seed[:], b)
+	return seed, nil
+}
+
+// LoadEpoch reads an epoch published by ExportEpoch. It only checks that the
+// publication is complete and that the files are well formed; ObserveEpoch
+// checks what they say. The error wraps ErrUnpublished for a partial epoch.
+func LoadEpoch(dir string) (*Epoch, error) {
+	if err := checkPublished(dir); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(dir, epochFile))
+	if err != nil {
+		return nil, err
+	}
+	var doc epochJSON
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("%v: %w", epochFile, err)
+	}
+	fail := func(name string, err error) (*Epoch, error) {
+		return nil, fmt.Errorf("%v: %v: %w", epochFile, name, err)
+	}
+
+	e := &Epoch{CandidateNum: doc.CandidateNum, Tally: doc.Tally, Winner: doc.Winner}
+	if e.Backend, err = ParseBackend(doc.Backend); err != nil {
+		return fail("backend", err)
+	}
+	if e.Curve, err = ParseCurve(doc.Curve); err != nil {
+		return fail("curve", err)
+	}
+	if e.CandidateNum < 2 {
+		return fail("candidateNum", fmt.Errorf("%v candidates", e.CandidateNum))
+	}
+	if e.Seed, err = parseHexSeed(doc.Seed); err != nil {
+		return fail("seed", err)
+	}
+	if e.SeedCommitment, err = parseHexSeed(doc.SeedCommitment); err != nil {
+		return fail("seedCommitment", err)
+	}
+	e.NonceCommitments = make([]fr_bn254.Element, len(doc.Transcript))
+	e.Nonces = make([]fr_bn254.Element, len(doc.Transcript))
+	e.Revealed = make([]bool, len(doc.Transcript))
+	for i, entry := range doc.Transcript {
+		if e.NonceCommitments[i], err = parseHexElement(entry.NonceCommitment); err != nil {
+			return fail(fmt.Sprintf("transcript %v", i), err)
+		}
+		if entry.Nonce != "" {
+			e.Revealed[i] = true
+			if e.Nonces[i], err = parseHexElement(entry.Nonce); err != nil {
+				return fail(fmt.Sprintf("transcript %v", i), err)
+			}
+		}
+	}
+	if e.PublicR, err = parseHexElement(doc.PublicR); err != nil {
+		return fail("publicR", err)
+	}
+	e.Commitments = make([]*big.Int, len(doc.Commitments))
+	for i := 0; i < len(doc.Commitments); i++ {
+		if e.Commitments[i], err = parseHexBigInt(doc.Commitments[i], e.Curve); err != nil {
+			return fail(fmt.Sprintf("commitment %v", i), err)
+		}
+		if e.Commitments[i] == nil {
+			return fail(fmt.Sprintf("commitment %v", i), errors.New("missing"))
+		}
+	}
+	if e.CommitmentRoot, err = parseHexElement(doc.CommitmentRoot); err != nil {
+		return fail("commitmentRoot", err)
+	}
+	vectors := []struct {
+		name string
+		dst  *[]fr_bn254.Element
+		src  []string
+	}{
+		{"pairFirst", &e.PairFirst, doc.PairFirst},
+		{"pairSecond", &e.PairSecond, doc.PairSecond},
+		{"dummies", &e.Dummies, doc.Dummies},
+	}
+	for _, v := range vectors {
+		if *v.dst, err = parseHexElements(v.src); err != nil {
+			return fail(v.name, err)
+		}
+	}
+	if len(e.PairFirst) != len(e.PairSecond) {
+		return fail("pairSecond", fmt.Errorf("%v pairs for %v first elements", len(e.PairSecond), len(e.PairFirst)))
+	}
+
+	if e.VerifyingKey, err = os.ReadFile(filepath.Join(dir, epochKeyFile)); err != nil {
+		return nil, err
+	}
+	names, err := filepath.Glob(filepath.Join(dir, epochSubmissions, "*.sub"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	e.Submissions = make([]ClientSubmission, len(names))
+	for i := 0; i < len(names); i++ {
+		if filepath.Base(names[i]) != submissionFile(i) {
+			return nil, fmt.Errorf("%v: missing, the next submission is %v", submissionFile(i), filepath.Base(names[i]))
+		}
+		if e.Submissions[i], err = LoadSubmission(names[i]); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// A client whose proof fails verification after the shuffler released the
+// data would leave its pairs and dummies mixed with the others, and the
+// products would no longer match. The shuffler can take it out without
+// unshuffling anyone else: it keeps, under seal, which positions of the
+// release came from which client and reveals only the positions of the
+// excluded clients. The server divides their factors out of the product of
+// the shuffled data and drops their pairs from the tally.
+//
+// The retention policy of the mapping:
+//   - it is sealed at Release and never leaves the shuffler whole, only as the
+//     reveal of one excluded client;
+//   - a reveal is for a client of the last release and happens at most once;
+//   - it lives in memory only, and Discard drops it once the tally is
+//     published, after which nothing can be revealed.
+
+var (
+	ErrNotReleased      = errors.New("exclusion: the client is not in the release")
+	ErrAlreadyRevealed  = errors.New("exclusion: the positions of the client were already revealed")
+	ErrMappingDiscarded = errors.New("exclusion: the shuffle mapping was discarded")
+	ErrBadExclusion     = errors.New("exclusion: the revealed positions do not fit the release")
+)
+
+// ExclusionReveal is the message of the shuffler to the server for one
+// excluded client: the positions of its pairs and dummies in the release.
+type ExclusionReveal struct {
+	ClientID       uint32
+	PairPositions  []int
+	DummyPositions []int
+}
+
+// ShuffleMapping is the sealed mapping of a release: the owner of every
+// shuffled pair and dummy.
+type ShuffleMapping struct {
+	pairOwners  []uint32
+	dummyOwners []uint32
+	revealed    map[uint32]bool
+	discarded   bool
+}
+
+func newShuffleMapping(pairOwners []uint32, dummyOwners []uint32) *ShuffleMapping {
+	return &ShuffleMapping{
+		pairOwners:  pairOwners,
+		dummyOwners: dummyOwners,
+		revealed:    make(map[uint32]bool),
+	}
+}
+
+// Reveal returns the positions of the client id in the release.
+func (m *ShuffleMapping) Reveal(id uint32) (ExclusionReveal, error) {
+	reveal, err := m.positions(id)
+	if err != nil {
+		return ExclusionReveal{}, err
+	}
+	m.revealed[id] = true
+	return reveal, nil
+}
+
+// positions returns the positions of the client id in the release, without
+// marking them revealed.
+func (m *ShuffleMapping) positions(id uint32) (ExclusionReveal, error) {
+	if m.discarded {
+		return ExclusionReveal{}, ErrMappingDiscarded
+	}
+	if m.revealed[id] {
+		return ExclusionReveal{}, fmt.Errorf("client %v: %w", id, ErrAlreadyRevealed)
+	}
+	reveal := ExclusionReveal{ClientID: id}
+	for i, owner := range m.pairOwners {
+		if owner == id {
+			reveal.PairPositions = append(reveal.PairPositions, i)
+		}
+	}
+	for i, owner := range m.dummyOwners {
+		if owner == id {
+			reveal.DummyPositions = append(reveal.DummyPositions, i)
+		}
+	}
+	if reveal.PairPositions == nil && reveal.DummyPositions == nil {
+		return ExclusionReveal{}, fmt.Errorf("client %v: %w", id, ErrNotReleased)
+	}
+	return reveal, nil
+}
+
+// Discard drops the mapping.
+func (m *ShuffleMapping) Discard() {
+	m.pairOwners, m.dummyOwners, m.revealed = nil, nil, nil
+	m.discarded = true
+}
+
+// Exclude removes the pairs and dummies of the revealed clients from the
+// release and their factors from prodFromShuffler, the product of the release
+// at publicR. The factors of all the excluded clients are multiplied together
+// and inverted once, so that excluding many clients costs a single inversion.
+func Exclude(field Field, candidateNum int, pairFirst []fr_bn254.Element, pairSecond []fr_bn254.Element, dummies []fr_bn254.Element, prodFromShuffler *big.Int, reveals []ExclusionReveal, publicR fr_bn254.Element) ([]fr_bn254.Element, []fr_bn254.Element, []fr_bn254.Element, *big.Int, error) {
+	pairOut := make([]bool, len(pairFirst))
+	dummyOut := make([]bool, len(dummies))
+	var outFirst, outSecond, outDummies []fr_bn254.Element
+	for _, reveal := range reveals {
+		for _, i := range reveal.PairPositions {
+			if i < 0 || i >= len(pairFirst) || pairOut[i] {
+				return nil, nil, nil, nil, fmt.Errorf("client %v: %w", reveal.ClientID, ErrBadExclusion)
+			}
+			pairOut[i] = true
+			outFirst = append(outFirst, pairFirst[i])
+			outSecond = append(outSecond, pairSecond[i])
+		}
+		for _, i := range reveal.DummyPositions {
+			if i < 0 || i >= len(dummies) || dummyOut[i] {
+				return nil, nil, nil, nil, fmt.Errorf("client %v: %w", reveal.ClientID, ErrBadExclusion)
+			}
+			dummyOut[i] = true
+			outDummies = append(outDummies, dummies[i])
+		}
+	}
+
+	removed := field.Mul(field.PolyEval(packPairs(candidateNum, outFirst, outSecond), publicR), field.Prod(outDummies))
+	if removed.Sign() == 0 {
+		return nil, nil, nil, nil, fmt.Errorf("%w: a removed factor is zero", ErrBadExclusion)
+	}
+	prod := field.Mul(prodFromShuffler, field.Inverse(removed))
+
+	var keptFirst, keptSecond, keptDummies []fr_bn254.Element
+	for i := range pairFirst {
+		if !pairOut[i] {
+			keptFirst = append(keptFirst, pairFirst[i])
+			keptSecond = append(keptSecond, pairSecond[i])
+		}
+	}
+	for i := range dummies {
+		if !dummyOut[i] {
+			keptDummies = append(keptDummies, dummies[i])
+		}
+	}
+	return keptFirst, keptSecond, keptDummies, prod, nil
+}
@@ -0,0 +1,131 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/benchrand"
+)
+
+// releaseClients has a shuffler release the shares of n seeded clients.
+func releaseClients(t *testing.T, field Field, n int) (*Shuffler, []ClientState, fr_bn254.Element, []fr_bn254.Element, []fr_bn254.Element, []fr_bn254.Element) {
+	t.Helper()
+	publicR := benchrand.New(100).Fr()
+	shuffler := NewShuffler(CandidateNum, 3)
+	clients := make([]ClientState, n)
+	ids := make([]uint32, n)
+	for i := 0; i < n; i++ {
+		clients[i].InitRand(benchrand.New(uint64(i)), field, 3)
+		ids[i] = uint32(i)
+		shuffler.shares[ids[i]] = &sharesMsg{
+			ClientID:   ids[i],
+			PairFirst:  clients[i].PairFirst,
+			PairSecond: clients[i].PairSecond,
+			Dummies:    clients[i].PrivateY,
+		}
+	}
+	pairFirst, pairSecond, dummies, missing := shuffler.Release(ids)
+	if len(missing) > 0 {
+		t.Fatalf("missing %v", missing)
+	}
+	return shuffler, clients, publicR, pairFirst, pairSecond, dummies
+}
+
+func TestExclude(t *testing.T) {
+	for _, field := range []Field{bn254Field{}, bls12381Field{}} {
+		for _, excluded := range [][]uint32{{2}, {0, 3, 5}} {
+			shuffler, clients, publicR, pairFirst, pairSecond, dummies := releaseClients(t, field, 6)
+			prod := shufflerProduct(field, CandidateNum, pairFirst, pairSecond, dummies, publicR)
+
+			reveals, err := shuffler.RevealExcluded(excluded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pairFirst, pairSecond, dummies, prod, err = Exclude(field, CandidateNum, pairFirst, pairSecond, dummies, prod, reveals, publicR)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			isExcluded := make(map[uint32]bool)
+			for _, id := range excluded {
+				isExcluded[id] = true
+			}
+			var keptFirst, keptSecond []fr_bn254.Element
+			clientsProd := big.NewInt(1)
+			for i := range clients {
+				if isExcluded[uint32(i)] {
+					continue
+				}
+				keptFirst = append(keptFirst, clients[i].PairFirst...)
+				keptSecond = append(keptSecond, clients[i].PairSecond...)
+				clientsProd = field.Mul(clientsProd, clients[i].ComputePolyEval(publicR))
+			}
+			if prod.Cmp(clientsProd) != 0 {
+				t.Fatalf("%v, excluding %v: the product is not the one of the clients left", field.Curve(), excluded)
+			}
+			if prod.Cmp(shufflerProduct(field, CandidateNum, pairFirst, pairSecond, dummies, publicR)) != 0 {
+				t.Fatalf("%v, excluding %v: the product is not the one of the data left", field.Curve(), excluded)
+			}
+			got, err := ComputeTally(pairFirst, pairSecond, CandidateNum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ComputeTally(keptFirst, keptSecond, CandidateNum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%v, excluding %v: tally %v, expected %v", field.Curve(), excluded, got, want)
+			}
+		}
+	}
+}
+
+func TestRevealExcludedPolicy(t *testing.T) {
+	shuffler, _, _, _, _, _ := releaseClients(t, bn254Field{}, 3)
+	if _, err := shuffler.RevealExcluded([]uint32{7}); !errors.Is(err, ErrNotReleased) {
+		t.Fatalf("a client out of the release: got %v, expected ErrNotReleased", err)
+	}
+	// a bad id reveals none of the others
+	if _, err := shuffler.RevealExcluded([]uint32{1, 7}); !errors.Is(err, ErrNotReleased) {
+		t.Fatalf("a client out of the release after another: got %v, expected ErrNotReleased", err)
+	}
+	if _, err := shuffler.RevealExcluded([]uint32{1, 1}); !errors.Is(err, ErrAlreadyRevealed) {
+		t.Fatalf("the same client twice: got %v, expected ErrAlreadyRevealed", err)
+	}
+	if _, err := shuffler.RevealExcluded([]uint32{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shuffler.RevealExcluded([]uint32{1}); !errors.Is(err, ErrAlreadyRevealed) {
+		t.Fatalf("a second reveal: got %v, expected ErrAlreadyRevealed", err)
+	}
+	shuffler.Discard()
+	if _, err := shuffler.RevealExcluded([]uint32{2}); !errors.Is(err, ErrMappingDiscarded) {
+		t.Fatalf("a reveal after Discard: got %v, expected ErrMappingDiscarded", err)
+	}
+}
+
+func TestExcludeBadReveal(t *testing.T) {
+	field := bn254Field{}
+	shuffler, _, publicR, pairFirst, pairSecond, dummies := releaseClients(t, field, 2)
+	prod := shufflerProduct(field, CandidateNum, pairFirst, pairSecond, dummies, publicR)
+	reveals, err := shuffler.RevealExcluded([]uint32{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same positions twice
+	reveals = append(reveals, reveals[0])
+	if _, _, _, _, err := Exclude(field, CandidateNum, pairFirst, pairSecond, dummies, prod, reveals, publicR); !errors.Is(err, ErrBadExclusion) {
+		t.Fatalf("overlapping reveals: got %v, expected ErrBadExclusion", err)
+	}
+	reveals = []ExclusionReveal{{ClientID: 0, PairPositions: []int{len(pairFirst)}}}
+	if _, _, _, _, err := Exclude(field, CandidateNum, pairFirst, pairSecond, dummies, prod, reveals, publicR); !errors.Is(err, ErrBadExclusion) {
+		t.Fatalf("a position out of range: got %v, expected ErrBadExclusion", err)
+	}
+}
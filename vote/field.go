@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/hasher"
+)
+
+// Field is the scalar field of the curve the vote circuit is proved on.
+//
+// The clients sample their inputs (candidates, dummies, salts, the challenge)
+// as BN254 scalars. The BN254 modulus is the smallest of the supported fields,
+// so these inputs are valid elements of every Field and only the values
+// derived from them (the mask, the commitment and the products) depend on the
+// curve. Derived values are returned reduced, as big.Int.
+type Field interface {
+	Curve() ecc.ID
+	// Prod returns vec[0] * ... * vec[n-1].
+	Prod(vec []fr_bn254.Element) *big.Int
+	// PolyEval returns (vec[0] + r) * ... * (vec[n-1] + r).
+	PolyEval(vec []fr_bn254.Element, r fr_bn254.Element) *big.Int
+	// Mul returns a * b.
+	Mul(a, b *big.Int) *big.Int
+	// Inverse returns 1 / a, or 0 if a is 0.
+	Inverse(a *big.Int) *big.Int
+	// Commit returns the digest of the elements under the hash of the
+	// protocol, as computed in the circuit.
+	Commit(vals []*big.Int) *big.Int
+}
+
+// NewField returns the Field of curve under Params.
+func NewField(curve ecc.ID) (Field, error) {
+	return Params.NewField(curve)
+}
+
+// ParseCurve returns the curve named name, e.g. "bn254" or "bls12_381".
+func ParseCurve(name string) (ecc.ID, error) {
+	for _, curve := range ecc.Implemented() {
+		if curve.String() == name {
+			if _, err := NewField(curve); err != nil {
+				return ecc.UNKNOWN, err
+			}
+			return curve, nil
+		}
+	}
+	return ecc.UNKNOWN, fmt.Errorf("unknown curve %q", name)
+}
+
+// curveOf returns the supported curve whose scalar field has the given modulus.
+func curveOf(modulus *big.Int) (ecc.ID, error) {
+	for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+		if curve.ScalarField().Cmp(modulus) == 0 {
+			return curve, nil
+		}
+	}
+	return ecc.UNKNOWN, fmt.Errorf("no supported curve has the scalar field %v", modulus)
+}
+
+// commitBytes hashes vals with h over the field of curve; every value is
+// written as one 32-byte big-endian block, see inputBlocks. NewField checked
+// that h supports curve.
+func commitBytes(h hasher.HashConfig, curve ecc.ID, vals []*big.Int) []byte {
+	goHash, err := h.Native(curve)
+	if err != nil {
+		panic(err)
+	}
+	for _, b := range inputBlocks(vals) {
+		goHash.Write(b)
+	}
+	return goHash.Sum(nil)
+}
+
+// inputBlocks returns the 32-byte big-endian block of every value, left
+// padded with zeros.
+func inputBlocks(vals []*big.Int) [][]byte {
+	blocks := make([][]byte, len(vals))
+	for i := 0; i < len(vals); i++ {
+		blocks[i] = vals[i].FillBytes(make([]byte, 32))
+	}
+	return blocks
+}
+
+// constantTimeEqual reports whether the values a and b of field are equal,
+// comparing their big-endian bytes at the width of the field in constant
+// time: the server compares the commitments and products of the clients
+// with it. A value outside of the field equals none.
+func constantTimeEqual(field Field, a, b *big.Int) bool {
+	size := (field.Curve().ScalarField().BitLen() + 7) / 8
+	if a.Sign() < 0 || b.Sign() < 0 || a.BitLen() > 8*size || b.BitLen() > 8*size {
+		return false
+	}
+	return subtle.ConstantTimeCompare(a.FillBytes(make([]byte, size)), b.FillBytes(make([]byte, size))) == 1
+}
+
+// MiMCInputBytes returns the blocks the native MiMC of a commitment consumes
+// for elems, in order: one 32-byte big-endian block per element. A client in
+// another language writes exactly these bytes to its MiMC to agree with
+// Field.Commit.
+func MiMCInputBytes(elems ...fr_bn254.Element) [][]byte {
+	vals := make([]*big.Int, len(elems))
+	for i := range elems {
+		vals[i] = elems[i].BigInt(new(big.Int))
+	}
+	return inputBlocks(vals)
+}
+
+// The zero value of a field commits with MiMC.
+type bn254Field struct {
+	hash hasher.HashConfig
+}
+
+func (bn254Field) Curve() ecc.ID { return ecc.BN254 }
+
+func (bn254Field) Prod(vec []fr_bn254.Element) *big.Int {
+	prod := fr_bn254.One()
+	for i := 0; i < len(vec); i++ {
+		prod.Mul(&prod, &vec[i])
+	}
+	return prod.BigInt(new(big.Int))
+}
+
+func (bn254Field) PolyEval(vec []fr_bn254.Element, r fr_bn254.Element) *big.Int {
+	prod := PolyEval(vec, r)
+	return prod.BigInt(new(big.Int))
+}
+
+func (bn254Field) Mul(a, b *big.Int) *big.Int {
+	var x, y fr_bn254.Element
+	x.SetBigInt(a)
+	y.SetBigInt(b)
+	x.Mul(&x, &y)
+	return x.BigInt(new(big.Int))
+}
+
+func (bn254Field) Inverse(a *big.Int) *big.Int {
+	var x fr_bn254.Element
+	x.SetBigInt(a)
+	x.Inverse(&x)
+	return x.BigInt(new(big.Int))
+}
+
+func (f bn254Field) Commit(vals []*big.Int) *big.Int {
+	return new(big.Int).SetBytes(commitBytes(f.hash, ecc.BN254, vals))
+}
+
+type bls12381Field struct {
+	hash hasher.HashConfig
+}
+
+func (bls12381Field) Curve() ecc.ID { return ecc.BLS12_381 }
+
+// toBLS12381 maps a BN254 scalar to the same integer in the BLS12-381 field.
+func toBLS12381(x *fr_bn254.Element) fr_bls12381.Element {
+	var b big.Int
+	var res fr_bls12381.Element
+	res.SetBigInt(x.BigInt(&b))
+	return res
+}
+
+func (bls12381Field) Prod(vec []fr_bn254.Element) *big.Int {
+	prod := fr_bls12381.One()
+	for i := 0; i < len(vec); i++ {
+		tmp := toBLS12381(&vec[i])
+		prod.Mul(&prod, &tmp)
+	}
+	return prod.BigInt(new(big.Int))
+}
+
+func (bls12381Field) PolyEval(vec []fr_bn254.Element, r fr_bn254.Element) *big.Int {
+	_r := toBLS12381(&r)
+	prod := fr_bls12381.One()
+	for i := 0; i < len(vec); i++ {
+		tmp := toBLS12381(&vec[i])
+		tmp.Add(&tmp, &_r)
+		prod.Mul(&prod, &tmp)
+	}
+	return prod.BigInt(new(big.Int))
+}
+
+func (bls12381Field) Mul(a, b *big.Int) *big.Int {
+	var x, y fr_bls12381.Element
+	x.SetBigInt(a)
+	y.SetBigInt(b)
+	x.Mul(&x, &y)
+	return x.BigInt(new(big.Int))
+}
+
+func (bls12381Field) Inverse(a *big.Int) *big.Int {
+	var x fr_bls12381.Element
+	x.SetBigInt(a)
+	x.Inverse(&x)
+	return x.BigInt(new(big.Int))
+}
+
+func (f bls12381Field) Commit(vals []*big.Int) *big.Int {
+	return new(big.Int).SetBytes(commitBytes(f.hash, ecc.BLS12_381, vals))
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/witness"
+)
+
+// The JSON documents encode every field element as its 32-byte big-endian
+// hex string, so a checkpoint can be read and diffed by hand. Values in the
+// field of the proof system (see field.go) use the same encoding; a missing
+// one is the empty string.
+
+func hexElement(x *fr_bn254.Element) string {
+	b := x.Bytes()
+	return hex.EncodeToString(b[:])
+}
+
+func parseHexElement(s string) (fr_bn254.Element, error) {
+	var x fr_bn254.Element
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return x, err
+	}
+	if len(b) != fr_bn254.Bytes {
+		return x, fmt.Errorf("element of %v bytes", len(b))
+	}
+	err = x.SetBytesCanonical(b)
+	return x, err
+}
+
+func hexElements(v []fr_bn254.Element) []string {
+	res := make([]string, len(v))
+	for i := 0; i < len(v); i++ {
+		res[i] = hexElement(&v[i])
+	}
+	return res
+}
+
+func parseHexElements(v []string) ([]fr_bn254.Element, error) {
+	res := make([]fr_bn254.Element, len(v))
+	for i := 0; i < len(v); i++ {
+		var err error
+		if res[i], err = parseHexElement(v[i]); err != nil {
+			return nil, fmt.Errorf("element %v: %w", i, err)
+		}
+	}
+	return res, nil
+}
+
+func hexBigInt(x *big.Int) string {
+	if x == nil {
+		return ""
+	}
+	var b [fr_bn254.Bytes]byte
+	x.FillBytes(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// parseHexBigInt parses an element of the field of curve.
+func parseHexBigInt(s string, curve ecc.ID) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != fr_bn254.Bytes {
+		return nil, fmt.Errorf("element of %v bytes", len(b))
+	}
+	x := new(big.Int).SetBytes(b)
+	if x.Cmp(curve.ScalarField()) >= 0 {
+		return nil, fmt.Errorf("element %v is not reduced", s)
+	}
+	return x, nil
+}
+
+type clientStateJSON struct {
+	Curve           string   `json:"curve"`
+	SortedCandidate []string `json:"sortedCandidate"`
+	PairFirst       []string `json:"pairFirst"`
+	PairSecond      []string `json:"pairSecond"`
+	PrivateX        []string `json:"privateX"`
+	PrivateY        []string `json:"privateY"`
+	PublicCom       string   `json:"publicCom"`
+	PrivateMask     string   `json:"privateMask"`
+	PrivateSalt     string   `json:"privateSalt"`
+	PublicR         string   `json:"publicR"`
+	Nonce           string   `json:"nonce"`
+	NonceCom        string   `json:"nonceCom"`
+	SecretKey       string   `json:"secretKey"`
+	VoterCom        string   `json:"voterCom"`
+}
+
+// MarshalJSON encodes the client, including its private inputs.
+func (c *ClientState) MarshalJSON() ([]byte, error) {
+	if c.field == nil {
+		return nil, fmt.Errorf("json: the client is not initialized")
+	}
+	return json.Marshal(clientStateJSON{
+		Curve:           c.field.Curve().String(),
+		SortedCandidate: hexElements(c.SortedCandidate),
+		PairFirst:       hexElements(c.PairFirst),
+		PairSecond:      hexElements(c.PairSecond),
+		PrivateX:        hexElements(c.PrivateX),
+		PrivateY:        hexElements(c.PrivateY),
+		PublicCom:       hexBigInt(c.PublicCom),
+		PrivateMask:     hexBigInt(c.PrivateMask),
+		PrivateSalt:     hexElement(&c.PrivateSalt),
+		PublicR:         hexElement(&c.PublicR),
+		Nonce:           hexElement(&c.Nonce),
+		NonceCom:        hexElement(&c.NonceCom),
+		SecretKey:       hexElement(&c.SecretKey),
+		VoterCom:        hexBigInt(c.VoterCom),
+	})
+}
+
+// UnmarshalJSON decodes a client encoded by MarshalJSON.
+func (c *ClientState) UnmarshalJSON(data []byte) error {
+	var doc clientStateJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	curve, err := ParseCurve(doc.Curve)
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	field, err := NewField(curve)
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+
+	var res ClientState
+	res.field = field
+	vectors := []struct {
+		name string
+		dst  *[]fr_bn254.Element
+		src  []string
+	}{
+		{"sortedCandidate", &res.SortedCandidate, doc.SortedCandidate},
+		{"pairFirst", &res.PairFirst, doc.PairFirst},
+		{"pairSecond", &res.PairSecond, doc.PairSecond},
+		{"privateX", &res.PrivateX, doc.PrivateX},
+		{"privateY", &res.PrivateY, doc.PrivateY},
+	}
+	for _, v := range vectors {
+		if *v.dst, err = parseHexElements(v.src); err != nil {
+			return fmt.Errorf("json: %v: %w", v.name, err)
+		}
+	}
+	scalars := []struct {
+		name string
+		dst  **big.Int
+		src  string
+	}{
+		{"publicCom", &res.PublicCom, doc.PublicCom},
+		{"privateMask", &res.PrivateMask, doc.PrivateMask},
+		{"voterCom", &res.VoterCom, doc.VoterCom},
+	}
+	for _, v := range scalars {
+		if *v.dst, err = parseHexBigInt(v.src, curve); err != nil {
+			return fmt.Errorf("json: %v: %w", v.name, err)
+		}
+	}
+	elements := []struct {
+		name string
+		dst  *fr_bn254.Element
+		src  string
+	}{
+		{"privateSalt", &res.PrivateSalt, doc.PrivateSalt},
+		{"publicR", &res.PublicR, doc.PublicR},
+		{"nonce", &res.Nonce, doc.Nonce},
+		{"nonceCom", &res.NonceCom, doc.NonceCom},
+		{"secretKey", &res.SecretKey, doc.SecretKey},
+	}
+	for _, v := range elements {
+		if *v.dst, err = parseHexElement(v.src); err != nil {
+			return fmt.Errorf("json: %v: %w", v.name, err)
+		}
+	}
+	*c = res
+	return nil
+}
+
+type clientSubmissionJSON struct {
+	Backend       string `json:"backend"`
+	Curve         string `json:"curve"`
+	PublicProd    string `json:"publicProd"`
+	Proof         string `json:"proof,omitempty"`
+	PublicWitness string `json:"publicWitness,omitempty"`
+}
+
+// MarshalJSON encodes the submission, so that a server can checkpoint the
+// submissions it has not verified yet. The proof and the public witness are
+// hex encoded in their binary form.
+func (s *ClientSubmission) MarshalJSON() ([]byte, error) {
+	if s.system == nil {
+		return nil, fmt.Errorf("json: the submission has no proof system")
+	}
+	doc := clientSubmissionJSON{
+		Backend:    s.system.Backend().String(),
+		Curve:      s.system.Curve().String(),
+		PublicProd: hexBigInt(s.publicProd),
+		Proof:      hex.EncodeToString(s.proof),
+	}
+	if s.publicWitness != nil {
+		b, err := s.publicWitness.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("json: public witness: %w", err)
+		}
+		doc.PublicWitness = hex.EncodeToString(b)
+	}
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON decodes a submission encoded by MarshalJSON and attaches a
+// fresh ProofSystem of its backend and curve.
+func (s *ClientSubmission) UnmarshalJSON(data []byte) error {
+	var doc clientSubmissionJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	id, err := ParseBackend(doc.Backend)
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	curve, err := ParseCurve(doc.Curve)
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	system, err := NewProofSystem(id, curve)
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	publicProd, err := parseHexBigInt(doc.PublicProd, curve)
+	if err != nil {
+		return fmt.Errorf("json: publicProd: %w", err)
+	}
+	if publicProd == nil {
+		return fmt.Errorf("json: the submission has no publicProd")
+	}
+	proof, err := hex.DecodeString(doc.Proof)
+	if err != nil {
+		return fmt.Errorf("json: proof: %w", err)
+	}
+	publicWitnessBytes, err := hex.DecodeString(doc.PublicWitness)
+	if err != nil {
+		return fmt.Errorf("json: public witness: %w", err)
+	}
+	if (len(proof) == 0) != (len(publicWitnessBytes) == 0) {
+		return fmt.Errorf("json: a proof needs a public witness and vice versa")
+	}
+	var publicWitness witness.Witness
+	if len(publicWitnessBytes) > 0 {
+		if publicWitness, err = decodePublicWitness(curve, publicWitnessBytes); err != nil {
+			return fmt.Errorf("json: public witness: %w", err)
+		}
+	} else {
+		proof = nil
+	}
+
+	s.publicWitness = publicWitness
+	s.publicProd = publicProd
+	s.proof = proof
+	s.system = system
+	return nil
+}
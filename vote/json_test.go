@@ -0,0 +1,69 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+func TestClientStateJSONRoundTrip(t *testing.T) {
+	for _, field := range []Field{bn254Field{}, bls12381Field{}} {
+		t.Run(field.Curve().String(), func(t *testing.T) {
+			var c ClientState
+			c.Init(field, 8)
+			c.PublicR = randomFr()
+
+			data, err := json.Marshal(&c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded ClientState
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c, decoded) {
+				t.Fatalf("the decoded client differs:\n%+v\n%+v", c, decoded)
+			}
+		})
+	}
+}
+
+func TestClientSubmissionJSONRoundTrip(t *testing.T) {
+	allSubmission, vk := genSubmissions(t, NewGroth16System(ecc.BN254), 2)
+	// client 1 does not attach a proof
+	allSubmission[1].proof = nil
+	allSubmission[1].publicWitness = nil
+
+	data, err := json.Marshal(allSubmission)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []ClientSubmission
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(allSubmission); i++ {
+		var want, got bytes.Buffer
+		if _, err := allSubmission[i].WriteTo(&want); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decoded[i].WriteTo(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(want.Bytes(), got.Bytes()) {
+			t.Fatalf("client %v: the decoded submission differs", i)
+		}
+	}
+	if errs := VerifySubmissions(decoded, vk); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	if err := json.Unmarshal([]byte(`{"backend":"groth16","curve":"bn254","publicProd":"zz"}`), &decoded[0]); err == nil {
+		t.Fatal("no error for a malformed publicProd")
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// KeyDir is the directory caching the compiled circuits and their keys
+// between runs, see CompileAndSetup. The cache is opt-in: an empty KeyDir
+// disables it.
+var KeyDir = ""
+
+// CircuitChecksum returns a short hex digest of the serialized constraint
+// system, for the server and the clients to check that they compiled the same
+// circuit.
+func CircuitChecksum(ccs constraint.ConstraintSystem) (string, error) {
+	h := sha256.New()
+	if _, err := ccs.WriteTo(h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+func writeToFile(name string, w io.WriterTo) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := w.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readFromFile(name string, r io.ReaderFrom) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = r.ReadFrom(f)
+	return err
+}
+
+// ErrKeyMismatch is returned when the saved keys belong to another circuit.
+var ErrKeyMismatch = errors.New("keys: the saved keys belong to another circuit")
+
+// keyMetadata is saved next to a key pair and checked when it is loaded.
+type keyMetadata struct {
+	CandidateNum int `json:"candidateNum"`
+}
+
+func saveKeys(pk io.WriterTo, vk io.WriterTo, dir string, scheme string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(keyMetadata{CandidateNum: CandidateNum})
+	if err != nil {
+		return err
+	}
+	metaFile := filepath.Join(dir, scheme+".json")
+	if err := os.WriteFile(metaFile, meta, 0600); err != nil {
+		return fmt.Errorf("write %v: %w", metaFile, err)
+	}
+	pkFile := filepath.Join(dir, scheme+".pk")
+	if err := writeToFile(pkFile, pk); err != nil {
+		return fmt.Errorf("write %v: %w", pkFile, err)
+	}
+	vkFile := filepath.Join(dir, scheme+".vk")
+	if err := writeToFile(vkFile, vk); err != nil {
+		return fmt.Errorf("write %v: %w", vkFile, err)
+	}
+	return nil
+}
+
+// checkKeyMetadata rejects the keys saved in dir for another number of
+// candidates, and the keys saved without metadata, which could belong to any
+// circuit.
+func checkKeyMetadata(dir string, scheme string) error {
+	metaFile := filepath.Join(dir, scheme+".json")
+	b, err := os.ReadFile(metaFile)
+	if errors.Is(err, os.ErrNotExist) {
+		// keys without metadata are a mismatch, no keys at all are not
+		if _, statErr := os.Stat(filepath.Join(dir, scheme+".pk")); statErr == nil {
+			return fmt.Errorf("%w: %v has no metadata", ErrKeyMismatch, dir)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("read %v: %w", metaFile, err)
+	}
+	var meta keyMetadata
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return fmt.Errorf("read %v: %w", metaFile, err)
+	}
+	if meta.CandidateNum != CandidateNum {
+		return fmt.Errorf("%w: %v candidates, expected %v", ErrKeyMismatch, meta.CandidateNum, CandidateNum)
+	}
+	return nil
+}
+
+func loadKeys(pk io.ReaderFrom, vk io.ReaderFrom, dir string, scheme string) error {
+	if err := checkKeyMetadata(dir, scheme); err != nil {
+		return err
+	}
+	pkFile := filepath.Join(dir, scheme+".pk")
+	if err := readFromFile(pkFile, pk); err != nil {
+		return fmt.Errorf("read %v: %w", pkFile, err)
+	}
+	vkFile := filepath.Join(dir, scheme+".vk")
+	if err := readFromFile(vkFile, vk); err != nil {
+		return fmt.Errorf("read %v: %w", vkFile, err)
+	}
+	return nil
+}
+
+// SaveGroth16Keys writes a groth16 key pair to dir, together with the number
+// of candidates of the circuit.
+func SaveGroth16Keys(pk groth16.ProvingKey, vk groth16.VerifyingKey, dir string) error {
+	return saveKeys(pk, vk, dir, "groth16")
+}
+
+// LoadGroth16Keys reads a groth16 key pair on curve from dir. The error wraps
+// os.ErrNotExist when no key pair has been saved there, and ErrKeyMismatch
+// when the keys were saved for another number of candidates.
+func LoadGroth16Keys(curve ecc.ID, dir string) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	pk := groth16.NewProvingKey(curve)
+	vk := groth16.NewVerifyingKey(curve)
+	if err := loadKeys(pk, vk, dir, "groth16"); err != nil {
+		return nil, nil, err
+	}
+	return pk, vk, nil
+}
+
+// A subdirectory of KeyDir holds everything the setup of a circuit produces:
+// the compiled constraint system, the key pair and, for plonk, the kzg srs, so
+// a run reusing it starts proving right away. Every circuit has its own
+// subdirectory with a manifest of the shape of the circuit, and reusing the
+// directory for another shape is an error rather than a cache miss.
+
+const (
+	manifestFile = "manifest.json"
+	ccsFile      = "circuit.ccs"
+	pkFile       = "proving.key"
+	vkFile       = "verifying.key"
+	srsFile      = "kzg.srs"
+)
+
+// KeyManifest is the shape of the circuit the saved keys belong to.
+type KeyManifest struct {
+	Circuit      string `json:"circuit"`
+	Backend      string `json:"backend"`
+	Curve        string `json:"curve"`
+	CandidateNum int    `json:"candidateNum"`
+	PairNum      int    `json:"pairNum"`
+	DummyNum     int    `json:"dummyNum"`
+	Hash         string `json:"hash"`
+}
+
+// VoteManifest is the manifest of the vote circuit of the configuration with
+// dummyNum dummies.
+func VoteManifest(system ProofSystem, dummyNum int) KeyManifest {
+	return KeyManifest{
+		Circuit:      "vote",
+		Backend:      system.Backend().String(),
+		Curve:        system.Curve().String(),
+		CandidateNum: CandidateNum,
+		PairNum:      CandidateNum * (CandidateNum - 1) / 2,
+		DummyNum:     dummyNum,
+		Hash:         Params.Hash.String(),
+	}
+}
+
+// NullifierManifest is the manifest of the nullifier circuit of the
+// configuration.
+func NullifierManifest(system ProofSystem) KeyManifest {
+	return KeyManifest{
+		Circuit: "nullifier",
+		Backend: system.Backend().String(),
+		Curve:   system.Curve().String(),
+		Hash:    Params.Hash.String(),
+	}
+}
+
+// serializable is a key, or an srs, gnark writes and reads back.
+type serializable interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// Keys are the artifacts of the setup of a circuit. SRS is nil for groth16.
+type Keys struct {
+	Manifest KeyManifest
+	CCS      constraint.ConstraintSystem
+	PK       serializable
+	VK       serializable
+	SRS      kzg.SRS
+}
+
+// artifacts returns the files of k and what they hold.
+func (k *Keys) artifacts() ([]string, []serializable) {
+	names := []string{ccsFile, pkFile, vkFile}
+	objects := []serializable{k.CCS, k.PK, k.VK}
+	if k.SRS != nil {
+		names = append(names, srsFile)
+		objects = append(objects, k.SRS)
+	}
+	return names, objects
+}
+
+// SaveKeys writes the artifacts of k to dir, then the manifest, so that an
+// interrupted save is a cache miss on the next run.
+func (k *Keys) SaveKeys(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	names, objects := k.artifacts()
+	for i := range names {
+		name := filepath.Join(dir, names[i])
+		if err := writeToFile(name, objects[i]); err != nil {
+			return fmt.Errorf("write %v: %w", name, err)
+		}
+	}
+	manifest, err := json.MarshalIndent(k.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, manifestFile)
+	if err := os.WriteFile(name, manifest, 0600); err != nil {
+		return fmt.Errorf("write %v: %w", name, err)
+	}
+	return nil
+}
+
+// LoadKeys reads the artifacts saved in dir for the circuit of k.Manifest.
+// The error wraps os.ErrNotExist when nothing has been saved in dir, and
+// ErrKeyMismatch when dir holds the artifacts of another circuit.
+func (k *Keys) LoadKeys(dir string) error {
+	name := filepath.Join(dir, manifestFile)
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("read %v: %w", name, err)
+	}
+	var saved KeyManifest
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return fmt.Errorf("read %v: %w", name, err)
+	}
+	if saved != k.Manifest {
+		return fmt.Errorf("%w: %v holds %+v, expected %+v", ErrKeyMismatch, dir, saved, k.Manifest)
+	}
+
+	curve, err := ParseCurve(k.Manifest.Curve)
+	if err != nil {
+		return err
+	}
+	switch k.Manifest.Backend {
+	case backend.GROTH16.String():
+		k.CCS = groth16.NewCS(curve)
+		k.PK, k.VK, k.SRS = groth16.NewProvingKey(curve), groth16.NewVerifyingKey(curve), nil
+	case backend.PLONK.String():
+		if err := k.newPlonk(curve); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported backend %v", k.Manifest.Backend)
+	}
+	names, objects := k.artifacts()
+	for i := range names {
+		name := filepath.Join(dir, names[i])
+		if err := readFromFile(name, objects[i]); err != nil {
+			return fmt.Errorf("read %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// keyedSystem is a ProofSystem whose keys can be saved and reloaded.
+type keyedSystem interface {
+	// setupKeys runs the setup of ccs, without any cache.
+	setupKeys(ccs constraint.ConstraintSystem) (*Keys, error)
+	// useKeys makes the system prove with the proving key of k.
+	useKeys(k *Keys) (VerifyingKeyBytes, error)
+}
+
+// compileCircuit compiles circuit with system and checks, before any setup,
+// that its public variables are public.
+func compileCircuit(system ProofSystem, circuit frontend.Circuit, public []string) (constraint.ConstraintSystem, error) {
+	ccs, err := system.Compile(circuit)
+	if err != nil {
+		return nil, fmt.Errorf("circuit compile: %w", err)
+	}
+	if err := gnarkshim.CheckPublic(ccs, public); err != nil {
+		return nil, err
+	}
+	return ccs, nil
+}
+
+// CompileAndSetup compiles circuit, whose public variables are public, and
+// sets up its keys with system. With a KeyDir, the artifacts saved for
+// manifest are reused, and saved on the first run. The public variables of a
+// reused circuit are checked as those of a compiled one.
+func CompileAndSetup(system ProofSystem, circuit frontend.Circuit, public []string, manifest KeyManifest) (constraint.ConstraintSystem, VerifyingKeyBytes, error) {
+	keyed, ok := system.(keyedSystem)
+	if KeyDir == "" || !ok {
+		ccs, err := compileCircuit(system, circuit, public)
+		if err != nil {
+			return nil, nil, err
+		}
+		vk, err := system.Setup(ccs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("setup: %w", err)
+		}
+		return ccs, vk, nil
+	}
+
+	dir := filepath.Join(KeyDir, manifest.Circuit+"-"+manifest.Backend)
+	keys := Keys{Manifest: manifest}
+	err := keys.LoadKeys(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		ccs, err := compileCircuit(system, circuit, public)
+		if err != nil {
+			return nil, nil, err
+		}
+		saved, err := keyed.setupKeys(ccs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("setup: %w", err)
+		}
+		saved.Manifest = manifest
+		if err := saved.SaveKeys(dir); err != nil {
+			return nil, nil, err
+		}
+		keys = *saved
+	} else if err != nil {
+		return nil, nil, err
+	} else if err := gnarkshim.CheckPublic(keys.CCS, public); err != nil {
+		// the cache hit skipped compileCircuit
+		return nil, nil, fmt.Errorf("%v: %w", dir, err)
+	}
+	vk, err := keyed.useKeys(&keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys.CCS, vk, nil
+}
@@ -0,0 +1,186 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"example/verification/internal/benchreport"
+	"example/verification/internal/hasher"
+)
+
+// The command line entry point of the benchmark and of the network demo. The
+// wasm client has its own, see wasm.go.
+
+func main() {
+	// the network demo and the audit tools run instead of the benchmark
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "server":
+			os.Exit(serverMain(os.Args[2:]))
+		case "client":
+			os.Exit(clientMain(os.Args[2:]))
+		case "verify":
+			os.Exit(verifyMain(os.Args[2:]))
+		case "observe":
+			os.Exit(observeMain(os.Args[2:]))
+		case "admin":
+			os.Exit(adminMain(os.Args[2:]))
+		}
+	}
+
+	flag.IntVar(&ClientNum, "clients", ClientNum, "number of clients")
+	flag.IntVar(&CandidateNum, "candidates", CandidateNum, "number of candidates")
+	flag.IntVar(&CorruptedNum, "corrupted", CorruptedNum, "number of corrupted clients")
+	flag.Uint64Var(&Lambda, "lambda", Lambda, "statistical security parameter")
+	format := flag.String("format", Format.String(), "format of the results, csv, or json for JSON lines next to the CSV")
+	hashName := flag.String("hash", Params.Hash.String(), "hash of the commitments and the nullifiers, mimc or poseidon (bn254 only)")
+	curveName := flag.String("curve", Curve.String(), "curve of the proof systems, bn254 or bls12_381")
+	flag.IntVar(&Workers, "workers", Workers, "number of goroutines generating and verifying the client proofs")
+	flag.IntVar(&TallyQuorum, "quorum", TallyQuorum, "verified proofs required before tallying, 0 for all checked proofs")
+	flag.StringVar(&KeyDir, "keydir", KeyDir, "directory caching the compiled circuits, the keys and the srs between runs, empty to disable")
+	flag.StringVar(&SubmissionDir, "submissions", SubmissionDir, "directory to save the submissions in for \"vote verify\", empty to disable")
+	compression := flag.String("compress", Compression.String(), "codec of the compressible messages, none, deflate or zstd")
+	duplicates := flag.String("duplicates", Duplicates.String(), "policy for duplicate commitments, reject or audit")
+	flag.Uint64Var(&ElectionID, "election-id", ElectionID, "identifier of the election the nullifiers and the Fiat-Shamir challenge are bound to")
+	flag.BoolVar(&FiatShamir, "fiat-shamir", FiatShamir, "derive the challenge from the vote commitments instead of the server seed and the client nonces")
+	flag.Float64Var(&AuditFraction, "audit", AuditFraction, "fraction of the clients opening their ballot instead of having their proof verified")
+	flag.Float64Var(&MaliciousFrac, "malicious-frac", MaliciousFrac, "fraction of the clients shipping to the shuffler pairs that do not match their proof, to simulate their detection")
+	flag.Uint64Var(&Seed, "seed", Seed, "seed of a reproducible run, for benchmarks only; 0 for crypto/rand")
+	flag.StringVar(&MixnetFile, "mixnet", MixnetFile, "batch file of an external mixnet to import the shuffled ballots from instead of shuffling them, see mixnet.go")
+	flag.StringVar(&MixnetSource, "mixnet-source", MixnetSource, "source the attestation of the -mixnet batch must name, empty to accept any")
+	flag.StringVar(&MetricsFile, "metrics", MetricsFile, "JSON lines file the metrics of every run are appended to, empty to disable")
+	flag.BoolVar(&Localize, "localize", Localize, "name the clients whose product is inconsistent when the consistency check fails")
+	flag.BoolVar(&DebugSolver, "debug-solver", DebugSolver, "name the invariant of the circuit a witness breaks when its proof fails")
+	flag.BoolVar(&VerboseMetrics, "verbose-metrics", VerboseMetrics, "keep the raw verification latencies in the -metrics file")
+	dummies := flag.String("dummies", "", "comma separated numbers of dummies per client to sweep instead of the one the security target requires")
+	flag.StringVar(&RunDir, "run-dir", RunDir, "directory of the results and of the resolved configuration of the run")
+	configFile := flag.String(configFlag, "", "JSON or YAML file setting the flags by name, overridden by the command line, see config.go")
+	flag.Parse()
+	if err := LoadConfig(flag.CommandLine, *configFile); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	var err error
+	if DummyLengths, err = benchreport.ParseDummyLengths(*dummies); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if Duplicates, err = ParseDuplicatePolicy(*duplicates); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if Compression, err = ParseCodec(*compression); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if Format, err = benchreport.ParseFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if Params.Hash, err = hasher.Parse(*hashName); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if Curve, err = ParseCurve(*curveName); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if err := validateConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	if err := SaveConfig(flag.CommandLine, filepath.Join(RunDir, "vote-config.json")); err != nil {
+		log.Fatalf("save the configuration: %v", err)
+	}
+
+	var files []*os.File
+	reporters, files, err = openReporters(RunDir, Format)
+	if err != nil {
+		panic(err)
+	}
+	for _, f := range files {
+		defer f.Close()
+	}
+
+	// without -dummies, one length: the one of the security target
+	lengths := DummyLengths
+	if len(lengths) == 0 {
+		lengths = []uint64{0}
+	}
+	for _, system := range []ProofSystem{NewGroth16System(Curve), NewPlonkSystem(Curve)} {
+		var sweep []benchreport.BenchmarkResult
+		for _, dummyNum := range lengths {
+			var results []benchreport.BenchmarkResult
+			for t := 0; t < TestRepeat; t++ {
+				result, err := Vote(system, dummyNum)
+				if MaliciousFrac > 0 && errors.Is(err, ErrInconsistent) {
+					// the simulation expects the server to abort
+					log.Printf("Voting %v: malicious clients detected: %v\n", system.Name(), err)
+					continue
+				}
+				if err != nil {
+					log.Fatalf("Voting %v: %v", system.Name(), err)
+				}
+				results = append(results, result)
+			}
+			if len(results) == 0 {
+				continue
+			}
+			mean := benchreport.Mean(results)
+			if TestRepeat > 1 {
+				log.Printf("%v, mean of %v runs: proof %v, verify %v, server %v, communication %v bytes\n",
+					system.Name(), TestRepeat, mean.ProofTime, mean.VerifyTime, mean.ServerTime, mean.CommCost.Total)
+			}
+			sweep = append(sweep, mean)
+		}
+		if len(DummyLengths) > 0 {
+			logSweep(system, sweep)
+		}
+	}
+
+	//ShuffleZKPlonk()
+}
+
+// logSweep logs the per client cost of every dummy length of -dummies, the
+// lengths below the security target marked out.
+func logSweep(system ProofSystem, sweep []benchreport.BenchmarkResult) {
+	log.Printf("=====Dummy Sweep (%v)=====\n", system.Name())
+	for _, r := range sweep {
+		mark := ""
+		if r.BelowTarget {
+			mark = "  <-- BELOW THE SECURITY TARGET"
+		}
+		log.Printf("%v dummies: preparation %v, communication %v bytes%v\n", r.DummyLen, r.PrepTime, r.CommCost.Total, mark)
+	}
+	log.Printf("============================\n")
+}
+
+// openReporters opens the results of the runs in dir: output-vote.csv, and
+// output-vote.jsonl as well for FormatJSON. The caller closes the files.
+func openReporters(dir string, format benchreport.Format) ([]*benchreport.Reporter, []*os.File, error) {
+	formats := []benchreport.Format{benchreport.FormatCSV}
+	if format == benchreport.FormatJSON {
+		formats = append(formats, benchreport.FormatJSON)
+	}
+	var reps []*benchreport.Reporter
+	var files []*os.File
+	for _, f := range formats {
+		rep, file, err := benchreport.OpenReporter(filepath.Join(dir, "output-vote"+f.Ext()), f)
+		if err != nil {
+			for _, opened := range files {
+				opened.Close()
+			}
+			return nil, nil, err
+		}
+		reps = append(reps, rep)
+		files = append(files, file)
+	}
+	return reps, files, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/benchrand"
+)
+
+// A malicious client proves the ballot it committed to, PrivateX, but ships
+// other pairs to the shuffler. Its proof verifies, so only the product check
+// can catch it: the shuffled pairs no longer evaluate to the product of the
+// PublicProd of the clients. The simulation mode draws MaliciousFrac of the
+// clients and has them cheat this way, to check that the server detects them.
+
+// MaliciousFrac is the fraction of the clients shipping to the shuffler pairs
+// that do not match their proof; any nonzero fraction is at least one client.
+var MaliciousFrac = 0.0
+
+// SelectMalicious draws the ceil(fraction * n) clients out of n that cheat,
+// in increasing order.
+func SelectMalicious(rnd *benchrand.Rand, n int, fraction float64) []int {
+	k := int(math.Ceil(fraction * float64(n)))
+	if k <= 0 {
+		return nil
+	}
+	if k > n {
+		k = n
+	}
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	rnd.Shuffle(n, func(i, j int) { perm[i], perm[j] = perm[j], perm[i] })
+	malicious := perm[:k]
+	sort.Ints(malicious)
+	return malicious
+}
+
+// ShipInconsistentPairs returns clients as the shuffler receives them when
+// the clients in malicious cheat: the second candidate of their first pair is
+// swapped for the next one, while their PrivateX, commitment and product keep
+// the ballot they prove. clients is not changed.
+func ShipInconsistentPairs(clients []ClientState, malicious []int) []ClientState {
+	if len(malicious) == 0 {
+		return clients
+	}
+	shipped := append([]ClientState(nil), clients...)
+	for _, i := range malicious {
+		second := append([]fr_bn254.Element(nil), clients[i].PairSecond...)
+		second[0].SetUint64((second[0].Uint64() + 1) % uint64(CandidateNum))
+		shipped[i].PairSecond = second
+	}
+	return shipped
+}
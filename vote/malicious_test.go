@@ -0,0 +1,67 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/benchreport"
+	"example/verification/internal/hasher"
+)
+
+func TestSelectMalicious(t *testing.T) {
+	if malicious := SelectMalicious(benchrand.New(1), 10, 0); len(malicious) != 0 {
+		t.Fatalf("no fraction: %v", malicious)
+	}
+	if malicious := SelectMalicious(benchrand.New(1), 10, 0.01); len(malicious) != 1 {
+		t.Fatalf("a small fraction: %v, expected one client", malicious)
+	}
+	if malicious := SelectMalicious(benchrand.New(1), 10, 1); len(malicious) != 10 {
+		t.Fatalf("all the clients: %v", malicious)
+	}
+}
+
+func TestVoteMalicious(t *testing.T) {
+	defer func(n, c, k int, l, seed uint64, keyDir string, frac float64, localize bool, r []*benchreport.Reporter) {
+		ClientNum, CandidateNum, CorruptedNum, Lambda, Seed, KeyDir, MaliciousFrac, Localize, reporters = n, c, k, l, seed, keyDir, frac, localize, r
+	}(ClientNum, CandidateNum, CorruptedNum, Lambda, Seed, KeyDir, MaliciousFrac, Localize, reporters)
+	defer func(p ProtocolParams) { Params = p }(Params)
+	ClientNum, CandidateNum, CorruptedNum, Lambda, Seed, KeyDir, Localize = 12, 3, 2, 40, 3, "", true
+	Params = ProtocolParams{Hash: hasher.Stub}
+	reporters = []*benchreport.Reporter{benchreport.NewReporter(io.Discard, benchreport.FormatJSON)}
+
+	dummyNum, err := ComputeDummyNum(Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frac := range []float64{0.01, 0.5} {
+		MaliciousFrac = frac
+		_, err := Vote(NewGroth16System(ecc.BN254), 0)
+		if !errors.Is(err, ErrInconsistent) {
+			t.Fatalf("fraction %v: got %v, expected ErrInconsistent", frac, err)
+		}
+
+		// a seeded run draws the clients, then the malicious ones, from the
+		// seed: the server names exactly those
+		rnd := benchrand.New(Seed)
+		for i := 0; i < ClientNum; i++ {
+			var c ClientState
+			c.InitRand(rnd, bn254Field{}, dummyNum)
+		}
+		malicious := SelectMalicious(rnd, ClientNum, frac)
+		if !strings.Contains(err.Error(), fmt.Sprintf("clients %v ", malicious)) {
+			t.Fatalf("fraction %v: %v does not name the malicious clients %v", frac, err, malicious)
+		}
+		// their openings hold, and show that they shipped other pairs
+		if !strings.Contains(err.Error(), fmt.Sprintf("blame %v and clear []", malicious)) {
+			t.Fatalf("fraction %v: %v does not blame the malicious clients %v", frac, err, malicious)
+		}
+	}
+}
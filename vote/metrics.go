@@ -0,0 +1,169 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// The aggregate "Verify" time of the benchmark hides the spread of the
+// verification of the single proofs: the first call also parses the verifying
+// key, and the batch of Groth16 has no per-proof time at all. The latency of
+// every checked proof is therefore measured on its own, one proof at a time,
+// before the proofs are verified for the election.
+
+// LatencyStats summarizes the latencies of the verification of the proofs, in
+// the order of the calls. First is the latency of the first call, which also
+// pays for the preprocessing of the verifying key. Samples is only kept with
+// -verbose-metrics.
+type LatencyStats struct {
+	Count   int             `json:"count"`
+	First   time.Duration   `json:"first"`
+	Min     time.Duration   `json:"min"`
+	P50     time.Duration   `json:"p50"`
+	P95     time.Duration   `json:"p95"`
+	Max     time.Duration   `json:"max"`
+	Samples []time.Duration `json:"samples,omitempty"`
+}
+
+// NewLatencyStats summarizes samples, given in the order of the calls. The
+// percentiles are nearest-rank.
+func NewLatencyStats(samples []time.Duration) LatencyStats {
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencyStats{
+		Count: len(samples),
+		First: samples[0],
+		Min:   sorted[0],
+		P50:   percentile(sorted, 50),
+		P95:   percentile(sorted, 95),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func (s LatencyStats) String() string {
+	return fmt.Sprintf("first %v, min %v, p50 %v, p95 %v, max %v (%v proofs)", s.First, s.Min, s.P50, s.P95, s.Max, s.Count)
+}
+
+// errNoLatency is returned when there is no proof to measure.
+var errNoLatency = errors.New("metrics: no proof to verify")
+
+// measureLatencies calls verify on 0..n-1 in order and returns the time each
+// call took on clock. It stops at the first error.
+func measureLatencies(n int, clock func() time.Time, verify func(i int) error) ([]time.Duration, error) {
+	samples := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		start := clock()
+		if err := verify(i); err != nil {
+			return nil, fmt.Errorf("client %v: verification: %w", i, err)
+		}
+		samples = append(samples, clock().Sub(start))
+	}
+	return samples, nil
+}
+
+// MeasureVerifyLatency verifies the proofs attached to the submissions one
+// at a time and returns their latencies. Run before any other verification
+// against vk, the first sample includes the parsing of vk.
+func MeasureVerifyLatency(allSubmission []ClientSubmission, vk VerifyingKeyBytes) ([]time.Duration, error) {
+	var proved []*ClientSubmission
+	for i := 0; i < len(allSubmission); i++ {
+		if allSubmission[i].proof != nil {
+			proved = append(proved, &allSubmission[i])
+		}
+	}
+	if len(proved) == 0 {
+		return nil, errNoLatency
+	}
+	return measureLatencies(len(proved), time.Now, func(i int) error {
+		return VerifySubmission(vk, proved[i])
+	})
+}
+
+// The memory of a run is sampled around its costly phases, each sample after
+// a garbage collection so that the heap only holds what is still live. The
+// change of the live heap across a phase is what the phase keeps, such as the
+// keys of the setup; Sys, the memory obtained from the OS, is never given
+// back to it and its last sample is the peak of the run. The samples are
+// taken outside of the timed sections.
+
+// MemoryPhase is the memory of one phase of a run, in bytes.
+type MemoryPhase struct {
+	// HeapDelta is the change of the live heap across the phase.
+	HeapDelta int64 `json:"heapDelta"`
+	// Sys is the memory obtained from the OS at the end of the phase.
+	Sys uint64 `json:"sys"`
+}
+
+func (p MemoryPhase) String() string {
+	return fmt.Sprintf("heap %+d, sys %v", p.HeapDelta, p.Sys)
+}
+
+// MemoryStats is the memory of the setup, of the proofs of the clients and of
+// the aggregation of the server, in bytes.
+type MemoryStats struct {
+	Setup     MemoryPhase `json:"setup"`
+	Prove     MemoryPhase `json:"prove"`
+	Aggregate MemoryPhase `json:"aggregate"`
+	// PeakSys is the largest Sys of the phases.
+	PeakSys uint64 `json:"peakSys"`
+}
+
+// sampleMemory collects the garbage and reads the memory statistics.
+func sampleMemory() runtime.MemStats {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m
+}
+
+// newMemoryPhase returns the phase between the samples before and after.
+func newMemoryPhase(before *runtime.MemStats, after *runtime.MemStats) MemoryPhase {
+	return MemoryPhase{
+		HeapDelta: int64(after.HeapAlloc) - int64(before.HeapAlloc),
+		Sys:       after.Sys,
+	}
+}
+
+// RunMetrics are the metrics of one run written to MetricsFile, with the
+// durations in nanoseconds and the memory in bytes.
+type RunMetrics struct {
+	Backend       string        `json:"backend"`
+	Curve         string        `json:"curve"`
+	Clients       int           `json:"clients"`
+	Seed          uint64        `json:"seed"`
+	VerifyTotal   time.Duration `json:"verifyTotal"`
+	VerifyLatency LatencyStats  `json:"verifyLatency"`
+	Memory        MemoryStats   `json:"memory"`
+}
+
+// appendMetrics appends m to the JSON lines file name.
+func appendMetrics(name string, m RunMetrics) error {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
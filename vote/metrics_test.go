@@ -0,0 +1,120 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeVerifier takes the scripted latencies on a fake clock.
+type fakeVerifier struct {
+	now       time.Time
+	latencies []time.Duration
+	fail      int
+}
+
+func (v *fakeVerifier) clock() time.Time { return v.now }
+
+func (v *fakeVerifier) verify(i int) error {
+	v.now = v.now.Add(v.latencies[i])
+	if i == v.fail {
+		return errors.New("invalid proof")
+	}
+	return nil
+}
+
+func TestLatencyStats(t *testing.T) {
+	ms := time.Millisecond
+	// a slow first call, then 19 calls of 1..19ms in a shuffled order
+	latencies := []time.Duration{50 * ms}
+	for _, l := range []int{7, 3, 19, 1, 12, 5, 16, 9, 2, 14, 18, 4, 11, 6, 17, 8, 13, 10, 15} {
+		latencies = append(latencies, time.Duration(l)*ms)
+	}
+	v := &fakeVerifier{latencies: latencies, fail: -1}
+	samples, err := measureLatencies(len(latencies), v.clock, v.verify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(samples, latencies) {
+		t.Fatalf("samples %v, expected %v", samples, latencies)
+	}
+
+	got := NewLatencyStats(samples)
+	expected := LatencyStats{Count: 20, First: 50 * ms, Min: 1 * ms, P50: 10 * ms, P95: 19 * ms, Max: 50 * ms}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("stats %+v, expected %+v", got, expected)
+	}
+
+	if s := NewLatencyStats([]time.Duration{3 * ms}); s.First != 3*ms || s.Min != 3*ms || s.P50 != 3*ms || s.P95 != 3*ms || s.Max != 3*ms {
+		t.Fatalf("stats of one sample %+v", s)
+	}
+	if s := NewLatencyStats(nil); !reflect.DeepEqual(s, LatencyStats{}) {
+		t.Fatalf("stats of no sample %+v", s)
+	}
+}
+
+func TestMeasureLatenciesStopsAtFailure(t *testing.T) {
+	v := &fakeVerifier{latencies: []time.Duration{1, 2, 3}, fail: 1}
+	if _, err := measureLatencies(3, v.clock, v.verify); err == nil {
+		t.Fatal("a failed verification was measured")
+	}
+	if _, err := MeasureVerifyLatency(nil, nil); !errors.Is(err, errNoLatency) {
+		t.Fatalf("expected errNoLatency, got %v", err)
+	}
+}
+
+func TestAppendMetrics(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "metrics.json")
+	stats := NewLatencyStats([]time.Duration{3, 1, 2})
+	stats.Samples = []time.Duration{3, 1, 2}
+	m := RunMetrics{Backend: "Groth16", Curve: "bn254", Clients: 3, VerifyTotal: 6, VerifyLatency: stats}
+	for i := 0; i < 2; i++ {
+		if err := appendMetrics(name, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for i := 0; i < 2; i++ {
+		var got RunMetrics
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Fatalf("line %v: %+v, expected %+v", i, got, m)
+		}
+	}
+}
+
+func TestMemoryPhase(t *testing.T) {
+	before := sampleMemory()
+	live := make([]byte, 32<<20)
+	for i := range live {
+		live[i] = byte(i)
+	}
+	after := sampleMemory()
+	p := newMemoryPhase(&before, &after)
+	runtime.KeepAlive(live)
+	// up to the garbage of the test so far, collected in the phase
+	if p.HeapDelta < int64(len(live))/2 || p.Sys != after.Sys || p.Sys < uint64(len(live)) {
+		t.Fatalf("%v after allocating %v live bytes", p, len(live))
+	}
+
+	// the garbage of a phase does not count
+	live = nil
+	released := sampleMemory()
+	if p := newMemoryPhase(&after, &released); p.HeapDelta > -(32<<20)/2 {
+		t.Fatalf("%v after dropping 32MiB", p)
+	}
+}
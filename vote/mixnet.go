@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// A deployment may shuffle the ballots with an external mixnet instead of the
+// shufflers of this program. The server then imports the shuffled multiset
+// the mixnet releases, a batch file, and runs the product check and the tally
+// on it. All integers are big-endian:
+//
+//	[8]byte  mixnetMagic
+//	uint32   attestation length, followed by the attestation, in JSON
+//	vector   the first elements of the pairs
+//	vector   the second elements of the pairs
+//	vector   the dummies
+//
+// A vector is the encoding of fr.Vector: a uint32 count followed by the
+// elements, 32 bytes each, canonical (below the modulus). Nothing may follow
+// the dummies.
+//
+// The attestation names the source and the batch, and binds them to the
+// release with the SHA-256 of the three encoded vectors. Unlike our shuffler,
+// the mixnet does not prove its release: only the product check catches a
+// dishonest one.
+//
+// The benchmark draws its clients anew every run, so a batch can only match
+// the clients of a seeded run, see Seed.
+
+// mixnetMagic starts a batch file, with the version of the format.
+const mixnetMagic = "SZKMIX01"
+
+var (
+	// MixnetFile is the batch file of an external mixnet the server imports
+	// instead of shuffling the ballots itself, empty to use our shufflers.
+	MixnetFile = ""
+	// MixnetSource is the source the attestation of MixnetFile must name,
+	// empty to accept any.
+	MixnetSource = ""
+)
+
+var (
+	ErrMixnetFormat = errors.New("mixnet: malformed batch")
+	ErrMixnetCount  = errors.New("mixnet: the batch does not hold the ballots of the commitments")
+)
+
+// MixnetAttestation is the attestation of a batch.
+type MixnetAttestation struct {
+	Source string `json:"source"`
+	Batch  string `json:"batch"`
+	// Digest is the SHA-256 of the encoded vectors, in hex.
+	Digest string `json:"digest"`
+}
+
+// MixnetBatch is the shuffled multiset an external mixnet releases.
+type MixnetBatch struct {
+	Attestation MixnetAttestation
+	PairFirst   []fr_bn254.Element
+	PairSecond  []fr_bn254.Element
+	Dummies     []fr_bn254.Element
+}
+
+// encodeVectors returns the encoding of the vectors of b.
+func (b *MixnetBatch) encodeVectors() []byte {
+	var buf bytes.Buffer
+	for _, v := range [][]fr_bn254.Element{b.PairFirst, b.PairSecond, b.Dummies} {
+		vector := fr_bn254.Vector(v)
+		vector.WriteTo(&buf)
+	}
+	return buf.Bytes()
+}
+
+// WriteMixnetBatch writes the batch of source with the given shuffled pairs
+// and dummies to w, with its attestation. It is the export side of the format,
+// for a mixnet or a test fixture.
+func WriteMixnetBatch(w io.Writer, source, batch string, pairFirst, pairSecond, dummies []fr_bn254.Element) error {
+	b := MixnetBatch{PairFirst: pairFirst, PairSecond: pairSecond, Dummies: dummies}
+	vectors := b.encodeVectors()
+	digest := sha256.Sum256(vectors)
+	attestation, err := json.Marshal(MixnetAttestation{Source: source, Batch: batch, Digest: hex.EncodeToString(digest[:])})
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(mixnetMagic)
+	binary.Write(&buf, binary.BigEndian, uint32(len(attestation)))
+	buf.Write(attestation)
+	buf.Write(vectors)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// maxMixnetVector bounds the count of a vector, so that a corrupted count
+// does not allocate before the elements are read.
+const maxMixnetVector = 1 << 26
+
+// readMixnetVector reads a vector, rejecting the non-canonical elements.
+func readMixnetVector(r io.Reader, name string) ([]fr_bn254.Element, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, fmt.Errorf("%w: %v count: %v", ErrMixnetFormat, name, err)
+	}
+	if n > maxMixnetVector {
+		return nil, fmt.Errorf("%w: %v count %v", ErrMixnetFormat, name, n)
+	}
+	vec := make([]fr_bn254.Element, 0, n)
+	var b [fr_bn254.Bytes]byte
+	for i := 0; i < int(n); i++ {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, fmt.Errorf("%w: %v element %v: %v", ErrMixnetFormat, name, i, err)
+		}
+		var e fr_bn254.Element
+		if err := e.SetBytesCanonical(b[:]); err != nil {
+			return nil, fmt.Errorf("%w: %v element %v is not canonical", ErrMixnetFormat, name, i)
+		}
+		vec = append(vec, e)
+	}
+	return vec, nil
+}
+
+// ReadMixnetBatch reads a batch and checks the digest of its attestation.
+func ReadMixnetBatch(r io.Reader) (MixnetBatch, error) {
+	var b MixnetBatch
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(mixnetMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != mixnetMagic {
+		return b, fmt.Errorf("%w: not a %v batch", ErrMixnetFormat, mixnetMagic)
+	}
+	var n uint32
+	if err := binary.Read(br, binary.BigEndian, &n); err != nil || n > maxWireFrameSize {
+		return b, fmt.Errorf("%w: attestation length", ErrMixnetFormat)
+	}
+	attestation := make([]byte, n)
+	if _, err := io.ReadFull(br, attestation); err != nil {
+		return b, fmt.Errorf("%w: attestation: %v", ErrMixnetFormat, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(attestation))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&b.Attestation); err != nil {
+		return b, fmt.Errorf("%w: attestation: %v", ErrMixnetFormat, err)
+	}
+	if b.Attestation.Source == "" {
+		return b, fmt.Errorf("%w: the attestation names no source", ErrMixnetFormat)
+	}
+
+	var err error
+	if b.PairFirst, err = readMixnetVector(br, "first"); err != nil {
+		return b, err
+	}
+	if b.PairSecond, err = readMixnetVector(br, "second"); err != nil {
+		return b, err
+	}
+	if b.Dummies, err = readMixnetVector(br, "dummies"); err != nil {
+		return b, err
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return b, fmt.Errorf("%w: trailing data after the dummies", ErrMixnetFormat)
+	}
+
+	digest := sha256.Sum256(b.encodeVectors())
+	if b.Attestation.Digest != hex.EncodeToString(digest[:]) {
+		return b, fmt.Errorf("%w: the digest of the attestation does not match the vectors", ErrMixnetFormat)
+	}
+	return b, nil
+}
+
+// Check checks that b holds the ballots of commitments clients voting on
+// candidateNum candidates with dummyNum dummies each: as many pairs as the
+// ballots hold, and as many dummies as the clients sent.
+func (b *MixnetBatch) Check(commitments int, candidateNum int, dummyNum uint64) error {
+	pairs := commitments * candidateNum * (candidateNum - 1) / 2
+	if len(b.PairFirst) != pairs || len(b.PairSecond) != pairs {
+		return fmt.Errorf("%w: %v and %v pair elements for %v commitments, expected %v", ErrMixnetCount, len(b.PairFirst), len(b.PairSecond), commitments, pairs)
+	}
+	if uint64(len(b.Dummies)) != uint64(commitments)*dummyNum {
+		return fmt.Errorf("%w: %v dummies for %v commitments, expected %v", ErrMixnetCount, len(b.Dummies), commitments, uint64(commitments)*dummyNum)
+	}
+	return nil
+}
+
+// ImportMixnet reads the batch file name and checks it against the
+// commitments, see MixnetBatch.Check, and against MixnetSource.
+func ImportMixnet(name string, commitments int, candidateNum int, dummyNum uint64) (MixnetBatch, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return MixnetBatch{}, err
+	}
+	defer f.Close()
+	b, err := ReadMixnetBatch(f)
+	if err != nil {
+		return MixnetBatch{}, err
+	}
+	if MixnetSource != "" && b.Attestation.Source != MixnetSource {
+		return MixnetBatch{}, fmt.Errorf("%w: source %q, expected %q", ErrMixnetFormat, b.Attestation.Source, MixnetSource)
+	}
+	if err := b.Check(commitments, candidateNum, dummyNum); err != nil {
+		return MixnetBatch{}, err
+	}
+	return b, nil
+}
@@ -0,0 +1,140 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/benchreport"
+	"example/verification/internal/hasher"
+)
+
+// mixnetFixture plays an external mixnet: it shuffles the pairs and the
+// dummies of clients on its own and returns its batch file, after tamper
+// changed the release if not nil.
+func mixnetFixture(t *testing.T, clients []ClientState, tamper func(first, second, dummies *[]fr_bn254.Element)) []byte {
+	t.Helper()
+	rng := mathrand.New(mathrand.NewSource(1))
+	var first, second, dummies []fr_bn254.Element
+	for i := range clients {
+		first = append(first, clients[i].PairFirst...)
+		second = append(second, clients[i].PairSecond...)
+		dummies = append(dummies, clients[i].PrivateY...)
+	}
+	rng.Shuffle(len(first), func(i, j int) {
+		first[i], first[j] = first[j], first[i]
+		second[i], second[j] = second[j], second[i]
+	})
+	rng.Shuffle(len(dummies), func(i, j int) { dummies[i], dummies[j] = dummies[j], dummies[i] })
+	if tamper != nil {
+		tamper(&first, &second, &dummies)
+	}
+	var buf bytes.Buffer
+	if err := WriteMixnetBatch(&buf, "fixture-mixnet", "batch-1", first, second, dummies); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newMixnetClients(n int, dummyNum uint64) []ClientState {
+	rnd := benchrand.New(3)
+	clients := make([]ClientState, n)
+	for i := range clients {
+		clients[i].InitRand(rnd, bn254Field{}, dummyNum)
+	}
+	return clients
+}
+
+func TestReadMixnetBatch(t *testing.T) {
+	clients := newMixnetClients(4, 2)
+	data := mixnetFixture(t, clients, nil)
+	b, err := ReadMixnetBatch(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Attestation.Source != "fixture-mixnet" || b.Attestation.Batch != "batch-1" {
+		t.Fatalf("attestation %+v", b.Attestation)
+	}
+	if err := b.Check(len(clients), CandidateNum, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Check(len(clients)+1, CandidateNum, 2); !errors.Is(err, ErrMixnetCount) {
+		t.Fatalf("a missing ballot: got %v, expected ErrMixnetCount", err)
+	}
+	if err := b.Check(len(clients), CandidateNum, 3); !errors.Is(err, ErrMixnetCount) {
+		t.Fatalf("missing dummies: got %v, expected ErrMixnetCount", err)
+	}
+
+	// the first element of the first vector starts after the magic, the
+	// attestation and the count
+	attestationLen := int(data[8])<<24 | int(data[9])<<16 | int(data[10])<<8 | int(data[11])
+	firstElement := 8 + 4 + attestationLen + 4
+
+	modulus := fr_bn254.Modulus().FillBytes(make([]byte, fr_bn254.Bytes))
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"magic":         func(d []byte) []byte { d[0] ^= 1; return d },
+		"non-canonical": func(d []byte) []byte { copy(d[firstElement:], modulus); return d },
+		"digest":        func(d []byte) []byte { d[len(d)-1] ^= 1; return d },
+		"truncated":     func(d []byte) []byte { return d[:len(d)-1] },
+		"trailing":      func(d []byte) []byte { return append(d, 0) },
+	} {
+		corrupted := corrupt(append([]byte(nil), data...))
+		if _, err := ReadMixnetBatch(bytes.NewReader(corrupted)); !errors.Is(err, ErrMixnetFormat) {
+			t.Fatalf("%v: got %v, expected ErrMixnetFormat", name, err)
+		}
+	}
+}
+
+func TestVoteMixnet(t *testing.T) {
+	defer func(n, c, k int, l, seed uint64, keyDir, mixnet string, r []*benchreport.Reporter) {
+		ClientNum, CandidateNum, CorruptedNum, Lambda, Seed, KeyDir, MixnetFile, reporters = n, c, k, l, seed, keyDir, mixnet, r
+	}(ClientNum, CandidateNum, CorruptedNum, Lambda, Seed, KeyDir, MixnetFile, reporters)
+	defer func(p ProtocolParams) { Params = p }(Params)
+	ClientNum, CandidateNum, CorruptedNum, Lambda, Seed, KeyDir = 12, 3, 2, 40, 3, ""
+	Params = ProtocolParams{Hash: hasher.Stub}
+	reporters = []*benchreport.Reporter{benchreport.NewReporter(io.Discard, benchreport.FormatJSON)}
+
+	// a seeded run draws the same clients as the fixture
+	dummyNum, err := ComputeDummyNum(Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := newMixnetClients(ClientNum, dummyNum)
+	MixnetFile = filepath.Join(t.TempDir(), "batch")
+
+	run := func(tamper func(first, second, dummies *[]fr_bn254.Element)) error {
+		if err := os.WriteFile(MixnetFile, mixnetFixture(t, clients, tamper), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Vote(NewGroth16System(ecc.BN254), 0)
+		return err
+	}
+	if err := run(nil); err != nil {
+		t.Fatal(err)
+	}
+	// the mixnet swapped the second element of a pair for another candidate
+	err = run(func(first, second, dummies *[]fr_bn254.Element) {
+		(*second)[0].SetUint64((*second)[0].Uint64()%uint64(CandidateNum) + 1)
+	})
+	if !errors.Is(err, ErrInconsistent) {
+		t.Fatalf("a tampered ballot: got %v, expected ErrInconsistent", err)
+	}
+	// the mixnet dropped a ballot
+	err = run(func(first, second, dummies *[]fr_bn254.Element) {
+		pairs := CandidateNum * (CandidateNum - 1) / 2
+		*first, *second = (*first)[pairs:], (*second)[pairs:]
+	})
+	if !errors.Is(err, ErrMixnetCount) {
+		t.Fatalf("a dropped ballot: got %v, expected ErrMixnetCount", err)
+	}
+}
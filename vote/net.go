@@ -0,0 +1,464 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+
+	"example/verification/internal/frwire"
+)
+
+// The network demo (server.go, client.go) runs the vote protocol between a
+// server, a shuffler and client processes over TCP. Every message is one
+// frame, integers big-endian:
+//
+//	uint32  length of the rest of the frame
+//	byte    message type
+//	...     payload
+//
+// Field elements take 32 bytes and byte strings are prefixed with their uint32
+// length. Vectors of field elements are in the format of internal/frwire,
+// whose count is little-endian. The payload of msgSubmission is a submission in the
+// wire format of wire.go.
+//
+// The server announces in msgHello the codecs it accepts (see compress.go).
+// A client may then send its compressible messages with the msgCompressed bit
+// set on the type; such a payload starts with the codec byte.
+//
+// An election runs in rounds, each bounded by the round deadline of the server:
+//
+//	join     client connects to the server    server → client msgHello
+//	identify client → server msgIdentify
+//	commit   client → shuffler msgShares      client → server msgCommit
+//	open     server → client msgOpenRequest   client → server msgOpening
+//	reveal   server → client msgRevealRequest client → server msgReveal
+//	prove    server → client msgChallenge     client → server msgSubmission
+//	result   server → client msgResult
+//
+// Only a server with a registry of the clients runs the identify round (see
+// provision.go), and only the clients flagged for a duplicate commitment take
+// part in the open round (see commitments.go). A client that misses a round is dropped from the
+// election. The shuffler only releases the data of the clients left after the
+// last round, so the dropped clients do not break the consistency check, and
+// the server checks whether the dummies still meet the security target for
+// the clients left (see dropout.go).
+
+const (
+	msgHello byte = iota + 1
+	msgShares
+	msgCommit
+	msgRevealRequest
+	msgReveal
+	msgChallenge
+	msgSubmission
+	msgResult
+	msgOpenRequest
+	msgOpening
+	msgIdentify
+)
+
+// msgCompressed is set on the type of a compressed message.
+const msgCompressed byte = 0x80
+
+// compressible reports whether messages of type typ are worth compressing.
+// The batches of field elements are not.
+func compressible(typ byte) bool {
+	switch typ {
+	case msgSubmission, msgOpening, msgResult:
+		return true
+	}
+	return false
+}
+
+// maxMessageSize bounds the frames accepted by readMessage. The largest
+// message is msgShares, with the dummies of one client.
+const maxMessageSize = 1 << 24
+
+var ErrProtocol = errors.New("net: malformed message")
+
+// ErrAborted is returned to a client when the server ends the election early.
+var ErrAborted = errors.New("net: the server aborted the election")
+
+func writeMessage(w io.Writer, typ byte, payload []byte) error {
+	frame := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(1+len(payload)))
+	frame[4] = typ
+	_, err := w.Write(append(frame, payload...))
+	return err
+}
+
+// writeCompressed is writeMessage compressing the payload of a compressible
+// message with codec, if that makes it smaller. It returns the size of the
+// frame on the wire.
+func writeCompressed(w io.Writer, typ byte, payload []byte, codec Codec) (int, error) {
+	if !compressible(typ) {
+		codec = CodecNone
+	}
+	used, b := compressPayload(codec, payload)
+	if used == CodecNone {
+		return 5 + len(payload), writeMessage(w, typ, payload)
+	}
+	return 6 + len(b), writeMessage(w, typ|msgCompressed, append([]byte{byte(used)}, b...))
+}
+
+// readMessage reads one frame and checks that it has type want. A msgResult
+// received in place of another message is the server aborting the election.
+func readMessage(r io.Reader, want byte) (*msgParser, error) {
+	_, p, err := readOneOf(r, want)
+	return p, err
+}
+
+// readOneOf is readMessage accepting any of the types in want.
+func readOneOf(r io.Reader, want ...byte) (byte, *msgParser, error) {
+	var frame [4]byte
+	if _, err := io.ReadFull(r, frame[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(frame[:])
+	if size < 1 || size > maxMessageSize {
+		return 0, nil, fmt.Errorf("%w: frame of %v bytes", ErrProtocol, size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, fmt.Errorf("%w: truncated frame", ErrProtocol)
+		}
+		return 0, nil, err
+	}
+	typ, payload := body[0], body[1:]
+	if typ&msgCompressed != 0 {
+		typ &^= msgCompressed
+		if len(payload) < 1 || !compressible(typ) {
+			return 0, nil, fmt.Errorf("%w: unexpected compressed message", ErrProtocol)
+		}
+		var err error
+		if payload, err = decompressPayload(Codec(payload[0]), payload[1:], maxMessageSize); err != nil {
+			return 0, nil, fmt.Errorf("%w: %v", ErrProtocol, err)
+		}
+	}
+	p := &msgParser{b: payload}
+	for _, w := range want {
+		if typ == w {
+			return typ, p, nil
+		}
+	}
+	if typ == msgResult {
+		var res resultMsg
+		if err := res.decode(p); err == nil {
+			return 0, nil, fmt.Errorf("%w: %v", ErrAborted, res.Message)
+		}
+	}
+	return 0, nil, fmt.Errorf("%w: got message type %v, expected %v", ErrProtocol, typ, want)
+}
+
+type msgBuilder struct {
+	bytes.Buffer
+}
+
+func (b *msgBuilder) putUint32(v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (b *msgBuilder) putElement(x *fr_bn254.Element) {
+	buf := x.Bytes()
+	b.Write(buf[:])
+}
+
+func (b *msgBuilder) putBigInt(x *big.Int) {
+	var buf [fr_bn254.Bytes]byte
+	x.FillBytes(buf[:])
+	b.Write(buf[:])
+}
+
+func (b *msgBuilder) putElements(v []fr_bn254.Element) {
+	// the vectors of a message are far from 2^32 elements
+	enc, _ := frwire.SerializeFieldElements(v)
+	b.Write(enc)
+}
+
+func (b *msgBuilder) putBytes(v []byte) {
+	b.putUint32(uint32(len(v)))
+	b.Write(v)
+}
+
+// msgParser decodes a payload. The first error sticks: the getters return
+// zero values afterwards and finish reports it.
+type msgParser struct {
+	b   []byte
+	err error
+}
+
+func (p *msgParser) take(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	if n > len(p.b) {
+		p.err = fmt.Errorf("%w: truncated payload", ErrProtocol)
+		return nil
+	}
+	res := p.b[:n]
+	p.b = p.b[n:]
+	return res
+}
+
+func (p *msgParser) byte() byte {
+	if b := p.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (p *msgParser) uint32() uint32 {
+	if b := p.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (p *msgParser) element() fr_bn254.Element {
+	var x fr_bn254.Element
+	if b := p.take(fr_bn254.Bytes); b != nil {
+		if err := x.SetBytesCanonical(b); err != nil {
+			p.err = fmt.Errorf("%w: %v", ErrProtocol, err)
+		}
+	}
+	return x
+}
+
+// bigInt reads an element of the field of curve.
+func (p *msgParser) bigInt(curve ecc.ID) *big.Int {
+	x := new(big.Int)
+	if b := p.take(fr_bn254.Bytes); b != nil {
+		x.SetBytes(b)
+		if x.Cmp(curve.ScalarField()) >= 0 {
+			p.err = fmt.Errorf("%w: the element is not reduced", ErrProtocol)
+		}
+	}
+	return x
+}
+
+func (p *msgParser) elements() []fr_bn254.Element {
+	if p.err != nil {
+		return nil
+	}
+	v, rest, err := frwire.ReadFieldElements(p.b)
+	if err != nil {
+		p.err = fmt.Errorf("%w: %v", ErrProtocol, err)
+		return nil
+	}
+	p.b = rest
+	return v
+}
+
+func (p *msgParser) bytes() []byte {
+	n := p.uint32()
+	return p.take(int(n))
+}
+
+func (p *msgParser) seed() [32]byte {
+	var s [32]byte
+	copy(s[:], p.take(len(s)))
+	return s
+}
+
+// finish returns the first decoding error, or an error if bytes are left.
+func (p *msgParser) finish() error {
+	if p.err == nil && len(p.b) != 0 {
+		p.err = fmt.Errorf("%w: %v trailing bytes", ErrProtocol, len(p.b))
+	}
+	return p.err
+}
+
+// helloMsg tells a client how the election is run.
+type helloMsg struct {
+	ClientID uint32
+	// Token authenticates the client to the shuffler.
+	Token          [32]byte
+	Backend        backend.ID
+	Curve          ecc.ID
+	CandidateNum   uint32
+	DummyNum       uint32
+	Checksum       string
+	SeedCommitment [32]byte
+	VerifyingKey   VerifyingKeyBytes
+	ShufflerAddr   string
+	// Codecs are the codecs the server accepts.
+	Codecs codecMask
+	// Identify asks the client for msgIdentify, see provision.go.
+	Identify bool
+}
+
+func (m *helloMsg) encode() []byte {
+	var b msgBuilder
+	b.putUint32(m.ClientID)
+	b.Write(m.Token[:])
+	b.WriteByte(byte(m.Backend))
+	b.WriteByte(byte(m.Curve))
+	b.putUint32(m.CandidateNum)
+	b.putUint32(m.DummyNum)
+	b.putBytes([]byte(m.Checksum))
+	b.Write(m.SeedCommitment[:])
+	b.putBytes(m.VerifyingKey)
+	b.putBytes([]byte(m.ShufflerAddr))
+	b.WriteByte(byte(m.Codecs))
+	if m.Identify {
+		b.WriteByte(1)
+	} else {
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+func (m *helloMsg) decode(p *msgParser) error {
+	m.ClientID = p.uint32()
+	m.Token = p.seed()
+	m.Backend = backend.ID(p.byte())
+	m.Curve = ecc.ID(p.byte())
+	m.CandidateNum = p.uint32()
+	m.DummyNum = p.uint32()
+	m.Checksum = string(p.bytes())
+	m.SeedCommitment = p.seed()
+	m.VerifyingKey = append(VerifyingKeyBytes(nil), p.bytes()...)
+	m.ShufflerAddr = string(p.bytes())
+	m.Codecs = codecMask(p.byte())
+	m.Identify = p.byte() == 1
+	return p.finish()
+}
+
+// identifyMsg identifies a client as a registered one: Signature is its
+// signature of identifyMessage.
+type identifyMsg struct {
+	ClientID  uint32
+	Signature []byte
+}
+
+func (m *identifyMsg) encode() []byte {
+	var b msgBuilder
+	b.putUint32(m.ClientID)
+	b.putBytes(m.Signature)
+	return b.Bytes()
+}
+
+func (m *identifyMsg) decode(p *msgParser) error {
+	m.ClientID = p.uint32()
+	m.Signature = append([]byte(nil), p.bytes()...)
+	return p.finish()
+}
+
+// sharesMsg carries the pairs and the dummies of a client to the shuffler.
+type sharesMsg struct {
+	ClientID   uint32
+	Token      [32]byte
+	PairFirst  []fr_bn254.Element
+	PairSecond []fr_bn254.Element
+	Dummies    []fr_bn254.Element
+}
+
+func (m *sharesMsg) encode() []byte {
+	var b msgBuilder
+	b.putUint32(m.ClientID)
+	b.Write(m.Token[:])
+	b.putElements(m.PairFirst)
+	b.putElements(m.PairSecond)
+	b.putElements(m.Dummies)
+	return b.Bytes()
+}
+
+func (m *sharesMsg) decode(p *msgParser) error {
+	m.ClientID = p.uint32()
+	m.Token = p.seed()
+	m.PairFirst = p.elements()
+	m.PairSecond = p.elements()
+	m.Dummies = p.elements()
+	return p.finish()
+}
+
+// commitMsg carries the commitments of a client to the server.
+type commitMsg struct {
+	PublicCom *big.Int
+	NonceCom  fr_bn254.Element
+}
+
+func (m *commitMsg) encode() []byte {
+	var b msgBuilder
+	b.putBigInt(m.PublicCom)
+	b.putElement(&m.NonceCom)
+	return b.Bytes()
+}
+
+func (m *commitMsg) decode(p *msgParser, curve ecc.ID) error {
+	m.PublicCom = p.bigInt(curve)
+	m.NonceCom = p.element()
+	return p.finish()
+}
+
+func encodeOpening(o Opening) []byte {
+	var b msgBuilder
+	b.putElements(o.PrivateX)
+	b.putElements(o.PrivateY)
+	b.putBigInt(o.PrivateMask)
+	b.putElement(&o.PrivateSalt)
+	return b.Bytes()
+}
+
+func decodeOpening(p *msgParser, curve ecc.ID) (Opening, error) {
+	var o Opening
+	o.PrivateX = p.elements()
+	o.PrivateY = p.elements()
+	o.PrivateMask = p.bigInt(curve)
+	o.PrivateSalt = p.element()
+	return o, p.finish()
+}
+
+// challengeMsg reveals the server seed and the challenge derived from it.
+type challengeMsg struct {
+	Seed    [32]byte
+	PublicR fr_bn254.Element
+}
+
+func (m *challengeMsg) encode() []byte {
+	var b msgBuilder
+	b.Write(m.Seed[:])
+	b.putElement(&m.PublicR)
+	return b.Bytes()
+}
+
+func (m *challengeMsg) decode(p *msgParser) error {
+	m.Seed = p.seed()
+	m.PublicR = p.element()
+	return p.finish()
+}
+
+// resultMsg ends the election for a client.
+type resultMsg struct {
+	OK      bool
+	Message string
+}
+
+func (m *resultMsg) encode() []byte {
+	var b msgBuilder
+	if m.OK {
+		b.WriteByte(1)
+	} else {
+		b.WriteByte(0)
+	}
+	b.putBytes([]byte(m.Message))
+	return b.Bytes()
+}
+
+func (m *resultMsg) decode(p *msgParser) error {
+	m.OK = p.byte() == 1
+	m.Message = string(p.bytes())
+	return p.finish()
+}
@@ -0,0 +1,202 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/frwire"
+	"example/verification/internal/hasher"
+	"example/verification/internal/pool"
+)
+
+// runNetworkElection runs a server for clientNum clients and honest clients
+// on the loopback interface, plus the connections opened by extra, which
+// stand for misbehaving clients. The server publishes the epoch to epochDir
+// if it is not empty.
+func runNetworkElection(t *testing.T, clientNum int, honest int, timeout time.Duration, epochDir string, extra func(addr string)) (*ElectionResult, []error) {
+	t.Helper()
+	return runProvisionedElection(t, clientNum, make([]*Bundle, honest), nil, timeout, epochDir, extra)
+}
+
+// runProvisionedElection is runNetworkElection with a server restricted to
+// registry, or open to all when it is nil, and a client for every bundle,
+// which joins unprovisioned when its bundle is nil.
+func runProvisionedElection(t *testing.T, clientNum int, bundles []*Bundle, registry *Registry, timeout time.Duration, epochDir string, extra func(addr string)) (*ElectionResult, []error) {
+	t.Helper()
+	defer func(keyDir string, candidateNum, corruptedNum int, params ProtocolParams) {
+		KeyDir, CandidateNum, CorruptedNum, Params = keyDir, candidateNum, corruptedNum, params
+	}(KeyDir, CandidateNum, CorruptedNum, Params)
+	KeyDir = t.TempDir()
+	CandidateNum = 3
+	CorruptedNum = 0
+	// a round of a few clients needs thousands of dummies, which the circuit
+	// hashes into the commitment: the stub keeps the setup short
+	Params = ProtocolParams{Hash: hasher.Stub}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	shufflerLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := Server{System: NewGroth16System(ecc.BN254), ClientNum: clientNum, RoundTimeout: timeout, EpochDir: epochDir, Registry: registry}
+	type outcome struct {
+		res *ElectionResult
+		err error
+	}
+	done := make(chan outcome)
+	go func() {
+		res, err := server.Run(ln, shufflerLn)
+		done <- outcome{res, err}
+	}()
+
+	errs := make([]error, len(bundles))
+	clientsDone := make(chan struct{})
+	go func() {
+		pool.RunAll(len(bundles), len(bundles), func(i int) error {
+			_, errs[i] = RunRegisteredClient(ln.Addr().String(), "", timeout, bundles[i])
+			return nil
+		})
+		close(clientsDone)
+	}()
+	if extra != nil {
+		extra(ln.Addr().String())
+	}
+	o := <-done
+	<-clientsDone
+	if o.err != nil {
+		t.Fatalf("server: %v", o.err)
+	}
+	return o.res, errs
+}
+
+func TestNetworkElection(t *testing.T) {
+	res, errs := runNetworkElection(t, 3, 3, time.Minute, "", nil)
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("client %v: %v", i, err)
+		}
+	}
+	if len(res.Counted) != 3 || !res.Tally.Consistent(3) {
+		t.Fatalf("counted %v clients, tally %v", len(res.Counted), res.Tally)
+	}
+	if res.WeakPrivacy || res.BelowTarget || len(res.Dropped) != 0 {
+		t.Fatalf("3 honest clients: weak privacy %v, below target %v, dropped %v", res.WeakPrivacy, res.BelowTarget, res.Dropped)
+	}
+}
+
+func TestNetworkSingleClient(t *testing.T) {
+	// the election runs end to end, but the tally is the ballot of the client
+	res, errs := runNetworkElection(t, 3, 1, 5*time.Second, "", nil)
+	if errs[0] != nil {
+		t.Fatalf("client: %v", errs[0])
+	}
+	if len(res.Counted) != 1 || !res.Tally.Consistent(1) {
+		t.Fatalf("counted %v clients, tally %v", len(res.Counted), res.Tally)
+	}
+	if !res.WeakPrivacy {
+		t.Fatal("weak privacy not flagged for a single client")
+	}
+}
+
+func TestNetworkEmptyEpoch(t *testing.T) {
+	res, _ := runNetworkElection(t, 3, 0, time.Second, "", nil)
+	if !res.Empty || len(res.Counted) != 0 || res.Winner != -1 {
+		t.Fatalf("no client joined: got %+v, want an empty epoch", res)
+	}
+}
+
+func TestNetworkStraggler(t *testing.T) {
+	// a client that joins but never commits is dropped when the commit round
+	// times out
+	res, errs := runNetworkElection(t, 3, 2, 5*time.Second, "", func(addr string) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		if _, err := readMessage(conn, msgHello); err != nil {
+			t.Error(err)
+		}
+		// wait until the server hangs up
+		io.Copy(io.Discard, conn)
+	})
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("client %v: %v", i, err)
+		}
+	}
+	if len(res.Counted) != 2 || !res.Tally.Consistent(2) {
+		t.Fatalf("counted %v clients, tally %v", len(res.Counted), res.Tally)
+	}
+	// 2 clients left are not enough for the dummies of 3
+	if len(res.Dropped) != 1 || !res.BelowTarget {
+		t.Fatalf("dropped %v, below target %v, expected one client and below target", res.Dropped, res.BelowTarget)
+	}
+}
+
+func TestMessageMalformed(t *testing.T) {
+	commit := commitMsg{PublicCom: ecc.BN254.ScalarField(), NonceCom: randomFr()}
+	var buf bytes.Buffer
+	if err := writeMessage(&buf, msgCommit, commit.encode()); err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+
+	for l := 0; l < len(frame); l++ {
+		if _, err := readMessage(bytes.NewReader(frame[:l]), msgCommit); err == nil {
+			t.Fatalf("no error for a frame truncated to %v bytes", l)
+		}
+	}
+	if _, err := readMessage(bytes.NewReader(frame), msgReveal); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("expected ErrProtocol, got %v", err)
+	}
+	// the commitment is the modulus, which is not reduced
+	p, err := readMessage(bytes.NewReader(frame), msgCommit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := commit.decode(p, ecc.BN254); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("expected ErrProtocol, got %v", err)
+	}
+}
+
+func TestSharesMessage(t *testing.T) {
+	m := sharesMsg{
+		ClientID:   7,
+		Token:      [32]byte{1, 2, 3},
+		PairFirst:  []fr_bn254.Element{fr_bn254.NewElement(0), fr_bn254.NewElement(1)},
+		PairSecond: []fr_bn254.Element{fr_bn254.NewElement(2), fr_bn254.NewElement(2)},
+		Dummies:    []fr_bn254.Element{randomFr(), randomFr(), randomFr()},
+	}
+	payload := m.encode()
+	var got sharesMsg
+	if err := got.decode(&msgParser{b: payload}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Fatalf("got %+v, expected %+v", got, m)
+	}
+
+	// the dummies announced one element more than they hold
+	count := len(payload) - frwire.Size(len(m.Dummies))
+	payload[count]++
+	if err := got.decode(&msgParser{b: payload}); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("a truncated vector: got %v, expected ErrProtocol", err)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"errors"
+
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+// The wasm client only proves with groth16: plonk.go is not built in, and
+// the plonk backend is unsupported.
+
+var errNoPlonk = errors.New("plonk is not built into the wasm client")
+
+func newPlonkSystem(curve ecc.ID) (ProofSystem, error) {
+	return nil, errNoPlonk
+}
+
+func (k *Keys) newPlonk(curve ecc.ID) error {
+	return errNoPlonk
+}
@@ -0,0 +1,16 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+
+	"example/verification/internal/gadget"
+)
+
+// The wasm client does not diagnose the witnesses the solver rejects:
+// solver.go and the test engine of gnark are not built in.
+
+func diagnoseAssignment(curve ecc.ID, assignment *VoteCircuit) *gadget.Failure {
+	return nil
+}
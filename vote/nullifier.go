@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gadget"
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/hasher"
+	"example/verification/internal/pool"
+)
+
+// A fresh salt gives a fresh commitment, so the commitment set alone does not
+// stop a voter from submitting twice. Every voter holds a secret key, and is
+// known by the public commitment to it. In an election, the voter publishes
+// the nullifier MiMC(secret key, election id) and proves with a
+// NullifierCircuit that it derives from the key behind its voter commitment.
+// The nullifier is the same for every submission of the voter in the
+// election, so the server only accepts it once.
+
+// ElectionID separates the nullifiers of different elections.
+var ElectionID = uint64(1)
+
+var ErrReplayedNullifier = errors.New("nullifier: the nullifier was already used in the election")
+
+// NullifierCircuit proves that PublicNullifier is the nullifier, in the
+// election PublicElectionID, of the voter committed to by
+// PublicVoterCommitment.
+type NullifierCircuit struct {
+	PrivateSecretKey      frontend.Variable
+	PublicElectionID      frontend.Variable `gnark:",public"`
+	PublicVoterCommitment frontend.Variable `gnark:",public"`
+	PublicNullifier       frontend.Variable `gnark:",public"`
+
+	// Hash is the hash of the commitment and of the nullifier, see params.go
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+// NullifierPublic are the public variables of NullifierCircuit, in the
+// order of the public witness.
+var NullifierPublic = []string{"PublicElectionID", "PublicVoterCommitment", "PublicNullifier"}
+
+func (circuit *NullifierCircuit) Define(api frontend.API) error {
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	h.Write(circuit.PrivateSecretKey)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicVoterCommitment, h.Sum())
+
+	h.Reset()
+	h.Write(circuit.PrivateSecretKey, circuit.PublicElectionID)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicNullifier, h.Sum())
+	return nil
+}
+
+// VoterCommitment is the public commitment to the secret key of a voter.
+func VoterCommitment(field Field, secretKey fr_bn254.Element) *big.Int {
+	return field.Commit([]*big.Int{secretKey.BigInt(new(big.Int))})
+}
+
+// Nullifier is the nullifier of the voter of secretKey in the election
+// electionID.
+func Nullifier(field Field, secretKey fr_bn254.Element, electionID uint64) *big.Int {
+	return field.Commit([]*big.Int{secretKey.BigInt(new(big.Int)), new(big.Int).SetUint64(electionID)})
+}
+
+// GenNullifierAssignment assigns the nullifier circuit of the client in the
+// election electionID.
+func (c *ClientState) GenNullifierAssignment(electionID uint64) NullifierCircuit {
+	return NullifierCircuit{
+		PrivateSecretKey:      frontendVariable(&c.SecretKey),
+		PublicElectionID:      electionID,
+		PublicVoterCommitment: frontend.Variable(c.VoterCom),
+		PublicNullifier:       frontend.Variable(Nullifier(c.field, c.SecretKey, electionID)),
+	}
+}
+
+// NullifierSubmission is what a client sends to the server next to its vote.
+// proof is nil for the clients that do not attach a proof.
+type NullifierSubmission struct {
+	nullifier *big.Int
+	voterCom  *big.Int
+	proof     ProofBytes
+}
+
+// publicWitness is the public witness of the nullifier proof of s, built by
+// the server so that the proof is bound to the nullifier it records.
+func (s *NullifierSubmission) publicWitness(curve ecc.ID, electionID uint64) (witness.Witness, error) {
+	return gnarkshim.NewPublicWitness(curve, &NullifierCircuit{
+		PublicElectionID:      electionID,
+		PublicVoterCommitment: frontend.Variable(s.voterCom),
+		PublicNullifier:       frontend.Variable(s.nullifier),
+	})
+}
+
+// GenNullifierSubmissions builds the nullifier submissions of all clients in
+// the election electionID. Like the vote proofs, only the first
+// MaxNumOfCheckProof clients attach a proof, generated on Workers goroutines.
+func GenNullifierSubmissions(system ProofSystem, clients []ClientState, ccs constraint.ConstraintSystem, electionID uint64) ([]NullifierSubmission, error) {
+	subs := make([]NullifierSubmission, len(clients))
+	for i := 0; i < len(clients); i++ {
+		subs[i].nullifier = Nullifier(clients[i].field, clients[i].SecretKey, electionID)
+		subs[i].voterCom = clients[i].VoterCom
+	}
+	err := pool.Run(numCheckedProofs(len(clients)), Workers, func(i int) error {
+		assignment := clients[i].GenNullifierAssignment(electionID)
+		witness, err := gnarkshim.NewWitness(system.Curve(), &assignment)
+		if err != nil {
+			return fmt.Errorf("client %v: witness generation: %w", i, err)
+		}
+		if subs[i].proof, err = system.Prove(ccs, witness); err != nil {
+			return fmt.Errorf("client %v: %w", i, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// VerifyNullifierSubmissions verifies the attached nullifier proofs in the
+// election electionID and returns one error per failed proof.
+func VerifyNullifierSubmissions(system ProofSystem, subs []NullifierSubmission, vk VerifyingKeyBytes, electionID uint64) []error {
+	errs := pool.RunAll(len(subs), Workers, func(i int) error {
+		if subs[i].proof == nil {
+			return nil
+		}
+		publicWitness, err := subs[i].publicWitness(system.Curve(), electionID)
+		if err != nil {
+			return fmt.Errorf("client %v: nullifier: %w", i, err)
+		}
+		if err := system.Verify(subs[i].proof, vk, publicWitness); err != nil {
+			return fmt.Errorf("client %v: nullifier: %w", i, err)
+		}
+		return nil
+	})
+	return compactErrors(errs)
+}
+
+// NullifierSet holds the nullifiers used in one election, keyed by their hex
+// encoding.
+type NullifierSet map[string]bool
+
+// Add records the nullifier of client i, or returns ErrReplayedNullifier if it
+// was already used.
+func (s NullifierSet) Add(i int, nullifier *big.Int) error {
+	key := hexBigInt(nullifier)
+	if s[key] {
+		return fmt.Errorf("client %v: %w", i, ErrReplayedNullifier)
+	}
+	s[key] = true
+	return nil
+}
+
+// CheckNullifiers records the nullifiers of the submissions, in order, in a
+// fresh set. It returns one error per replayed nullifier.
+func CheckNullifiers(subs []NullifierSubmission) (NullifierSet, []error) {
+	set := make(NullifierSet)
+	var errs []error
+	for i := 0; i < len(subs); i++ {
+		if err := set.Add(i, subs[i].nullifier); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return set, errs
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/test"
+)
+
+func TestNullifierCircuit(t *testing.T) {
+	var client ClientState
+	client.Init(bn254Field{}, 4)
+
+	assignment := client.GenNullifierAssignment(ElectionID)
+	if err := test.IsSolved(&NullifierCircuit{}, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the nullifier of another election
+	assignment = client.GenNullifierAssignment(ElectionID)
+	assignment.PublicElectionID = ElectionID + 1
+	if err := test.IsSolved(&NullifierCircuit{}, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("a nullifier of another election was accepted")
+	}
+	// a fresh nullifier, from a key which is not the one of the voter
+	var other ClientState
+	other.Init(bn254Field{}, 4)
+	assignment = other.GenNullifierAssignment(ElectionID)
+	assignment.PublicVoterCommitment = client.VoterCom
+	if err := test.IsSolved(&NullifierCircuit{}, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("the key of another voter was accepted")
+	}
+}
+
+func TestReplayedSubmission(t *testing.T) {
+	defer func(dir string) { KeyDir = dir }(KeyDir)
+	KeyDir = ""
+
+	// client 2 replays the ballot of client 0 with a fresh salt, which gives
+	// a fresh commitment
+	clients := make([]ClientState, 3)
+	clients[0].Init(bn254Field{}, 4)
+	clients[1].Init(bn254Field{}, 4)
+	clients[2] = clients[0]
+	clients[2].PrivateSalt = randomFr()
+	clients[2].PublicCom = clients[2].Opening().Commit(bn254Field{})
+	if _, errs := CheckCommitments(bn254Field{}, RejectDuplicates, clients); len(errs) != 0 {
+		t.Fatalf("the replay was caught by the commitments: %v", errs)
+	}
+
+	system, err := NewProofSystem(backend.GROTH16, ecc.BN254)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccs, err := system.Compile(&NullifierCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vk, err := system.Setup(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs, err := GenNullifierSubmissions(system, clients, ccs, ElectionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := VerifyNullifierSubmissions(system, subs, vk, ElectionID); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	set, errs := CheckNullifiers(subs)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrReplayedNullifier) {
+		t.Fatalf("expected one ErrReplayedNullifier, got %v", errs)
+	}
+	if len(set) != 2 {
+		t.Fatalf("%v nullifiers recorded, expected 2", len(set))
+	}
+
+	// the replay cannot dodge the set with another nullifier: its proof no
+	// longer verifies
+	subs[2].nullifier = Nullifier(bn254Field{}, randomFr(), ElectionID)
+	if _, errs := CheckNullifiers(subs); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if errs := VerifyNullifierSubmissions(system, subs, vk, ElectionID); len(errs) != 1 {
+		t.Fatalf("expected the forged nullifier to fail, got %v", errs)
+	}
+	// nor reuse its proof in another election
+	if errs := VerifyNullifierSubmissions(system, subs[:2], vk, ElectionID+1); len(errs) != 2 {
+		t.Fatalf("expected the proofs to fail in another election, got %v", errs)
+	}
+}
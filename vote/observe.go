@@ -0,0 +1,266 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"example/verification/internal/pool"
+)
+
+// An election observer checks a published epoch (see epoch.go) with a single
+// command, and needs no Go toolchain, only the vote binary:
+//
+//	vote observe verify -epoch-dir ./published
+//
+// It prints one line per check and a verdict, and exits with status 1 if any
+// check failed.
+
+var (
+	ErrBadCommitmentRoot = errors.New("observe: the commitment root does not match the commitments")
+	ErrBadChallenge      = errors.New("observe: the challenge does not match the transcript")
+	ErrBadPublicInputs   = errors.New("observe: the submission is not bound to its commitment and the challenge")
+	ErrBadTally          = errors.New("observe: the tally does not match the shuffled pairs")
+	ErrBadPairs          = errors.New("observe: the shuffled pairs do not fit the ballots")
+)
+
+// ObserveCheck is the outcome of one check of an epoch.
+type ObserveCheck struct {
+	Name string
+	// Detail says what passed; Err says what failed.
+	Detail string
+	Err    error
+}
+
+// ObserveEpoch runs every check of e. sample is the number of proofs to
+// verify, drawn with rng; zero or more than the submissions verifies all of
+// them.
+func ObserveEpoch(e *Epoch, sample int, rng *mathrand.Rand) []ObserveCheck {
+	checks := []struct {
+		name  string
+		check func() (string, error)
+	}{
+		{"commitment root", func() (string, error) { return observeCommitmentRoot(e) }},
+		{"challenge", func() (string, error) { return observeChallenge(e) }},
+		{"public inputs", func() (string, error) { return observePublicInputs(e) }},
+		{"proofs", func() (string, error) { return observeProofs(e, sample, rng) }},
+		{"product", func() (string, error) { return observeProduct(e) }},
+		{"tally", func() (string, error) { return observeTally(e) }},
+	}
+	res := make([]ObserveCheck, len(checks))
+	for i, c := range checks {
+		res[i].Name = c.name
+		res[i].Detail, res[i].Err = c.check()
+	}
+	return res
+}
+
+func observeCommitmentRoot(e *Epoch) (string, error) {
+	root := CommitmentRoot(e.Commitments)
+	if !root.Equal(&e.CommitmentRoot) {
+		return "", fmt.Errorf("%w: published %v, recomputed %v from the %v commitments",
+			ErrBadCommitmentRoot, hexElement(&e.CommitmentRoot), hexElement(&root), len(e.Commitments))
+	}
+	return fmt.Sprintf("the root of the %v commitments matches", len(e.Commitments)), nil
+}
+
+func observeChallenge(e *Epoch) (string, error) {
+	if SeedCommitment(e.Seed) != e.SeedCommitment {
+		return "", ErrSeedMismatch
+	}
+	round := NewChallengeRound(e.SeedCommitment, e.NonceCommitments)
+	revealed := 0
+	for i := 0; i < len(e.NonceCommitments); i++ {
+		if !e.Revealed[i] {
+			continue
+		}
+		if err := round.Reveal(i, e.Nonces[i]); err != nil {
+			return "", err
+		}
+		revealed++
+	}
+	publicR, err := round.Derive(e.Seed)
+	if err != nil {
+		return "", err
+	}
+	if !publicR.Equal(&e.PublicR) {
+		return "", fmt.Errorf("%w: published %v, derived %v", ErrBadChallenge, hexElement(&e.PublicR), hexElement(&publicR))
+	}
+	return fmt.Sprintf("publicR derives from the seed and %v of %v nonces", revealed, len(e.NonceCommitments)), nil
+}
+
+func observePublicInputs(e *Epoch) (string, error) {
+	if err := checkPairs(e); err != nil {
+		return "", err
+	}
+	if len(e.Submissions) != len(e.Commitments) {
+		return "", fmt.Errorf("%w: %v submissions for %v commitments", ErrBadPublicInputs, len(e.Submissions), len(e.Commitments))
+	}
+	for i := 0; i < len(e.Submissions); i++ {
+		s := &e.Submissions[i]
+		if s.system.Backend() != e.Backend || s.system.Curve() != e.Curve {
+			return "", fmt.Errorf("%w: %v: %v on %v, the epoch is %v on %v", ErrBadPublicInputs,
+				submissionFile(i), s.system.Backend(), s.system.Curve(), e.Backend, e.Curve)
+		}
+		if s.publicWitness == nil {
+			return "", fmt.Errorf("%v: %w", submissionFile(i), ErrNoProof)
+		}
+		expected, err := expectedPublicWitness(e.Curve, e.CandidateNum, e.PublicR, s.publicProd, e.Commitments[i])
+		if err != nil {
+			return "", fmt.Errorf("%v: %w", submissionFile(i), err)
+		}
+		got, err := s.publicWitness.MarshalBinary()
+		if err != nil {
+			return "", fmt.Errorf("%v: %w", submissionFile(i), err)
+		}
+		if !bytes.Equal(expected, got) {
+			return "", fmt.Errorf("%w: %v", ErrBadPublicInputs, submissionFile(i))
+		}
+	}
+	return fmt.Sprintf("the %v submissions are bound to their commitment and publicR", len(e.Submissions)), nil
+}
+
+func observeProofs(e *Epoch, sample int, rng *mathrand.Rand) (string, error) {
+	n := len(e.Submissions)
+	indices := rng.Perm(n)
+	if sample > 0 && sample < n {
+		indices = indices[:sample]
+	}
+	sort.Ints(indices)
+	errs := pool.RunAll(len(indices), Workers, func(i int) error {
+		if err := VerifySubmission(e.VerifyingKey, &e.Submissions[indices[i]]); err != nil {
+			return fmt.Errorf("%v: %w", submissionFile(indices[i]), err)
+		}
+		return nil
+	})
+	if errs = compactErrors(errs); len(errs) > 0 {
+		return "", fmt.Errorf("%v of %v sampled proofs failed, first %w", len(errs), len(indices), errs[0])
+	}
+	return fmt.Sprintf("%v of %v proofs sampled, all verify", len(indices), n), nil
+}
+
+// checkPairs checks that e has the C(C-1)/2 pairs of each of its ballots,
+// before the checks that size their work by C: the public inputs, of the
+// circuit of C candidates, the product and the tally, of C*C counters.
+func checkPairs(e *Epoch) error {
+	if len(e.Submissions) == 0 || len(e.PairFirst) == 0 {
+		return fmt.Errorf("%w: %v pairs for %v submissions", ErrBadPairs, len(e.PairFirst), len(e.Submissions))
+	}
+	// a ballot has at least C-1 pairs
+	if e.CandidateNum > len(e.PairFirst)+1 {
+		return fmt.Errorf("%w: %v candidates for %v pairs", ErrBadPairs, e.CandidateNum, len(e.PairFirst))
+	}
+	perBallot := e.CandidateNum * (e.CandidateNum - 1) / 2
+	if len(e.PairFirst) != len(e.PairSecond) || len(e.PairFirst) != perBallot*len(e.Submissions) {
+		return fmt.Errorf("%w: %v and %v pairs, expected %v for %v submissions of %v candidates",
+			ErrBadPairs, len(e.PairFirst), len(e.PairSecond), perBallot*len(e.Submissions), len(e.Submissions), e.CandidateNum)
+	}
+	return nil
+}
+
+func observeProduct(e *Epoch) (string, error) {
+	if err := checkPairs(e); err != nil {
+		return "", err
+	}
+	field, err := NewField(e.Curve)
+	if err != nil {
+		return "", err
+	}
+	prodFromShuffler := shufflerProduct(field, e.CandidateNum, e.PairFirst, e.PairSecond, e.Dummies, e.PublicR)
+	prodFromClient := ClientsProduct(field, e.Submissions)
+	if prodFromShuffler.Cmp(prodFromClient) != 0 {
+		return "", ErrInconsistent
+	}
+	return fmt.Sprintf("the %v pairs and %v dummies match the %v products", len(e.PairFirst), len(e.Dummies), len(e.Submissions)), nil
+}
+
+func observeTally(e *Epoch) (string, error) {
+	if err := checkPairs(e); err != nil {
+		return "", err
+	}
+	tally, err := NewTallyPairs(e.PairFirst, e.PairSecond, e.CandidateNum)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBadTally, err)
+	}
+	if len(e.Tally) != e.CandidateNum {
+		return "", fmt.Errorf("%w: %v rows for %v candidates", ErrBadTally, len(e.Tally), e.CandidateNum)
+	}
+	for a := 0; a < e.CandidateNum; a++ {
+		if len(e.Tally[a]) != e.CandidateNum {
+			return "", fmt.Errorf("%w: row %v has %v columns for %v candidates", ErrBadTally, a, len(e.Tally[a]), e.CandidateNum)
+		}
+		for b := 0; b < e.CandidateNum; b++ {
+			if e.Tally[a][b] != tally[a][b] {
+				return "", fmt.Errorf("%w: candidate %v over %v: published %v, recomputed %v", ErrBadTally, a, b, e.Tally[a][b], tally[a][b])
+			}
+		}
+	}
+	if err := tally.Validate(len(e.Submissions)); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBadTally, err)
+	}
+	if winner := tally.SoleWinner(); winner != e.Winner {
+		return "", fmt.Errorf("%w: published winner %v, recomputed %v", ErrBadTally, e.Winner, winner)
+	}
+	if e.Winner < 0 {
+		return "the tally matches, there is no sole winner", nil
+	}
+	return fmt.Sprintf("the tally matches, the sole winner is %v", e.Winner), nil
+}
+
+// printVerdict writes the report of checks on e to w and reports whether they
+// all passed.
+func printVerdict(w io.Writer, dir string, e *Epoch, checks []ObserveCheck) bool {
+	fmt.Fprintf(w, "Epoch %v: %v on %v, %v candidates, %v ballots\n\n", dir, e.Backend, e.Curve, e.CandidateNum, len(e.Submissions))
+	failed := 0
+	for _, c := range checks {
+		if c.Err != nil {
+			failed++
+			fmt.Fprintf(w, "  FAIL  %-16v %v\n", c.Name, c.Err)
+		} else {
+			fmt.Fprintf(w, "  PASS  %-16v %v\n", c.Name, c.Detail)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "\nVerdict: FAIL, %v of %v checks failed\n", failed, len(checks))
+		return false
+	}
+	fmt.Fprintf(w, "\nVerdict: PASS, all %v checks passed\n", len(checks))
+	return true
+}
+
+// observeMain is the entry point of "vote observe".
+func observeMain(args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintf(os.Stderr, "usage: vote observe verify -epoch-dir <dir>\n")
+		return 2
+	}
+	fs := flag.NewFlagSet("observe verify", flag.ExitOnError)
+	dir := fs.String("epoch-dir", "published", "directory of the published epoch")
+	sample := fs.Int("sample", 0, "number of proofs to verify, 0 for all")
+	fs.IntVar(&Workers, "workers", Workers, "number of goroutines verifying the proofs")
+	fs.Parse(args[1:])
+	if *sample < 0 {
+		fmt.Fprintf(os.Stderr, "invalid configuration: -sample must not be negative, got %v\n", *sample)
+		return 2
+	}
+
+	e, err := LoadEpoch(*dir)
+	if err != nil {
+		log.Printf("observe: cannot read the epoch: %v\n", err)
+		return 1
+	}
+	rng := mathrand.New(mathrand.NewSource(time.Now().UnixNano()))
+	if !printVerdict(os.Stdout, *dir, e, ObserveEpoch(e, *sample, rng)) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,248 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+)
+
+// failedChecks returns the names of the failed checks.
+func failedChecks(checks []ObserveCheck) []string {
+	var failed []string
+	for _, c := range checks {
+		if c.Err != nil {
+			failed = append(failed, c.Name)
+		}
+	}
+	return failed
+}
+
+func TestObserveEpoch(t *testing.T) {
+	published := filepath.Join(t.TempDir(), "published")
+	if _, errs := runNetworkElection(t, 3, 3, time.Minute, published, nil); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	rng := mathrand.New(mathrand.NewSource(1))
+
+	e, err := LoadEpoch(published)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := ObserveEpoch(e, 0, rng)
+	if failed := failedChecks(checks); len(failed) > 0 {
+		for _, c := range checks {
+			t.Logf("%v: %v", c.Name, c.Err)
+		}
+		t.Fatalf("the healthy epoch failed %v", failed)
+	}
+	var out bytes.Buffer
+	if !printVerdict(&out, published, e, checks) || !strings.Contains(out.String(), "Verdict: PASS") {
+		t.Fatalf("unexpected verdict:\n%v", out.String())
+	}
+
+	corruptions := []struct {
+		name    string
+		corrupt func(e *Epoch)
+		check   string
+		err     error
+	}{
+		{"bad root", func(e *Epoch) { e.CommitmentRoot = randomFr() }, "commitment root", ErrBadCommitmentRoot},
+		{"bad proof", func(e *Epoch) {
+			proof := append(ProofBytes(nil), e.Submissions[1].proof...)
+			proof[len(proof)/2] ^= 1
+			e.Submissions[1].proof = proof
+		}, "proofs", nil},
+		{"bad tally", func(e *Epoch) { e.Tally[0][1]++ }, "tally", ErrBadTally},
+	}
+	for _, c := range corruptions {
+		t.Run(c.name, func(t *testing.T) {
+			e, err := LoadEpoch(published)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.corrupt(e)
+			dir := filepath.Join(t.TempDir(), "published")
+			if err := ExportEpoch(dir, e); err != nil {
+				t.Fatal(err)
+			}
+			if e, err = LoadEpoch(dir); err != nil {
+				t.Fatal(err)
+			}
+			checks := ObserveEpoch(e, 0, rng)
+			if failed := failedChecks(checks); len(failed) != 1 || failed[0] != c.check {
+				t.Fatalf("failed %v, expected [%v]", failed, c.check)
+			}
+			for _, check := range checks {
+				if check.Name == c.check && c.err != nil && !errors.Is(check.Err, c.err) {
+					t.Fatalf("expected %v, got %v", c.err, check.Err)
+				}
+			}
+			var out bytes.Buffer
+			if printVerdict(&out, dir, e, checks) || !strings.Contains(out.String(), "Verdict: FAIL, 1 of 6 checks failed") {
+				t.Fatalf("unexpected verdict:\n%v", out.String())
+			}
+		})
+	}
+
+	// the pairs that do not fit the ballots fail the checks sized by them,
+	// rather than panic or allocate for the claimed candidates
+	misfits := []struct {
+		name    string
+		corrupt func(e *Epoch)
+	}{
+		{"no pairs", func(e *Epoch) { e.PairFirst, e.PairSecond = nil, nil }},
+		{"a pair dropped", func(e *Epoch) { e.PairFirst, e.PairSecond = e.PairFirst[1:], e.PairSecond[1:] }},
+		{"too many candidates", func(e *Epoch) { e.CandidateNum = 1 << 40 }},
+	}
+	for _, c := range misfits {
+		t.Run(c.name, func(t *testing.T) {
+			e, err := LoadEpoch(published)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.corrupt(e)
+			for _, check := range ObserveEpoch(e, 0, rng) {
+				switch check.Name {
+				case "public inputs", "product", "tally":
+					if !errors.Is(check.Err, ErrBadPairs) {
+						t.Fatalf("%v: expected ErrBadPairs, got %v", check.Name, check.Err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCommitmentInclusion(t *testing.T) {
+	field := bn254Field{}
+	clients := initClients(5, 2, field, 2)
+	commitments := make([]*big.Int, len(clients))
+	for i := range clients {
+		commitments[i] = clients[i].PublicCom
+	}
+	tree := CommitmentTree(commitments)
+	root := CommitmentRoot(commitments)
+	for i := range clients {
+		path, err := tree.Prove(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyCommitmentInclusion(commitments[i], path, root) {
+			t.Fatalf("the commitment of client %v is not in the root", i)
+		}
+		if VerifyCommitmentInclusion(new(big.Int).Add(commitments[i], big.NewInt(1)), path, root) {
+			t.Fatalf("a tampered commitment of client %v is in the root", i)
+		}
+	}
+}
+
+// testEpoch is an epoch of one client without its submission, enough to be
+// published and loaded.
+func testEpoch(t *testing.T) *Epoch {
+	clients := initClients(1, 1, bn254Field{}, 2)
+	return &Epoch{
+		Backend:          backend.GROTH16,
+		Curve:            ecc.BN254,
+		CandidateNum:     CandidateNum,
+		VerifyingKey:     VerifyingKeyBytes{1, 2, 3},
+		NonceCommitments: []fr_bn254.Element{randomFr()},
+		Nonces:           []fr_bn254.Element{randomFr()},
+		Revealed:         []bool{true},
+		PublicR:          randomFr(),
+		Commitments:      []*big.Int{clients[0].PublicCom},
+		CommitmentRoot:   CommitmentRoot([]*big.Int{clients[0].PublicCom}),
+		PairFirst:        clients[0].PairFirst,
+		PairSecond:       clients[0].PairSecond,
+		Dummies:          clients[0].PrivateY,
+		Winner:           -1,
+	}
+}
+
+func TestExportEpochAtomic(t *testing.T) {
+	defer func() { epochWriteHook = nil }()
+	artifacts := []string{"submissions", "verifying.key", "epoch.json", "manifest.json"}
+	for _, crashAt := range artifacts {
+		t.Run(crashAt, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "published")
+			crash := errors.New("crash")
+			epochWriteHook = func(name string) error {
+				if name == crashAt {
+					return crash
+				}
+				return nil
+			}
+			if err := ExportEpoch(dir, testEpoch(t)); !errors.Is(err, crash) {
+				t.Fatalf("got %v, expected the crash", err)
+			}
+			// nothing is published, and the staging directory is refused
+			if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("%v exists after a crash: %v", dir, err)
+			}
+			if _, err := LoadEpoch(dir + ".staging"); !errors.Is(err, ErrUnpublished) {
+				t.Fatalf("loading the staging directory: got %v, expected ErrUnpublished", err)
+			}
+
+			// publishing again after the crash completes
+			epochWriteHook = nil
+			if err := ExportEpoch(dir, testEpoch(t)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadEpoch(dir); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(dir + ".staging"); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("the staging directory is left after the publication: %v", err)
+			}
+		})
+	}
+
+	// a published epoch is not overwritten
+	dir := filepath.Join(t.TempDir(), "published")
+	if err := ExportEpoch(dir, testEpoch(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportEpoch(dir, testEpoch(t)); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("publishing twice: got %v, expected os.ErrExist", err)
+	}
+}
+
+func TestLoadEpochPartial(t *testing.T) {
+	tamper := map[string]func(dir string) error{
+		"no manifest": func(dir string) error { return os.Remove(filepath.Join(dir, "manifest.json")) },
+		"a file missing": func(dir string) error {
+			return os.Remove(filepath.Join(dir, "verifying.key"))
+		},
+		"a file changed": func(dir string) error {
+			return os.WriteFile(filepath.Join(dir, "verifying.key"), []byte{4}, 0600)
+		},
+		"a file added": func(dir string) error {
+			return os.WriteFile(filepath.Join(dir, "submissions", "extra"), nil, 0600)
+		},
+	}
+	for name, f := range tamper {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "published")
+			if err := ExportEpoch(dir, testEpoch(t)); err != nil {
+				t.Fatal(err)
+			}
+			if err := f(dir); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadEpoch(dir); !errors.Is(err, ErrUnpublished) {
+				t.Fatalf("got %v, expected ErrUnpublished", err)
+			}
+		})
+	}
+}
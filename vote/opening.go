@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/benchrand"
+)
+
+// A client that opens its ballot needs no SNARK: the server recomputes
+// natively everything the vote circuit checks, that the packed pairs are the
+// pairs of a ranking, that they open the commitment with the dummies, that
+// the mask is the product of the dummies and that the product is their
+// evaluation at publicR times the mask. In the audit mode a fraction of
+// the clients, drawn at random once the proofs are in, open their ballot and
+// their proofs are not verified.
+
+var (
+	ErrBadBallot  = errors.New("opening: the pairs are not a ranking of the candidates")
+	ErrBadProduct = errors.New("opening: the product does not match the opening")
+)
+
+// OpenedBallot is what an audited client reveals: the opening of its
+// commitment, whose PrivateX are the packed pairs of its ranking.
+type OpenedBallot struct {
+	Opening
+}
+
+// OpenedBallot returns the opened ballot of the client.
+func (c *ClientState) OpenedBallot() OpenedBallot {
+	return OpenedBallot{Opening: c.Opening()}
+}
+
+// checkRanking checks that privateX packs, as first * candidateNum + second,
+// the pairs of a ranking of candidateNum candidates in the order of the vote
+// circuit: (sorted[i], sorted[j]) for every i < j.
+func checkRanking(privateX []fr_bn254.Element, candidateNum int) error {
+	if candidateNum < 2 || len(privateX) != candidateNum*(candidateNum-1)/2 {
+		return fmt.Errorf("%w: %v pairs for %v candidates", ErrBadBallot, len(privateX), candidateNum)
+	}
+	c := uint64(candidateNum)
+	unpack := func(x *fr_bn254.Element) (uint64, uint64, bool) {
+		if !x.IsUint64() || x.Uint64() >= c*c {
+			return 0, 0, false
+		}
+		return x.Uint64() / c, x.Uint64() % c, true
+	}
+
+	// the first row holds (sorted[0], sorted[j]) for every j > 0
+	sorted := make([]uint64, candidateNum)
+	seen := make([]bool, candidateNum)
+	for j := 0; j < candidateNum-1; j++ {
+		first, second, ok := unpack(&privateX[j])
+		if !ok {
+			return ErrBadBallot
+		}
+		sorted[0], sorted[j+1] = first, second
+	}
+	for i := 0; i < candidateNum; i++ {
+		if seen[sorted[i]] {
+			return ErrBadBallot
+		}
+		seen[sorted[i]] = true
+	}
+
+	pair := 0
+	for i := 0; i < candidateNum; i++ {
+		for j := i + 1; j < candidateNum; j++ {
+			expected := fr_bn254.NewElement(sorted[i]*c + sorted[j])
+			if !privateX[pair].Equal(&expected) {
+				return ErrBadBallot
+			}
+			pair++
+		}
+	}
+	return nil
+}
+
+// VerifyOpening checks natively what the vote circuit proves about a client
+// with the given commitment and product under the challenge r. It returns
+// ErrBadBallot, ErrBadOpening or ErrBadProduct, whichever check fails first.
+func VerifyOpening(field Field, opened OpenedBallot, commitment *big.Int, r fr_bn254.Element, claimedProd *big.Int) error {
+	if err := checkRanking(opened.PrivateX, CandidateNum); err != nil {
+		return err
+	}
+	if !opened.Opens(field, commitment) {
+		return ErrBadOpening
+	}
+	if !constantTimeEqual(field, field.Prod(opened.PrivateY), opened.PrivateMask) {
+		return fmt.Errorf("%w: the mask is not the product of the dummies", ErrBadProduct)
+	}
+	if !constantTimeEqual(field, field.Mul(field.PolyEval(opened.PrivateX, r), opened.PrivateMask), claimedProd) {
+		return ErrBadProduct
+	}
+	return nil
+}
+
+// SelectAudited draws the round(fraction * n) clients out of n that open their
+// ballot, in increasing order.
+func SelectAudited(rnd *benchrand.Rand, n int, fraction float64) []int {
+	k := int(math.Round(fraction * float64(n)))
+	if k <= 0 {
+		return nil
+	}
+	if k > n {
+		k = n
+	}
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	rnd.Shuffle(n, func(i, j int) { perm[i], perm[j] = perm[j], perm[i] })
+	audited := perm[:k]
+	sort.Ints(audited)
+	return audited
+}
+
+// AuditOpenings has the audited clients open their ballot and checks every
+// opening against the commitment and the product they submitted. It returns
+// one error per failed opening.
+func AuditOpenings(field Field, clients []ClientState, allSubmission []ClientSubmission, audited []int, publicR fr_bn254.Element) []error {
+	var errs []error
+	for _, i := range audited {
+		if err := VerifyOpening(field, clients[i].OpenedBallot(), clients[i].PublicCom, publicR, allSubmission[i].publicProd); err != nil {
+			errs = append(errs, fmt.Errorf("client %v: %w", i, err))
+		}
+	}
+	return errs
+}
+
+// skipAudited returns a copy of allSubmission without the proofs of the
+// audited clients, whose openings were checked instead, and the number of
+// SNARK verifications it saves.
+func skipAudited(allSubmission []ClientSubmission, audited []int) ([]ClientSubmission, int) {
+	rest := append([]ClientSubmission(nil), allSubmission...)
+	saved := 0
+	for _, i := range audited {
+		if rest[i].proof != nil {
+			rest[i].proof = nil
+			rest[i].publicWitness = nil
+			saved++
+		}
+	}
+	return rest, saved
+}
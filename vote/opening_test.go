@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/benchrand"
+)
+
+func TestVerifyOpening(t *testing.T) {
+	var client ClientState
+	client.Init(bn254Field{}, 4)
+	publicR := randomFr()
+	verify := func(opened OpenedBallot, prod *big.Int) error {
+		return VerifyOpening(bn254Field{}, opened, client.PublicCom, publicR, prod)
+	}
+	prod := client.ComputePolyEval(publicR)
+
+	if err := verify(client.OpenedBallot(), prod); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the salt differs
+	opened := client.OpenedBallot()
+	opened.PrivateSalt.SetUint64(1)
+	opened.PrivateSalt.Add(&opened.PrivateSalt, &client.PrivateSalt)
+	if err := verify(opened, prod); !errors.Is(err, ErrBadOpening) {
+		t.Fatalf("a wrong salt: got %v, expected ErrBadOpening", err)
+	}
+
+	opened = client.OpenedBallot()
+	opened.PrivateMask = new(big.Int).Add(client.PrivateMask, big.NewInt(1))
+	if err := verify(opened, prod); !errors.Is(err, ErrBadOpening) {
+		t.Fatalf("a wrong mask: got %v, expected ErrBadOpening", err)
+	}
+
+	if err := verify(client.OpenedBallot(), new(big.Int).Add(prod, big.NewInt(1))); !errors.Is(err, ErrBadProduct) {
+		t.Fatalf("a wrong product: got %v, expected ErrBadProduct", err)
+	}
+
+	// swapping two pairs keeps the multiset the shuffler sees, but is not the
+	// ballot of a ranking
+	opened = client.OpenedBallot()
+	opened.PrivateX = append([]fr_bn254.Element(nil), opened.PrivateX...)
+	opened.PrivateX[0], opened.PrivateX[1] = opened.PrivateX[1], opened.PrivateX[0]
+	if err := verify(opened, prod); !errors.Is(err, ErrBadBallot) {
+		t.Fatalf("swapped pairs: got %v, expected ErrBadBallot", err)
+	}
+}
+
+func TestAuditSavesVerifications(t *testing.T) {
+	const n = 100
+	clients := make([]ClientState, n)
+	allSubmission := make([]ClientSubmission, n)
+	publicR := randomFr()
+	for i := 0; i < n; i++ {
+		clients[i].Init(bn254Field{}, 4)
+		allSubmission[i].publicProd = clients[i].ComputePolyEval(publicR)
+		allSubmission[i].proof = ProofBytes{1}
+	}
+
+	audited := SelectAudited(benchrand.New(1), n, 0.1)
+	if len(audited) != 10 {
+		t.Fatalf("%v clients audited, expected 10", len(audited))
+	}
+	if errs := AuditOpenings(bn254Field{}, clients, allSubmission, audited, publicR); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	rest, saved := skipAudited(allSubmission, audited)
+	if saved != len(audited) {
+		t.Fatalf("%v verifications saved, expected %v", saved, len(audited))
+	}
+	remaining := 0
+	for i := range rest {
+		if rest[i].proof != nil {
+			remaining++
+		}
+	}
+	if remaining != n-len(audited) || allSubmission[audited[0]].proof == nil {
+		t.Fatalf("%v proofs left to verify, expected %v", remaining, n-len(audited))
+	}
+
+	// a client that lied about its product fails the audit
+	allSubmission[audited[0]].publicProd = big.NewInt(1)
+	if errs := AuditOpenings(bn254Field{}, clients, allSubmission, audited, publicR); len(errs) != 1 || !errors.Is(errs[0], ErrBadProduct) {
+		t.Fatalf("expected one ErrBadProduct, got %v", errs)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc"
+
+	"example/verification/internal/hasher"
+)
+
+// ProtocolParams are the choices the clients, the server and the circuits
+// have to agree on, besides the proof system.
+type ProtocolParams struct {
+	// Hash is the hash of the commitments and of the nullifiers, in and out
+	// of the circuits.
+	Hash hasher.HashConfig
+}
+
+// Params are the protocol parameters of the benchmark.
+var Params = ProtocolParams{Hash: hasher.MiMC}
+
+// NewField returns the Field of curve, committing with p.Hash.
+func (p ProtocolParams) NewField(curve ecc.ID) (Field, error) {
+	if _, err := p.Hash.Native(curve); err != nil {
+		return nil, err
+	}
+	switch curve {
+	case ecc.BN254:
+		return bn254Field{hash: p.Hash}, nil
+	case ecc.BLS12_381:
+		return bls12381Field{hash: p.Hash}, nil
+	}
+	return nil, fmt.Errorf("unsupported curve %v", curve)
+}
@@ -0,0 +1,161 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// The plonk backend. It is left out of the wasm client, which only proves
+// with groth16, see noplonk.go.
+
+// PlonkSystem is the plonk ProofSystem.
+type PlonkSystem struct {
+	curve  ecc.ID
+	pk     plonk.ProvingKey
+	pkSize int
+
+	// parsed verifying keys, keyed by their serialization
+	mu  sync.Mutex
+	vks map[string]plonk.VerifyingKey
+}
+
+func NewPlonkSystem(curve ecc.ID) *PlonkSystem {
+	return &PlonkSystem{curve: curve, vks: make(map[string]plonk.VerifyingKey)}
+}
+
+func (s *PlonkSystem) Name() string { return "Plonk" }
+
+func (s *PlonkSystem) Backend() backend.ID { return backend.PLONK }
+
+func (s *PlonkSystem) Curve() ecc.ID { return s.curve }
+
+func (s *PlonkSystem) Compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	return gnarkshim.CompileSCS(s.curve, circuit)
+}
+
+func (s *PlonkSystem) Setup(ccs constraint.ConstraintSystem) (VerifyingKeyBytes, error) {
+	k, err := s.setupKeys(ccs)
+	if err != nil {
+		return nil, err
+	}
+	return s.useKeys(k)
+}
+
+func (s *PlonkSystem) setupKeys(ccs constraint.ConstraintSystem) (*Keys, error) {
+	srs, err := gnarkshim.NewKZGSRS(ccs)
+	if err != nil {
+		return nil, fmt.Errorf("kzg srs: %w", err)
+	}
+	pk, vk, err := gnarkshim.SetupPlonk(ccs, srs)
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{CCS: ccs, PK: pk, VK: vk, SRS: srs}, nil
+}
+
+func (s *PlonkSystem) useKeys(k *Keys) (VerifyingKeyBytes, error) {
+	pk, ok := k.PK.(plonk.ProvingKey)
+	if !ok {
+		return nil, errors.New("plonk: the keys belong to another backend")
+	}
+	vk, ok := k.VK.(plonk.VerifyingKey)
+	if !ok {
+		return nil, errors.New("plonk: the keys belong to another backend")
+	}
+	return s.use(pk, vk)
+}
+
+// use makes s prove with pk and returns the serialization of vk.
+func (s *PlonkSystem) use(pk plonk.ProvingKey, vk plonk.VerifyingKey) (VerifyingKeyBytes, error) {
+	var buf bytes.Buffer
+	if _, err := pk.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	s.pk, s.pkSize = pk, buf.Len()
+	buf.Reset()
+	if _, err := vk.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *PlonkSystem) ProvingKeySize() int { return s.pkSize }
+
+func (s *PlonkSystem) Prove(ccs constraint.ConstraintSystem, witness witness.Witness) (ProofBytes, error) {
+	proof, err := gnarkshim.ProvePlonk(ccs, s.pk, witness)
+	if err != nil {
+		return nil, fmt.Errorf("plonk prove: %w", err)
+	}
+	var buf bytes.Buffer
+	if _, err := proof.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *PlonkSystem) verifyingKey(vk VerifyingKeyBytes) (plonk.VerifyingKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _vk, ok := s.vks[string(vk)]; ok {
+		return _vk, nil
+	}
+	_vk := plonk.NewVerifyingKey(s.curve)
+	if _, err := _vk.ReadFrom(bytes.NewReader(vk)); err != nil {
+		return nil, fmt.Errorf("plonk verifying key: %w", err)
+	}
+	s.vks[string(vk)] = _vk
+	return _vk, nil
+}
+
+func (s *PlonkSystem) Verify(proof ProofBytes, vk VerifyingKeyBytes, publicWitness witness.Witness) error {
+	_vk, err := s.verifyingKey(vk)
+	if err != nil {
+		return err
+	}
+	_proof := plonk.NewProof(s.curve)
+	if _, err := _proof.ReadFrom(bytes.NewReader(proof)); err != nil {
+		return fmt.Errorf("plonk proof: %w", err)
+	}
+	return gnarkshim.VerifyPlonk(_proof, _vk, publicWitness)
+}
+
+func newPlonkSystem(curve ecc.ID) (ProofSystem, error) {
+	return NewPlonkSystem(curve), nil
+}
+
+// SavePlonkKeys writes a plonk key pair to dir, together with the number of
+// candidates of the circuit.
+func SavePlonkKeys(pk plonk.ProvingKey, vk plonk.VerifyingKey, dir string) error {
+	return saveKeys(pk, vk, dir, "plonk")
+}
+
+// LoadPlonkKeys is the plonk counterpart of LoadGroth16Keys.
+func LoadPlonkKeys(curve ecc.ID, dir string) (plonk.ProvingKey, plonk.VerifyingKey, error) {
+	pk := plonk.NewProvingKey(curve)
+	vk := plonk.NewVerifyingKey(curve)
+	if err := loadKeys(pk, vk, dir, "plonk"); err != nil {
+		return nil, nil, err
+	}
+	return pk, vk, nil
+}
+
+// newPlonk allocates the plonk artifacts of k on curve, to be read back.
+func (k *Keys) newPlonk(curve ecc.ID) error {
+	k.CCS = plonk.NewCS(curve)
+	k.PK, k.VK, k.SRS = plonk.NewProvingKey(curve), plonk.NewVerifyingKey(curve), kzg.NewSRS(curve)
+	return nil
+}
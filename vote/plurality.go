@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+	"example/verification/internal/hasher"
+)
+
+// In a plurality (first-past-the-post) election each voter picks a single
+// candidate. The client sends the shuffler the one pair
+// (3 * candidateNum, choice), packed as 3 * candidateNum^2 + choice, past the
+// Borda scores of borda.go, so that the tallies of the other ballots reject
+// it.
+
+type PluralityVoteCircuit struct {
+	// Choice is the candidate the voter picks
+	Choice frontend.Variable
+
+	// The following are for the polynomial evaluation
+	PrivateMask frontend.Variable
+	// DummyVec are the dummies the client sends to the shuffler; the mask is
+	// their product, as the server multiplies them in. They are committed, so
+	// the mask is the one of the dummies fixed before publicR.
+	DummyVec   []frontend.Variable
+	PublicR    frontend.Variable `gnark:",public"`
+	PublicProd frontend.Variable `gnark:",public"`
+
+	// The following are for the commitment
+	PublicCommitment frontend.Variable `gnark:",public"`
+	PrivateSalt      frontend.Variable
+
+	// CandidateNum is the number of candidates
+	CandidateNum int `gnark:"-"`
+	// Hash is the hash of the commitment, see params.go
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+func (circuit *PluralityVoteCircuit) Define(api frontend.API) error {
+	candidateNum := circuit.CandidateNum
+
+	// the choice is a root of (x - 0) * ... * (x - (candidateNum-1))
+	membership := frontend.Variable(1)
+	for k := 0; k < candidateNum; k++ {
+		membership = api.Mul(membership, api.Sub(circuit.Choice, k))
+	}
+	gadget.Label(api, gadget.Range).AssertIsEqual(membership, 0)
+
+	packed := api.Add(3*candidateNum*candidateNum, circuit.Choice)
+
+	// The following is for the polynomial evaluation
+	privateProd := api.Add(packed, circuit.PublicR)
+	privateProd = api.Mul(privateProd, circuit.PrivateMask)
+	gadget.Label(api, gadget.Product).AssertIsEqual(privateProd, circuit.PublicProd)
+
+	dummyProd := frontend.Variable(1)
+	for i := 0; i < len(circuit.DummyVec); i++ {
+		dummyProd = api.Mul(dummyProd, circuit.DummyVec[i])
+	}
+	gadget.Label(api, gadget.Product).AssertIsEqual(circuit.PrivateMask, dummyProd)
+
+	// the commitment binds the pair sent to the shuffler
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	h.Write(packed)
+	h.Write(circuit.DummyVec...)
+	h.Write(circuit.PrivateMask)
+	h.Write(circuit.PrivateSalt)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicCommitment, h.Sum())
+	return nil
+}
+
+// NewPluralityVoteCircuit allocates an empty plurality circuit for
+// candidateNum candidates and dummyNum dummies, hashing with Params.Hash,
+// ready to be compiled. Its public variables are VotePublic.
+func NewPluralityVoteCircuit(candidateNum int, dummyNum int) PluralityVoteCircuit {
+	return PluralityVoteCircuit{
+		Choice:           0,
+		PrivateMask:      0,
+		DummyVec:         make([]frontend.Variable, dummyNum),
+		PublicR:          0,
+		PublicProd:       0,
+		PublicCommitment: 0,
+		PrivateSalt:      0,
+		CandidateNum:     candidateNum,
+		Hash:             Params.Hash,
+	}
+}
+
+// PluralityClientState is the ClientState of a plurality ballot: its one
+// pair is the marker 3 * CandidateNum and the choice.
+type PluralityClientState struct {
+	ClientState
+}
+
+// Init samples a random choice for the client together with dummyNum dummies
+// and computes the commitment to them in field.
+func (c *PluralityClientState) Init(field Field, dummyNum uint64) {
+	c.InitRand(benchrand.New(0), field, dummyNum)
+}
+
+// InitRand is Init drawing all the randomness of the client from rnd.
+func (c *PluralityClientState) InitRand(rnd *benchrand.Rand, field Field, dummyNum uint64) {
+	c.SortedCandidate = nil
+	c.PairFirst = []fr_bn254.Element{fr_bn254.NewElement(uint64(3 * CandidateNum))}
+	c.PairSecond = []fr_bn254.Element{fr_bn254.NewElement(uint64(rnd.Intn(CandidateNum)))}
+	c.PrivateX = packPairs(CandidateNum, c.PairFirst, c.PairSecond)
+
+	c.initSecrets(rnd, field, dummyNum)
+}
+
+// Choice returns the candidate picked by the client.
+func (c *PluralityClientState) Choice() fr_bn254.Element {
+	return c.PairSecond[0]
+}
+
+// GenAssignment is ClientState.GenAssignment for the plurality ballot of the
+// client.
+func (c *PluralityClientState) GenAssignment(publicR fr_bn254.Element) PluralityVoteCircuit {
+	dummyVec := make([]frontend.Variable, len(c.PrivateY))
+	for i := 0; i < len(dummyVec); i++ {
+		dummyVec[i] = frontendVariable(&c.PrivateY[i])
+	}
+
+	publicProd := c.ComputePolyEval(publicR)
+
+	choice := c.Choice()
+	return PluralityVoteCircuit{
+		Choice:           frontendVariable(&choice),
+		PrivateMask:      frontend.Variable(c.PrivateMask),
+		DummyVec:         dummyVec,
+		PublicR:          frontendVariable(&publicR),
+		PublicProd:       frontend.Variable(publicProd),
+		PublicCommitment: frontend.Variable(c.PublicCom),
+		PrivateSalt:      frontendVariable(&c.PrivateSalt),
+	}
+}
+
+// CountPluralityVotes returns the number of votes of each of the CandidateNum
+// candidates in the shuffled choices, the second elements of the plurality
+// pairs.
+func CountPluralityVotes(shuffledChoices []fr_bn254.Element) ([]uint64, error) {
+	votes := make([]uint64, CandidateNum)
+	for i := 0; i < len(shuffledChoices); i++ {
+		choice := shuffledChoices[i]
+		if !choice.IsUint64() || choice.Uint64() >= uint64(CandidateNum) {
+			return nil, fmt.Errorf("%w: choice %v is not one of %v candidates", ErrBallotKind, i, CandidateNum)
+		}
+		votes[choice.Uint64()]++
+	}
+	return votes, nil
+}
+
+// ComputePlurality checks that the shuffled pairs are plurality pairs of
+// CandidateNum candidates and counts their votes.
+func ComputePlurality(shuffledPairFirst, shuffledPairSecond []fr_bn254.Element) ([]uint64, error) {
+	if len(shuffledPairFirst) != len(shuffledPairSecond) {
+		return nil, fmt.Errorf("%w: %v first and %v second elements", ErrBallotKind, len(shuffledPairFirst), len(shuffledPairSecond))
+	}
+	marker := fr_bn254.NewElement(uint64(3 * CandidateNum))
+	for i := 0; i < len(shuffledPairFirst); i++ {
+		if !shuffledPairFirst[i].Equal(&marker) {
+			return nil, fmt.Errorf("%w: pair %v is not a plurality vote", ErrBallotKind, i)
+		}
+	}
+	return CountPluralityVotes(shuffledPairSecond)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+)
+
+func TestPluralityVoteCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewPluralityVoteCircuit(CandidateNum, 4)
+
+	var client PluralityClientState
+	client.InitRand(benchrand.New(1), bn254Field{}, 4)
+	assignment := client.GenAssignment(randomFr())
+	assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// a choice out of the candidates, with the packed pair and the commitment
+	// consistent with it
+	client.PairSecond[0] = fr_bn254.NewElement(uint64(CandidateNum))
+	client.PrivateX = packPairs(CandidateNum, client.PairFirst, client.PairSecond)
+	client.PublicCom = client.Opening().Commit(bn254Field{})
+	assignment = client.GenAssignment(randomFr())
+	err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+	if err == nil || gadget.ParseFailure(err).Invariant != gadget.Range {
+		t.Fatalf("a choice out of the candidates: got %v, expected the range check to fail", err)
+	}
+	assert.ProverFailed(&circuit, &assignment, test.WithCurves(ecc.BN254))
+}
+
+func TestComputePlurality(t *testing.T) {
+	field := bn254Field{}
+	rnd := benchrand.New(8)
+	publicR := rnd.Fr()
+	clients := make([]ClientState, 20)
+	expected := make([]uint64, CandidateNum)
+	clientsProd := big.NewInt(1)
+	for i := range clients {
+		var c PluralityClientState
+		c.InitRand(rnd, field, 2)
+		choice := c.Choice()
+		expected[choice.Uint64()]++
+		clientsProd = field.Mul(clientsProd, c.ComputePolyEval(publicR))
+		clients[i] = c.ClientState
+	}
+	pairFirst, pairSecond := ShufflePairs(rnd, clients)
+	dummies := ShuffleDummies(rnd, clients)
+	if ShufflerProduct(field, pairFirst, pairSecond, dummies, publicR).Cmp(clientsProd) != 0 {
+		t.Fatal("the product of the shuffled choices is not the product of the clients")
+	}
+	votes, err := ComputePlurality(pairFirst, pairSecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(votes, expected) {
+		t.Fatalf("votes %v, expected %v", votes, expected)
+	}
+
+	if _, err := CountPluralityVotes([]fr_bn254.Element{fr_bn254.NewElement(uint64(CandidateNum))}); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("a choice out of the candidates: got %v, expected ErrBallotKind", err)
+	}
+	var ranked ClientState
+	ranked.InitRand(rnd, field, 2)
+	if _, err := ComputePlurality(ranked.PairFirst, ranked.PairSecond); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("the plurality tally of a ranking: got %v, expected ErrBallotKind", err)
+	}
+	if _, err := ComputeBordaScores(pairFirst, pairSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("the Borda tally of plurality votes: got %v, expected ErrBallotKind", err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/consensys/gnark/backend/groth16"
+)
+
+// PrepareSubmission is the prove round of a client without the network: it
+// proves the ballot of the client encoded by ClientState.MarshalJSON, with
+// the challenge in its PublicR, using the serialized Groth16 proving key of
+// the server. It returns the submission encoded by
+// ClientSubmission.MarshalJSON, for the server to verify.
+//
+// It only depends on the Groth16 backend, so that the wasm client stays
+// small, see wasm.go.
+func PrepareSubmission(clientJSON []byte, provingKey []byte) ([]byte, error) {
+	var c ClientState
+	if err := c.UnmarshalJSON(clientJSON); err != nil {
+		return nil, fmt.Errorf("client: %w", err)
+	}
+	curve := c.field.Curve()
+	system := NewGroth16System(curve)
+	pk := groth16.NewProvingKey(curve)
+	if _, err := pk.ReadFrom(bytes.NewReader(provingKey)); err != nil {
+		return nil, fmt.Errorf("proving key: %w", err)
+	}
+	system.pk, system.pkSize = pk, len(provingKey)
+
+	// only written when it differs, as in RunClient
+	if CandidateNum != len(c.SortedCandidate) {
+		CandidateNum = len(c.SortedCandidate)
+	}
+	circuit := NewVoteCircuit(CandidateNum, len(c.PrivateY))
+	ccs, err := compileCircuit(system, &circuit, VotePublic)
+	if err != nil {
+		return nil, err
+	}
+	assignment := c.GenAssignment(c.PublicR)
+	proof, publicWitness, err := GenProof(system, assignment.Circuit, ccs)
+	if err != nil {
+		return nil, fmt.Errorf("proof generation: %w", err)
+	}
+	submission := ClientSubmission{publicWitness: publicWitness, publicProd: assignment.PublicProd, proof: proof, system: system}
+	return submission.MarshalJSON()
+}
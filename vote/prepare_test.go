@@ -0,0 +1,128 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+// prepareInputs returns a client with a challenge, encoded as
+// PrepareSubmission expects it, together with the proving key and the
+// verifying key of a fresh groth16 setup.
+func prepareInputs(t *testing.T) ([]byte, []byte, VerifyingKeyBytes) {
+	system := NewGroth16System(ecc.BN254)
+	_, vk := setupSystem(t, system)
+	var pk bytes.Buffer
+	if _, err := system.pk.WriteTo(&pk); err != nil {
+		t.Fatal(err)
+	}
+
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	var c ClientState
+	c.Init(bn254Field{}, dummyNum)
+	c.PublicR = randomFr()
+	client, err := json.Marshal(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, pk.Bytes(), vk
+}
+
+// verifyPrepared decodes a submission returned by PrepareSubmission and
+// verifies it natively against vk.
+func verifyPrepared(t *testing.T, data []byte, vk VerifyingKeyBytes) {
+	t.Helper()
+	var s ClientSubmission
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySubmission(vk, &s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrepareSubmission(t *testing.T) {
+	client, pk, vk := prepareInputs(t)
+	submission, err := PrepareSubmission(client, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyPrepared(t, submission, vk)
+
+	if _, err := PrepareSubmission(client, pk[:len(pk)/2]); err == nil {
+		t.Fatal("no error for a truncated proving key")
+	}
+	if _, err := PrepareSubmission([]byte("{}"), pk); err == nil {
+		t.Fatal("no error for an empty client")
+	}
+}
+
+// buildWasm compiles the wasm client to dir and returns its path.
+func buildWasm(t *testing.T, dir string) string {
+	t.Helper()
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go tool is not in PATH")
+	}
+	out := filepath.Join(dir, "client.wasm")
+	cmd := exec.Command(goTool, "build", "-o", out, ".")
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("GOOS=js GOARCH=wasm go build: %v\n%s", err, output)
+	}
+	return out
+}
+
+func TestWasmBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the wasm client")
+	}
+	buildWasm(t, t.TempDir())
+}
+
+// TestPrepareSubmissionWasm proves a ballot with the wasm client, run by node
+// with testdata/wasm_roundtrip.js, and verifies the proof natively.
+func TestPrepareSubmissionWasm(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the wasm client")
+	}
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not in PATH")
+	}
+	wasmExec := filepath.Join(runtime.GOROOT(), "lib", "wasm", "wasm_exec.js")
+	if _, err := os.Stat(wasmExec); err != nil {
+		// before go 1.24
+		wasmExec = filepath.Join(runtime.GOROOT(), "misc", "wasm", "wasm_exec.js")
+	}
+	dir := t.TempDir()
+	wasm := buildWasm(t, dir)
+
+	client, pk, vk := prepareInputs(t)
+	clientFile := filepath.Join(dir, "client.json")
+	pkFile := filepath.Join(dir, "groth16.pk")
+	outFile := filepath.Join(dir, "submission.json")
+	if err := os.WriteFile(clientFile, client, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pkFile, pk, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(node, filepath.Join("testdata", "wasm_roundtrip.js"), wasmExec, wasm, clientFile, pkFile, outFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("node: %v\n%s", err, output)
+	}
+	submission, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyPrepared(t, submission, vk)
+}
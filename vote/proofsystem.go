@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// ProofBytes is a serialized proof.
+type ProofBytes []byte
+
+// VerifyingKeyBytes is a serialized verifying key.
+type VerifyingKeyBytes []byte
+
+// ProofSystem is the SNARK backend of the vote protocol. Setup keeps the
+// proving key inside the implementation, so Prove only needs the constraint
+// system and the witness.
+type ProofSystem interface {
+	// Name is the name of the backend in the benchmark output.
+	Name() string
+	// Backend identifies the backend in the wire format of the submissions.
+	Backend() backend.ID
+	// Curve is the curve the circuit is compiled, set up and proved on.
+	Curve() ecc.ID
+	// Compile compiles the circuit with the builder of the backend.
+	Compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error)
+	// Setup generates the key pair of ccs. CompileAndSetup caches it in
+	// KeyDir.
+	Setup(ccs constraint.ConstraintSystem) (VerifyingKeyBytes, error)
+	// ProvingKeySize is the serialized size of the proving key set up last.
+	ProvingKeySize() int
+	Prove(ccs constraint.ConstraintSystem, witness witness.Witness) (ProofBytes, error)
+	Verify(proof ProofBytes, vk VerifyingKeyBytes, publicWitness witness.Witness) error
+}
+
+// BatchVerifier is implemented by the proof systems able to check many
+// proofs at once. A failed batch says nothing about which proof is invalid.
+type BatchVerifier interface {
+	BatchVerify(proofs []ProofBytes, vk VerifyingKeyBytes, publicWitnesses []witness.Witness) error
+}
+
+// Groth16System is the groth16 ProofSystem.
+type Groth16System struct {
+	curve  ecc.ID
+	pk     groth16.ProvingKey
+	pkSize int
+
+	// parsed verifying keys, keyed by their serialization
+	mu  sync.Mutex
+	vks map[string]groth16.VerifyingKey
+}
+
+func NewGroth16System(curve ecc.ID) *Groth16System {
+	return &Groth16System{curve: curve, vks: make(map[string]groth16.VerifyingKey)}
+}
+
+func (s *Groth16System) Name() string { return "Groth16" }
+
+func (s *Groth16System) Backend() backend.ID { return backend.GROTH16 }
+
+func (s *Groth16System) Curve() ecc.ID { return s.curve }
+
+func (s *Groth16System) Compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	return gnarkshim.CompileR1CS(s.curve, circuit)
+}
+
+func (s *Groth16System) Setup(ccs constraint.ConstraintSystem) (VerifyingKeyBytes, error) {
+	pk, vk, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		return nil, err
+	}
+	return s.use(pk, vk)
+}
+
+func (s *Groth16System) setupKeys(ccs constraint.ConstraintSystem) (*Keys, error) {
+	pk, vk, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{CCS: ccs, PK: pk, VK: vk}, nil
+}
+
+func (s *Groth16System) useKeys(k *Keys) (VerifyingKeyBytes, error) {
+	pk, ok := k.PK.(groth16.ProvingKey)
+	if !ok {
+		return nil, errors.New("groth16: the keys belong to another backend")
+	}
+	vk, ok := k.VK.(groth16.VerifyingKey)
+	if !ok {
+		return nil, errors.New("groth16: the keys belong to another backend")
+	}
+	return s.use(pk, vk)
+}
+
+// use makes s prove with pk and returns the serialization of vk.
+func (s *Groth16System) use(pk groth16.ProvingKey, vk groth16.VerifyingKey) (VerifyingKeyBytes, error) {
+	var buf bytes.Buffer
+	if _, err := pk.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	s.pk, s.pkSize = pk, buf.Len()
+	buf.Reset()
+	if _, err := vk.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Groth16System) ProvingKeySize() int { return s.pkSize }
+
+func (s *Groth16System) Prove(ccs constraint.ConstraintSystem, witness witness.Witness) (ProofBytes, error) {
+	proof, err := gnarkshim.ProveGroth16(ccs, s.pk, witness)
+	if err != nil {
+		return nil, fmt.Errorf("groth16 prove: %w", err)
+	}
+	var buf bytes.Buffer
+	if _, err := proof.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Groth16System) verifyingKey(vk VerifyingKeyBytes) (groth16.VerifyingKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _vk, ok := s.vks[string(vk)]; ok {
+		return _vk, nil
+	}
+	_vk := groth16.NewVerifyingKey(s.curve)
+	if _, err := _vk.ReadFrom(bytes.NewReader(vk)); err != nil {
+		return nil, fmt.Errorf("groth16 verifying key: %w", err)
+	}
+	s.vks[string(vk)] = _vk
+	return _vk, nil
+}
+
+func (s *Groth16System) proof(proof ProofBytes) (groth16.Proof, error) {
+	_proof := groth16.NewProof(s.curve)
+	if _, err := _proof.ReadFrom(bytes.NewReader(proof)); err != nil {
+		return nil, fmt.Errorf("groth16 proof: %w", err)
+	}
+	return _proof, nil
+}
+
+func (s *Groth16System) Verify(proof ProofBytes, vk VerifyingKeyBytes, publicWitness witness.Witness) error {
+	_vk, err := s.verifyingKey(vk)
+	if err != nil {
+		return err
+	}
+	_proof, err := s.proof(proof)
+	if err != nil {
+		return err
+	}
+	return gnarkshim.VerifyGroth16(_proof, _vk, publicWitness)
+}
+
+// BatchVerify runs BatchVerifyGroth16 on the deserialized proofs.
+func (s *Groth16System) BatchVerify(proofs []ProofBytes, vk VerifyingKeyBytes, publicWitnesses []witness.Witness) error {
+	_vk, err := s.verifyingKey(vk)
+	if err != nil {
+		return err
+	}
+	_proofs := make([]groth16.Proof, len(proofs))
+	_publicWitnesses := make([]*witness.Witness, len(proofs))
+	for i := 0; i < len(proofs); i++ {
+		if _proofs[i], err = s.proof(proofs[i]); err != nil {
+			return fmt.Errorf("proof %v: %w", i, err)
+		}
+		_publicWitnesses[i] = &publicWitnesses[i]
+	}
+	return batchCheckGroth16(_proofs, _vk, _publicWitnesses)
+}
+
+// NewProofSystem returns a fresh ProofSystem for the backend id on curve.
+func NewProofSystem(id backend.ID, curve ecc.ID) (ProofSystem, error) {
+	if _, err := NewField(curve); err != nil {
+		return nil, err
+	}
+	switch id {
+	case backend.GROTH16:
+		return NewGroth16System(curve), nil
+	case backend.PLONK:
+		return newPlonkSystem(curve)
+	}
+	return nil, fmt.Errorf("unsupported backend %v", id)
+}
+
+// ParseBackend returns the backend named name, "groth16" or "plonk".
+func ParseBackend(name string) (backend.ID, error) {
+	for _, id := range []backend.ID{backend.GROTH16, backend.PLONK} {
+		if id.String() == name {
+			return id, nil
+		}
+	}
+	return backend.UNKNOWN, fmt.Errorf("unknown backend %q", name)
+}
+
+// GenProof proves the assignment with system and returns the proof together
+// with its public witness. The returned error names the stage that failed
+// and, with DebugSolver, the invariant the assignment breaks.
+func GenProof(system ProofSystem, assignment VoteCircuit, ccs constraint.ConstraintSystem) (ProofBytes, witness.Witness, error) {
+	// witness definition
+	witness, err := gnarkshim.NewWitness(system.Curve(), &assignment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("witness generation: %w", err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return nil, nil, fmt.Errorf("public witness extraction: %w", err)
+	}
+
+	proof, err := system.Prove(ccs, witness)
+	if err != nil {
+		if DebugSolver {
+			if failure := diagnoseAssignment(system.Curve(), &assignment); failure != nil {
+				return nil, nil, fmt.Errorf("%w: %w", err, failure)
+			}
+		}
+		return nil, nil, err
+	}
+	return proof, publicWitness, nil
+}
+
+// expectedPublicWitness is the public witness, marshalled, of a vote proof
+// for candidateNum candidates with the given challenge, product and
+// commitment. The dummies are secret and do not change it.
+func expectedPublicWitness(curve ecc.ID, candidateNum int, publicR fr_bn254.Element, publicProd *big.Int, publicCom *big.Int) ([]byte, error) {
+	assignment := NewVoteCircuit(candidateNum, 0)
+	assignment.PublicR = frontendVariable(&publicR)
+	assignment.PublicProd = frontend.Variable(publicProd)
+	assignment.PublicCommitment = frontend.Variable(publicCom)
+	publicWitness, err := gnarkshim.NewPublicWitness(curve, &assignment)
+	if err != nil {
+		return nil, err
+	}
+	return publicWitness.MarshalBinary()
+}
@@ -0,0 +1,117 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gnarkshim"
+)
+
+// benchCandidateNums are the numbers of candidates the proof benchmarks
+// scale over, up to MaxCandidateNum.
+var benchCandidateNums = append([]int{3, 5, 10}, largeCandidateNums...)
+
+// benchDummyNum is the number of dummies of the proof benchmarks.
+const benchDummyNum = 8
+
+// voteProofSetup is a set up vote circuit of candidateNum candidates, the
+// witness of one client, and its proof.
+type voteProofSetup struct {
+	ccs           constraint.ConstraintSystem
+	vk            VerifyingKeyBytes
+	fullWitness   witness.Witness
+	publicWitness witness.Witness
+	proof         ProofBytes
+}
+
+// setupVoteProof compiles and sets up the vote circuit of candidateNum
+// candidates with system, and proves one client.
+func setupVoteProof(b *testing.B, system ProofSystem, candidateNum int) voteProofSetup {
+	defer func(c int) { CandidateNum = c }(CandidateNum)
+	CandidateNum = candidateNum
+
+	circuit := NewVoteCircuit(candidateNum, benchDummyNum)
+	ccs, err := system.Compile(&circuit)
+	if err != nil {
+		b.Fatal(err)
+	}
+	vk, err := system.Setup(ccs)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var client ClientState
+	client.InitRand(benchrand.New(1), bn254Field{}, benchDummyNum)
+	assignment := client.GenAssignment(randomFr()).Circuit
+	s := voteProofSetup{ccs: ccs, vk: vk}
+	if s.fullWitness, err = gnarkshim.NewWitness(system.Curve(), &assignment); err != nil {
+		b.Fatal(err)
+	}
+	if s.publicWitness, err = s.fullWitness.Public(); err != nil {
+		b.Fatal(err)
+	}
+	if s.proof, err = system.Prove(ccs, s.fullWitness); err != nil {
+		b.Fatal(err)
+	}
+	return s
+}
+
+// benchmarkVoteProve measures the proof of one client, for every number of
+// candidates of benchCandidateNums. The compilation and the setup are not
+// measured.
+func benchmarkVoteProve(b *testing.B, newSystem func() ProofSystem) {
+	for _, c := range benchCandidateNums {
+		b.Run(fmt.Sprintf("candidates=%v", c), func(b *testing.B) {
+			system := newSystem()
+			s := setupVoteProof(b, system, c)
+			b.ResetTimer()
+			for k := 0; k < b.N; k++ {
+				if _, err := system.Prove(s.ccs, s.fullWitness); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(s.ccs.GetNbConstraints()), "constraints")
+		})
+	}
+}
+
+// benchmarkVoteVerify measures the verification of one proof, for every
+// number of candidates of benchCandidateNums.
+func benchmarkVoteVerify(b *testing.B, newSystem func() ProofSystem) {
+	for _, c := range benchCandidateNums {
+		b.Run(fmt.Sprintf("candidates=%v", c), func(b *testing.B) {
+			system := newSystem()
+			s := setupVoteProof(b, system, c)
+			b.ResetTimer()
+			for k := 0; k < b.N; k++ {
+				if err := system.Verify(s.proof, s.vk, s.publicWitness); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(s.proof)), "proof-bytes")
+		})
+	}
+}
+
+func BenchmarkVoteProveGroth16(b *testing.B) {
+	benchmarkVoteProve(b, func() ProofSystem { return NewGroth16System(ecc.BN254) })
+}
+
+func BenchmarkVoteProvePlonk(b *testing.B) {
+	benchmarkVoteProve(b, func() ProofSystem { return NewPlonkSystem(ecc.BN254) })
+}
+
+func BenchmarkVoteVerifyGroth16(b *testing.B) {
+	benchmarkVoteVerify(b, func() ProofSystem { return NewGroth16System(ecc.BN254) })
+}
+
+func BenchmarkVoteVerifyPlonk(b *testing.B) {
+	benchmarkVoteVerify(b, func() ProofSystem { return NewPlonkSystem(ecc.BN254) })
+}
@@ -0,0 +1,423 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+
+	"example/verification/internal/hasher"
+)
+
+// A pilot provisions its voters before the election with "vote admin
+// provision". Every client gets a credential: a client ID, an Ed25519 key
+// pair and a master secret the salts of its commitments are derived from.
+// The server gets the registry of the IDs and public keys:
+//
+//	registry.json              the registry, for the -registry of the server
+//	bundles/client-<id>.bundle the bundle of a client, encrypted
+//	bundle-keys.txt            the key of every bundle, in hex
+//
+// A bundle is the credential of a client together with the ParamsBundle of
+// the election, in a tar archive sealed with XChaCha20-Poly1305 under a key
+// of its own. The keys are delivered to the clients out of band.
+//
+// A server with a registry sets Identify in the hello: every client answers
+// with msgIdentify, its ID and the signature of the token of its connection
+// and of the seed commitment of the election. The clients whose signature
+// does not verify against the registry, or whose ID is already taken, are
+// dismissed before the commit round, so only registered clients submit.
+
+var (
+	ErrRegistry     = errors.New("registry: invalid registry")
+	ErrUnregistered = errors.New("registry: client not registered")
+	ErrBundle       = errors.New("bundle: cannot open the bundle")
+	// ErrNotProvisioned is returned to a client without a bundle by a server
+	// with a registry.
+	ErrNotProvisioned = errors.New("the server only accepts registered clients, run the client with its -bundle")
+)
+
+// masterSecretSize is the size of the master secret of a credential.
+const masterSecretSize = 32
+
+// Credential is the identity of a provisioned client.
+type Credential struct {
+	ClientID     uint32             `json:"clientID"`
+	PublicKey    ed25519.PublicKey  `json:"publicKey"`
+	PrivateKey   ed25519.PrivateKey `json:"privateKey"`
+	MasterSecret []byte             `json:"masterSecret"`
+}
+
+func (c *Credential) validate() error {
+	if len(c.PrivateKey) != ed25519.PrivateKeySize || len(c.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("credential of client %v: keys of %v and %v bytes", c.ClientID, len(c.PrivateKey), len(c.PublicKey))
+	}
+	if !c.PublicKey.Equal(c.PrivateKey.Public()) {
+		return fmt.Errorf("credential of client %v: the public key is not the one of the private key", c.ClientID)
+	}
+	if len(c.MasterSecret) != masterSecretSize {
+		return fmt.Errorf("credential of client %v: master secret of %v bytes", c.ClientID, len(c.MasterSecret))
+	}
+	return nil
+}
+
+// Salt derives from the master secret the salt of the commitment of the
+// client to the election electionID whose server committed to its seed with
+// seedCommitment, so that every run of an election gets another salt.
+func (c *Credential) Salt(electionID uint64, seedCommitment [32]byte) fr_bn254.Element {
+	info := []byte("vote salt")
+	info = binary.BigEndian.AppendUint64(info, electionID)
+	info = append(info, seedCommitment[:]...)
+	// 16 bytes more than the field, for a negligible bias of the reduction
+	var b [fr_bn254.Bytes + 16]byte
+	io.ReadFull(hkdf.New(sha256.New, c.MasterSecret, nil, info), b[:])
+	var salt fr_bn254.Element
+	salt.SetBytes(b[:])
+	return salt
+}
+
+// identifyMessage is the message a client signs to identify on the
+// connection of token to the election electionID with seed commitment
+// seedCommitment.
+func identifyMessage(electionID uint64, seedCommitment [32]byte, token [32]byte) []byte {
+	msg := []byte("vote identify")
+	msg = binary.BigEndian.AppendUint64(msg, electionID)
+	msg = append(msg, seedCommitment[:]...)
+	return append(msg, token[:]...)
+}
+
+// RegisteredClient is the entry of a client in a Registry.
+type RegisteredClient struct {
+	ClientID  uint32            `json:"clientID"`
+	PublicKey ed25519.PublicKey `json:"publicKey"`
+}
+
+// Registry is the list of the clients provisioned for an election.
+type Registry struct {
+	ElectionID uint64             `json:"electionID"`
+	Clients    []RegisteredClient `json:"clients"`
+
+	keys map[uint32]ed25519.PublicKey
+}
+
+// NewRegistry returns the registry of clients for the election electionID.
+func NewRegistry(electionID uint64, clients []RegisteredClient) (*Registry, error) {
+	r := &Registry{ElectionID: electionID, Clients: clients, keys: make(map[uint32]ed25519.PublicKey, len(clients))}
+	for _, c := range clients {
+		if _, ok := r.keys[c.ClientID]; ok {
+			return nil, fmt.Errorf("%w: client %v registered twice", ErrRegistry, c.ClientID)
+		}
+		if len(c.PublicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: client %v: public key of %v bytes", ErrRegistry, c.ClientID, len(c.PublicKey))
+		}
+		r.keys[c.ClientID] = c.PublicKey
+	}
+	return r, nil
+}
+
+// LoadRegistry reads the registry saved to name.
+func LoadRegistry(name string) (*Registry, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var doc Registry
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrRegistry, name, err)
+	}
+	return NewRegistry(doc.ElectionID, doc.Clients)
+}
+
+// Save writes the registry to name, in JSON.
+func (r *Registry) Save(name string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0644)
+}
+
+// Verify checks that sig is the signature of msg by the registered client id.
+func (r *Registry) Verify(id uint32, msg []byte, sig []byte) error {
+	key, ok := r.keys[id]
+	if !ok {
+		return fmt.Errorf("%w: unknown client %v", ErrUnregistered, id)
+	}
+	if !ed25519.Verify(key, msg, sig) {
+		return fmt.Errorf("%w: bad signature of client %v", ErrUnregistered, id)
+	}
+	return nil
+}
+
+// ParamsBundle are the parameters of the election a provisioned client needs
+// besides what the server announces in the hello.
+type ParamsBundle struct {
+	ElectionID uint64 `json:"electionID"`
+	// Server is the address of the server.
+	Server       string `json:"server"`
+	Backend      string `json:"backend"`
+	Curve        string `json:"curve"`
+	Hash         string `json:"hash"`
+	CandidateNum int    `json:"candidates"`
+}
+
+func (p *ParamsBundle) validate() error {
+	if _, err := ParseBackend(p.Backend); err != nil {
+		return err
+	}
+	if _, err := ParseCurve(p.Curve); err != nil {
+		return err
+	}
+	if _, err := hasher.Parse(p.Hash); err != nil {
+		return err
+	}
+	if p.CandidateNum < 2 || p.CandidateNum > MaxCandidateNum {
+		return fmt.Errorf("%v candidates, expected 2 to %v", p.CandidateNum, MaxCandidateNum)
+	}
+	return nil
+}
+
+// check compares the parameters with the ones the server announces.
+func (p *ParamsBundle) check(hello *helloMsg) error {
+	if hello.Backend.String() != p.Backend || hello.Curve.String() != p.Curve || int(hello.CandidateNum) != p.CandidateNum {
+		return fmt.Errorf("the server runs %v on %v with %v candidates, the bundle %v on %v with %v", hello.Backend, hello.Curve, hello.CandidateNum, p.Backend, p.Curve, p.CandidateNum)
+	}
+	return nil
+}
+
+// Bundle is what a provisioned client receives.
+type Bundle struct {
+	Credential Credential
+	Params     ParamsBundle
+}
+
+// Provision generates the credentials of n clients, with IDs 0 to n-1, and
+// the registry of the election electionID, drawing the keys from rnd.
+func Provision(rnd io.Reader, n int, electionID uint64) ([]Credential, *Registry, error) {
+	creds := make([]Credential, n)
+	entries := make([]RegisteredClient, n)
+	for i := range creds {
+		pub, priv, err := ed25519.GenerateKey(rnd)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret := make([]byte, masterSecretSize)
+		if _, err := io.ReadFull(rnd, secret); err != nil {
+			return nil, nil, err
+		}
+		creds[i] = Credential{ClientID: uint32(i), PublicKey: pub, PrivateKey: priv, MasterSecret: secret}
+		entries[i] = RegisteredClient{ClientID: uint32(i), PublicKey: pub}
+	}
+	registry, err := NewRegistry(electionID, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	return creds, registry, nil
+}
+
+// bundleMagic starts a sealed bundle and is authenticated with it.
+const bundleMagic = "vote bundle 1\n"
+
+// The files of the archive of a bundle.
+const (
+	bundleCredential = "credential.json"
+	bundleParams     = "params.json"
+)
+
+// SealBundle archives b and encrypts the archive with key, of
+// chacha20poly1305.KeySize bytes, drawing the nonce from rnd.
+func SealBundle(rnd io.Reader, key []byte, b *Bundle) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, f := range []struct {
+		name string
+		v    any
+	}{{bundleCredential, &b.Credential}, {bundleParams, &b.Params}} {
+		data, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0600, Size: int64(len(data))}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, len(bundleMagic)+aead.NonceSize(), len(bundleMagic)+aead.NonceSize()+archive.Len()+aead.Overhead())
+	copy(sealed, bundleMagic)
+	nonce := sealed[len(bundleMagic):]
+	if _, err := io.ReadFull(rnd, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(sealed, nonce, archive.Bytes(), []byte(bundleMagic)), nil
+}
+
+// OpenBundle decrypts with key and checks the bundle sealed by SealBundle.
+func OpenBundle(key []byte, sealed []byte) (*Bundle, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBundle, err)
+	}
+	if len(sealed) < len(bundleMagic)+aead.NonceSize() || string(sealed[:len(bundleMagic)]) != bundleMagic {
+		return nil, fmt.Errorf("%w: not a bundle", ErrBundle)
+	}
+	nonce := sealed[len(bundleMagic) : len(bundleMagic)+aead.NonceSize()]
+	archive, err := aead.Open(nil, nonce, sealed[len(bundleMagic)+aead.NonceSize():], []byte(bundleMagic))
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong key or corrupted bundle", ErrBundle)
+	}
+
+	var b Bundle
+	files := map[string]any{bundleCredential: &b.Credential, bundleParams: &b.Params}
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBundle, err)
+		}
+		v, ok := files[hdr.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected file %v", ErrBundle, hdr.Name)
+		}
+		delete(files, hdr.Name)
+		dec := json.NewDecoder(tr)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			return nil, fmt.Errorf("%w: %v: %v", ErrBundle, hdr.Name, err)
+		}
+	}
+	for name := range files {
+		return nil, fmt.Errorf("%w: missing %v", ErrBundle, name)
+	}
+	if err := b.Credential.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBundle, err)
+	}
+	if err := b.Params.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBundle, err)
+	}
+	return &b, nil
+}
+
+// LoadBundle opens the bundle saved to name with the key hexKey of
+// bundle-keys.txt.
+func LoadBundle(name string, hexKey string) (*Bundle, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(hexKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: key: %v", ErrBundle, err)
+	}
+	sealed, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return OpenBundle(key, sealed)
+}
+
+// bundleName is the name of the bundle of client id in the directory of a
+// provisioning.
+func bundleName(dir string, id uint32) string {
+	return filepath.Join(dir, "bundles", fmt.Sprintf("client-%v.bundle", id))
+}
+
+// WriteProvision provisions n clients for the election of params and writes
+// the registry, the bundles and their keys to dir, see provision.go. The keys
+// and the nonces are drawn from rnd.
+func WriteProvision(rnd io.Reader, dir string, n int, params ParamsBundle) (*Registry, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	creds, registry, err := Provision(rnd, n, params.ElectionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "bundles"), 0700); err != nil {
+		return nil, err
+	}
+	if err := registry.Save(filepath.Join(dir, "registry.json")); err != nil {
+		return nil, err
+	}
+	var keys bytes.Buffer
+	for i := range creds {
+		key := make([]byte, chacha20poly1305.KeySize)
+		if _, err := io.ReadFull(rnd, key); err != nil {
+			return nil, err
+		}
+		sealed, err := SealBundle(rnd, key, &Bundle{Credential: creds[i], Params: params})
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(bundleName(dir, creds[i].ClientID), sealed, 0600); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&keys, "%v %x\n", creds[i].ClientID, key)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bundle-keys.txt"), keys.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// adminMain is the entry point of "vote admin".
+func adminMain(args []string) int {
+	if len(args) == 0 || args[0] != "provision" {
+		fmt.Fprintf(os.Stderr, "usage: vote admin provision [flags]\n")
+		return 2
+	}
+	fs := flag.NewFlagSet("admin provision", flag.ExitOnError)
+	n := fs.Int("clients", 200, "number of clients to provision")
+	dir := fs.String("out", "pilot", "directory to write the registry, the bundles and their keys to")
+	var params ParamsBundle
+	fs.Uint64Var(&params.ElectionID, "election-id", ElectionID, "identifier of the election")
+	fs.StringVar(&params.Server, "server", "localhost:7000", "address of the server the clients connect to")
+	fs.StringVar(&params.Backend, "backend", "groth16", "proof system, groth16 or plonk")
+	fs.StringVar(&params.Curve, "curve", Curve.String(), "curve of the proof system, bn254 or bls12_381")
+	fs.StringVar(&params.Hash, "hash", Params.Hash.String(), "hash of the commitments, mimc or poseidon (bn254 only)")
+	fs.IntVar(&params.CandidateNum, "candidates", CandidateNum, "number of candidates")
+	fs.Parse(args[1:])
+	if *n < 1 {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v clients\n", *n)
+		return 2
+	}
+	if err := params.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+
+	if _, err := WriteProvision(rand.Reader, *dir, *n, params); err != nil {
+		log.Printf("admin: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%v clients provisioned in %v: registry.json for the -registry of the server, bundles and bundle-keys.txt for the clients\n", *n, *dir)
+	return 0
+}
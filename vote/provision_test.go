@@ -0,0 +1,225 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"example/verification/internal/benchrand"
+)
+
+func testParams() ParamsBundle {
+	return ParamsBundle{ElectionID: 7, Server: "localhost:7000", Backend: "groth16", Curve: "bn254", Hash: "mimc", CandidateNum: 3}
+}
+
+func TestProvision(t *testing.T) {
+	creds, registry, err := Provision(benchrand.New(1), 5, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 5 || len(registry.Clients) != 5 || registry.ElectionID != 7 {
+		t.Fatalf("got %v credentials and %v registered clients", len(creds), len(registry.Clients))
+	}
+	msg := []byte("message")
+	for i := range creds {
+		if creds[i].ClientID != uint32(i) {
+			t.Fatalf("credential %v: client ID %v", i, creds[i].ClientID)
+		}
+		if err := creds[i].validate(); err != nil {
+			t.Fatal(err)
+		}
+		sig := ed25519.Sign(creds[i].PrivateKey, msg)
+		if err := registry.Verify(creds[i].ClientID, msg, sig); err != nil {
+			t.Fatalf("client %v: %v", i, err)
+		}
+		// the signature of a client does not pass for another
+		if err := registry.Verify((creds[i].ClientID+1)%5, msg, sig); !errors.Is(err, ErrUnregistered) {
+			t.Fatalf("client %v signing for another: got %v, expected ErrUnregistered", i, err)
+		}
+	}
+	if err := registry.Verify(5, msg, ed25519.Sign(creds[0].PrivateKey, msg)); !errors.Is(err, ErrUnregistered) {
+		t.Fatalf("unknown client: got %v, expected ErrUnregistered", err)
+	}
+}
+
+func TestCredentialSalt(t *testing.T) {
+	creds, _, err := Provision(benchrand.New(1), 2, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := [32]byte{1}
+	salt := creds[0].Salt(7, seed)
+	if again := creds[0].Salt(7, seed); !again.Equal(&salt) {
+		t.Fatal("the salt is not deterministic")
+	}
+	other := [32]byte{2}
+	for name, s := range map[string]func() any{
+		"another election": func() any { return creds[0].Salt(8, seed) },
+		"another seed":     func() any { return creds[0].Salt(7, other) },
+		"another client":   func() any { return creds[1].Salt(7, seed) },
+	} {
+		if reflect.DeepEqual(s(), salt) {
+			t.Fatalf("%v: same salt", name)
+		}
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	creds, _, err := Provision(rand.Reader, 1, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Bundle{Credential: creds[0], Params: testParams()}
+	key := make([]byte, 32)
+	rand.Read(key)
+	sealed, err := SealBundle(rand.Reader, key, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := OpenBundle(key, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(opened, b) {
+		t.Fatalf("opened %+v, sealed %+v", *opened, *b)
+	}
+
+	wrongKey := append([]byte(nil), key...)
+	wrongKey[0] ^= 1
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	for name, tc := range map[string]struct{ key, sealed []byte }{
+		"wrong key": {wrongKey, sealed},
+		"tampered":  {key, tampered},
+		"truncated": {key, sealed[:len(bundleMagic)+10]},
+		"short key": {key[:16], sealed},
+	} {
+		if _, err := OpenBundle(tc.key, tc.sealed); !errors.Is(err, ErrBundle) {
+			t.Fatalf("%v: got %v, expected ErrBundle", name, err)
+		}
+	}
+}
+
+// TestWriteProvision provisions a pilot and opens the bundle of every client
+// with its key, as the clients do.
+func TestWriteProvision(t *testing.T) {
+	dir := t.TempDir()
+	registry, err := WriteProvision(rand.Reader, dir, 3, testParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRegistry(filepath.Join(dir, "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, registry) {
+		t.Fatalf("loaded %+v, provisioned %+v", *loaded, *registry)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "bundle-keys.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	opened := 0
+	msg := []byte("message")
+	for scanner := bufio.NewScanner(f); scanner.Scan(); opened++ {
+		id, key, _ := strings.Cut(scanner.Text(), " ")
+		n, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := LoadBundle(bundleName(dir, uint32(n)), key)
+		if err != nil {
+			t.Fatalf("client %v: %v", id, err)
+		}
+		if b.Credential.ClientID != uint32(n) || b.Params != testParams() {
+			t.Fatalf("client %v: got the bundle of client %v, params %+v", id, b.Credential.ClientID, b.Params)
+		}
+		if err := loaded.Verify(b.Credential.ClientID, msg, ed25519.Sign(b.Credential.PrivateKey, msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if opened != 3 {
+		t.Fatalf("%v bundle keys, expected 3", opened)
+	}
+
+	params := testParams()
+	params.Curve = "bw6_761"
+	if _, err := WriteProvision(rand.Reader, t.TempDir(), 3, params); err == nil {
+		t.Fatal("an unsupported curve was provisioned")
+	}
+}
+
+func TestLoadRegistryInvalid(t *testing.T) {
+	_, registry, err := Provision(rand.Reader, 2, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown field": `{"electionID": 7, "clients": [], "admins": []}`,
+		"short key":     `{"electionID": 7, "clients": [{"clientID": 1, "publicKey": "AAAA"}]}`,
+	} {
+		file := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".json")
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRegistry(file); !errors.Is(err, ErrRegistry) {
+			t.Fatalf("%v: got %v, expected ErrRegistry", name, err)
+		}
+	}
+	clients := append(registry.Clients, registry.Clients[0])
+	if _, err := NewRegistry(7, clients); !errors.Is(err, ErrRegistry) {
+		t.Fatalf("a client registered twice: got %v, expected ErrRegistry", err)
+	}
+}
+
+// TestNetworkRegistry runs an election restricted to a registry: the
+// provisioned clients are counted, a client without a bundle and one with
+// the bundle of another pilot are refused.
+func TestNetworkRegistry(t *testing.T) {
+	creds, registry, err := Provision(rand.Reader, 2, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, _, err := Provision(rand.Reader, 1, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundles := []*Bundle{
+		{Credential: creds[0], Params: testParams()},
+		{Credential: creds[1], Params: testParams()},
+		nil,
+		{Credential: foreign[0], Params: testParams()},
+	}
+	res, errs := runProvisionedElection(t, 4, bundles, registry, 10*time.Second, "", nil)
+	for i := 0; i < 2; i++ {
+		if errs[i] != nil {
+			t.Errorf("registered client %v: %v", i, errs[i])
+		}
+	}
+	if !errors.Is(errs[2], ErrNotProvisioned) {
+		t.Errorf("client without a bundle: got %v, expected ErrNotProvisioned", errs[2])
+	}
+	if errs[3] == nil {
+		t.Error("the client of another pilot took part")
+	}
+	if len(res.Counted) != 2 || !res.Tally.Consistent(2) || len(res.Unregistered) != 2 {
+		t.Fatalf("counted %v clients, refused %v, tally %v", len(res.Counted), res.Unregistered, res.Tally)
+	}
+	// the refused clients are not dropouts
+	if len(res.Dropped) != 0 {
+		t.Fatalf("dropped %v", res.Dropped)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+	"example/verification/internal/hasher"
+)
+
+// In quadratic voting each voter has a budget of voice credits and gives
+// candidate i a weight k_i for k_i^2 of them, spending the whole budget. The
+// client sends the shuffler one value per candidate,
+// 4 * candidateNum^2 + i * 2^QuadraticWeightBits + k_i, past the plurality
+// votes of plurality.go. The weight can exceed candidateNum, so the value is
+// not (first, second) packed as first * candidateNum + second with second a
+// weight: the pair is the quotient and the remainder of the value by
+// candidateNum, which the server packs back into the value.
+
+// QuadraticWeightBits is the size of a quadratic weight: the circuit checks
+// that every weight is in [0, 2^QuadraticWeightBits).
+const QuadraticWeightBits = 16
+
+// ErrBudget is returned when the weights of a quadratic ballot do not spend
+// the budget.
+var ErrBudget = errors.New("quadratic ballot: the sum of the squared weights is not the budget")
+
+// QuadraticPublic are the public variables of QuadraticVoteCircuit, in the
+// order of the public witness.
+var QuadraticPublic = []string{"PublicR", "PublicProd", "PublicCommitment", "PublicBudget"}
+
+type QuadraticVoteCircuit struct {
+	// Weights[i] is the weight the voter gives candidate i
+	Weights []frontend.Variable
+
+	// The following are for the polynomial evaluation
+	PrivateMask frontend.Variable
+	// DummyVec are the dummies the client sends to the shuffler; the mask is
+	// their product, as the server multiplies them in. They are committed, so
+	// the mask is the one of the dummies fixed before publicR.
+	DummyVec   []frontend.Variable
+	PublicR    frontend.Variable `gnark:",public"`
+	PublicProd frontend.Variable `gnark:",public"`
+
+	// The following are for the commitment
+	PublicCommitment frontend.Variable `gnark:",public"`
+	PrivateSalt      frontend.Variable
+
+	// PublicBudget is the number of voice credits of the voter
+	PublicBudget frontend.Variable `gnark:",public"`
+
+	// Hash is the hash of the commitment, see params.go
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+func (circuit *QuadraticVoteCircuit) Define(api frontend.API) error {
+	candidateNum := len(circuit.Weights)
+
+	// the weights are small and non-negative, so that their squares sum up
+	// without wrapping around the field
+	credits := frontend.Variable(0)
+	for i := 0; i < candidateNum; i++ {
+		gadget.Label(api, gadget.Threshold).ToBinary(circuit.Weights[i], QuadraticWeightBits)
+		credits = api.Add(credits, api.Mul(circuit.Weights[i], circuit.Weights[i]))
+	}
+	gadget.Label(api, gadget.Threshold).AssertIsEqual(credits, circuit.PublicBudget)
+
+	processedVec := make([]frontend.Variable, candidateNum)
+	for i := 0; i < candidateNum; i++ {
+		processedVec[i] = api.Add(quadraticOffset(candidateNum, i), circuit.Weights[i])
+	}
+
+	// The following is for the polynomial evaluation
+	privateProd := PolyEvalInCircuit(api, processedVec, circuit.PublicR)
+	privateProd = api.Mul(privateProd, circuit.PrivateMask)
+	gadget.Label(api, gadget.Product).AssertIsEqual(privateProd, circuit.PublicProd)
+
+	dummyProd := frontend.Variable(1)
+	for i := 0; i < len(circuit.DummyVec); i++ {
+		dummyProd = api.Mul(dummyProd, circuit.DummyVec[i])
+	}
+	gadget.Label(api, gadget.Product).AssertIsEqual(circuit.PrivateMask, dummyProd)
+
+	// the commitment binds the values sent to the shuffler
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	h.Write(processedVec...)
+	h.Write(circuit.DummyVec...)
+	h.Write(circuit.PrivateMask)
+	h.Write(circuit.PrivateSalt)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicCommitment, h.Sum())
+	return nil
+}
+
+// quadraticOffset is the value sent to the shuffler for a weight of 0 to
+// candidate i.
+func quadraticOffset(candidateNum int, i int) uint64 {
+	return uint64(4*candidateNum*candidateNum) + uint64(i)<<QuadraticWeightBits
+}
+
+// NewQuadraticVoteCircuit allocates an empty quadratic circuit for
+// candidateNum candidates and dummyNum dummies, hashing with Params.Hash,
+// ready to be compiled. Its public variables are QuadraticPublic.
+func NewQuadraticVoteCircuit(candidateNum int, dummyNum int) QuadraticVoteCircuit {
+	return QuadraticVoteCircuit{
+		Weights:          make([]frontend.Variable, candidateNum),
+		PrivateMask:      0,
+		DummyVec:         make([]frontend.Variable, dummyNum),
+		PublicR:          0,
+		PublicProd:       0,
+		PublicCommitment: 0,
+		PrivateSalt:      0,
+		PublicBudget:     0,
+		Hash:             Params.Hash,
+	}
+}
+
+// QuadraticClientState is the ClientState of a quadratic ballot: its pair of
+// candidate i is the quotient and the remainder by CandidateNum of the value
+// sent to the shuffler.
+type QuadraticClientState struct {
+	ClientState
+	Budget  uint64
+	Weights []uint64
+}
+
+// Init checks that the weights, one per candidate, spend the budget, and
+// computes the pairs of the client together with dummyNum dummies and the
+// commitment to them in field.
+func (c *QuadraticClientState) Init(field Field, dummyNum uint64, budget uint64, weights []uint64) error {
+	return c.InitRand(benchrand.New(0), field, dummyNum, budget, weights)
+}
+
+// InitRand is Init drawing all the randomness of the client from rnd.
+func (c *QuadraticClientState) InitRand(rnd *benchrand.Rand, field Field, dummyNum uint64, budget uint64, weights []uint64) error {
+	if len(weights) != CandidateNum {
+		return fmt.Errorf("quadratic ballot: %v weights for %v candidates", len(weights), CandidateNum)
+	}
+	var credits uint64
+	for i, w := range weights {
+		if w >= 1<<QuadraticWeightBits {
+			return fmt.Errorf("quadratic ballot: weight %v of candidate %v does not fit in %v bits", w, i, QuadraticWeightBits)
+		}
+		credits += w * w
+	}
+	if credits != budget {
+		return fmt.Errorf("%w: %v credits spent of %v", ErrBudget, credits, budget)
+	}
+
+	c.Budget = budget
+	c.Weights = append([]uint64(nil), weights...)
+	c.SortedCandidate = nil
+	c.PairFirst = make([]fr_bn254.Element, CandidateNum)
+	c.PairSecond = make([]fr_bn254.Element, CandidateNum)
+	for i, w := range weights {
+		value := quadraticOffset(CandidateNum, i) + w
+		c.PairFirst[i] = fr_bn254.NewElement(value / uint64(CandidateNum))
+		c.PairSecond[i] = fr_bn254.NewElement(value % uint64(CandidateNum))
+	}
+	c.PrivateX = packPairs(CandidateNum, c.PairFirst, c.PairSecond)
+
+	c.initSecrets(rnd, field, dummyNum)
+	return nil
+}
+
+// GenAssignment is ClientState.GenAssignment for the quadratic ballot of the
+// client.
+func (c *QuadraticClientState) GenAssignment(publicR fr_bn254.Element) QuadraticVoteCircuit {
+	weights := make([]frontend.Variable, len(c.Weights))
+	for i := 0; i < len(weights); i++ {
+		weights[i] = c.Weights[i]
+	}
+
+	dummyVec := make([]frontend.Variable, len(c.PrivateY))
+	for i := 0; i < len(dummyVec); i++ {
+		dummyVec[i] = frontendVariable(&c.PrivateY[i])
+	}
+
+	publicProd := c.ComputePolyEval(publicR)
+
+	return QuadraticVoteCircuit{
+		Weights:          weights,
+		PrivateMask:      frontend.Variable(c.PrivateMask),
+		DummyVec:         dummyVec,
+		PublicR:          frontendVariable(&publicR),
+		PublicProd:       frontend.Variable(publicProd),
+		PublicCommitment: frontend.Variable(c.PublicCom),
+		PrivateSalt:      frontendVariable(&c.PrivateSalt),
+		PublicBudget:     c.Budget,
+	}
+}
+
+// ComputeQuadratic returns the sum of the weights of every candidate in the
+// shuffled quadratic pairs of candidateNum candidates.
+func ComputeQuadratic(shuffledPairFirst, shuffledPairSecond []fr_bn254.Element, candidateNum int) ([]uint64, error) {
+	if len(shuffledPairFirst) != len(shuffledPairSecond) {
+		return nil, fmt.Errorf("%w: %v first and %v second elements", ErrBallotKind, len(shuffledPairFirst), len(shuffledPairSecond))
+	}
+	start := quadraticOffset(candidateNum, 0)
+	end := quadraticOffset(candidateNum, candidateNum)
+	weights := make([]uint64, candidateNum)
+	for i := 0; i < len(shuffledPairFirst); i++ {
+		a, b := shuffledPairFirst[i], shuffledPairSecond[i]
+		if !a.IsUint64() || !b.IsUint64() || b.Uint64() >= uint64(candidateNum) || a.Uint64() >= end {
+			return nil, fmt.Errorf("%w: pair %v is not a quadratic weight of one of %v candidates", ErrBallotKind, i, candidateNum)
+		}
+		value := a.Uint64()*uint64(candidateNum) + b.Uint64()
+		if value < start || value >= end {
+			return nil, fmt.Errorf("%w: pair %v is not a quadratic weight of one of %v candidates", ErrBallotKind, i, candidateNum)
+		}
+		value -= start
+		weights[value>>QuadraticWeightBits] += value & (1<<QuadraticWeightBits - 1)
+	}
+	return weights, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/benchrand"
+)
+
+// quadraticWeights returns weights of CandidateNum candidates, the first ones
+// set to first.
+func quadraticWeights(first ...uint64) []uint64 {
+	weights := make([]uint64, CandidateNum)
+	copy(weights, first)
+	return weights
+}
+
+func TestQuadraticVoteCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewQuadraticVoteCircuit(CandidateNum, 4)
+
+	var client QuadraticClientState
+	if err := client.InitRand(benchrand.New(1), bn254Field{}, 4, 25, quadraticWeights(3, 4)); err != nil {
+		t.Fatal(err)
+	}
+	assignment := client.GenAssignment(randomFr())
+	assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// weights that spend another budget
+	wrong := assignment
+	wrong.PublicBudget = 24
+	assert.ProverFailed(&circuit, &wrong, test.WithCurves(ecc.BN254))
+
+	// a negative weight, whose square is in the budget
+	var minusOne fr_bn254.Element
+	minusOne.SetOne()
+	minusOne.Neg(&minusOne)
+	negative := assignment
+	negative.Weights = append(negative.Weights[:0:0], negative.Weights...)
+	negative.Weights[0] = frontendVariable(&minusOne)
+	negative.Weights[1] = 0
+	negative.PublicBudget = 1
+	assert.ProverFailed(&circuit, &negative, test.WithCurves(ecc.BN254))
+}
+
+func TestQuadraticClientInit(t *testing.T) {
+	var client QuadraticClientState
+	if err := client.Init(bn254Field{}, 2, 24, quadraticWeights(3, 4)); !errors.Is(err, ErrBudget) {
+		t.Fatalf("weights over the budget: got %v, expected ErrBudget", err)
+	}
+	if err := client.Init(bn254Field{}, 2, 9, []uint64{3}); err == nil {
+		t.Fatal("no error for a weight missing")
+	}
+	if err := client.Init(bn254Field{}, 2, 1<<32, quadraticWeights(1<<16)); err == nil {
+		t.Fatal("no error for a weight out of range")
+	}
+}
+
+func TestComputeQuadratic(t *testing.T) {
+	field := bn254Field{}
+	rnd := benchrand.New(9)
+	publicR := rnd.Fr()
+	clients := make([]ClientState, 20)
+	expected := make([]uint64, CandidateNum)
+	clientsProd := big.NewInt(1)
+	for i := range clients {
+		// weights up to 300, past the packing of the other ballots
+		weights := make([]uint64, CandidateNum)
+		var budget uint64
+		for j := range weights {
+			weights[j] = uint64(rnd.Intn(300))
+			budget += weights[j] * weights[j]
+			expected[j] += weights[j]
+		}
+		var c QuadraticClientState
+		if err := c.InitRand(rnd, field, 2, budget, weights); err != nil {
+			t.Fatal(err)
+		}
+		clientsProd = field.Mul(clientsProd, c.ComputePolyEval(publicR))
+		clients[i] = c.ClientState
+	}
+	pairFirst, pairSecond := ShufflePairs(rnd, clients)
+	dummies := ShuffleDummies(rnd, clients)
+	if ShufflerProduct(field, pairFirst, pairSecond, dummies, publicR).Cmp(clientsProd) != 0 {
+		t.Fatal("the product of the shuffled weights is not the product of the clients")
+	}
+	weights, err := ComputeQuadratic(pairFirst, pairSecond, CandidateNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(weights, expected) {
+		t.Fatalf("weights %v, expected %v", weights, expected)
+	}
+
+	var plurality PluralityClientState
+	plurality.InitRand(rnd, field, 2)
+	if _, err := ComputeQuadratic(plurality.PairFirst, plurality.PairSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("the quadratic tally of a plurality vote: got %v, expected ErrBallotKind", err)
+	}
+	if _, err := ComputeBordaScores(pairFirst, pairSecond, CandidateNum); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("the Borda tally of quadratic weights: got %v, expected ErrBallotKind", err)
+	}
+	if _, err := ComputePlurality(pairFirst, pairSecond); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("the plurality tally of quadratic weights: got %v, expected ErrBallotKind", err)
+	}
+}
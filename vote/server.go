@@ -0,0 +1,850 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	mathrand "math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/pool"
+)
+
+// ErrInconsistent is returned when the shuffled data does not match the
+// products sent by the clients.
+var ErrInconsistent = errors.New("the product from the shuffler and the product from the clients are not equal")
+
+// ErrEmptyEpoch is returned by the join round when no client joined. Run
+// turns it into an ElectionResult with Empty set.
+var ErrEmptyEpoch = errors.New("empty epoch: no client joined")
+
+// Shuffler is the shuffler endpoint of the network demo. It collects the pairs
+// and dummies of the registered clients and hands them over, shuffled, once
+// the server knows which clients finished the election.
+type Shuffler struct {
+	candidateNum int
+	dummyNum     int
+
+	mu     sync.Mutex
+	tokens map[uint32][32]byte
+	shares map[uint32]*sharesMsg
+	// mapping is the sealed mapping of the last release, see ShuffleMapping.
+	mapping *ShuffleMapping
+}
+
+// NewShuffler returns a shuffler for clients with candidateNum candidates and
+// dummyNum dummies.
+func NewShuffler(candidateNum int, dummyNum int) *Shuffler {
+	return &Shuffler{
+		candidateNum: candidateNum,
+		dummyNum:     dummyNum,
+		tokens:       make(map[uint32][32]byte),
+		shares:       make(map[uint32]*sharesMsg),
+	}
+}
+
+// Register allows the client id, authenticated by token, to send its shares.
+func (s *Shuffler) Register(id uint32, token [32]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[id] = token
+}
+
+// Serve accepts the shares of the clients on ln until ln is closed. Every
+// connection carries one msgShares and has timeout to deliver it.
+func (s *Shuffler) Serve(ln net.Listener, timeout time.Duration) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(timeout))
+			if err := s.receive(conn); err != nil {
+				log.Printf("shuffler: %v: %v\n", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (s *Shuffler) receive(conn net.Conn) error {
+	p, err := readMessage(conn, msgShares)
+	if err != nil {
+		return err
+	}
+	var m sharesMsg
+	if err := m.decode(p); err != nil {
+		return err
+	}
+	pairNum := s.candidateNum * (s.candidateNum - 1) / 2
+	if len(m.PairFirst) != pairNum || len(m.PairSecond) != pairNum || len(m.Dummies) != s.dummyNum {
+		return fmt.Errorf("client %v: %w: %v pairs and %v dummies", m.ClientID, ErrProtocol, len(m.PairFirst), len(m.Dummies))
+	}
+	// out of range candidates would still pass the product check, since only
+	// candidateNum * first + second enters it
+	for i := 0; i < pairNum; i++ {
+		if !m.PairFirst[i].IsUint64() || m.PairFirst[i].Uint64() >= uint64(s.candidateNum) ||
+			!m.PairSecond[i].IsUint64() || m.PairSecond[i].Uint64() >= uint64(s.candidateNum) {
+			return fmt.Errorf("client %v: %w: pair %v is not a pair of candidates", m.ClientID, ErrProtocol, i)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[m.ClientID]
+	if !ok || token != m.Token {
+		return fmt.Errorf("client %v: unknown client or wrong token", m.ClientID)
+	}
+	if _, ok := s.shares[m.ClientID]; ok {
+		return fmt.Errorf("client %v: shares already received", m.ClientID)
+	}
+	s.shares[m.ClientID] = &m
+	return nil
+}
+
+// Release shuffles and returns the pairs and dummies of the clients ids. The
+// clients whose shares never arrived are returned in missing and left out.
+func (s *Shuffler) Release(ids []uint32) (pairFirst []fr_bn254.Element, pairSecond []fr_bn254.Element, dummies []fr_bn254.Element, missing []uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pairOwners, dummyOwners []uint32
+	for _, id := range ids {
+		m, ok := s.shares[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		pairFirst = append(pairFirst, m.PairFirst...)
+		pairSecond = append(pairSecond, m.PairSecond...)
+		dummies = append(dummies, m.Dummies...)
+		for range m.PairFirst {
+			pairOwners = append(pairOwners, id)
+		}
+		for range m.Dummies {
+			dummyOwners = append(dummyOwners, id)
+		}
+	}
+	mathrand.Shuffle(len(pairFirst), func(i, j int) {
+		pairFirst[i], pairFirst[j] = pairFirst[j], pairFirst[i]
+		pairSecond[i], pairSecond[j] = pairSecond[j], pairSecond[i]
+		pairOwners[i], pairOwners[j] = pairOwners[j], pairOwners[i]
+	})
+	mathrand.Shuffle(len(dummies), func(i, j int) {
+		dummies[i], dummies[j] = dummies[j], dummies[i]
+		dummyOwners[i], dummyOwners[j] = dummyOwners[j], dummyOwners[i]
+	})
+	s.mapping = newShuffleMapping(pairOwners, dummyOwners)
+	return pairFirst, pairSecond, dummies, missing
+}
+
+// RevealExcluded reveals the positions in the last release of the excluded
+// clients ids. Either all of them are revealed, or none is: a bad id leaves
+// the others to a later call.
+func (s *Shuffler) RevealExcluded(ids []uint32) ([]ExclusionReveal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mapping == nil {
+		return nil, ErrMappingDiscarded
+	}
+	reveals := make([]ExclusionReveal, len(ids))
+	seen := make(map[uint32]bool, len(ids))
+	for i, id := range ids {
+		if seen[id] {
+			return nil, fmt.Errorf("client %v: %w", id, ErrAlreadyRevealed)
+		}
+		seen[id] = true
+		reveal, err := s.mapping.positions(id)
+		if err != nil {
+			return nil, err
+		}
+		reveals[i] = reveal
+	}
+	for _, id := range ids {
+		s.mapping.revealed[id] = true
+	}
+	return reveals, nil
+}
+
+// Discard drops the mapping of the last release once the tally is published.
+func (s *Shuffler) Discard() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mapping != nil {
+		s.mapping.Discard()
+	}
+}
+
+// Server runs one election of the network demo. Every round, including the
+// join, lasts at most RoundTimeout.
+type Server struct {
+	System       ProofSystem
+	ClientNum    int
+	RoundTimeout time.Duration
+	// Duplicates is how the clients sharing a commitment are handled.
+	Duplicates DuplicatePolicy
+	// EpochDir, if not empty, is where the epoch is published for the
+	// observers at the end of the election.
+	EpochDir string
+	// Registry, if not nil, restricts the election to the clients it
+	// registers, see provision.go.
+	Registry *Registry
+}
+
+// ElectionResult is the outcome of a networked election.
+type ElectionResult struct {
+	// Counted are the ids of the clients whose ballots are in the tally.
+	Counted []uint32
+	// Unregistered are the clients refused by the registry of the server.
+	Unregistered []uint32
+	// Rejected are the clients refused for a duplicate commitment, and
+	// Flagged the clients audited for one.
+	Rejected []uint32
+	Flagged  []uint32
+	// Excluded are the clients whose proof failed verification and whose
+	// data was taken out of the release, see Exclude.
+	Excluded []uint32
+	// Dropped are the clients that registered with the shuffler but did
+	// not submit, see PlanDropout.
+	Dropped []uint32
+	// BelowTarget is set when the clients that submitted need more dummies
+	// than the round has for the security target.
+	BelowTarget bool
+	Tally       TallyPairs
+	// Winner is the sole winner, or -1.
+	Winner int
+	// Smith is the Smith set of the tally, see TallyPairs.SmithSet.
+	Smith []int
+	// Borda are the Borda scores of the tally, see TallyPairs.Borda.
+	Borda []uint64
+	// Empty is set when no client joined: nothing is counted and there is
+	// no winner.
+	Empty bool
+	// WeakPrivacy is set when the counted clients leave e or fewer honest
+	// ones: the dummies no longer hide the ballots, and with a single client
+	// the tally is its ballot.
+	WeakPrivacy bool
+}
+
+// remoteClient is the server side of a client connection.
+type remoteClient struct {
+	id    uint32
+	conn  net.Conn
+	token [32]byte
+
+	commit     commitMsg
+	nonce      fr_bn254.Element
+	submission ClientSubmission
+}
+
+// round runs step on every client in parallel, each under the round deadline,
+// and returns the clients whose step succeeded. The others are dropped.
+func (s *Server) round(name string, clients []*remoteClient, step func(c *remoteClient) error) ([]*remoteClient, error) {
+	deadline := time.Now().Add(s.RoundTimeout)
+	errs := pool.RunAll(len(clients), len(clients), func(i int) error {
+		clients[i].conn.SetDeadline(deadline)
+		return step(clients[i])
+	})
+	var live []*remoteClient
+	for i := 0; i < len(clients); i++ {
+		if errs[i] != nil {
+			log.Printf("server: %v round: dropping client %v: %v\n", name, clients[i].id, errs[i])
+			clients[i].conn.Close()
+			continue
+		}
+		live = append(live, clients[i])
+	}
+	if len(live) == 0 {
+		return nil, fmt.Errorf("%v round: no client left", name)
+	}
+	return live, nil
+}
+
+// join accepts up to ClientNum clients until the round deadline.
+func (s *Server) join(ln net.Listener) ([]*remoteClient, error) {
+	type deadliner interface{ SetDeadline(time.Time) error }
+	if d, ok := ln.(deadliner); ok {
+		d.SetDeadline(time.Now().Add(s.RoundTimeout))
+	}
+	var clients []*remoteClient
+	for len(clients) < s.ClientNum {
+		conn, err := ln.Accept()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return clients, err
+		}
+		c := &remoteClient{id: uint32(len(clients)), conn: conn}
+		if _, err := rand.Read(c.token[:]); err != nil {
+			return clients, err
+		}
+		clients = append(clients, c)
+	}
+	if len(clients) == 0 {
+		return nil, ErrEmptyEpoch
+	}
+	if len(clients) < s.ClientNum {
+		log.Printf("server: join round: %v of %v clients joined\n", len(clients), s.ClientNum)
+	}
+	return clients, nil
+}
+
+// identify checks the clients against s.Registry and returns the clients
+// that signed for a registered ID not taken by another. The others are
+// dismissed and returned in unregistered.
+func (s *Server) identify(clients []*remoteClient, seedCommitment [32]byte) (live []*remoteClient, unregistered []uint32) {
+	deadline := time.Now().Add(s.RoundTimeout)
+	ids := make([]uint32, len(clients))
+	errs := pool.RunAll(len(clients), len(clients), func(i int) error {
+		c := clients[i]
+		c.conn.SetDeadline(deadline)
+		p, err := readMessage(c.conn, msgIdentify)
+		if err != nil {
+			return err
+		}
+		var m identifyMsg
+		if err := m.decode(p); err != nil {
+			return err
+		}
+		ids[i] = m.ClientID
+		return s.Registry.Verify(m.ClientID, identifyMessage(s.Registry.ElectionID, seedCommitment, c.token), m.Signature)
+	})
+	taken := make(map[uint32]bool, len(clients))
+	for i, c := range clients {
+		err := errs[i]
+		if err == nil && taken[ids[i]] {
+			err = fmt.Errorf("%w: client %v already joined", ErrUnregistered, ids[i])
+		}
+		if err != nil {
+			log.Printf("server: identify round: refusing client %v: %v\n", c.id, err)
+			unregistered = append(unregistered, c.id)
+			s.dismiss(c, err)
+			continue
+		}
+		taken[ids[i]] = true
+		live = append(live, c)
+	}
+	return live, unregistered
+}
+
+// dismiss tells c why it is dropped and hangs up.
+func (s *Server) dismiss(c *remoteClient, reason error) {
+	c.conn.SetDeadline(time.Now().Add(s.RoundTimeout))
+	res := resultMsg{Message: reason.Error()}
+	writeMessage(c.conn, msgResult, res.encode())
+	c.conn.Close()
+}
+
+// checkCommitments enforces the uniqueness of the commitments of the clients,
+// in the order they joined, and runs the open round of the flagged clients.
+// The clients rejected or failing to open are removed from clients.
+func (s *Server) checkCommitments(field Field, clients *[]*remoteClient) (rejected []uint32, flagged []uint32) {
+	set := NewCommitmentSet(s.Duplicates)
+	var unique []*remoteClient
+	for _, c := range *clients {
+		if err := set.Add(int(c.id), c.commit.PublicCom); err != nil {
+			log.Printf("server: %v\n", err)
+			rejected = append(rejected, c.id)
+			s.dismiss(c, err)
+			continue
+		}
+		unique = append(unique, c)
+	}
+	*clients = unique
+
+	isFlagged := make(map[uint32]bool)
+	for _, i := range set.Flagged() {
+		flagged = append(flagged, uint32(i))
+		isFlagged[uint32(i)] = true
+	}
+	if len(flagged) == 0 {
+		return rejected, nil
+	}
+	log.Printf("server: clients %v share a commitment and have to open it\n", flagged)
+	var audited, others []*remoteClient
+	for _, c := range *clients {
+		if isFlagged[c.id] {
+			audited = append(audited, c)
+		} else {
+			others = append(others, c)
+		}
+	}
+	// an empty result only means that no flagged client opened
+	opened, _ := s.round("open", audited, func(c *remoteClient) error {
+		if err := writeMessage(c.conn, msgOpenRequest, nil); err != nil {
+			return err
+		}
+		p, err := readMessage(c.conn, msgOpening)
+		if err != nil {
+			return err
+		}
+		o, err := decodeOpening(p, s.System.Curve())
+		if err != nil {
+			return err
+		}
+		return set.Open(field, int(c.id), o)
+	})
+	*clients = append(others, opened...)
+	sort.Slice(*clients, func(i, j int) bool { return (*clients)[i].id < (*clients)[j].id })
+	return rejected, flagged
+}
+
+// expectedPublicWitness is the public witness of a client proof with the
+// given commitment, challenge and product; the server never takes the public
+// witness sent by a client at face value.
+func (s *Server) expectedPublicWitness(c *remoteClient, publicR fr_bn254.Element) ([]byte, error) {
+	return expectedPublicWitness(s.System.Curve(), CandidateNum, publicR, c.submission.publicProd, c.commit.PublicCom)
+}
+
+// Run sets up the circuit, serves the shuffler on shufflerLn and runs one
+// election with the clients connecting on ln. Every client still connected at
+// the end receives the outcome.
+func (s *Server) Run(ln net.Listener, shufflerLn net.Listener) (_ *ElectionResult, err error) {
+	system := s.System
+	field, err := NewField(system.Curve())
+	if err != nil {
+		return nil, err
+	}
+	dummyNum, err := ComputeDummyNum(Lambda, uint64(s.ClientNum), uint64(CorruptedNum))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("server: lambda %v, n %v, t %v, Dummy Num: %v\n", Lambda, s.ClientNum, CorruptedNum, dummyNum)
+
+	circuit := NewVoteCircuit(CandidateNum, int(dummyNum))
+	ccs, vk, err := CompileAndSetup(system, &circuit, VotePublic, VoteManifest(system, int(dummyNum)))
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := CircuitChecksum(ccs)
+	if err != nil {
+		return nil, fmt.Errorf("circuit checksum: %w", err)
+	}
+	seed, err := NewServerSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	shuffler := NewShuffler(CandidateNum, int(dummyNum))
+	go shuffler.Serve(shufflerLn, s.RoundTimeout)
+	defer shufflerLn.Close()
+
+	log.Printf("server: waiting for %v clients on %v, shuffler on %v\n", s.ClientNum, ln.Addr(), shufflerLn.Addr())
+	var summary string
+	clients, err := s.join(ln)
+	defer func() {
+		// the clients still connected learn the outcome
+		res := resultMsg{OK: err == nil, Message: summary}
+		if err != nil {
+			res.Message = err.Error()
+		}
+		for i := 0; i < len(clients); i++ {
+			clients[i].conn.SetDeadline(time.Now().Add(s.RoundTimeout))
+			writeMessage(clients[i].conn, msgResult, res.encode())
+			clients[i].conn.Close()
+		}
+	}()
+	if errors.Is(err, ErrEmptyEpoch) {
+		log.Printf("server: %v\n", err)
+		summary = err.Error()
+		return &ElectionResult{Winner: -1, Empty: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err = s.round("hello", clients, func(c *remoteClient) error {
+		shuffler.Register(c.id, c.token)
+		hello := helloMsg{
+			ClientID:       c.id,
+			Token:          c.token,
+			Backend:        system.Backend(),
+			Curve:          system.Curve(),
+			CandidateNum:   uint32(CandidateNum),
+			DummyNum:       uint32(dummyNum),
+			Checksum:       checksum,
+			SeedCommitment: SeedCommitment(seed),
+			VerifyingKey:   vk,
+			ShufflerAddr:   shufflerLn.Addr().String(),
+			Codecs:         codecsOf(Compression),
+			Identify:       s.Registry != nil,
+		}
+		return writeMessage(c.conn, msgHello, hello.encode())
+	})
+	if err != nil {
+		return nil, err
+	}
+	var unregistered []uint32
+	if s.Registry != nil {
+		clients, unregistered = s.identify(clients, SeedCommitment(seed))
+		if len(clients) == 0 {
+			return nil, errors.New("identify round: no registered client")
+		}
+	}
+	registered := make([]uint32, len(clients))
+	for i := 0; i < len(clients); i++ {
+		registered[i] = clients[i].id
+	}
+
+	clients, err = s.round("commit", clients, func(c *remoteClient) error {
+		p, err := readMessage(c.conn, msgCommit)
+		if err != nil {
+			return err
+		}
+		return c.commit.decode(p, system.Curve())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rejected, flagged := s.checkCommitments(field, &clients)
+	if len(clients) == 0 {
+		return nil, errors.New("commit round: no client left")
+	}
+	// the rejected clients are not dropouts
+	isRejected := make(map[uint32]bool, len(rejected))
+	for _, id := range rejected {
+		isRejected[id] = true
+	}
+	var accepted []uint32
+	for _, id := range registered {
+		if !isRejected[id] {
+			accepted = append(accepted, id)
+		}
+	}
+	registered = accepted
+
+	clients, err = s.round("reveal", clients, func(c *remoteClient) error {
+		if err := writeMessage(c.conn, msgRevealRequest, nil); err != nil {
+			return err
+		}
+		p, err := readMessage(c.conn, msgReveal)
+		if err != nil {
+			return err
+		}
+		c.nonce = p.element()
+		return p.finish()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the clients dropped so far are out of the challenge transcript; a bad
+	// reveal only excludes the nonce
+	nonceCommitments := make([]fr_bn254.Element, len(clients))
+	for i := 0; i < len(clients); i++ {
+		nonceCommitments[i] = clients[i].commit.NonceCom
+	}
+	challenge := NewChallengeRound(SeedCommitment(seed), nonceCommitments)
+	nonces := make([]fr_bn254.Element, len(clients))
+	revealed := make([]bool, len(clients))
+	for i := 0; i < len(clients); i++ {
+		if err := challenge.Reveal(i, clients[i].nonce); err != nil {
+			log.Printf("server: client %v: %v\n", clients[i].id, err)
+			continue
+		}
+		nonces[i], revealed[i] = clients[i].nonce, true
+	}
+	publicR, err := challenge.Derive(seed)
+	if err != nil {
+		return nil, fmt.Errorf("challenge: %w", err)
+	}
+
+	clients, err = s.round("prove", clients, func(c *remoteClient) error {
+		msg := challengeMsg{Seed: seed, PublicR: publicR}
+		if err := writeMessage(c.conn, msgChallenge, msg.encode()); err != nil {
+			return err
+		}
+		p, err := readMessage(c.conn, msgSubmission)
+		if err != nil {
+			return err
+		}
+		payload := p.take(len(p.b))
+		c.submission = ClientSubmission{system: system}
+		if _, err := c.submission.ReadFrom(bytes.NewReader(payload)); err != nil {
+			return err
+		}
+		if c.submission.proof == nil {
+			return errors.New("the submission has no proof")
+		}
+		expected, err := s.expectedPublicWitness(c, publicR)
+		if err != nil {
+			return err
+		}
+		got, err := c.submission.publicWitness.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(expected, got) {
+			return errors.New("the public witness does not match the commitment and the challenge")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the shuffler only releases the data of the clients left
+	ids := make([]uint32, len(clients))
+	for i := 0; i < len(clients); i++ {
+		ids[i] = clients[i].id
+	}
+	pairFirst, pairSecond, dummies, missing := shuffler.Release(ids)
+	if len(missing) > 0 {
+		log.Printf("server: the shuffler has no shares from clients %v\n", missing)
+		var live []*remoteClient
+		for i, j := 0, 0; i < len(clients); i++ {
+			if j < len(missing) && clients[i].id == missing[j] {
+				j++
+				clients[i].conn.Close()
+				continue
+			}
+			live = append(live, clients[i])
+		}
+		clients = live
+		if len(clients) == 0 {
+			return nil, errors.New("no client left")
+		}
+	}
+	ids = ids[:0]
+	for i := 0; i < len(clients); i++ {
+		ids = append(ids, clients[i].id)
+	}
+
+	// the shuffler held the shares per client until the release, so the
+	// dropped clients are not mixed in
+	plan, err := PlanDropout(Lambda, dummyNum, uint64(CorruptedNum), registered, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Unrecoverable {
+		return nil, fmt.Errorf("clients %v: %w", plan.Dropped, ErrDropoutMixed)
+	}
+	if len(plan.Dropped) > 0 {
+		log.Printf("server: clients %v dropped out, %v submitted\n", plan.Dropped, len(plan.Survivors))
+	}
+	switch {
+	case plan.BelowTarget && plan.Required == 0:
+		log.Printf("server: WARNING: the %v clients that submitted leave too few honest ones for any number of dummies: this round does not have the claimed privacy\n", len(plan.Survivors))
+	case plan.BelowTarget:
+		log.Printf("server: WARNING: the %v clients that submitted need %v dummies per client, the round has %v: this round does not have the claimed privacy\n", len(plan.Survivors), plan.Required, dummyNum)
+	}
+
+	allSubmission := make([]ClientSubmission, len(clients))
+	for i := 0; i < len(clients); i++ {
+		allSubmission[i] = clients[i].submission
+	}
+	prodFromShuffler := ShufflerProduct(field, pairFirst, pairSecond, dummies, publicR)
+	var excluded []uint32
+	if batchVerifySubmissions(allSubmission, vk) != nil {
+		verifyErrs := VerifyAll(allSubmission, vk, false)
+		var live []*remoteClient
+		var liveSubmission []ClientSubmission
+		for i := 0; i < len(clients); i++ {
+			if verifyErrs[i] != nil {
+				log.Printf("server: excluding client %v: %v\n", clients[i].id, verifyErrs[i])
+				excluded = append(excluded, clients[i].id)
+				s.dismiss(clients[i], verifyErrs[i])
+				continue
+			}
+			live = append(live, clients[i])
+			liveSubmission = append(liveSubmission, allSubmission[i])
+		}
+		clients, allSubmission = live, liveSubmission
+	}
+	if len(excluded) > 0 {
+		if len(clients) == 0 {
+			return nil, errors.New("every proof failed verification")
+		}
+		reveals, err := shuffler.RevealExcluded(excluded)
+		if err != nil {
+			return nil, err
+		}
+		pairFirst, pairSecond, dummies, prodFromShuffler, err = Exclude(field, CandidateNum, pairFirst, pairSecond, dummies, prodFromShuffler, reveals, publicR)
+		if err != nil {
+			return nil, err
+		}
+		ids = ids[:0]
+		for i := 0; i < len(clients); i++ {
+			ids = append(ids, clients[i].id)
+		}
+	}
+
+	// the polls are closed; the tally waits for every proof left
+	gate := NewTallyGate(len(clients), systemClock{})
+	gate.Close()
+	gate.RecordVerified(len(allSubmission))
+
+	prodFromClient := ClientsProduct(field, allSubmission)
+	if prodFromShuffler.Cmp(prodFromClient) != 0 {
+		return nil, ErrInconsistent
+	}
+	log.Printf("server: consistency check passed for %v clients\n", len(clients))
+
+	tally, err := gate.Tally(pairFirst, pairSecond, CandidateNum)
+	if err != nil {
+		return nil, err
+	}
+	shuffler.Discard()
+	res := &ElectionResult{Counted: ids, Unregistered: unregistered, Rejected: rejected, Flagged: flagged, Excluded: excluded, Dropped: plan.Dropped, BelowTarget: plan.BelowTarget, Tally: tally.Pairs, Winner: tally.Winner, Smith: tally.Smith, Borda: tally.Borda}
+	if float64(len(clients)-CorruptedNum) <= e {
+		res.WeakPrivacy = true
+		log.Printf("server: only %v clients counted with %v corrupted: the tally does not hide their ballots\n", len(clients), CorruptedNum)
+	}
+	if s.EpochDir != "" {
+		commitments := make([]*big.Int, len(clients))
+		for i := 0; i < len(clients); i++ {
+			commitments[i] = clients[i].commit.PublicCom
+		}
+		epoch := &Epoch{
+			Backend:          system.Backend(),
+			Curve:            system.Curve(),
+			CandidateNum:     CandidateNum,
+			VerifyingKey:     vk,
+			Seed:             seed,
+			SeedCommitment:   SeedCommitment(seed),
+			NonceCommitments: nonceCommitments,
+			Nonces:           nonces,
+			Revealed:         revealed,
+			PublicR:          publicR,
+			Commitments:      commitments,
+			CommitmentRoot:   CommitmentRoot(commitments),
+			PairFirst:        pairFirst,
+			PairSecond:       pairSecond,
+			Dummies:          dummies,
+			Tally:            tally.Pairs,
+			Winner:           res.Winner,
+			Submissions:      allSubmission,
+		}
+		if err := ExportEpoch(s.EpochDir, epoch); err != nil {
+			return nil, fmt.Errorf("export epoch: %w", err)
+		}
+	}
+	summary = fmt.Sprintf("the consistency check passed for %v clients, sole winner %v", len(clients), res.Winner)
+	return res, nil
+}
+
+// serverMain is the entry point of "vote server".
+func serverMain(args []string) int {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	addr := fs.String("listen", ":7000", "address the clients connect to")
+	shufflerAddr := fs.String("shuffler", ":7001", "address of the shuffler endpoint")
+	fs.IntVar(&ClientNum, "clients", ClientNum, "number of clients")
+	fs.IntVar(&CandidateNum, "candidates", CandidateNum, "number of candidates")
+	fs.IntVar(&CorruptedNum, "corrupted", -1, "number of corrupted clients, -1 for half of the clients")
+	fs.Uint64Var(&Lambda, "lambda", Lambda, "statistical security parameter")
+	curveName := fs.String("curve", Curve.String(), "curve of the proof system, bn254 or bls12_381")
+	backendName := fs.String("backend", "groth16", "proof system, groth16 or plonk")
+	fs.StringVar(&KeyDir, "keydir", "keys", "directory caching the circuit and its keys, shared with the clients")
+	timeout := fs.Duration("timeout", time.Minute, "deadline of every round")
+	duplicates := fs.String("duplicates", Duplicates.String(), "policy for duplicate commitments, reject or audit")
+	compression := fs.String("compress", Compression.String(), "codec accepted from the clients, none, deflate or zstd")
+	epochDir := fs.String("export", "", "directory to publish the epoch to for the observers, empty for none")
+	registryFile := fs.String("registry", "", "registry of the clients provisioned with \"vote admin provision\", empty to accept any client")
+	fs.StringVar(&RunDir, "run-dir", RunDir, "directory of the resolved configuration of the server")
+	configFile := fs.String(configFlag, "", "JSON or YAML file setting the flags by name, overridden by the command line, see config.go")
+	fs.Parse(args)
+	if err := LoadConfig(fs, *configFile); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+	if CorruptedNum < 0 {
+		CorruptedNum = ClientNum / 2
+	}
+
+	var err error
+	if Curve, err = ParseCurve(*curveName); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+	if Duplicates, err = ParseDuplicatePolicy(*duplicates); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+	if Compression, err = ParseCodec(*compression); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+	id, err := ParseBackend(*backendName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+	if err := validateConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+	system, err := NewProofSystem(id, Curve)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+	var registry *Registry
+	if *registryFile != "" {
+		if registry, err = LoadRegistry(*registryFile); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+			return 2
+		}
+	}
+	if err := SaveConfig(fs, filepath.Join(RunDir, "server-config.json")); err != nil {
+		log.Printf("server: save the configuration: %v\n", err)
+		return 1
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Printf("server: %v\n", err)
+		return 1
+	}
+	defer ln.Close()
+	shufflerLn, err := net.Listen("tcp", *shufflerAddr)
+	if err != nil {
+		log.Printf("server: %v\n", err)
+		return 1
+	}
+
+	server := Server{System: system, ClientNum: ClientNum, RoundTimeout: *timeout, Duplicates: Duplicates, EpochDir: *epochDir, Registry: registry}
+	res, err := server.Run(ln, shufflerLn)
+	if err != nil {
+		log.Printf("server: %v\n", err)
+		return 1
+	}
+	if res.Empty {
+		fmt.Printf("No client joined, the epoch is empty\n")
+		return 0
+	}
+	fmt.Printf("Counted the ballots of %v clients\n", len(res.Counted))
+	if res.WeakPrivacy {
+		fmt.Printf("Warning: too few clients were counted for the shuffle to hide their ballots\n")
+	}
+	if len(res.Unregistered) > 0 {
+		fmt.Printf("Refused clients %v, which are not in the registry\n", res.Unregistered)
+	}
+	if len(res.Excluded) > 0 {
+		fmt.Printf("Excluded clients %v, whose proof failed verification\n", res.Excluded)
+	}
+	if res.Winner >= 0 {
+		fmt.Printf("The sole winner is %v\n", res.Winner)
+	} else {
+		fmt.Printf("There is no sole winner, the Smith set is %v\n", res.Smith)
+	}
+	fmt.Printf("Borda scores: %v\n", res.Borda)
+	return 0
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// ProtocolPhase is the round of the protocol a session has completed, see
+// ProtocolSession. Unlike Phase, which tells whether the server still
+// accepts submissions, it follows the rounds of the clients.
+type ProtocolPhase int
+
+const (
+	// ProtocolStart is the phase of a new session: no round ran yet.
+	ProtocolStart ProtocolPhase = iota
+	// ProtocolInit: the clients drew their ballots, dummies and masks.
+	ProtocolInit
+	// ProtocolCommitment: the server holds the commitments of the clients.
+	ProtocolCommitment
+	// ProtocolChallenge: publicR is derived.
+	ProtocolChallenge
+	// ProtocolProve: the clients submitted their proofs against publicR.
+	ProtocolProve
+	// ProtocolVerify: the server verified the submissions, the session is
+	// over.
+	ProtocolVerify
+)
+
+func (p ProtocolPhase) String() string {
+	switch p {
+	case ProtocolStart:
+		return "start"
+	case ProtocolInit:
+		return "init"
+	case ProtocolCommitment:
+		return "commitment"
+	case ProtocolChallenge:
+		return "challenge"
+	case ProtocolProve:
+		return "prove"
+	case ProtocolVerify:
+		return "verify"
+	}
+	return fmt.Sprintf("ProtocolPhase(%d)", int(p))
+}
+
+var (
+	ErrInvalidPhase = errors.New("session: phase out of order")
+	ErrPhaseInput   = errors.New("session: wrong input for the phase")
+)
+
+// ProtocolSession enforces the order of the rounds of one election: Init,
+// Commitment, Challenge, Prove and Verify, each once. It keeps the input of
+// every round it completed.
+type ProtocolSession struct {
+	Phase ProtocolPhase
+
+	Clients     []ClientState
+	Commitments []*big.Int
+	PublicR     fr_bn254.Element
+	Submissions []ClientSubmission
+}
+
+// Advance completes the round phase with its input, which must be the one
+// after s.Phase:
+//
+//	ProtocolInit        []ClientState, the clients
+//	ProtocolCommitment  []*big.Int, a commitment per client
+//	ProtocolChallenge   fr_bn254.Element, publicR
+//	ProtocolProve       []ClientSubmission, a submission per client
+//	ProtocolVerify      nil
+//
+// Out of order, it returns ErrInvalidPhase, and ErrPhaseInput for an input
+// of another type or length. The session is left as it was on an error.
+func (s *ProtocolSession) Advance(phase ProtocolPhase, input interface{}) error {
+	if phase != s.Phase+1 || phase > ProtocolVerify {
+		return fmt.Errorf("%w: %v after %v", ErrInvalidPhase, phase, s.Phase)
+	}
+	switch phase {
+	case ProtocolInit:
+		clients, ok := input.([]ClientState)
+		if !ok || len(clients) == 0 {
+			return s.inputErr(phase, input)
+		}
+		s.Clients = clients
+	case ProtocolCommitment:
+		commitments, ok := input.([]*big.Int)
+		if !ok || len(commitments) != len(s.Clients) {
+			return s.inputErr(phase, input)
+		}
+		s.Commitments = commitments
+	case ProtocolChallenge:
+		publicR, ok := input.(fr_bn254.Element)
+		if !ok {
+			return s.inputErr(phase, input)
+		}
+		s.PublicR = publicR
+	case ProtocolProve:
+		submissions, ok := input.([]ClientSubmission)
+		if !ok || len(submissions) != len(s.Clients) {
+			return s.inputErr(phase, input)
+		}
+		s.Submissions = submissions
+	case ProtocolVerify:
+		if input != nil {
+			return s.inputErr(phase, input)
+		}
+	}
+	s.Phase = phase
+	return nil
+}
+
+func (s *ProtocolSession) inputErr(phase ProtocolPhase, input interface{}) error {
+	return fmt.Errorf("%w: %T for %v with %v clients", ErrPhaseInput, input, phase, len(s.Clients))
+}
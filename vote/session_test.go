@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+func TestProtocolSession(t *testing.T) {
+	clients := make([]ClientState, 2)
+	rounds := []struct {
+		phase ProtocolPhase
+		input interface{}
+	}{
+		{ProtocolInit, clients},
+		{ProtocolCommitment, []*big.Int{big.NewInt(1), big.NewInt(2)}},
+		{ProtocolChallenge, fr_bn254.NewElement(3)},
+		{ProtocolProve, make([]ClientSubmission, 2)},
+		{ProtocolVerify, nil},
+	}
+	var s ProtocolSession
+	for i, round := range rounds {
+		// every round but the next one is out of order
+		for _, other := range rounds {
+			if other.phase == round.phase {
+				continue
+			}
+			if err := s.Advance(other.phase, other.input); !errors.Is(err, ErrInvalidPhase) {
+				t.Fatalf("%v after %v: got %v, expected ErrInvalidPhase", other.phase, s.Phase, err)
+			}
+		}
+		// the input of the previous round does not fit this one
+		if i > 0 {
+			if err := s.Advance(round.phase, rounds[i-1].input); !errors.Is(err, ErrPhaseInput) {
+				t.Fatalf("%v with the input of %v: got %v, expected ErrPhaseInput", round.phase, rounds[i-1].phase, err)
+			}
+		}
+		if s.Phase != ProtocolStart+ProtocolPhase(i) {
+			t.Fatalf("a refused round moved the session to %v", s.Phase)
+		}
+		if err := s.Advance(round.phase, round.input); err != nil {
+			t.Fatalf("%v: %v", round.phase, err)
+		}
+	}
+	if s.Phase != ProtocolVerify || s.PublicR != fr_bn254.NewElement(3) || len(s.Submissions) != 2 {
+		t.Fatalf("session %v, publicR %v, %v submissions", s.Phase, s.PublicR.String(), len(s.Submissions))
+	}
+	if err := s.Advance(ProtocolVerify+1, nil); !errors.Is(err, ErrInvalidPhase) {
+		t.Fatalf("a round after verify: got %v", err)
+	}
+}
+
+func TestProtocolSessionInputLength(t *testing.T) {
+	var s ProtocolSession
+	if err := s.Advance(ProtocolInit, []ClientState{}); !errors.Is(err, ErrPhaseInput) {
+		t.Fatalf("no client: got %v, expected ErrPhaseInput", err)
+	}
+	if err := s.Advance(ProtocolInit, make([]ClientState, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Advance(ProtocolCommitment, []*big.Int{big.NewInt(1)}); !errors.Is(err, ErrPhaseInput) {
+		t.Fatalf("a commitment for 3 clients: got %v, expected ErrPhaseInput", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// The shuffler does not send a proof of its own. Two vectors are permutations
+// of each other exactly when PolyEval agrees on them as polynomials in r, so
+// by Schwartz-Zippel a random r tells them apart except with probability
+// len/p. The server never sees the original vector, the data the clients
+// shipped, but it knows its evaluation: the product of the PublicProd of the
+// clients, each proved in VoteCircuit, divided by the product of the dummies.
+// The product check of the server, ShufflerProduct against ClientsProduct,
+// is therefore the permutation argument, and a proof from the shuffler would
+// repeat it.
+//
+// What the argument needs from the shuffler is that the release is fixed
+// before r: it sends ReleaseCommitment(permuted) with the release, before
+// publicR is derived, and the server checks the release against it once r is
+// known.
+
+const shuffleDomain = "shuffle-zkp/vote/shuffle/v1"
+
+var ErrBadShuffle = errors.New("shuffle: the release does not match the commitment of the shuffler")
+
+// ReleaseCommitment is the commitment of the shuffler to its release.
+func ReleaseCommitment(permuted []fr_bn254.Element) [32]byte {
+	h := sha256.New()
+	h.Write([]byte(shuffleDomain))
+	for i := 0; i < len(permuted); i++ {
+		b := permuted[i].Bytes()
+		h.Write(b[:])
+	}
+	var com [32]byte
+	copy(com[:], h.Sum(nil))
+	return com
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/shuffle"
+)
+
+// TestShuffledProduct checks that the product check is a permutation
+// argument: a permutation of the data of the clients passes it, a release
+// with a vote replaced or dropped does not.
+func TestShuffledProduct(t *testing.T) {
+	rnd := benchrand.New(1)
+	field := bn254Field{}
+	original := make([]fr_bn254.Element, 20)
+	for i := range original {
+		original[i] = rnd.Fr()
+	}
+	dummies := make([]fr_bn254.Element, 5)
+	for i := range dummies {
+		dummies[i] = rnd.Fr()
+	}
+	permuted := append([]fr_bn254.Element(nil), original...)
+	rnd.Shuffle(len(permuted), func(i, j int) {
+		permuted[i], permuted[j] = permuted[j], permuted[i]
+	})
+	r := rnd.Fr()
+	// what the PublicProd of the clients multiply to
+	clientsProd := field.Mul(field.PolyEval(original, r), field.Prod(dummies))
+	released := func(vec []fr_bn254.Element) *big.Int {
+		return field.Mul(field.PolyEval(vec, r), field.Prod(dummies))
+	}
+
+	if released(permuted).Cmp(clientsProd) != 0 {
+		t.Fatal("a permutation fails the product check")
+	}
+	tampered := append([]fr_bn254.Element(nil), permuted...)
+	tampered[0] = tampered[1]
+	if released(tampered).Cmp(clientsProd) == 0 {
+		t.Fatal("a vote replaced by a copy of another one passes the product check")
+	}
+	if released(permuted[1:]).Cmp(clientsProd) == 0 {
+		t.Fatal("a vote dropped passes the product check")
+	}
+}
+
+func TestReleaseCommitment(t *testing.T) {
+	rnd := benchrand.New(2)
+	release := []fr_bn254.Element{rnd.Fr(), rnd.Fr(), rnd.Fr()}
+	com := ReleaseCommitment(release)
+	if ReleaseCommitment(append([]fr_bn254.Element(nil), release...)) != com {
+		t.Fatal("the commitment is not deterministic")
+	}
+	// the order of the release is committed too: it is shuffled before r
+	swapped := []fr_bn254.Element{release[1], release[0], release[2]}
+	if ReleaseCommitment(swapped) == com || ReleaseCommitment(release[:2]) == com {
+		t.Fatal("another release opens the commitment")
+	}
+}
+
+// TestMisbehavingShuffler checks that the product check catches a shuffler
+// that pulls the pairs of the ballots apart or drops one.
+func TestMisbehavingShuffler(t *testing.T) {
+	field := bn254Field{}
+	rnd := benchrand.New(3)
+	publicR := rnd.Fr()
+	clients := make([]ClientState, 10)
+	clientsProd := big.NewInt(1)
+	for i := range clients {
+		clients[i].InitRand(rnd, field, 2)
+		clientsProd = field.Mul(clientsProd, clients[i].ComputePolyEval(publicR))
+	}
+	for _, misbehave := range []struct {
+		name string
+		fn   shuffle.Misbehavior
+	}{
+		{"honest", nil},
+		{"reorder", shuffle.ReorderColumn(benchrand.New(4), 1)},
+		{"drop", shuffle.DropRecord(0)},
+	} {
+		s := shuffle.New(rnd, 2)
+		s.Misbehave = misbehave.fn
+		for i := range clients {
+			s.CollectShares(i, shuffle.Records(clients[i].PairFirst, clients[i].PairSecond))
+			s.CollectDummies(i, clients[i].PrivateY)
+		}
+		records, dummies := s.Output()
+		consistent := ShufflerProduct(field, shuffle.Column(records, 0), shuffle.Column(records, 1), dummies, publicR).Cmp(clientsProd) == 0
+		if consistent != (misbehave.fn == nil) {
+			t.Errorf("%v shuffler: product check passed %v", misbehave.name, consistent)
+		}
+	}
+}
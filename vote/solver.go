@@ -0,0 +1,25 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+
+	"example/verification/internal/gadget"
+)
+
+// When the witness of a client does not solve the vote circuit, the prover
+// only names the index of the constraint that failed. With DebugSolver,
+// GenProof runs the witness again through the test engine of gnark, where
+// the assertions of the circuits are labeled with the invariant they check,
+// see internal/gadget, and adds the invariant that broke to the error: the
+// commitment, the product, a threshold on a private input, the range of a
+// candidate or the permutation of the ranking. The test engine is left out of the wasm
+// client, which does not diagnose its failures, see nosolver.go.
+
+// diagnoseAssignment returns the first assertion of the vote circuit that
+// assignment breaks on curve, nil if it solves the circuit.
+func diagnoseAssignment(curve ecc.ID, assignment *VoteCircuit) *gadget.Failure {
+	circuit := NewVoteCircuit(len(assignment.SortedCandidate), len(assignment.DummyVec))
+	return gadget.Diagnose(curve, &circuit, assignment)
+}
@@ -0,0 +1,94 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+	"example/verification/internal/hasher"
+)
+
+// TestDiagnoseAssignment breaks every invariant of the vote circuit in turn
+// and checks that the diagnosis names it.
+func TestDiagnoseAssignment(t *testing.T) {
+	field := bn254Field{}
+	publicR := randomFr()
+	var client ClientState
+	client.InitRand(benchrand.New(1), field, 4)
+	valid := client.GenAssignment(publicR).Circuit
+	if f := diagnoseAssignment(ecc.BN254, &valid); f != nil {
+		t.Fatalf("a valid ballot: got %v", f)
+	}
+
+	sorted := make([]uint64, CandidateNum)
+	for i := range sorted {
+		sorted[i] = uint64(i)
+	}
+	sorted[1] = sorted[0]
+	twice := rankedClient(field, sorted, 4)
+
+	for _, tc := range []struct {
+		name       string
+		assignment func() VoteCircuit
+		invariant  gadget.Invariant
+	}{
+		{"candidate out of range", func() VoteCircuit {
+			a := client.GenAssignment(publicR).Circuit
+			a.SortedCandidate[0] = CandidateNum
+			return a
+		}, gadget.Range},
+		{"candidate ranked twice", func() VoteCircuit {
+			return twice.GenAssignment(publicR).Circuit
+		}, gadget.Permutation},
+		{"product doubled", func() VoteCircuit {
+			a := client.GenAssignment(publicR).Circuit
+			a.PublicProd = field.Mul(client.ComputePolyEval(publicR), big.NewInt(2))
+			return a
+		}, gadget.Product},
+		{"commitment changed", func() VoteCircuit {
+			a := client.GenAssignment(publicR).Circuit
+			a.PublicCommitment = new(big.Int).Add(client.PublicCom, big.NewInt(1))
+			return a
+		}, gadget.Commitment},
+	} {
+		assignment := tc.assignment()
+		if f := diagnoseAssignment(ecc.BN254, &assignment); f == nil || f.Invariant != tc.invariant {
+			t.Fatalf("%v: got %v, expected the %v invariant", tc.name, f, tc.invariant)
+		}
+	}
+}
+
+// TestGenProofDebugSolver checks that a proof that fails names the invariant
+// the witness breaks with DebugSolver, and only then.
+func TestGenProofDebugSolver(t *testing.T) {
+	defer func(n int, debug bool, p ProtocolParams) {
+		CandidateNum, DebugSolver, Params = n, debug, p
+	}(CandidateNum, DebugSolver, Params)
+	CandidateNum, Params = 3, ProtocolParams{Hash: hasher.Stub}
+	system := NewGroth16System(ecc.BN254)
+	ccs, _ := setupSystem(t, system)
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+
+	var client ClientState
+	client.Init(bn254Field{hash: Params.Hash}, dummyNum)
+	publicR := randomFr()
+	assignment := client.GenAssignment(publicR).Circuit
+	assignment.PublicProd = new(big.Int).Add(client.ComputePolyEval(publicR), big.NewInt(1))
+
+	var failure *gadget.Failure
+	DebugSolver = false
+	if _, _, err := GenProof(system, assignment, ccs); err == nil || errors.As(err, &failure) {
+		t.Fatalf("without DebugSolver: got %v", err)
+	}
+	DebugSolver = true
+	_, _, err := GenProof(system, assignment, ccs)
+	if !errors.As(err, &failure) || failure.Invariant != gadget.Product {
+		t.Fatalf("with DebugSolver: got %v, expected the product invariant", err)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// Phase is the stage of an election epoch as seen by the server.
+type Phase int
+
+const (
+	// PhaseCommit accepts the client commitments and proofs.
+	PhaseCommit Phase = iota
+	// PhaseClosed is entered once the polls close; no submission is accepted anymore.
+	PhaseClosed
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseCommit:
+		return "commit"
+	case PhaseClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("Phase(%d)", int(p))
+}
+
+// Clock is the time source of the server. Tests substitute a fake one.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// TallyPairs is the pairwise comparison count of an election:
+// TallyPairs[a][b] is the number of voters ranking candidate a above b.
+type TallyPairs [][]uint64
+
+// ErrBallotKind is returned when a tally is given pairs of another ballot,
+// such as the approvals of approval.go to the pairwise tally.
+var ErrBallotKind = errors.New("tally: the pairs are not of the ballot tallied")
+
+// NewTallyPairs counts the shuffled (first, second) pairs of candidateNum
+// candidates. Every pair must rank a candidate above another one.
+func NewTallyPairs(pairFirst []fr_bn254.Element, pairSecond []fr_bn254.Element, candidateNum int) (TallyPairs, error) {
+	if len(pairFirst) != len(pairSecond) {
+		return nil, fmt.Errorf("%w: %v first and %v second elements", ErrBallotKind, len(pairFirst), len(pairSecond))
+	}
+	for i := 0; i < len(pairFirst); i++ {
+		a, b := pairFirst[i], pairSecond[i]
+		if !a.IsUint64() || !b.IsUint64() || a.Uint64() >= uint64(candidateNum) || b.Uint64() >= uint64(candidateNum) || a.Equal(&b) {
+			return nil, fmt.Errorf("%w: pair %v is not a pair of distinct candidates", ErrBallotKind, i)
+		}
+	}
+	tally := make(TallyPairs, candidateNum)
+	for i := 0; i < len(tally); i++ {
+		tally[i] = make([]uint64, candidateNum)
+	}
+	for i := 0; i < len(pairFirst); i++ {
+		tally[pairFirst[i].Uint64()][pairSecond[i].Uint64()] += 1
+	}
+	return tally, nil
+}
+
+// SoleWinner returns the candidate ranked above every other candidate by a
+// strict majority, or -1 if there is none.
+func (t TallyPairs) SoleWinner() int {
+	for i := 0; i < len(t); i++ {
+		ok := true
+		for j := 0; j < len(t); j++ {
+			if i != j && t[i][j] <= t[j][i] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// ErrInconsistentTally is returned by Validate for a tally whose pairs of
+// candidates were not all compared by every voter.
+var ErrInconsistentTally = errors.New("tally: the pairs of candidates are not compared by every voter")
+
+// Validate checks that t is a square matrix and that every pair of
+// candidates was compared by exactly voters voters. The error names the first
+// pair that was not.
+func (t TallyPairs) Validate(voters int) error {
+	for i := 0; i < len(t); i++ {
+		if len(t[i]) != len(t) {
+			return fmt.Errorf("%w: row %v has %v columns for %v candidates", ErrInconsistentTally, i, len(t[i]), len(t))
+		}
+	}
+	for i := 0; i < len(t); i++ {
+		for j := i + 1; j < len(t); j++ {
+			if t[i][j]+t[j][i] != uint64(voters) {
+				return fmt.Errorf("%w: candidates %v and %v compared %v times by %v voters", ErrInconsistentTally, i, j, t[i][j]+t[j][i], voters)
+			}
+		}
+	}
+	return nil
+}
+
+// Consistent reports whether every pair of candidates was compared by exactly
+// voters voters, see Validate.
+func (t TallyPairs) Consistent(voters int) bool {
+	return t.Validate(voters) == nil
+}
+
+// Wins returns, for every candidate, the number of candidates it is ranked
+// above by a strict majority.
+func (t TallyPairs) Wins() []int {
+	wins := make([]int, len(t))
+	for i := 0; i < len(t); i++ {
+		for j := 0; j < len(t); j++ {
+			if i != j && t[i][j] > t[j][i] {
+				wins[i]++
+			}
+		}
+	}
+	return wins
+}
+
+// Borda returns the Borda score of every candidate: on a ballot ranking
+// candidateNum candidates, the candidate at position k earns candidateNum-1-k
+// points, one per candidate ranked below it. Summed over the ballots, this is
+// the number of pairwise comparisons the candidate wins.
+func (t TallyPairs) Borda() []uint64 {
+	scores := make([]uint64, len(t))
+	for i := 0; i < len(t); i++ {
+		for j := 0; j < len(t); j++ {
+			scores[i] += t[i][j]
+		}
+	}
+	return scores
+}
+
+// SmithSet returns, in increasing order, the smallest non-empty set of
+// candidates such that every candidate in it beats every candidate outside of
+// it by a strict majority. It is the Condorcet winner alone when there is one,
+// and otherwise holds the candidates of the top cycle and those tied with
+// them.
+func (t TallyPairs) SmithSet() []int {
+	// reach[a][b] if there is a chain a = c0, ..., ck = b in which every
+	// candidate beats or ties the next one
+	reach := make([][]bool, len(t))
+	for a := 0; a < len(t); a++ {
+		reach[a] = make([]bool, len(t))
+		for b := 0; b < len(t); b++ {
+			reach[a][b] = a == b || t[a][b] >= t[b][a]
+		}
+	}
+	for k := 0; k < len(t); k++ {
+		for a := 0; a < len(t); a++ {
+			if !reach[a][k] {
+				continue
+			}
+			for b := 0; b < len(t); b++ {
+				if reach[k][b] {
+					reach[a][b] = true
+				}
+			}
+		}
+	}
+
+	// a candidate is in the Smith set if it reaches every other one: no
+	// candidate outside of its top cycle beats or ties it
+	var smith []int
+	for a := 0; a < len(t); a++ {
+		all := true
+		for b := 0; b < len(t); b++ {
+			if !reach[a][b] {
+				all = false
+				break
+			}
+		}
+		if all {
+			smith = append(smith, a)
+		}
+	}
+	return smith
+}
+
+// TallyResult is the outcome of an election.
+type TallyResult struct {
+	// Pairs is the full pairwise comparison count.
+	Pairs TallyPairs
+	// Winner is the Condorcet winner, or -1 if there is none.
+	Winner int
+	// Wins[a] is the number of candidates a beats by a strict majority.
+	Wins []int
+	// Smith is the Smith set, from which a tie-breaking rule picks the
+	// winner when there is no Condorcet winner.
+	Smith []int
+	// Borda is the Borda score of every candidate.
+	Borda []uint64
+}
+
+// NewTallyResult derives the winner, the win counts, the Smith set and the
+// Borda scores of pairs.
+func NewTallyResult(pairs TallyPairs) TallyResult {
+	return TallyResult{Pairs: pairs, Winner: pairs.SoleWinner(), Wins: pairs.Wins(), Smith: pairs.SmithSet(), Borda: pairs.Borda()}
+}
+
+// Leaders returns, in increasing order, the candidates with the largest
+// score, such as the approval counts or the Borda sums.
+func Leaders(scores []uint64) []int {
+	var leaders []int
+	for i := 0; i < len(scores); i++ {
+		if len(leaders) > 0 && scores[i] > scores[leaders[0]] {
+			leaders = leaders[:0]
+		}
+		if len(leaders) == 0 || scores[i] == scores[leaders[0]] {
+			leaders = append(leaders, i)
+		}
+	}
+	return leaders
+}
+
+// ComputeTally tallies the shuffled (first, second) pairs of candidateNum
+// candidates, see NewTallyPairs.
+func ComputeTally(shuffledPairFirst, shuffledPairSecond []fr_bn254.Element, candidateNum int) (TallyResult, error) {
+	pairs, err := NewTallyPairs(shuffledPairFirst, shuffledPairSecond, candidateNum)
+	if err != nil {
+		return TallyResult{}, err
+	}
+	return NewTallyResult(pairs), nil
+}
+
+// ComputeBorda returns the Borda scores of the shuffled (first, second) pairs
+// of candidateNum candidates, see ComputeRankBordaScores.
+func ComputeBorda(shuffledPairFirst, shuffledPairSecond []fr_bn254.Element, candidateNum int) ([]uint64, error) {
+	return ComputeRankBordaScores(shuffledPairFirst, shuffledPairSecond, candidateNum)
+}
+
+// ComputeRankBordaScores returns the Borda scores of the shuffled (first,
+// second) pairs of the ranking ballots of candidateNum candidates, without
+// the pairwise matrix: every pair is a win of first over second and earns it
+// one point, so a candidate at position k of a ballot earns
+// candidateNum-1-k. The pairs are the untagged ones of VoteCircuit, unlike
+// the score pairs of BordaVoteCircuit that ComputeBordaScores sums.
+func ComputeRankBordaScores(shuffledPairFirst, shuffledPairSecond []fr_bn254.Element, candidateNum int) ([]uint64, error) {
+	if len(shuffledPairFirst) != len(shuffledPairSecond) {
+		return nil, fmt.Errorf("%w: %v first and %v second elements", ErrBallotKind, len(shuffledPairFirst), len(shuffledPairSecond))
+	}
+	scores := make([]uint64, candidateNum)
+	for i := 0; i < len(shuffledPairFirst); i++ {
+		a, b := shuffledPairFirst[i], shuffledPairSecond[i]
+		if !a.IsUint64() || !b.IsUint64() || a.Uint64() >= uint64(candidateNum) || b.Uint64() >= uint64(candidateNum) || a.Equal(&b) {
+			return nil, fmt.Errorf("%w: pair %v is not a pair of distinct candidates", ErrBallotKind, i)
+		}
+		scores[a.Uint64()]++
+	}
+	return scores, nil
+}
+
+// FindBordaWinner returns the candidate with the highest Borda score, or -1
+// when no candidate has it alone: the tied ones are the Leaders of scores.
+func FindBordaWinner(scores []uint64) int {
+	leaders := Leaders(scores)
+	if len(leaders) != 1 {
+		return -1
+	}
+	return leaders[0]
+}
+
+// Print writes the pairwise matrix, the win counts and the winner, or the
+// Smith set, and the Borda scores to w.
+func (r TallyResult) Print(w io.Writer) {
+	fmt.Fprintf(w, "Pairwise comparison (row over column):\n")
+	for a := 0; a < len(r.Pairs); a++ {
+		for b := 0; b < len(r.Pairs); b++ {
+			if a == b {
+				fmt.Fprintf(w, "%8v", "-")
+			} else {
+				fmt.Fprintf(w, "%8v", r.Pairs[a][b])
+			}
+		}
+		fmt.Fprintf(w, "    wins %v\n", r.Wins[a])
+	}
+	if r.Winner >= 0 {
+		fmt.Fprintf(w, "The sole winner is %v\n", r.Winner)
+	} else {
+		fmt.Fprintf(w, "There is no sole winner, the Smith set is %v\n", r.Smith)
+	}
+	fmt.Fprintf(w, "Borda scores: %v\n", r.Borda)
+}
+
+var (
+	ErrPollsOpen    = errors.New("tally: the commit phase is not closed")
+	ErrQuorumNotMet = errors.New("tally: the quorum of verified proofs is not reached")
+)
+
+// TallyGate withholds the tally until the commit phase is closed and at least
+// Quorum client proofs have verified. Nothing is tallied before the gate opens,
+// so no partial result can leak while the polls are open.
+type TallyGate struct {
+	Quorum int
+
+	clock    Clock
+	phase    Phase
+	verified int
+	openedAt time.Time
+}
+
+// NewTallyGate returns a gate in the commit phase.
+func NewTallyGate(quorum int, clock Clock) *TallyGate {
+	return &TallyGate{Quorum: quorum, clock: clock, phase: PhaseCommit}
+}
+
+// Phase returns the current phase of the gate.
+func (g *TallyGate) Phase() Phase {
+	return g.phase
+}
+
+// Verify runs the verifier of one client proof and counts it toward the quorum
+// if it succeeds.
+func (g *TallyGate) Verify(verify func() error) error {
+	if err := verify(); err != nil {
+		return err
+	}
+	g.RecordVerified(1)
+	return nil
+}
+
+// RecordVerified counts n proofs that were verified elsewhere, e.g. in a batch.
+func (g *TallyGate) RecordVerified(n int) {
+	g.verified += n
+	g.tryOpen()
+}
+
+// Close ends the commit phase.
+func (g *TallyGate) Close() {
+	g.phase = PhaseClosed
+	g.tryOpen()
+}
+
+func (g *TallyGate) tryOpen() {
+	if g.openedAt.IsZero() && g.phase == PhaseClosed && g.verified >= g.Quorum {
+		g.openedAt = g.clock.Now()
+	}
+}
+
+// OpenedAt returns the time at which the gate opened, if it did.
+func (g *TallyGate) OpenedAt() (time.Time, bool) {
+	return g.openedAt, !g.openedAt.IsZero()
+}
+
+// Tally tallies the shuffled pairs, or fails if the gate is still shut.
+func (g *TallyGate) Tally(pairFirst []fr_bn254.Element, pairSecond []fr_bn254.Element, candidateNum int) (TallyResult, error) {
+	if g.phase != PhaseClosed {
+		return TallyResult{}, ErrPollsOpen
+	}
+	if g.verified < g.Quorum {
+		return TallyResult{}, fmt.Errorf("%w: %v of %v", ErrQuorumNotMet, g.verified, g.Quorum)
+	}
+	return ComputeTally(pairFirst, pairSecond, candidateNum)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	mathrand "math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func TestTallyGate(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)}
+	gate := NewTallyGate(2, clock)
+
+	pairFirst := []fr_bn254.Element{fr_bn254.NewElement(0), fr_bn254.NewElement(1)}
+	pairSecond := []fr_bn254.Element{fr_bn254.NewElement(1), fr_bn254.NewElement(0)}
+
+	// a controllable verifier: the first proof fails, the others succeed
+	results := []error{errors.New("bad proof"), nil, nil}
+	verify := func(i int) func() error {
+		return func() error { return results[i] }
+	}
+
+	if err := gate.Verify(verify(0)); err == nil {
+		t.Fatal("expected the failing proof to be reported")
+	}
+	if err := gate.Verify(verify(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gate.Tally(pairFirst, pairSecond, 2); !errors.Is(err, ErrPollsOpen) {
+		t.Fatalf("tally before the polls close: got %v", err)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	gate.Close()
+	if _, err := gate.Tally(pairFirst, pairSecond, 2); !errors.Is(err, ErrQuorumNotMet) {
+		t.Fatalf("tally before the quorum: got %v", err)
+	}
+	if _, ok := gate.OpenedAt(); ok {
+		t.Fatal("the gate must not be open before the quorum")
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if err := gate.Verify(verify(2)); err != nil {
+		t.Fatal(err)
+	}
+	tally, err := gate.Tally(pairFirst, pairSecond, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tally.Pairs[0][1] != 1 || tally.Pairs[1][0] != 1 {
+		t.Fatalf("unexpected tally %v", tally.Pairs)
+	}
+	if openedAt, ok := gate.OpenedAt(); !ok || !openedAt.Equal(clock.now) {
+		t.Fatalf("gate opened at %v, expected %v", openedAt, clock.now)
+	}
+}
+
+// rankingPairs returns the shuffled pairs of the rankings, every ranking
+// listing the candidates from the most to the least preferred.
+func rankingPairs(rankings [][]int) ([]fr_bn254.Element, []fr_bn254.Element) {
+	var pairFirst, pairSecond []fr_bn254.Element
+	for _, ranking := range rankings {
+		for i := 0; i < len(ranking); i++ {
+			for j := i + 1; j < len(ranking); j++ {
+				pairFirst = append(pairFirst, fr_bn254.NewElement(uint64(ranking[i])))
+				pairSecond = append(pairSecond, fr_bn254.NewElement(uint64(ranking[j])))
+			}
+		}
+	}
+	mathrand.Shuffle(len(pairFirst), func(i, j int) {
+		pairFirst[i], pairFirst[j] = pairFirst[j], pairFirst[i]
+		pairSecond[i], pairSecond[j] = pairSecond[j], pairSecond[i]
+	})
+	return pairFirst, pairSecond
+}
+
+// rankingTally returns the pairwise count of the rankings.
+func rankingTally(rankings [][]int) TallyPairs {
+	pairFirst, pairSecond := rankingPairs(rankings)
+	pairs, err := NewTallyPairs(pairFirst, pairSecond, len(rankings[0]))
+	if err != nil {
+		panic(err)
+	}
+	return pairs
+}
+
+func TestComputeTally(t *testing.T) {
+	// 0 has the most first choices, but 1 beats every other candidate
+	pairFirst, pairSecond := rankingPairs([][]int{
+		{0, 1, 2, 3},
+		{0, 1, 3, 2},
+		{1, 2, 3, 0},
+		{1, 3, 0, 2},
+		{2, 1, 0, 3},
+	})
+	res, err := ComputeTally(pairFirst, pairSecond, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := TallyPairs{
+		{0, 2, 3, 3},
+		{3, 0, 4, 5},
+		{2, 1, 0, 3},
+		{2, 0, 2, 0},
+	}
+	if !reflect.DeepEqual(res.Pairs, expected) {
+		t.Fatalf("pairs %v, expected %v", res.Pairs, expected)
+	}
+	if !res.Pairs.Consistent(5) {
+		t.Fatal("the tally of 5 voters is not consistent")
+	}
+	if res.Winner != 1 {
+		t.Fatalf("winner %v, expected 1", res.Winner)
+	}
+	if wins := []int{2, 3, 1, 0}; !reflect.DeepEqual(res.Wins, wins) {
+		t.Fatalf("wins %v, expected %v", res.Wins, wins)
+	}
+
+	// a Condorcet cycle: 0 over 1, 1 over 2 and 2 over 0
+	pairFirst, pairSecond = rankingPairs([][]int{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}})
+	if res, err = ComputeTally(pairFirst, pairSecond, 3); err != nil {
+		t.Fatal(err)
+	}
+	if res.Winner != -1 {
+		t.Fatalf("winner %v in a cycle", res.Winner)
+	}
+	if wins := []int{1, 1, 1}; !reflect.DeepEqual(res.Wins, wins) {
+		t.Fatalf("wins %v, expected %v", res.Wins, wins)
+	}
+
+	// an empty epoch has no winner, a single voter picks it
+	if res, err = ComputeTally(nil, nil, 3); err != nil || res.Winner != -1 || !res.Pairs.Consistent(0) {
+		t.Fatalf("empty tally %+v, %v", res, err)
+	}
+	pairFirst, pairSecond = rankingPairs([][]int{{2, 0, 1}})
+	if res, err = ComputeTally(pairFirst, pairSecond, 3); err != nil || res.Winner != 2 || !res.Pairs.Consistent(1) {
+		t.Fatalf("tally of one voter %+v, %v", res, err)
+	}
+}
+
+func TestComputeBorda(t *testing.T) {
+	// positions score 3, 2, 1 and 0 points:
+	// 0: 3+3+0+1+1, 1: 2+2+3+3+2, 2: 1+0+2+0+3, 3: 0+1+1+2+0
+	pairFirst, pairSecond := rankingPairs([][]int{
+		{0, 1, 2, 3},
+		{0, 1, 3, 2},
+		{1, 2, 3, 0},
+		{1, 3, 0, 2},
+		{2, 1, 0, 3},
+	})
+	if borda, err := ComputeBorda(pairFirst, pairSecond, 4); err != nil || !reflect.DeepEqual(borda, []uint64{8, 12, 6, 4}) {
+		t.Fatalf("borda %v, %v, expected [8 12 6 4]", borda, err)
+	}
+	if res, err := ComputeTally(pairFirst, pairSecond, 4); err != nil || !reflect.DeepEqual(res.Borda, []uint64{8, 12, 6, 4}) {
+		t.Fatalf("tally borda %v, expected [8 12 6 4]", res.Borda)
+	}
+
+	// every candidate scores 3 in a Condorcet cycle of three voters
+	pairFirst, pairSecond = rankingPairs([][]int{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}})
+	if borda, err := ComputeBorda(pairFirst, pairSecond, 3); err != nil || !reflect.DeepEqual(borda, []uint64{3, 3, 3}) {
+		t.Fatalf("borda %v, %v in a cycle, expected [3 3 3]", borda, err)
+	}
+}
+
+func TestComputeRankBordaScores(t *testing.T) {
+	// positions score 2, 1 and 0 points:
+	// 0: 2+0+1+0, 1: 1+2+0+2, 2: 0+1+2+1
+	pairFirst, pairSecond := rankingPairs([][]int{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}, {1, 2, 0}})
+	scores, err := ComputeRankBordaScores(pairFirst, pairSecond, 3)
+	if err != nil || !reflect.DeepEqual(scores, []uint64{3, 5, 4}) {
+		t.Fatalf("scores %v, %v, expected [3 5 4]", scores, err)
+	}
+	if winner := FindBordaWinner(scores); winner != 1 {
+		t.Fatalf("winner %v, expected 1", winner)
+	}
+	// the pairwise tally agrees
+	if res, err := ComputeTally(pairFirst, pairSecond, 3); err != nil || !reflect.DeepEqual(res.Borda, scores) {
+		t.Fatalf("tally borda %v, %v", res.Borda, err)
+	}
+
+	// the pair of a candidate with itself, or of one out of range
+	for _, pair := range [][2]uint64{{1, 1}, {0, 3}} {
+		first := append([]fr_bn254.Element{fr_bn254.NewElement(pair[0])}, pairFirst...)
+		second := append([]fr_bn254.Element{fr_bn254.NewElement(pair[1])}, pairSecond...)
+		if _, err := ComputeRankBordaScores(first, second, 3); !errors.Is(err, ErrBallotKind) {
+			t.Fatalf("pair %v: got %v, expected ErrBallotKind", pair, err)
+		}
+	}
+}
+
+func TestFindBordaWinner(t *testing.T) {
+	for _, c := range []struct {
+		scores []uint64
+		winner int
+	}{
+		{[]uint64{8, 12, 6, 4}, 1},
+		{[]uint64{3, 3, 3}, -1},
+		{[]uint64{5, 1, 5, 4}, -1},
+		{[]uint64{0, 2}, 1},
+		{nil, -1},
+	} {
+		if got := FindBordaWinner(c.scores); got != c.winner {
+			t.Fatalf("winner of %v: got %v, expected %v", c.scores, got, c.winner)
+		}
+	}
+}
+
+func TestLeaders(t *testing.T) {
+	for _, c := range []struct {
+		scores  []uint64
+		leaders []int
+	}{
+		{[]uint64{3, 7, 2}, []int{1}},
+		{[]uint64{5, 1, 5, 4}, []int{0, 2}},
+		{[]uint64{0, 0}, []int{0, 1}},
+		{nil, nil},
+	} {
+		if got := Leaders(c.scores); !reflect.DeepEqual(got, c.leaders) {
+			t.Fatalf("leaders of %v: got %v, expected %v", c.scores, got, c.leaders)
+		}
+	}
+}
+
+func TestSmithSet(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		pairs TallyPairs
+		smith []int
+	}{
+		{
+			name:  "Condorcet winner",
+			pairs: rankingTally([][]int{{1, 0, 2}, {1, 2, 0}, {0, 1, 2}}),
+			smith: []int{1},
+		},
+		{
+			// 0 over 1, 1 over 2 and 2 over 0
+			name:  "3-cycle",
+			pairs: rankingTally([][]int{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}}),
+			smith: []int{0, 1, 2},
+		},
+		{
+			// every candidate beats exactly one other, 0-2 and 1-3 split evenly
+			name: "4-cycle",
+			pairs: TallyPairs{
+				{0, 2, 1, 0},
+				{0, 0, 2, 1},
+				{1, 0, 0, 2},
+				{2, 1, 0, 0},
+			},
+			smith: []int{0, 1, 2, 3},
+		},
+		{
+			// 0 and 1 split evenly and both beat 2 and 3
+			name:  "even split",
+			pairs: rankingTally([][]int{{0, 1, 2, 3}, {1, 0, 3, 2}, {0, 1, 2, 3}, {1, 0, 2, 3}}),
+			smith: []int{0, 1},
+		},
+		{
+			// a 3-cycle above a candidate everyone ranks last
+			name:  "top cycle",
+			pairs: rankingTally([][]int{{0, 1, 2, 3}, {1, 2, 0, 3}, {2, 0, 1, 3}}),
+			smith: []int{0, 1, 2},
+		},
+	} {
+		if smith := c.pairs.SmithSet(); !reflect.DeepEqual(smith, c.smith) {
+			t.Fatalf("%v: Smith set %v, expected %v", c.name, smith, c.smith)
+		}
+	}
+}
+
+func TestSoleWinner(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		pairs  TallyPairs
+		winner int
+	}{
+		{"clear winner", rankingTally([][]int{{2, 0, 1}, {2, 1, 0}, {0, 2, 1}}), 2},
+		{"winner without a first choice majority", rankingTally([][]int{{0, 1, 2}, {2, 1, 0}, {1, 0, 2}, {1, 2, 0}, {0, 1, 2}}), 1},
+		// rock over scissors over paper over rock
+		{"rock-paper-scissors", rankingTally([][]int{{0, 2, 1}, {2, 1, 0}, {1, 0, 2}}), -1},
+		{"tie", rankingTally([][]int{{0, 1}, {1, 0}}), -1},
+		{"no voter", TallyPairs{{0, 0, 0}, {0, 0, 0}, {0, 0, 0}}, -1},
+	} {
+		if winner := c.pairs.SoleWinner(); winner != c.winner {
+			t.Fatalf("%v: winner %v, expected %v", c.name, winner, c.winner)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		pairs  TallyPairs
+		voters int
+		valid  bool
+	}{
+		{"3 voters", rankingTally([][]int{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}}), 3, true},
+		{"a voter too many", rankingTally([][]int{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}}), 4, false},
+		{"a pair dropped", TallyPairs{{0, 1, 1}, {0, 0, 0}, {0, 0, 0}}, 1, false},
+		{"not square", TallyPairs{{0, 1}, {0}}, 1, false},
+		{"no candidate", TallyPairs{}, 5, true},
+	} {
+		err := c.pairs.Validate(c.voters)
+		if c.valid && err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if !c.valid && !errors.Is(err, ErrInconsistentTally) {
+			t.Fatalf("%v: got %v, expected ErrInconsistentTally", c.name, err)
+		}
+		if c.pairs.Consistent(c.voters) != c.valid {
+			t.Fatalf("%v: Consistent is not Validate", c.name)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Proves a ballot with the wasm client, for TestPrepareSubmissionWasm.
+//
+//   node wasm_roundtrip.js <wasm_exec.js> <client.wasm> <client.json> <pk> <out.json>
+//
+// The client is encoded by ClientState.MarshalJSON and the proving key is the
+// binary one of the server; the submission is written to out.json.
+"use strict";
+
+const fs = require("fs");
+const [wasmExec, wasmFile, clientFile, pkFile, outFile] = process.argv.slice(2);
+require(wasmExec);
+
+const go = new Go();
+WebAssembly.instantiate(fs.readFileSync(wasmFile), go.importObject).then((result) => {
+	go.run(result.instance);
+	const submission = globalThis.prepareSubmission(
+		fs.readFileSync(clientFile, "utf8"),
+		new Uint8Array(fs.readFileSync(pkFile)),
+	);
+	if (submission instanceof Error) {
+		console.error(submission.message);
+		process.exit(1);
+	}
+	fs.writeFileSync(outFile, submission);
+	process.exit(0);
+}).catch((err) => {
+	console.error(err);
+	process.exit(1);
+});
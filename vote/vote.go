@@ -1,56 +1,135 @@
 package main
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
-	"log"
 	"math"
-	"math/rand"
-	"os"
-	"time"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
-	"github.com/consensys/gnark-crypto/hash"
-	"github.com/consensys/gnark/backend/groth16"
-	"github.com/consensys/gnark/backend/plonk"
 	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/frontend/cs/scs"
-	"github.com/consensys/gnark/std/hash/mimc"
-	"github.com/consensys/gnark/test"
 
-	cs "github.com/consensys/gnark/constraint/bn254"
+	"example/verification/internal/benchrand"
+	"example/verification/internal/benchreport"
+	"example/verification/internal/gadget"
+	"example/verification/internal/hasher"
+	"example/verification/internal/polyeval"
+	"example/verification/internal/pool"
+	"example/verification/internal/shuffle"
 )
 
 const (
 	// 5 private inputs
 	PrivateShareNum = 60
-	CandidateNum    = 10
 	//DummyVecLength   = 60
-	ClientNum          = 1000
-	CorruptedNum       = 500
 	e                  = 2.71828182845904523536028747135266249775724709369995
 	BN254Size          = 32
 	CommitmentSize     = 32
 	MaxNumOfCheckProof = 10
 	TestRepeat         = 1
+
+	// MaxCandidateNum is the most candidates the circuit and the tally are
+	// tested with. A ballot of C candidates is C * (C - 1) / 2 pairs, 496
+	// at 32, and the constraints and the proving time grow with the pairs.
+	MaxCandidateNum = 32
+)
+
+// The benchmark configuration. The defaults can be overridden with the
+// -clients, -candidates, -corrupted, -lambda and -curve flags.
+var (
+	ClientNum    = 1000
+	CandidateNum = 10
+	CorruptedNum = 500
+	Lambda       = uint64(80)
+	Curve        = ecc.BN254
+
+	// Workers is the number of goroutines generating and verifying the client proofs.
+	Workers = runtime.NumCPU()
+
+	// TallyQuorum is the number of verified proofs required before the tally
+	// is computed. 0 requires every checked proof.
+	TallyQuorum = 0
+
+	// Duplicates is how the server handles two clients with the same commitment.
+	Duplicates = RejectDuplicates
+
+	// Compression is the codec of the compressible messages, see compress.go.
+	Compression = CodecZstd
+
+	// SubmissionDir is where the submissions are saved for a later audit,
+	// see audit.go. Empty disables it.
+	SubmissionDir = ""
+
+	// Seed makes the runs reproducible, see internal/benchrand. 0 draws
+	// everything from crypto/rand.
+	Seed = uint64(0)
+
+	// MetricsFile is the JSON lines file the metrics of every run are
+	// appended to, see metrics.go. Empty disables it.
+	MetricsFile = ""
+
+	// VerboseMetrics keeps the raw latency samples in MetricsFile.
+	VerboseMetrics = false
+
+	// AuditFraction is the fraction of the clients that open their ballot
+	// instead of having their proof verified, see opening.go.
+	AuditFraction = 0.0
+
+	// Localize names the clients whose product does not match their data
+	// when the consistency check fails, see dispute.go.
+	Localize = false
+
+	// DebugSolver names the invariant of the circuit a witness breaks when
+	// its proof fails, see solver.go.
+	DebugSolver = false
+
+	// Format is the format of the results: every run is appended to
+	// output-vote.csv, and with FormatJSON to output-vote.jsonl as well, see
+	// internal/benchreport.
+	Format = benchreport.FormatCSV
+
+	// DummyLengths, if not empty, replaces the number of dummies
+	// ComputeDummyNum requires: the benchmark runs once per length, to chart
+	// the cost of the dummies. The runs below the security target are
+	// flagged, see BelowSecurityTarget.
+	DummyLengths []uint64
 )
 
-var file *os.File
-var DummyVecLength uint64
+// MinHonestNum is the fewest honest clients the dummies can hide in: the
+// first count above e.
+const MinHonestNum uint64 = 3
 
-func ComputeDummyNum(lambda uint64, n uint64, t uint64) uint64 {
+// ComputeDummyNum returns the number of dummies every client sends for a
+// statistical security of lambda bits against t corrupted clients out of n.
+// The bound needs more than e honest clients, see MinHonestNum.
+func ComputeDummyNum(lambda uint64, n uint64, t uint64) (uint64, error) {
+	if t >= n || n-t < MinHonestNum {
+		return 0, fmt.Errorf("%v corrupted clients out of %v: the dummies need at least %v honest clients, so at least %v clients", t, n, MinHonestNum, t+MinHonestNum)
+	}
 	tmp := float64(2*lambda+254)/float64(math.Log2(float64(n-t))-math.Log2(e)) + 2
-	return uint64(math.Ceil(tmp))
+	return uint64(math.Ceil(tmp)), nil
+}
+
+// BelowSecurityTarget reports whether dummyNum dummies per client are fewer
+// than ComputeDummyNum requires for lambda bits against t corrupted clients
+// out of n.
+func BelowSecurityTarget(dummyNum uint64, lambda uint64, n uint64, t uint64) (bool, error) {
+	required, err := ComputeDummyNum(lambda, n, t)
+	if err != nil {
+		return false, err
+	}
+	return dummyNum < required, nil
 }
 
 func PolyEval(vec []fr_bn254.Element, r fr_bn254.Element) fr_bn254.Element {
-	prod := vec[0]
-	prod.Add(&prod, &r)
-	for i := 1; i < len(vec); i++ {
+	prod := fr_bn254.One()
+	for i := 0; i < len(vec); i++ {
 		tmp := vec[i]
 		tmp.Add(&tmp, &r)
 		prod.Mul(&prod, &tmp)
@@ -78,27 +157,48 @@ type VoteCircuit struct {
 
 	// The following are for the polynomial evaluation
 	PrivateMask frontend.Variable
-	PublicR     frontend.Variable `gnark:",public"`
-	PublicProd  frontend.Variable `gnark:",public"`
+	// DummyVec are the dummies the client sends to the shuffler; the mask is
+	// their product, as the server multiplies them in. They are committed, so
+	// the mask is the one of the dummies fixed before publicR.
+	DummyVec   []frontend.Variable
+	PublicR    frontend.Variable `gnark:",public"`
+	PublicProd frontend.Variable `gnark:",public"`
 
 	// The following are for the commitment
 	PublicCommitment frontend.Variable `gnark:",public"`
 	PrivateSalt      frontend.Variable
+
+	// Hash is the hash of the commitment, see params.go
+	Hash hasher.HashConfig `gnark:"-"`
 }
 
-func (circuit *VoteCircuit) Define(api frontend.API) error {
+// VotePublic are the public variables of VoteCircuit, in the order of the
+// public witness that expectedPublicWitness rebuilds.
+var VotePublic = []string{"PublicR", "PublicProd", "PublicCommitment"}
 
-	// first verify that the unsorted candidate list is a permutation of 0 - (CandidateNum - 1)
-	unsortedCandidate := make([]frontend.Variable, CandidateNum)
-	for i := 0; i < CandidateNum; i++ {
+// rankingInCircuit checks that sortedCandidate is a ranking of the candidates and
+// that pairFirst and pairSecond are its pairs, and returns the pairs packed
+// as first * candidateNum + second.
+func rankingInCircuit(api frontend.API, publicR frontend.Variable, sortedCandidate, pairFirst, pairSecond []frontend.Variable) []frontend.Variable {
+	candidateNum := len(sortedCandidate)
+
+	// every index is a candidate. The permutation check below implies it, but
+	// an index out of range then fails here, where the cause is clear
+	for i := 0; i < candidateNum; i++ {
+		gadget.Label(api, gadget.Range).AssertIsLessOrEqual(sortedCandidate[i], candidateNum-1)
+	}
+
+	// first verify that the unsorted candidate list is a permutation of 0 - (candidateNum - 1)
+	unsortedCandidate := make([]frontend.Variable, candidateNum)
+	for i := 0; i < candidateNum; i++ {
 		//api.AssertIsEqual(circuit.UnsortedCandidate[i], frontend.Variable(i))
 		unsortedCandidate[i] = frontend.Variable(i)
 	}
 
-	// then verify that the sorted candidate list is a permutation of 0 - (CandidateNum - 1)
-	unsortedProd := PolyEvalInCircuit(api, unsortedCandidate, circuit.PublicR)
-	sortedProd := PolyEvalInCircuit(api, circuit.SortedCandidate, circuit.PublicR)
-	api.AssertIsEqual(unsortedProd, sortedProd)
+	// then verify that the sorted candidate list is a permutation of 0 - (candidateNum - 1)
+	unsortedProd := PolyEvalInCircuit(api, unsortedCandidate, publicR)
+	sortedProd := PolyEvalInCircuit(api, sortedCandidate, publicR)
+	gadget.Label(api, gadget.Permutation).AssertIsEqual(unsortedProd, sortedProd)
 
 	// Then verify that the pairs are correct
 	// Essentially, there are (c * (c - 1) / 2) pairs
@@ -109,38 +209,72 @@ func (circuit *VoteCircuit) Define(api frontend.API) error {
 	// (0, 2), (0, 3)
 	// (2, 3)
 
-	processedVec := make([]frontend.Variable, len(circuit.PairFirstVar))
+	processedVec := make([]frontend.Variable, len(pairFirst))
 	base := 0
-	for i := 0; i < CandidateNum; i++ {
-		for j := 0; j < CandidateNum-i-1; j++ {
+	for i := 0; i < candidateNum; i++ {
+		for j := 0; j < candidateNum-i-1; j++ {
 			// first verify the first element of the pair is sorted[i]
-			api.AssertIsEqual(circuit.PairFirstVar[base+j], circuit.SortedCandidate[i])
+			gadget.Label(api, gadget.Permutation).AssertIsEqual(pairFirst[base+j], sortedCandidate[i])
 
 			// then verify the second element of the pair is sorted[i+j+1]
-			api.AssertIsEqual(circuit.PairSecondVar[base+j], circuit.SortedCandidate[i+j+1])
+			gadget.Label(api, gadget.Permutation).AssertIsEqual(pairSecond[base+j], sortedCandidate[i+j+1])
 
-			// the processedVec should be first * CandidateNum + second
-			processedVec[base+j] = api.Add(api.Mul(circuit.PairFirstVar[base+j], frontend.Variable(CandidateNum)), circuit.PairSecondVar[base+j])
+			// the processedVec should be first * candidateNum + second
+			processedVec[base+j] = api.Add(api.Mul(pairFirst[base+j], frontend.Variable(candidateNum)), pairSecond[base+j])
 		}
-		base += CandidateNum - i - 1
+		base += candidateNum - i - 1
 	}
+	return processedVec
+}
+
+func (circuit *VoteCircuit) Define(api frontend.API) error {
+	processedVec := rankingInCircuit(api, circuit.PublicR, circuit.SortedCandidate, circuit.PairFirstVar, circuit.PairSecondVar)
 
 	// The following is for the polynomial evaluation
 	privateProd := PolyEvalInCircuit(api, processedVec, circuit.PublicR)
 	privateProd = api.Mul(privateProd, circuit.PrivateMask)
-	api.AssertIsEqual(privateProd, circuit.PublicProd)
+	gadget.Label(api, gadget.Product).AssertIsEqual(privateProd, circuit.PublicProd)
+
+	// the mask is the product of the dummies, otherwise the product of the
+	// client would not match the data of the shuffler
+	dummyProd := frontend.Variable(1)
+	for i := 0; i < len(circuit.DummyVec); i++ {
+		dummyProd = api.Mul(dummyProd, circuit.DummyVec[i])
+	}
+	gadget.Label(api, gadget.Product).AssertIsEqual(circuit.PrivateMask, dummyProd)
 
 	// checking commitment
-	mimc, _ := mimc.NewMiMC(api)
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
 	for i := 0; i < len(circuit.PairFirstVar); i++ {
-		mimc.Write(processedVec[i])
+		h.Write(processedVec[i])
 	}
-	mimc.Write(circuit.PrivateMask)
-	mimc.Write(circuit.PrivateSalt)
-	api.AssertIsEqual(circuit.PublicCommitment, mimc.Sum())
+	h.Write(circuit.DummyVec...)
+	h.Write(circuit.PrivateMask)
+	h.Write(circuit.PrivateSalt)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicCommitment, h.Sum())
 	return nil
 }
 
+// NewVoteCircuit allocates an empty vote circuit for candidateNum candidates
+// and dummyNum dummies, hashing with Params.Hash, ready to be compiled.
+func NewVoteCircuit(candidateNum int, dummyNum int) VoteCircuit {
+	return VoteCircuit{
+		SortedCandidate:  make([]frontend.Variable, candidateNum),
+		PairFirstVar:     make([]frontend.Variable, candidateNum*(candidateNum-1)/2),
+		PairSecondVar:    make([]frontend.Variable, candidateNum*(candidateNum-1)/2),
+		PrivateMask:      0,
+		DummyVec:         make([]frontend.Variable, dummyNum),
+		PublicR:          0,
+		PublicProd:       0,
+		PublicCommitment: 0,
+		PrivateSalt:      0,
+		Hash:             Params.Hash,
+	}
+}
+
 // generate a random element in fr_bn254
 func randomFr() fr_bn254.Element {
 	var e fr_bn254.Element
@@ -153,16 +287,13 @@ func randomFr() fr_bn254.Element {
 //	DummyVec   [DummyVecLength]fr_bn254.Element
 //}
 
-type ClientSubmissionToServer struct {
-	publicWitness *witness.Witness
-	publicProd    fr_bn254.Element
-	proof         *groth16.Proof
-}
-
-type ClientSubmissionToServerPlonk struct {
-	publicWitness *witness.Witness
-	publicProd    fr_bn254.Element
-	proof         *plonk.Proof
+// ClientSubmission is what a client sends to the server. proof and
+// publicWitness are nil for the clients that do not attach a proof.
+type ClientSubmission struct {
+	publicWitness witness.Witness
+	publicProd    *big.Int
+	proof         ProofBytes
+	system        ProofSystem
 }
 
 type ClientState struct {
@@ -173,27 +304,46 @@ type ClientState struct {
 	PrivateX []fr_bn254.Element // the private X are the packed version of the pairs
 	PrivateY []fr_bn254.Element // the private Y are the dummies
 
-	PublicCom   fr_bn254.Element
-	PrivateMask fr_bn254.Element
+	// the values derived from the private inputs live in the field of the
+	// proof system, see field.go
+	PublicCom   *big.Int
+	PrivateMask *big.Int
 	PrivateSalt fr_bn254.Element
 
-	PublicProd fr_bn254.Element
-	PublicR    fr_bn254.Element
+	// the challenge the client proves against, see challenge.go
+	PublicR fr_bn254.Element
+
+	// the nonce contributed to the challenge, see challenge.go
+	Nonce    fr_bn254.Element
+	NonceCom fr_bn254.Element
+
+	// the secret key of the voter and the commitment to it, see nullifier.go
+	SecretKey fr_bn254.Element
+	VoterCom  *big.Int
+
+	field Field
 }
 
-func (c *ClientState) Init() {
+// Init samples a random ranking for the client together with dummyNum dummies
+// and computes the commitment to them in field.
+func (c *ClientState) Init(field Field, dummyNum uint64) {
+	c.InitRand(benchrand.New(0), field, dummyNum)
+}
+
+// InitRand is Init drawing all the randomness of the client from rnd, so that
+// a seeded rnd gives the same client on every run.
+func (c *ClientState) InitRand(rnd *benchrand.Rand, field Field, dummyNum uint64) {
 	c.SortedCandidate = make([]fr_bn254.Element, CandidateNum)
 	c.PairFirst = make([]fr_bn254.Element, CandidateNum*(CandidateNum-1)/2)
 	c.PairSecond = make([]fr_bn254.Element, CandidateNum*(CandidateNum-1)/2)
 	c.PrivateX = make([]fr_bn254.Element, CandidateNum*(CandidateNum-1)/2)
-	c.PrivateY = make([]fr_bn254.Element, DummyVecLength)
 
 	for i := 0; i < CandidateNum; i++ {
 		c.SortedCandidate[i] = fr_bn254.NewElement(uint64(i))
 	}
 
 	//create a random order of the candidate
-	rand.Shuffle(len(c.SortedCandidate), func(i, j int) {
+	rnd.Shuffle(len(c.SortedCandidate), func(i, j int) {
 		c.SortedCandidate[i], c.SortedCandidate[j] = c.SortedCandidate[j], c.SortedCandidate[i]
 	})
 
@@ -214,40 +364,103 @@ func (c *ClientState) Init() {
 		c.PrivateX[i] = tmp
 	}
 
+	c.initSecrets(rnd, field, dummyNum)
+}
+
+// initSecrets draws from rnd the dummies, the salt, the nonce and the secret
+// key of the client, once its packed votes PrivateX are set, and computes the
+// values derived from them in field.
+func (c *ClientState) initSecrets(rnd *benchrand.Rand, field Field, dummyNum uint64) {
+	c.field = field
+	c.PrivateY = make([]fr_bn254.Element, dummyNum)
+
 	// now generate the private dummy
 	for i := 0; i < len(c.PrivateY); i++ {
-		c.PrivateY[i] = randomFr()
+		c.PrivateY[i] = rnd.Fr()
 	}
 
 	// the privateMask is the product of privateY
-	c.PrivateMask = fr_bn254.One()
-	for i := 0; i < len(c.PrivateY); i++ {
-		c.PrivateMask.Mul(&c.PrivateMask, &c.PrivateY[i])
-	}
+	c.PrivateMask = field.Prod(c.PrivateY)
 
 	//private salt is a random value
-	c.PrivateSalt = randomFr()
+	c.PrivateSalt = rnd.Fr()
 
-	// the public commitment is the hash of the privateX, privateMask and privateSalt
-	goMimc := hash.MIMC_BN254.New()
-	for i := 0; i < len(c.PrivateX); i++ {
-		b := c.PrivateX[i].Bytes()
-		goMimc.Write(b[:])
+	// the public commitment is the hash of the privateX, privateY, privateMask
+	// and privateSalt
+	c.PublicCom = c.Opening().Commit(field)
+
+	// the nonce for the challenge and its commitment
+	c.Nonce = rnd.Fr()
+	c.NonceCom = NonceCommitment(c.Nonce)
+
+	c.SecretKey = rnd.Fr()
+	c.VoterCom = VoterCommitment(field, c.SecretKey)
+}
+
+// InitClientsParallel initializes n clients of the configured Curve and
+// dummy count on workers goroutines, see initClients.
+func InitClientsParallel(n int, workers int) ([]ClientState, error) {
+	field, err := NewField(Curve)
+	if err != nil {
+		return nil, err
+	}
+	dummyNum, err := ComputeDummyNum(Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	if err != nil {
+		return nil, err
 	}
-	b := c.PrivateMask.Bytes()
-	goMimc.Write(b[:])
-	b = c.PrivateSalt.Bytes()
-	goMimc.Write(b[:])
-	c.PublicCom.SetBytes(goMimc.Sum(nil))
+	return initClients(n, workers, field, dummyNum), nil
 }
 
-func (c *ClientState) ComputePolyEval(publicR fr_bn254.Element) {
-	prod := PolyEval(c.PrivateX, publicR)
-	prod.Mul(&prod, &c.PrivateMask)
-	c.PublicProd = prod
+// initClients initializes n clients in field on workers goroutines. Every
+// goroutine takes a contiguous range of the clients and its own unseeded
+// benchrand.Rand, whose permutations are not safe for concurrent use; the
+// clients are returned in order.
+func initClients(n int, workers int, field Field, dummyNum uint64) []ClientState {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+	clients := make([]ClientState, n)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(from int, to int) {
+			defer wg.Done()
+			rnd := benchrand.New(0)
+			for i := from; i < to; i++ {
+				clients[i].InitRand(rnd, field, dummyNum)
+			}
+		}(w*n/workers, (w+1)*n/workers)
+	}
+	wg.Wait()
+	return clients
 }
 
-func (c *ClientState) GenAssignment(publicR fr_bn254.Element) VoteCircuit {
+// ComputePolyEval returns the product the client sends to the server for the
+// challenge publicR: the evaluation of its pairs at publicR times its mask.
+func (c *ClientState) ComputePolyEval(publicR fr_bn254.Element) *big.Int {
+	return c.field.Mul(c.field.PolyEval(c.PrivateX, publicR), c.PrivateMask)
+}
+
+// frontendVariable converts a BN254 scalar to a variable that can be assigned
+// in the field of any supported curve.
+func frontendVariable(x *fr_bn254.Element) frontend.Variable {
+	return x.BigInt(new(big.Int))
+}
+
+// VoteAssignment is the assignment of the circuit of a client for a challenge,
+// together with the product it proves.
+type VoteAssignment struct {
+	Circuit    VoteCircuit
+	PublicProd *big.Int
+}
+
+// GenAssignment returns the assignment of the client for the challenge
+// publicR. It does not change c, so that the assignments of a client can be
+// generated concurrently.
+func (c *ClientState) GenAssignment(publicR fr_bn254.Element) VoteAssignment {
 	// first initialize all variables needed in the votecircuit
 	unsortedCandidate := make([]frontend.Variable, CandidateNum)
 	sortedCandidate := make([]frontend.Variable, CandidateNum)
@@ -256,17 +469,21 @@ func (c *ClientState) GenAssignment(publicR fr_bn254.Element) VoteCircuit {
 
 	for i := 0; i < CandidateNum; i++ {
 		unsortedCandidate[i] = frontend.Variable(i)
-		sortedCandidate[i] = frontend.Variable(c.SortedCandidate[i])
+		sortedCandidate[i] = frontendVariable(&c.SortedCandidate[i])
 	}
 
 	for i := 0; i < len(pairFirstVar); i++ {
-		pairFirstVar[i] = frontend.Variable(c.PairFirst[i])
-		pairSecondVar[i] = frontend.Variable(c.PairSecond[i])
+		pairFirstVar[i] = frontendVariable(&c.PairFirst[i])
+		pairSecondVar[i] = frontendVariable(&c.PairSecond[i])
+	}
+
+	dummyVec := make([]frontend.Variable, len(c.PrivateY))
+	for i := 0; i < len(dummyVec); i++ {
+		dummyVec[i] = frontendVariable(&c.PrivateY[i])
 	}
 
 	// now compute the public prod
-	c.ComputePolyEval(publicR)
-	publicProd := frontend.Variable(c.PublicProd)
+	publicProd := c.ComputePolyEval(publicR)
 
 	// now create the assignment
 	assignment := VoteCircuit{
@@ -274,642 +491,226 @@ func (c *ClientState) GenAssignment(publicR fr_bn254.Element) VoteCircuit {
 		PairFirstVar:     pairFirstVar,
 		PairSecondVar:    pairSecondVar,
 		PrivateMask:      frontend.Variable(c.PrivateMask),
-		PublicR:          frontend.Variable(publicR),
-		PublicProd:       publicProd,
+		DummyVec:         dummyVec,
+		PublicR:          frontendVariable(&publicR),
+		PublicProd:       frontend.Variable(publicProd),
 		PublicCommitment: frontend.Variable(c.PublicCom),
-		PrivateSalt:      frontend.Variable(c.PrivateSalt),
+		PrivateSalt:      frontendVariable(&c.PrivateSalt),
 	}
 
-	return assignment
+	return VoteAssignment{Circuit: assignment, PublicProd: publicProd}
 }
 
-func GenProofGroth16(assignment VoteCircuit, ccs *constraint.ConstraintSystem, pk *groth16.ProvingKey) (*groth16.Proof, *witness.Witness) {
-	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	//fmt.Println(witness)
-	publicWitness, _ := witness.Public()
-
-	// groth16: Prove & Verify
-	proof, _ := groth16.Prove(*ccs, *pk, witness)
-
-	return &proof, &publicWitness
-}
-
-func GenProofPlonk(assignment VoteCircuit, ccs *constraint.ConstraintSystem, pk *plonk.ProvingKey) (*plonk.Proof, *witness.Witness) {
-	// witness definition
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	//fmt.Println(witness)
-	publicWitness, _ := witness.Public()
-
-	// plonk: Prove & Verify
-	proof, _ := plonk.Prove(*ccs, *pk, witness)
-
-	return &proof, &publicWitness
+// GenAllProofs proves the assignments on workers goroutines sharing the
+// read-only ccs and the proving key of system. The proofs and public
+// witnesses are returned in the order of the assignments.
+func GenAllProofs(system ProofSystem, allAssignment []VoteCircuit, ccs constraint.ConstraintSystem, workers int) ([]ProofBytes, []witness.Witness, error) {
+	proofs := make([]ProofBytes, len(allAssignment))
+	publicWitnesses := make([]witness.Witness, len(allAssignment))
+	err := pool.Run(len(allAssignment), workers, func(i int) error {
+		proof, publicWitness, err := GenProof(system, allAssignment[i], ccs)
+		if err != nil {
+			return fmt.Errorf("client %v: %w", i, err)
+		}
+		proofs[i], publicWitnesses[i] = proof, publicWitness
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return proofs, publicWitnesses, nil
 }
 
-func VoteGroth16() {
-	DummyVecLength = uint64(ComputeDummyNum(80, ClientNum, CorruptedNum))
-	log.Printf("lambda %v, n %v, t %v, Dummy Num: %v\n", 80, ClientNum, CorruptedNum, DummyVecLength)
-
-	// define a dummy vote circuit
-	var circuit = VoteCircuit{
-		SortedCandidate:  make([]frontend.Variable, CandidateNum),
-		PairFirstVar:     make([]frontend.Variable, CandidateNum*(CandidateNum-1)/2),
-		PairSecondVar:    make([]frontend.Variable, CandidateNum*(CandidateNum-1)/2),
-		PrivateMask:      0,
-		PublicR:          0,
-		PublicProd:       0,
-		PublicCommitment: 0,
-		PrivateSalt:      0,
+// tallyQuorum returns the quorum of the tally gate.
+func tallyQuorum() int {
+	if TallyQuorum > 0 {
+		return TallyQuorum
 	}
+	return numCheckedProofs(ClientNum)
+}
 
-	ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-
-	// groth16 zkSNARK: Setup
-	pk, vk, _ := groth16.Setup(ccs)
-
-	var buf bytes.Buffer
-	pk.WriteTo(&buf)
-	// check how many bytes are written
-	provingKeySize := buf.Len()
-	// clean the buffer
-	buf.Reset()
-
-	// Step 1: define n clients
-	start := time.Now()
-	clients := make([]ClientState, ClientNum)
-	for i := 0; i < len(clients); i++ {
-		clients[i].Init()
+// numCheckedProofs is the number of clients among n that attach a real proof.
+func numCheckedProofs(n int) int {
+	if n < MaxNumOfCheckProof {
+		return n
 	}
-	prepTime := time.Since(start)
+	return MaxNumOfCheckProof
+}
 
-	// print the information of the 0-th client
-	fmt.Printf("=====Client 0=====\n")
-	for i := 0; i < len(clients[0].SortedCandidate); i++ {
-		// print the sorted candidate, cast it to uint64
-		fmt.Printf("rank: %v", clients[0].SortedCandidate[i].Uint64())
+// GenSubmissions builds the server submissions of the clients of
+// allAssignment. Only the first MaxNumOfCheckProof clients attach a real
+// proof, generated on Workers goroutines; a failure is reported with the index
+// of the offending client.
+func GenSubmissions(system ProofSystem, allAssignment []VoteAssignment, ccs constraint.ConstraintSystem) ([]ClientSubmission, error) {
+	circuits := make([]VoteCircuit, numCheckedProofs(len(allAssignment)))
+	for i := range circuits {
+		circuits[i] = allAssignment[i].Circuit
 	}
-	fmt.Printf("\n")
-	tmpCnt := 0
-	for i := 0; i < CandidateNum; i++ {
-		for j := 0; j < CandidateNum-i-1; j++ {
-			fmt.Printf("(%v, %v)", clients[0].PairFirst[tmpCnt].Uint64(), clients[0].PairSecond[tmpCnt].Uint64())
-			tmpCnt += 1
-		}
-		fmt.Printf("\n")
+	proofs, publicWitnesses, err := GenAllProofs(system, circuits, ccs, Workers)
+	if err != nil {
+		return nil, err
 	}
-	tmpCnt = 0
-	for i := 0; i < CandidateNum; i++ {
-		for j := 0; j < CandidateNum-i-1; j++ {
-			fmt.Printf("%v ", clients[0].PrivateX[tmpCnt].Uint64())
-			tmpCnt += 1
+	allSubmission := make([]ClientSubmission, len(allAssignment))
+	for i := 0; i < len(allAssignment); i++ {
+		if i < len(proofs) {
+			allSubmission[i].proof = proofs[i]
+			allSubmission[i].publicWitness = publicWitnesses[i]
 		}
-		fmt.Printf("\n")
+		allSubmission[i].publicProd = allAssignment[i].PublicProd
+		allSubmission[i].system = system
 	}
-	fmt.Printf("============================\n")
-
-	// DATA COLLECTION PHASE: each client submits its votes to the shuffler
+	return allSubmission, nil
+}
 
-	shuffledPairFirst := make([]fr_bn254.Element, ClientNum*(CandidateNum*(CandidateNum-1)/2))
-	shuffledPairSecond := make([]fr_bn254.Element, ClientNum*(CandidateNum*(CandidateNum-1)/2))
+// ErrNoProof is returned for a submission without an attached proof.
+var ErrNoProof = errors.New("the submission has no proof")
 
-	voteCnt := 0
-	for i := 0; i < len(clients); i++ {
-		for j := 0; j < len(clients[i].PairFirst); j++ {
-			shuffledPairFirst[voteCnt] = clients[i].PairFirst[j]
-			shuffledPairSecond[voteCnt] = clients[i].PairSecond[j]
-			voteCnt += 1
-		}
+// VerifySubmission verifies the proof attached to s against vk.
+func VerifySubmission(vk VerifyingKeyBytes, s *ClientSubmission) error {
+	if s.proof == nil {
+		return ErrNoProof
 	}
-	// shuffled the votes. Shuffle the pairFirst and pairSecond with the same permutation
-	rand.Shuffle(len(shuffledPairFirst), func(i, j int) {
-		shuffledPairFirst[i], shuffledPairFirst[j] = shuffledPairFirst[j], shuffledPairFirst[i]
-		shuffledPairSecond[i], shuffledPairSecond[j] = shuffledPairSecond[j], shuffledPairSecond[i]
-	})
-
-	// DETECTION PHASE:
-
-	// Step 1: Client does the following
-	// a) randomly sample the dummies (already done when we initialize the clients)
-	// b) send the dummies to the shuffler
-	// c) send the commitment to the server
+	return s.system.Verify(s.proof, vk, s.publicWitness)
+}
 
-	allDummies := make([]fr_bn254.Element, ClientNum*DummyVecLength)
-	dummyCnt := 0
-	for i := 0; i < len(clients); i++ {
-		for j := 0; j < len(clients[i].PrivateY); j++ {
-			allDummies[dummyCnt] = clients[i].PrivateY[j]
-			dummyCnt += 1
+// ErrVerificationSkipped marks the proofs left unchecked by a fail-fast
+// verification after another proof failed.
+var ErrVerificationSkipped = errors.New("verification skipped after an earlier failure")
+
+// VerifyAll verifies the proofs attached to the submissions on Workers
+// goroutines and returns one entry per submission, nil when the proof verified
+// or no proof was attached. With failFast, the proofs not yet started when a
+// failure is seen are reported as ErrVerificationSkipped.
+func VerifyAll(allSubmission []ClientSubmission, vk VerifyingKeyBytes, failFast bool) []error {
+	var failed atomic.Bool
+	return pool.RunAll(len(allSubmission), Workers, func(i int) error {
+		if allSubmission[i].proof == nil {
+			return nil
 		}
-	}
-	// shuffle the dummies
-	rand.Shuffle(len(allDummies), func(i, j int) {
-		allDummies[i], allDummies[j] = allDummies[j], allDummies[i]
+		if failFast && failed.Load() {
+			return ErrVerificationSkipped
+		}
+		if err := VerifySubmission(vk, &allSubmission[i]); err != nil {
+			failed.Store(true)
+			return fmt.Errorf("client %v: verification: %w", i, err)
+		}
+		return nil
 	})
+}
 
-	commitments := make([]fr_bn254.Element, ClientNum)
-	for i := 0; i < ClientNum; i++ {
-		commitments[i] = clients[i].PublicCom
-	}
-
-	// Step 2: the server broadcasts the publicR
-	publicR := randomFr()
-
-	// Step 3:
-	// now the clients can compute the assignment
-	start = time.Now()
-	allAssignment := make([]VoteCircuit, ClientNum)
-	for i := 0; i < len(clients); i++ {
-		allAssignment[i] = clients[i].GenAssignment(publicR)
-	}
-	prepTime += time.Since(start)
-
-	// now the clients can compute the proofs
-	// we only generate proofs for the first MaxNumOfCheckProof clients
-	start = time.Now()
-	allSubmission := make([]ClientSubmissionToServer, ClientNum)
-	for i := 0; i < len(clients); i++ {
-		if i < MaxNumOfCheckProof {
-			allSubmission[i].proof, allSubmission[i].publicWitness = GenProofGroth16(allAssignment[i], &ccs, &pk)
-			allSubmission[i].publicProd = clients[i].PublicProd
-		} else {
-			allSubmission[i].proof = nil
-			allSubmission[i].publicWitness = nil
-			allSubmission[i].publicProd = clients[i].PublicProd
+// compactErrors drops the nil entries of errs.
+func compactErrors(errs []error) []error {
+	var res []error
+	for i := 0; i < len(errs); i++ {
+		if errs[i] != nil {
+			res = append(res, errs[i])
 		}
 	}
-	proofTime := time.Since(start)
-
-	// check how many bytes are written per client
-	proofSize := 0
-	publicWitnessSize := 0
-	// proofSize is the size of the allSubmission[0].proof
-	// publicWitnessSize is the size of the allSubmission[0].publicWitness
-	// we assume that all the proofs and publicWitnesses have the same size
-	if allSubmission[0].proof != nil {
-		(*(allSubmission[0].proof)).WriteTo(&buf)
-		proofSize = buf.Len()
-		buf.Reset()
-	}
-	if allSubmission[0].publicWitness != nil {
-		(*(allSubmission[0].publicWitness)).WriteTo(&buf)
-		publicWitnessSize = buf.Len()
-		buf.Reset()
-	}
+	return res
+}
 
-	// now the server can verify the proofs
-	start = time.Now()
+// batchVerifySubmissions checks all the attached proofs in one batch. It
+// fails if the proof system of the submissions cannot batch.
+func batchVerifySubmissions(allSubmission []ClientSubmission, vk VerifyingKeyBytes) error {
+	var batcher BatchVerifier
+	var proofs []ProofBytes
+	var publicWitnesses []witness.Witness
 	for i := 0; i < len(allSubmission); i++ {
-		if i < MaxNumOfCheckProof {
-			verification_err := groth16.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
-			if verification_err != nil {
-				fmt.Printf("verification error in client %v", i)
-			}
-		}
-	}
-	verifyTime := time.Since(start)
-
-	// finally, the server verifies the polynomial evaluations
-	start = time.Now()
-
-	processedVec := make([]fr_bn254.Element, len(shuffledPairFirst))
-	for i := 0; i < len(shuffledPairFirst); i++ {
-		tmp := fr_bn254.NewElement(uint64(CandidateNum))
-		tmp.Mul(&tmp, &shuffledPairFirst[i])
-		tmp.Add(&tmp, &shuffledPairSecond[i])
-		processedVec[i] = tmp
-	}
-	prodFromShuffler := PolyEval(processedVec, publicR)
-	for i := 0; i < len(allDummies); i++ {
-		prodFromShuffler.Mul(&prodFromShuffler, &allDummies[i])
-	}
-
-	// print the product from the shuffler
-	fmt.Printf("prodFromShuffler: %v\n", prodFromShuffler)
-
-	prodFromClient := fr_bn254.NewElement(uint64(1))
-	for i := 0; i < len(clients); i++ {
-		prodFromClient.Mul(&prodFromClient, &allSubmission[i].publicProd)
-	}
-
-	// now the server compares the prodFromShuffler and the prodFromClients
-	if !prodFromShuffler.Equal(&prodFromClient) {
-		fmt.Printf("The product from the shuffler and the product from the clients are not equal\n")
-	}
-
-	serverTime := time.Since(start)
-
-	// now we see if there is any sole winner
-	comparisonVoteCnt := make([][]uint64, CandidateNum)
-	for i := 0; i < len(comparisonVoteCnt); i++ {
-		comparisonVoteCnt[i] = make([]uint64, CandidateNum)
-	}
-	for i := 0; i < len(shuffledPairFirst); i++ {
-		comparisonVoteCnt[shuffledPairFirst[i].Uint64()][shuffledPairSecond[i].Uint64()] += 1
-	}
-	soleWinner := -1
-	for i := 0; i < CandidateNum; i++ {
-		ok := true
-		for j := 0; j < CandidateNum; j++ {
-			if i != j && comparisonVoteCnt[i][j] <= comparisonVoteCnt[j][i] {
-				ok = false
-				break
-			}
-			if i != j && comparisonVoteCnt[i][j]+comparisonVoteCnt[j][i] != ClientNum {
-				fmt.Print("The comparison is not correct\n")
-			}
+		if allSubmission[i].proof == nil {
+			continue
 		}
-		if ok {
-			fmt.Printf("The sole winner is %v\n", i)
-			// print the vote for the sole winner
-			for j := 0; j < CandidateNum; j++ {
-				fmt.Printf("%v ", comparisonVoteCnt[i][j])
-			}
-			soleWinner = i
+		b, ok := allSubmission[i].system.(BatchVerifier)
+		if !ok || (batcher != nil && b != batcher) {
+			return errors.New("the submissions cannot be batched")
 		}
+		batcher = b
+		proofs = append(proofs, allSubmission[i].proof)
+		publicWitnesses = append(publicWitnesses, allSubmission[i].publicWitness)
 	}
-	if soleWinner == -1 {
-		fmt.Printf("There is no sole winner\n")
-	}
-
-	//now we compute the cost
-
-	// now we compute the communication
-	// the client sends the commitments to the server
-	// the server broadcasts the challenge
-	// the client sends the public witness and the proof to the server
-
-	proofRelatedCommCost := uint64(proofSize) // + publicWitnessSize
-	//commCost := (float64(dummyCostPerClient) + float64(proofSize) + float64(publicWitnessSize) + float64(CommitmentSize) + float64(BN254Size)) / 1024
-	dummyCostPerClient := DummyVecLength * uint64(BN254Size)
-	commCost := uint64(proofSize) + uint64(publicWitnessSize) + BN254Size + CommitmentSize + dummyCostPerClient
-
-	log.Print("========Stats (Voting w/ Groth16 Proof)======\n")
-	nbConstraints := ccs.GetNbConstraints()
-	log.Printf("Number of Constraints: %v\n", nbConstraints)
-	log.Printf("============================\n")
-
-	log.Printf("=====Communication Cost (bytes)=====\n")
-	log.Printf("Proof: %v\n", proofRelatedCommCost)
-	log.Printf("Other: %v\n", commCost-proofRelatedCommCost)
-	log.Printf("Total: %v\n", commCost)
-	// we now print the breakdown of the communication cost
-	log.Printf("Proof Size %v\n", proofSize)
-	log.Printf("Public Witness Size %v\n", publicWitnessSize)
-	log.Printf("Commitment Size %v\n", CommitmentSize)
-	log.Printf("Challenge Size %v\n", BN254Size)
-	log.Printf("Dummy Size %v\n", dummyCostPerClient)
-	log.Printf("============================\n")
-
-	// now we compute the computation cost
-	//23 parts : prep, proof
-	clientTime := prepTime/time.Duration(ClientNum) + proofTime/time.Duration(MaxNumOfCheckProof)
-	log.Printf("=====Client Computation Cost=====\n")
-	log.Printf("Preparation: %v\n", prepTime/time.Duration(ClientNum))
-	log.Printf("Proof: %v\n", proofTime/time.Duration(MaxNumOfCheckProof))
-	log.Printf("Total: %v\n", clientTime)
-	log.Printf("============================\n")
-
-	// now we compute the server time amortized per client
-	serverTotalTime := serverTime/time.Duration(ClientNum) + verifyTime/time.Duration(MaxNumOfCheckProof)
-	log.Printf("=====Server Computation Cost=====\n")
-	log.Printf("Other: %v\n", serverTime/time.Duration(ClientNum))
-	log.Printf("Verify: %v\n", verifyTime/time.Duration(MaxNumOfCheckProof))
-	log.Printf("Total: %v\n", serverTotalTime)
-	log.Printf("============================\n")
-
-	// now we compute the storage cost
-	// the proving key size is the storage cost
-	log.Printf("=====Storage Cost (Bytes) =====\n")
-	log.Printf("Proving Key: %v\n", provingKeySize)
-	log.Printf("============================\n")
-
-	s := fmt.Sprintf("Voting Groth16, %v, %v, %v, %v, %v, %v, %v\n",
-		nbConstraints,
-		ClientNum,
-		ClientNum-CorruptedNum,
-		clientTime,
-		serverTotalTime,
-		commCost,
-		provingKeySize)
-	file.WriteString(s)
-}
-
-func VotePlonk() {
-	DummyVecLength = uint64(ComputeDummyNum(80, ClientNum, CorruptedNum))
-	log.Printf("lambda %v, n %v, t %v, Dummy Num: %v\n", 80, ClientNum, CorruptedNum, DummyVecLength)
-
-	// define a dummy vote circuit
-	var circuit = VoteCircuit{
-		SortedCandidate:  make([]frontend.Variable, CandidateNum),
-		PairFirstVar:     make([]frontend.Variable, CandidateNum*(CandidateNum-1)/2),
-		PairSecondVar:    make([]frontend.Variable, CandidateNum*(CandidateNum-1)/2),
-		PrivateMask:      0,
-		PublicR:          0,
-		PublicProd:       0,
-		PublicCommitment: 0,
-		PrivateSalt:      0,
-	}
-	//ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
-	if err != nil {
-		log.Println("scs circuit compile error")
-	}
-
-	//setup kzg
-	_r1cs := ccs.(*cs.SparseR1CS)
-	srs, err := test.NewKZGSRS(_r1cs)
-	if err != nil {
-		log.Println("kzg srs error")
+	if batcher == nil {
+		return nil
 	}
+	return batcher.BatchVerify(proofs, vk, publicWitnesses)
+}
 
-	// plonk zkSNARK: Setup
-	pk, vk, _ := plonk.Setup(ccs, srs)
-	var buf bytes.Buffer
-	pk.WriteTo(&buf)
-	// check how many bytes are written
-	provingKeySize := buf.Len()
-	// clean the buffer
-	buf.Reset()
-
-	// Step 1: define n clients
-	start := time.Now()
-	clients := make([]ClientState, ClientNum)
-	for i := 0; i < len(clients); i++ {
-		clients[i].Init()
-	}
-	prepTime := time.Since(start)
-
-	// print the information of the 0-th client
-	fmt.Printf("=====Client 0=====\n")
-	for i := 0; i < len(clients[0].SortedCandidate); i++ {
-		// print the sorted candidate, cast it to uint64
-		fmt.Printf("rank: %v", clients[0].SortedCandidate[i].Uint64())
-	}
-	fmt.Printf("\n")
-	tmpCnt := 0
-	for i := 0; i < CandidateNum; i++ {
-		for j := 0; j < CandidateNum-i-1; j++ {
-			fmt.Printf("(%v, %v)", clients[0].PairFirst[tmpCnt].Uint64(), clients[0].PairSecond[tmpCnt].Uint64())
-			tmpCnt += 1
-		}
-		fmt.Printf("\n")
-	}
-	tmpCnt = 0
-	for i := 0; i < CandidateNum; i++ {
-		for j := 0; j < CandidateNum-i-1; j++ {
-			fmt.Printf("%v ", clients[0].PrivateX[tmpCnt].Uint64())
-			tmpCnt += 1
-		}
-		fmt.Printf("\n")
-	}
-	fmt.Printf("============================\n")
-
-	// DATA COLLECTION PHASE: each client submits its votes to the shuffler
-
-	shuffledPairFirst := make([]fr_bn254.Element, ClientNum*(CandidateNum*(CandidateNum-1)/2))
-	shuffledPairSecond := make([]fr_bn254.Element, ClientNum*(CandidateNum*(CandidateNum-1)/2))
-
-	voteCnt := 0
-	for i := 0; i < len(clients); i++ {
-		for j := 0; j < len(clients[i].PairFirst); j++ {
-			shuffledPairFirst[voteCnt] = clients[i].PairFirst[j]
-			shuffledPairSecond[voteCnt] = clients[i].PairSecond[j]
-			voteCnt += 1
-		}
+// VerifySubmissions verifies the proofs attached to the submissions and
+// returns one error per client whose proof does not verify. If the proof
+// system supports it, all proofs are first checked in one batch; the clients
+// are only checked one by one, in parallel, when the batch fails.
+func VerifySubmissions(allSubmission []ClientSubmission, vk VerifyingKeyBytes) []error {
+	if batchVerifySubmissions(allSubmission, vk) == nil {
+		return nil
 	}
-	// shuffled the votes. Shuffle the pairFirst and pairSecond with the same permutation
-	rand.Shuffle(len(shuffledPairFirst), func(i, j int) {
-		shuffledPairFirst[i], shuffledPairFirst[j] = shuffledPairFirst[j], shuffledPairFirst[i]
-		shuffledPairSecond[i], shuffledPairSecond[j] = shuffledPairSecond[j], shuffledPairSecond[i]
-	})
-
-	// DETECTION PHASE:
+	return compactErrors(VerifyAll(allSubmission, vk, false))
+}
 
-	// Step 1: Client does the following
-	// a) randomly sample the dummies (already done when we initialize the clients)
-	// b) send the dummies to the shuffler
-	// c) send the commitment to the server
+// ShufflerProduct is the product the server recomputes from the shuffled
+// data: the packed pairs evaluated at publicR times the product of the dummies.
+// It equals ClientsProduct when the shuffler and the clients saw the same data.
+func ShufflerProduct(field Field, pairFirst []fr_bn254.Element, pairSecond []fr_bn254.Element, dummies []fr_bn254.Element, publicR fr_bn254.Element) *big.Int {
+	return shufflerProduct(field, CandidateNum, pairFirst, pairSecond, dummies, publicR)
+}
 
-	allDummies := make([]fr_bn254.Element, ClientNum*DummyVecLength)
-	dummyCnt := 0
-	for i := 0; i < len(clients); i++ {
-		for j := 0; j < len(clients[i].PrivateY); j++ {
-			allDummies[dummyCnt] = clients[i].PrivateY[j]
-			dummyCnt += 1
-		}
-	}
-	// shuffle the dummies
-	rand.Shuffle(len(allDummies), func(i, j int) {
-		allDummies[i], allDummies[j] = allDummies[j], allDummies[i]
-	})
+// shufflerProduct is ShufflerProduct for candidateNum candidates. Over BN254,
+// the packed pairs are built and evaluated chunk by chunk on Workers
+// goroutines, see polyeval, instead of being packed in a new slice.
+func shufflerProduct(field Field, candidateNum int, pairFirst []fr_bn254.Element, pairSecond []fr_bn254.Element, dummies []fr_bn254.Element, publicR fr_bn254.Element) *big.Int {
+	if field.Curve() == ecc.BN254 {
+		pairs := polyeval.Func(len(pairFirst), func(i int) fr_bn254.Element {
+			return packPair(candidateNum, &pairFirst[i], &pairSecond[i])
+		}, publicR, polyeval.DefaultChunk, Workers)
+		dummyProd := polyeval.Slice(dummies, fr_bn254.Element{}, polyeval.DefaultChunk, Workers)
+		pairs.Mul(&pairs, &dummyProd)
+		return pairs.BigInt(new(big.Int))
+	}
+	processedVec := packPairs(candidateNum, pairFirst, pairSecond)
+	return field.Mul(field.PolyEval(processedVec, publicR), field.Prod(dummies))
+}
 
-	commitments := make([]fr_bn254.Element, ClientNum)
-	for i := 0; i < ClientNum; i++ {
-		commitments[i] = clients[i].PublicCom
+// packPairs returns candidateNum * pairFirst[i] + pairSecond[i] for every i.
+func packPairs(candidateNum int, pairFirst []fr_bn254.Element, pairSecond []fr_bn254.Element) []fr_bn254.Element {
+	processedVec := make([]fr_bn254.Element, len(pairFirst))
+	for i := 0; i < len(pairFirst); i++ {
+		processedVec[i] = packPair(candidateNum, &pairFirst[i], &pairSecond[i])
 	}
+	return processedVec
+}
 
-	// Step 2: the server broadcasts the publicR
-	publicR := randomFr()
+// packPair returns candidateNum * first + second.
+func packPair(candidateNum int, first *fr_bn254.Element, second *fr_bn254.Element) fr_bn254.Element {
+	tmp := fr_bn254.NewElement(uint64(candidateNum))
+	tmp.Mul(&tmp, first)
+	tmp.Add(&tmp, second)
+	return tmp
+}
 
-	// Step 3:
-	// now the clients can compute the assignment
-	start = time.Now()
-	allAssignment := make([]VoteCircuit, ClientNum)
+// ShufflePairs is the shuffler releasing the pairs of all the clients, the
+// pairFirst and pairSecond shuffled with the same permutation drawn from rnd.
+func ShufflePairs(rnd *benchrand.Rand, clients []ClientState) ([]fr_bn254.Element, []fr_bn254.Element) {
+	s := shuffle.New(rnd, 2)
 	for i := 0; i < len(clients); i++ {
-		allAssignment[i] = clients[i].GenAssignment(publicR)
+		s.CollectShares(i, shuffle.Records(clients[i].PairFirst, clients[i].PairSecond))
 	}
-	prepTime += time.Since(start)
+	records, _ := s.Output()
+	return shuffle.Column(records, 0), shuffle.Column(records, 1)
+}
 
-	// now the clients can compute the proofs
-	// we only generate proofs for the first MaxNumOfCheckProof clients
-	start = time.Now()
-	allSubmission := make([]ClientSubmissionToServerPlonk, ClientNum)
+// ShuffleDummies is the shuffler releasing the dummies of all the clients,
+// shuffled with a permutation drawn from rnd.
+func ShuffleDummies(rnd *benchrand.Rand, clients []ClientState) []fr_bn254.Element {
+	s := shuffle.New(rnd, 0)
 	for i := 0; i < len(clients); i++ {
-		if i < MaxNumOfCheckProof {
-			allSubmission[i].proof, allSubmission[i].publicWitness = GenProofPlonk(allAssignment[i], &ccs, &pk)
-			allSubmission[i].publicProd = clients[i].PublicProd
-		} else {
-			allSubmission[i].proof = nil
-			allSubmission[i].publicWitness = nil
-			allSubmission[i].publicProd = clients[i].PublicProd
-		}
-	}
-	proofTime := time.Since(start)
-
-	// check how many bytes are written per client
-	proofSize := 0
-	publicWitnessSize := 0
-	// proofSize is the size of the allSubmission[0].proof
-	// publicWitnessSize is the size of the allSubmission[0].publicWitness
-	// we assume that all the proofs and publicWitnesses have the same size
-	if allSubmission[0].proof != nil {
-		(*(allSubmission[0].proof)).WriteTo(&buf)
-		proofSize = buf.Len()
-		buf.Reset()
-	}
-	if allSubmission[0].publicWitness != nil {
-		(*(allSubmission[0].publicWitness)).WriteTo(&buf)
-		publicWitnessSize = buf.Len()
-		buf.Reset()
+		s.CollectDummies(i, clients[i].PrivateY)
 	}
+	_, dummies := s.Output()
+	return dummies
+}
 
-	// now the server can verify the proofs
-	start = time.Now()
+// ClientsProduct multiplies the publicProd of all the submissions.
+func ClientsProduct(field Field, allSubmission []ClientSubmission) *big.Int {
+	prod := big.NewInt(1)
 	for i := 0; i < len(allSubmission); i++ {
-		if i < MaxNumOfCheckProof {
-			verification_err := plonk.Verify(*allSubmission[i].proof, vk, *allSubmission[i].publicWitness)
-			if verification_err != nil {
-				fmt.Printf("verification error in client %v", i)
-			}
-		}
-	}
-	verifyTime := time.Since(start)
-
-	// finally, the server verifies the polynomial evaluations
-	start = time.Now()
-
-	processedVec := make([]fr_bn254.Element, len(shuffledPairFirst))
-	for i := 0; i < len(shuffledPairFirst); i++ {
-		tmp := fr_bn254.NewElement(uint64(CandidateNum))
-		tmp.Mul(&tmp, &shuffledPairFirst[i])
-		tmp.Add(&tmp, &shuffledPairSecond[i])
-		processedVec[i] = tmp
-	}
-	prodFromShuffler := PolyEval(processedVec, publicR)
-	for i := 0; i < len(allDummies); i++ {
-		prodFromShuffler.Mul(&prodFromShuffler, &allDummies[i])
+		prod = field.Mul(prod, allSubmission[i].publicProd)
 	}
-
-	// print the product from the shuffler
-	fmt.Printf("prodFromShuffler: %v\n", prodFromShuffler)
-
-	prodFromClient := fr_bn254.NewElement(uint64(1))
-	for i := 0; i < len(clients); i++ {
-		prodFromClient.Mul(&prodFromClient, &allSubmission[i].publicProd)
-	}
-
-	// now the server compares the prodFromShuffler and the prodFromClients
-	if !prodFromShuffler.Equal(&prodFromClient) {
-		fmt.Printf("The product from the shuffler and the product from the clients are not equal\n")
-	}
-
-	serverTime := time.Since(start)
-
-	// now we see if there is any sole winner
-	comparisonVoteCnt := make([][]uint64, CandidateNum)
-	for i := 0; i < len(comparisonVoteCnt); i++ {
-		comparisonVoteCnt[i] = make([]uint64, CandidateNum)
-	}
-	for i := 0; i < len(shuffledPairFirst); i++ {
-		comparisonVoteCnt[shuffledPairFirst[i].Uint64()][shuffledPairSecond[i].Uint64()] += 1
-	}
-	soleWinner := -1
-	for i := 0; i < CandidateNum; i++ {
-		ok := true
-		for j := 0; j < CandidateNum; j++ {
-			if i != j && comparisonVoteCnt[i][j] <= comparisonVoteCnt[j][i] {
-				ok = false
-				break
-			}
-			if i != j && comparisonVoteCnt[i][j]+comparisonVoteCnt[j][i] != ClientNum {
-				fmt.Print("The comparison is not correct\n")
-			}
-		}
-		if ok {
-			fmt.Printf("The sole winner is %v\n", i)
-			// print the vote for the sole winner
-			for j := 0; j < CandidateNum; j++ {
-				fmt.Printf("%v ", comparisonVoteCnt[i][j])
-			}
-			soleWinner = i
-		}
-	}
-	if soleWinner == -1 {
-		fmt.Printf("There is no sole winner\n")
-	}
-
-	//now we compute the cost
-
-	// now we compute the communication
-	// the client sends the commitments to the server
-	// the server broadcasts the challenge
-	// the client sends the public witness and the proof to the server
-
-	proofRelatedCommCost := uint64(proofSize) // + publicWitnessSize
-	//commCost := (float64(dummyCostPerClient) + float64(proofSize) + float64(publicWitnessSize) + float64(CommitmentSize) + float64(BN254Size)) / 1024
-	dummyCostPerClient := DummyVecLength * uint64(BN254Size)
-	commCost := uint64(proofSize) + uint64(publicWitnessSize) + BN254Size + CommitmentSize + dummyCostPerClient
-
-	log.Print("========Stats (Voting w/ Plonk)======\n")
-	nbConstraints := ccs.GetNbConstraints()
-	log.Printf("Number of Constraints: %v\n", nbConstraints)
-	log.Printf("============================\n")
-
-	log.Printf("=====Communication Cost (bytes)=====\n")
-	log.Printf("Proof: %v\n", proofRelatedCommCost)
-	log.Printf("Other: %v\n", commCost-proofRelatedCommCost)
-	log.Printf("Total: %v\n", commCost)
-	// we now print the breakdown of the communication cost
-	log.Printf("Proof Size %v\n", proofSize)
-	log.Printf("Public Witness Size %v\n", publicWitnessSize)
-	log.Printf("Commitment Size %v\n", CommitmentSize)
-	log.Printf("Challenge Size %v\n", BN254Size)
-	log.Printf("Dummy Size %v\n", dummyCostPerClient)
-	log.Printf("============================\n")
-
-	// now we compute the computation cost
-	//23 parts : prep, proof
-	clientTime := prepTime/time.Duration(ClientNum) + proofTime/time.Duration(MaxNumOfCheckProof)
-	log.Printf("=====Client Computation Cost=====\n")
-	log.Printf("Preparation: %v\n", prepTime/time.Duration(ClientNum))
-	log.Printf("Proof: %v\n", proofTime/time.Duration(MaxNumOfCheckProof))
-	log.Printf("Total: %v\n", clientTime)
-	log.Printf("============================\n")
-
-	// now we compute the server time amortized per client
-	serverTotalTime := serverTime/time.Duration(ClientNum) + verifyTime/time.Duration(MaxNumOfCheckProof)
-	log.Printf("=====Server Computation Cost=====\n")
-	log.Printf("Other: %v\n", serverTime/time.Duration(ClientNum))
-	log.Printf("Verify: %v\n", verifyTime/time.Duration(MaxNumOfCheckProof))
-	log.Printf("Total: %v\n", serverTotalTime)
-	log.Printf("============================\n")
-
-	// now we compute the storage cost
-	// the proving key size is the storage cost
-	log.Printf("=====Storage Cost (Bytes) =====\n")
-	log.Printf("Proving Key: %v\n", provingKeySize)
-	log.Printf("============================\n")
-
-	s := fmt.Sprintf("Voting Plonk, %v, %v, %v, %v, %v, %v, %v\n",
-		nbConstraints,
-		ClientNum,
-		ClientNum-CorruptedNum,
-		clientTime,
-		serverTotalTime,
-		commCost,
-		provingKeySize)
-	file.WriteString(s)
-}
-
-func main() {
-	var err error
-	file, err = os.OpenFile("output-vote.csv", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	if err != nil {
-		panic(err)
-	}
-
-	defer file.Close()
-
-	file.WriteString("Name, #Const, #Client, #Honest, Client Time, Server Time, Comm Cost, Proving Key Size\n")
-
-	for t := 0; t < TestRepeat; t++ {
-		VoteGroth16()
-	}
-
-	for t := 0; t < TestRepeat; t++ {
-		VotePlonk()
-	}
-
-	//ShuffleZKPlonk()
+	return prod
 }
@@ -0,0 +1,1114 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/benchreport"
+	"example/verification/internal/gadget"
+	"example/verification/internal/gnarkshim"
+	"example/verification/internal/hasher"
+	"example/verification/internal/pool"
+)
+
+// mustDummyNum is ComputeDummyNum failing tb on an error.
+func mustDummyNum(tb testing.TB, lambda uint64, n uint64, t uint64) uint64 {
+	tb.Helper()
+	dummyNum, err := ComputeDummyNum(lambda, n, t)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return dummyNum
+}
+
+// setupSystem compiles the vote circuit with system and runs a fresh setup.
+func setupSystem(tb testing.TB, system ProofSystem) (constraint.ConstraintSystem, VerifyingKeyBytes) {
+	defer func(dir string) { KeyDir = dir }(KeyDir)
+	KeyDir = ""
+
+	dummyNum := mustDummyNum(tb, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	circuit := NewVoteCircuit(CandidateNum, int(dummyNum))
+	ccs, err := system.Compile(&circuit)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	vk, err := system.Setup(ccs)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return ccs, vk
+}
+
+// genSubmissions returns the submissions of n fresh clients, all with a proof.
+// They commit with the stub hash: the circuit hashes every dummy into the
+// commitment, which with MiMC makes the proofs of the suite too slow.
+func genSubmissions(tb testing.TB, system ProofSystem, n int) ([]ClientSubmission, VerifyingKeyBytes) {
+	defer func(p ProtocolParams) { Params = p }(Params)
+	Params = ProtocolParams{Hash: hasher.Stub}
+	dummyNum := mustDummyNum(tb, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	ccs, vk := setupSystem(tb, system)
+	field, err := NewField(system.Curve())
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	clients := make([]ClientState, n)
+	allAssignment := make([]VoteAssignment, n)
+	circuits := make([]VoteCircuit, n)
+	publicR := randomFr()
+	for i := 0; i < n; i++ {
+		clients[i].Init(field, dummyNum)
+		allAssignment[i] = clients[i].GenAssignment(publicR)
+		circuits[i] = allAssignment[i].Circuit
+	}
+	proofs, publicWitnesses, err := GenAllProofs(system, circuits, ccs, Workers)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	allSubmission := make([]ClientSubmission, n)
+	for i := 0; i < n; i++ {
+		allSubmission[i] = ClientSubmission{
+			publicWitness: publicWitnesses[i],
+			publicProd:    allAssignment[i].PublicProd,
+			proof:         proofs[i],
+			system:        system,
+		}
+	}
+	return allSubmission, vk
+}
+
+func TestGenSubmissionsWrongPublicProd(t *testing.T) {
+	defer func(p ProtocolParams) { Params = p }(Params)
+	Params = ProtocolParams{Hash: hasher.Stub}
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+
+	systems := []struct {
+		system ProofSystem
+		stage  string
+	}{
+		{NewGroth16System(ecc.BN254), "groth16 prove"},
+		{NewPlonkSystem(ecc.BN254), "plonk prove"},
+	}
+	for _, s := range systems {
+		t.Run(s.system.Name(), func(t *testing.T) {
+			ccs, _ := setupSystem(t, s.system)
+
+			clients := make([]ClientState, 2)
+			allAssignment := make([]VoteAssignment, len(clients))
+			publicR := randomFr()
+			for i := 0; i < len(clients); i++ {
+				clients[i].Init(bn254Field{hash: Params.Hash}, dummyNum)
+				allAssignment[i] = clients[i].GenAssignment(publicR)
+			}
+
+			// client 1 claims a product that does not match its private inputs
+			wrongProd := new(big.Int).Add(allAssignment[1].PublicProd, big.NewInt(1))
+			allAssignment[1].Circuit.PublicProd = frontend.Variable(wrongProd)
+
+			_, err := GenSubmissions(s.system, allAssignment, ccs)
+			if err == nil {
+				t.Fatal("expected an error for the inconsistent assignment")
+			}
+			if !strings.Contains(err.Error(), "client 1") || !strings.Contains(err.Error(), s.stage) {
+				t.Fatalf("error does not identify the client and stage: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenProofIncompleteAssignment(t *testing.T) {
+	defer func(p ProtocolParams) { Params = p }(Params)
+	Params = ProtocolParams{Hash: hasher.Stub}
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	system := NewGroth16System(ecc.BN254)
+	ccs, _ := setupSystem(t, system)
+
+	var client ClientState
+	client.Init(bn254Field{hash: Params.Hash}, dummyNum)
+	assignment := client.GenAssignment(randomFr()).Circuit
+	// an unassigned variable makes the witness construction fail
+	assignment.PublicR = nil
+
+	_, _, err := GenProof(system, assignment, ccs)
+	if err == nil || !strings.Contains(err.Error(), "witness generation") {
+		t.Fatalf("expected a witness generation error, got %v", err)
+	}
+}
+
+func TestGroth16KeysRoundTrip(t *testing.T) {
+	defer func(p ProtocolParams) { Params = p }(Params)
+	Params = ProtocolParams{Hash: hasher.Stub}
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+
+	circuit := NewVoteCircuit(CandidateNum, int(dummyNum))
+	ccs, err := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if _, _, err := LoadGroth16Keys(ecc.BN254, dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist on an empty directory, got %v", err)
+	}
+	// the keys read back prove and verify
+	pk, vk, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveGroth16Keys(pk, vk, dir); err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err = LoadGroth16Keys(ecc.BN254, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var client ClientState
+	client.Init(bn254Field{hash: Params.Hash}, dummyNum)
+	assignment := client.GenAssignment(randomFr()).Circuit
+	witness, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := gnarkshim.ProveGroth16(ccs, pk, witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gnarkshim.VerifyGroth16(proof, vk, publicWitness); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroth16KeysMetadata(t *testing.T) {
+	defer func(candidateNum int) { CandidateNum = candidateNum }(CandidateNum)
+	CandidateNum = 3
+
+	circuit := NewVoteCircuit(CandidateNum, 4)
+	ccs, err := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := gnarkshim.SetupGroth16(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := SaveGroth16Keys(pk, vk, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadGroth16Keys(ecc.BN254, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys of 3 candidates are rejected for 4 candidates
+	CandidateNum = 4
+	if _, _, err := LoadGroth16Keys(ecc.BN254, dir); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("expected ErrKeyMismatch, got %v", err)
+	}
+
+	// keys saved without metadata are rejected too
+	CandidateNum = 3
+	if err := os.Remove(filepath.Join(dir, "groth16.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadGroth16Keys(ecc.BN254, dir); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("no metadata: expected ErrKeyMismatch, got %v", err)
+	}
+
+	// an empty directory holds no keys
+	if _, _, err := LoadGroth16Keys(ecc.BN254, t.TempDir()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("no keys: expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestCompileAndSetupCache(t *testing.T) {
+	defer func(keyDir string, candidateNum int) {
+		KeyDir, CandidateNum = keyDir, candidateNum
+	}(KeyDir, CandidateNum)
+	KeyDir = t.TempDir()
+	CandidateNum = 3
+
+	for _, newSystem := range []func() ProofSystem{
+		func() ProofSystem { return NewGroth16System(ecc.BN254) },
+		func() ProofSystem { return NewPlonkSystem(ecc.BN254) },
+	} {
+		system := newSystem()
+		circuit := NewVoteCircuit(CandidateNum, 4)
+		ccs, vk, err := CompileAndSetup(system, &circuit, VotePublic, VoteManifest(system, 4))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a fresh system proves with the saved circuit and keys
+		reloaded := newSystem()
+		reloadedCCS, reloadedVK, err := CompileAndSetup(reloaded, &circuit, VotePublic, VoteManifest(reloaded, 4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(vk, reloadedVK) {
+			t.Fatalf("%v: the reloaded verifying key differs", system.Name())
+		}
+		checksum, _ := CircuitChecksum(ccs)
+		reloadedChecksum, _ := CircuitChecksum(reloadedCCS)
+		if checksum != reloadedChecksum {
+			t.Fatalf("%v: the reloaded circuit differs", system.Name())
+		}
+		var client ClientState
+		client.Init(bn254Field{}, 4)
+		proof, publicWitness, err := GenProof(reloaded, client.GenAssignment(randomFr()).Circuit, reloadedCCS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := system.Verify(proof, vk, publicWitness); err != nil {
+			t.Fatalf("%v: %v", system.Name(), err)
+		}
+
+		// the saved circuit is checked against the expected public variables
+		if _, _, err := CompileAndSetup(newSystem(), &circuit, VotePublic[1:], VoteManifest(system, 4)); err == nil {
+			t.Fatalf("%v: a cached circuit with other public variables is accepted", system.Name())
+		}
+
+		// the cache of 3 candidates is not reused for 4
+		CandidateNum = 4
+		circuit = NewVoteCircuit(CandidateNum, 4)
+		if _, _, err := CompileAndSetup(newSystem(), &circuit, VotePublic, VoteManifest(system, 4)); !errors.Is(err, ErrKeyMismatch) {
+			t.Fatalf("%v: expected ErrKeyMismatch, got %v", system.Name(), err)
+		}
+		CandidateNum = 3
+	}
+}
+
+// misTaggedNullifierCircuit is NullifierCircuit with the secret key tagged
+// public by mistake.
+type misTaggedNullifierCircuit struct {
+	PrivateSecretKey      frontend.Variable `gnark:",public"`
+	PublicElectionID      frontend.Variable `gnark:",public"`
+	PublicVoterCommitment frontend.Variable `gnark:",public"`
+	PublicNullifier       frontend.Variable `gnark:",public"`
+	Hash                  hasher.HashConfig `gnark:"-"`
+}
+
+func (circuit *misTaggedNullifierCircuit) Define(api frontend.API) error {
+	return (*NullifierCircuit)(circuit).Define(api)
+}
+
+func TestCompileAndSetupPublicLayout(t *testing.T) {
+	defer func(keyDir string) { KeyDir = keyDir }(KeyDir)
+	KeyDir = t.TempDir()
+
+	for _, system := range []ProofSystem{NewGroth16System(ecc.BN254), NewPlonkSystem(ecc.BN254)} {
+		if _, _, err := CompileAndSetup(system, &NullifierCircuit{}, NullifierPublic, NullifierManifest(system)); err != nil {
+			t.Fatalf("%v: %v", system.Name(), err)
+		}
+
+		manifest := NullifierManifest(system)
+		manifest.Circuit = "mistagged"
+		_, _, err := CompileAndSetup(system, &misTaggedNullifierCircuit{}, NullifierPublic, manifest)
+		if !errors.Is(err, gnarkshim.ErrPublicLayout) {
+			t.Fatalf("%v: a mis-tagged circuit: got %v, expected ErrPublicLayout", system.Name(), err)
+		}
+		if _, err := os.Stat(filepath.Join(KeyDir, manifest.Circuit+"-"+manifest.Backend)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%v: the keys of a mis-tagged circuit were saved", system.Name())
+		}
+	}
+}
+
+// TestBallotCircuitsPublic compiles every ballot circuit and checks its
+// public variables against the layout the server expects.
+func TestBallotCircuitsPublic(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		circuit func() frontend.Circuit
+		public  []string
+	}{
+		{"vote", func() frontend.Circuit { c := NewVoteCircuit(CandidateNum, 2); return &c }, VotePublic},
+		{"approval", func() frontend.Circuit { c := NewApprovalVoteCircuit(CandidateNum, 2); return &c }, VotePublic},
+		{"borda", func() frontend.Circuit { c := NewBordaVoteCircuit(CandidateNum, 2); return &c }, VotePublic},
+		{"plurality", func() frontend.Circuit { c := NewPluralityVoteCircuit(CandidateNum, 2); return &c }, VotePublic},
+		{"quadratic", func() frontend.Circuit { c := NewQuadraticVoteCircuit(CandidateNum, 2); return &c }, QuadraticPublic},
+		{"weighted", func() frontend.Circuit { c := NewWeightedVoteCircuit(CandidateNum, 2); return &c }, WeightedPublic},
+		{"bucket", func() frontend.Circuit { c := NewBucketVoteCircuit(CandidateNum, 2, 4); return &c }, BucketPublic(4)},
+		{"delegation", func() frontend.Circuit { c := NewDelegationCircuit(2, CandidateNum, 2); return &c }, DelegationPublic(2)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ccs, err := gnarkshim.CompileR1CS(ecc.BN254, tc.circuit())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := gnarkshim.CheckPublic(ccs, tc.public); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVoteCircuitRuntimeCandidateNum(t *testing.T) {
+	defer func(n int) { CandidateNum = n }(CandidateNum)
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+
+	for _, n := range []int{2, 5} {
+		CandidateNum = n
+		var client ClientState
+		client.Init(bn254Field{}, dummyNum)
+		circuit := NewVoteCircuit(n, int(dummyNum))
+		assignment := client.GenAssignment(randomFr()).Circuit
+		if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("%v candidates: %v", n, err)
+		}
+	}
+}
+
+// largeCandidateNums are the numbers of candidates, up to MaxCandidateNum,
+// where the ballot has hundreds of pairs.
+var largeCandidateNums = []int{16, 24, MaxCandidateNum}
+
+// TestVoteCircuitManyCandidates compiles the circuit and solves the witness of
+// one client up to MaxCandidateNum candidates. The proofs at these sizes are
+// timed by BenchmarkVoteProveGroth16.
+func TestVoteCircuitManyCandidates(t *testing.T) {
+	defer func(n int) { CandidateNum = n }(CandidateNum)
+	const dummyNum = 8
+
+	perPair := math.Inf(1)
+	for _, n := range largeCandidateNums {
+		CandidateNum = n
+		pairNum := n * (n - 1) / 2
+
+		// every ordered pair of distinct candidates packs to its own value,
+		// below n * n, far from the size of the field
+		packed := make(map[uint64]bool, 2*pairNum)
+		for a := 0; a < n; a++ {
+			for b := 0; b < n; b++ {
+				if a == b {
+					continue
+				}
+				first, second := fr_bn254.NewElement(uint64(a)), fr_bn254.NewElement(uint64(b))
+				x := packPair(n, &first, &second)
+				if !x.IsUint64() || x.Uint64() >= uint64(n*n) || packed[x.Uint64()] {
+					t.Fatalf("%v candidates: the pair (%v, %v) packs to %v", n, a, b, x.String())
+				}
+				packed[x.Uint64()] = true
+			}
+		}
+
+		circuit := NewVoteCircuit(n, dummyNum)
+		ccs, err := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+		if err != nil {
+			t.Fatalf("%v candidates: %v", n, err)
+		}
+		var client ClientState
+		client.InitRand(benchrand.New(1), bn254Field{}, dummyNum)
+		if len(client.PairFirst) != pairNum {
+			t.Fatalf("%v candidates: %v pairs, expected %v", n, len(client.PairFirst), pairNum)
+		}
+		assignment := client.GenAssignment(randomFr()).Circuit
+		w, err := gnarkshim.NewWitness(ecc.BN254, &assignment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ccs.IsSolved(w); err != nil {
+			t.Fatalf("%v candidates: %v", n, err)
+		}
+
+		// the hash of every pair dominates: the constraints grow with the
+		// pairs, not faster
+		nbConstraints := ccs.GetNbConstraints()
+		t.Logf("%v candidates: %v pairs, %v constraints", n, pairNum, nbConstraints)
+		ratio := float64(nbConstraints) / float64(pairNum)
+		if ratio > perPair {
+			t.Fatalf("%v candidates: %.0f constraints per pair, more than the %.0f of fewer candidates", n, ratio, perPair)
+		}
+		perPair = ratio
+
+		// the tally of a few ballots
+		const voters = 5
+		var pairFirst, pairSecond []fr_bn254.Element
+		rnd := benchrand.New(uint64(n))
+		for i := 0; i < voters; i++ {
+			var c ClientState
+			c.InitRand(rnd, bn254Field{}, 0)
+			pairFirst = append(pairFirst, c.PairFirst...)
+			pairSecond = append(pairSecond, c.PairSecond...)
+		}
+		tally, err := NewTallyPairs(pairFirst, pairSecond, n)
+		if err != nil {
+			t.Fatalf("%v candidates: %v", n, err)
+		}
+		if !tally.Consistent(voters) {
+			t.Fatalf("%v candidates: inconsistent tally of %v ballots", n, voters)
+		}
+		var borda uint64
+		for _, score := range tally.Borda() {
+			borda += score
+		}
+		if borda != uint64(voters*pairNum) {
+			t.Fatalf("%v candidates: Borda scores sum to %v, expected %v", n, borda, voters*pairNum)
+		}
+	}
+
+	CandidateNum = MaxCandidateNum + 1
+	if err := validateConfig(); err == nil {
+		t.Fatalf("-candidates %v: no error", CandidateNum)
+	}
+}
+
+func TestVoteCircuitCurves(t *testing.T) {
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	circuit := NewVoteCircuit(CandidateNum, int(dummyNum))
+
+	for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+		field, err := NewField(curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var client ClientState
+		client.Init(field, dummyNum)
+		assignment := client.GenAssignment(randomFr()).Circuit
+		if err := test.IsSolved(&circuit, &assignment, curve.ScalarField()); err != nil {
+			t.Fatalf("%v: %v", curve, err)
+		}
+	}
+
+	// the derived values only hold in the field they were computed in
+	var client ClientState
+	client.Init(bn254Field{}, dummyNum)
+	assignment := client.GenAssignment(randomFr()).Circuit
+	if err := test.IsSolved(&circuit, &assignment, ecc.BLS12_381.ScalarField()); err == nil {
+		t.Fatal("a BN254 assignment solved the BLS12-381 circuit")
+	}
+}
+
+func TestVoteCircuitMask(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewVoteCircuit(CandidateNum, 4)
+
+	var honest ClientState
+	honest.Init(bn254Field{}, 4)
+	assignment := honest.GenAssignment(randomFr()).Circuit
+	assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// the commitment and the product are consistent with the mask, but the
+	// mask is not the product of the dummies
+	var forged ClientState
+	forged.Init(bn254Field{}, 4)
+	forged.PrivateMask = bn254Field{}.Mul(forged.PrivateMask, big.NewInt(2))
+	forged.PublicCom = forged.Opening().Commit(bn254Field{})
+	assignment = forged.GenAssignment(randomFr()).Circuit
+	assert.ProverFailed(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// dummies other than the committed ones: [mask, 1, 1, 1] multiply to the
+	// mask, but do not open the commitment
+	assignment = honest.GenAssignment(randomFr()).Circuit
+	assignment.DummyVec = []frontend.Variable{honest.PrivateMask, 1, 1, 1}
+	err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+	if f := gadget.ParseFailure(err); err == nil || f.Invariant != gadget.Commitment {
+		t.Fatalf("dummies other than the committed ones: got %v, expected the commitment to fail", err)
+	}
+	assert.ProverFailed(&circuit, &assignment, test.WithCurves(ecc.BN254))
+}
+
+// TestVoteCircuitProver proves the ballot of a client on every curve, and
+// refuses the ballots breaking each constraint of the circuit. The forged
+// ballots are committed to, so only the constraint under test fails.
+func TestVoteCircuitProver(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewVoteCircuit(CandidateNum, 4)
+
+	for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+		field, err := NewField(curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicR := randomFr()
+		var client ClientState
+		client.InitRand(benchrand.New(1), field, 4)
+		assignment := client.GenAssignment(publicR).Circuit
+		assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(curve))
+
+		sorted := make([]uint64, CandidateNum)
+		for i := range sorted {
+			sorted[i] = uint64(i)
+		}
+		ranked := rankedClient(field, sorted, 4)
+		assignment = ranked.GenAssignment(publicR).Circuit
+		if err := test.IsSolved(&circuit, &assignment, curve.ScalarField()); err != nil {
+			t.Fatalf("%v: the ranking 0, 1, ...: %v", curve, err)
+		}
+
+		// the first pair reversed, against the ranking
+		notMatching := rankedClient(field, sorted, 4)
+		notMatching.PairFirst[0], notMatching.PairSecond[0] = notMatching.PairSecond[0], notMatching.PairFirst[0]
+		recommit(field, &notMatching)
+		assignment = notMatching.GenAssignment(publicR).Circuit
+		assert.ProverFailed(&circuit, &assignment, test.WithCurves(curve))
+
+		// a candidate ranked twice
+		sorted[1] = sorted[0]
+		notPermutation := rankedClient(field, sorted, 4)
+		assignment = notPermutation.GenAssignment(publicR).Circuit
+		assert.ProverFailed(&circuit, &assignment, test.WithCurves(curve))
+
+		assignment = client.GenAssignment(publicR).Circuit
+		assignment.PublicProd = field.Mul(client.ComputePolyEval(publicR), big.NewInt(2))
+		assert.ProverFailed(&circuit, &assignment, test.WithCurves(curve))
+	}
+}
+
+// rankedClient is a client of field whose ranking is replaced by sorted, with
+// the pairs, the packed pairs and the commitment recomputed to match.
+func rankedClient(field Field, sorted []uint64, dummyNum uint64) ClientState {
+	var c ClientState
+	c.Init(field, dummyNum)
+	for i := range c.SortedCandidate {
+		c.SortedCandidate[i] = fr_bn254.NewElement(sorted[i])
+	}
+	k := 0
+	for i := 0; i < CandidateNum; i++ {
+		for j := i + 1; j < CandidateNum; j++ {
+			c.PairFirst[k], c.PairSecond[k] = c.SortedCandidate[i], c.SortedCandidate[j]
+			k++
+		}
+	}
+	recommit(field, &c)
+	return c
+}
+
+// recommit recomputes the packed pairs of c and its commitment under field
+// from its pairs.
+func recommit(field Field, c *ClientState) {
+	c.PrivateX = packPairs(CandidateNum, c.PairFirst, c.PairSecond)
+	c.PublicCom = c.Opening().Commit(field)
+}
+
+func TestVoteCircuitCandidateRange(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewVoteCircuit(CandidateNum, 4)
+
+	sorted := make([]uint64, CandidateNum)
+	for i := range sorted {
+		sorted[i] = uint64(i)
+	}
+	client := rankedClient(bn254Field{}, sorted, 4)
+	assignment := client.GenAssignment(randomFr()).Circuit
+	assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// an index of CandidateNum is caught by the range check
+	sorted[0] = uint64(CandidateNum)
+	client = rankedClient(bn254Field{}, sorted, 4)
+	assignment = client.GenAssignment(randomFr()).Circuit
+	err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField())
+	if err == nil || !strings.Contains(err.Error(), "assertIsLessOrEqual") || gadget.ParseFailure(err).Invariant != gadget.Range {
+		t.Fatalf("an index out of range: got %v, expected the range check to fail", err)
+	}
+	assert.ProverFailed(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// indices in range that are not a permutation are still refused
+	sorted[0] = 1
+	client = rankedClient(bn254Field{}, sorted, 4)
+	assignment = client.GenAssignment(randomFr()).Circuit
+	assert.ProverFailed(&circuit, &assignment, test.WithCurves(ecc.BN254))
+}
+
+func TestVoteCircuitHash(t *testing.T) {
+	defer func(p ProtocolParams) { Params = p }(Params)
+	Params = ProtocolParams{Hash: hasher.Stub}
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+
+	field, err := NewField(ecc.BN254)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var client ClientState
+	client.Init(field, dummyNum)
+	if client.PublicCom.Cmp(client.Opening().Commit(bn254Field{})) == 0 {
+		t.Fatal("the stub commitment is the MiMC commitment")
+	}
+	circuit := NewVoteCircuit(CandidateNum, int(dummyNum))
+	assignment := client.GenAssignment(randomFr()).Circuit
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+	nullifier := client.GenNullifierAssignment(ElectionID)
+	if err := test.IsSolved(&NullifierCircuit{Hash: Params.Hash}, &nullifier, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the circuit checks the commitment with its own hash
+	circuit.Hash = hasher.MiMC
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("a stub commitment solved the MiMC circuit")
+	}
+}
+
+func TestVoteCircuitPoseidon(t *testing.T) {
+	defer func(p ProtocolParams) { Params = p }(Params)
+	Params = ProtocolParams{Hash: hasher.Poseidon}
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+
+	field, err := NewField(ecc.BN254)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var client ClientState
+	client.Init(field, dummyNum)
+	circuit := NewVoteCircuit(CandidateNum, int(dummyNum))
+	assignment := client.GenAssignment(randomFr()).Circuit
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the poseidon instance is the one of bn254
+	if _, err := NewField(ecc.BLS12_381); err == nil {
+		t.Fatal("no error for poseidon over bls12-381")
+	}
+}
+
+// BenchmarkCommitmentConstraints reports the R1CS constraints of the
+// commitment of the vote circuit under every hash: the stub hash is linear and
+// costs no constraint, so the commitment takes the difference with it.
+func BenchmarkCommitmentConstraints(b *testing.B) {
+	defer func(p ProtocolParams) { Params = p }(Params)
+	count := func(h hasher.HashConfig) int {
+		Params = ProtocolParams{Hash: h}
+		circuit := NewVoteCircuit(CandidateNum, 0)
+		ccs, err := gnarkshim.CompileR1CS(ecc.BN254, &circuit)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return ccs.GetNbConstraints()
+	}
+	base := count(hasher.Stub)
+	for _, h := range []hasher.HashConfig{hasher.MiMC, hasher.Poseidon} {
+		b.Run(h.String(), func(b *testing.B) {
+			var n int
+			for k := 0; k < b.N; k++ {
+				n = count(h)
+			}
+			b.ReportMetric(float64(n-base), "commitment-constraints")
+			b.ReportMetric(float64(n), "constraints")
+		})
+	}
+}
+
+// seededRun is the vote pipeline of a run of seed, without the proofs.
+type seededRun struct {
+	commitments           []*big.Int
+	pairFirst, pairSecond []fr_bn254.Element
+	dummies               []fr_bn254.Element
+	prodFromShuffler      *big.Int
+	prodFromClients       *big.Int
+}
+
+func runSeeded(t *testing.T, seed uint64) seededRun {
+	rnd := benchrand.New(seed)
+	clients := make([]ClientState, 20)
+	for i := 0; i < len(clients); i++ {
+		clients[i].InitRand(rnd, bn254Field{}, 8)
+	}
+	var run seededRun
+	for i := 0; i < len(clients); i++ {
+		run.commitments = append(run.commitments, clients[i].PublicCom)
+	}
+	run.pairFirst, run.pairSecond = ShufflePairs(rnd, clients)
+	run.dummies = ShuffleDummies(rnd, clients)
+	publicR, err := DeriveChallenge(rnd, clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run.prodFromShuffler = ShufflerProduct(bn254Field{}, run.pairFirst, run.pairSecond, run.dummies, publicR)
+	run.prodFromClients = big.NewInt(1)
+	for i := 0; i < len(clients); i++ {
+		run.prodFromClients = bn254Field{}.Mul(run.prodFromClients, clients[i].ComputePolyEval(publicR))
+	}
+	if run.prodFromShuffler.Cmp(run.prodFromClients) != 0 {
+		t.Fatal("the product from the shuffler differs from the product from the clients")
+	}
+	return run
+}
+
+func TestSeededRunsAreReproducible(t *testing.T) {
+	a, b := runSeeded(t, 42), runSeeded(t, 42)
+	for i := range a.commitments {
+		if a.commitments[i].Cmp(b.commitments[i]) != 0 {
+			t.Fatalf("client %v: the commitments differ", i)
+		}
+	}
+	if !reflect.DeepEqual(a.pairFirst, b.pairFirst) || !reflect.DeepEqual(a.pairSecond, b.pairSecond) {
+		t.Fatal("the shuffled pairs differ")
+	}
+	if !reflect.DeepEqual(a.dummies, b.dummies) {
+		t.Fatal("the shuffled dummies differ")
+	}
+	if a.prodFromShuffler.Cmp(b.prodFromShuffler) != 0 || a.prodFromClients.Cmp(b.prodFromClients) != 0 {
+		t.Fatal("the products differ")
+	}
+
+	// another seed, or no seed, is another run
+	for _, seed := range []uint64{43, 0} {
+		if c := runSeeded(t, seed); c.commitments[0].Cmp(a.commitments[0]) == 0 || reflect.DeepEqual(c.pairFirst, a.pairFirst) {
+			t.Fatalf("seed %v repeats the run of seed 42", seed)
+		}
+	}
+}
+
+// TestGenAssignmentConcurrent generates the assignments of the clients on
+// several goroutines, which the race detector checks, and compares each
+// product with one recomputed from the client.
+func TestGenAssignmentConcurrent(t *testing.T) {
+	const n, workers = 100, 8
+	field := bn254Field{}
+	rnd := benchrand.New(9)
+	clients := make([]ClientState, n)
+	for i := range clients {
+		clients[i].InitRand(rnd, field, 2)
+	}
+	publicR := rnd.Fr()
+	allAssignment := make([]VoteAssignment, n)
+	pool.RunAll(n, workers, func(i int) error {
+		allAssignment[i] = clients[i].GenAssignment(publicR)
+		return nil
+	})
+
+	for i := range clients {
+		prod := clients[i].ComputePolyEval(publicR)
+		if allAssignment[i].PublicProd.Cmp(prod) != 0 {
+			t.Fatalf("client %v: the product of the assignment is not the one of the client", i)
+		}
+		if allAssignment[i].Circuit.PublicProd.(*big.Int).Cmp(prod) != 0 {
+			t.Fatalf("client %v: the circuit is assigned another product", i)
+		}
+	}
+	// another challenge leaves the first assignments as they are
+	other := clients[0].GenAssignment(rnd.Fr())
+	if other.PublicProd.Cmp(allAssignment[0].PublicProd) == 0 {
+		t.Fatal("two challenges give the same product")
+	}
+	if allAssignment[0].PublicProd.Cmp(clients[0].ComputePolyEval(publicR)) != 0 {
+		t.Fatal("the product of the first challenge changed")
+	}
+}
+
+func TestGenAllProofsParallel(t *testing.T) {
+	defer func(n int, p ProtocolParams) { CandidateNum, Params = n, p }(CandidateNum, Params)
+	CandidateNum, Params = 3, ProtocolParams{Hash: hasher.Stub}
+	dummyNum := mustDummyNum(t, Lambda, uint64(ClientNum), uint64(CorruptedNum))
+	system := NewGroth16System(ecc.BN254)
+	ccs, vk := setupSystem(t, system)
+
+	clients := make([]ClientState, 8)
+	allAssignment := make([]VoteCircuit, len(clients))
+	publicR := randomFr()
+	for i := 0; i < len(clients); i++ {
+		clients[i].Init(bn254Field{hash: Params.Hash}, dummyNum)
+		allAssignment[i] = clients[i].GenAssignment(publicR).Circuit
+	}
+
+	proofs, _, err := GenAllProofs(system, allAssignment, ccs, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each proof must verify against the public inputs of the client at the
+	// same index, which only holds if the pool preserved the order
+	for i := 0; i < len(clients); i++ {
+		publicWitness, err := gnarkshim.NewPublicWitness(ecc.BN254, &allAssignment[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := system.Verify(proofs[i], vk, publicWitness); err != nil {
+			t.Fatalf("client %v: %v", i, err)
+		}
+	}
+}
+
+func TestVerifyAllReportsCorruptedProof(t *testing.T) {
+	defer func(n int) { Workers = n }(Workers)
+	allSubmission, vk := genSubmissions(t, NewGroth16System(ecc.BN254), 5)
+
+	// client 3 sends the public witness of client 4
+	allSubmission[3].publicWitness = allSubmission[4].publicWitness
+
+	Workers = 3
+	errs := VerifyAll(allSubmission, vk, false)
+	for i := 0; i < len(errs); i++ {
+		if (errs[i] != nil) != (i == 3) {
+			t.Fatalf("client %v: unexpected result %v", i, errs[i])
+		}
+	}
+	if !strings.HasPrefix(errs[3].Error(), "client 3:") {
+		t.Fatalf("error does not name the client: %v", errs[3])
+	}
+
+	// with a single worker the proofs are checked in order, so everything
+	// after the failure is skipped
+	Workers = 1
+	errs = VerifyAll(allSubmission, vk, true)
+	if errs[2] != nil || errs[3] == nil || !errors.Is(errs[4], ErrVerificationSkipped) {
+		t.Fatalf("unexpected fail-fast results %v", errs)
+	}
+}
+
+func TestProofSystems(t *testing.T) {
+	for _, system := range []ProofSystem{NewGroth16System(ecc.BN254), NewPlonkSystem(ecc.BN254)} {
+		t.Run(system.Name(), func(t *testing.T) {
+			allSubmission, vk := genSubmissions(t, system, 3)
+			if errs := VerifySubmissions(allSubmission, vk); len(errs) != 0 {
+				t.Fatal(errs)
+			}
+
+			// client 0 sends the proof of client 1
+			allSubmission[0].proof = allSubmission[1].proof
+			errs := VerifySubmissions(allSubmission, vk)
+			if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "client 0:") {
+				t.Fatalf("unexpected results %v", errs)
+			}
+		})
+	}
+}
+
+func TestDummyNumDoesNotLeakAcrossRuns(t *testing.T) {
+	configs := []struct {
+		lambda, n, t uint64
+	}{
+		{80, 1000, 500},
+		{40, 100, 10},
+	}
+	for _, config := range configs {
+		dummyNum := mustDummyNum(t, config.lambda, config.n, config.t)
+		var client ClientState
+		client.Init(bn254Field{}, dummyNum)
+		if uint64(len(client.PrivateY)) != dummyNum {
+			t.Fatalf("lambda %v, n %v, t %v: got %v dummies, expected %v",
+				config.lambda, config.n, config.t, len(client.PrivateY), dummyNum)
+		}
+	}
+}
+
+func TestComputeDummyNumNeedsHonestClients(t *testing.T) {
+	for _, config := range []struct{ n, t uint64 }{{2, 1}, {2, 0}, {10, 8}, {10, 10}, {10, 11}} {
+		got, err := ComputeDummyNum(80, config.n, config.t)
+		if err == nil {
+			t.Fatalf("n %v, t %v: got %v dummies, expected an error", config.n, config.t, got)
+		}
+		// the error suggests the clients the corrupted ones need
+		if minimum := fmt.Sprintf("at least %v clients", config.t+MinHonestNum); !strings.Contains(err.Error(), minimum) {
+			t.Fatalf("n %v, t %v: %q does not suggest %q", config.n, config.t, err, minimum)
+		}
+	}
+	if _, err := ComputeDummyNum(80, 10, 7); err != nil {
+		t.Fatalf("3 honest clients: %v", err)
+	}
+
+	defer func(n, t int) { ClientNum, CorruptedNum = n, t }(ClientNum, CorruptedNum)
+	for _, config := range []struct{ n, t int }{{2, 1}, {2, 0}, {5, 3}} {
+		ClientNum, CorruptedNum = config.n, config.t
+		if err := validateConfig(); err == nil {
+			t.Fatalf("-clients %v -corrupted %v: no error", config.n, config.t)
+		}
+	}
+}
+
+func TestBelowSecurityTarget(t *testing.T) {
+	for _, config := range []struct{ lambda, n, t uint64 }{{80, 1000, 500}, {40, 12, 2}, {128, 100, 10}, {80, 10, 7}} {
+		// the dummies meet the target when (d - 2) (log2(n - t) - log2(e))
+		// reaches 2 lambda + 254
+		bits := math.Log2(float64(config.n-config.t)) - math.Log2(e)
+		for d := uint64(1); d < 400; d++ {
+			below, err := BelowSecurityTarget(d, config.lambda, config.n, config.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := float64(d-2)*bits < float64(2*config.lambda+254); d >= 2 && below != expected {
+				t.Fatalf("lambda %v, n %v, t %v: %v dummies flagged %v, expected %v", config.lambda, config.n, config.t, d, below, expected)
+			}
+			if d < 2 && !below {
+				t.Fatalf("lambda %v, n %v, t %v: %v dummies are not below the target", config.lambda, config.n, config.t, d)
+			}
+		}
+	}
+	if _, err := BelowSecurityTarget(100, 80, 10, 8); err == nil {
+		t.Fatal("no error without enough honest clients")
+	}
+}
+
+func TestPolyEvalEmpty(t *testing.T) {
+	for _, field := range []Field{bn254Field{}, bls12381Field{}} {
+		if got := field.PolyEval(nil, randomFr()); got.Cmp(big.NewInt(1)) != 0 {
+			t.Fatalf("%v: the empty product is %v, expected 1", field.Curve(), got)
+		}
+	}
+}
+
+func TestInitClientsParallel(t *testing.T) {
+	const n, dummyNum = 37, 4
+	clients := initClients(n, 5, bn254Field{}, dummyNum)
+	if len(clients) != n {
+		t.Fatalf("got %v clients, expected %v", len(clients), n)
+	}
+	coms := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		c := &clients[i]
+		if c.PublicCom == nil || len(c.PrivateY) != dummyNum {
+			t.Fatalf("client %v is not initialized", i)
+		}
+		// the commitment opens to the state of the client itself
+		if c.Opening().Commit(bn254Field{}).Cmp(c.PublicCom) != 0 {
+			t.Fatalf("client %v: the commitment does not match the state", i)
+		}
+		if coms[c.PublicCom.String()] {
+			t.Fatalf("client %v: two clients drew the same randomness", i)
+		}
+		coms[c.PublicCom.String()] = true
+	}
+
+	defer func(n, t int, lambda uint64) { ClientNum, CorruptedNum, Lambda = n, t, lambda }(ClientNum, CorruptedNum, Lambda)
+	ClientNum, CorruptedNum, Lambda = 100, 10, 40
+	clients, err := InitClientsParallel(3, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 3 || uint64(len(clients[2].PrivateY)) != mustDummyNum(t, 40, 100, 10) {
+		t.Fatal("InitClientsParallel does not follow the configuration")
+	}
+}
+
+// BenchmarkInitClients initializes the 1000 clients of the default
+// configuration serially and on Workers goroutines.
+func BenchmarkInitClients(b *testing.B) {
+	const n = 1000
+	dummyNum := mustDummyNum(b, 80, n, 500)
+	b.Run("serial", func(b *testing.B) {
+		for k := 0; k < b.N; k++ {
+			clients := make([]ClientState, n)
+			for i := 0; i < n; i++ {
+				clients[i].Init(bn254Field{}, dummyNum)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for k := 0; k < b.N; k++ {
+			initClients(n, Workers, bn254Field{}, dummyNum)
+		}
+	})
+}
+
+// TestOpenReporters reports a run in each format: the CSV row is always
+// written, the JSON object only next to it.
+func TestOpenReporters(t *testing.T) {
+	result := benchreport.BenchmarkResult{Program: "vote", Backend: "Groth16", ClientNum: 12}
+	for _, format := range []benchreport.Format{benchreport.FormatCSV, benchreport.FormatJSON} {
+		dir := t.TempDir()
+		reps, files, err := openReporters(dir, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rep := range reps {
+			if err := rep.Report(result); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, f := range files {
+			f.Close()
+		}
+		csvOut, err := os.ReadFile(filepath.Join(dir, "output-vote.csv"))
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if rows := strings.Count(string(csvOut), "\n"); rows != 2 {
+			t.Fatalf("%v: %v CSV lines, expected the header and a row", format, rows)
+		}
+		jsonOut, err := os.ReadFile(filepath.Join(dir, "output-vote.jsonl"))
+		if format == benchreport.FormatCSV {
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("csv: got %v, expected no JSON lines", err)
+			}
+			continue
+		}
+		var got benchreport.BenchmarkResult
+		if err != nil || json.Unmarshal(jsonOut, &got) != nil || got != result {
+			t.Fatalf("json: read %q, %v", jsonOut, err)
+		}
+	}
+}
+
+func TestVoteReportsJSON(t *testing.T) {
+	defer func(n, c, k int, l uint64, keyDir string, r []*benchreport.Reporter) {
+		ClientNum, CandidateNum, CorruptedNum, Lambda, KeyDir, reporters = n, c, k, l, keyDir, r
+	}(ClientNum, CandidateNum, CorruptedNum, Lambda, KeyDir, reporters)
+	defer func(p ProtocolParams) { Params = p }(Params)
+	ClientNum, CandidateNum, CorruptedNum, Lambda, KeyDir = 12, 3, 2, 40, ""
+	Params = ProtocolParams{Hash: hasher.Stub}
+	var out bytes.Buffer
+	reporters = []*benchreport.Reporter{benchreport.NewReporter(&out, benchreport.FormatJSON)}
+
+	returned, err := Vote(NewGroth16System(ecc.BN254), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result benchreport.BenchmarkResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result != returned {
+		t.Fatalf("reported %+v, returned %+v", result, returned)
+	}
+	if result.Backend != "Groth16" || result.ClientNum != 12 || result.CandidateNum != 3 {
+		t.Fatalf("unexpected configuration in %+v", result)
+	}
+	v := reflect.ValueOf(result)
+	for i := 0; i < v.NumField(); i++ {
+		// only a run of -dummies below the security target sets BelowTarget
+		if v.Type().Field(i).Name == "BelowTarget" {
+			continue
+		}
+		if v.Field(i).IsZero() {
+			t.Errorf("%v is zero", v.Type().Field(i).Name)
+		}
+	}
+	if result.CommCost.Total != result.CommCost.Proof+result.CommCost.Other {
+		t.Fatalf("inconsistent communication cost %+v", result.CommCost)
+	}
+}
+
+func TestShufflerProductStreamed(t *testing.T) {
+	defer func(n int) { Workers = n }(Workers)
+	rnd := benchrand.New(5)
+	clients := make([]ClientState, 20)
+	for i := range clients {
+		clients[i].InitRand(rnd, bn254Field{}, 3)
+	}
+	pairFirst, pairSecond := ShufflePairs(rnd, clients)
+	dummies := ShuffleDummies(rnd, clients)
+	publicR := randomFr()
+
+	// the product before the streaming evaluation
+	field := bn254Field{}
+	want := field.Mul(field.PolyEval(packPairs(CandidateNum, pairFirst, pairSecond), publicR), field.Prod(dummies))
+	for _, workers := range []int{1, 3, 16} {
+		Workers = workers
+		if got := ShufflerProduct(field, pairFirst, pairSecond, dummies, publicR); got.Cmp(want) != 0 {
+			t.Fatalf("%v workers: got %v, expected %v", workers, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"syscall/js"
+)
+
+// The wasm client proves a ballot in the browser. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o client.wasm ./vote
+//
+// and load it with the wasm_exec.js of the Go distribution. It registers
+//
+//	prepareSubmission(client: string, provingKey: Uint8Array): string | Error
+//
+// which runs PrepareSubmission and returns the submission, or an Error. The
+// files touching the disk or the network, the command line and the plonk
+// backend are not built in: the network rounds are left to the page.
+// testdata/wasm_roundtrip.js drives it from node, see
+// TestPrepareSubmissionWasm.
+
+func main() {
+	js.Global().Set("prepareSubmission", js.FuncOf(prepareSubmission))
+	// the exported function lives as long as the program
+	select {}
+}
+
+func prepareSubmission(this js.Value, args []js.Value) any {
+	if len(args) != 2 {
+		return jsError("prepareSubmission: expected the client and the proving key")
+	}
+	provingKey := make([]byte, args[1].Get("length").Int())
+	js.CopyBytesToGo(provingKey, args[1])
+	submission, err := PrepareSubmission([]byte(args[0].String()), provingKey)
+	if err != nil {
+		return jsError("prepareSubmission: " + err.Error())
+	}
+	return string(submission)
+}
+
+// jsError returns msg as a JS Error: a panic in a js.FuncOf would stop the
+// program instead of throwing.
+func jsError(msg string) any {
+	return js.Global().Get("Error").New(msg)
+}
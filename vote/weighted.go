@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/gadget"
+	"example/verification/internal/hasher"
+)
+
+// In a weighted election a client of weight w counts as w voters. It ranks
+// the candidates as in VoteCircuit, and sends the shuffler every pair of its
+// ranking w times: the tally counts the pairs and needs no change, and a
+// consistent tally sums up to the total weight instead of the number of
+// voters. Its PublicProd is then the product of its pairs raised to w, times
+// the mask, which the circuit checks against the public weight.
+
+// WeightBits is the size of a weight: the circuit checks that PublicWeight is
+// in [0, 2^WeightBits).
+const WeightBits = 16
+
+// WeightedPublic are the public variables of WeightedVoteCircuit, in the
+// order of the public witness.
+var WeightedPublic = []string{"PublicR", "PublicProd", "PublicCommitment", "PublicWeight"}
+
+type WeightedVoteCircuit struct {
+	// the ranking and its pairs, see VoteCircuit
+	SortedCandidate []frontend.Variable
+	PairFirstVar    []frontend.Variable
+	PairSecondVar   []frontend.Variable
+
+	// The following are for the polynomial evaluation
+	PrivateMask frontend.Variable
+	// DummyVec are the dummies the client sends to the shuffler; the mask is
+	// their product, as the server multiplies them in.
+	DummyVec   []frontend.Variable
+	PublicR    frontend.Variable `gnark:",public"`
+	PublicProd frontend.Variable `gnark:",public"`
+
+	// The following are for the commitment
+	PublicCommitment frontend.Variable `gnark:",public"`
+	PrivateSalt      frontend.Variable
+
+	// PublicWeight is the number of times the pairs are sent to the shuffler
+	PublicWeight frontend.Variable `gnark:",public"`
+
+	// Hash is the hash of the commitment, see params.go
+	Hash hasher.HashConfig `gnark:"-"`
+}
+
+func (circuit *WeightedVoteCircuit) Define(api frontend.API) error {
+	processedVec := rankingInCircuit(api, circuit.PublicR, circuit.SortedCandidate, circuit.PairFirstVar, circuit.PairSecondVar)
+
+	// the product of the pairs raised to the weight, by square and multiply
+	// from the most significant bit
+	pairProd := PolyEvalInCircuit(api, processedVec, circuit.PublicR)
+	weightBits := gadget.Label(api, gadget.Threshold).ToBinary(circuit.PublicWeight, WeightBits)
+	privateProd := frontend.Variable(1)
+	for i := len(weightBits) - 1; i >= 0; i-- {
+		privateProd = api.Mul(privateProd, privateProd)
+		privateProd = api.Select(weightBits[i], api.Mul(privateProd, pairProd), privateProd)
+	}
+	privateProd = api.Mul(privateProd, circuit.PrivateMask)
+	gadget.Label(api, gadget.Product).AssertIsEqual(privateProd, circuit.PublicProd)
+
+	dummyProd := frontend.Variable(1)
+	for i := 0; i < len(circuit.DummyVec); i++ {
+		dummyProd = api.Mul(dummyProd, circuit.DummyVec[i])
+	}
+	gadget.Label(api, gadget.Product).AssertIsEqual(circuit.PrivateMask, dummyProd)
+
+	// the commitment binds one copy of the pairs; the weight is public
+	h, err := circuit.Hash.Circuit(api)
+	if err != nil {
+		return err
+	}
+	h.Write(processedVec...)
+	h.Write(circuit.DummyVec...)
+	h.Write(circuit.PrivateMask)
+	h.Write(circuit.PrivateSalt)
+	gadget.Label(api, gadget.Commitment).AssertIsEqual(circuit.PublicCommitment, h.Sum())
+	return nil
+}
+
+// NewWeightedVoteCircuit allocates an empty weighted circuit for
+// candidateNum candidates and dummyNum dummies, hashing with Params.Hash,
+// ready to be compiled. Its public variables are WeightedPublic.
+func NewWeightedVoteCircuit(candidateNum int, dummyNum int) WeightedVoteCircuit {
+	return WeightedVoteCircuit{
+		SortedCandidate:  make([]frontend.Variable, candidateNum),
+		PairFirstVar:     make([]frontend.Variable, candidateNum*(candidateNum-1)/2),
+		PairSecondVar:    make([]frontend.Variable, candidateNum*(candidateNum-1)/2),
+		PrivateMask:      0,
+		DummyVec:         make([]frontend.Variable, dummyNum),
+		PublicR:          0,
+		PublicProd:       0,
+		PublicCommitment: 0,
+		PrivateSalt:      0,
+		PublicWeight:     0,
+		Hash:             Params.Hash,
+	}
+}
+
+// WeightedClientState is the ClientState of a client of weight Weight. Its
+// pairs are those of one ranking; SharedPairs are what it sends the shuffler.
+type WeightedClientState struct {
+	ClientState
+	Weight uint64
+}
+
+// Init samples a random ranking for a client of the given weight together
+// with dummyNum dummies and computes the commitment to them in field.
+func (c *WeightedClientState) Init(field Field, dummyNum uint64, weight uint64) {
+	c.InitRand(benchrand.New(0), field, dummyNum, weight)
+}
+
+// InitRand is Init drawing all the randomness of the client from rnd.
+func (c *WeightedClientState) InitRand(rnd *benchrand.Rand, field Field, dummyNum uint64, weight uint64) {
+	c.ClientState.InitRand(rnd, field, dummyNum)
+	c.Weight = weight
+}
+
+// ComputePolyEval is ClientState.ComputePolyEval with the product of the
+// pairs raised to the weight.
+func (c *WeightedClientState) ComputePolyEval(publicR fr_bn254.Element) *big.Int {
+	pairProd := c.field.PolyEval(c.PrivateX, publicR)
+	prod := big.NewInt(1)
+	for i := 63; i >= 0; i-- {
+		prod = c.field.Mul(prod, prod)
+		if c.Weight>>uint(i)&1 == 1 {
+			prod = c.field.Mul(prod, pairProd)
+		}
+	}
+	return c.field.Mul(prod, c.PrivateMask)
+}
+
+// SharedPairs returns the pairs of the client, each repeated Weight times.
+func (c *WeightedClientState) SharedPairs() ([]fr_bn254.Element, []fr_bn254.Element) {
+	var pairFirst, pairSecond []fr_bn254.Element
+	for k := uint64(0); k < c.Weight; k++ {
+		pairFirst = append(pairFirst, c.PairFirst...)
+		pairSecond = append(pairSecond, c.PairSecond...)
+	}
+	return pairFirst, pairSecond
+}
+
+// GenAssignment is ClientState.GenAssignment for the weighted ballot of the
+// client.
+func (c *WeightedClientState) GenAssignment(publicR fr_bn254.Element) WeightedVoteCircuit {
+	assignment := c.ClientState.GenAssignment(publicR).Circuit
+	return WeightedVoteCircuit{
+		SortedCandidate:  assignment.SortedCandidate,
+		PairFirstVar:     assignment.PairFirstVar,
+		PairSecondVar:    assignment.PairSecondVar,
+		PrivateMask:      assignment.PrivateMask,
+		DummyVec:         assignment.DummyVec,
+		PublicR:          assignment.PublicR,
+		PublicProd:       frontend.Variable(c.ComputePolyEval(publicR)),
+		PublicCommitment: assignment.PublicCommitment,
+		PrivateSalt:      assignment.PrivateSalt,
+		PublicWeight:     c.Weight,
+	}
+}
+
+// ComputeWeightedTally is ComputeTally for clients of weights summing up to
+// totalWeight: every pair of candidates has to be decided by the whole
+// weight, the number of voters of an unweighted tally.
+func ComputeWeightedTally(shuffledPairFirst, shuffledPairSecond []fr_bn254.Element, candidateNum int, totalWeight uint64) (TallyResult, error) {
+	res, err := ComputeTally(shuffledPairFirst, shuffledPairSecond, candidateNum)
+	if err != nil {
+		return TallyResult{}, err
+	}
+	if !res.Pairs.Consistent(int(totalWeight)) {
+		return TallyResult{}, fmt.Errorf("%w: the pairs do not add up to a total weight of %v", ErrBallotKind, totalWeight)
+	}
+	return res, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+
+	"example/verification/internal/benchrand"
+	"example/verification/internal/shuffle"
+)
+
+func TestWeightedVoteCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	circuit := NewWeightedVoteCircuit(CandidateNum, 4)
+
+	var client WeightedClientState
+	client.InitRand(benchrand.New(1), bn254Field{}, 4, 3)
+	assignment := client.GenAssignment(randomFr())
+	assert.ProverSucceeded(&circuit, &assignment, test.WithCurves(ecc.BN254))
+
+	// the product of a client of weight 3 claimed for a weight of 2
+	lighter := assignment
+	lighter.PublicWeight = 2
+	assert.ProverFailed(&circuit, &lighter, test.WithCurves(ecc.BN254))
+}
+
+// TestWeightedElection runs three clients of weights 1, 2 and 3: the shuffled
+// pairs match the product of the clients and the tally adds up to 6.
+func TestWeightedElection(t *testing.T) {
+	field := bn254Field{}
+	rnd := benchrand.New(5)
+	publicR := rnd.Fr()
+	s := shuffle.New(rnd, 2)
+	clientsProd := big.NewInt(1)
+	for i, weight := range []uint64{1, 2, 3} {
+		var c WeightedClientState
+		c.InitRand(rnd, field, 2, weight)
+		clientsProd = field.Mul(clientsProd, c.ComputePolyEval(publicR))
+		pairFirst, pairSecond := c.SharedPairs()
+		s.CollectShares(i, shuffle.Records(pairFirst, pairSecond))
+		s.CollectDummies(i, c.PrivateY)
+	}
+	records, dummies := s.Output()
+	pairFirst, pairSecond := shuffle.Column(records, 0), shuffle.Column(records, 1)
+	if ShufflerProduct(field, pairFirst, pairSecond, dummies, publicR).Cmp(clientsProd) != 0 {
+		t.Fatal("the product of the shuffled pairs is not the product of the clients")
+	}
+	if _, err := ComputeWeightedTally(pairFirst, pairSecond, CandidateNum, 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ComputeWeightedTally(pairFirst, pairSecond, CandidateNum, 3); !errors.Is(err, ErrBallotKind) {
+		t.Fatalf("a tally of weight 6 taken for 3 voters: got %v, expected ErrBallotKind", err)
+	}
+}
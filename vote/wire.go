@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	fr_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/witness"
+
+	"example/verification/internal/gnarkshim"
+)
+
+// The wire format of a ClientSubmission is a single length-prefixed frame.
+// All integers are big-endian:
+//
+//	uint32  length of the rest of the frame
+//	byte    wireVersion
+//	byte    backend of the proof system (backend.ID)
+//	byte    curve of the proof system (ecc.ID), since version 2
+//	[32]byte publicProd
+//	uint32  proof length, followed by the proof
+//	uint32  public witness length, followed by the public witness
+//
+// A submission without a proof has empty proof and public witness sections.
+// Version 1 frames have no curve byte and are read as BN254 submissions.
+
+const wireVersion = 2
+
+// maxWireFrameSize bounds the frames accepted by ReadFrom, so a corrupted
+// length prefix cannot trigger a huge allocation.
+const maxWireFrameSize = 1 << 24
+
+var ErrWireFormat = errors.New("wire: malformed submission")
+
+// WriteTo writes the submission as one frame.
+func (s *ClientSubmission) WriteTo(w io.Writer) (int64, error) {
+	if s.system == nil {
+		return 0, errors.New("wire: the submission has no proof system")
+	}
+	var publicWitness []byte
+	if s.publicWitness != nil {
+		var err error
+		if publicWitness, err = s.publicWitness.MarshalBinary(); err != nil {
+			return 0, fmt.Errorf("wire: public witness: %w", err)
+		}
+	}
+
+	var body bytes.Buffer
+	body.WriteByte(wireVersion)
+	body.WriteByte(byte(s.system.Backend()))
+	body.WriteByte(byte(s.system.Curve()))
+	var b [fr_bn254.Bytes]byte
+	s.publicProd.FillBytes(b[:])
+	body.Write(b[:])
+	writeSection(&body, s.proof)
+	writeSection(&body, publicWitness)
+
+	var frame [4]byte
+	binary.BigEndian.PutUint32(frame[:], uint32(body.Len()))
+	n, err := w.Write(frame[:])
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := body.WriteTo(w)
+	return int64(n) + m, err
+}
+
+func writeSection(buf *bytes.Buffer, section []byte) {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(section)))
+	buf.Write(l[:])
+	buf.Write(section)
+}
+
+// ReadFrom reads one frame written by WriteTo. If s.system is already set, the
+// backend and curve of the frame must match it; otherwise a fresh ProofSystem
+// of that backend and curve is attached to the submission. A truncated or
+// malformed frame is reported as an error wrapping ErrWireFormat.
+func (s *ClientSubmission) ReadFrom(r io.Reader) (int64, error) {
+	var frame [4]byte
+	n, err := io.ReadFull(r, frame[:])
+	if err != nil {
+		return int64(n), wireError(err)
+	}
+	size := binary.BigEndian.Uint32(frame[:])
+	if size > maxWireFrameSize {
+		return int64(n), fmt.Errorf("%w: frame of %v bytes", ErrWireFormat, size)
+	}
+	body := make([]byte, size)
+	m, err := io.ReadFull(r, body)
+	if err != nil {
+		return int64(n + m), wireError(err)
+	}
+	return int64(n + m), s.decode(body)
+}
+
+func wireError(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated frame", ErrWireFormat)
+	}
+	return err
+}
+
+func (s *ClientSubmission) decode(body []byte) error {
+	if len(body) < 1 {
+		return fmt.Errorf("%w: truncated header", ErrWireFormat)
+	}
+	headerSize := 3
+	switch body[0] {
+	case 1:
+		headerSize = 2
+	case wireVersion:
+	default:
+		return fmt.Errorf("%w: unsupported version %v", ErrWireFormat, body[0])
+	}
+	if len(body) < headerSize+fr_bn254.Bytes {
+		return fmt.Errorf("%w: truncated header", ErrWireFormat)
+	}
+	id := backend.ID(body[1])
+	curve := ecc.BN254
+	if headerSize == 3 {
+		curve = ecc.ID(body[2])
+	}
+	system := s.system
+	if system == nil {
+		var err error
+		if system, err = NewProofSystem(id, curve); err != nil {
+			return fmt.Errorf("%w: %v", ErrWireFormat, err)
+		}
+	} else if system.Backend() != id || system.Curve() != curve {
+		return fmt.Errorf("%w: got a %v submission on %v, expected %v on %v", ErrWireFormat, id, curve, system.Backend(), system.Curve())
+	}
+
+	publicProd := new(big.Int).SetBytes(body[headerSize : headerSize+fr_bn254.Bytes])
+	if publicProd.Cmp(curve.ScalarField()) >= 0 {
+		return fmt.Errorf("%w: publicProd is not reduced", ErrWireFormat)
+	}
+	rest := body[headerSize+fr_bn254.Bytes:]
+
+	proof, rest, err := readSection(rest)
+	if err != nil {
+		return fmt.Errorf("%w: proof: %v", ErrWireFormat, err)
+	}
+	publicWitnessBytes, rest, err := readSection(rest)
+	if err != nil {
+		return fmt.Errorf("%w: public witness: %v", ErrWireFormat, err)
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("%w: %v trailing bytes", ErrWireFormat, len(rest))
+	}
+	if (len(proof) == 0) != (len(publicWitnessBytes) == 0) {
+		return fmt.Errorf("%w: a proof needs a public witness and vice versa", ErrWireFormat)
+	}
+
+	var publicWitness witness.Witness
+	if len(publicWitnessBytes) > 0 {
+		if publicWitness, err = decodePublicWitness(curve, publicWitnessBytes); err != nil {
+			return fmt.Errorf("%w: public witness: %v", ErrWireFormat, err)
+		}
+	} else {
+		proof = nil
+	}
+
+	s.publicWitness = publicWitness
+	s.publicProd = publicProd
+	s.proof = proof
+	s.system = system
+	return nil
+}
+
+// readSection splits a uint32 length-prefixed section off b.
+func readSection(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, errors.New("truncated length")
+	}
+	l := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if uint64(l) > uint64(len(b)) {
+		return nil, nil, fmt.Errorf("section of %v bytes, %v left", l, len(b))
+	}
+	return b[:l], b[l:], nil
+}
+
+// decodePublicWitness parses a witness on curve. The witness is serialized as
+// nbPublic, nbSecret and a length-prefixed vector; the vector length is checked
+// against the data before gnark allocates it. Every supported curve has
+// 32-byte scalars.
+func decodePublicWitness(curve ecc.ID, b []byte) (witness.Witness, error) {
+	if len(b) < 12 {
+		return nil, errors.New("truncated header")
+	}
+	if l := binary.BigEndian.Uint32(b[8:12]); uint64(l)*fr_bn254.Bytes != uint64(len(b)-12) {
+		return nil, fmt.Errorf("vector of %v elements in %v bytes", l, len(b)-12)
+	}
+	publicWitness, err := gnarkshim.NewEmptyWitness(curve)
+	if err != nil {
+		return nil, err
+	}
+	if err := publicWitness.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return publicWitness, nil
+}
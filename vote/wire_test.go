@@ -0,0 +1,80 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+func TestSubmissionWireRoundTrip(t *testing.T) {
+	systems := []ProofSystem{NewGroth16System(ecc.BN254), NewPlonkSystem(ecc.BN254), NewGroth16System(ecc.BLS12_381)}
+	for _, system := range systems {
+		t.Run(system.Name()+"/"+system.Curve().String(), func(t *testing.T) {
+			allSubmission, vk := genSubmissions(t, system, 2)
+			// client 1 does not attach a proof
+			allSubmission[1].proof = nil
+			allSubmission[1].publicWitness = nil
+
+			var buf bytes.Buffer
+			for i := 0; i < len(allSubmission); i++ {
+				if _, err := allSubmission[i].WriteTo(&buf); err != nil {
+					t.Fatal(err)
+				}
+			}
+			decoded := make([]ClientSubmission, len(allSubmission))
+			for i := 0; i < len(decoded); i++ {
+				if _, err := decoded[i].ReadFrom(&buf); err != nil {
+					t.Fatalf("client %v: %v", i, err)
+				}
+				if decoded[i].system.Backend() != system.Backend() || decoded[i].system.Curve() != system.Curve() {
+					t.Fatalf("client %v: decoded a %v submission on %v", i, decoded[i].system.Backend(), decoded[i].system.Curve())
+				}
+				if decoded[i].publicProd.Cmp(allSubmission[i].publicProd) != 0 {
+					t.Fatalf("client %v: publicProd differs", i)
+				}
+			}
+			if decoded[1].proof != nil || decoded[1].publicWitness != nil {
+				t.Fatal("client 1: a proof appeared on the wire")
+			}
+			if errs := VerifySubmissions(decoded, vk); len(errs) != 0 {
+				t.Fatal(errs)
+			}
+		})
+	}
+}
+
+func TestSubmissionWireMalformed(t *testing.T) {
+	allSubmission, _ := genSubmissions(t, NewGroth16System(ecc.BN254), 1)
+	var buf bytes.Buffer
+	if _, err := allSubmission[0].WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+
+	for l := 0; l < len(frame); l++ {
+		var s ClientSubmission
+		if _, err := s.ReadFrom(bytes.NewReader(frame[:l])); err == nil {
+			t.Fatalf("no error for a frame truncated to %v bytes", l)
+		}
+	}
+
+	// a frame whose sections do not add up to its length
+	corrupted := append([]byte(nil), frame...)
+	proofLen := corrupted[4+3+32 : 4+3+32+4]
+	binary.BigEndian.PutUint32(proofLen, binary.BigEndian.Uint32(proofLen)+1)
+	var s ClientSubmission
+	if _, err := s.ReadFrom(bytes.NewReader(corrupted)); !errors.Is(err, ErrWireFormat) {
+		t.Fatalf("expected ErrWireFormat, got %v", err)
+	}
+
+	// a groth16 submission read into a plonk one
+	s = ClientSubmission{system: NewPlonkSystem(ecc.BN254)}
+	if _, err := s.ReadFrom(bytes.NewReader(frame)); !errors.Is(err, ErrWireFormat) {
+		t.Fatalf("expected ErrWireFormat, got %v", err)
+	}
+}